@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/api"
+)
+
+// maxCompareResponseSize bounds the size of the responses gt compare reads.
+const maxCompareResponseSize = 256 << 20
+
+// compareUsage prints the usage of the compare command to stderr.
+func compareUsage() {
+	prog := os.Args[0]
+	fmt.Fprintf(os.Stderr, "\nUsage: %s compare --a url --b url [options]\n", prog)
+	fmt.Fprintln(os.Stderr, "Compares the trust state of two running servers and reports the differences; exits 0 if they agree and 1 if not.")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --a            Base URL of the first server")
+	fmt.Fprintln(os.Stderr, "  --b            Base URL of the second server")
+	fmt.Fprintln(os.Stderr, "  --tenant       Compare the certificate pools of this tenant")
+	fmt.Fprintln(os.Stderr, "  --timeout      Timeout of each request (default: 30s)")
+	fmt.Fprintln(os.Stderr, "")
+}
+
+// trustSnapshot is the trust state of a running server compared by gt
+// compare.
+type trustSnapshot struct {
+	version        api.VersionResponse
+	poolGeneration uint64
+	certs          map[string]string // Subjects of the pool certificates by SHA-256 fingerprint
+	tsls           []string          // Summaries of the loaded TSLs, sorted
+}
+
+// trustHash returns a hash of the certificates of s, equal on servers
+// trusting the same certificates.
+func (s *trustSnapshot) trustHash() string {
+	fingerprints := make([]string, 0, len(s.certs))
+	for fp := range s.certs {
+		fingerprints = append(fingerprints, fp)
+	}
+	slices.Sort(fingerprints)
+	sum := sha256.Sum256([]byte(strings.Join(fingerprints, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// runCompare implements "gt compare": it fetches the build information and
+// pipeline hash (GET /version), the certificate pool (GET /certificates) and
+// the loaded TSLs (GET /info) of two servers, prints their differences to w
+// and returns the process exit code: 0 if the servers agree, 1 if they
+// differ or cannot be reached, and 2 on invalid arguments. Pool generations
+// are counted by each server and are shown but not compared. It detects
+// instances of a multi-region deployment that have diverged in what they
+// trust.
+func runCompare(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.Usage = compareUsage
+	urlA := fs.String("a", "", "Base URL of the first server")
+	urlB := fs.String("b", "", "Base URL of the second server")
+	tenant := fs.String("tenant", "", "Compare the certificate pools of this tenant")
+	timeout := fs.Duration("timeout", 30*time.Second, "Timeout of each request")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		compareUsage()
+		return 2
+	}
+	if *urlA == "" || *urlB == "" {
+		fmt.Fprintln(os.Stderr, "Error: --a and --b are required")
+		compareUsage()
+		return 2
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	a, err := fetchTrustSnapshot(client, *urlA, *tenant)
+	if err != nil {
+		fmt.Fprintf(w, "error: a: %v\n", err)
+		return 1
+	}
+	b, err := fetchTrustSnapshot(client, *urlB, *tenant)
+	if err != nil {
+		fmt.Fprintf(w, "error: b: %v\n", err)
+		return 1
+	}
+
+	for _, s := range []struct {
+		name, url string
+		snapshot  *trustSnapshot
+	}{{"a", *urlA, a}, {"b", *urlB, b}} {
+		fmt.Fprintf(w, "%s: %s: version %s, pool generation %d, %d TSLs, %d certificates, trust hash %s\n",
+			s.name, s.url, s.snapshot.version.Version, s.snapshot.poolGeneration,
+			len(s.snapshot.tsls), len(s.snapshot.certs), s.snapshot.trustHash())
+	}
+
+	differences := compareSnapshots(w, a, b)
+	if differences == 0 {
+		fmt.Fprintln(w, "no differences")
+		return 0
+	}
+	fmt.Fprintf(w, "%d differences\n", differences)
+	return 1
+}
+
+// compareSnapshots prints the differences between a and b to w and returns
+// their number.
+func compareSnapshots(w io.Writer, a, b *trustSnapshot) int {
+	differences := 0
+	differ := func(name, valueA, valueB string) {
+		if valueA != valueB {
+			differences++
+			fmt.Fprintf(w, "differs: %s: a %q, b %q\n", name, valueA, valueB)
+		}
+	}
+	differ("version", a.version.Version, b.version.Version)
+	differ("pipeline_hash", a.version.PipelineHash, b.version.PipelineHash)
+	differ("registries", strings.Join(a.version.Features.Registries, ","), strings.Join(b.version.Features.Registries, ","))
+	differ("signing", strings.Join(a.version.Features.Signing, ","), strings.Join(b.version.Features.Signing, ","))
+
+	for _, d := range []struct {
+		name     string
+		from, to *trustSnapshot
+	}{{"a", a, b}, {"b", b, a}} {
+		for _, tsl := range d.from.tsls {
+			if _, found := slices.BinarySearch(d.to.tsls, tsl); !found {
+				differences++
+				fmt.Fprintf(w, "TSL only on %s: %s\n", d.name, tsl)
+			}
+		}
+	}
+	for _, d := range []struct {
+		name     string
+		from, to *trustSnapshot
+	}{{"a", a, b}, {"b", b, a}} {
+		fingerprints := make([]string, 0, len(d.from.certs))
+		for fp := range d.from.certs {
+			if _, ok := d.to.certs[fp]; !ok {
+				fingerprints = append(fingerprints, fp)
+			}
+		}
+		slices.Sort(fingerprints)
+		for _, fp := range fingerprints {
+			differences++
+			fmt.Fprintf(w, "certificate only on %s: %s %s\n", d.name, fp, d.from.certs[fp])
+		}
+	}
+	return differences
+}
+
+// fetchTrustSnapshot fetches the trust state of the server at baseURL, with
+// the certificate pool of tenant, if not empty.
+func fetchTrustSnapshot(client *http.Client, baseURL, tenant string) (*trustSnapshot, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	s := &trustSnapshot{certs: map[string]string{}}
+	if err := fetchJSON(client, baseURL+"/version", "", &s.version); err != nil {
+		return nil, err
+	}
+
+	var certs api.CertificatesResponse
+	if err := fetchJSON(client, baseURL+"/certificates", tenant, &certs); err != nil {
+		return nil, err
+	}
+	s.poolGeneration = certs.PoolGeneration
+	for _, cert := range certs.Certificates {
+		s.certs[cert.SHA256] = cert.Subject
+	}
+
+	var info struct {
+		Summaries []struct {
+			Summary string `json:"summary"`
+		} `json:"tsl_summaries"`
+	}
+	if err := fetchJSON(client, baseURL+"/info", "", &info); err != nil {
+		return nil, err
+	}
+	for _, tsl := range info.Summaries {
+		s.tsls = append(s.tsls, tsl.Summary)
+	}
+	slices.Sort(s.tsls)
+	return s, nil
+}
+
+// fetchJSON decodes the JSON response to GET url into v, asking for the
+// resources of tenant, if not empty.
+func fetchJSON(client *http.Client, url, tenant string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if tenant != "" {
+		req.Header.Set(api.TenantHeader, tenant)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxCompareResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: %w", url, err)
+	}
+	return nil
+}
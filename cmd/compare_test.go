@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCompareServer returns a server with the given pipeline hash, pool
+// generation, certificates by fingerprint and TSL summaries.
+func newCompareServer(t *testing.T, pipelineHash string, generation uint64, certs map[string]string, tsls ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/version":
+			_ = json.NewEncoder(w).Encode(api.VersionResponse{Version: "1.4.0", PipelineHash: pipelineHash})
+		case "/certificates":
+			resp := api.CertificatesResponse{PoolGeneration: generation}
+			if r.Header.Get(api.TenantHeader) == "" {
+				for fp, subject := range certs {
+					resp.Certificates = append(resp.Certificates, api.CertSummary{SHA256: fp, Subject: subject})
+				}
+			}
+			resp.Count = len(resp.Certificates)
+			_ = json.NewEncoder(w).Encode(resp)
+		case "/info":
+			summaries := []map[string]interface{}{}
+			for _, tsl := range tsls {
+				summaries = append(summaries, map[string]interface{}{"summary": tsl})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"tsl_summaries": summaries, "total": len(tsls)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunCompare(t *testing.T) {
+	certs := map[string]string{"aa": "CN=Root A", "bb": "CN=Root B"}
+	a := newCompareServer(t, "hash1", 3, certs, "TSL[Source: se.xml]")
+	b := newCompareServer(t, "hash1", 7, certs, "TSL[Source: se.xml]")
+
+	var buf bytes.Buffer
+	assert.Equal(t, 0, runCompare([]string{"--a", a.URL, "--b", b.URL + "/"}, &buf))
+	assert.Contains(t, buf.String(), "a: "+a.URL+": version 1.4.0, pool generation 3, 1 TSLs, 2 certificates, trust hash ")
+	assert.Contains(t, buf.String(), "b: "+b.URL+"/: version 1.4.0, pool generation 7, 1 TSLs, 2 certificates, trust hash ")
+	assert.Contains(t, buf.String(), "no differences\n")
+
+	c := newCompareServer(t, "hash2", 3, map[string]string{"aa": "CN=Root A", "cc": "CN=Root C"}, "TSL[Source: se.xml]", "TSL[Source: fi.xml]")
+	buf.Reset()
+	assert.Equal(t, 1, runCompare([]string{"--a", a.URL, "--b", c.URL}, &buf))
+	assert.Contains(t, buf.String(), `differs: pipeline_hash: a "hash1", b "hash2"`+"\n"+
+		"TSL only on b: TSL[Source: fi.xml]\n"+
+		"certificate only on a: bb CN=Root B\n"+
+		"certificate only on b: cc CN=Root C\n"+
+		"4 differences\n")
+
+	buf.Reset()
+	assert.Equal(t, 0, runCompare([]string{"--a", a.URL, "--b", b.URL, "--tenant", "acme"}, &buf))
+	assert.Contains(t, buf.String(), "0 certificates")
+	assert.NotContains(t, buf.String(), "certificate only on")
+
+	addr := c.URL
+	c.Close()
+	buf.Reset()
+	assert.Equal(t, 1, runCompare([]string{"--a", a.URL, "--b", addr, "--timeout", "1s"}, &buf))
+	assert.Contains(t, buf.String(), "error: b: ")
+}
+
+func TestRunCompare_Arguments(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Equal(t, 2, runCompare([]string{"--no-such-flag"}, &buf))
+	assert.Equal(t, 2, runCompare([]string{"--a", "http://a"}, &buf))
+	assert.Equal(t, 2, runCompare([]string{"--a", "http://a", "--b", "http://b", "extra"}, &buf))
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+)
+
+// Outcomes of a doctor check.
+const (
+	checkPass = "PASS"
+	checkFail = "FAIL"
+	checkSkip = "SKIP"
+)
+
+// doctorCheck is the outcome of a single diagnostics check.
+type doctorCheck struct {
+	Status string // checkPass, checkFail or checkSkip
+	Name   string // What was checked, e.g. "connectivity https://example.com/tsl.xml"
+	Detail string // Why the check passed, failed or was skipped
+}
+
+// doctorReport collects the outcomes of the diagnostics checks.
+type doctorReport struct {
+	checks []doctorCheck
+}
+
+func (r *doctorReport) pass(name, format string, args ...any) {
+	r.checks = append(r.checks, doctorCheck{checkPass, name, fmt.Sprintf(format, args...)})
+}
+
+func (r *doctorReport) fail(name string, err error) {
+	r.checks = append(r.checks, doctorCheck{checkFail, name, err.Error()})
+}
+
+func (r *doctorReport) skip(name, format string, args ...any) {
+	r.checks = append(r.checks, doctorCheck{checkSkip, name, fmt.Sprintf(format, args...)})
+}
+
+// failed returns the number of failed checks.
+func (r *doctorReport) failed() int {
+	n := 0
+	for _, c := range r.checks {
+		if c.Status == checkFail {
+			n++
+		}
+	}
+	return n
+}
+
+// print writes the report, one check per line, followed by a summary.
+func (r *doctorReport) print(w io.Writer) {
+	passed, skipped := 0, 0
+	for _, c := range r.checks {
+		switch c.Status {
+		case checkPass:
+			passed++
+		case checkSkip:
+			skipped++
+		}
+		fmt.Fprintf(w, "[%s] %s: %s\n", c.Status, c.Name, c.Detail)
+	}
+	fmt.Fprintf(w, "\n%d checks: %d passed, %d failed, %d skipped\n", len(r.checks), passed, r.failed(), skipped)
+}
+
+// doctorUsage prints the usage of the doctor command to stderr.
+func doctorUsage() {
+	prog := os.Args[0]
+	fmt.Fprintf(os.Stderr, "\nUsage: %s doctor [options] [pipeline.yaml]...\n", prog)
+	fmt.Fprintln(os.Stderr, "Checks the runtime environment and prints a pass/fail report.")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --config       Configuration file path (YAML format)")
+	fmt.Fprintln(os.Stderr, "  --profile      Configuration profile (overrides GT_PROFILE)")
+	fmt.Fprintln(os.Stderr, "  --timeout      Timeout of each connectivity check (default: 10s)")
+	fmt.Fprintln(os.Stderr, "")
+}
+
+// runDoctor implements "gt doctor": it checks that the configuration and the
+// pipeline are consistent and that the environment provides what the pipeline
+// needs (xsltproc for transform steps, loadable PKCS#11 modules and valid
+// credentials for signing, connectivity to the TSL URLs of load steps, and writable output and log
+// directories), prints a report to w and returns the process exit code: 0 if
+// every check passed, 1 if any failed and 2 on invalid arguments.
+func runDoctor(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.Usage = doctorUsage
+	configFile := fs.String("config", "", "Configuration file path (YAML format)")
+	profile := fs.String("profile", "", "Configuration profile (overrides GT_PROFILE)")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of each connectivity check")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	report := &doctorReport{}
+
+	cfg, err := config.LoadConfigProfile(*configFile, *profile)
+	if err == nil {
+		err = cfg.Validate()
+	}
+	switch {
+	case err != nil:
+		report.fail("config", err)
+		cfg = nil
+	case *configFile != "" && cfg.Profile != "":
+		report.pass("config", "%s is valid with profile %s", *configFile, cfg.Profile)
+	case *configFile != "":
+		report.pass("config", "%s is valid", *configFile)
+	case cfg.Profile != "":
+		report.pass("config", "profile %s and environment are valid", cfg.Profile)
+	default:
+		report.pass("config", "defaults and environment are valid")
+	}
+
+	var pipelines []*pipeline.Pipeline
+	if fs.NArg() > 0 {
+		if pl := checkPipelineFile(report, "pipeline", fs.Args()...); pl != nil {
+			pipelines = append(pipelines, pl)
+		}
+	} else {
+		report.skip("pipeline", "no pipeline file given")
+	}
+
+	if cfg != nil {
+		for _, tenant := range cfg.Security.Tenants {
+			if tenant.Pipeline == "" {
+				continue
+			}
+			if pl := checkPipelineFile(report, "tenant "+tenant.ID+" pipeline", tenant.Pipeline); pl != nil {
+				pipelines = append(pipelines, pl)
+			}
+		}
+		checkLogOutput(report, "log output", cfg.Logging.Output)
+		if cfg.Logging.AccessLog != "" {
+			checkLogOutput(report, "access log output", cfg.Logging.AccessLog)
+		}
+		if cfg.Server.PublishDir != "" {
+			if info, err := os.Stat(cfg.Server.PublishDir); err != nil {
+				report.fail("publish_dir "+cfg.Server.PublishDir, err)
+			} else if !info.IsDir() {
+				report.fail("publish_dir "+cfg.Server.PublishDir, errors.New("not a directory"))
+			} else {
+				report.pass("publish_dir "+cfg.Server.PublishDir, "directory exists")
+			}
+		}
+	}
+
+	checkXSLT(report, pipelines)
+	checkPKCS11(report, pipelines)
+	checkSigners(report, pipelines)
+	checkConnectivity(report, pipelines, *timeout)
+	checkOutputDirectories(report, pipelines)
+
+	report.print(w)
+	if report.failed() > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkPipelineFile parses the pipeline files and directories at paths and
+// checks that every step they use is registered. It returns the pipeline if
+// it could be parsed.
+func checkPipelineFile(report *doctorReport, name string, paths ...string) *pipeline.Pipeline {
+	path := strings.Join(paths, ", ")
+	pl, err := pipeline.NewPipelineFiles(paths...)
+	if err != nil {
+		report.fail(name, err)
+		return nil
+	}
+
+	var unknown []string
+	for _, pipe := range pl.Pipes {
+		if _, ok := pipeline.GetFunctionByName(pipe.MethodName); !ok {
+			step := pipe.MethodName
+			if suggestion := pipeline.SuggestStep(step); suggestion != "" {
+				step += fmt.Sprintf(" (%s, did you mean %s?)", pipeLocation(pipe), suggestion)
+			}
+			unknown = append(unknown, step)
+		}
+	}
+	if len(unknown) > 0 {
+		report.fail(name, fmt.Errorf("%s: unknown steps %s", path, strings.Join(unknown, ", ")))
+	} else {
+		report.pass(name, "%s has %d steps", path, len(pl.Pipes))
+	}
+	return pl
+}
+
+// pipeLocation returns the line of pipe, and its file if the pipeline was
+// read from several.
+func pipeLocation(pipe pipeline.Pipe) string {
+	if pipe.File != "" {
+		return fmt.Sprintf("%s line %d", pipe.File, pipe.Line)
+	}
+	return fmt.Sprintf("line %d", pipe.Line)
+}
+
+// pipelineSteps returns the steps with the given name in pipelines.
+func pipelineSteps(pipelines []*pipeline.Pipeline, name string) []pipeline.Pipe {
+	var pipes []pipeline.Pipe
+	for _, pl := range pipelines {
+		for _, pipe := range pl.Pipes {
+			if pipe.MethodName == name {
+				pipes = append(pipes, pipe)
+			}
+		}
+	}
+	return pipes
+}
+
+// checkXSLT checks that xsltproc is installed if a pipeline transforms TSLs.
+func checkXSLT(report *doctorReport, pipelines []*pipeline.Pipeline) {
+	if len(pipelineSteps(pipelines, "transform")) == 0 {
+		report.skip("xsltproc", "no transform steps")
+		return
+	}
+	path, err := exec.LookPath("xsltproc")
+	if err != nil {
+		report.fail("xsltproc", fmt.Errorf("required by transform steps: %w", err))
+		return
+	}
+	report.pass("xsltproc", "found at %s", path)
+}
+
+// checkPKCS11 checks that the PKCS#11 modules of signing publish steps load.
+func checkPKCS11(report *doctorReport, pipelines []*pipeline.Pipeline) {
+	found := false
+	checked := make(map[string]bool)
+	for _, pipe := range pipelineSteps(pipelines, "publish") {
+		for _, arg := range pipe.MethodArguments {
+			if !strings.HasPrefix(arg, "pkcs11:") {
+				continue
+			}
+			found = true
+			cfg := dsig.ExtractPKCS11Config(arg)
+			if cfg == nil {
+				report.fail("pkcs11", errors.New("invalid PKCS#11 URI in publish step"))
+				continue
+			}
+			if checked[cfg.Path] {
+				continue
+			}
+			checked[cfg.Path] = true
+			if err := dsig.CheckPKCS11Module(cfg.Path); err != nil {
+				report.fail("pkcs11 "+cfg.Path, err)
+			} else {
+				report.pass("pkcs11 "+cfg.Path, "module loads")
+			}
+		}
+	}
+	if !found {
+		report.skip("pkcs11", "no PKCS#11 signing")
+	}
+}
+
+// checkSigners checks the signing credentials of the publish steps: that the
+// certificate and key load, belong together and are valid now.
+func checkSigners(report *doctorReport, pipelines []*pipeline.Pipeline) {
+	found := false
+	for _, pl := range pipelines {
+		if len(pl.SigningBackends()) == 0 {
+			continue
+		}
+		found = true
+		if err := pl.CheckSigners(time.Now()); err != nil {
+			report.fail("signers", err)
+		} else {
+			report.pass("signers", "credentials of %s valid", strings.Join(pl.SigningBackends(), ", "))
+		}
+	}
+	if !found {
+		report.skip("signers", "no signing publish steps")
+	}
+}
+
+// checkConnectivity checks that the TSL URLs of load steps can be fetched,
+// and that local TSL files can be read.
+func checkConnectivity(report *doctorReport, pipelines []*pipeline.Pipeline, timeout time.Duration) {
+	loads := pipelineSteps(pipelines, "load")
+	if len(loads) == 0 {
+		report.skip("connectivity", "no load steps")
+		return
+	}
+
+	client := &http.Client{Timeout: timeout}
+	for _, pipe := range loads {
+		if len(pipe.MethodArguments) == 0 {
+			continue
+		}
+		source := pipe.MethodArguments[0]
+		name := "connectivity " + source
+		switch {
+		case source == pipeline.StdinSource:
+			report.skip(name, "read from the standard input")
+			continue
+		case strings.HasPrefix(source, "data:"):
+			report.skip(name, "inline data: URL")
+			continue
+		case strings.Contains(source, "{{"):
+			report.skip(name, "set by pipeline variables when the pipeline runs")
+			continue
+		}
+		if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+			f, err := os.Open(strings.TrimPrefix(source, "file://"))
+			if err != nil {
+				report.fail(name, err)
+				continue
+			}
+			f.Close()
+			report.pass(name, "file is readable")
+			continue
+		}
+
+		start := time.Now()
+		resp, err := client.Get(source)
+		if err != nil {
+			report.fail(name, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			report.fail(name, fmt.Errorf("HTTP %s", resp.Status))
+			continue
+		}
+		report.pass(name, "HTTP %d in %s", resp.StatusCode, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// checkOutputDirectories checks that the output directories of publish and
+// transform steps are writable.
+func checkOutputDirectories(report *doctorReport, pipelines []*pipeline.Pipeline) {
+	var dirs []string
+	for _, pipe := range pipelineSteps(pipelines, "publish") {
+		if len(pipe.MethodArguments) > 0 {
+			dirs = append(dirs, pipe.MethodArguments[0])
+		}
+	}
+	for _, pipe := range pipelineSteps(pipelines, "transform") {
+		if len(pipe.MethodArguments) > 1 && pipe.MethodArguments[1] != "replace" {
+			dirs = append(dirs, pipe.MethodArguments[1])
+		}
+	}
+	if len(dirs) == 0 {
+		report.skip("output directories", "no publish or transform output directories")
+		return
+	}
+
+	checked := make(map[string]bool)
+	for _, dir := range dirs {
+		if checked[dir] {
+			continue
+		}
+		checked[dir] = true
+		if err := checkWritable(pipeline.StaticOutputDir(dir)); err != nil {
+			report.fail("output directory "+dir, err)
+		} else {
+			report.pass("output directory "+dir, "writable")
+		}
+	}
+}
+
+// checkLogOutput checks that the log file, if logging goes to one, can be
+// created in its directory.
+func checkLogOutput(report *doctorReport, name, output string) {
+	switch strings.ToLower(output) {
+	case "", "stdout", "stderr":
+		report.skip(name, "logging to %s", strings.ToLower(output))
+		return
+	}
+	if err := checkWritable(filepath.Dir(output)); err != nil {
+		report.fail(name+" "+output, err)
+		return
+	}
+	report.pass(name+" "+output, "directory is writable")
+}
+
+// checkWritable checks that a file can be created in dir. If dir does not
+// exist yet, its nearest existing parent must be writable, since the steps
+// create missing directories.
+func checkWritable(dir string) error {
+	dir = filepath.Clean(dir)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	f, err := os.CreateTemp(dir, ".gt-doctor-*")
+	if err != nil {
+		return fmt.Errorf("cannot create files in %s: %w", dir, err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
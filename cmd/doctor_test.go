@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePipeline writes a pipeline YAML file to a temporary directory.
+func writePipeline(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestRunDoctor_Pass(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<TrustServiceStatusList/>"))
+	}))
+	defer server.Close()
+
+	out := filepath.Join(t.TempDir(), "not", "yet", "created")
+	path := writePipeline(t, "- load:\n  - "+server.URL+"/tsl.xml\n- publish:\n  - "+out+"\n")
+
+	var buf bytes.Buffer
+	code := runDoctor([]string{path}, &buf)
+	report := buf.String()
+	assert.Equal(t, 0, code, report)
+	assert.Contains(t, report, "[PASS] config:")
+	assert.Contains(t, report, "[PASS] pipeline: "+path+" has 2 steps")
+	assert.Contains(t, report, "[PASS] connectivity "+server.URL+"/tsl.xml: HTTP 200")
+	assert.Contains(t, report, "[PASS] output directory "+out+": writable")
+	assert.Contains(t, report, "[SKIP] xsltproc: no transform steps")
+	assert.Contains(t, report, "[SKIP] pkcs11: no PKCS#11 signing")
+	assert.Contains(t, report, "[SKIP] signers: no signing publish steps")
+	assert.Contains(t, report, "0 failed")
+}
+
+func TestRunDoctor_Failures(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	t.Setenv("PATH", t.TempDir())
+	notDir := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(notDir, nil, 0644))
+
+	path := writePipeline(t, `- load:
+  - `+server.URL+`/missing.xml
+- load:
+  - /nonexistent/tsl.xml
+- load:
+  - "-"
+- load:
+  - "{{.Vars.source}}"
+- transform:
+  - embedded:tsl-to-html.xslt
+  - replace
+- publish:
+  - `+notDir+`
+  - pkcs11:module=/nonexistent/libpkcs11.so
+- no-such-step:
+  - x
+- selct:
+  - all
+`)
+
+	var buf bytes.Buffer
+	code := runDoctor([]string{path}, &buf)
+	report := buf.String()
+	assert.Equal(t, 1, code, report)
+	assert.Contains(t, report, "[FAIL] pipeline: "+path+": unknown steps no-such-step, selct (line 17, did you mean select?)")
+	assert.Contains(t, report, "[FAIL] connectivity "+server.URL+"/missing.xml: HTTP 404")
+	assert.Contains(t, report, "[FAIL] connectivity /nonexistent/tsl.xml:")
+	assert.Contains(t, report, "[SKIP] connectivity -: read from the standard input")
+	assert.Contains(t, report, "[SKIP] connectivity {{.Vars.source}}: set by pipeline variables when the pipeline runs")
+	assert.Contains(t, report, "[FAIL] xsltproc: required by transform steps")
+	assert.Contains(t, report, "[FAIL] pkcs11 /nonexistent/libpkcs11.so:")
+	assert.Contains(t, report, "[FAIL] signers: step 5 (publish) failed: invalid pkcs11 signer:")
+	assert.Contains(t, report, "[FAIL] output directory "+notDir+": "+notDir+" is not a directory")
+	assert.Contains(t, report, "7 failed")
+}
+
+func TestRunDoctor_PipelineDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00-load.yaml"), []byte("- load:\n  - /nonexistent/tsl.xml\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "10-select.yaml"), []byte("- select:\n  - all\n- selct:\n  - all\n"), 0644))
+
+	var buf bytes.Buffer
+	code := runDoctor([]string{dir}, &buf)
+	report := buf.String()
+	assert.Equal(t, 1, code, report)
+	assert.Contains(t, report, "[FAIL] pipeline: "+dir+": unknown steps selct ("+filepath.Join(dir, "10-select.yaml")+" line 3, did you mean select?)")
+}
+
+func TestRunDoctor_ConfigAndArguments(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Equal(t, 2, runDoctor([]string{"--no-such-flag"}, &buf))
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("logging:\n  level: loud\n"), 0644))
+
+	buf.Reset()
+	assert.Equal(t, 1, runDoctor([]string{"--config", configPath}, &buf))
+	assert.Contains(t, buf.String(), "[FAIL] config:")
+	assert.Contains(t, buf.String(), "[SKIP] pipeline: no pipeline file given")
+}
+
+func TestRunDoctor_Profile(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Equal(t, 0, runDoctor([]string{"--profile", "airgapped"}, &buf))
+	assert.Contains(t, buf.String(), "[PASS] config: profile airgapped and environment are valid")
+
+	buf.Reset()
+	assert.Equal(t, 1, runDoctor([]string{"--profile", "prod"}, &buf))
+	assert.Contains(t, buf.String(), `unknown profile "prod"`)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultHealthcheckURL is the API server gt healthcheck asks by default,
+// that of the default configuration.
+const defaultHealthcheckURL = "http://127.0.0.1:6001"
+
+// healthcheckUsage prints the usage of the healthcheck command to stderr.
+func healthcheckUsage() {
+	prog := os.Args[0]
+	fmt.Fprintf(os.Stderr, "\nUsage: %s healthcheck [options]\n", prog)
+	fmt.Fprintln(os.Stderr, "Asks a running server whether it is ready, for exec probes; exits 0 if it is and 1 if not.")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintf(os.Stderr, "  --url          Base URL of the API server (default: %s)\n", defaultHealthcheckURL)
+	fmt.Fprintln(os.Stderr, "  --live         Check liveness (/healthz) instead of readiness (/readyz)")
+	fmt.Fprintln(os.Stderr, "  --timeout      Timeout of the request (default: 5s)")
+	fmt.Fprintln(os.Stderr, "")
+}
+
+// runHealthcheck implements "gt healthcheck": it requests GET /readyz, or
+// GET /healthz with --live, from the API server, prints the outcome to w and
+// returns the process exit code: 0 if the server answered 200, 1 if it
+// answered otherwise or could not be reached, and 2 on invalid arguments.
+// It lets exec probes of systemd, Nomad or Kubernetes check the server
+// without curl in the image.
+func runHealthcheck(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	fs.Usage = healthcheckUsage
+	baseURL := fs.String("url", defaultHealthcheckURL, "Base URL of the API server")
+	live := fs.Bool("live", false, "Check liveness (/healthz) instead of readiness (/readyz)")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout of the request")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		healthcheckUsage()
+		return 2
+	}
+
+	endpoint := strings.TrimSuffix(*baseURL, "/") + "/readyz"
+	if *live {
+		endpoint = strings.TrimSuffix(*baseURL, "/") + "/healthz"
+	}
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		fmt.Fprintf(w, "unhealthy: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	status := body.Status
+	if status == "" {
+		status = resp.Status
+	}
+	if body.Message != "" {
+		status += ": " + body.Message
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(w, "unhealthy: %s (HTTP %d)\n", status, resp.StatusCode)
+		return 1
+	}
+	fmt.Fprintf(w, "%s\n", status)
+	return 0
+}
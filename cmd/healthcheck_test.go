@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunHealthcheck(t *testing.T) {
+	var ready atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/healthz":
+			_, _ = w.Write([]byte(`{"status":"ok"}`))
+		case r.URL.Path == "/readyz" && ready.Load():
+			_, _ = w.Write([]byte(`{"status":"ready","message":"Service is ready to accept traffic"}`))
+		case r.URL.Path == "/readyz":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"not_ready","message":"No TSLs loaded yet"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	assert.Equal(t, 1, runHealthcheck([]string{"--url", server.URL}, &buf))
+	assert.Equal(t, "unhealthy: not_ready: No TSLs loaded yet (HTTP 503)\n", buf.String())
+
+	buf.Reset()
+	assert.Equal(t, 0, runHealthcheck([]string{"--url", server.URL + "/", "--live"}, &buf))
+	assert.Equal(t, "ok\n", buf.String())
+
+	ready.Store(true)
+	buf.Reset()
+	assert.Equal(t, 0, runHealthcheck([]string{"--url", server.URL}, &buf))
+	assert.Equal(t, "ready: Service is ready to accept traffic\n", buf.String())
+
+	addr := server.URL
+	server.Close()
+	buf.Reset()
+	assert.Equal(t, 1, runHealthcheck([]string{"--url", addr, "--timeout", "1s"}, &buf))
+	assert.Contains(t, buf.String(), "unhealthy:")
+
+	assert.Equal(t, 2, runHealthcheck([]string{"--no-such-flag"}, &buf))
+	assert.Equal(t, 2, runHealthcheck([]string{"extra"}, &buf))
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"embed"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultLOTL is the list of trusted lists loaded by the pipeline created by
+// "gt init".
+const DefaultLOTL = "https://ec.europa.eu/tools/lotl/eu-lotl.xml"
+
+// initTemplates holds the files created by "gt init". Files ending in .tmpl
+// are rendered with text/template and written without the suffix; the rest
+// are copied as they are.
+//
+//go:embed templates/init
+var initTemplates embed.FS
+
+// initTemplateRoot is the directory of the templates in initTemplates.
+const initTemplateRoot = "templates/init"
+
+// initCertificate is the path of the certificate of the example service,
+// created by "gt init" rather than embedded so that it is valid when used.
+var initCertificate = filepath.Join("tsl", "providers", "example-provider", "example-ca.pem")
+
+// initData is the data the .tmpl files are rendered with.
+type initData struct {
+	LOTL string // URL of the list of trusted lists to load
+}
+
+func initUsage() {
+	prog := os.Args[0]
+	fmt.Fprintf(os.Stderr, "\nUsage: %s init [options] [directory]\n", prog)
+	fmt.Fprintln(os.Stderr, "Creates a working directory with a configuration file, a pipeline loading the")
+	fmt.Fprintln(os.Stderr, "EU trusted lists and a directory tree to generate your own trusted list from.")
+	fmt.Fprintln(os.Stderr, "The directory defaults to the current directory.")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --lotl         URL of the list of trusted lists to load (default: "+DefaultLOTL+")")
+	fmt.Fprintln(os.Stderr, "  --force        Overwrite existing files")
+	fmt.Fprintln(os.Stderr, "")
+}
+
+// runInit implements "gt init": it writes the embedded example files to a
+// directory, prints the files created and the next steps to w and returns the
+// process exit code: 0 on success, 1 if the files could not be written or
+// already exist without --force, and 2 on invalid arguments.
+func runInit(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.Usage = initUsage
+	lotl := fs.String("lotl", DefaultLOTL, "URL of the list of trusted lists to load")
+	force := fs.Bool("force", false, "Overwrite existing files")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		initUsage()
+		return 2
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	files, err := renderInitFiles(initData{LOTL: *lotl})
+	if err != nil {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return 1
+	}
+	if !*force {
+		var existing []string
+		for _, name := range sortedKeys(files) {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				existing = append(existing, name)
+			}
+		}
+		if len(existing) > 0 {
+			fmt.Fprintf(w, "Error: %s already has %s; use --force to overwrite\n", dir, strings.Join(existing, ", "))
+			return 1
+		}
+	}
+
+	for _, name := range sortedKeys(files) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Fprintf(w, "Error: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(path, files[name], 0644); err != nil {
+			fmt.Fprintf(w, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(w, "Created in %s:\n", dir)
+	for _, name := range sortedKeys(files) {
+		fmt.Fprintf(w, "  %s\n", filepath.ToSlash(name))
+	}
+	fmt.Fprintln(w, "\nNext steps:")
+	if dir != "." {
+		fmt.Fprintf(w, "  cd %s\n", dir)
+	}
+	fmt.Fprintln(w, "  gt doctor --config config.yaml pipeline.yaml   # check the setup")
+	fmt.Fprintln(w, "  gt --config config.yaml pipeline.yaml          # serve decisions based on the EU trusted lists")
+	fmt.Fprintln(w, "  gt --no-server generate.yaml                   # publish your own trusted list")
+	return 0
+}
+
+// renderInitFiles returns the files created by "gt init", by path relative
+// to the directory: the rendered templates and the certificate of the
+// example service.
+func renderInitFiles(data initData) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := fs.WalkDir(initTemplates, initTemplateRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := initTemplates.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(strings.TrimPrefix(path, initTemplateRoot+"/"))
+		if strings.HasSuffix(name, ".tmpl") {
+			tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("invalid template %s: %w", name, err)
+			}
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return fmt.Errorf("failed to render %s: %w", name, err)
+			}
+			name, content = strings.TrimSuffix(name, ".tmpl"), buf.Bytes()
+		}
+		files[name] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := exampleCertificate(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the example certificate: %w", err)
+	}
+	files[initCertificate] = cert
+	return files, nil
+}
+
+// exampleCertificate returns a PEM encoded self-signed CA certificate, valid
+// for a year from now, for the example service of the generated trusted list.
+// Its key is discarded: the certificate is only listed, never used to sign.
+func exampleCertificate(now time.Time) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Country:      []string{"SE"},
+			Organization: []string{"Example Trust Services AB"},
+			CommonName:   "Example Certificate Authority",
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// sortedKeys returns the keys of files, sorted.
+func sortedKeys(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
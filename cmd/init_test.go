@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "trust")
+	var buf bytes.Buffer
+	require.Equal(t, 0, runInit([]string{"--lotl", "https://tsl.example.org/lotl.xml", dir}, &buf), buf.String())
+	out := buf.String()
+	assert.Contains(t, out, "Created in "+dir)
+	assert.Contains(t, out, "tsl/providers/example-provider/example-ca.pem")
+	assert.Contains(t, out, "cd "+dir)
+
+	for _, name := range []string{"README.md", "config.yaml", "pipeline.yaml", "generate.yaml", "tsl/scheme.yaml",
+		"tsl/providers/example-provider/provider.yaml", "tsl/providers/example-provider/example-ca.yaml"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "pipeline.yaml.tmpl"))
+
+	// The configuration is valid and the pipeline loads the given list
+	cfg, err := config.LoadConfig(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	pl, err := pipeline.NewPipeline(filepath.Join(dir, "pipeline.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, pl.Pipes[1].MethodArguments, "https://tsl.example.org/lotl.xml")
+
+	// The example certificate is valid now
+	data, err := os.ReadFile(filepath.Join(dir, "tsl/providers/example-provider/example-ca.pem"))
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.True(t, time.Now().Before(cert.NotAfter))
+
+	// The generate pipeline publishes a trusted list with the example service
+	t.Chdir(dir)
+	pl, err = pipeline.NewPipeline("generate.yaml")
+	require.NoError(t, err)
+	ctx, err := pl.Process(pipeline.NewContext())
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctx.TSLs.Size())
+	assert.FileExists(t, filepath.Join(dir, "published", "generated", "tsl.xml"))
+}
+
+func TestRunInit_Existing(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("# mine\n"), 0644))
+
+	var buf bytes.Buffer
+	assert.Equal(t, 1, runInit([]string{dir}, &buf))
+	assert.Contains(t, buf.String(), "already has config.yaml; use --force to overwrite")
+	data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "# mine\n", string(data))
+	assert.NoFileExists(t, filepath.Join(dir, "pipeline.yaml"))
+
+	buf.Reset()
+	assert.Equal(t, 0, runInit([]string{"--force", dir}, &buf))
+	assert.FileExists(t, filepath.Join(dir, "pipeline.yaml"))
+
+	assert.Equal(t, 2, runInit([]string{"a", "b"}, &buf))
+}
@@ -21,12 +21,16 @@
 //   - publish:
 //   - /path/to/output
 //
+// Several pipeline files, or a directory whose *.yaml files are read in
+// lexical order (00-fetch.yaml, 10-load.yaml, 20-publish.yaml), may be given
+// instead of one file; their steps are run in the order given.
+//
 // # Available Pipeline Steps
 //
 // The following pipeline steps are available:
 //
 // - [pipeline.LoadTSL]: Loads a TSL from a URL or file path
-//   - Args: URL or file path to load the TSL from
+//   - Args: URL, file path, "-" for the standard input, or data: URL to load the TSL from
 //
 // - [pipeline.SelectCertPool]: Builds a certificate pool from the loaded TSLs
 //   - Args: Service type URI filter (optional)
@@ -38,7 +42,8 @@
 //   - Args: Path to scheme metadata YAML file, path to certificate metadata YAML file(s)
 //
 // - [pipeline.PublishTSL]: Serializes TSLs to XML files in a directory
-//   - Args: Output directory path, optional signer configuration
+//   - Args: Output directory path, optional signer configuration, options such
+//     as "dry-run" to compare with the published files instead of writing
 //
 // - [pipeline.TransformTSL]: Applies XSLT transformation to TSLs
 //   - Args: XSLT stylesheet path, mode ("replace" or output directory), extension (optional)
@@ -50,12 +55,54 @@
 //
 // Command line options:
 //
+//	--profile      Configuration profile: production, airgapped, dev or one defined in the configuration file
 //	--host         API server hostname (default: 127.0.0.1)
 //	--port         API server port (default: 6001)
+//	--external-url External URL for PDP discovery (default: http://host:port)
 //	--frequency    Pipeline update frequency (default: 5m)
+//	--swagger      Serve the Swagger UI under /swagger/index.html
+//	--mock         Serve the bundled mock TSL instead of a pipeline file
+//	--set          Set a pipeline variable, name=value, used by steps as {{.Vars.name}} (repeatable)
 //	--version      Show version information
 //	--help         Show help message
 //
+// The doctor command checks the runtime environment before deployment and
+// prints a pass/fail report: configuration and pipeline consistency, xsltproc
+// for transform steps, PKCS#11 module loading, connectivity to the TSL URLs
+// of load steps, and writable output and log directories. It exits 1 if any
+// check fails.
+//
+//	gt doctor [--config file] [--profile name] [--timeout 10s] [pipeline.yaml]...
+//
+// The init command creates a working directory to start from: a configuration
+// file, a pipeline loading the EU trusted lists and publishing them, and a
+// pipeline generating a trusted list from an example scheme, provider and
+// certificate tree. It refuses to overwrite existing files without --force.
+//
+//	gt init [--lotl url] [--force] [directory]
+//
+// The healthcheck command asks a running server whether it is ready (GET
+// /readyz, or /healthz with --live) and exits 0 if it is and 1 if not, for
+// exec probes in images without curl. server.health_addr additionally opens
+// a bare TCP listener once the server is ready, for TCP probes.
+//
+//	gt healthcheck [--url http://127.0.0.1:6001] [--live] [--timeout 5s]
+//
+// The replay command replays decisions recorded in audit logs written with
+// logging.audit.include_requests, or in JSONL files of AuthZEN requests,
+// against a running PDP or, with --pipeline, against trust data evaluated
+// in-process, and reports the decisions that differ from the recorded ones.
+// It exits 0 if all match and 1 if not, to validate upgrades.
+//
+//	gt replay [--url url | --pipeline pipeline.yaml] [--config file] [--speed 1] [--reasons] [file]...
+//
+// The compare command fetches the version and pipeline hash, certificate
+// pool and loaded TSLs of two running servers and reports how they differ,
+// to detect instances of a multi-region deployment that no longer trust the
+// same certificates. It exits 0 if they agree and 1 if not.
+//
+//	gt compare --a url --b url [--tenant name] [--timeout 30s]
+//
 // Logging options:
 //
 //	--log-level    Logging level: debug, info, warn, error, fatal (default: info)
@@ -83,26 +130,95 @@
 //	                          Returns 200 if ready, 503 if not ready
 //	                          Returns: {"status": "ready|not_ready", "ready": bool, "tsl_count": <number>, ...}
 //
+//	GET /version            - Version, commit, build date, Go version, enabled features
+//	                          and the SHA-256 hash of the active pipeline file
+//
 //	GET /metrics            - Prometheus metrics endpoint for monitoring
 //	                          Returns: Prometheus-formatted metrics (text/plain)
 //
+//	GET /openapi.json       - OpenAPI specification of the API, for generating clients
+//	GET /swagger/*          - Swagger UI (with --swagger or server.swagger)
+//
+//	GET, HEAD /published/*  - Published TSL files and sidecars (when server.publish_dir is set)
+//	                          Supports ETag, Last-Modified and conditional requests
+//
 // See: https://github.com/SUNET/go-trust for more information
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/SUNET/go-trust/docs/swagger"
+	"github.com/SUNET/go-trust/pkg/analytics"
 	"github.com/SUNET/go-trust/pkg/api"
+	"github.com/SUNET/go-trust/pkg/audit"
 	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/SUNET/go-trust/pkg/dsig"
 	"github.com/SUNET/go-trust/pkg/logging"
 	"github.com/SUNET/go-trust/pkg/pipeline"
-	"github.com/gin-gonic/gin"
+	"github.com/SUNET/go-trust/pkg/registry"
+	"github.com/SUNET/go-trust/pkg/registry/oidfed"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/SUNET/go-trust/pkg/utils/x509util"
 )
 
+// @title Go-Trust API
+// @version 1.0
+// @description Trust decision engine for ETSI TS 119612 Trust Status Lists (TSLs)
+// @description
+// @description Go-Trust provides AuthZEN-based trust decisions for X.509 certificates using ETSI trust status lists.
+// @description It processes TSLs, validates certificates, and provides health/metrics endpoints for production deployment.
+// @termsOfService https://github.com/SUNET/go-trust
+
+// @contact.name SUNET
+// @contact.url https://github.com/SUNET/go-trust
+// @contact.email noreply@sunet.se
+
+// @license.name BSD-2-Clause
+// @license.url https://opensource.org/licenses/BSD-2-Clause
+
+// @host localhost:6001
+// @BasePath /
+
+// @schemes http https
+
+// @tag.name Health
+// @tag.description Health check and readiness endpoints for Kubernetes and monitoring systems
+
+// @tag.name Status
+// @tag.description Server status and TSL information endpoints
+
+// @tag.name AuthZEN
+// @tag.description AuthZEN protocol endpoints for trust decision evaluation
+
+// @tag.name TSLs
+// @tag.description Loaded TSLs and the certificates trusted from them
+
+// @tag.name Published
+// @tag.description Published TSL files for mirrors
+
+// @tag.name Proxy
+// @tag.description Fetched TSLs re-served with their original signatures
+
+// @tag.name Metrics
+// @tag.description Prometheus metrics
+
+// @tag.name Admin
+// @tag.description Diagnostics protected by the admin token
+
+// @tag.name Docs
+// @tag.description OpenAPI specification of this API
+
 // Version is set at build time using -ldflags
 // It represents the current version of the Go-Trust application.
 // Default value is "dev" for development builds. In production,
@@ -110,6 +226,14 @@ import (
 // go build -ldflags "-X main.Version=1.0.0" ./cmd
 var Version = "dev"
 
+// Commit and BuildDate identify the build and are reported by GET /version.
+// They can be set at build time like Version; if they are not, the VCS
+// information recorded by the Go toolchain is used.
+var (
+	Commit    = ""
+	BuildDate = ""
+)
+
 // parseLogLevel converts a string log level to the corresponding LogLevel enum value.
 // This is used to convert command-line arguments to the internal level representation.
 //
@@ -141,19 +265,57 @@ func parseLogLevel(level string) logging.LogLevel {
 	}
 }
 
+// subcommand is a command of the binary other than running pipelines, run as
+// "gt <name> [arguments]".
+type subcommand struct {
+	name  string
+	args  string                               // Synopsis of the arguments, for the usage
+	about string                               // What the command does, for the usage
+	run   func(args []string, w io.Writer) int // Runs the command, returning the process exit code
+}
+
+// subcommands are the subcommands of the binary, in the order of the usage.
+var subcommands = []subcommand{
+	{"doctor", "[--config file] [pipeline.yaml]...", "Check the runtime environment", runDoctor},
+	{"init", "[--lotl url] [--force] [directory]", "Create a working directory to start from", runInit},
+	{"healthcheck", "[--url url] [--live]", "Check that a running server is ready", runHealthcheck},
+	{"replay", "[--url url] [--speed 1] [file]...", "Replay recorded decisions and report mismatches", runReplay},
+	{"compare", "--a url --b url", "Compare the trust state of two running servers", runCompare},
+}
+
+// findSubcommand returns the subcommand called name, or nil if there is none.
+func findSubcommand(name string) *subcommand {
+	for i := range subcommands {
+		if subcommands[i].name == name {
+			return &subcommands[i]
+		}
+	}
+	return nil
+}
+
 // usage prints the command-line usage information to stderr.
 // It shows the available command-line options and their descriptions.
 func usage() {
 	prog := os.Args[0]
-	fmt.Fprintf(os.Stderr, "\nUsage: %s [options] <pipeline.yaml>\n", prog)
+	fmt.Fprintf(os.Stderr, "\nUsage: %s [options] <pipeline.yaml>...\n", prog)
+	fmt.Fprintf(os.Stderr, "       %s [options] --mock\n", prog)
+	for _, cmd := range subcommands {
+		fmt.Fprintf(os.Stderr, "       %s %s %s  %s\n", prog, cmd.name, cmd.args, cmd.about)
+	}
+	fmt.Fprintln(os.Stderr, "Pipeline files run in the order given; a directory stands for its *.yaml files in lexical order.")
 	fmt.Fprintln(os.Stderr, "Options:")
 	fmt.Fprintln(os.Stderr, "  --help         Show this help message and exit.")
 	fmt.Fprintln(os.Stderr, "  --version      Show version information and exit.")
 	fmt.Fprintln(os.Stderr, "  --config       Configuration file path (YAML format)")
+	fmt.Fprintln(os.Stderr, "  --profile      Configuration profile: production, airgapped, dev or one defined in the configuration file")
 	fmt.Fprintln(os.Stderr, "  --host         API server hostname (default: 127.0.0.1)")
 	fmt.Fprintln(os.Stderr, "  --port         API server port (default: 6001)")
+	fmt.Fprintln(os.Stderr, "  --external-url External URL for PDP discovery (e.g., https://pdp.example.com)")
 	fmt.Fprintln(os.Stderr, "  --frequency    Pipeline update frequency (default: 5m)")
-	fmt.Fprintln(os.Stderr, "  --no-server    Run pipeline once and exit (no API server)")
+	fmt.Fprintln(os.Stderr, "  --swagger      Serve the Swagger UI under /swagger/index.html")
+	fmt.Fprintln(os.Stderr, "  --no-server    Run pipeline once and exit (no API server); exits 2 if a dry-run publish finds changes")
+	fmt.Fprintln(os.Stderr, "  --mock         Serve the bundled mock TSL instead of a pipeline (offline client testing)")
+	fmt.Fprintln(os.Stderr, "  --set          Set a pipeline variable, name=value, used by steps as {{.Vars.name}} (repeatable)")
 	fmt.Fprintln(os.Stderr, "Logging options:")
 	fmt.Fprintln(os.Stderr, "  --log-level    Logging level: debug, info, warn, error, fatal (default: info)")
 	fmt.Fprintln(os.Stderr, "  --log-format   Logging format: text or json (default: text)")
@@ -161,21 +323,62 @@ func usage() {
 	fmt.Fprintln(os.Stderr, "\nConfiguration precedence (highest to lowest):")
 	fmt.Fprintln(os.Stderr, "  1. Command-line flags")
 	fmt.Fprintln(os.Stderr, "  2. Environment variables (GT_* prefix)")
-	fmt.Fprintln(os.Stderr, "  3. Configuration file (--config)")
-	fmt.Fprintln(os.Stderr, "  4. Built-in defaults")
+	fmt.Fprintln(os.Stderr, "  3. Profile section of the configuration file (--profile)")
+	fmt.Fprintln(os.Stderr, "  4. Configuration file (--config)")
+	fmt.Fprintln(os.Stderr, "  5. Bundled profile (--profile)")
+	fmt.Fprintln(os.Stderr, "  6. Built-in defaults")
 	fmt.Fprintln(os.Stderr, "")
 }
 
+// varFlags collects the name=value pairs of repeated --set flags.
+type varFlags []string
+
+// String implements flag.Value.
+func (v *varFlags) String() string {
+	return strings.Join(*v, ",")
+}
+
+// Set implements flag.Value.
+func (v *varFlags) Set(value string) error {
+	*v = append(*v, value)
+	return nil
+}
+
+// pipelineVars returns the context pipeline runs start from: a new context
+// with the pipeline variables of sets, which steps use as {{.Vars.name}},
+// such as the source of a load step.
+func pipelineVars(pl *pipeline.Pipeline, sets varFlags) (*pipeline.Context, error) {
+	ctx := pipeline.NewContext()
+	if len(sets) == 0 {
+		return ctx, nil
+	}
+	return pipeline.SetVars(pl, ctx, sets...)
+}
+
+// baseURL returns the URL under which clients reach the API, announced by
+// AuthZEN discovery: the configured external URL, or the listen address.
+func baseURL(cfg *config.Config) string {
+	if cfg.Server.ExternalURL != "" {
+		return strings.TrimSuffix(cfg.Server.ExternalURL, "/")
+	}
+	scheme := "http"
+	if cfg.Server.TLS.CertFile != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%s", scheme, cfg.Server.Host, cfg.Server.Port)
+}
+
 // main is the entry point for the Go-Trust application.
 //
 // It performs the following operations:
 // 1. Parses command-line arguments and options (including logging options)
 // 2. Configures structured logging based on command line arguments
-// 3. Loads the specified pipeline YAML file
+// 3. Loads the specified pipeline YAML file, or the bundled mock pipeline with --mock
 // 4. Initializes the server context with configured logger
 // 5. Starts a background updater to periodically process the pipeline
 // 6. Sets up the HTTP API server with Gin
 // 7. Starts the API server on the specified address and port
+// 8. On SIGINT or SIGTERM, drains the API server and stops the background updater
 //
 // The pipeline YAML file defines the steps to process Trust Status Lists (TSLs).
 // The processed TSLs are used by the API server to make trust decisions.
@@ -186,13 +389,25 @@ func usage() {
 // The log format can be either human-readable text or structured JSON for machine processing.
 // Log output can be directed to stdout, stderr, or a file path.
 func main() {
+	if len(os.Args) > 1 {
+		if cmd := findSubcommand(os.Args[1]); cmd != nil {
+			os.Exit(cmd.run(os.Args[2:], os.Stdout))
+		}
+	}
+
 	showHelp := flag.Bool("help", false, "Show help message")
 	showVersion := flag.Bool("version", false, "Show version information")
 	configFile := flag.String("config", "", "Configuration file path (YAML format)")
+	profile := flag.String("profile", "", "Configuration profile (overrides GT_PROFILE)")
 	host := flag.String("host", "", "API server hostname (overrides config file)")
 	port := flag.String("port", "", "API server port (overrides config file)")
+	externalURL := flag.String("external-url", "", "External URL for PDP discovery (overrides config file)")
 	freq := flag.Duration("frequency", 0, "Pipeline update frequency (overrides config file)")
+	swaggerUI := flag.Bool("swagger", false, "Serve the Swagger UI (overrides config file)")
 	noServer := flag.Bool("no-server", false, "Run pipeline once and exit (no API server)")
+	mock := flag.Bool("mock", false, "Serve the bundled mock TSL instead of a pipeline file")
+	var sets varFlags
+	flag.Var(&sets, "set", "Set a pipeline variable, name=value (repeatable)")
 
 	// Logging configuration
 	logLevel := flag.String("log-level", "", "Logging level (overrides config file)")
@@ -206,37 +421,60 @@ func main() {
 		os.Exit(0)
 	}
 	if *showVersion {
-		fmt.Println("Version:", Version)
+		info := api.NewBuildInfo(Version, Commit, BuildDate)
+		fmt.Println("Version:", info.Version)
+		if info.Commit != "" {
+			fmt.Println("Commit:", info.Commit)
+		}
+		if info.BuildDate != "" {
+			fmt.Println("Build date:", info.BuildDate)
+		}
+		fmt.Println("Go version:", info.GoVersion)
 		os.Exit(0)
 	}
 
 	args := flag.Args()
-	if len(args) < 1 {
+	var pipelineFiles []string
+	switch {
+	case *mock && len(args) > 0:
+		fmt.Fprintln(os.Stderr, "Error: --mock cannot be combined with pipeline YAML files.")
+		usage()
+		os.Exit(1)
+	case *mock:
+		pipelineFiles = []string{pipeline.MockTSLSource}
+	case len(args) < 1:
 		fmt.Fprintln(os.Stderr, "Error: missing pipeline YAML file argument.")
 		usage()
 		os.Exit(1)
+	default:
+		pipelineFiles = args
 	}
+	pipelineFile := strings.Join(pipelineFiles, ", ")
 
-	pipelineFile := args[0]
-
-	// Load configuration with precedence: defaults → config file → env vars → command-line flags
-	// Step 1 & 2 & 3: Load defaults, config file, and apply env vars
-	cfg, err := config.LoadConfig(*configFile)
+	// Load configuration with precedence: defaults → bundled profile → config file → profile section → env vars → command-line flags
+	// Step 1 to 5: Load defaults, the profile, config file, and apply env vars
+	cfg, err := config.LoadConfigProfile(*configFile, *profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Step 4: Apply command-line flag overrides (highest precedence)
+	// Step 6: Apply command-line flag overrides (highest precedence)
 	if *host != "" {
 		cfg.Server.Host = *host
 	}
 	if *port != "" {
 		cfg.Server.Port = *port
 	}
+	if *externalURL != "" {
+		cfg.Server.ExternalURL = *externalURL
+	}
 	if *freq != 0 {
 		cfg.Server.Frequency = *freq
 	}
+	if *swaggerUI {
+		cfg.Server.Swagger = true
+	}
 	if *logLevel != "" {
 		cfg.Logging.Level = *logLevel
 	}
@@ -253,6 +491,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Limit TSL downloads of all pipelines
+	pipeline.SetMaxDownloadSize(cfg.Pipeline.MaxDownloadSize)
+	pipeline.SetMaxReferencedTSLs(cfg.Pipeline.MaxReferencedTSLs)
+	pipeline.SetDownloadRateLimit(cfg.Pipeline.DownloadRateLimit)
+
+	// Tolerate clock differences, and check the clock against TSL servers
+	x509util.SetClockSkew(cfg.Security.ClockSkew)
+	pipeline.SetClockCheckThreshold(cfg.Pipeline.ClockCheckThreshold)
+
+	// Bound the certificate pools built by select steps
+	pipeline.SetMaxPoolSize(cfg.Pipeline.MaxPoolSize)
+
+	// Show TSL names in the preferred languages
+	i18n.SetPreferredLanguages(cfg.Server.Languages)
+
+	// Link API responses to the published provider pages
+	pipeline.SetProviderPagesURL(cfg.Server.ProviderPagesURL)
+
 	// Configure logger based on merged configuration
 	parsedLogLevel := parseLogLevel(cfg.Logging.Level)
 	var logger logging.Logger
@@ -264,36 +520,42 @@ func main() {
 	}
 
 	// Configure log output
-	output := strings.ToLower(cfg.Logging.Output)
-	switch output {
-	case "stdout":
-		// Default is already stdout
-	case "stderr":
-		logger.(logging.OutputConfigurable).SetOutput(os.Stderr)
-	default:
-		// Assume it's a file path
-		dir := filepath.Dir(cfg.Logging.Output)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to create log directory: %v\n", err)
+	if strings.ToLower(cfg.Logging.Output) != "stdout" {
+		out, err := logging.OpenOutput(cfg.Logging.Output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log output: %v\n", err)
 			os.Exit(1)
 		}
+		logger.(logging.OutputConfigurable).SetOutput(out)
+	}
+
+	if cfg.Profile != "" {
+		logger.Info("Configuration profile active", logging.F("profile", cfg.Profile))
+	}
 
-		file, err := os.OpenFile(cfg.Logging.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	// Configure pipeline with logger
+	var pl *pipeline.Pipeline
+	if *mock {
+		// Mock mode: bundled fixture TSL, deterministic decisions, no network access
+		pl = pipeline.MockPipeline(logger)
+		logger.Warn("Running in mock mode with bundled fixture TSL; decisions are not based on real trust lists")
+	} else {
+		pl, err = pipeline.NewPipelineFiles(pipelineFiles...)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Failed to load pipeline: %v\n", err)
 			os.Exit(1)
 		}
-		logger.(logging.OutputConfigurable).SetOutput(file)
-	}
+		// Create a pipeline with our configured logger
+		pl = pl.WithLogger(logger)
 
-	// Configure pipeline with logger
-	pl, err := pipeline.NewPipeline(pipelineFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load pipeline: %v\n", err)
-		os.Exit(1)
+		// Fail now rather than at the first scheduled publish if a signer is broken
+		if err := pl.CheckSigners(time.Now()); err != nil {
+			logger.Error("Invalid signer configuration",
+				logging.F("pipeline", pipelineFile),
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
 	}
-	// Create a pipeline with our configured logger
-	pl = pl.WithLogger(logger)
 
 	// If --no-server flag is set, run pipeline once and exit
 	if *noServer {
@@ -301,8 +563,19 @@ func main() {
 			logging.F("pipeline", pipelineFile),
 			logging.F("version", Version))
 
-		ctx := pipeline.NewContext()
-		_, err := pl.Process(ctx)
+		ctx, err := pipelineVars(pl, sets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --set: %v\n", err)
+			os.Exit(1)
+		}
+		_, err = pl.Process(ctx)
+		if pipeline.IsPublishDiff(err) {
+			// A dry-run publish found changes; exit 2 to tell them apart from failures
+			logger.Warn("Published output would change",
+				logging.F("error", err.Error()),
+				logging.F("pipeline", pipelineFile))
+			os.Exit(2)
+		}
 		if err != nil {
 			logger.Error("Pipeline execution failed",
 				logging.F("error", err.Error()),
@@ -318,51 +591,435 @@ func main() {
 	// Create server context with logger
 	serverCtx := api.NewServerContext(logger)
 	serverCtx.PipelineContext = pipeline.NewContext()
+	serverCtx.BaseURL = baseURL(cfg)
+	serverCtx.PublishDir = cfg.Server.PublishDir
+	if cfg.Server.Proxy.Enabled {
+		pipeline.SetRetainSourceDocuments(true)
+		serverCtx.Proxy = &api.ProxyOptions{
+			AllowUnverified: cfg.Server.Proxy.AllowUnverified,
+			MaxAge:          cfg.Server.Proxy.MaxAge,
+		}
+	}
+	serverCtx.AdminToken = cfg.Security.AdminToken
+	serverCtx.DecisionTimeout = cfg.Server.DecisionTimeout
+	serverCtx.ServerTiming = cfg.Server.ServerTiming
+	serverCtx.BuildInfo = api.NewBuildInfo(Version, Commit, BuildDate)
+	serverCtx.BuildInfo.PipelineHash = pl.Hash
+	serverCtx.BuildInfo.Signing = pl.SigningBackends()
+
+	// Configure the AuthZEN tenant allow-list if any tenants are listed
+	if len(cfg.Security.Tenants) > 0 {
+		serverCtx.TenantPolicy = newTenantPolicy(cfg.Security.Tenants)
+		logger.Info("Tenant policy configured",
+			logging.F("tenants", len(cfg.Security.Tenants)))
+	}
+
+	// Configure the fingerprint overrides of trust decisions
+	overridesCfg := cfg.Security.Overrides
+	overrides, err := api.NewOverrides(overridesCfg.Deny, overridesCfg.Allow, overridesCfg.File)
+	if err != nil {
+		logger.Error("Failed to load decision overrides",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
+	serverCtx.Overrides = overrides
+	if deny, allow := overrides.Len(); deny > 0 || allow > 0 || overridesCfg.File != "" {
+		logger.Info("Decision overrides configured",
+			logging.F("deny", deny),
+			logging.F("allow", allow),
+			logging.F("file", overridesCfg.File))
+	}
+
+	// Evaluate requests against the staged policy as well, if any, to
+	// compare its decisions with the active ones before cutover
+	stagedOverrides := overrides
+	if stagedCfg := cfg.Security.StagedPolicy; stagedCfg != nil {
+		stagedTenants := serverCtx.TenantPolicy
+		if len(stagedCfg.Tenants) > 0 {
+			stagedTenants = newTenantPolicy(stagedCfg.Tenants)
+		}
+		if o := stagedCfg.Overrides; o != nil {
+			stagedOverrides, err = api.NewOverrides(o.Deny, o.Allow, o.File)
+			if err != nil {
+				logger.Error("Failed to load staged decision overrides",
+					logging.F("error", err.Error()))
+				os.Exit(1)
+			}
+		}
+		serverCtx.StagedPolicy = api.NewStagedPolicy(stagedTenants, stagedOverrides)
+		logger.Info("Staged policy configured for shadow evaluation",
+			logging.F("tenants", len(stagedCfg.Tenants)),
+			logging.F("overrides", stagedCfg.Overrides != nil))
+	}
+
+	// Bind requests of some actions to the TLS client certificate of the caller
+	serverCtx.ClientBinding, err = api.NewClientBinding(cfg.Security.ClientBinding)
+	if err != nil {
+		logger.Error("Failed to configure client binding",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
+	if serverCtx.ClientBinding != nil {
+		logger.Info("Client certificate binding configured",
+			logging.F("actions", len(cfg.Security.ClientBinding)))
+	}
+
+	// Require subject.id to name the leaf certificate of resource.key
+	nameRules := make([]api.NameRule, 0, len(cfg.Security.NameMatching))
+	for _, rule := range cfg.Security.NameMatching {
+		nameRules = append(nameRules, api.NameRule{Actions: rule.Actions, Types: rule.Types, Match: rule.Match})
+	}
+	serverCtx.NameMatcher, err = api.NewNameMatcher(nameRules)
+	if err != nil {
+		logger.Error("Failed to configure name matching",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
+	if serverCtx.NameMatcher != nil {
+		logger.Info("Subject name matching configured",
+			logging.F("rules", len(nameRules)))
+	}
+
+	// Load the end-user messages of denial reasons
+	userMessages, err := api.LoadMessageCatalog(cfg.Server.UserMessages)
+	if err != nil {
+		logger.Error("Failed to load user message catalog",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
+	serverCtx.UserMessages = userMessages
+
+	// Send denied decisions to the configured webhook, if any
+	var denyWebhook *api.DenyWebhook
+	if hookCfg := cfg.Security.DenyWebhook; hookCfg.URL != "" {
+		denyWebhook, err = api.NewDenyWebhook(api.DenyWebhookOptions{
+			URL:           hookCfg.URL,
+			Actions:       hookCfg.Actions,
+			Token:         hookCfg.Token,
+			BatchSize:     hookCfg.BatchSize,
+			FlushInterval: hookCfg.FlushInterval,
+			MaxPerMinute:  hookCfg.MaxPerMinute,
+			MaxPending:    hookCfg.MaxPending,
+		}, logger)
+		if err != nil {
+			logger.Error("Failed to configure deny webhook",
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		serverCtx.DenyWebhook = denyWebhook
+		logger.Info("Deny webhook configured",
+			logging.F("url", hookCfg.URL),
+			logging.F("actions", hookCfg.Actions))
+	}
+
+	// Export decisions and admin requests to the configured SIEM target, if any
+	var auditExporter *audit.Exporter
+	if auditCfg := cfg.Logging.Audit; auditCfg.Target != "" {
+		auditExporter, err = audit.New(audit.Options{
+			Target:   auditCfg.Target,
+			Format:   auditCfg.Format,
+			Fields:   auditCfg.Fields,
+			Facility: auditCfg.Facility,
+			TLS: audit.TLSOptions{
+				CAFile:   auditCfg.TLS.CAFile,
+				CertFile: auditCfg.TLS.CertFile,
+				KeyFile:  auditCfg.TLS.KeyFile,
+			},
+			Queue:   auditCfg.QueueSize,
+			OnFull:  auditCfg.OnFull,
+			Version: Version,
+
+			IncludeRequests: auditCfg.IncludeRequests,
+		}, logger)
+		if err != nil {
+			logger.Error("Failed to configure audit export",
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		serverCtx.Audit = auditExporter
+		logger.Info("Audit export configured",
+			logging.F("target", auditCfg.Target),
+			logging.F("format", auditCfg.Format))
+	}
+
+	// Roll up decision counts into files for offline analysis, if configured
+	var analyticsRollup *analytics.Rollup
+	if analyticsCfg := cfg.Logging.Analytics; analyticsCfg.Dir != "" {
+		analyticsRollup, err = analytics.New(analytics.Options{
+			Dir:      analyticsCfg.Dir,
+			Format:   analyticsCfg.Format,
+			Interval: analyticsCfg.Interval,
+		}, logger)
+		if err != nil {
+			logger.Error("Failed to configure decision analytics",
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		serverCtx.Analytics = analyticsRollup
+		logger.Info("Decision analytics configured",
+			logging.F("dir", analyticsCfg.Dir),
+			logging.F("format", analyticsCfg.Format))
+	}
 
 	// Initialize Prometheus metrics
 	metrics := api.NewMetrics()
 	serverCtx.Metrics = metrics
+	// Latency and retries of remote signing services used by publish steps
+	if err := metrics.Register(dsig.RemoteSignerCollectors()...); err != nil {
+		logger.Error("Failed to register remote signer metrics",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
+	// Waits for the source and output locks shared by concurrent pipeline runs
+	if err := metrics.Register(pipeline.LockCollectors()...); err != nil {
+		logger.Error("Failed to register pipeline lock metrics",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
 	logger.Info("Metrics initialized")
 
-	// Configure rate limiting if enabled
-	if cfg.Security.RateLimitRPS > 0 {
-		// Use burst size of 10% of RPS, minimum of 5
-		burst := cfg.Security.RateLimitRPS / 10
-		if burst < 5 {
-			burst = 5
+	// Cancelled on SIGINT/SIGTERM to trigger graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Changes to the overrides file take effect without a restart
+	reloadInterval := overridesCfg.ReloadInterval
+	if reloadInterval == 0 {
+		reloadInterval = defaultOverridesReloadInterval
+	}
+	overrides.Watch(ctx, reloadInterval, logger)
+	if stagedCfg := cfg.Security.StagedPolicy; stagedCfg != nil && stagedCfg.Overrides != nil {
+		stagedReloadInterval := stagedCfg.Overrides.ReloadInterval
+		if stagedReloadInterval == 0 {
+			stagedReloadInterval = defaultOverridesReloadInterval
 		}
-		serverCtx.RateLimiter = api.NewRateLimiter(cfg.Security.RateLimitRPS, burst)
-		logger.Info("Rate limiting configured",
-			logging.F("rps", cfg.Security.RateLimitRPS),
-			logging.F("burst", burst))
+		stagedOverrides.Watch(ctx, stagedReloadInterval, logger)
 	}
+	denyWebhook.Start(ctx)
+	auditExporter.Start(ctx)
+	analyticsRollup.Start(ctx)
 
-	// Start background updater
-	api.StartBackgroundUpdater(pl, serverCtx, cfg.Server.Frequency)
+	// Route AuthZEN requests about federation entities to an OpenID
+	// Federation registry if one is configured. Trust chains of entities
+	// evaluated recently are resolved again in the background before they
+	// expire.
+	if cfg.Registries.OIDFed != nil {
+		reg, err := newOIDFedRegistry(cfg.Registries.OIDFed)
+		if err != nil {
+			logger.Error("Failed to configure OpenID Federation registry",
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		if err := metrics.Register(reg.CacheCollectors()...); err != nil {
+			logger.Error("Failed to register OpenID Federation cache metrics",
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		reg.StartPrefetch(ctx, oidfedPrefetchInterval)
 
-	// Gin API server
-	r := gin.Default()
+		// Evaluations, decisions, latency, errors and health of each
+		// registry are exported as go_trust_registry_* metrics
+		registryMetrics := registry.NewMetrics()
+		if err := metrics.Register(registryMetrics); err != nil {
+			logger.Error("Failed to register registry metrics",
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
 
-	// Register metrics endpoint first (includes middleware)
-	api.RegisterMetricsEndpoint(r, metrics)
+		mgr := registry.NewRegistryManager(registry.FirstMatch, 30*time.Second)
+		mgr.Register(registryMetrics.Instrument(reg))
+		serverCtx.RegistryManager = mgr
+		serverCtx.ComponentChecks = append(serverCtx.ComponentChecks, oidfedCacheComponent(reg))
+		logger.Info("OpenID Federation registry configured",
+			logging.F("trust_anchors", cfg.Registries.OIDFed.TrustAnchors))
+	}
 
-	// Register other API routes
-	api.RegisterAPIRoutes(r, serverCtx)
-	api.RegisterHealthEndpoints(r, serverCtx)
+	// Start background updater, with the pipeline variables of --set in every run
+	base, err := pipelineVars(pl, sets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --set: %v\n", err)
+		os.Exit(1)
+	}
+	updater, err := api.StartBackgroundUpdater(ctx, pl, serverCtx, cfg.Server.Frequency,
+		api.WithJitter(cfg.Server.FrequencyJitter), api.WithWarmUp(cfg.Server.WarmUpSamples), api.WithBaseContext(base))
+	if err != nil {
+		logger.Error("Failed to start background updater",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// Start one updater per tenant with its own pipeline
+	var tenantUpdaters []*api.BackgroundUpdater
+	for _, tenant := range cfg.Security.Tenants {
+		if tenant.Pipeline == "" {
+			continue
+		}
+		tenantPl, err := pipeline.NewPipeline(tenant.Pipeline)
+		if err != nil {
+			logger.Error("Failed to load tenant pipeline",
+				logging.F("tenant", tenant.ID),
+				logging.F("pipeline", tenant.Pipeline),
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		tenantPl = tenantPl.WithLogger(logger)
+		if err := tenantPl.CheckSigners(time.Now()); err != nil {
+			logger.Error("Invalid signer configuration",
+				logging.F("tenant", tenant.ID),
+				logging.F("pipeline", tenant.Pipeline),
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		tenantUpdater, err := api.StartBackgroundUpdater(ctx, tenantPl, serverCtx, cfg.Server.Frequency,
+			api.WithJitter(cfg.Server.FrequencyJitter), api.WithWarmUp(cfg.Server.WarmUpSamples), api.ForTenant(tenant.ID))
+		if err != nil {
+			logger.Error("Failed to start tenant background updater",
+				logging.F("tenant", tenant.ID),
+				logging.F("error", err.Error()))
+			os.Exit(1)
+		}
+		tenantUpdaters = append(tenantUpdaters, tenantUpdater)
+	}
+
+	// Bare TCP health listener for probes that only open a connection
+	if cfg.Server.HealthAddr != "" {
+		api.StartHealthListener(ctx, cfg.Server.HealthAddr, serverCtx)
+	}
+
+	// API server with the configured middleware stack
+	r, err := api.NewServer(cfg, serverCtx)
+	if err != nil {
+		logger.Error("Failed to set up API server",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
+	api.RegisterOpenAPIEndpoints(r, serverCtx, swagger.SwaggerInfo, cfg.Server.Swagger)
 	listenAddr := fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port)
+	tlsConfig, err := api.NewTLSConfig(cfg.Server.TLS)
+	if err != nil {
+		logger.Error("Failed to set up TLS",
+			logging.F("error", err.Error()))
+		os.Exit(1)
+	}
 
 	// Log startup information
 	logger.Info("API server starting",
 		logging.F("address", listenAddr),
 		logging.F("version", Version),
 		logging.F("pipeline", pipelineFile),
+		logging.F("base_url", serverCtx.BaseURL),
 		logging.F("log_level", cfg.Logging.Level),
-		logging.F("frequency", cfg.Server.Frequency.String()))
+		logging.F("frequency", cfg.Server.Frequency.String()),
+		logging.F("tls", tlsConfig != nil),
+		logging.F("client_auth", tlsConfig != nil && tlsConfig.ClientCAs != nil))
 
-	if err := r.Run(listenAddr); err != nil {
-		logger.Error("API server failed to start",
-			logging.F("error", err.Error()),
-			logging.F("address", listenAddr))
-		os.Exit(1)
+	srv := &http.Server{
+		Addr:      listenAddr,
+		Handler:   r,
+		TLSConfig: tlsConfig,
 	}
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			// The certificate is in TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("API server failed to start",
+				logging.F("error", err.Error()),
+				logging.F("address", listenAddr))
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Shutdown signal received, stopping API server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("API server shutdown failed",
+			logging.F("error", err.Error()))
+	}
+	updater.Stop()
+	for _, tenantUpdater := range tenantUpdaters {
+		tenantUpdater.Stop()
+	}
+	analyticsRollup.Wait()
+
+	logger.Info("Shutdown complete")
+}
+
+// oidfedPrefetchInterval is how often trust chains of hot federation
+// entities are checked for upcoming expiry.
+const oidfedPrefetchInterval = time.Minute
+
+// defaultOverridesReloadInterval is how often the decision overrides file is
+// checked for changes if no interval is configured.
+const defaultOverridesReloadInterval = 30 * time.Second
+
+// newTenantPolicy returns the tenant allow-list of tenants.
+func newTenantPolicy(tenants []config.TenantConfig) *api.TenantPolicy {
+	list := make([]api.Tenant, 0, len(tenants))
+	for _, t := range tenants {
+		list = append(list, api.Tenant{
+			ID:             t.ID,
+			Purposes:       t.Purposes,
+			AllowedActions: t.AllowedActions,
+			Territories:    t.Territories,
+		})
+	}
+	return api.NewTenantPolicy(list)
+}
+
+// oidfedCacheComponent returns the ComponentCheck of the trust chain cache
+// of reg, reporting its size and hits, and its last prefetch as its last
+// success.
+func oidfedCacheComponent(reg *oidfed.OIDFedRegistry) api.ComponentCheck {
+	return func() api.ComponentStatus {
+		stats := reg.CacheStats()
+		status := api.ComponentStatus{
+			Component: api.ComponentCache,
+			Name:      "oidfed trust chains",
+			Status:    api.HealthOK,
+			Message:   fmt.Sprintf("%d entries, %d hits, %d misses", stats.Entries, stats.Hits, stats.Misses),
+		}
+		if !stats.LastRefresh.IsZero() {
+			t := stats.LastRefresh.UTC()
+			status.LastSuccess = &t
+		}
+		return status
+	}
+}
+
+// newOIDFedRegistry returns the OpenID Federation registry configured by cfg,
+// which evaluates requests with resource.type "entity".
+func newOIDFedRegistry(cfg *config.OIDFedConfig) (*oidfed.OIDFedRegistry, error) {
+	anchors := make([]oidfed.TrustAnchorConfig, 0, len(cfg.TrustAnchors))
+	for _, entityID := range cfg.TrustAnchors {
+		anchors = append(anchors, oidfed.TrustAnchorConfig{EntityID: entityID})
+	}
+	constraints := make([]oidfed.MetadataConstraint, 0, len(cfg.MetadataConstraints))
+	for _, c := range cfg.MetadataConstraints {
+		constraints = append(constraints, oidfed.MetadataConstraint{
+			EntityType: c.EntityType,
+			Claim:      c.Claim,
+			Required:   c.Required,
+			OneOf:      c.OneOf,
+			Includes:   c.Includes,
+			URISchemes: c.URISchemes,
+		})
+	}
+	return oidfed.NewOIDFedRegistry(oidfed.Config{
+		TrustAnchors:       anchors,
+		RequiredTrustMarks: cfg.RequiredTrustMarks,
+		EntityTypes:        cfg.EntityTypes,
+		Description:        "OpenID Federation",
+		CacheTTL:           cfg.CacheTTL,
+		NegativeCacheTTL:   cfg.NegativeCacheTTL,
+
+		MetadataConstraints: constraints,
+	})
 }
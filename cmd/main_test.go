@@ -261,6 +261,26 @@ func TestNoServerMode(t *testing.T) {
 	t.Logf("No-server mode output: %s", strings.TrimSpace(outputStr))
 }
 
+// TestMockMode tests that --mock runs the bundled mock pipeline without a pipeline file
+func TestMockMode(t *testing.T) {
+	requireIntegrationBinary(t)
+
+	cmd := exec.Command("./gt-test", "--mock", "--no-server")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "Mock pipeline should execute successfully")
+
+	outputStr := string(output)
+	assert.Contains(t, outputStr, "Running in mock mode", "Should warn about mock mode")
+	assert.Contains(t, outputStr, "Loaded mock TSL", "Should load the bundled TSL")
+	assert.Contains(t, outputStr, "Pipeline execution completed successfully", "Should complete successfully")
+
+	// A pipeline file cannot be combined with --mock
+	cmd = exec.Command("./gt-test", "--mock", "pipeline.yaml")
+	output, err = cmd.CombinedOutput()
+	require.Error(t, err)
+	assert.Contains(t, string(output), "--mock cannot be combined with a pipeline YAML file")
+}
+
 // TestNoServerModeWithLogging tests --no-server with different log levels
 func TestNoServerModeWithLogging(t *testing.T) {
 	requireIntegrationBinary(t)
@@ -301,6 +321,41 @@ func TestNoServerModeInvalidPipeline(t *testing.T) {
 	t.Logf("Error output: %s", strings.TrimSpace(outputStr))
 }
 
+// TestNoServerModeDryRunPublish tests that --no-server exits with status 2
+// when a dry-run publish finds changes
+func TestNoServerModeDryRunPublish(t *testing.T) {
+	requireIntegrationBinary(t)
+
+	schemeDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(schemeDir, "providers"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(schemeDir, "scheme.yaml"), []byte(`operatorNames:
+  - language: en
+    value: "Test Operator"
+type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric"
+`), 0644))
+
+	outputDir := t.TempDir()
+	tempPipeline := createTempPipeline(t, `
+- generate:
+    - "`+schemeDir+`"
+- publish:
+    - "`+outputDir+`"
+    - "dry-run"
+`)
+	defer os.Remove(tempPipeline)
+
+	cmd := exec.Command("./gt-test", "--no-server", tempPipeline)
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr, "Should exit non-zero when the output would change")
+	assert.Equal(t, 2, exitErr.ExitCode())
+	assert.Contains(t, string(output), "Published output would change")
+
+	entries, err := os.ReadDir(outputDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "A dry run should not write files")
+}
+
 // TestBasicPipelineExecution tests running a basic pipeline without API calls
 // This test uses a simple pipeline that doesn't start a listener
 func TestBasicPipelineExecution(t *testing.T) {
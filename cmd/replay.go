@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/api"
+	"github.com/SUNET/go-trust/pkg/audit"
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// maxReplayRecordSize bounds the size of a line of a replayed file.
+const maxReplayRecordSize = 4 << 20
+
+// Decisions of replayed records.
+const (
+	replayAllow = "allow"
+	replayDeny  = "deny"
+	replayError = "error" // No decision could be made
+)
+
+// replayUsage prints the usage of the replay command to stderr.
+func replayUsage() {
+	prog := os.Args[0]
+	fmt.Fprintf(os.Stderr, "\nUsage: %s replay [options] [file]...\n", prog)
+	fmt.Fprintln(os.Stderr, "Replays recorded trust decisions against a PDP and reports those that differ; exits 0 if all match and 1 if not.")
+	fmt.Fprintln(os.Stderr, "Files hold one JSON record per line: ECS audit events with the request (logging.audit.include_requests),")
+	fmt.Fprintln(os.Stderr, "{\"request\": ..., \"decision\": ...} records or bare AuthZEN requests. Without files, or with -, stdin is read.")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintf(os.Stderr, "  --url          Base URL of the PDP (default: %s)\n", defaultHealthcheckURL)
+	fmt.Fprintln(os.Stderr, "  --pipeline     Decide in-process against the trust data of this pipeline file or directory instead (repeatable)")
+	fmt.Fprintln(os.Stderr, "  --config       Configuration file whose tenants and overrides apply to in-process decisions")
+	fmt.Fprintln(os.Stderr, "  --set          Set a pipeline variable of --pipeline, name=value (repeatable)")
+	fmt.Fprintln(os.Stderr, "  --speed        Replay speed relative to the recorded timing, 2 for twice as fast; 0 replays as fast as possible (default: 0)")
+	fmt.Fprintln(os.Stderr, "  --reasons      Also report decisions with a different reason code")
+	fmt.Fprintln(os.Stderr, "  --timeout      Timeout of each request to the PDP (default: 10s)")
+	fmt.Fprintln(os.Stderr, "")
+}
+
+// replayRecord is a recorded decision to replay.
+type replayRecord struct {
+	line      int             // Line of the record in its file
+	time      time.Time       // When the decision was made, if recorded
+	requestID string          // Request ID of the decision, if recorded
+	request   json.RawMessage // AuthZEN evaluation request
+	decision  string          // replayAllow, replayDeny, replayError, or "" if not recorded
+	reason    string          // Reason code of the decision, if recorded
+}
+
+// replayDecider makes the decision on a replayed AuthZEN request, returning
+// the HTTP response of the PDP.
+type replayDecider func(req *http.Request) (*http.Response, error)
+
+// replayStats counts the outcomes of a replay.
+type replayStats struct {
+	replayed, matched, mismatched, failed, unrecorded, skipped int
+}
+
+// runReplay implements "gt replay": it replays the decisions recorded in the
+// files of args against a PDP, or against a pipeline evaluated in-process,
+// reports to w each decision that differs from the recorded one, followed by
+// a summary, and returns the process exit code: 0 if every decision matched,
+// 1 if some differ or could not be replayed, and 2 on invalid arguments or
+// unreadable files. It validates an upgrade or a configuration change
+// against real traffic before it is rolled out.
+func runReplay(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.Usage = replayUsage
+	baseURL := fs.String("url", defaultHealthcheckURL, "Base URL of the PDP")
+	var pipelines, sets varFlags
+	fs.Var(&pipelines, "pipeline", "Decide in-process against the trust data of this pipeline (repeatable)")
+	configFile := fs.String("config", "", "Configuration file whose tenants and overrides apply to in-process decisions")
+	fs.Var(&sets, "set", "Set a pipeline variable, name=value (repeatable)")
+	speed := fs.Float64("speed", 0, "Replay speed relative to the recorded timing; 0 replays as fast as possible")
+	reasons := fs.Bool("reasons", false, "Also report decisions with a different reason code")
+	timeout := fs.Duration("timeout", 10*time.Second, "Timeout of each request to the PDP")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *speed < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --speed cannot be negative")
+		return 2
+	}
+
+	var decide replayDecider
+	endpoint := strings.TrimSuffix(*baseURL, "/") + "/evaluation"
+	if len(pipelines) > 0 {
+		handler, err := newReplayEngine(pipelines, *configFile, sets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		endpoint = "http://replay/evaluation"
+		decide = func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec.Result(), nil
+		}
+	} else {
+		client := &http.Client{Timeout: *timeout}
+		decide = client.Do
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var stats replayStats
+	var first time.Time
+	start := time.Now()
+	for _, file := range files {
+		err := readReplayFile(file, &stats, func(rec *replayRecord) {
+			// Keep the recorded pace, scaled by speed
+			if *speed > 0 && !rec.time.IsZero() {
+				if first.IsZero() {
+					first = rec.time
+				}
+				offset := time.Duration(float64(rec.time.Sub(first)) / *speed)
+				if wait := time.Until(start.Add(offset)); wait > 0 {
+					time.Sleep(wait)
+				}
+			}
+			replayOne(w, decide, endpoint, file, rec, *reasons, &stats)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	fmt.Fprintf(w, "replayed %d decisions: %d matched, %d mismatched, %d failed, %d not recorded; %d records skipped\n",
+		stats.replayed, stats.matched, stats.mismatched, stats.failed, stats.unrecorded, stats.skipped)
+	if stats.mismatched > 0 || stats.failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readReplayFile calls replay with each record of the file at path, or of
+// stdin if path is "-", counting lines that are not decisions to replay in
+// stats.
+func readReplayFile(path string, stats *replayStats, replay func(rec *replayRecord)) error {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxReplayRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		rec, err := parseReplayRecord(scanner.Bytes())
+		if err != nil || rec == nil {
+			stats.skipped++
+			continue
+		}
+		rec.line = line
+		replay(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// parseReplayRecord parses a line of a replayed file: an ECS audit event of a
+// decision recorded with its request, a {"request": ..., "decision": ...}
+// record, whose decision is true, false, "allow", "deny" or "error", or a
+// bare AuthZEN request, whose decision is not recorded. It returns nil for
+// other audit events and decisions recorded without their request.
+func parseReplayRecord(line []byte) (*replayRecord, error) {
+	var doc struct {
+		// ECS audit events
+		Timestamp time.Time `json:"@timestamp"`
+		Event     struct {
+			Action  string `json:"action"`
+			Outcome string `json:"outcome"`
+			Reason  string `json:"reason"`
+		} `json:"event"`
+		HTTP struct {
+			Request struct {
+				ID string `json:"id"`
+			} `json:"request"`
+		} `json:"http"`
+		GoTrust *struct {
+			Decision string          `json:"decision"`
+			Request  json.RawMessage `json:"request"`
+		} `json:"go_trust"`
+
+		// Recorded requests
+		Time      time.Time       `json:"time"`
+		RequestID string          `json:"request_id"`
+		Request   json.RawMessage `json:"request"`
+		Decision  json.RawMessage `json:"decision"`
+		Reason    string          `json:"reason"`
+
+		// Bare AuthZEN requests
+		Subject  json.RawMessage `json:"subject"`
+		Resource json.RawMessage `json:"resource"`
+	}
+	if err := json.Unmarshal(line, &doc); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case doc.GoTrust != nil:
+		if (doc.Event.Action != audit.KindDecision && doc.Event.Action != audit.KindOverride) || len(doc.GoTrust.Request) == 0 {
+			return nil, nil
+		}
+		rec := &replayRecord{
+			time:      doc.Timestamp,
+			requestID: doc.HTTP.Request.ID,
+			request:   doc.GoTrust.Request,
+			decision:  doc.GoTrust.Decision,
+			reason:    doc.Event.Reason,
+		}
+		if doc.Event.Outcome == audit.OutcomeUnknown {
+			rec.decision = replayError
+		}
+		return rec, nil
+	case len(doc.Request) > 0:
+		rec := &replayRecord{
+			time:      doc.Time,
+			requestID: doc.RequestID,
+			request:   doc.Request,
+			reason:    doc.Reason,
+		}
+		var decision interface{}
+		if len(doc.Decision) > 0 {
+			if err := json.Unmarshal(doc.Decision, &decision); err != nil {
+				return nil, err
+			}
+		}
+		switch d := decision.(type) {
+		case nil:
+		case bool:
+			rec.decision = replayDeny
+			if d {
+				rec.decision = replayAllow
+			}
+		case string:
+			if d != replayAllow && d != replayDeny && d != replayError {
+				return nil, fmt.Errorf("invalid decision %q", d)
+			}
+			rec.decision = d
+		default:
+			return nil, fmt.Errorf("invalid decision %s", doc.Decision)
+		}
+		return rec, nil
+	case len(doc.Subject) > 0 && len(doc.Resource) > 0:
+		return &replayRecord{request: line}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// replayOne replays rec, read from file, with decide and reports to w how the
+// decision compares with the recorded one.
+func replayOne(w io.Writer, decide replayDecider, endpoint, file string, rec *replayRecord, reasons bool, stats *replayStats) {
+	where := fmt.Sprintf("%s:%d", file, rec.line)
+	if rec.requestID != "" {
+		where += " (request " + rec.requestID + ")"
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, endpoint, bytes.NewReader(rec.request))
+	if err != nil {
+		stats.failed++
+		fmt.Fprintf(w, "failed: %s: %v\n", where, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if rec.requestID != "" {
+		req.Header.Set(api.RequestIDHeader, rec.requestID)
+	}
+	resp, err := decide(req)
+	if err != nil {
+		stats.failed++
+		fmt.Fprintf(w, "failed: %s: %v\n", where, err)
+		return
+	}
+	decision, reason := replayedDecision(resp)
+	stats.replayed++
+
+	switch {
+	case rec.decision == "":
+		stats.unrecorded++
+	case decision != rec.decision || (reasons && rec.reason != "" && reason != rec.reason):
+		stats.mismatched++
+		fmt.Fprintf(w, "mismatch: %s: recorded %s, replayed %s\n", where,
+			describeDecision(rec.decision, rec.reason), describeDecision(decision, reason))
+	default:
+		stats.matched++
+	}
+}
+
+// replayedDecision returns the decision and reason code of the PDP response
+// resp, and closes its body. Responses other than 200 OK are decisions that
+// could not be made.
+func replayedDecision(resp *http.Response) (decision, reason string) {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			return replayError, api.ReasonUnavailable
+		}
+		return replayError, api.ReasonError
+	}
+	var body authzen.EvaluationResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxReplayRecordSize)).Decode(&body); err != nil {
+		return replayError, api.ReasonError
+	}
+	if body.Decision {
+		return replayAllow, api.DecisionReasonCode(&body)
+	}
+	return replayDeny, api.DecisionReasonCode(&body)
+}
+
+// describeDecision returns decision with its reason code, if known.
+func describeDecision(decision, reason string) string {
+	if reason == "" || reason == api.ReasonNone {
+		return decision
+	}
+	return fmt.Sprintf("%s (%s)", decision, reason)
+}
+
+// newReplayEngine runs the pipeline of the files once and returns the API
+// handler deciding against its trust data, with the tenants and overrides of
+// the configuration file, if any. Tenant pipelines and trust registries of
+// the configuration are not used.
+func newReplayEngine(files []string, configFile string, sets varFlags) (http.Handler, error) {
+	cfg := config.DefaultConfig()
+	if configFile != "" {
+		var err error
+		if cfg, err = config.LoadConfig(configFile); err != nil {
+			return nil, err
+		}
+	}
+	logger := logging.NewLogger(logging.WarnLevel)
+	logger.(logging.OutputConfigurable).SetOutput(os.Stderr)
+
+	pl, err := pipeline.NewPipelineFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pipeline: %w", err)
+	}
+	pl = pl.WithLogger(logger)
+	ctx, err := pipelineVars(pl, sets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --set: %w", err)
+	}
+	result, err := pl.Process(ctx)
+	if err != nil && !pipeline.IsPublishDiff(err) {
+		return nil, fmt.Errorf("pipeline execution failed: %w", err)
+	}
+	if result == nil {
+		return nil, errors.New("pipeline execution produced no trust data")
+	}
+
+	serverCtx := api.NewServerContext(logger)
+	serverCtx.InstallContext("", result)
+	serverCtx.DecisionTimeout = cfg.Server.DecisionTimeout
+	if len(cfg.Security.Tenants) > 0 {
+		serverCtx.TenantPolicy = newTenantPolicy(cfg.Security.Tenants)
+	}
+	o := cfg.Security.Overrides
+	if serverCtx.Overrides, err = api.NewOverrides(o.Deny, o.Allow, o.File); err != nil {
+		return nil, fmt.Errorf("failed to load decision overrides: %w", err)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(api.RequestIDMiddleware())
+	api.RegisterAPIRoutes(r, serverCtx)
+	return r, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/api"
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplayPDP returns a PDP trusting the subjects "trusted", denying others
+// as unknown and failing on the subject "broken".
+func newReplayPDP(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req authzen.EvaluationRequest
+		if r.URL.Path != "/evaluation" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp := authzen.EvaluationResponse{Decision: req.Subject.ID == "trusted"}
+		switch req.Subject.ID {
+		case "trusted":
+		case "broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		default:
+			resp.Context = &authzen.EvaluationResponseContext{Reason: map[string]interface{}{"error": "unknown authority"}}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeReplayFile(t *testing.T, lines ...string) string {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	return path
+}
+
+func TestRunReplay(t *testing.T) {
+	server := newReplayPDP(t)
+	path := writeReplayFile(t,
+		// ECS audit events
+		`{"@timestamp":"2026-10-16T09:00:00Z","event":{"action":"trust-decision","outcome":"success","reason":"none"},"http":{"request":{"id":"r1"}},"go_trust":{"decision":"allow","request":{"subject":{"type":"key","id":"trusted"},"resource":{"type":"x5c","id":"trusted"}}}}`,
+		`{"@timestamp":"2026-10-16T09:00:01Z","event":{"action":"trust-decision","outcome":"failure","reason":"unknown_authority"},"http":{"request":{"id":"r2"}},"go_trust":{"decision":"deny","request":{"subject":{"type":"key","id":"other"},"resource":{"type":"x5c","id":"other"}}}}`,
+		`{"@timestamp":"2026-10-16T09:00:02Z","event":{"action":"admin","outcome":"success"},"go_trust":{}}`,
+		`{"@timestamp":"2026-10-16T09:00:03Z","event":{"action":"trust-decision","outcome":"success"},"go_trust":{"decision":"allow"}}`,
+		"",
+		// Recorded requests and a bare request
+		`{"request_id":"r3","decision":true,"request":{"subject":{"type":"key","id":"trusted"},"resource":{"type":"x5c","id":"trusted"}}}`,
+		`{"subject":{"type":"key","id":"other"},"resource":{"type":"x5c","id":"other"}}`,
+		"not json")
+
+	var buf bytes.Buffer
+	assert.Equal(t, 0, runReplay([]string{"--url", server.URL, path}, &buf))
+	assert.Equal(t, "replayed 4 decisions: 3 matched, 0 mismatched, 0 failed, 1 not recorded; 3 records skipped\n", buf.String())
+}
+
+func TestRunReplay_Mismatches(t *testing.T) {
+	server := newReplayPDP(t)
+	path := writeReplayFile(t,
+		`{"request_id":"r1","decision":"deny","reason":"revoked","request":{"subject":{"type":"key","id":"trusted"},"resource":{"type":"x5c","id":"trusted"}}}`,
+		`{"request_id":"r2","decision":"deny","reason":"revoked","request":{"subject":{"type":"key","id":"other"},"resource":{"type":"x5c","id":"other"}}}`,
+		`{"request_id":"r3","decision":"allow","request":{"subject":{"type":"key","id":"broken"},"resource":{"type":"x5c","id":"broken"}}}`)
+
+	var buf bytes.Buffer
+	assert.Equal(t, 1, runReplay([]string{"--url", server.URL, path}, &buf))
+	assert.Equal(t, "mismatch: "+path+":1 (request r1): recorded deny (revoked), replayed allow\n"+
+		"mismatch: "+path+":3 (request r3): recorded allow, replayed error (unavailable)\n"+
+		"replayed 3 decisions: 1 matched, 2 mismatched, 0 failed, 0 not recorded; 0 records skipped\n", buf.String())
+
+	buf.Reset()
+	assert.Equal(t, 1, runReplay([]string{"--url", server.URL, "--reasons", path}, &buf))
+	assert.Contains(t, buf.String(), "mismatch: "+path+":2 (request r2): recorded deny (revoked), replayed deny (unknown_authority)\n")
+
+	addr := server.URL
+	server.Close()
+	buf.Reset()
+	assert.Equal(t, 1, runReplay([]string{"--url", addr, "--timeout", "1s", path}, &buf))
+	assert.Contains(t, buf.String(), "0 matched, 0 mismatched, 3 failed")
+}
+
+func TestRunReplay_Speed(t *testing.T) {
+	server := newReplayPDP(t)
+	path := writeReplayFile(t,
+		`{"time":"2026-10-16T09:00:00Z","decision":true,"request":{"subject":{"type":"key","id":"trusted"},"resource":{"type":"x5c","id":"trusted"}}}`,
+		`{"time":"2026-10-16T09:00:01Z","decision":true,"request":{"subject":{"type":"key","id":"trusted"},"resource":{"type":"x5c","id":"trusted"}}}`)
+
+	var buf bytes.Buffer
+	start := time.Now()
+	assert.Equal(t, 0, runReplay([]string{"--url", server.URL, "--speed", "4", path}, &buf))
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond, "replayed at four times the recorded pace")
+}
+
+func TestRunReplay_Arguments(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Equal(t, 2, runReplay([]string{"--no-such-flag"}, &buf))
+	assert.Equal(t, 2, runReplay([]string{"--speed", "-1"}, &buf))
+	assert.Equal(t, 2, runReplay([]string{filepath.Join(t.TempDir(), "missing.jsonl")}, &buf))
+	assert.Equal(t, 2, runReplay([]string{"--pipeline", filepath.Join(t.TempDir(), "missing.yaml")}, &buf))
+}
+
+func TestParseReplayRecord(t *testing.T) {
+	rec, err := parseReplayRecord([]byte(`{"@timestamp":"2026-10-16T09:00:00Z","event":{"action":"trust-decision","outcome":"unknown","reason":"timeout"},"go_trust":{"request":{"subject":{"id":"a"}}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, replayError, rec.decision)
+	assert.Equal(t, api.ReasonTimeout, rec.reason)
+
+	_, err = parseReplayRecord([]byte(`{"decision":"maybe","request":{}}`))
+	assert.ErrorContains(t, err, "invalid decision")
+}
+
+func TestRunReplay_InProcess(t *testing.T) {
+	dir := t.TempDir()
+	tsl, err := os.ReadFile("../pkg/pipeline/testdata/extensions-tsl.xml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tsl.xml"), tsl, 0600))
+	pipelinePath := filepath.Join(dir, "pipeline.yaml")
+	require.NoError(t, os.WriteFile(pipelinePath, []byte("- load:\n  - "+filepath.Join(dir, "tsl.xml")+"\n"), 0600))
+	path := writeReplayFile(t,
+		`{"decision":"allow","request":{"subject":{"type":"key","id":"trusted"},"resource":{"type":"x5c","id":"trusted","key":["MIIB"]}}}`)
+
+	var buf bytes.Buffer
+	assert.Equal(t, 1, runReplay([]string{"--pipeline", pipelinePath, path}, &buf))
+	assert.Contains(t, buf.String(), "recorded allow, replayed ")
+	assert.Contains(t, buf.String(), "replayed 1 decisions: 0 matched, 1 mismatched")
+}
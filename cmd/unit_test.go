@@ -2,13 +2,18 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/SUNET/go-trust/docs/swagger"
+	"github.com/SUNET/go-trust/pkg/config"
 	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestParseLogLevel tests the parseLogLevel function with various inputs
@@ -139,8 +144,12 @@ func TestUsage(t *testing.T) {
 		"--config",
 		"--host",
 		"--port",
+		"--external-url",
 		"--frequency",
+		"--swagger",
 		"--no-server",
+		"--mock",
+		"--set",
 		"--log-level",
 		"--log-format",
 		"--log-output",
@@ -163,6 +172,29 @@ func TestUsage(t *testing.T) {
 	assert.Contains(t, output, "Show this help message", "Should explain help option")
 }
 
+// TestSubcommands tests that every subcommand is dispatched and documented
+func TestSubcommands(t *testing.T) {
+	oldStderr := os.Stderr
+	r, w, err := os.Pipe()
+	assert.NoError(t, err, "Failed to create pipe")
+	os.Stderr = w
+	usage()
+	w.Close()
+	os.Stderr = oldStderr
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, r)
+	assert.NoError(t, err, "Failed to read from pipe")
+
+	for _, cmd := range subcommands {
+		found := findSubcommand(cmd.name)
+		if assert.NotNil(t, found, "%s should be dispatched", cmd.name) {
+			assert.Equal(t, cmd.name, found.name)
+		}
+		assert.Contains(t, buf.String(), " "+cmd.name+" "+cmd.args+"  "+cmd.about, "Usage should document %s", cmd.name)
+	}
+	assert.Nil(t, findSubcommand("pipeline.yaml"), "Other arguments are not subcommands")
+}
+
 // TestUsageOutputFormat tests that usage output is well-formatted
 func TestUsageOutputFormat(t *testing.T) {
 	// Capture stderr (usage writes to stderr, not stdout)
@@ -209,6 +241,82 @@ func TestUsageOutputFormat(t *testing.T) {
 	}
 }
 
+// TestBaseURL tests that the external URL takes precedence over the listen address
+func TestBaseURL(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.Host = "0.0.0.0"
+	cfg.Server.Port = "8080"
+	assert.Equal(t, "http://0.0.0.0:8080", baseURL(cfg))
+
+	cfg.Server.ExternalURL = "https://pdp.example.com/"
+	assert.Equal(t, "https://pdp.example.com", baseURL(cfg))
+}
+
+// TestPipelineVars tests that repeated --set flags become pipeline variables
+func TestPipelineVars(t *testing.T) {
+	pl := &pipeline.Pipeline{Logger: logging.NewLogger(logging.ErrorLevel)}
+
+	var sets varFlags
+	require.NoError(t, sets.Set("source=-"))
+	require.NoError(t, sets.Set("env=test"))
+	assert.Equal(t, "source=-,env=test", sets.String())
+
+	ctx, err := pipelineVars(pl, sets)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"source": "-", "env": "test"}, ctx.Vars())
+
+	ctx, err = pipelineVars(pl, nil)
+	require.NoError(t, err)
+	assert.Empty(t, ctx.Vars())
+
+	_, err = pipelineVars(pl, varFlags{"no-value"})
+	assert.ErrorContains(t, err, "invalid variable")
+}
+
+// TestNewOIDFedRegistry tests that the configured OpenID Federation registry
+// handles entity requests
+func TestNewOIDFedRegistry(t *testing.T) {
+	reg, err := newOIDFedRegistry(&config.OIDFedConfig{
+		TrustAnchors: []string{"https://ta.example.com"},
+		EntityTypes:  []string{"openid_relying_party"},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, reg.SupportedResourceTypes(), "entity")
+	assert.Equal(t, []string{"https://ta.example.com"}, reg.Info().TrustAnchors)
+
+	_, err = newOIDFedRegistry(&config.OIDFedConfig{})
+	assert.Error(t, err)
+
+	_, err = newOIDFedRegistry(&config.OIDFedConfig{
+		TrustAnchors:        []string{"https://ta.example.com"},
+		MetadataConstraints: []config.MetadataConstraintConfig{{EntityType: "openid_relying_party", Claim: "redirect_uris"}},
+	})
+	assert.Error(t, err, "constraints are passed on to the registry")
+}
+
+// TestOpenAPISpec tests that the generated specification documents the API
+// endpoints; regenerate it with "make swagger" when this fails
+func TestOpenAPISpec(t *testing.T) {
+	var spec struct {
+		Paths map[string]any `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(swagger.SwaggerInfo.ReadDoc()), &spec))
+
+	for _, path := range []string{
+		"/healthz",
+		"/readyz",
+		"/metrics",
+		"/.well-known/authzen-configuration",
+		"/evaluation",
+		"/tsls",
+		"/certificates",
+		"/version",
+		"/openapi.json",
+	} {
+		assert.Contains(t, spec.Paths, path, "OpenAPI specification should document %s", path)
+	}
+}
+
 // TestVersionVariable tests that the Version variable is properly set
 func TestVersionVariable(t *testing.T) {
 	// The Version variable is set at build time with -ldflags
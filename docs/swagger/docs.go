@@ -44,9 +44,279 @@ const docTemplate = `{
                 }
             }
         },
+        "/admin/denials": {
+            "get": {
+                "description": "Returns the most recent negative AuthZEN decisions of this instance, newest first, with\nthe subject, tenant, normalized reason code and reason message of each. Only decisions\nmade since the server started are kept, up to a fixed number. Requests refused with an\nerror response are not decisions and are not listed. Requires the admin bearer token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Recent denied decisions",
+                "responses": {
+                    "200": {
+                        "description": "denials",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.Denial"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/admin/refresh": {
+            "post": {
+                "description": "Asks the background updaters to run their pipelines now instead of at their next\nscheduled time. The runs happen asynchronously: the response is sent as soon as they\nare requested, and their outcome appears in the run history and pool generation.\nA run that is already pending is not requested twice. Requires the admin bearer token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Trigger a pipeline run",
+                "responses": {
+                    "202": {
+                        "description": "triggered: number of pipelines that will run",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "integer"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/admin/signers": {
+            "get": {
+                "description": "Verifies the XML-DSIG signature of every TSL XML file in the publish directory and\nreports the signing certificate of each, and the keys that signed them, for key\nceremonies and signing key rollovers. Copies signed with the next key during a\nrollover are below next/ and marked next. Files that are not signed, or whose\nsignature does not verify, are listed with signed or valid false. Requires the admin\nbearer token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Keys that signed the published TSLs",
+                "responses": {
+                    "200": {
+                        "description": "Signing keys and signatures of the published files",
+                        "schema": {
+                            "$ref": "#/definitions/api.PublishedSignersResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "The publish directory could not be read",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
+        "/certificates": {
+            "get": {
+                "description": "Returns the certificates of the active certificate pool, ordered by subject, each with\nits provenance: the source URL and territory of the TSL it is listed in and the\nprovider, service name, service type and status of the trust service it belongs to.\nA certificate listed under several services has one provenance entry for each.\n\nThe X-Tenant header selects the certificate pool of a tenant with its own pipeline.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "TSLs"
+                ],
+                "summary": "List trusted certificates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tenant whose certificate pool is listed",
+                        "name": "X-Tenant",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of certificates to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of certificates to return",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.CertificatesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid offset or limit",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/certificates/{sha256}": {
+            "get": {
+                "description": "Returns a certificate of the active certificate pool, identified by the hex SHA-256\nfingerprint of its DER encoding, with the TSL services it was selected from.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "TSLs"
+                ],
+                "summary": "Get a trusted certificate",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hex SHA-256 fingerprint of the certificate",
+                        "name": "sha256",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tenant whose certificate pool is searched",
+                        "name": "X-Tenant",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.CertSummary"
+                        }
+                    },
+                    "404": {
+                        "description": "Certificate not in the pool",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/debug/verify": {
+            "post": {
+                "description": "Verifies a submitted certificate chain against the loaded trust configuration and\nreturns a detailed, non-authoritative report: the chains built against the active\ncertificate pool, with the TSL source, territory, provider and service each pool\ncertificate was selected from, and, for every TSL service certificate that could anchor the chain,\nthe matched trust service, its service information extensions and the verification\nresult for that path.\n\nThe report helps integrators debug onboarding issues. It is not an AuthZEN decision\nand is not counted in decision metrics. Requires the admin bearer token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Diagnose certificate chain verification (admin)",
+                "parameters": [
+                    {
+                        "description": "Certificate chain as PEM or x5c",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.VerifyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Verification report",
+                        "schema": {
+                            "$ref": "#/definitions/api.VerifyReport"
+                        }
+                    },
+                    "400": {
+                        "description": "No or unparseable certificates",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid admin token",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ]
+            }
+        },
         "/evaluation": {
             "post": {
-                "description": "Evaluates whether a name-to-key binding is trusted according to loaded TSLs\n\nThis endpoint implements the AuthZEN Trust Registry Profile as specified in\ndraft-johansson-authzen-trust. It validates that a public key (in resource.key)\nis correctly bound to a name (in subject.id) according to ETSI TS 119612 Trust Status Lists.\n\nThe request MUST have:\n- subject.type = \"key\" and subject.id = the name to validate\n- resource.type = \"jwk\" or \"x5c\" with resource.key containing the public key/certificates\n- resource.id MUST equal subject.id\n- action (optional) with name = the role being validated",
+                "description": "Evaluates whether a name-to-key binding is trusted according to loaded trust registries\n\nThis endpoint implements the AuthZEN Trust Registry Profile as specified in\ndraft-johansson-authzen-trust. It validates that a public key (in resource.key)\nis correctly bound to a name (in subject.id) using configured trust registries\n(ETSI TS 119612 TSLs, OpenID Federation, DID methods, etc.).\n\nThe request MUST have:\n- subject.type = \"key\" and subject.id = the name to validate\n- resource.type = \"jwk\" or \"x5c\" with resource.key containing the public key/certificates\n- resource.id MUST equal subject.id\n- action (optional) with name = the role being validated\n\nAn OpenID Federation entity is evaluated with resource.type = \"entity\", subject.type =\n\"entity\" (or \"key\") and its entity identifier URL as subject.id and resource.id; no\nresource.key is needed. Such requests are routed to the OpenID Federation registry,\nwhich returns the resolved trust chain (trust_chain, trust_anchor) and the entity's\nmetadata in context.reason.\n\nThe request context may carry \"tenant\" and \"purpose\" identifiers. When a tenant\nallow-list is configured, requests whose tenant, purpose or action is not allowed\nare denied without evaluation. Tenant and purpose are recorded in the decision log.\nThe tenant may also be selected with the X-Tenant header. Tenants configured with\ntheir own pipeline are evaluated against that pipeline's certificate pool.\n\nA positive decision for an ETSI TSL chain returns context.reason.trust_service: the\nprovider, service, service type and status URIs, territory and source of the TSL\nlisting the trust anchor, and the SHA-256 fingerprint of the trust anchor.\n\nWith \"qualification\": true in the request context, a positive decision for an ETSI\nTSL chain also returns context.reason.qualification, classifying the trust service the\nchain is anchored in as \"qualified\" or \"non-qualified\" from its service type and status.\n\nWith \"territories\": [\"SE\"] in the request context, or territories configured for the\ntenant, a chain is only trusted if its trust anchor is listed in a TSL of one of those\nscheme territories; otherwise the decision is false with a \"territory mismatch\" reason.\n\nDecisions made against a pipeline's certificate pool report the generation of that\npool in context.pool_generation, which increases with every successful pipeline run.\n\nCertificates on the configured override deny-list are distrusted, also as trust anchors\nof a verified chain, and leaf certificates on the allow-list are trusted regardless of\nthe registries. Such decisions report \"override\" and \"fingerprint\" in context.reason.\n\nEach decision has a deadline (server.decision_timeout, 10s by default). A request not\ndecided in time is denied with a \"decision timeout\" reason; the evaluation of a request\nwhose client disconnects is abandoned.\n\nWith server.server_timing enabled, responses carry a Server-Timing header with the time\nspent in each phase of the decision (bind, parse, lookup, policy, verify), in milliseconds.\n\nWith server.max_concurrent_evaluations set, requests beyond that many evaluations in\nprogress are answered at once with a 503 problem and a Retry-After header.\n\nWith security.client_binding, requests of the listed actions whose resource.key leaf is not the\nTLS client certificate of the caller (leaf), or not issued by its CA (chain), are denied.\n\nWith security.name_matching, requests whose subject.id matches no subject alternative name\n(DNS, URI or email) of the resource.key leaf are denied with the \"name_mismatch\" reason code.\n\nRequests that cannot be evaluated are answered with an RFC 9457 problem\n(application/problem+json) instead of a decision: 400 for malformed JSON, requests\nfailing Trust Registry Profile validation and unparseable or empty resource.key,\n503 when no trust data is loaded yet, and 500 when evaluation fails. Requests that\nare understood but not trusted are answered with 200 and decision false.",
                 "consumes": [
                     "application/json"
                 ],
@@ -66,6 +336,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/authzen.EvaluationRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tenant selecting the trust configuration",
+                        "name": "X-Tenant",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -76,12 +352,21 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request format or validation error",
+                        "description": "Malformed request, validation error or unparseable resource.key",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Evaluation failed",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "No trust data loaded, or too many concurrent evaluations",
+                        "schema": {
+                            "$ref": "#/definitions/api.Problem"
                         }
                     }
                 }
@@ -109,7 +394,7 @@ const docTemplate = `{
         },
         "/info": {
             "get": {
-                "description": "Returns detailed summaries of all loaded Trust Status Lists\n\nDEPRECATED: This endpoint is deprecated. Use GET /tsls instead.\n\nThis endpoint provides comprehensive information about each TSL including:\n- Territory code\n- Sequence number\n- Issue date\n- Next update date\n- Number of services",
+                "description": "Returns detailed summaries of all loaded Trust Status Lists\n\nResponses carry ETag and Last-Modified headers; conditional requests using\nIf-None-Match or If-Modified-Since receive 304 Not Modified when nothing changed.\n\nDEPRECATED: This endpoint is deprecated. Use GET /tsls instead.\n\nLarge result sets can be paged with offset/limit and trimmed with fields=.\nResponses are gzip-compressed when the client sends Accept-Encoding: gzip.\n\nThis endpoint provides comprehensive information about each TSL including:\n- Territory code\n- Sequence number\n- Issue date\n- Next update date\n- Number of services\n- Signature verification status, if the load step verified signatures\n- Source URL, distribution points and whether the TSL was loaded from one of them\n- Scheme operator electronic addresses and pointers to other TSLs",
                 "produces": [
                     "application/json"
                 ],
@@ -118,9 +403,100 @@ const docTemplate = `{
                 ],
                 "summary": "Get TSL information (DEPRECATED - use GET /tsls)",
                 "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified from a previous response",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of TSL summaries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of TSL summaries to return (0 = all)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated summary keys to include (e.g. scheme_operator_name,num_trust_service_providers)",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated scheme territories to include (e.g. SE,EL); GR and GB select EL and UK",
+                        "name": "territory",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "tsl_summaries, total",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the cached copy"
+                    },
+                    "400": {
+                        "description": "Invalid pagination or territory parameters",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Exposes Prometheus metrics for monitoring and alerting\n\nMetrics include:\n- Pipeline execution duration and counts\n- TSL processing metrics\n- Signature verification status of the fetched TSLs\n- API request rates and latency\n- Certificate validation metrics\n- Trust decisions by action, resource type, tenant, purpose, decision and reason\n- Trust decision latency by phase (bind, parse, lookup, policy, verify, respond)\n- Error counts by type\n- Evaluations, decisions, latency, errors and health per trust registry",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Metrics"
+                ],
+                "summary": "Prometheus metrics",
+                "responses": {
+                    "200": {
+                        "description": "Prometheus metrics in text format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/openapi.json": {
+            "get": {
+                "description": "Returns the OpenAPI (Swagger 2.0) specification of this API, generated from the handler\nannotations, with the host and scheme under which this instance is reached. Clients can\nbe generated from it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Docs"
+                ],
+                "summary": "OpenAPI specification",
                 "responses": {
                     "200": {
-                        "description": "tsl_summaries",
+                        "description": "OpenAPI specification",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -129,124 +505,1009 @@ const docTemplate = `{
                 }
             }
         },
-        "/readyz": {
-            "get": {
-                "description": "Returns ready status if pipeline has been processed and TSLs are loaded\n\nQuery Parameters:\n- verbose=true: Include detailed TSL information in the response",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Health"
-                ],
-                "summary": "Readiness check",
-                "parameters": [
-                    {
-                        "type": "boolean",
-                        "description": "Include detailed TSL information",
-                        "name": "verbose",
-                        "in": "query"
+        "/proxy": {
+            "get": {
+                "description": "Lists the TSLs of the default pipeline that the TSL proxy re-serves, with the\nlocal URL of each, the upstream URL it was fetched from, its digest and its\nsignature verification status. Only TSLs fetched over HTTP(S) and, unless\nserver.proxy.allow_unverified is set, whose signature the load step verified\nare served.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Proxy"
+                ],
+                "summary": "List the TSLs served by the TSL proxy",
+                "responses": {
+                    "200": {
+                        "description": "TSLs served",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProxyIndexResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/proxy/{path}": {
+            "get": {
+                "description": "Serves a TSL fetched by the default pipeline byte for byte, with its original\nsignature, under the host and path of its upstream URL: the TSL fetched from\nhttps://example.org/tsl/se.xml is served as /proxy/example.org/tsl/se.xml.\n\nResponses carry an ETag (the SHA-256 of the document), Last-Modified (as sent\nupstream, or the fetch time), and Cache-Control allowing clients to cache the TSL\nuntil its NextUpdate, at most server.proxy.max_age. If-None-Match,\nIf-Modified-Since, Range and HEAD are supported. X-TSL-Source-URL names the\nupstream URL, and X-TSL-Signature-Status the signature verification status.",
+                "produces": [
+                    "application/xml"
+                ],
+                "tags": [
+                    "Proxy"
+                ],
+                "summary": "Download a TSL through the TSL proxy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host and path of the upstream URL",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified from a previous response",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "TSL document",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the cached copy"
+                    },
+                    "404": {
+                        "description": "TSL not served",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Serves a TSL fetched by the default pipeline byte for byte, with its original\nsignature, under the host and path of its upstream URL: the TSL fetched from\nhttps://example.org/tsl/se.xml is served as /proxy/example.org/tsl/se.xml.\n\nResponses carry an ETag (the SHA-256 of the document), Last-Modified (as sent\nupstream, or the fetch time), and Cache-Control allowing clients to cache the TSL\nuntil its NextUpdate, at most server.proxy.max_age. If-None-Match,\nIf-Modified-Since, Range and HEAD are supported. X-TSL-Source-URL names the\nupstream URL, and X-TSL-Signature-Status the signature verification status.",
+                "produces": [
+                    "application/xml"
+                ],
+                "tags": [
+                    "Proxy"
+                ],
+                "summary": "Download a TSL through the TSL proxy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Host and path of the upstream URL",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified from a previous response",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "TSL document",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the cached copy"
+                    },
+                    "404": {
+                        "description": "TSL not served",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/published/{filepath}": {
+            "get": {
+                "description": "Serves files written by the publish step (TSL XML, .sha256 and .meta sidecars,\nHTML) from the configured publish directory.\n\nResponses carry an ETag (the SHA-256 of the file, taken from the .sha256 sidecar\nwhen present) and Last-Modified, and honour If-None-Match, If-Modified-Since and\nRange. HEAD is supported so that mirrors can check for changes without downloading.\nFor TSL XML files with a .meta sidecar, X-TSL-Sequence-Number and X-TSL-Issue-Date\nheaders are included.",
+                "produces": [
+                    "application/xml"
+                ],
+                "tags": [
+                    "Published"
+                ],
+                "summary": "Download a published file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file relative to the publish directory",
+                        "name": "filepath",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified from a previous response",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the cached copy"
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Serves files written by the publish step (TSL XML, .sha256 and .meta sidecars,\nHTML) from the configured publish directory.\n\nResponses carry an ETag (the SHA-256 of the file, taken from the .sha256 sidecar\nwhen present) and Last-Modified, and honour If-None-Match, If-Modified-Since and\nRange. HEAD is supported so that mirrors can check for changes without downloading.\nFor TSL XML files with a .meta sidecar, X-TSL-Sequence-Number and X-TSL-Issue-Date\nheaders are included.",
+                "produces": [
+                    "application/xml"
+                ],
+                "tags": [
+                    "Published"
+                ],
+                "summary": "Download a published file",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Path of the file relative to the publish directory",
+                        "name": "filepath",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified from a previous response",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the cached copy"
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Returns ready status if pipeline has been processed and TSLs are loaded\nThe response lists the upstream TSL sources with the outcome of their last fetch\n\nQuery Parameters:\n- verbose=true: Include detailed TSL information in the response",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness check",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include detailed TSL information",
+                        "name": "verbose",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Service is ready",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service is not ready",
+                        "schema": {
+                            "$ref": "#/definitions/api.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/schemas": {
+            "get": {
+                "description": "Lists the JSON Schemas (draft 2020-12) of the AuthZEN Trust Registry Profile evaluation\nrequest and response implemented by this server. They are generated from the Go types\nof the messages, and can be used to validate clients or generate models in other\nlanguages.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "AuthZEN"
+                ],
+                "summary": "List JSON Schemas",
+                "responses": {
+                    "200": {
+                        "description": "schemas",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/api.SchemaInfo"
+                                }
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/schemas/{name}": {
+            "get": {
+                "description": "Returns a JSON Schema (draft 2020-12) listed by GET /schemas, such as\nevaluation-request.json or evaluation-response.json. The $id of the schema is its\nURL on this instance. The request schema includes the rules of the Trust Registry\nProfile, except that resource.id must equal subject.id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "AuthZEN"
+                ],
+                "summary": "Get a JSON Schema",
+                "parameters": [
+                    {
+                        "enum": [
+                            "evaluation-request.json",
+                            "evaluation-response.json"
+                        ],
+                        "type": "string",
+                        "description": "Schema file name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "JSON Schema",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown schema",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/status": {
+            "get": {
+                "description": "Returns the current server status including TSL count, last processing time, the\ngeneration of the installed certificate pool and reports of the most recent pipeline\nruns (per-step timings, items, warnings, errors)\n\nResponses carry ETag and Last-Modified headers; conditional requests using\nIf-None-Match or If-Modified-Since receive 304 Not Modified when nothing changed.\n\nDEPRECATED: This endpoint is deprecated. Use GET /readyz for health checks.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Status"
+                ],
+                "summary": "Get server status (DEPRECATED - use GET /readyz)",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last-Modified from a previous response",
+                        "name": "If-Modified-Since",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "tsl_count, last_processed, pool_generation, runs",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "304": {
+                        "description": "Not modified since the cached copy"
+                    }
+                }
+            }
+        },
+        "/status/components": {
+            "get": {
+                "description": "Returns the health of each subsystem of the server: the pipeline updater, the signing\npublish steps, the trust registries, caches, the audit and analytics outputs, the\nupstream TSL sources and the deny webhook, as far as they are configured, with the\ntime of their last success and their last error\n\nEach component is ok, unknown (not exercised yet), degraded or down; status is the\nworst of them. Unlike GET /readyz, the response is always 200, for display rather\nthan routing decisions.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Health of the subsystems",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ComponentsResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tsls": {
+            "get": {
+                "description": "Returns comprehensive information about all loaded Trust Status Lists\n\nThis is the primary endpoint for retrieving TSL metadata including:\n- Territory codes\n- Sequence numbers\n- Issue and next update dates\n- Service counts per TSL\n- Source URL, distribution points and whether the TSL was loaded from one of them\n- Scheme operator electronic addresses and pointers to other TSLs\n- Last processing timestamp\n\nThe territory parameter restricts the list to TSLs of the given scheme territories.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "TSLs"
+                ],
+                "summary": "List Trust Status Lists",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated scheme territories to include (e.g. SE,EL); GR and GB select EL and UK",
+                        "name": "territory",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "count, last_updated, tsls",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown territory code",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "description": "Returns the version, git commit, build date and Go version of the running instance,\nthe features its configuration enables and the SHA-256 hash of the active pipeline file",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Build information",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.VersionResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.CandidatePath": {
+            "type": "object",
+            "properties": {
+                "anchor": {
+                    "$ref": "#/definitions/api.CertSummary"
+                },
+                "error": {
+                    "description": "Verification error for this path",
+                    "type": "string"
+                },
+                "service": {
+                    "$ref": "#/definitions/api.ServiceMatch"
+                },
+                "verified": {
+                    "description": "Whether the chain verifies with this certificate as the only root",
+                    "type": "boolean"
+                }
+            }
+        },
+        "api.CertSummary": {
+            "type": "object",
+            "properties": {
+                "is_ca": {
+                    "type": "boolean"
+                },
+                "issuer": {
+                    "type": "string"
+                },
+                "not_after": {
+                    "type": "string"
+                },
+                "not_before": {
+                    "type": "string"
+                },
+                "provenance": {
+                    "description": "TSL services the certificate was selected into the certificate pool from",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pipeline.CertProvenance"
+                    }
+                },
+                "serial": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "description": "Hex SHA-256 fingerprint of the DER encoding",
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.CertificatesResponse": {
+            "type": "object",
+            "properties": {
+                "certificates": {
+                    "description": "Pool certificates with their provenance",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.CertSummary"
+                    }
+                },
+                "count": {
+                    "description": "Number of certificates in the pool",
+                    "type": "integer"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "pool_generation": {
+                    "description": "Generation of the pool (see ServerContext.InstallContext)",
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ComponentStatus": {
+            "type": "object",
+            "properties": {
+                "component": {
+                    "description": "Kind of subsystem, such as ComponentPipeline",
+                    "type": "string"
+                },
+                "last_error": {
+                    "description": "Most recent error",
+                    "type": "string"
+                },
+                "last_error_at": {
+                    "description": "When the most recent error occurred",
+                    "type": "string"
+                },
+                "last_success": {
+                    "description": "When the subsystem last worked",
+                    "type": "string"
+                },
+                "message": {
+                    "description": "Summary of the state",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name of the subsystem within its kind",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "HealthOK, HealthUnknown, HealthDegraded or HealthDown",
+                    "type": "string"
+                },
+                "target": {
+                    "description": "What the subsystem connects or writes to",
+                    "type": "string"
+                }
+            }
+        },
+        "api.ComponentsResponse": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ComponentStatus"
+                    }
+                },
+                "status": {
+                    "description": "Worst health of the components",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.Denial": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "Requested action, if any",
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "description": "Time taken to decide",
+                    "type": "integer"
+                },
+                "message": {
+                    "description": "Reason given in the decision",
+                    "type": "string"
+                },
+                "pool_generation": {
+                    "description": "Pool generation the request was decided against, 0 if none",
+                    "type": "integer"
+                },
+                "reason": {
+                    "description": "Normalized reason code (see DecisionReasonCode)",
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "X-Request-ID of the request",
+                    "type": "string"
+                },
+                "resource_type": {
+                    "description": "Resource type label (x5c, jwk, entity or other)",
+                    "type": "string"
+                },
+                "subject_id": {
+                    "description": "Name whose key binding was denied",
+                    "type": "string"
+                },
+                "tenant": {
+                    "description": "Tenant label of the request",
+                    "type": "string"
+                },
+                "time": {
+                    "description": "When the decision was made",
+                    "type": "string"
+                }
+            }
+        },
+        "api.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "description": "What was wrong",
+                    "type": "string",
+                    "example": "subject.id must be present"
+                },
+                "request_id": {
+                    "description": "X-Request-ID of the request",
+                    "type": "string",
+                    "example": "3f2a..."
+                },
+                "status": {
+                    "description": "HTTP status code",
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "description": "HTTP status text",
+                    "type": "string",
+                    "example": "Bad Request"
+                },
+                "type": {
+                    "description": "Always \"about:blank\": the status code says it all",
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
+        "api.ProxiedTSL": {
+            "type": "object",
+            "properties": {
+                "fetched_at": {
+                    "description": "When the document was fetched",
+                    "type": "string"
+                },
+                "next_update": {
+                    "description": "NextUpdate of the TSL",
+                    "type": "string"
+                },
+                "sequence_number": {
+                    "description": "TSL sequence number",
+                    "type": "integer"
+                },
+                "sha256": {
+                    "description": "Hex SHA-256 of the document",
+                    "type": "string"
+                },
+                "signature_status": {
+                    "description": "Signature verification status, if verified by the load step",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "Upstream URL the TSL was fetched from",
+                    "type": "string"
+                },
+                "territory": {
+                    "description": "Scheme territory",
+                    "type": "string"
+                },
+                "url": {
+                    "description": "Local URL of the TSL, relative to the server",
+                    "type": "string"
+                }
+            }
+        },
+        "api.ProxyIndexResponse": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Number of TSLs served",
+                    "type": "integer"
+                },
+                "tsls": {
+                    "description": "TSLs served, by local URL",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ProxiedTSL"
+                    }
+                }
+            }
+        },
+        "api.PublishedSignersResponse": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "description": "Signature of every published TSL, by path",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pipeline.PublishedSignature"
+                    }
+                },
+                "keys": {
+                    "description": "Keys with valid signatures, by number of files signed",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SigningKeySummary"
+                    }
+                }
+            }
+        },
+        "api.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "last_processed": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "pool_generation": {
+                    "description": "Generation of the installed certificate pool",
+                    "type": "integer"
+                },
+                "ready": {
+                    "type": "boolean"
+                },
+                "sources": {
+                    "description": "Reachability of upstream TSL sources",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.SourceStatus"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "tsl_count": {
+                    "type": "integer"
+                },
+                "tsls": {
+                    "description": "Only included with ?verbose=true",
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": true
+                    }
+                }
+            }
+        },
+        "api.SchemaInfo": {
+            "description": "JSON Schema served by this instance",
+            "type": "object",
+            "properties": {
+                "description": {
+                    "description": "What the schema describes",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "File name under /schemas/",
+                    "type": "string",
+                    "example": "evaluation-request.json"
+                },
+                "title": {
+                    "description": "Go type the schema is generated from",
+                    "type": "string",
+                    "example": "EvaluationRequest"
+                },
+                "url": {
+                    "description": "Absolute URL and $id of the schema",
+                    "type": "string",
+                    "example": "https://pdp.example.com/schemas/evaluation-request.json"
+                }
+            }
+        },
+        "api.ServiceMatch": {
+            "type": "object",
+            "properties": {
+                "extensions": {
+                    "description": "Service information extensions",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/pipeline.ServiceExtensions"
+                        }
+                    ]
+                },
+                "provider": {
+                    "type": "string"
+                },
+                "provider_page": {
+                    "description": "Link to the page of the provider, at the anchor certificate, if provider pages are published",
+                    "type": "string"
+                },
+                "service": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "territory": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.SigningKeySummary": {
+            "type": "object",
+            "properties": {
+                "files": {
+                    "description": "Number of published files signed with the key",
+                    "type": "integer"
+                },
+                "not_after": {
+                    "description": "End of the validity of the signing certificate",
+                    "type": "string"
+                },
+                "sha256": {
+                    "description": "Hex SHA-256 fingerprint of the signing certificate",
+                    "type": "string"
+                },
+                "subject": {
+                    "description": "Subject of the signing certificate",
+                    "type": "string"
+                }
+            }
+        },
+        "api.SourceStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error of the last request, if it failed",
+                    "type": "string"
+                },
+                "last_fetch": {
+                    "description": "When the TSL was last requested",
+                    "type": "string"
+                },
+                "last_success": {
+                    "description": "When the TSL was last fetched successfully",
+                    "type": "string"
+                },
+                "stale": {
+                    "description": "True if the last run did not fetch the TSL successfully",
+                    "type": "boolean"
+                },
+                "status_code": {
+                    "description": "HTTP status code of the last request",
+                    "type": "integer"
+                },
+                "url": {
+                    "description": "URL of the TSL",
+                    "type": "string"
+                }
+            }
+        },
+        "api.VerifyReport": {
+            "type": "object",
+            "properties": {
+                "authoritative": {
+                    "description": "Always false",
+                    "type": "boolean"
+                },
+                "candidates": {
+                    "description": "TSL service certificates that could anchor the chain",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.CandidatePath"
                     }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Service is ready",
-                        "schema": {
-                            "$ref": "#/definitions/api.ReadinessResponse"
-                        }
-                    },
-                    "503": {
-                        "description": "Service is not ready",
-                        "schema": {
-                            "$ref": "#/definitions/api.ReadinessResponse"
-                        }
+                },
+                "certificates": {
+                    "description": "The submitted certificates, in order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.CertSummary"
                     }
-                }
-            }
-        },
-        "/status": {
-            "get": {
-                "description": "Returns the current server status including TSL count and last processing time\n\nDEPRECATED: This endpoint is deprecated. Use GET /readyz for health checks.",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Status"
-                ],
-                "summary": "Get server status (DEPRECATED - use GET /readyz)",
-                "deprecated": true,
-                "responses": {
-                    "200": {
-                        "description": "tsl_count, last_processed",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                },
+                "chains": {
+                    "description": "Chains built against the active pool",
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/api.CertSummary"
                         }
                     }
+                },
+                "error": {
+                    "description": "Verification error against the active pool",
+                    "type": "string"
+                },
+                "tenant": {
+                    "description": "Tenant whose trust configuration was used",
+                    "type": "string"
+                },
+                "trusted": {
+                    "description": "Whether the leaf verifies against the active certificate pool",
+                    "type": "boolean"
                 }
             }
         },
-        "/tsls": {
-            "get": {
-                "description": "Returns comprehensive information about all loaded Trust Status Lists\n\nThis is the primary endpoint for retrieving TSL metadata including:\n- Territory codes\n- Sequence numbers\n- Issue and next update dates\n- Service counts per TSL\n- Last processing timestamp",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "TSLs"
-                ],
-                "summary": "List Trust Status Lists",
-                "responses": {
-                    "200": {
-                        "description": "count, last_updated, tsls",
-                        "schema": {
-                            "type": "object",
-                            "additionalProperties": true
-                        }
+        "api.VerifyRequest": {
+            "type": "object",
+            "properties": {
+                "pem": {
+                    "description": "PEM-encoded certificates, leaf first",
+                    "type": "string"
+                },
+                "tenant": {
+                    "description": "Tenant whose trust configuration is used (optional)",
+                    "type": "string"
+                },
+                "x5c": {
+                    "description": "Base64 DER certificates, leaf first",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.HealthResponse": {
+        },
+        "api.VersionFeatures": {
             "type": "object",
             "properties": {
-                "status": {
-                    "type": "string"
+                "registries": {
+                    "description": "Types of the configured trust registries",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "timestamp": {
+                "signing": {
+                    "description": "Signing backends configured in the pipeline",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "xslt_available": {
+                    "description": "Whether the XSLT engine is on the PATH",
+                    "type": "boolean"
+                },
+                "xslt_engine": {
+                    "description": "Program used by the transform step",
                     "type": "string"
                 }
             }
         },
-        "api.ReadinessResponse": {
+        "api.VersionResponse": {
             "type": "object",
             "properties": {
-                "last_processed": {
+                "build_date": {
                     "type": "string"
                 },
-                "message": {
+                "commit": {
                     "type": "string"
                 },
-                "ready": {
-                    "type": "boolean"
+                "features": {
+                    "$ref": "#/definitions/api.VersionFeatures"
                 },
-                "status": {
+                "go_version": {
                     "type": "string"
                 },
-                "timestamp": {
+                "pipeline_hash": {
                     "type": "string"
                 },
-                "tsl_count": {
-                    "type": "integer"
-                },
-                "tsls": {
-                    "description": "Only included with ?verbose=true",
-                    "type": "array",
-                    "items": {
-                        "type": "object",
-                        "additionalProperties": true
-                    }
+                "version": {
+                    "type": "string"
                 }
             }
         },
@@ -326,9 +1587,22 @@ const docTemplate = `{
                     "type": "string",
                     "example": "decision-123"
                 },
+                "pool_generation": {
+                    "description": "PoolGeneration identifies the certificate pool snapshot the decision was\nmade against, so that it can be tied to a specific pipeline run",
+                    "type": "integer",
+                    "example": 42
+                },
                 "reason": {
                     "description": "Reason information (user or admin)",
                     "type": "object"
+                },
+                "reason_user": {
+                    "description": "ReasonUser explains a denial to the end user, such as a wallet holder,\nin their language, while Reason holds the technical details for\nadministrators",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/authzen.UserReason"
+                        }
+                    ]
                 }
             }
         },
@@ -396,8 +1670,13 @@ const docTemplate = `{
                     }
                 },
                 "type": {
-                    "description": "MUST be \"jwk\" or \"x5c\"",
+                    "description": "MUST be \"jwk\", \"x5c\" or \"entity\"",
                     "type": "string",
+                    "enum": [
+                        "jwk",
+                        "x5c",
+                        "entity"
+                    ],
                     "example": "x5c"
                 }
             }
@@ -412,11 +1691,188 @@ const docTemplate = `{
                     "example": "did:example:123"
                 },
                 "type": {
-                    "description": "MUST be \"key\"",
+                    "description": "MUST be \"key\", or \"entity\" for entity resources",
                     "type": "string",
+                    "enum": [
+                        "key",
+                        "entity"
+                    ],
                     "example": "key"
                 }
             }
+        },
+        "authzen.UserReason": {
+            "description": "End-user explanation of a denied decision",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Stable reason code",
+                    "type": "string",
+                    "example": "expired"
+                },
+                "lang": {
+                    "description": "Language of the message",
+                    "type": "string",
+                    "example": "en"
+                },
+                "message": {
+                    "description": "Human-readable message",
+                    "type": "string",
+                    "example": "The certificate has expired or is not yet valid."
+                }
+            }
+        },
+        "pipeline.CertProvenance": {
+            "type": "object",
+            "properties": {
+                "provider": {
+                    "description": "Name of the trust service provider",
+                    "type": "string"
+                },
+                "provider_page": {
+                    "description": "Link to the page of the provider, if provider pages are published",
+                    "type": "string"
+                },
+                "service": {
+                    "description": "Name of the trust service",
+                    "type": "string"
+                },
+                "service_type": {
+                    "description": "Service type identifier URI",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "URL or file the TSL was loaded from",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Service status URI",
+                    "type": "string"
+                },
+                "territory": {
+                    "description": "Scheme territory of the TSL",
+                    "type": "string"
+                }
+            }
+        },
+        "pipeline.CriteriaList": {
+            "type": "object",
+            "properties": {
+                "assert": {
+                    "description": "AssertAll, AssertAtLeastOne or AssertNone",
+                    "type": "string"
+                },
+                "criteria_lists": {
+                    "description": "Nested criteria, each one criterion",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pipeline.CriteriaList"
+                    }
+                },
+                "description": {
+                    "type": "string"
+                },
+                "key_usage": {
+                    "description": "Key usage bits, by name, each map one criterion",
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "boolean"
+                        }
+                    }
+                },
+                "policy_sets": {
+                    "description": "Policy OIDs, each set one criterion",
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "pipeline.PublishedSignature": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Why the signature does not verify",
+                    "type": "string"
+                },
+                "file": {
+                    "description": "Path relative to the publish directory, with forward slashes",
+                    "type": "string"
+                },
+                "issuer": {
+                    "description": "Issuer of the signing certificate",
+                    "type": "string"
+                },
+                "next": {
+                    "description": "Whether the file is a copy signed with the next key, below NextSignedDir",
+                    "type": "boolean"
+                },
+                "not_after": {
+                    "description": "End of the validity of the signing certificate",
+                    "type": "string"
+                },
+                "not_before": {
+                    "description": "Start of the validity of the signing certificate",
+                    "type": "string"
+                },
+                "serial": {
+                    "description": "Decimal serial number of the signing certificate",
+                    "type": "string"
+                },
+                "sha256": {
+                    "description": "Hex SHA-256 fingerprint of the signing certificate",
+                    "type": "string"
+                },
+                "signed": {
+                    "description": "Whether the file carries an XML-DSIG signature",
+                    "type": "boolean"
+                },
+                "subject": {
+                    "description": "Subject of the signing certificate",
+                    "type": "string"
+                },
+                "valid": {
+                    "description": "Whether the signature verifies against its certificate",
+                    "type": "boolean"
+                }
+            }
+        },
+        "pipeline.QualificationElement": {
+            "type": "object",
+            "properties": {
+                "criteria": {
+                    "$ref": "#/definitions/pipeline.CriteriaList"
+                },
+                "qualifiers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "pipeline.ServiceExtensions": {
+            "type": "object",
+            "properties": {
+                "additional_service_information": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "qualifications": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pipeline.QualificationElement"
+                    }
+                }
+            }
         }
     },
     "tags": [
@@ -431,6 +1887,30 @@ const docTemplate = `{
         {
             "description": "AuthZEN protocol endpoints for trust decision evaluation",
             "name": "AuthZEN"
+        },
+        {
+            "description": "Loaded TSLs and the certificates trusted from them",
+            "name": "TSLs"
+        },
+        {
+            "description": "Published TSL files for mirrors",
+            "name": "Published"
+        },
+        {
+            "description": "Fetched TSLs re-served with their original signatures",
+            "name": "Proxy"
+        },
+        {
+            "description": "Prometheus metrics",
+            "name": "Metrics"
+        },
+        {
+            "description": "Diagnostics protected by the admin token",
+            "name": "Admin"
+        },
+        {
+            "description": "OpenAPI specification of this API",
+            "name": "Docs"
         }
     ]
 }`
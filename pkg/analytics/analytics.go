@@ -0,0 +1,302 @@
+// Package analytics rolls up trust decisions into counts by hour, action,
+// resource type, tenant, territory, decision and reason, and writes them to
+// CSV or Parquet files for offline analysis of trust usage, without shipping
+// every audit event to a data warehouse.
+//
+// A Rollup counts the decisions recorded since it last wrote a file and,
+// once started, writes them at the end of every interval, by default every
+// day at midnight UTC, and when stopped. Each file holds the counts of the
+// decisions recorded since the previous one, so counts of the same hour in
+// several files, as after a restart, add up.
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// File formats of a Rollup.
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// DefaultInterval is how often a Rollup writes a file when created with a
+// non-positive interval.
+const DefaultInterval = 24 * time.Hour
+
+// Decision values of a Row.
+const (
+	DecisionAllow   = "allow"
+	DecisionDeny    = "deny"
+	DecisionUnknown = "unknown" // The decision could not be made, as on an evaluation error
+)
+
+// Columns are the column names of the files, in order.
+var Columns = []string{"hour", "action", "resource_type", "tenant", "territory", "decision", "reason", "count"}
+
+// Decision is a trust decision to count.
+type Decision struct {
+	Time         time.Time
+	Action       string // Action label, see api.DecisionLabels
+	ResourceType string
+	Tenant       string
+	Territory    string // Scheme territory of the TSL the trusted chain is anchored in, if known
+	Decision     string // DecisionAllow, DecisionDeny or DecisionUnknown
+	Reason       string // Normalized reason code
+}
+
+// Row is a line of a rollup file: the number of decisions of an hour with the
+// same values.
+type Row struct {
+	Hour         time.Time // Start of the hour, in UTC
+	Action       string
+	ResourceType string
+	Tenant       string
+	Territory    string
+	Decision     string
+	Reason       string
+	Count        int64
+}
+
+// Options configures a Rollup.
+type Options struct {
+	Dir      string        // Directory the files are written to; created if missing
+	Format   string        // FormatCSV (default) or FormatParquet
+	Interval time.Duration // How often a file is written; 0 uses DefaultInterval
+}
+
+// Rollup counts trust decisions and writes the counts to files. Record is
+// safe for concurrent use.
+type Rollup struct {
+	dir      string
+	format   string
+	interval time.Duration
+	logger   logging.Logger
+
+	mu          sync.Mutex
+	counts      map[Row]int64 // Decisions by row, with Count left 0
+	lastSuccess time.Time     // When Start last wrote its counts
+	lastError   string        // Error of the last failed write of Start
+	lastErrorAt time.Time     // When a write of Start last failed
+
+	done chan struct{} // Closed once Start has written its last file
+}
+
+// New returns a Rollup for opts. Call Start to begin writing files.
+func New(opts Options, logger logging.Logger) (*Rollup, error) {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("analytics directory is required")
+	}
+	format := strings.ToLower(opts.Format)
+	switch format {
+	case "":
+		format = FormatCSV
+	case FormatCSV, FormatParquet:
+	default:
+		return nil, fmt.Errorf("invalid analytics format %q: must be %s or %s", opts.Format, FormatCSV, FormatParquet)
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create analytics directory: %w", err)
+	}
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Rollup{
+		dir:      opts.Dir,
+		format:   format,
+		interval: interval,
+		logger:   logger,
+		counts:   make(map[Row]int64),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Record counts d. Recording on a nil Rollup does nothing.
+func (r *Rollup) Record(d Decision) {
+	if r == nil {
+		return
+	}
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	key := Row{
+		Hour:         d.Time.UTC().Truncate(time.Hour),
+		Action:       d.Action,
+		ResourceType: d.ResourceType,
+		Tenant:       d.Tenant,
+		Territory:    d.Territory,
+		Decision:     d.Decision,
+		Reason:       d.Reason,
+	}
+	r.mu.Lock()
+	r.counts[key]++
+	r.mu.Unlock()
+}
+
+// Flush writes the counts recorded since the last file to a new file in the
+// directory, named after the time of writing, such as
+// decisions-20261016T000000Z.csv, and resets them. It writes nothing if
+// nothing was recorded. On failure the counts are kept for the next attempt.
+//
+// Returns:
+//   - The path of the file written, or "" if nothing was recorded
+func (r *Rollup) Flush(now time.Time) (string, error) {
+	r.mu.Lock()
+	counts := r.counts
+	r.counts = make(map[Row]int64)
+	r.mu.Unlock()
+	if len(counts) == 0 {
+		return "", nil
+	}
+
+	path := filepath.Join(r.dir, fmt.Sprintf("decisions-%s.%s", now.UTC().Format("20060102T150405Z"), r.format))
+	if err := r.write(path, sortedRows(counts)); err != nil {
+		// Keep the counts, adding those recorded meanwhile
+		r.mu.Lock()
+		for row, n := range counts {
+			r.counts[row] += n
+		}
+		r.mu.Unlock()
+		return "", err
+	}
+	return path, nil
+}
+
+// write writes rows to path, through a temporary file so that readers never
+// see a partial file.
+func (r *Rollup) write(path string, rows []Row) error {
+	tmp, err := os.CreateTemp(r.dir, ".decisions-*")
+	if err != nil {
+		return fmt.Errorf("failed to create analytics file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if r.format == FormatParquet {
+		err = WriteParquet(tmp, rows)
+	} else {
+		err = WriteCSV(tmp, rows)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write analytics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write analytics file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Start writes a file at the end of every interval, aligned to midnight UTC,
+// until ctx is cancelled, then writes the counts recorded since the last one.
+// Failures are reported to the logger. Starting a nil Rollup does nothing.
+// Start must be called at most once.
+func (r *Rollup) Start(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	go func() {
+		defer close(r.done)
+		for {
+			now := time.Now()
+			next := now.UTC().Truncate(r.interval).Add(r.interval)
+			timer := time.NewTimer(next.Sub(now))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				r.flush(time.Now())
+				return
+			case <-timer.C:
+				r.flush(next)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the Rollup started with Start has written its last file
+// after its context was cancelled, so that the counts are not lost on
+// shutdown. It must only be called after Start. Waiting on a nil Rollup
+// returns at once.
+func (r *Rollup) Wait() {
+	if r == nil {
+		return
+	}
+	<-r.done
+}
+
+// Health reports the outcome of the most recent writes of a Rollup.
+type Health struct {
+	Dir         string    // Directory the files are written to
+	LastSuccess time.Time // When the counts were last written; zero if never
+	LastError   string    // Error of the last failed write
+	LastErrorAt time.Time // When a write last failed; zero if none did
+}
+
+// Health returns the outcome of the most recent writes of the Rollup
+// started with Start. A nil Rollup returns the zero Health.
+func (r *Rollup) Health() Health {
+	if r == nil {
+		return Health{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return Health{Dir: r.dir, LastSuccess: r.lastSuccess, LastError: r.lastError, LastErrorAt: r.lastErrorAt}
+}
+
+// flush writes a file and logs and records the outcome.
+func (r *Rollup) flush(now time.Time) {
+	path, err := r.Flush(now)
+	r.mu.Lock()
+	if err != nil {
+		r.lastError, r.lastErrorAt = err.Error(), time.Now()
+	} else {
+		r.lastSuccess = time.Now()
+	}
+	r.mu.Unlock()
+	switch {
+	case err != nil:
+		r.logger.Error("Failed to write decision analytics",
+			logging.F("dir", r.dir),
+			logging.F("error", err.Error()))
+	case path != "":
+		r.logger.Info("Decision analytics written",
+			logging.F("file", path))
+	}
+}
+
+// sortedRows returns counts as rows ordered by hour and then by the other
+// columns.
+func sortedRows(counts map[Row]int64) []Row {
+	rows := make([]Row, 0, len(counts))
+	for row, n := range counts {
+		row.Count = n
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		return strings.Join(a.labels(), "\x00") < strings.Join(b.labels(), "\x00")
+	})
+	return rows
+}
+
+// labels returns the string columns of row, in column order.
+func (row *Row) labels() []string {
+	return []string{row.Action, row.ResourceType, row.Tenant, row.Territory, row.Decision, row.Reason}
+}
@@ -0,0 +1,352 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHour = time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+func recordTestDecisions(r *Rollup) {
+	allow := Decision{Time: testHour.Add(5 * time.Minute), Action: "http://ec.europa.eu/NS/wallet-provider", ResourceType: "x5c", Territory: "SE", Decision: DecisionAllow, Reason: "trusted"}
+	r.Record(allow)
+	allow.Time = testHour.Add(55 * time.Minute)
+	r.Record(allow)
+	r.Record(Decision{Time: testHour.Add(70 * time.Minute), ResourceType: "jwk", Tenant: "acme", Decision: DecisionDeny, Reason: "unknown_authority"})
+}
+
+func TestNew(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "analytics")
+
+	r, err := New(Options{Dir: dir}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, r.format)
+	assert.Equal(t, DefaultInterval, r.interval)
+	assert.DirExists(t, dir)
+
+	r, err = New(Options{Dir: dir, Format: "Parquet", Interval: time.Hour}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, FormatParquet, r.format)
+	assert.Equal(t, time.Hour, r.interval)
+
+	_, err = New(Options{Dir: dir, Format: "json"}, nil)
+	assert.ErrorContains(t, err, "invalid analytics format")
+
+	_, err = New(Options{}, nil)
+	assert.Error(t, err)
+}
+
+func TestFlushCSV(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Options{Dir: dir}, nil)
+	require.NoError(t, err)
+
+	path, err := r.Flush(testHour)
+	require.NoError(t, err)
+	assert.Empty(t, path, "nothing recorded, nothing written")
+
+	recordTestDecisions(r)
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	path, err = r.Flush(now)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "decisions-20261017T000000Z.csv"), path)
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		Columns,
+		{"2026-10-16T09:00:00Z", "http://ec.europa.eu/NS/wallet-provider", "x5c", "", "SE", "allow", "trusted", "2"},
+		{"2026-10-16T10:00:00Z", "", "jwk", "acme", "", "deny", "unknown_authority", "1"},
+	}, records)
+
+	path, err = r.Flush(now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, path, "counts are reset after a flush")
+}
+
+func TestFlushKeepsCountsOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Options{Dir: dir}, nil)
+	require.NoError(t, err)
+	recordTestDecisions(r)
+
+	require.NoError(t, os.RemoveAll(dir))
+	_, err = r.Flush(testHour)
+	require.Error(t, err)
+
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	path, err := r.Flush(testHour)
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), ",2\n")
+}
+
+func TestHealth(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Options{Dir: dir}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Health{Dir: dir}, r.Health())
+
+	recordTestDecisions(r)
+	require.NoError(t, os.RemoveAll(dir))
+	r.flush(testHour)
+	health := r.Health()
+	assert.NotEmpty(t, health.LastError)
+	assert.False(t, health.LastErrorAt.IsZero())
+	assert.True(t, health.LastSuccess.IsZero())
+
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	r.flush(testHour)
+	health = r.Health()
+	assert.False(t, health.LastSuccess.Before(health.LastErrorAt), "the last write succeeded")
+
+	assert.Equal(t, Health{}, (*Rollup)(nil).Health())
+}
+
+func TestWriteParquet(t *testing.T) {
+	r, err := New(Options{Dir: t.TempDir()}, nil)
+	require.NoError(t, err)
+	recordTestDecisions(r)
+	rows := sortedRows(r.counts)
+	require.Len(t, rows, 2)
+	assert.Equal(t, testHour, rows[0].Hour)
+	assert.Equal(t, int64(2), rows[0].Count)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteParquet(&buf, rows))
+	data := buf.Bytes()
+
+	require.True(t, bytes.HasPrefix(data, []byte(parquetMagic)))
+	require.True(t, bytes.HasSuffix(data, []byte(parquetMagic)))
+	metaLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metaStart := len(data) - 8 - metaLen
+	require.Greater(t, metaStart, len(parquetMagic))
+
+	// FileMetaData
+	meta, n := decodeThriftStruct(t, data[metaStart:len(data)-8])
+	require.Equal(t, metaLen, n)
+	assert.Equal(t, int64(1), meta[1])
+	assert.Equal(t, int64(len(rows)), meta[3])
+	assert.Equal(t, parquetCreatedBy, meta[6])
+
+	schema := meta[2].([]any)
+	require.Len(t, schema, len(Columns)+1)
+	root := schema[0].(map[int16]any)
+	assert.Equal(t, "schema", root[4])
+	assert.Equal(t, int64(len(Columns)), root[5])
+
+	// Physical and converted types of the columns, in the order of Columns
+	types := map[string][2]any{"hour": {int64(2), int64(9)}, "count": {int64(2), nil}}
+	for _, name := range Columns[1 : len(Columns)-1] {
+		types[name] = [2]any{int64(6), int64(0)}
+	}
+	for i, name := range Columns {
+		el := schema[i+1].(map[int16]any)
+		assert.Equal(t, name, el[4])
+		assert.Equal(t, int64(0), el[3], "%s is required", name)
+		assert.Equal(t, types[name][0], el[1], "type of %s", name)
+		assert.Equal(t, types[name][1], el[6], "converted type of %s", name)
+	}
+
+	rowGroups := meta[4].([]any)
+	require.Len(t, rowGroups, 1)
+	rowGroup := rowGroups[0].(map[int16]any)
+	assert.Equal(t, int64(len(rows)), rowGroup[3])
+	chunks := rowGroup[1].([]any)
+	require.Len(t, chunks, len(Columns))
+
+	// Column chunks follow each other from the magic to the metadata, each a
+	// single data page of PLAIN encoded values
+	offset := int64(len(parquetMagic))
+	var total int64
+	for i, name := range Columns {
+		chunk := chunks[i].(map[int16]any)
+		md := chunk[3].(map[int16]any)
+		assert.Equal(t, offset, chunk[2], "file offset of %s", name)
+		assert.Equal(t, offset, md[9], "data page offset of %s", name)
+		assert.Equal(t, types[name][0], md[1])
+		assert.Equal(t, []any{int64(0), int64(3)}, md[2])
+		assert.Equal(t, []any{name}, md[3])
+		assert.Equal(t, int64(0), md[4])
+		assert.Equal(t, int64(len(rows)), md[5])
+
+		header, headerLen := decodeThriftStruct(t, data[offset:metaStart])
+		assert.Equal(t, int64(0), header[1])
+		size := header[2].(int64)
+		assert.Equal(t, size, header[3])
+		page := header[5].(map[int16]any)
+		assert.Equal(t, map[int16]any{1: int64(len(rows)), 2: int64(0), 3: int64(3), 4: int64(3)}, page)
+
+		chunkSize := int64(headerLen) + size
+		assert.Equal(t, chunkSize, md[6])
+		assert.Equal(t, chunkSize, md[7])
+
+		values := data[offset+int64(headerLen) : offset+chunkSize]
+		assert.Equal(t, parquetColumnValues(rows, i), decodePlain(t, values, types[name][0] == int64(2)), "values of %s", name)
+
+		offset += chunkSize
+		total += chunkSize
+	}
+	assert.Equal(t, int64(metaStart), offset)
+	assert.Equal(t, total, rowGroup[2])
+}
+
+// parquetColumnValues returns the values of column i of rows, as decodePlain
+// returns them.
+func parquetColumnValues(rows []Row, i int) []any {
+	var values []any
+	for _, row := range rows {
+		values = append(values, []any{row.Hour.UnixMilli(), row.Action, row.ResourceType, row.Tenant, row.Territory, row.Decision, row.Reason, row.Count}[i])
+	}
+	return values
+}
+
+// decodePlain decodes PLAIN encoded INT64 or BYTE_ARRAY values.
+func decodePlain(t *testing.T, data []byte, int64s bool) []any {
+	t.Helper()
+	var values []any
+	for len(data) > 0 {
+		if int64s {
+			require.GreaterOrEqual(t, len(data), 8)
+			values = append(values, int64(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+			continue
+		}
+		require.GreaterOrEqual(t, len(data), 4)
+		n := int(binary.LittleEndian.Uint32(data))
+		require.GreaterOrEqual(t, len(data), 4+n)
+		values = append(values, string(data[4:4+n]))
+		data = data[4+n:]
+	}
+	return values
+}
+
+// thriftReader decodes the Thrift compact protocol, independently of the
+// encoder, into maps of field IDs to values: int64 for integers, string for
+// binary, []any for lists and map[int16]any for structs.
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+// decodeThriftStruct decodes the struct at the start of data and returns it
+// with the number of bytes it takes.
+func decodeThriftStruct(t *testing.T, data []byte) (map[int16]any, int) {
+	t.Helper()
+	r := &thriftReader{t: t, data: data}
+	return r.structure(), r.pos
+}
+
+func (r *thriftReader) byte() byte {
+	require.Less(r.t, r.pos, len(r.data), "truncated Thrift data")
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	require.Greater(r.t, n, 0, "bad varint at %d", r.pos)
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := map[int16]any{}
+	var last int16
+	for {
+		b := r.byte()
+		typ := b & 0x0f
+		if typ == thriftStop {
+			return fields
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		switch typ {
+		case 1, 2: // Booleans are encoded in the field type
+			fields[id] = typ == 1
+		default:
+			fields[id] = r.value(typ)
+		}
+		last = id
+	}
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.uvarint())
+		require.LessOrEqual(r.t, r.pos+n, len(r.data), "truncated Thrift binary")
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		b := r.byte()
+		n := int(b >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := []any{}
+		for range n {
+			list = append(list, r.value(b&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	r.t.Fatalf("unexpected Thrift type %d at %d", typ, r.pos)
+	return nil
+}
+
+func TestZigzag(t *testing.T) {
+	assert.Equal(t, uint64(0), zigzag(0))
+	assert.Equal(t, uint64(1), zigzag(-1))
+	assert.Equal(t, uint64(2), zigzag(1))
+	assert.Equal(t, uint64(3), zigzag(-2))
+}
+
+func TestStartFlushesOnStop(t *testing.T) {
+	dir := t.TempDir()
+	r, err := New(Options{Dir: dir, Format: FormatParquet}, nil)
+	require.NoError(t, err)
+	recordTestDecisions(r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Start(ctx)
+	cancel()
+	r.Wait()
+
+	files, err := filepath.Glob(filepath.Join(dir, "decisions-*.parquet"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestNilRollup(t *testing.T) {
+	var r *Rollup
+	r.Record(Decision{Decision: DecisionAllow})
+	r.Start(context.Background())
+	r.Wait()
+}
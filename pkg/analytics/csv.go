@@ -0,0 +1,27 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteCSV writes rows to w as CSV with a header line of Columns. Hours are
+// written in RFC 3339.
+func WriteCSV(w io.Writer, rows []Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return err
+	}
+	for i := range rows {
+		row := &rows[i]
+		record := append([]string{row.Hour.UTC().Format(time.RFC3339)}, row.labels()...)
+		record = append(record, strconv.FormatInt(row.Count, 10))
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
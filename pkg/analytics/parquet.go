@@ -0,0 +1,281 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// The subset of the Parquet format written by WriteParquet: a single row
+// group with one uncompressed, PLAIN encoded data page per column. All columns
+// are required, so pages hold no repetition or definition levels. Rollups are
+// small, so nothing more elaborate is needed for any Parquet reader to load
+// them. See https://github.com/apache/parquet-format.
+
+const parquetMagic = "PAR1"
+
+// Physical types.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+)
+
+// Converted types.
+const (
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetNoConversion    = -1
+)
+
+// Encodings, page types, compression codecs and repetition types.
+const (
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetDataPage     = 0
+	parquetUncompressed = 0
+	parquetRequired     = 0
+)
+
+// parquetCreatedBy identifies the writer in the file metadata.
+const parquetCreatedBy = "go-trust analytics"
+
+// parquetColumn describes a column of the files and how its values are
+// encoded.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	appendTo  func(b []byte, row *Row) []byte // Appends the PLAIN encoded value of row
+}
+
+// parquetColumns returns the columns of the files, in the order of Columns:
+// the hour as a timestamp in milliseconds, the labels as UTF-8 strings and
+// the count as a 64-bit integer.
+func parquetColumns() []parquetColumn {
+	cols := []parquetColumn{{
+		name:      Columns[0],
+		typ:       parquetInt64,
+		converted: parquetTimestampMillis,
+		appendTo: func(b []byte, row *Row) []byte {
+			return binary.LittleEndian.AppendUint64(b, uint64(row.Hour.UnixMilli()))
+		},
+	}}
+	for i, name := range Columns[1 : len(Columns)-1] {
+		cols = append(cols, parquetColumn{
+			name:      name,
+			typ:       parquetByteArray,
+			converted: parquetUTF8,
+			appendTo: func(b []byte, row *Row) []byte {
+				s := row.labels()[i]
+				b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+				return append(b, s...)
+			},
+		})
+	}
+	return append(cols, parquetColumn{
+		name:      Columns[len(Columns)-1],
+		typ:       parquetInt64,
+		converted: parquetNoConversion,
+		appendTo: func(b []byte, row *Row) []byte {
+			return binary.LittleEndian.AppendUint64(b, uint64(row.Count))
+		},
+	})
+}
+
+// columnChunk locates the data page of a column in the file.
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+// WriteParquet writes rows to w as a Parquet file with the columns of
+// Columns.
+func WriteParquet(w io.Writer, rows []Row) error {
+	cols := parquetColumns()
+	var out bytes.Buffer
+	out.WriteString(parquetMagic)
+
+	chunks := make([]columnChunk, len(cols))
+	for i, col := range cols {
+		var values []byte
+		for j := range rows {
+			values = col.appendTo(values, &rows[j])
+		}
+		header := parquetPageHeader(len(rows), len(values))
+		chunks[i] = columnChunk{offset: int64(out.Len()), size: int64(len(header) + len(values))}
+		out.Write(header)
+		out.Write(values)
+	}
+
+	meta := parquetFileMetaData(cols, chunks, len(rows))
+	out.Write(meta)
+	out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta))))
+	out.WriteString(parquetMagic)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// parquetPageHeader encodes the PageHeader of a data page of n values taking
+// size bytes.
+func parquetPageHeader(n, size int) []byte {
+	var buf bytes.Buffer
+	t := &thriftCompact{buf: &buf}
+	t.i32(1, parquetDataPage)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.structField(5, func(t *thriftCompact) { // DataPageHeader
+		t.i32(1, int32(n))
+		t.i32(2, parquetPlain)
+		t.i32(3, parquetRLE)
+		t.i32(4, parquetRLE)
+	})
+	t.end()
+	return buf.Bytes()
+}
+
+// parquetFileMetaData encodes the FileMetaData of a file with the given
+// columns and numRows rows in a single row group.
+func parquetFileMetaData(cols []parquetColumn, chunks []columnChunk, numRows int) []byte {
+	var buf bytes.Buffer
+	t := &thriftCompact{buf: &buf}
+	t.i32(1, 1) // version
+	t.listHeader(2, thriftStruct, len(cols)+1)
+	t.elem(func(t *thriftCompact) { // Root SchemaElement
+		t.str(4, "schema")
+		t.i32(5, int32(len(cols)))
+	})
+	for _, col := range cols {
+		t.elem(func(t *thriftCompact) {
+			t.i32(1, col.typ)
+			t.i32(3, parquetRequired)
+			t.str(4, col.name)
+			if col.converted != parquetNoConversion {
+				t.i32(6, col.converted)
+			}
+		})
+	}
+	t.i64(3, int64(numRows))
+
+	var totalSize int64
+	for _, chunk := range chunks {
+		totalSize += chunk.size
+	}
+	t.listHeader(4, thriftStruct, 1)
+	t.elem(func(t *thriftCompact) { // RowGroup
+		t.listHeader(1, thriftStruct, len(cols))
+		for i, col := range cols {
+			chunk := chunks[i]
+			t.elem(func(t *thriftCompact) { // ColumnChunk
+				t.i64(2, chunk.offset)
+				t.structField(3, func(t *thriftCompact) { // ColumnMetaData
+					t.i32(1, col.typ)
+					t.listHeader(2, thriftI32, 2)
+					t.varint(zigzag(parquetPlain))
+					t.varint(zigzag(parquetRLE))
+					t.listHeader(3, thriftBinary, 1)
+					t.bytes(col.name)
+					t.i32(4, parquetUncompressed)
+					t.i64(5, int64(numRows))
+					t.i64(6, chunk.size)
+					t.i64(7, chunk.size)
+					t.i64(9, chunk.offset)
+				})
+			})
+		}
+		t.i64(2, totalSize)
+		t.i64(3, int64(numRows))
+	})
+	t.str(6, parquetCreatedBy)
+	t.end()
+	return buf.Bytes()
+}
+
+// Thrift compact protocol types.
+const (
+	thriftStop   = 0
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftCompact encodes a Thrift struct in the compact protocol, in which
+// Parquet encodes page headers and file metadata.
+type thriftCompact struct {
+	buf  *bytes.Buffer
+	last int16 // ID of the last field written
+}
+
+// field writes the header of field id of type typ.
+func (t *thriftCompact) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftCompact) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftCompact) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftCompact) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.bytes(s)
+}
+
+// structField writes field id as a struct whose fields are written by fn.
+func (t *thriftCompact) structField(id int16, fn func(t *thriftCompact)) {
+	t.field(id, thriftStruct)
+	t.elem(fn)
+}
+
+// listHeader writes the header of field id as a list of n elements of type
+// elem, which must then be written.
+func (t *thriftCompact) listHeader(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.varint(uint64(n))
+	}
+}
+
+// elem writes a struct, such as a list element, whose fields are written by
+// fn.
+func (t *thriftCompact) elem(fn func(t *thriftCompact)) {
+	nested := &thriftCompact{buf: t.buf}
+	fn(nested)
+	nested.end()
+}
+
+// end ends the struct.
+func (t *thriftCompact) end() {
+	t.buf.WriteByte(thriftStop)
+}
+
+func (t *thriftCompact) bytes(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftCompact) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+// zigzag maps signed integers to unsigned ones with small absolute values
+// mapped to small values, as the compact protocol encodes them.
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
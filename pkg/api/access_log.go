@@ -0,0 +1,181 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID of a request. An ID sent by the client, or
+// by a proxy in front of the server, is kept; otherwise one is generated. The
+// ID is returned in the response under the same header.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the gin context key of the request ID.
+const requestIDKey = "request_id"
+
+// maxRequestIDLength bounds the length of request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// Access log formats.
+const (
+	AccessLogJSON   = "json"   // One JSON object per request
+	AccessLogCommon = "common" // Common Log Format, followed by the request ID and latency in microseconds
+)
+
+// AccessLogEntry is a request recorded in the access log.
+type AccessLogEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Protocol  string    `json:"protocol"`
+	Status    int       `json:"status"`
+	Bytes     int       `json:"bytes"`
+	LatencyMS float64   `json:"latency_ms"` // Latency in milliseconds, with microsecond precision
+	RequestID string    `json:"request_id"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// RequestIDMiddleware assigns every request an ID, taken from the
+// RequestIDHeader request header if it holds a printable value of at most
+// maxRequestIDLength characters, or generated otherwise. The ID is stored in
+// the gin context (see RequestID) and set as RequestIDHeader on the response.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID assigned to the request by RequestIDMiddleware, or
+// "" if the middleware is not installed.
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit request ID in hex.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// AccessLogMiddleware writes one line per request to w, in the given format
+// (AccessLogJSON or AccessLogCommon), separately from the application log so
+// that it can be shipped to a SIEM as is. Lines are written whole even when
+// requests complete concurrently. The request ID is the one assigned by
+// RequestIDMiddleware, which must run before this middleware.
+//
+// It returns an error for an unknown format.
+func AccessLogMiddleware(w io.Writer, format string) (gin.HandlerFunc, error) {
+	var write func(io.Writer, *AccessLogEntry, time.Duration) error
+	switch strings.ToLower(format) {
+	case "", AccessLogJSON:
+		write = writeAccessLogJSON
+	case AccessLogCommon:
+		write = writeAccessLogCommon
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+
+	var mu sync.Mutex
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.RequestURI()
+
+		c.Next()
+
+		latency := time.Since(start)
+		entry := &AccessLogEntry{
+			Time:      start,
+			Method:    c.Request.Method,
+			Path:      path,
+			Protocol:  c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Bytes:     max(c.Writer.Size(), 0),
+			LatencyMS: float64(latency.Microseconds()) / 1000,
+			RequestID: RequestID(c),
+			ClientIP:  c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		_ = write(w, entry, latency)
+	}, nil
+}
+
+func writeAccessLogJSON(w io.Writer, entry *AccessLogEntry, _ time.Duration) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// writeAccessLogCommon writes entry in the Common Log Format, extended with
+// the quoted request ID and the latency in microseconds, like Apache's
+// "%h %l %u %t \"%r\" %>s %b \"%{X-Request-ID}i\" %D".
+func writeAccessLogCommon(w io.Writer, entry *AccessLogEntry, latency time.Duration) error {
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = fmt.Sprint(entry.Bytes)
+	}
+	_, err := fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %s \"%s\" %d\n",
+		entry.ClientIP,
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		entry.Method, entry.Path, entry.Protocol,
+		entry.Status, bytes,
+		entry.RequestID,
+		latency.Microseconds())
+	return err
+}
+
+// RequestLogMiddleware logs every request to the application log at info
+// level, for servers without a separate access log. Like the access log, it
+// relies on RequestIDMiddleware for the request ID.
+func RequestLogMiddleware(logger logging.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.RequestURI()
+
+		c.Next()
+
+		logger.Info("HTTP request",
+			logging.F("method", c.Request.Method),
+			logging.F("path", path),
+			logging.F("status", c.Writer.Status()),
+			logging.F("bytes", max(c.Writer.Size(), 0)),
+			logging.F("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			logging.F("request_id", RequestID(c)),
+			logging.F("client_ip", c.ClientIP()))
+	}
+}
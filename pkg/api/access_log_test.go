@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accessLogRouter returns a router that writes its access log to buf.
+func accessLogRouter(t *testing.T, buf *bytes.Buffer, format string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	accessLog, err := AccessLogMiddleware(buf, format)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), accessLog)
+	r.GET("/hello", func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	return r
+}
+
+func TestAccessLogMiddleware_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	r := accessLogRouter(t, &buf, AccessLogJSON)
+
+	req := httptest.NewRequest(http.MethodGet, "/hello?x=1", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var entry AccessLogEntry
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, http.MethodGet, entry.Method)
+	assert.Equal(t, "/hello?x=1", entry.Path)
+	assert.Equal(t, "HTTP/1.1", entry.Protocol)
+	assert.Equal(t, http.StatusOK, entry.Status)
+	assert.Equal(t, 5, entry.Bytes)
+	assert.GreaterOrEqual(t, entry.LatencyMS, 0.0)
+	assert.Equal(t, "192.0.2.1", entry.ClientIP)
+	assert.Equal(t, "test-agent", entry.UserAgent)
+	assert.Len(t, entry.RequestID, 32, "generated request ID")
+	assert.Equal(t, entry.RequestID, w.Header().Get(RequestIDHeader))
+
+	// Not found requests are logged too
+	buf.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, http.StatusNotFound, entry.Status)
+}
+
+func TestAccessLogMiddleware_Common(t *testing.T) {
+	var buf bytes.Buffer
+	r := accessLogRouter(t, &buf, AccessLogCommon)
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set(RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-123", w.Header().Get(RequestIDHeader), "client request ID is kept")
+	line := strings.TrimSuffix(buf.String(), "\n")
+	assert.Regexp(t, regexp.MustCompile(`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /hello HTTP/1\.1" 200 5 "req-123" \d+$`), line)
+}
+
+func TestAccessLogMiddleware_UnknownFormat(t *testing.T) {
+	_, err := AccessLogMiddleware(&bytes.Buffer{}, "combined")
+	assert.Error(t, err)
+}
+
+func TestRequestIDMiddleware_RejectsUnsafeIDs(t *testing.T) {
+	for _, id := range []string{"with space", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		assert.False(t, validRequestID(id), "%q", id)
+	}
+	assert.True(t, validRequestID("0f1e2d3c-abc_DEF.123"))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.GET("/", func(c *gin.Context) { c.String(http.StatusOK, RequestID(c)) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "bad id")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.NotEqual(t, "bad id", w.Body.String())
+	assert.Equal(t, w.Header().Get(RequestIDHeader), w.Body.String())
+}
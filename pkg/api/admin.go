@@ -0,0 +1,150 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuthMiddleware returns a Gin middleware that protects administrative
+// endpoints. Requests must carry an "Authorization: Bearer <token>" header with
+// the configured admin token; other requests are rejected with 401.
+func AdminAuthMiddleware(serverCtx *ServerContext, token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		serverCtx.RLock()
+		exporter := serverCtx.Audit
+		serverCtx.RUnlock()
+		defer func() { auditAdmin(exporter, c, start) }()
+
+		presented, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			serverCtx.Logger.Warn("Rejected unauthenticated admin request",
+				logging.F("remote_ip", c.ClientIP()),
+				logging.F("path", c.Request.URL.Path))
+			c.Header("WWW-Authenticate", `Bearer realm="go-trust-admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminDenialsHandler godoc
+// @Summary Recent denied decisions
+// @Description Returns the most recent negative AuthZEN decisions of this instance, newest first, with
+// @Description the subject, tenant, normalized reason code and reason message of each. Only decisions
+// @Description made since the server started are kept, up to a fixed number. Requests refused with an
+// @Description error response are not decisions and are not listed. Requires the admin bearer token.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string][]Denial "denials"
+// @Failure 401 {object} map[string]string "Missing or invalid admin token"
+// @Router /admin/denials [get]
+func AdminDenialsHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverCtx.RLock()
+		denials := serverCtx.Denials
+		serverCtx.RUnlock()
+
+		c.JSON(http.StatusOK, gin.H{"denials": denials.Recent()})
+	}
+}
+
+// AdminRefreshHandler godoc
+// @Summary Trigger a pipeline run
+// @Description Asks the background updaters to run their pipelines now instead of at their next
+// @Description scheduled time. The runs happen asynchronously: the response is sent as soon as they
+// @Description are requested, and their outcome appears in the run history and pool generation.
+// @Description A run that is already pending is not requested twice. Requires the admin bearer token.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} map[string]int "triggered: number of pipelines that will run"
+// @Failure 401 {object} map[string]string "Missing or invalid admin token"
+// @Router /admin/refresh [post]
+func AdminRefreshHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverCtx.RLock()
+		triggered := serverCtx.TriggerRefresh()
+		serverCtx.RUnlock()
+
+		serverCtx.Logger.Info("Pipeline refresh requested",
+			logging.F("remote_ip", c.ClientIP()),
+			logging.F("request_id", RequestID(c)),
+			logging.F("triggered", triggered))
+
+		c.JSON(http.StatusAccepted, gin.H{"triggered": triggered})
+	}
+}
+
+// SigningKeySummary lists a key that signed published TSLs.
+type SigningKeySummary struct {
+	SHA256   string    `json:"sha256"`    // Hex SHA-256 fingerprint of the signing certificate
+	Subject  string    `json:"subject"`   // Subject of the signing certificate
+	NotAfter time.Time `json:"not_after"` // End of the validity of the signing certificate
+	Files    int       `json:"files"`     // Number of published files signed with the key
+}
+
+// PublishedSignersResponse is the response of GET /admin/signers.
+type PublishedSignersResponse struct {
+	Keys  []SigningKeySummary           `json:"keys"`  // Keys with valid signatures, by number of files signed
+	Files []pipeline.PublishedSignature `json:"files"` // Signature of every published TSL, by path
+}
+
+// AdminSignersHandler godoc
+// @Summary Keys that signed the published TSLs
+// @Description Verifies the XML-DSIG signature of every TSL XML file in the publish directory and
+// @Description reports the signing certificate of each, and the keys that signed them, for key
+// @Description ceremonies and signing key rollovers. Copies signed with the next key during a
+// @Description rollover are below next/ and marked next. Files that are not signed, or whose
+// @Description signature does not verify, are listed with signed or valid false. Requires the admin
+// @Description bearer token.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} PublishedSignersResponse "Signing keys and signatures of the published files"
+// @Failure 401 {object} map[string]string "Missing or invalid admin token"
+// @Failure 500 {object} map[string]string "The publish directory could not be read"
+// @Router /admin/signers [get]
+func AdminSignersHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverCtx.RLock()
+		dir := serverCtx.PublishDir
+		serverCtx.RUnlock()
+
+		files, err := pipeline.PublishedSignatures(dir)
+		if err != nil {
+			serverCtx.Logger.Error("Failed to read published signatures",
+				logging.F("dir", dir),
+				logging.F("error", err.Error()))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read the publish directory"})
+			return
+		}
+
+		keys := []SigningKeySummary{}
+		index := make(map[string]int)
+		for _, f := range files {
+			if !f.Valid {
+				continue
+			}
+			i, ok := index[f.SHA256]
+			if !ok {
+				i = len(keys)
+				index[f.SHA256] = i
+				keys = append(keys, SigningKeySummary{SHA256: f.SHA256, Subject: f.Subject, NotAfter: f.NotAfter})
+			}
+			keys[i].Files++
+		}
+		sort.SliceStable(keys, func(i, j int) bool { return keys[i].Files > keys[j].Files })
+
+		c.JSON(http.StatusOK, PublishedSignersResponse{Keys: keys, Files: files})
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	r := gin.New()
+	r.GET("/admin", AdminAuthMiddleware(serverCtx, "s3cret"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized},
+		{"empty bearer", "Bearer ", http.StatusUnauthorized},
+		{"valid token", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+				assert.JSONEq(t, `{"error":"unauthorized"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminAuthMiddleware_EmptyToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	r := gin.New()
+	r.GET("/admin", AdminAuthMiddleware(serverCtx, ""), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// setupAdminRoutes returns a router with the API routes of serverCtx and the
+// admin token "s3cret".
+func setupAdminRoutes(serverCtx *ServerContext) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	serverCtx.AdminToken = "s3cret"
+	r := gin.New()
+	RegisterAPIRoutes(r, serverCtx)
+	return r
+}
+
+func TestAdminDenialsHandler(t *testing.T) {
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	serverCtx.Denials.Record(Denial{SubjectID: "alice", Reason: ReasonUnknownAuthority})
+	serverCtx.Denials.Record(Denial{SubjectID: "bob", Reason: ReasonExpired})
+	r := setupAdminRoutes(serverCtx)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/denials", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/denials", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Denials []Denial `json:"denials"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Denials, 2)
+	assert.Equal(t, "bob", resp.Denials[0].SubjectID, "newest first")
+	assert.Equal(t, ReasonExpired, resp.Denials[0].Reason)
+	assert.Equal(t, "alice", resp.Denials[1].SubjectID)
+}
+
+func TestAdminSignersHandler(t *testing.T) {
+	signer, err := testutil.NewSelfSigned("TSL Signer", testutil.WithRSAKey(2048))
+	require.NoError(t, err)
+	keyPEM, err := signer.KeyPEM()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "signer.pem")
+	keyFile := filepath.Join(dir, "signer.key")
+	require.NoError(t, os.WriteFile(certFile, signer.CertPEM(), 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+
+	publishDir := t.TempDir()
+	for _, name := range []string{"se.xml", "no.xml"} {
+		signed, err := dsig.NewFileSigner(certFile, keyFile).Sign([]byte(`<TrustServiceStatusList Id="tsl"/>`))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(publishDir, name), signed, 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(publishDir, "unsigned.xml"), []byte(`<TrustServiceStatusList/>`), 0644))
+
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	serverCtx.PublishDir = publishDir
+	r := setupAdminRoutes(serverCtx)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/signers", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp PublishedSignersResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Files, 3)
+	require.Len(t, resp.Keys, 1)
+	assert.Equal(t, 2, resp.Keys[0].Files)
+	assert.Equal(t, "CN=TSL Signer", resp.Keys[0].Subject)
+	assert.Equal(t, resp.Keys[0].SHA256, resp.Files[0].SHA256)
+	assert.Equal(t, "unsigned.xml", resp.Files[2].File)
+	assert.False(t, resp.Files[2].Signed)
+
+	require.NoError(t, os.RemoveAll(publishDir))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestAdminRefreshHandler(t *testing.T) {
+	pl, calls := newCountingPipeline("admin_refresh", nil)
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, time.Hour)
+	require.NoError(t, err)
+	defer updater.Stop()
+	r := setupAdminRoutes(serverCtx)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/refresh", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, int32(1), calls.Load(), "unauthenticated requests must not trigger a run")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/refresh", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.JSONEq(t, `{"triggered":1}`, w.Body.String())
+	assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/analytics"
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/registry/etsi"
+)
+
+// recordAnalytics counts the decision answered with resp in rollup. labels
+// are the decision's metric labels, with its reason code; action names are
+// limited as in metrics, if not nil, so that clients cannot grow the rollup
+// without bound. A nil resp is a decision that could not be made.
+func recordAnalytics(rollup *analytics.Rollup, metrics *Metrics, labels DecisionLabels, resp *authzen.EvaluationResponse) {
+	if rollup == nil {
+		return
+	}
+	action := labels.Action
+	if metrics != nil {
+		action = metrics.actionLabel(action)
+	}
+	d := analytics.Decision{
+		Time:         time.Now(),
+		Action:       action,
+		ResourceType: resourceTypeLabel(labels.ResourceType),
+		Tenant:       labels.Tenant,
+		Decision:     analytics.DecisionUnknown,
+		Reason:       labels.Reason,
+	}
+	if resp != nil {
+		d.Decision = analytics.DecisionDeny
+		if resp.Decision {
+			d.Decision = analytics.DecisionAllow
+			d.Territory = decisionTerritory(resp)
+		}
+	}
+	rollup.Record(d)
+}
+
+// decisionTerritory returns the scheme territory of the TSL listing the trust
+// service a positive decision is attributed to, or "" if unknown.
+func decisionTerritory(resp *authzen.EvaluationResponse) string {
+	if resp.Context == nil {
+		return ""
+	}
+	if ts, ok := resp.Context.Reason[etsi.ReasonKeyTrustService].(*pipeline.MatchedTrustService); ok && ts != nil {
+		return ts.Territory
+	}
+	return ""
+}
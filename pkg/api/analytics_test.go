@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/analytics"
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/registry/etsi"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthZENDecisionHandler_Analytics(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "wallet.example.com")
+	require.NoError(t, err)
+
+	rollup, err := analytics.New(analytics.Options{Dir: t.TempDir()}, nil)
+	require.NoError(t, err)
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.Analytics = rollup
+	serverCtx.Unlock()
+
+	body := `{"subject":{"type":"key","id":"wallet"},"resource":{"type":"x5c","id":"wallet","key":["` + leaf.Base64() + `"]},"action":{"name":"wallet-provider"}}`
+	for range 2 {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	path, err := rollup.Flush(time.Now())
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[1], ",wallet-provider,x5c,none,,deny,"+ReasonUnknownAuthority+",2"), lines[1])
+}
+
+func TestDecisionTerritory(t *testing.T) {
+	ts := &pipeline.MatchedTrustService{}
+	ts.Territory = "SE"
+	resp := &authzen.EvaluationResponse{
+		Decision: true,
+		Context: &authzen.EvaluationResponseContext{Reason: map[string]interface{}{
+			etsi.ReasonKeyTrustService: ts,
+		}},
+	}
+	assert.Equal(t, "SE", decisionTerritory(resp))
+	assert.Empty(t, decisionTerritory(&authzen.EvaluationResponse{Decision: true}))
+}
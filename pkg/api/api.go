@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
-	"time"
 
 	"github.com/SUNET/go-trust/pkg/authzen"
 	"github.com/SUNET/go-trust/pkg/logging"
@@ -133,96 +132,6 @@ func buildResponse(decision bool, reason string) authzen.EvaluationResponse {
 	}
 }
 
-// StartBackgroundUpdater runs the pipeline at regular intervals and updates the server context.
-// This function starts a goroutine that processes the pipeline at the specified frequency
-// and updates the ServerContext with the new pipeline results. The updated context is then
-// used by API handlers to respond to requests with fresh data.
-//
-// The pipeline is processed immediately upon calling this function, before starting the
-// background updates. This ensures TSLs are available as soon as the server starts.
-//
-// Success and failure events are logged using the ServerContext's structured logger:
-// - On success: An info-level message with the update frequency
-// - On failure: An error-level message with the error details and frequency
-//
-// Parameters:
-//   - pl: The pipeline to process periodically
-//   - serverCtx: The server context to update with pipeline results (must have a valid logger)
-//   - freq: The frequency at which to process the pipeline (e.g., 5m for every 5 minutes)
-//
-// This function is typically called at server startup to ensure TSLs are kept up-to-date.
-func StartBackgroundUpdater(pl *pipeline.Pipeline, serverCtx *ServerContext, freq time.Duration) error {
-	// Process pipeline immediately to ensure TSLs are loaded without waiting
-	start := time.Now()
-	newCtx, err := pl.Process(pipeline.NewContext())
-	duration := time.Since(start)
-
-	serverCtx.Lock()
-	if err == nil && newCtx != nil {
-		serverCtx.PipelineContext = newCtx
-		serverCtx.LastProcessed = time.Now()
-		tslCount := countTSLs(newCtx)
-		serverCtx.Logger.Info("Initial pipeline processing successful",
-			logging.F("tsl_count", tslCount))
-
-		// Record metrics if available
-		if serverCtx.Metrics != nil {
-			serverCtx.Metrics.RecordPipelineExecution(duration, tslCount, nil)
-		}
-	} else if err != nil {
-		serverCtx.Logger.Error("Initial pipeline processing failed",
-			logging.F("error", err.Error()))
-
-		// Record error metrics if available
-		if serverCtx.Metrics != nil {
-			serverCtx.Metrics.RecordPipelineExecution(duration, 0, err)
-		}
-	}
-	serverCtx.Unlock()
-
-	// Start background processing
-	go func() {
-		for {
-			time.Sleep(freq)
-
-			start := time.Now()
-			newCtx, err := pl.Process(pipeline.NewContext())
-			duration := time.Since(start)
-
-			serverCtx.Lock()
-			if err == nil && newCtx != nil {
-				serverCtx.PipelineContext = newCtx
-				serverCtx.LastProcessed = time.Now()
-			}
-			serverCtx.Unlock()
-
-			if err != nil {
-				// ServerContext always has a logger after our improvements
-				serverCtx.Logger.Error("Pipeline processing failed",
-					logging.F("error", err.Error()),
-					logging.F("frequency", freq.String()))
-
-				// Record error metrics if available
-				if serverCtx.Metrics != nil {
-					serverCtx.Metrics.RecordPipelineExecution(duration, 0, err)
-				}
-			} else {
-				// Log successful update
-				tslCount := countTSLs(newCtx)
-				serverCtx.Logger.Info("Pipeline processed successfully",
-					logging.F("frequency", freq.String()),
-					logging.F("tsl_count", tslCount))
-
-				// Record metrics if available
-				if serverCtx.Metrics != nil {
-					serverCtx.Metrics.RecordPipelineExecution(duration, tslCount, nil)
-				}
-			}
-		}
-	}()
-	return nil
-}
-
 // countTSLs counts the number of TSLs in the pipeline context.
 // This is a helper function to provide consistent TSL counting for logging.
 func countTSLs(ctx *pipeline.Context) int {
@@ -234,14 +143,16 @@ func countTSLs(ctx *pipeline.Context) int {
 
 // NewServerContext creates a new ServerContext with a configured logger.
 // The ServerContext will always have a valid logger - if none is provided,
-// it will use the DefaultLogger.
+// it will use the DefaultLogger. It keeps the last DefaultDenialLogSize
+// denied decisions in Denials.
 func NewServerContext(logger logging.Logger) *ServerContext {
 	// Always ensure a valid logger
 	if logger == nil {
 		logger = logging.DefaultLogger()
 	}
 	return &ServerContext{
-		Logger: logger,
+		Logger:  logger,
+		Denials: NewDenialLog(DefaultDenialLogSize),
 	}
 }
 
@@ -261,17 +172,65 @@ func NewServerContext(logger logging.Logger) *ServerContext {
 //	validating that a public key (in resource.key) is correctly bound to a name (in subject.id)
 //	according to the trusted certificates in the pipeline context.
 //
+// GET /schemas - Lists the JSON Schemas of the evaluation request and response
+//
+// GET /schemas/:name - Returns the JSON Schema evaluation-request.json or evaluation-response.json
+//
 // TSL Information:
 //
 // GET /tsls - Returns detailed information about all loaded Trust Status Lists
 //
+// GET /certificates - Lists the certificates of the certificate pool with the TSL
+// services they were selected from
+//
+// GET /certificates/:sha256 - Returns a single pool certificate by fingerprint
+//
+// Build Information:
+//
+// GET /version - Returns the version, commit, build date, Go version, enabled features
+// and active pipeline hash of the instance (see ServerContext.BuildInfo)
+//
+// Subsystem Health:
+//
+// GET /status/components - Returns the health of the pipeline updater, signer, registries,
+// caches, storage and outbound connectivity (see ServerContext.Components)
+//
 // Deprecated Endpoints (will be removed in v2.0.0):
 //
 // GET /status - DEPRECATED: Use GET /readyz instead
 //
 // GET /info - DEPRECATED: Use GET /tsls instead
 //
+// Published Files:
+//
+// GET, HEAD /published/*filepath - Serves files from ServerContext.PublishDir, if set
+//
+// TSL Proxy (registered only if ServerContext.Proxy is set):
+//
+// GET /proxy - Lists the TSLs fetched by the default pipeline that the proxy serves
+//
+// GET, HEAD /proxy/*path - Serves a fetched TSL, with its original signature, under the host and path of its URL
+//
+// Admin Endpoints (registered only if ServerContext.AdminToken is set, and
+// requiring "Authorization: Bearer <AdminToken>"):
+//
+// POST /debug/verify - Returns a non-authoritative verification report for a certificate chain
+//
+// GET /admin/denials - Returns the most recent denied decisions (see ServerContext.Denials)
+//
+// POST /admin/refresh - Triggers a run of the background updaters (see ServerContext.TriggerRefresh)
+//
+// GET /admin/signers - Reports the keys that signed the TSLs in ServerContext.PublishDir, if set
+//
+// Operator Dashboard:
+//
+// GET /ui/ - Serves the operator dashboard (see RegisterUIEndpoints)
+//
+// The /tsls, /certificates and /info responses are gzip-compressed for clients that accept it.
+//
 // If a RateLimiter is configured in the ServerContext, it will be applied to all routes.
+// If an EvaluationLimit is configured, POST /evaluation requests beyond it are
+// answered with 503 and Retry-After.
 func RegisterAPIRoutes(r *gin.Engine, serverCtx *ServerContext) {
 	// Apply rate limiting middleware if configured
 	if serverCtx.RateLimiter != nil {
@@ -284,15 +243,65 @@ func RegisterAPIRoutes(r *gin.Engine, serverCtx *ServerContext) {
 	// AuthZEN well-known discovery endpoint (Section 9 of base spec)
 	r.GET("/.well-known/authzen-configuration", WellKnownHandler(serverCtx.BaseURL))
 
-	// AuthZEN evaluation endpoint
-	r.POST("/evaluation", AuthZENDecisionHandler(serverCtx))
+	// AuthZEN evaluation endpoint, with excess requests shed if a
+	// concurrency limit is configured
+	if serverCtx.EvaluationLimit != nil {
+		r.POST("/evaluation", serverCtx.EvaluationLimit.Middleware(serverCtx.Metrics), AuthZENDecisionHandler(serverCtx))
+		serverCtx.Logger.Info("Evaluation concurrency limit enabled",
+			logging.F("max", serverCtx.EvaluationLimit.Max()))
+	} else {
+		r.POST("/evaluation", AuthZENDecisionHandler(serverCtx))
+	}
+
+	// JSON Schemas of the evaluation request and response
+	r.GET(SchemasPath, SchemasHandler(serverCtx.BaseURL))
+	r.GET(SchemasPath+"/:name", SchemaHandler(serverCtx.BaseURL))
 
 	// TSL information endpoint
-	r.GET("/tsls", TSLsHandler(serverCtx))
+	r.GET("/tsls", GzipMiddleware(), TSLsHandler(serverCtx))
+
+	// Trusted certificates with their provenance
+	r.GET("/certificates", GzipMiddleware(), CertificatesHandler(serverCtx))
+	r.GET("/certificates/:sha256", CertificateHandler(serverCtx))
+
+	// Build and feature information
+	r.GET("/version", VersionHandler(serverCtx))
+
+	// Health of each subsystem, for dashboards
+	r.GET("/status/components", ComponentsHandler(serverCtx))
+
+	// Published TSL files with their sidecars, for mirrors
+	if serverCtx.PublishDir != "" {
+		published := PublishedFileHandler(serverCtx, serverCtx.PublishDir)
+		r.GET("/published/*filepath", published)
+		r.HEAD("/published/*filepath", published)
+	}
+
+	// Fetched TSLs re-served under stable local URLs, for internal consumers
+	if serverCtx.Proxy != nil {
+		r.GET(ProxyPath, ProxyIndexHandler(serverCtx, serverCtx.Proxy))
+		proxy := ProxyTSLHandler(serverCtx, serverCtx.Proxy)
+		r.GET(ProxyPath+"/*path", proxy)
+		r.HEAD(ProxyPath+"/*path", proxy)
+	}
+
+	// Admin diagnostics, only with an admin token configured
+	if serverCtx.AdminToken != "" {
+		adminAuth := AdminAuthMiddleware(serverCtx, serverCtx.AdminToken)
+		r.POST("/debug/verify", adminAuth, DebugVerifyHandler(serverCtx))
+		r.GET("/admin/denials", adminAuth, AdminDenialsHandler(serverCtx))
+		r.POST("/admin/refresh", adminAuth, AdminRefreshHandler(serverCtx))
+		if serverCtx.PublishDir != "" {
+			r.GET("/admin/signers", adminAuth, AdminSignersHandler(serverCtx))
+		}
+	}
+
+	// Operator dashboard, rendered in the browser from the endpoints above
+	RegisterUIEndpoints(r)
 
 	// Deprecated endpoints (kept for backward compatibility)
 	r.GET("/status", StatusHandler(serverCtx))
-	r.GET("/info", InfoHandler(serverCtx))
+	r.GET("/info", GzipMiddleware(), InfoHandler(serverCtx))
 
 	// Test-mode shutdown endpoint
 	// This endpoint is only registered when GO_TRUST_TEST_MODE environment variable is set
@@ -1,25 +1,28 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"os/exec"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/authzen"
 	"github.com/SUNET/go-trust/pkg/logging"
 	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/registry"
+	"github.com/SUNET/go-trust/pkg/testutil"
 	"github.com/SUNET/go-trust/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Test selectCertPool with no TSLs, no trust services, and no matching policy
@@ -168,50 +171,13 @@ var testCertBase64 string
 var testCertDER []byte
 var testCert *x509.Certificate
 
-// generateTestCertBase64 runs openssl to generate a self-signed cert and returns the base64-encoded DER string.
+// generateTestCertBase64 generates a self-signed CA certificate and returns the base64-encoded DER string.
 func generateTestCertBase64() (string, []byte, *x509.Certificate, error) {
-	// Use unique temp files for key, cert, and der
-	keyFile, err := os.CreateTemp("", "testkey-*.pem")
+	cert, err := testutil.NewCA("Test Cert")
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create temp key file: %w", err)
+		return "", nil, nil, err
 	}
-	defer os.Remove(keyFile.Name())
-	keyFile.Close()
-	certFile, err := os.CreateTemp("", "testcert-*.pem")
-	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create temp cert file: %w", err)
-	}
-	defer os.Remove(certFile.Name())
-	certFile.Close()
-	derFile, err := os.CreateTemp("", "testcert-*.der")
-	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create temp der file: %w", err)
-	}
-	defer os.Remove(derFile.Name())
-	derFile.Close()
-
-	// Build the openssl command using the temp files
-	opensslCmd := fmt.Sprintf("openssl req -x509 -newkey rsa:2048 -keyout %s -out %s -days 365 -nodes -subj '/CN=Test Cert' 2>/dev/null && openssl x509 -outform der -in %s -out %s 2>/dev/null && openssl base64 -in %s -A 2>/dev/null", keyFile.Name(), certFile.Name(), certFile.Name(), derFile.Name(), derFile.Name())
-	cmd := exec.Command("bash", "-c", opensslCmd)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	// Do not capture Stderr, as it is redirected in the shell command
-	err = cmd.Run()
-	output := out.String()
-	if err != nil {
-		// Print the OpenSSL output for debugging
-		return "", nil, nil, fmt.Errorf("openssl error: %v\noutput: %s", err, output)
-	}
-	certBase64 := strings.TrimSpace(output)
-	certDER, err := base64.StdEncoding.DecodeString(certBase64)
-	if err != nil {
-		return certBase64, nil, nil, fmt.Errorf("base64 decode error: %v\noutput: %s", err, output)
-	}
-	cert, err := x509.ParseCertificate(certDER)
-	if err != nil {
-		return certBase64, certDER, nil, fmt.Errorf("parse cert error: %v\noutput: %s", err, output)
-	}
-	return certBase64, certDER, cert, nil
+	return cert.Base64(), cert.DER, cert.Certificate, nil
 }
 
 func init() {
@@ -254,6 +220,25 @@ func TestStatusEndpoint(t *testing.T) {
 	assert.Equal(t, 200, w.Code)
 	assert.Contains(t, w.Body.String(), "tsl_count")
 	assert.Contains(t, w.Body.String(), "last_processed")
+	assert.Contains(t, w.Body.String(), `"runs":[]`)
+
+	// Run reports recorded by the updater are included, and change the ETag
+	etag := w.Header().Get("ETag")
+	serverCtx.Lock()
+	serverCtx.RecordRun(&pipeline.RunReport{
+		Start: time.Now(),
+		Steps: []pipeline.StepReport{{Name: "load", ItemsProcessed: 3}},
+		Error: "step 1 (select) failed",
+	})
+	serverCtx.Unlock()
+
+	req, _ = http.NewRequest("GET", "/status", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"items_processed":3`)
+	assert.Contains(t, w.Body.String(), "step 1 (select) failed")
 }
 
 func TestInfoEndpoint_Empty(t *testing.T) {
@@ -265,6 +250,25 @@ func TestInfoEndpoint_Empty(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "tsl_summaries")
 }
 
+func TestInfoEndpoint_Signature(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	tsl := &etsi119612.TSL{Source: "https://example.com/se.xml"}
+	serverCtx.Lock()
+	serverCtx.PipelineContext.TSLs = utils.NewStack[*etsi119612.TSL]()
+	serverCtx.PipelineContext.TSLs.Push(tsl)
+	serverCtx.PipelineContext.RecordSignature(tsl, &pipeline.TSLSignature{Status: pipeline.SignatureVerified, Signer: "CN=SE Operator", Admitted: true})
+	serverCtx.Unlock()
+
+	for _, path := range []string{"/info", "/tsls"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code)
+		assert.Contains(t, w.Body.String(), `"signature":{"status":"verified","signer":"CN=SE Operator","admitted":true}`, path)
+		assert.Contains(t, w.Body.String(), `"source_url":"https://example.com/se.xml"`, path)
+	}
+}
+
 func TestInfoEndpoint_NilAndMixedTSLs(t *testing.T) {
 	r, serverCtx := setupTestServer()
 
@@ -412,32 +416,28 @@ func TestAuthzenDecisionEndpoint_Errors(t *testing.T) {
 	}
 
 	// Valid JSON, but violates AuthZEN Trust Registry Profile validation
-	// (subject.type is not "key")
-	// Per AuthZEN spec, validation errors return 200 with decision=false
+	// (subject.type is not "key"): refused as a bad request, not decided
 	body := `{"subject":{"type":"user","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[]}}`
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, httptest.NewRequest("POST", "/evaluation", strings.NewReader(body)))
-	if w.Code != 200 {
-		t.Errorf("Expected 200 for validation error (AuthZEN spec), got %d", w.Code)
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for validation error, got %d", w.Code)
 	}
-	var respValidation map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &respValidation)
-	if respValidation["decision"] != false {
-		t.Errorf("Expected decision=false for validation error, got %v", respValidation["decision"])
+	if ct := w.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Errorf("Expected %s for validation error, got %s", ProblemContentType, ct)
 	}
 
 	// Valid JSON, but resource.id != subject.id (validation error)
-	// Per AuthZEN spec, validation errors return 200 with decision=false
 	body = `{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"bob","key":["` + testCertBase64 + `"]}}`
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, httptest.NewRequest("POST", "/evaluation", strings.NewReader(body)))
-	if w.Code != 200 {
-		t.Errorf("Expected 200 for resource.id != subject.id (AuthZEN spec), got %d", w.Code)
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for resource.id != subject.id, got %d", w.Code)
 	}
-	var respMismatch map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &respMismatch)
-	if respMismatch["decision"] != false {
-		t.Errorf("Expected decision=false for ID mismatch, got %v", respMismatch["decision"])
+	var problem Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	if !strings.Contains(problem.Detail, "must match subject.id") {
+		t.Errorf("Expected ID mismatch detail, got %q", problem.Detail)
 	}
 
 	// Valid JSON, missing CertPool
@@ -448,17 +448,20 @@ func TestAuthzenDecisionEndpoint_Errors(t *testing.T) {
 	body = `{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":["` + testCertBase64 + `"]}}`
 	w = httptest.NewRecorder()
 	r2.ServeHTTP(w, httptest.NewRequest("POST", "/evaluation", strings.NewReader(body)))
-	if !strings.Contains(w.Body.String(), "CertPool is nil") {
-		t.Errorf("Expected CertPool is nil error, got %s", w.Body.String())
+	if w.Code != 503 {
+		t.Errorf("Expected 503 for missing CertPool, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), ErrNoTrustData.Error()) {
+		t.Errorf("Expected %q error, got %s", ErrNoTrustData, w.Body.String())
 	}
 
-	// Valid JSON, cert verification failure (garbage cert)
+	// Valid JSON, key material that is not a certificate
 	garbageCert := base64.StdEncoding.EncodeToString([]byte("notacert"))
 	body = fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":["%s"]}}`, garbageCert)
 	w = httptest.NewRecorder()
 	r.ServeHTTP(w, httptest.NewRequest("POST", "/evaluation", strings.NewReader(body)))
-	if !strings.Contains(w.Body.String(), "\"decision\":false") {
-		t.Errorf("Expected decision:false for cert verification failure, got %s", w.Body.String())
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for unparseable certificate, got %d: %s", w.Code, w.Body.String())
 	}
 }
 
@@ -480,7 +483,11 @@ func TestStartBackgroundUpdater(t *testing.T) {
 		Logger: logging.DefaultLogger(), // Initialize server context logger
 	}
 	interval := 10 * time.Millisecond
-	_ = StartBackgroundUpdater(pl, serverCtx, interval)
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, interval)
+	if err != nil {
+		t.Fatalf("StartBackgroundUpdater failed: %v", err)
+	}
+	defer updater.Stop()
 
 	// Wait for the updater to run at least once
 	time.Sleep(30 * time.Millisecond)
@@ -633,3 +640,182 @@ func TestRateLimiting_Disabled(t *testing.T) {
 		assert.Equal(t, 200, w.Code, "Request %d should succeed when rate limiting disabled", i+1)
 	}
 }
+
+func TestAuthZENDecisionHandler_Qualification(t *testing.T) {
+	r, serverCtx := setupTestServer()
+
+	ca, err := testutil.NewCA("Qualified CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().WithTerritory("SE").
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("QC Service").WithCert(ca))).
+		Build())
+	ctx.CertPool = ca.Pool()
+	serverCtx.Lock()
+	serverCtx.PipelineContext = ctx
+	serverCtx.Unlock()
+
+	evaluate := func(requestContext string) map[string]interface{} {
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}%s}`, leaf.Base64(), requestContext)
+		req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := evaluate(`,"context":{"qualification":true}`)
+	assert.Equal(t, true, resp["decision"])
+	qualification := resp["context"].(map[string]interface{})["reason"].(map[string]interface{})["qualification"].(map[string]interface{})
+	assert.Equal(t, pipeline.QualificationQualified, qualification["level"])
+	assert.Equal(t, true, qualification["qualified"])
+	assert.Equal(t, "QC Service", qualification["service_name"])
+	assert.Equal(t, "SE", qualification["territory"])
+
+	// Without the request context key only the matched trust service is given
+	resp = evaluate("")
+	assert.Equal(t, true, resp["decision"])
+	reason := resp["context"].(map[string]interface{})["reason"].(map[string]interface{})
+	assert.NotContains(t, reason, "qualification")
+	trustService := reason["trust_service"].(map[string]interface{})
+	assert.Equal(t, "Test TSP", trustService["provider"])
+	assert.Equal(t, "QC Service", trustService["service"])
+	assert.Equal(t, "SE", trustService["territory"])
+	assert.Equal(t, pipeline.Fingerprint(ca.Certificate), trustService["fingerprint"])
+}
+
+func TestAuthZENDecisionHandler_PoolGeneration(t *testing.T) {
+	r, serverCtx := setupTestServer()
+
+	ca, err := testutil.NewCA("Generation CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	evaluate := func() map[string]interface{} {
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, leaf.Base64())
+		req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	trusted := pipeline.NewContext()
+	trusted.CertPool = ca.Pool()
+	serverCtx.Lock()
+	serverCtx.InstallContext("", trusted)
+	serverCtx.Unlock()
+
+	resp := evaluate()
+	assert.Equal(t, true, resp["decision"])
+	assert.Equal(t, float64(1), resp["context"].(map[string]interface{})["pool_generation"])
+
+	// A swapped-in pool without the CA denies, and reports the new generation
+	untrusted := pipeline.NewContext()
+	untrusted.CertPool = x509.NewCertPool()
+	serverCtx.Lock()
+	serverCtx.InstallContext("", untrusted)
+	serverCtx.Unlock()
+
+	resp = evaluate()
+	assert.Equal(t, false, resp["decision"])
+	assert.Equal(t, float64(2), resp["context"].(map[string]interface{})["pool_generation"])
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var status map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, float64(2), status["pool_generation"])
+}
+
+// entityRegistry is a TrustRegistry that trusts one OpenID Federation entity.
+type entityRegistry struct {
+	trusted string
+}
+
+func (e *entityRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRequest) (*authzen.EvaluationResponse, error) {
+	if req.Resource.ID != e.trusted {
+		return &authzen.EvaluationResponse{Context: &authzen.EvaluationResponseContext{
+			Reason: map[string]interface{}{"message": "no valid trust chain found"},
+		}}, nil
+	}
+	return &authzen.EvaluationResponse{Decision: true, Context: &authzen.EvaluationResponseContext{
+		Reason: map[string]interface{}{
+			"entity_id":    req.Resource.ID,
+			"trust_chain":  []string{req.Resource.ID, "https://ta.example.com"},
+			"trust_anchor": "https://ta.example.com",
+		},
+	}}, nil
+}
+
+func (e *entityRegistry) SupportedResourceTypes() []string {
+	return []string{authzen.ResourceTypeEntity}
+}
+
+func (e *entityRegistry) Info() registry.RegistryInfo {
+	return registry.RegistryInfo{Name: "federation", Type: "openid_federation"}
+}
+
+func (e *entityRegistry) Healthy() bool                     { return true }
+func (e *entityRegistry) Refresh(ctx context.Context) error { return nil }
+
+func TestAuthZENDecisionHandler_EntityRouting(t *testing.T) {
+	r, serverCtx := setupTestServer()
+
+	evaluate := func(body string) map[string]interface{} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	entity := func(id string) string {
+		return fmt.Sprintf(`{"subject":{"type":"entity","id":%q},"resource":{"type":"entity","id":%q}}`, id, id)
+	}
+
+	// Without a registry for entities, they are denied
+	resp := evaluate(entity("https://rp.example.com"))
+	assert.Equal(t, false, resp["decision"])
+	assert.Equal(t, "no applicable registries for resource type",
+		resp["context"].(map[string]interface{})["reason"].(map[string]interface{})["error"])
+
+	mgr := registry.NewRegistryManager(registry.FirstMatch, time.Second)
+	mgr.Register(&entityRegistry{trusted: "https://rp.example.com"})
+	serverCtx.Lock()
+	serverCtx.RegistryManager = mgr
+	serverCtx.Unlock()
+
+	resp = evaluate(entity("https://rp.example.com"))
+	assert.Equal(t, true, resp["decision"])
+	reason := resp["context"].(map[string]interface{})["reason"].(map[string]interface{})
+	assert.Equal(t, "https://ta.example.com", reason["trust_anchor"])
+	assert.Equal(t, []interface{}{"https://rp.example.com", "https://ta.example.com"}, reason["trust_chain"])
+
+	assert.Equal(t, false, evaluate(entity("https://other.example.com"))["decision"])
+
+	// Certificate chains are still evaluated against the pipeline's pool,
+	// which no registry of the manager handles
+	ca, err := testutil.NewCA("Entity Routing CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+	trusted := pipeline.NewContext()
+	trusted.CertPool = ca.Pool()
+	serverCtx.Lock()
+	serverCtx.InstallContext("", trusted)
+	serverCtx.Unlock()
+
+	resp = evaluate(fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, leaf.Base64()))
+	assert.Equal(t, true, resp["decision"])
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/audit"
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/gin-gonic/gin"
+)
+
+// auditRequestKey is the gin context key of the AuthZEN request recorded in
+// decision events, see setAuditRequest.
+const auditRequestKey = "audit_request"
+
+// setAuditRequest keeps req, as received, to be recorded in the decision
+// events of the request answered by c, if exporter includes requests. The
+// tenant selected by the TenantHeader header is added to the request context,
+// so that the recorded request is decided for the same tenant when replayed.
+func setAuditRequest(exporter *audit.Exporter, c *gin.Context, req *authzen.EvaluationRequest, tenant string) {
+	if !exporter.IncludesRequests() {
+		return
+	}
+	recorded := *req
+	if tenant != "" {
+		recorded.Context = maps.Clone(req.Context)
+		if recorded.Context == nil {
+			recorded.Context = map[string]interface{}{}
+		}
+		recorded.Context[ContextKeyTenant] = tenant
+	}
+	if data, err := json.Marshal(&recorded); err == nil {
+		c.Set(auditRequestKey, json.RawMessage(data))
+	}
+}
+
+// auditDecision records the decision on req, answered with resp, with
+// exporter. labels are the decision's metric labels, with its reason code. A
+// nil resp is a decision that could not be made; a decision forced by an
+// override is recorded as an override event.
+func auditDecision(exporter *audit.Exporter, c *gin.Context, req *authzen.EvaluationRequest, labels DecisionLabels, resp *authzen.EvaluationResponse, message string, generation uint64, duration time.Duration) {
+	if exporter == nil {
+		return
+	}
+	e := audit.Event{
+		Time:         time.Now(),
+		Kind:         audit.KindDecision,
+		Outcome:      audit.OutcomeUnknown,
+		RequestID:    RequestID(c),
+		ClientIP:     c.ClientIP(),
+		SubjectID:    req.Subject.ID,
+		ResourceType: resourceTypeLabel(req.Resource.Type),
+		Action:       labels.Action,
+		Tenant:       labels.Tenant,
+		Purpose:      labels.Purpose,
+		Reason:       labels.Reason,
+		Message:      message,
+		Generation:   generation,
+		Duration:     duration,
+	}
+	if recorded, ok := c.Get(auditRequestKey); ok {
+		e.Request = recorded.(json.RawMessage)
+	}
+	if resp != nil {
+		e.Outcome, e.Decision = audit.OutcomeFailure, audit.DecisionDeny
+		if resp.Decision {
+			e.Outcome, e.Decision = audit.OutcomeSuccess, audit.DecisionAllow
+		}
+		if kind, fp := overrideOf(resp); kind != "" {
+			e.Kind = audit.KindOverride
+			e.Fingerprint = fp
+		}
+	}
+	exporter.Record(e)
+}
+
+// auditAdmin records the admin request answered by c with exporter.
+func auditAdmin(exporter *audit.Exporter, c *gin.Context, start time.Time) {
+	if exporter == nil {
+		return
+	}
+	outcome := audit.OutcomeSuccess
+	if c.Writer.Status() >= http.StatusBadRequest {
+		outcome = audit.OutcomeFailure
+	}
+	exporter.Record(audit.Event{
+		Time:      start,
+		Kind:      audit.KindAdmin,
+		Outcome:   outcome,
+		RequestID: RequestID(c),
+		ClientIP:  c.ClientIP(),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Status:    c.Writer.Status(),
+		Duration:  time.Since(start),
+	})
+}
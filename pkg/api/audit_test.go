@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/audit"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// auditFile returns an exporter writing ECS records to a file, and a function
+// returning the first n records written to it. With includeRequests, decision
+// events carry the request.
+func auditFile(t *testing.T, includeRequests bool) (*audit.Exporter, func(n int) []map[string]interface{}) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	exporter, err := audit.New(audit.Options{Target: path, Format: audit.FormatECS, IncludeRequests: includeRequests}, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	exporter.Start(ctx)
+
+	return exporter, func(n int) []map[string]interface{} {
+		t.Helper()
+		var lines []string
+		require.Eventually(t, func() bool {
+			data, _ := os.ReadFile(path)
+			lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+			return len(lines) >= n && lines[0] != ""
+		}, time.Second, 10*time.Millisecond)
+		records := make([]map[string]interface{}, n)
+		for i := range records {
+			require.NoError(t, json.Unmarshal([]byte(lines[i]), &records[i]))
+		}
+		return records
+	}
+}
+
+func TestAuthZENDecisionHandler_Audit(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "wallet.example.com")
+	require.NoError(t, err)
+
+	exporter, records := auditFile(t, false)
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.Audit = exporter
+	serverCtx.Unlock()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(
+		`{"subject":{"type":"key","id":"wallet"},"resource":{"type":"x5c","id":"wallet","key":["`+leaf.Base64()+`"]},"action":{"name":"wallet-provider"}}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	record := records(1)[0]
+	event := record["event"].(map[string]interface{})
+	assert.Equal(t, audit.KindDecision, event["action"])
+	assert.Equal(t, audit.OutcomeFailure, event["outcome"])
+	assert.Equal(t, ReasonUnknownAuthority, event["reason"])
+	assert.Equal(t, "wallet", record["user"].(map[string]interface{})["name"])
+	goTrust := record["go_trust"].(map[string]interface{})
+	assert.Equal(t, audit.DecisionDeny, goTrust["decision"])
+	assert.Equal(t, "wallet-provider", goTrust["action"])
+	assert.NotContains(t, goTrust, "request")
+}
+
+func TestAuthZENDecisionHandler_AuditRequest(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "wallet.example.com")
+	require.NoError(t, err)
+
+	exporter, records := auditFile(t, true)
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.Audit = exporter
+	serverCtx.Unlock()
+
+	req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(
+		`{"subject":{"type":"key","id":"wallet"},"resource":{"type":"x5c","id":"wallet","key":["`+leaf.Base64()+`"]}}`))
+	req.Header.Set(TenantHeader, "acme")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The request is recorded with the tenant of the header, to be replayed
+	goTrust := records(1)[0]["go_trust"].(map[string]interface{})
+	request := goTrust["request"].(map[string]interface{})
+	assert.Equal(t, []interface{}{leaf.Base64()}, request["resource"].(map[string]interface{})["key"])
+	assert.Equal(t, "acme", request["context"].(map[string]interface{})[ContextKeyTenant])
+}
+
+func TestAdminAuthMiddleware_Audit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter, records := auditFile(t, false)
+	_, serverCtx := setupTestServer()
+	serverCtx.Audit = exporter
+	r := gin.New()
+	r.POST("/admin/refresh", AdminAuthMiddleware(serverCtx, "s3cret"), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/refresh", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodPost, "/admin/refresh", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := records(2)
+	for i, want := range []struct {
+		outcome string
+		status  float64
+	}{{audit.OutcomeFailure, http.StatusUnauthorized}, {audit.OutcomeSuccess, http.StatusAccepted}} {
+		event := got[i]["event"].(map[string]interface{})
+		assert.Equal(t, audit.KindAdmin, event["action"])
+		assert.Equal(t, want.outcome, event["outcome"])
+		assert.Equal(t, "/admin/refresh", got[i]["url"].(map[string]interface{})["path"])
+		response := got[i]["http"].(map[string]interface{})["response"].(map[string]interface{})
+		assert.Equal(t, want.status, response["status_code"])
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// CertificatesResponse is the response of GET /certificates.
+type CertificatesResponse struct {
+	Count          int           `json:"count"`                     // Number of certificates in the pool
+	PoolGeneration uint64        `json:"pool_generation,omitempty"` // Generation of the pool (see ServerContext.InstallContext)
+	Offset         int           `json:"offset,omitempty"`
+	Limit          int           `json:"limit,omitempty"`
+	Certificates   []CertSummary `json:"certificates"` // Pool certificates with their provenance
+}
+
+// CertificatesHandler godoc
+// @Summary List trusted certificates
+// @Description Returns the certificates of the active certificate pool, ordered by subject, each with
+// @Description its provenance: the source URL and territory of the TSL it is listed in and the
+// @Description provider, service name, service type and status of the trust service it belongs to.
+// @Description A certificate listed under several services has one provenance entry for each.
+// @Description
+// @Description The X-Tenant header selects the certificate pool of a tenant with its own pipeline.
+// @Tags TSLs
+// @Produce json
+// @Param X-Tenant header string false "Tenant whose certificate pool is listed"
+// @Param offset query int false "Number of certificates to skip"
+// @Param limit query int false "Maximum number of certificates to return"
+// @Success 200 {object} CertificatesResponse
+// @Failure 400 {object} map[string]string "Invalid offset or limit"
+// @Router /certificates [get]
+func CertificatesHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params, err := parseListParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		serverCtx.RLock()
+		pipelineCtx := serverCtx.TenantPipelineContext(c.GetHeader(TenantHeader))
+		serverCtx.RUnlock()
+
+		certs := pipelineCtx.PoolCertificates()
+		response := CertificatesResponse{
+			Count:        len(certs),
+			Certificates: make([]CertSummary, 0, len(certs)),
+		}
+		if pipelineCtx != nil {
+			response.PoolGeneration = pipelineCtx.Generation
+		}
+		if params.paginated() {
+			response.Offset = params.Offset
+			response.Limit = params.Limit
+		}
+		for _, entry := range page(certs, params) {
+			response.Certificates = append(response.Certificates, summarizePoolCert(entry))
+		}
+
+		serverCtx.Logger.Debug("API /certificates request",
+			logging.F("remote_ip", c.ClientIP()),
+			logging.F("tenant", c.GetHeader(TenantHeader)),
+			logging.F("certificate_count", len(certs)))
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// CertificateHandler godoc
+// @Summary Get a trusted certificate
+// @Description Returns a certificate of the active certificate pool, identified by the hex SHA-256
+// @Description fingerprint of its DER encoding, with the TSL services it was selected from.
+// @Tags TSLs
+// @Produce json
+// @Param sha256 path string true "Hex SHA-256 fingerprint of the certificate"
+// @Param X-Tenant header string false "Tenant whose certificate pool is searched"
+// @Success 200 {object} CertSummary
+// @Failure 404 {object} map[string]string "Certificate not in the pool"
+// @Router /certificates/{sha256} [get]
+func CertificateHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverCtx.RLock()
+		pipelineCtx := serverCtx.TenantPipelineContext(c.GetHeader(TenantHeader))
+		serverCtx.RUnlock()
+
+		entry := pipelineCtx.PoolCertificate(strings.ToLower(c.Param("sha256")))
+		if entry == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "certificate not in the certificate pool"})
+			return
+		}
+		c.JSON(http.StatusOK, summarizePoolCert(entry))
+	}
+}
+
+// summarizePoolCert returns the CertSummary of a pool certificate, including
+// its provenance.
+func summarizePoolCert(entry *pipeline.PoolCertificate) CertSummary {
+	summary := summarizeCert(entry.Certificate)
+	summary.Provenance = pipeline.LinkProviderPages(entry.Provenance, entry.Fingerprint)
+	return summary
+}
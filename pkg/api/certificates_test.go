@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selectedContext returns a pipeline Context whose certificate pool was built
+// by the select step from a TSL listing the given CAs under one service.
+func selectedContext(t *testing.T, cas ...*testutil.Cert) *pipeline.Context {
+	t.Helper()
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().WithTerritory("SE").WithSource("https://se.example/tsl.xml").
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("Test CA Service").WithCert(cas...))).
+		Build())
+	ctx, err := pipeline.SelectCertPool(nil, ctx)
+	require.NoError(t, err)
+	return ctx
+}
+
+func getJSON(t *testing.T, r http.Handler, path string, v any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+	return w.Code
+}
+
+func TestCertificatesEndpoint(t *testing.T) {
+	a, err := testutil.NewCA("A CA")
+	require.NoError(t, err)
+	b, err := testutil.NewCA("B CA")
+	require.NoError(t, err)
+
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.InstallContext("", selectedContext(t, b, a))
+	serverCtx.Unlock()
+
+	var response CertificatesResponse
+	require.Equal(t, http.StatusOK, getJSON(t, r, "/certificates", &response))
+	assert.Equal(t, 2, response.Count)
+	assert.Equal(t, uint64(1), response.PoolGeneration)
+	require.Len(t, response.Certificates, 2)
+
+	first := response.Certificates[0]
+	assert.Equal(t, "CN=A CA", first.Subject)
+	assert.Equal(t, pipeline.Fingerprint(a.Certificate), first.SHA256)
+	require.Len(t, first.Provenance, 1)
+	assert.Equal(t, pipeline.CertProvenance{
+		Source:      "https://se.example/tsl.xml",
+		Territory:   "SE",
+		Provider:    "Test TSP",
+		Service:     "Test CA Service",
+		ServiceType: pltesting.DefaultServiceType,
+		Status:      etsi119612.ServiceStatusGranted,
+	}, first.Provenance[0])
+
+	// Pagination
+	response = CertificatesResponse{}
+	require.Equal(t, http.StatusOK, getJSON(t, r, "/certificates?offset=1&limit=1", &response))
+	assert.Equal(t, 2, response.Count)
+	require.Len(t, response.Certificates, 1)
+	assert.Equal(t, "CN=B CA", response.Certificates[0].Subject)
+
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, r, "/certificates?limit=-1", &response))
+}
+
+func TestCertificateEndpoint(t *testing.T) {
+	ca, err := testutil.NewCA("Lookup CA")
+	require.NoError(t, err)
+	other, err := testutil.NewCA("Other CA")
+	require.NoError(t, err)
+
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.PipelineContext = selectedContext(t, ca)
+	serverCtx.Unlock()
+
+	var summary CertSummary
+	require.Equal(t, http.StatusOK, getJSON(t, r, "/certificates/"+pipeline.Fingerprint(ca.Certificate), &summary))
+	assert.Equal(t, "CN=Lookup CA", summary.Subject)
+	require.Len(t, summary.Provenance, 1)
+	assert.Equal(t, "https://se.example/tsl.xml", summary.Provenance[0].Source)
+
+	assert.Equal(t, http.StatusNotFound, getJSON(t, r, "/certificates/"+pipeline.Fingerprint(other.Certificate), &summary))
+}
+
+func TestCertificatesEndpoint_NoPool(t *testing.T) {
+	r, _ := setupTestServer()
+
+	var body map[string]any
+	require.Equal(t, http.StatusOK, getJSON(t, r, "/certificates", &body))
+	assert.Equal(t, float64(0), body["count"])
+	assert.Equal(t, []any{}, body["certificates"])
+}
+
+func TestCertificateEndpoint_ProviderPage(t *testing.T) {
+	ca, err := testutil.NewCA("A CA")
+	require.NoError(t, err)
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.InstallContext("", selectedContext(t, ca))
+	serverCtx.Unlock()
+	fingerprint := pipeline.Fingerprint(ca.Certificate)
+
+	var summary CertSummary
+	require.Equal(t, http.StatusOK, getJSON(t, r, "/certificates/"+fingerprint, &summary))
+	require.Len(t, summary.Provenance, 1)
+	assert.Empty(t, summary.Provenance[0].ProviderPage, "no links without a provider pages URL")
+
+	pipeline.SetProviderPagesURL("/published")
+	defer pipeline.SetProviderPagesURL("")
+	require.Equal(t, http.StatusOK, getJSON(t, r, "/certificates/"+fingerprint, &summary))
+	assert.Equal(t, "/published/providers/se/test-tsp.html#cert-"+fingerprint, summary.Provenance[0].ProviderPage)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/config"
+)
+
+// Client binding modes.
+const (
+	BindLeaf  = "leaf"  // The leaf certificate of resource.key is the TLS client certificate
+	BindChain = "chain" // The leaf certificate of resource.key is issued by the CA of the TLS client certificate
+)
+
+// BindAnyAction is the action name of a ClientBinding applying to the
+// actions without one of their own.
+const BindAnyAction = "*"
+
+// ClientBinding binds AuthZEN requests of some actions to the identity of
+// the caller: the key they ask about must be the TLS client certificate the
+// caller authenticated with, or one issued by the same CA. A caller can then
+// only have its own key evaluated, not that of another party.
+type ClientBinding struct {
+	modes map[string]string // BindLeaf or BindChain by action name or BindAnyAction
+}
+
+// NewClientBinding creates a ClientBinding with the binding modes of
+// actions, BindLeaf or BindChain by action name or BindAnyAction. It returns
+// nil if modes is empty, which binds no request.
+func NewClientBinding(modes map[string]string) (*ClientBinding, error) {
+	if len(modes) == 0 {
+		return nil, nil
+	}
+	b := &ClientBinding{modes: make(map[string]string, len(modes))}
+	for action, mode := range modes {
+		if mode != BindLeaf && mode != BindChain {
+			return nil, fmt.Errorf("invalid client binding %q of action %q: must be %s or %s", mode, action, BindLeaf, BindChain)
+		}
+		b.modes[action] = mode
+	}
+	return b, nil
+}
+
+// Check returns an error if a request for action with the resource.key
+// certificates certs, leaf first, is not bound to the TLS client certificate
+// of state as required for the action. Errors mention the client binding
+// policy, so that the denial is reported as a policy mismatch. A nil
+// ClientBinding binds no request.
+func (b *ClientBinding) Check(state *tls.ConnectionState, action string, certs []*x509.Certificate) error {
+	if b == nil {
+		return nil
+	}
+	mode, ok := b.modes[action]
+	if !ok {
+		if mode, ok = b.modes[BindAnyAction]; !ok {
+			return nil
+		}
+	}
+
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return errors.New("no TLS client certificate presented, as required by the client binding policy")
+	}
+	if len(certs) == 0 {
+		return errors.New("the client binding policy requires resource.key to hold a certificate")
+	}
+	client, leaf := state.PeerCertificates[0], certs[0]
+	switch mode {
+	case BindLeaf:
+		if !leaf.Equal(client) {
+			return errors.New("leaf certificate is not the TLS client certificate, as required by the client binding policy")
+		}
+	case BindChain:
+		if !bytes.Equal(leaf.RawIssuer, client.RawIssuer) || !bytes.Equal(leaf.AuthorityKeyId, client.AuthorityKeyId) {
+			return errors.New("leaf certificate is not issued by the CA of the TLS client certificate, as required by the client binding policy")
+		}
+	}
+	return nil
+}
+
+// NewTLSConfig returns the TLS configuration of the API server, or nil if
+// cfg has no certificate file, in which case plain HTTP is served. With a
+// client CA file, clients must present a certificate issued by one of its
+// CAs, unless client_auth is optional, in which case they may present none.
+func NewTLSConfig(cfg config.ServerTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if strings.EqualFold(cfg.ClientAuth, "optional") {
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
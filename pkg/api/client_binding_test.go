@@ -0,0 +1,140 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientBinding_Check(t *testing.T) {
+	ca, err := testutil.NewCA("Client CA")
+	require.NoError(t, err)
+	client, err := testutil.NewLeaf(ca, "rp.example.com")
+	require.NoError(t, err)
+	sibling, err := testutil.NewLeaf(ca, "signing.rp.example.com")
+	require.NoError(t, err)
+	otherCA, err := testutil.NewCA("Other CA")
+	require.NoError(t, err)
+	other, err := testutil.NewLeaf(otherCA, "rp.example.com")
+	require.NoError(t, err)
+
+	b, err := NewClientBinding(map[string]string{"wallet-provider": BindLeaf, BindAnyAction: BindChain})
+	require.NoError(t, err)
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client.Certificate, ca.Certificate}}
+
+	assert.NoError(t, b.Check(state, "wallet-provider", client.Chain()))
+	assert.ErrorContains(t, b.Check(state, "wallet-provider", sibling.Chain()), "is not the TLS client certificate")
+	assert.NoError(t, b.Check(state, "issuer", sibling.Chain()), "issued by the same CA")
+	assert.ErrorContains(t, b.Check(state, "issuer", other.Chain()), "not issued by the CA of the TLS client certificate")
+	assert.ErrorContains(t, b.Check(nil, "issuer", client.Chain()), "no TLS client certificate")
+	assert.ErrorContains(t, b.Check(&tls.ConnectionState{}, "", client.Chain()), "no TLS client certificate")
+	assert.ErrorContains(t, b.Check(state, "", nil), "requires resource.key to hold a certificate")
+
+	// Errors are reported as policy mismatches
+	assert.Equal(t, ReasonPolicyMismatch, reasonCodeFor(b.Check(state, "wallet-provider", sibling.Chain()).Error()))
+
+	// Actions without a binding are not bound
+	b, err = NewClientBinding(map[string]string{"wallet-provider": BindLeaf})
+	require.NoError(t, err)
+	assert.NoError(t, b.Check(nil, "issuer", other.Chain()))
+
+	var none *ClientBinding
+	assert.NoError(t, none.Check(nil, "wallet-provider", nil))
+}
+
+func TestNewClientBinding(t *testing.T) {
+	b, err := NewClientBinding(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	_, err = NewClientBinding(map[string]string{"wallet-provider": "subject"})
+	assert.ErrorContains(t, err, `invalid client binding "subject"`)
+}
+
+func TestAuthZENDecisionHandler_ClientBinding(t *testing.T) {
+	ca, err := testutil.NewCA("Client CA")
+	require.NoError(t, err)
+	client, err := testutil.NewLeaf(ca, "rp.example.com")
+	require.NoError(t, err)
+	other, err := testutil.NewLeaf(ca, "other.example.com")
+	require.NoError(t, err)
+
+	// Both leaves are allow-listed, so only the binding denies
+	allow, err := NewOverrides(nil, []string{pipeline.Fingerprint(client.Certificate), pipeline.Fingerprint(other.Certificate)}, "")
+	require.NoError(t, err)
+	binding, err := NewClientBinding(map[string]string{"wallet-provider": BindLeaf})
+	require.NoError(t, err)
+
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.Overrides = allow
+	serverCtx.ClientBinding = binding
+	serverCtx.Unlock()
+
+	evaluate := func(leaf *testutil.Cert, action string) string {
+		body := `{"subject":{"type":"key","id":"rp"},"resource":{"type":"x5c","id":"rp","key":["` + leaf.Base64() + `"]}`
+		if action != "" {
+			body += `,"action":{"name":"` + action + `"}`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body+"}"))
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client.Certificate}}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	assert.Contains(t, evaluate(client, "wallet-provider"), `"decision":true`)
+	resp := evaluate(other, "wallet-provider")
+	assert.Contains(t, resp, `"decision":false`)
+	assert.Contains(t, resp, "client binding policy")
+	assert.Contains(t, evaluate(other, ""), `"decision":true`, "action not bound")
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	tlsConfig, err := NewTLSConfig(config.ServerTLSConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, tlsConfig, "plain HTTP")
+
+	ca, err := testutil.NewCA("Server CA")
+	require.NoError(t, err)
+	server, err := testutil.NewLeaf(ca, "pdp.example.com")
+	require.NoError(t, err)
+	keyPEM, err := server.KeyPEM()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(certFile, server.CertPEM(), 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	require.NoError(t, os.WriteFile(caFile, ca.CertPEM(), 0600))
+
+	tlsConfig, err = NewTLSConfig(config.ServerTLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+
+	tlsConfig, err = NewTLSConfig(config.ServerTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	assert.NotNil(t, tlsConfig.ClientCAs)
+
+	tlsConfig, err = NewTLSConfig(config.ServerTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: "optional"})
+	require.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+
+	_, err = NewTLSConfig(config.ServerTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile})
+	assert.ErrorContains(t, err, "no certificates found in client CA file")
+	_, err = NewTLSConfig(config.ServerTLSConfig{CertFile: certFile, KeyFile: certFile})
+	assert.ErrorContains(t, err, "failed to load server certificate")
+}
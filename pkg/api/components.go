@@ -0,0 +1,261 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// Kinds of subsystems reported by GET /status/components.
+const (
+	ComponentPipeline     = "pipeline"     // Background pipeline updater
+	ComponentSigner       = "signer"       // Signing publish steps
+	ComponentRegistry     = "registry"     // Trust registries
+	ComponentCache        = "cache"        // Caches, such as the OpenID Federation trust chain cache
+	ComponentStorage      = "storage"      // Audit and analytics outputs
+	ComponentConnectivity = "connectivity" // Upstream TSL sources and outbound webhooks
+)
+
+// Health states of a component, from best to worst.
+const (
+	HealthOK       = "ok"       // Working
+	HealthUnknown  = "unknown"  // Not exercised yet
+	HealthDegraded = "degraded" // Partly failing, or serving older data
+	HealthDown     = "down"     // Failing
+)
+
+// healthRank orders the health states from best to worst.
+var healthRank = map[string]int{HealthOK: 0, HealthUnknown: 1, HealthDegraded: 2, HealthDown: 3}
+
+// ComponentStatus is the health of a subsystem, reported by GET
+// /status/components.
+type ComponentStatus struct {
+	Component   string     `json:"component"`               // Kind of subsystem, such as ComponentPipeline
+	Name        string     `json:"name"`                    // Name of the subsystem within its kind
+	Target      string     `json:"target,omitempty"`        // What the subsystem connects or writes to
+	Status      string     `json:"status"`                  // HealthOK, HealthUnknown, HealthDegraded or HealthDown
+	Message     string     `json:"message,omitempty"`       // Summary of the state
+	LastSuccess *time.Time `json:"last_success,omitempty"`  // When the subsystem last worked
+	LastError   string     `json:"last_error,omitempty"`    // Most recent error
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"` // When the most recent error occurred
+}
+
+// ComponentCheck reports the health of a subsystem that the ServerContext
+// does not know about, such as the cache of a registry. It is called for
+// every GET /status/components request and must be safe for concurrent use.
+type ComponentCheck func() ComponentStatus
+
+// ComponentsResponse is the response of GET /status/components.
+type ComponentsResponse struct {
+	Status     string            `json:"status"` // Worst health of the components
+	Timestamp  time.Time         `json:"timestamp"`
+	Components []ComponentStatus `json:"components"`
+}
+
+// setOutcome sets the last success and error of status and derives its
+// health: unknown without either, down if the error is the more recent,
+// ok otherwise.
+func setOutcome(status *ComponentStatus, lastSuccess time.Time, lastError string, lastErrorAt time.Time) {
+	if !lastSuccess.IsZero() {
+		t := lastSuccess.UTC()
+		status.LastSuccess = &t
+	}
+	if lastError != "" {
+		t := lastErrorAt.UTC()
+		status.LastError, status.LastErrorAt = lastError, &t
+	}
+	switch {
+	case lastSuccess.IsZero() && lastError == "":
+		status.Status = HealthUnknown
+	case lastError != "" && lastErrorAt.After(lastSuccess):
+		status.Status = HealthDown
+	default:
+		status.Status = HealthOK
+	}
+}
+
+// Components returns the health of the subsystems of the server: the
+// pipeline updater, the signing publish steps of the default pipeline, the
+// registries of the RegistryManager, the audit exporter and analytics rollup,
+// the upstream TSL sources and the deny webhook, as far as they are
+// configured, followed by those of ComponentChecks.
+func (s *ServerContext) Components() []ComponentStatus {
+	s.RLock()
+	components := []ComponentStatus{pipelineComponent(s.RunHistory, s.LastProcessed)}
+	for _, u := range s.updaters {
+		if u.tenant == "" {
+			if signer, ok := signerComponent(u.pl, s.RunHistory); ok {
+				components = append(components, signer)
+			}
+			break
+		}
+	}
+	sources := sourcesComponent(s.SourceStatuses())
+	manager, audit, analytics, webhook := s.RegistryManager, s.Audit, s.Analytics, s.DenyWebhook
+	checks := s.ComponentChecks
+	s.RUnlock()
+
+	if manager != nil {
+		for _, reg := range manager.Health() {
+			status := ComponentStatus{Component: ComponentRegistry, Name: reg.Info.Name, Target: reg.Info.Type, Status: HealthOK, Message: reg.Info.Description}
+			if !reg.Healthy {
+				status.Status = HealthDown
+			}
+			components = append(components, status)
+		}
+	}
+	if audit != nil {
+		health := audit.Health()
+		status := ComponentStatus{Component: ComponentStorage, Name: "audit", Target: health.Target}
+		setOutcome(&status, health.LastSuccess, health.LastError, health.LastErrorAt)
+		if status.Status == HealthUnknown {
+			status.Message = "No audit events written yet"
+		}
+		components = append(components, status)
+	}
+	if analytics != nil {
+		health := analytics.Health()
+		status := ComponentStatus{Component: ComponentStorage, Name: "analytics", Target: health.Dir}
+		setOutcome(&status, health.LastSuccess, health.LastError, health.LastErrorAt)
+		if status.Status == HealthUnknown {
+			status.Message = "No decision counts written yet"
+		}
+		components = append(components, status)
+	}
+	components = append(components, sources)
+	if webhook != nil {
+		components = append(components, webhook.componentStatus())
+	}
+	for _, check := range checks {
+		components = append(components, check())
+	}
+	return components
+}
+
+// pipelineComponent returns the health of the pipeline updater from the run
+// history: down if the last run failed and no trust data was ever
+// installed, degraded if it failed but the data of an earlier run is still
+// served.
+func pipelineComponent(runs []*pipeline.RunReport, lastProcessed time.Time) ComponentStatus {
+	status := ComponentStatus{Component: ComponentPipeline, Name: "updater"}
+	var lastError string
+	var lastErrorAt time.Time
+	for _, run := range runs {
+		if !run.Succeeded() {
+			lastError, lastErrorAt = run.Error, run.Start.Add(run.Duration)
+			break
+		}
+	}
+	setOutcome(&status, lastProcessed, lastError, lastErrorAt)
+	switch {
+	case status.Status == HealthUnknown:
+		status.Message = "No pipeline runs yet"
+	case status.Status == HealthDown && !lastProcessed.IsZero():
+		status.Status = HealthDegraded
+		status.Message = "The last run failed; serving the trust data of an earlier run"
+	case status.Status == HealthDown:
+		status.Message = "No run has succeeded yet"
+	}
+	return status
+}
+
+// signerComponent returns the health of the signing publish steps of pl
+// from the run history: the outcome of the most recent of these steps. It
+// returns false if pl does not sign.
+func signerComponent(pl *pipeline.Pipeline, runs []*pipeline.RunReport) (ComponentStatus, bool) {
+	steps := pl.SigningSteps()
+	if len(steps) == 0 {
+		return ComponentStatus{}, false
+	}
+	status := ComponentStatus{Component: ComponentSigner, Name: strings.Join(pl.SigningBackends(), ", ")}
+	var lastSuccess, lastErrorAt time.Time
+	var lastError string
+	for _, run := range runs {
+		for _, step := range slices.Backward(run.Steps) {
+			if _, ok := steps[step.Index]; !ok {
+				continue
+			}
+			end := step.Start.Add(step.Duration)
+			switch {
+			case step.Error != "" && lastError == "":
+				lastError, lastErrorAt = step.Error, end
+			case step.Error == "" && lastSuccess.IsZero():
+				lastSuccess = end
+			}
+		}
+	}
+	setOutcome(&status, lastSuccess, lastError, lastErrorAt)
+	if status.Status == HealthUnknown {
+		status.Message = "No signing publish step has run yet"
+	}
+	return status, true
+}
+
+// sourcesComponent returns the health of the upstream TSL sources: degraded
+// if some are stale, down if all are.
+func sourcesComponent(sources []SourceStatus) ComponentStatus {
+	status := ComponentStatus{Component: ComponentConnectivity, Name: "tsl sources", Status: HealthUnknown, Message: "No TSL sources fetched yet"}
+	if len(sources) == 0 {
+		return status
+	}
+	var lastSuccess, lastErrorAt time.Time
+	var lastError string
+	stale := 0
+	for _, source := range sources {
+		if source.Stale {
+			stale++
+		}
+		if source.LastSuccess != nil && source.LastSuccess.After(lastSuccess) {
+			lastSuccess = *source.LastSuccess
+		}
+		if source.Error != "" && source.LastFetch.After(lastErrorAt) {
+			lastError, lastErrorAt = source.URL+": "+source.Error, source.LastFetch
+		}
+	}
+	setOutcome(&status, lastSuccess, lastError, lastErrorAt)
+	status.Message = fmt.Sprintf("%d of %d sources stale", stale, len(sources))
+	switch {
+	case stale == len(sources):
+		status.Status = HealthDown
+	case stale > 0:
+		status.Status = HealthDegraded
+	default:
+		status.Status = HealthOK
+	}
+	return status
+}
+
+// ComponentsHandler godoc
+// @Summary Health of the subsystems
+// @Description Returns the health of each subsystem of the server: the pipeline updater, the signing
+// @Description publish steps, the trust registries, caches, the audit and analytics outputs, the
+// @Description upstream TSL sources and the deny webhook, as far as they are configured, with the
+// @Description time of their last success and their last error
+// @Description
+// @Description Each component is ok, unknown (not exercised yet), degraded or down; status is the
+// @Description worst of them. Unlike GET /readyz, the response is always 200, for display rather
+// @Description than routing decisions.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} ComponentsResponse
+// @Router /status/components [get]
+func ComponentsHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		components := serverCtx.Components()
+		overall := HealthOK
+		for _, component := range components {
+			if healthRank[component.Status] > healthRank[overall] {
+				overall = component.Status
+			}
+		}
+		c.JSON(200, ComponentsResponse{
+			Status:     overall,
+			Timestamp:  time.Now(),
+			Components: components,
+		})
+	}
+}
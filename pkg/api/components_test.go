@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/registry"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// healthRegistry is a TrustRegistry with a fixed health.
+type healthRegistry struct {
+	name    string
+	healthy bool
+}
+
+func (r healthRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRequest) (*authzen.EvaluationResponse, error) {
+	return &authzen.EvaluationResponse{Decision: false}, nil
+}
+
+func (r healthRegistry) SupportedResourceTypes() []string { return []string{"x5c"} }
+func (r healthRegistry) Info() registry.RegistryInfo {
+	return registry.RegistryInfo{Name: r.name, Type: "mock"}
+}
+func (r healthRegistry) Healthy() bool                     { return r.healthy }
+func (r healthRegistry) Refresh(ctx context.Context) error { return nil }
+
+// getComponents requests GET /status/components and decodes the response.
+func getComponents(t *testing.T, serverCtx *ServerContext) ComponentsResponse {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/status/components", ComponentsHandler(serverCtx))
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/status/components", nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp ComponentsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+// findComponent returns the component with name, failing if there is none.
+func findComponent(t *testing.T, components []ComponentStatus, name string) ComponentStatus {
+	t.Helper()
+	for _, c := range components {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no component %q in %+v", name, components)
+	return ComponentStatus{}
+}
+
+func TestComponentsEndpoint_NoRuns(t *testing.T) {
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	resp := getComponents(t, serverCtx)
+
+	assert.Equal(t, HealthUnknown, resp.Status)
+	require.Len(t, resp.Components, 2)
+	updater := findComponent(t, resp.Components, "updater")
+	assert.Equal(t, ComponentPipeline, updater.Component)
+	assert.Equal(t, HealthUnknown, updater.Status)
+	assert.Nil(t, updater.LastSuccess)
+	sources := findComponent(t, resp.Components, "tsl sources")
+	assert.Equal(t, HealthUnknown, sources.Status)
+}
+
+func TestComponentsEndpoint_Pipeline(t *testing.T) {
+	now := time.Now()
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	serverCtx.RecordRun(&pipeline.RunReport{Start: now.Add(-time.Minute), Duration: time.Second})
+	serverCtx.LastProcessed = now.Add(-time.Minute)
+
+	updater := findComponent(t, getComponents(t, serverCtx).Components, "updater")
+	assert.Equal(t, HealthOK, updater.Status)
+	require.NotNil(t, updater.LastSuccess)
+	assert.Empty(t, updater.LastError)
+
+	serverCtx.RecordRun(&pipeline.RunReport{Start: now, Duration: time.Second, Error: "fetch failed"})
+	resp := getComponents(t, serverCtx)
+	updater = findComponent(t, resp.Components, "updater")
+	assert.Equal(t, HealthDegraded, updater.Status, "the trust data of the earlier run is still served")
+	assert.Equal(t, "fetch failed", updater.LastError)
+	require.NotNil(t, updater.LastErrorAt)
+	assert.Equal(t, HealthDegraded, resp.Status)
+
+	serverCtx.LastProcessed = time.Time{}
+	updater = findComponent(t, getComponents(t, serverCtx).Components, "updater")
+	assert.Equal(t, HealthDown, updater.Status)
+}
+
+func TestComponentsEndpoint_Sources(t *testing.T) {
+	now := time.Now()
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	serverCtx.RecordSources(&pipeline.RunReport{Sources: []pipeline.SourceFetch{
+		{URL: "https://a.example.com/tsl.xml", Time: now, StatusCode: 200},
+		{URL: "https://b.example.com/tsl.xml", Time: now, StatusCode: 503, Error: "unexpected status"},
+	}})
+
+	sources := findComponent(t, getComponents(t, serverCtx).Components, "tsl sources")
+	assert.Equal(t, HealthDegraded, sources.Status)
+	assert.Equal(t, "1 of 2 sources stale", sources.Message)
+	assert.Equal(t, "https://b.example.com/tsl.xml: unexpected status", sources.LastError)
+	require.NotNil(t, sources.LastSuccess)
+
+	serverCtx.RecordSources(&pipeline.RunReport{})
+	sources = findComponent(t, getComponents(t, serverCtx).Components, "tsl sources")
+	assert.Equal(t, HealthDown, sources.Status)
+	assert.Equal(t, "2 of 2 sources stale", sources.Message)
+}
+
+func TestComponentsEndpoint_Signer(t *testing.T) {
+	now := time.Now()
+	pl := &pipeline.Pipeline{
+		Pipes: []pipeline.Pipe{
+			{MethodName: "load", MethodArguments: []string{"https://example.com/tsl.xml"}},
+			{MethodName: "publish", MethodArguments: []string{"/tmp/out", "cert.pem", "key.pem"}},
+		},
+		Logger: logging.DefaultLogger(),
+	}
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	serverCtx.updaters = []*BackgroundUpdater{{pl: pl}}
+
+	signer := findComponent(t, getComponents(t, serverCtx).Components, pipeline.SigningBackendFile)
+	assert.Equal(t, ComponentSigner, signer.Component)
+	assert.Equal(t, HealthUnknown, signer.Status)
+
+	serverCtx.RecordRun(&pipeline.RunReport{Start: now.Add(-time.Minute), Steps: []pipeline.StepReport{
+		{Index: 0, Name: "load", Start: now.Add(-time.Minute)},
+		{Index: 1, Name: "publish", Start: now.Add(-time.Minute), Duration: time.Second},
+	}})
+	serverCtx.RecordRun(&pipeline.RunReport{Start: now, Error: "publish failed", Steps: []pipeline.StepReport{
+		{Index: 0, Name: "load", Start: now, Error: "ignored"},
+		{Index: 1, Name: "publish", Start: now, Duration: time.Second, Error: "failed to sign"},
+	}})
+
+	signer = findComponent(t, getComponents(t, serverCtx).Components, pipeline.SigningBackendFile)
+	assert.Equal(t, HealthDown, signer.Status)
+	assert.Equal(t, "failed to sign", signer.LastError)
+	require.NotNil(t, signer.LastSuccess)
+	assert.True(t, signer.LastSuccess.Before(*signer.LastErrorAt))
+}
+
+func TestComponentsEndpoint_Registries(t *testing.T) {
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	mgr := registry.NewRegistryManager(registry.FirstMatch, time.Second)
+	mgr.Register(healthRegistry{name: "up", healthy: true})
+	mgr.Register(healthRegistry{name: "broken"})
+	serverCtx.RegistryManager = mgr
+
+	resp := getComponents(t, serverCtx)
+	assert.Equal(t, HealthDown, resp.Status)
+	up := findComponent(t, resp.Components, "up")
+	assert.Equal(t, ComponentRegistry, up.Component)
+	assert.Equal(t, HealthOK, up.Status)
+	assert.Equal(t, HealthDown, findComponent(t, resp.Components, "broken").Status)
+}
+
+func TestComponentsEndpoint_WebhookAndChecks(t *testing.T) {
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	webhook, err := NewDenyWebhook(DenyWebhookOptions{URL: "https://siem.example.com/denials"}, logging.DefaultLogger())
+	require.NoError(t, err)
+	serverCtx.DenyWebhook = webhook
+	serverCtx.ComponentChecks = []ComponentCheck{func() ComponentStatus {
+		return ComponentStatus{Component: ComponentCache, Name: "test cache", Status: HealthOK}
+	}}
+
+	components := getComponents(t, serverCtx).Components
+	status := findComponent(t, components, "deny webhook")
+	assert.Equal(t, ComponentConnectivity, status.Component)
+	assert.Equal(t, "https://siem.example.com/denials", status.Target)
+	assert.Equal(t, HealthUnknown, status.Status)
+	assert.Equal(t, HealthOK, findComponent(t, components, "test cache").Status)
+
+	webhook.recordResult("rejected with status 500")
+	status = findComponent(t, getComponents(t, serverCtx).Components, "deny webhook")
+	assert.Equal(t, HealthDown, status.Status)
+	assert.Equal(t, "rejected with status 500", status.LastError)
+
+	webhook.recordResult("")
+	status = findComponent(t, getComponents(t, serverCtx).Components, "deny webhook")
+	assert.Equal(t, HealthOK, status.Status)
+	assert.Equal(t, "rejected with status 500", status.LastError, "the last error is kept after a success")
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// contextETag computes an entity tag for a representation derived from the current
+// pipeline Context generation. The tag is a hash over the processing timestamp and the
+// identifying fields of every loaded TSL (source, territory, sequence number, issue date
+// and provider count), so it changes whenever the background updater installs a new
+// Context with different content.
+//
+// The resource name is mixed into the hash so that /status and /info, which render
+// different bodies from the same Context, never share an ETag. Any extra values are
+// hashed as well, for representations that include state beyond the Context.
+//
+// The caller must hold at least a read lock on the ServerContext.
+func contextETag(resource string, ctx *pipeline.Context, lastProcessed time.Time, extra ...any) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n", resource, lastProcessed.UnixNano())
+	for _, v := range extra {
+		fmt.Fprintf(h, "%v\n", v)
+	}
+
+	if ctx != nil && ctx.TSLs != nil {
+		fmt.Fprintf(h, "%d\n", ctx.TSLs.Size())
+		for _, tsl := range ctx.TSLs.ToSlice() {
+			if tsl == nil {
+				fmt.Fprint(h, "-\n")
+				continue
+			}
+			fmt.Fprintf(h, "%s|%d\n", tsl.Source, tsl.NumberOfTrustServiceProviders())
+			if si := tsl.StatusList.TslSchemeInformation; si != nil {
+				fmt.Fprintf(h, "%s|%d|%s\n", si.TslSchemeTerritory, si.TSLSequenceNumber, si.ListIssueDateTime)
+			}
+		}
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// checkNotModified sets the ETag and Last-Modified validators on the response and
+// evaluates the conditional request headers against them. It returns true if the
+// client's cached copy is still current, in which case a 304 Not Modified response
+// has already been written and the handler should return without rendering a body.
+//
+// Following RFC 9110 section 13.2.2, If-None-Match takes precedence: If-Modified-Since
+// is only considered when the request carries no If-None-Match header. A zero
+// lastModified (pipeline never processed) suppresses the Last-Modified header.
+func checkNotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+		return false
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err == nil && !lastModified.Truncate(time.Second).After(since) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// etagMatches reports whether an If-None-Match header value matches the given ETag
+// using the weak comparison function. The header may be "*" or a comma-separated
+// list of entity tags.
+func etagMatches(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextETag(t *testing.T) {
+	now := time.Now()
+	ctx := pipeline.NewContext()
+	ctx.TSLs = utils.NewStack[*etsi119612.TSL]()
+	ctx.TSLs.Push(&etsi119612.TSL{Source: "https://example.com/tsl.xml"})
+
+	etag := contextETag("status", ctx, now)
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+	// Stable for the same generation
+	assert.Equal(t, etag, contextETag("status", ctx, now))
+
+	// Differs per resource
+	assert.NotEqual(t, etag, contextETag("info", ctx, now))
+
+	// Differs when the context is reprocessed
+	assert.NotEqual(t, etag, contextETag("status", ctx, now.Add(time.Second)))
+
+	// Differs when the TSL content changes
+	ctx.TSLs.Push(&etsi119612.TSL{Source: "https://example.com/other.xml"})
+	assert.NotEqual(t, etag, contextETag("status", ctx, now))
+
+	// Nil context is handled
+	assert.NotEmpty(t, contextETag("status", nil, time.Time{}))
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc"`
+	assert.True(t, etagMatches(`"abc"`, etag))
+	assert.True(t, etagMatches(`W/"abc"`, etag))
+	assert.True(t, etagMatches(`"xyz", "abc"`, etag))
+	assert.True(t, etagMatches(`*`, etag))
+	assert.False(t, etagMatches(`"xyz"`, etag))
+	assert.False(t, etagMatches(`abc`, etag))
+}
+
+func TestConditionalRequests(t *testing.T) {
+	for _, path := range []string{"/status", "/info"} {
+		t.Run(path, func(t *testing.T) {
+			r, serverCtx := setupTestServer()
+			serverCtx.Lock()
+			serverCtx.LastProcessed = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+			serverCtx.Unlock()
+
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			require.Equal(t, 200, w.Code)
+
+			etag := w.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+			assert.Equal(t, "Thu, 02 Jan 2025 03:04:05 GMT", w.Header().Get("Last-Modified"))
+
+			// Matching If-None-Match returns 304 with no body
+			req, _ = http.NewRequest("GET", path, nil)
+			req.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNotModified, w.Code)
+			assert.Empty(t, w.Body.String())
+			assert.Equal(t, etag, w.Header().Get("ETag"))
+
+			// Stale If-None-Match returns the full body, even with a current If-Modified-Since
+			req, _ = http.NewRequest("GET", path, nil)
+			req.Header.Set("If-None-Match", `"stale"`)
+			req.Header.Set("If-Modified-Since", "Thu, 02 Jan 2025 03:04:05 GMT")
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+
+			// If-Modified-Since at or after LastProcessed returns 304
+			req, _ = http.NewRequest("GET", path, nil)
+			req.Header.Set("If-Modified-Since", "Thu, 02 Jan 2025 03:04:05 GMT")
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusNotModified, w.Code)
+
+			// If-Modified-Since before LastProcessed returns 200
+			req, _ = http.NewRequest("GET", path, nil)
+			req.Header.Set("If-Modified-Since", "Thu, 02 Jan 2025 03:04:04 GMT")
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+
+			// A new pipeline generation invalidates the ETag
+			serverCtx.Lock()
+			serverCtx.LastProcessed = serverCtx.LastProcessed.Add(time.Minute)
+			serverCtx.Unlock()
+			req, _ = http.NewRequest("GET", path, nil)
+			req.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, 200, w.Code)
+			assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/SUNET/go-trust/pkg/utils/x509util"
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyRequest is the body of POST /debug/verify. The chain is given either as
+// concatenated PEM certificates or as base64 DER certificates (x5c), leaf first.
+type VerifyRequest struct {
+	PEM    string   `json:"pem,omitempty"`    // PEM-encoded certificates, leaf first
+	X5C    []string `json:"x5c,omitempty"`    // Base64 DER certificates, leaf first
+	Tenant string   `json:"tenant,omitempty"` // Tenant whose trust configuration is used (optional)
+}
+
+// VerifyReport is the diagnostic result of POST /debug/verify. It is not an
+// AuthZEN decision and must not be used as one.
+type VerifyReport struct {
+	Authoritative bool            `json:"authoritative"`    // Always false
+	Tenant        string          `json:"tenant,omitempty"` // Tenant whose trust configuration was used
+	Trusted       bool            `json:"trusted"`          // Whether the leaf verifies against the active certificate pool
+	Error         string          `json:"error,omitempty"`  // Verification error against the active pool
+	Certificates  []CertSummary   `json:"certificates"`     // The submitted certificates, in order
+	Chains        [][]CertSummary `json:"chains,omitempty"` // Chains built against the active pool
+	Candidates    []CandidatePath `json:"candidates"`       // TSL service certificates that could anchor the chain
+}
+
+// CertSummary identifies a certificate in a VerifyReport.
+type CertSummary struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	IsCA      bool      `json:"is_ca"`
+	SHA256    string    `json:"sha256"` // Hex SHA-256 fingerprint of the DER encoding
+
+	// TSL services the certificate was selected into the certificate pool from
+	Provenance []pipeline.CertProvenance `json:"provenance,omitempty"`
+}
+
+// ServiceMatch identifies the TSL trust service a certificate was listed under.
+type ServiceMatch struct {
+	Territory string `json:"territory,omitempty"`
+	Provider  string `json:"provider"`
+	Service   string `json:"service"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+
+	// Link to the page of the provider, at the anchor certificate, if provider pages are published
+	ProviderPage string `json:"provider_page,omitempty"`
+
+	Extensions *pipeline.ServiceExtensions `json:"extensions,omitempty"` // Service information extensions
+}
+
+// CandidatePath is the result of verifying the submitted chain against a single
+// TSL service certificate whose subject matches an issuer in the chain.
+type CandidatePath struct {
+	Anchor   CertSummary  `json:"anchor"`
+	Service  ServiceMatch `json:"service"`
+	Verified bool         `json:"verified"`        // Whether the chain verifies with this certificate as the only root
+	Error    string       `json:"error,omitempty"` // Verification error for this path
+}
+
+// DebugVerifyHandler godoc
+// @Summary Diagnose certificate chain verification (admin)
+// @Description Verifies a submitted certificate chain against the loaded trust configuration and
+// @Description returns a detailed, non-authoritative report: the chains built against the active
+// @Description certificate pool, with the TSL source, territory, provider and service each pool
+// @Description certificate was selected from, and, for every TSL service certificate that could anchor the chain,
+// @Description the matched trust service, its service information extensions and the verification
+// @Description result for that path.
+// @Description
+// @Description The report helps integrators debug onboarding issues. It is not an AuthZEN decision
+// @Description and is not counted in decision metrics. Requires the admin bearer token.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body VerifyRequest true "Certificate chain as PEM or x5c"
+// @Success 200 {object} VerifyReport "Verification report"
+// @Failure 400 {object} map[string]string "No or unparseable certificates"
+// @Failure 401 {object} map[string]string "Missing or invalid admin token"
+// @Router /debug/verify [post]
+func DebugVerifyHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VerifyRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		certs, err := parseVerifyChain(&req)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		serverCtx.RLock()
+		pipelineCtx := serverCtx.TenantPipelineContext(req.Tenant)
+		serverCtx.RUnlock()
+
+		report := buildVerifyReport(pipelineCtx, certs, requestLanguages(c))
+		report.Tenant = req.Tenant
+
+		serverCtx.Logger.Info("Debug chain verification",
+			logging.F("remote_ip", c.ClientIP()),
+			logging.F("subject", report.Certificates[0].Subject),
+			logging.F("tenant", req.Tenant),
+			logging.F("trusted", report.Trusted),
+			logging.F("candidates", len(report.Candidates)))
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// parseVerifyChain parses the certificates of a VerifyRequest.
+func parseVerifyChain(req *VerifyRequest) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := []byte(req.PEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PEM certificate %d: %v", len(certs), err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 && len(bytes.TrimSpace([]byte(req.PEM))) > 0 {
+		return nil, fmt.Errorf("no PEM certificates found")
+	}
+
+	for i, s := range req.X5C {
+		der, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to base64 decode x5c[%d]: %v", i, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse x5c[%d]: %v", i, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates in request; provide pem or x5c")
+	}
+	return certs, nil
+}
+
+// buildVerifyReport verifies certs (leaf first) against the pipeline context's
+// certificate pool and against each TSL service certificate that matches an
+// issuer in the chain. Provider and service names are given in the languages
+// that best match langs.
+func buildVerifyReport(pipelineCtx *pipeline.Context, certs []*x509.Certificate, langs []string) *VerifyReport {
+	report := &VerifyReport{
+		Certificates: make([]CertSummary, 0, len(certs)),
+		Candidates:   []CandidatePath{},
+	}
+	for _, cert := range certs {
+		report.Certificates = append(report.Certificates, summarizeCert(cert))
+	}
+
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if pipelineCtx == nil || pipelineCtx.CertPool == nil {
+		report.Error = "no certificate pool loaded"
+	} else {
+		chains, err := x509util.Verify(leaf, x509.VerifyOptions{Roots: pipelineCtx.CertPool, Intermediates: intermediates})
+		if err != nil {
+			report.Error = err.Error()
+		} else {
+			report.Trusted = true
+			for _, chain := range chains {
+				summaries := make([]CertSummary, 0, len(chain))
+				for _, cert := range chain {
+					summary := summarizeCert(cert)
+					summary.Provenance = pipeline.LinkProviderPages(pipelineCtx.CertProvenance(cert), summary.SHA256)
+					summaries = append(summaries, summary)
+				}
+				report.Chains = append(report.Chains, summaries)
+			}
+		}
+	}
+
+	// Issuers named in the chain, and the chain certificates themselves, select
+	// which TSL service certificates are worth trying as anchors
+	wanted := make(map[string]bool)
+	for _, cert := range certs {
+		wanted[string(cert.RawIssuer)] = true
+		wanted[string(cert.RawSubject)] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, tsl := range pipelineCtx.AllTSLs() {
+		territory := ""
+		if si := tsl.StatusList.TslSchemeInformation; si != nil {
+			territory = si.TslSchemeTerritory
+		}
+		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+			svc.WithCertificates(func(anchor *x509.Certificate) {
+				if !wanted[string(anchor.RawSubject)] {
+					return
+				}
+				service := describeService(territory, tsp, svc, langs)
+				service.Extensions = pipelineCtx.ServiceExtensionsFor(svc)
+				key := string(anchor.Raw) + "\x00" + service.Provider + "\x00" + service.Service
+				if seen[key] {
+					return
+				}
+				seen[key] = true
+
+				candidate := CandidatePath{Anchor: summarizeCert(anchor), Service: service}
+				// Pages are named in the configured language, not the request's
+				candidate.Service.ProviderPage = pipeline.ProviderPageURL(territory,
+					pipeline.NewCertProvenance(tsl, tsp, svc).Provider, candidate.Anchor.SHA256)
+				roots := x509.NewCertPool()
+				roots.AddCert(anchor)
+				if _, err := x509util.Verify(leaf, x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+					candidate.Error = err.Error()
+				} else {
+					candidate.Verified = true
+				}
+				report.Candidates = append(report.Candidates, candidate)
+			})
+		})
+	}
+
+	return report
+}
+
+// summarizeCert returns the CertSummary of a certificate.
+func summarizeCert(cert *x509.Certificate) CertSummary {
+	return CertSummary{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		Serial:    cert.SerialNumber.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		IsCA:      cert.IsCA,
+		SHA256:    pipeline.Fingerprint(cert),
+	}
+}
+
+// describeService returns the ServiceMatch of a trust service, with names in
+// the languages that best match langs.
+func describeService(territory string, tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType, langs []string) ServiceMatch {
+	match := ServiceMatch{Territory: territory, Provider: "Unknown", Service: "Unknown"}
+	if tsp.TslTSPInformation != nil {
+		match.Provider = i18n.Name(tsp.TslTSPInformation.TSPName, langs, "Unknown")
+	}
+	if info := svc.TslServiceInformation; info != nil {
+		match.Service = i18n.Name(info.ServiceName, langs, "Unknown")
+		match.Type = info.TslServiceTypeIdentifier
+		match.Status = info.TslServiceStatus
+	}
+	return match
+}
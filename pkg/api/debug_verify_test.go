@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-token"
+
+// setupAdminServer returns a router with admin endpoints enabled and the given
+// pipeline context.
+func setupAdminServer(ctx *pipeline.Context) (*gin.Engine, *ServerContext) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	serverCtx := NewServerContext(logging.DefaultLogger())
+	serverCtx.PipelineContext = ctx
+	serverCtx.AdminToken = testAdminToken
+	serverCtx.Metrics = NewMetrics()
+	RegisterAPIRoutes(r, serverCtx)
+	return r, serverCtx
+}
+
+func postVerify(t *testing.T, r *gin.Engine, body string) (int, *VerifyReport) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/debug/verify", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+	var report VerifyReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, &report
+}
+
+func TestDebugVerify_NotRegisteredWithoutToken(t *testing.T) {
+	r, _ := setupTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/debug/verify", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDebugVerify_RequiresToken(t *testing.T) {
+	r, _ := setupAdminServer(pipeline.NewContext())
+	req := httptest.NewRequest(http.MethodPost, "/debug/verify", strings.NewReader(`{"x5c":[]}`))
+	req.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestDebugVerify_TrustedChain(t *testing.T) {
+	ca, err := testutil.NewCA("Issuing CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().WithTerritory("SE").
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("Test CA Service").WithCert(ca))).
+		Build())
+	ctx, err = pipeline.SelectCertPool(nil, ctx)
+	require.NoError(t, err)
+	svc := ctx.AllTSLs()[0].StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0]
+	ctx.ServiceExtensions = map[*etsi119612.TSPServiceType]*pipeline.ServiceExtensions{
+		svc: {AdditionalServiceInformation: []string{"http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSignatures"}},
+	}
+	r, serverCtx := setupAdminServer(ctx)
+
+	code, report := postVerify(t, r, fmt.Sprintf(`{"pem":%q}`, leaf.CertPEM()))
+	require.Equal(t, http.StatusOK, code)
+
+	assert.False(t, report.Authoritative)
+	assert.True(t, report.Trusted)
+	assert.Empty(t, report.Error)
+	require.Len(t, report.Certificates, 1)
+	assert.Equal(t, "CN=leaf", report.Certificates[0].Subject)
+	require.Len(t, report.Chains, 1)
+	assert.Len(t, report.Chains[0], 2)
+	assert.Empty(t, report.Chains[0][0].Provenance, "the leaf is not in the pool")
+	require.Len(t, report.Chains[0][1].Provenance, 1)
+	assert.Equal(t, "SE", report.Chains[0][1].Provenance[0].Territory)
+	assert.Equal(t, "Test CA Service", report.Chains[0][1].Provenance[0].Service)
+
+	require.Len(t, report.Candidates, 1)
+	candidate := report.Candidates[0]
+	assert.True(t, candidate.Verified)
+	assert.Equal(t, "SE", candidate.Service.Territory)
+	assert.Equal(t, "Test TSP", candidate.Service.Provider)
+	assert.Equal(t, "Test CA Service", candidate.Service.Service)
+	assert.Equal(t, etsi119612.ServiceStatusGranted, candidate.Service.Status)
+	assert.Equal(t, pltesting.DefaultServiceType, candidate.Service.Type)
+	require.NotNil(t, candidate.Service.Extensions)
+	assert.True(t, candidate.Service.Extensions.HasAdditionalServiceInformation("ForeSignatures"))
+
+	// Diagnostics are not AuthZEN decisions
+	assert.Equal(t, 0, promtestutil.CollectAndCount(serverCtx.Metrics.DecisionsTotal))
+}
+
+func TestDebugVerify_UntrustedChain(t *testing.T) {
+	// A withdrawn service lists the real issuer, and a granted service lists an
+	// unrelated CA with the same subject name
+	ca, err := testutil.NewCA("Issuing CA")
+	require.NoError(t, err)
+	impostor, err := testutil.NewCA("Issuing CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("Withdrawn").WithStatus(pltesting.ServiceStatusWithdrawn).WithCert(ca)).
+			WithService(pltesting.NewService("Other").WithCert(impostor))).
+		Build())
+	ctx.CertPool = impostor.Pool()
+	r, _ := setupAdminServer(ctx)
+
+	code, report := postVerify(t, r, fmt.Sprintf(`{"x5c":[%q]}`, leaf.Base64()))
+	require.Equal(t, http.StatusOK, code)
+
+	assert.False(t, report.Trusted)
+	assert.NotEmpty(t, report.Error)
+	assert.Empty(t, report.Chains)
+
+	require.Len(t, report.Candidates, 2)
+	byService := make(map[string]CandidatePath)
+	for _, c := range report.Candidates {
+		byService[c.Service.Service] = c
+	}
+	assert.True(t, byService["Withdrawn"].Verified)
+	assert.Equal(t, pltesting.ServiceStatusWithdrawn, byService["Withdrawn"].Service.Status)
+	assert.False(t, byService["Other"].Verified)
+	assert.NotEmpty(t, byService["Other"].Error)
+}
+
+func TestDebugVerify_NoPool(t *testing.T) {
+	leaf, err := testutil.NewChain("leaf")
+	require.NoError(t, err)
+	r, _ := setupAdminServer(pipeline.NewContext())
+
+	code, report := postVerify(t, r, fmt.Sprintf(`{"x5c":[%q,%q]}`, leaf.Base64(), leaf.Issuer.Base64()))
+	require.Equal(t, http.StatusOK, code)
+	assert.False(t, report.Trusted)
+	assert.Equal(t, "no certificate pool loaded", report.Error)
+	assert.Len(t, report.Certificates, 2)
+	assert.Empty(t, report.Candidates)
+}
+
+func TestDebugVerify_Tenant(t *testing.T) {
+	ca, err := testutil.NewCA("Bank CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	r, serverCtx := setupAdminServer(pipeline.NewContext())
+	bankCtx := pipeline.NewContext()
+	bankCtx.CertPool = ca.Pool()
+	serverCtx.TenantContexts = map[string]*pipeline.Context{"bank": bankCtx}
+
+	_, report := postVerify(t, r, fmt.Sprintf(`{"x5c":[%q],"tenant":"bank"}`, leaf.Base64()))
+	require.NotNil(t, report)
+	assert.True(t, report.Trusted)
+	assert.Equal(t, "bank", report.Tenant)
+
+	_, report = postVerify(t, r, fmt.Sprintf(`{"x5c":[%q]}`, leaf.Base64()))
+	require.NotNil(t, report)
+	assert.False(t, report.Trusted)
+}
+
+func TestDebugVerify_BadRequest(t *testing.T) {
+	r, _ := setupAdminServer(pipeline.NewContext())
+
+	for _, body := range []string{
+		`not json`,
+		`{}`,
+		`{"pem":"garbage"}`,
+		`{"x5c":["not base64!"]}`,
+		`{"x5c":["aGVsbG8="]}`,
+	} {
+		code, _ := postVerify(t, r, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+)
+
+// Normalized reason codes used as the "reason" label of decision metrics.
+// They group the free-form reasons returned by the registries into a small,
+// fixed set so that the label cardinality stays bounded.
+const (
+	ReasonNone              = "none"               // Decision was true
+	ReasonExpired           = "expired"            // Certificate expired or not yet valid
+	ReasonUnknownAuthority  = "unknown_authority"  // No trusted issuer or trust chain
+	ReasonRevoked           = "revoked"            // Certificate or service revoked or withdrawn
+	ReasonPolicyMismatch    = "policy_mismatch"    // Chain is trusted but not for the requested use
+	ReasonTerritoryMismatch = "territory_mismatch" // Chain is trusted but anchored outside the allowed territories
+	ReasonNameMismatch      = "name_mismatch"      // subject.id is not a name of the leaf certificate
+	ReasonInvalidRequest    = "invalid_request"    // Request or key material could not be parsed
+	ReasonUnavailable       = "unavailable"        // No trust data loaded or registries timed out
+	ReasonTimeout           = "timeout"            // No decision within the decision deadline
+	ReasonOverride          = "override"           // Certificate distrusted by the override deny-list
+	ReasonError             = "error"              // Evaluation failed with an internal error
+	ReasonOther             = "other"              // Anything not matched above
+)
+
+// Resource type label values. Resource types other than x5c, jwk and entity
+// are reported as ResourceTypeOther.
+const (
+	ResourceTypeX5C    = "x5c"
+	ResourceTypeJWK    = "jwk"
+	ResourceTypeEntity = "entity"
+	ResourceTypeOther  = "other"
+)
+
+// reasonPatterns maps substrings of lower-cased registry reason messages to
+// reason codes. The first match wins, so more specific patterns come first.
+var reasonPatterns = []struct {
+	substr string
+	code   string
+}{
+	{"distrusted by override", ReasonOverride},
+	{"territory mismatch", ReasonTerritoryMismatch},
+	{"name mismatch", ReasonNameMismatch},
+	{"revoked", ReasonRevoked},
+	{"withdrawn", ReasonRevoked},
+	{"expired", ReasonExpired},
+	{"not yet valid", ReasonExpired},
+	{"incompatible key usage", ReasonPolicyMismatch},
+	{"trust marks", ReasonPolicyMismatch},
+	{"metadata constraints", ReasonPolicyMismatch},
+	{"not authorized", ReasonPolicyMismatch},
+	{"policy", ReasonPolicyMismatch},
+	{"unknown authority", ReasonUnknownAuthority},
+	{"no valid trust chain", ReasonUnknownAuthority},
+	{"no registry returned positive match", ReasonUnknownAuthority},
+	{"not initialized", ReasonUnavailable},
+	{"certpool is nil", ReasonUnavailable},
+	{"decision timeout", ReasonTimeout},
+	{"timeout", ReasonUnavailable},
+	{"no applicable registries", ReasonUnavailable},
+	{"invalid request", ReasonInvalidRequest},
+	{"validation error", ReasonInvalidRequest},
+	{"resource.key", ReasonInvalidRequest},
+	{"jwk", ReasonInvalidRequest},
+	{"unsupported resource type", ReasonInvalidRequest},
+	{"entity id", ReasonInvalidRequest},
+	{"failed to parse certificate", ReasonInvalidRequest},
+}
+
+// DecisionReasonCode returns the normalized reason code for an evaluation
+// response. Responses with decision true yield ReasonNone; for denials the
+// "error" and "message" entries of the response reason are matched against
+// known registry and x509 verification messages.
+func DecisionReasonCode(resp *authzen.EvaluationResponse) string {
+	if resp == nil {
+		return ReasonError
+	}
+	if resp.Decision {
+		return ReasonNone
+	}
+	if resp.Context == nil || resp.Context.Reason == nil {
+		return ReasonOther
+	}
+
+	for _, key := range []string{"error", "message"} {
+		msg, ok := resp.Context.Reason[key].(string)
+		if !ok || msg == "" {
+			continue
+		}
+		if code := reasonCodeFor(msg); code != ReasonOther {
+			return code
+		}
+	}
+	return ReasonOther
+}
+
+// reasonCodeFor returns the reason code of a reason message, or ReasonOther if
+// it matches no known pattern.
+func reasonCodeFor(msg string) string {
+	msg = strings.ToLower(msg)
+	for _, p := range reasonPatterns {
+		if strings.Contains(msg, p.substr) {
+			return p.code
+		}
+	}
+	return ReasonOther
+}
+
+// resourceTypeLabel returns the metric label value for an AuthZEN resource type.
+func resourceTypeLabel(resourceType string) string {
+	switch resourceType {
+	case ResourceTypeX5C, ResourceTypeJWK, ResourceTypeEntity:
+		return resourceType
+	default:
+		return ResourceTypeOther
+	}
+}
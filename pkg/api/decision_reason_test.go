@@ -0,0 +1,119 @@
+package api
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func denial(key, msg string) *authzen.EvaluationResponse {
+	return &authzen.EvaluationResponse{
+		Context: &authzen.EvaluationResponseContext{
+			Reason: map[string]interface{}{key: msg},
+		},
+	}
+}
+
+func TestDecisionReasonCode(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *authzen.EvaluationResponse
+		want string
+	}{
+		{"nil response", nil, ReasonError},
+		{"permit", &authzen.EvaluationResponse{Decision: true}, ReasonNone},
+		{"no context", &authzen.EvaluationResponse{}, ReasonOther},
+		{"expired", denial("error", "x509: certificate has expired or is not yet valid: current time is after"), ReasonExpired},
+		{"unknown authority", denial("error", "x509: certificate signed by unknown authority"), ReasonUnknownAuthority},
+		{"no trust chain", denial("message", "no valid trust chain found"), ReasonUnknownAuthority},
+		{"no positive match", denial("error", "no registry returned positive match"), ReasonUnknownAuthority},
+		{"revoked", denial("error", "certificate revoked"), ReasonRevoked},
+		{"wrong eku", denial("error", "x509: certificate specifies an incompatible key usage"), ReasonPolicyMismatch},
+		{"trust marks", denial("message", "required trust marks not present"), ReasonPolicyMismatch},
+		{"metadata constraints", denial("message", "metadata constraints not satisfied"), ReasonPolicyMismatch},
+		{"override", denial("error", "certificate distrusted by override"), ReasonOverride},
+		{"territory", denial("error", "territory mismatch: trust anchor is listed for territory DE, not SE"), ReasonTerritoryMismatch},
+		{"invalid request", denial("error", "invalid request: subject.type must be 'key'"), ReasonInvalidRequest},
+		{"bad x5c", denial("error", "failed to decode resource.key[0]: illegal base64 data"), ReasonInvalidRequest},
+		{"no pool", denial("error", "TSL CertPool is not initialized"), ReasonUnavailable},
+		{"timeout", denial("error", "timeout waiting for registry responses"), ReasonUnavailable},
+		{"decision timeout", denial("error", "decision timeout: no decision within 10s"), ReasonTimeout},
+		{"unmatched", denial("error", "something unexpected"), ReasonOther},
+		{"non-string reason", denial("registry", ""), ReasonOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DecisionReasonCode(tt.resp))
+		})
+	}
+}
+
+func TestResourceTypeLabel(t *testing.T) {
+	assert.Equal(t, "x5c", resourceTypeLabel("x5c"))
+	assert.Equal(t, "jwk", resourceTypeLabel("jwk"))
+	assert.Equal(t, "other", resourceTypeLabel("did:web"))
+	assert.Equal(t, "other", resourceTypeLabel(""))
+}
+
+func TestAuthZENDecisionHandler_DecisionMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ca, err := testutil.NewCA("Decision CA")
+	require.NoError(t, err)
+	trusted, err := testutil.NewLeaf(ca, "trusted")
+	require.NoError(t, err)
+	expired, err := testutil.NewLeaf(ca, "expired", testutil.Expired())
+	require.NoError(t, err)
+	wrongEKU, err := testutil.NewLeaf(ca, "email", testutil.WithExtKeyUsage(x509.ExtKeyUsageEmailProtection))
+	require.NoError(t, err)
+	unknown, err := testutil.NewCA("Unknown CA")
+	require.NoError(t, err)
+
+	ctx := pipeline.NewContext()
+	ctx.CertPool = ca.Pool()
+	serverCtx := NewServerContext(logging.NewLogger(logging.InfoLevel))
+	serverCtx.PipelineContext = ctx
+	serverCtx.LastProcessed = time.Now()
+	serverCtx.Metrics = NewMetrics()
+
+	r := gin.New()
+	RegisterAPIRoutes(r, serverCtx)
+
+	evaluate := func(cert *testutil.Cert, action string) {
+		actionJSON := ""
+		if action != "" {
+			actionJSON = fmt.Sprintf(`,"action":{"name":%q}`, action)
+		}
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}%s}`,
+			cert.Base64(), actionJSON)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	evaluate(trusted, "issuer")
+	evaluate(trusted, "issuer")
+	evaluate(expired, "issuer")
+	evaluate(wrongEKU, "")
+	evaluate(unknown, "verifier")
+
+	decisions := serverCtx.Metrics.DecisionsTotal
+	assert.Equal(t, 2.0, promtestutil.ToFloat64(decisions.WithLabelValues("issuer", "x5c", "none", "none", "true", ReasonNone)))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("issuer", "x5c", "none", "none", "false", ReasonExpired)))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("none", "x5c", "none", "none", "false", ReasonPolicyMismatch)))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("verifier", "x5c", "none", "none", "false", ReasonUnknownAuthority)))
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultDecisionTimeout is the deadline of a trust decision when the server
+// configuration does not set one.
+const DefaultDecisionTimeout = 10 * time.Second
+
+// StatusClientClosedRequest is the status recorded in the access log for
+// evaluations abandoned because the client disconnected. No response reaches
+// the client.
+const StatusClientClosedRequest = 499
+
+// Causes of abandoned decisions, the "cause" label of the decision timeout
+// metric.
+const (
+	TimeoutCauseDeadline = "deadline" // The decision deadline passed
+	TimeoutCauseCanceled = "canceled" // The client disconnected
+)
+
+// decisionContext returns the context of one trust decision: parent, the
+// request context, with a deadline timeout from now. A timeout of 0 or less
+// uses DefaultDecisionTimeout.
+func decisionContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultDecisionTimeout
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// abandonDecision answers an evaluation whose context ended with err before a
+// decision was made. If the deadline passed, the request is denied with a
+// "decision timeout" reason; if the client disconnected, nothing is sent.
+func abandonDecision(c *gin.Context, serverCtx *ServerContext, labels DecisionLabels, err error, timeout, elapsed time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultDecisionTimeout
+	}
+	cause := TimeoutCauseDeadline
+	if !errors.Is(err, context.DeadlineExceeded) {
+		cause = TimeoutCauseCanceled
+	}
+
+	serverCtx.Logger.Warn("AuthZEN decision abandoned",
+		logging.F("remote_ip", c.ClientIP()),
+		logging.F("request_id", RequestID(c)),
+		logging.F("resource_type", labels.ResourceType),
+		logging.F("action", labels.Action),
+		logging.F("tenant", labels.Tenant),
+		logging.F("cause", cause),
+		logging.F("timeout", timeout.String()),
+		logging.F("duration_ms", elapsed.Milliseconds()))
+
+	if serverCtx.Metrics != nil {
+		serverCtx.Metrics.RecordDecisionTimeout(cause)
+	}
+	if cause == TimeoutCauseCanceled {
+		c.AbortWithStatus(StatusClientClosedRequest)
+		return
+	}
+
+	if serverCtx.Metrics != nil {
+		labels.Reason = ReasonTimeout
+		serverCtx.Metrics.RecordDecision(labels)
+	}
+	resp := buildResponse(false, fmt.Sprintf("decision timeout: no decision within %s", timeout))
+	serverCtx.RLock()
+	userMessages := serverCtx.UserMessages
+	serverCtx.RUnlock()
+	setUserReason(c, &resp, userMessages, ReasonTimeout)
+	c.JSON(200, resp)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/registry"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stallRegistry is a registry whose evaluations never finish on their own.
+type stallRegistry struct{}
+
+func (stallRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRequest) (*authzen.EvaluationResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (stallRegistry) SupportedResourceTypes() []string { return []string{authzen.ResourceTypeEntity} }
+func (stallRegistry) Info() registry.RegistryInfo {
+	return registry.RegistryInfo{Name: "stall", Type: "mock"}
+}
+func (stallRegistry) Healthy() bool                     { return true }
+func (stallRegistry) Refresh(ctx context.Context) error { return nil }
+
+func TestAuthZENDecisionHandler_DecisionTimeout(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	mgr := registry.NewRegistryManager(registry.FirstMatch, time.Minute)
+	mgr.Register(stallRegistry{})
+	serverCtx.Lock()
+	serverCtx.RegistryManager = mgr
+	serverCtx.Metrics = NewMetrics()
+	serverCtx.DecisionTimeout = 50 * time.Millisecond
+	serverCtx.Unlock()
+
+	body := `{"subject":{"type":"entity","id":"https://rp.example.com"},"resource":{"type":"entity","id":"https://rp.example.com"}}`
+	start := time.Now()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+	assert.Less(t, time.Since(start), 10*time.Second, "the registry timeout of a minute must not be waited for")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp authzen.EvaluationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Decision)
+	assert.Equal(t, "decision timeout: no decision within 50ms", resp.Context.Reason["error"])
+	assert.Equal(t, ReasonTimeout, DecisionReasonCode(&resp))
+	require.NotNil(t, resp.Context.ReasonUser)
+	assert.Equal(t, ReasonTimeout, resp.Context.ReasonUser.Code)
+
+	timeouts := serverCtx.Metrics.DecisionTimeoutsTotal
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(timeouts.WithLabelValues(TimeoutCauseDeadline)))
+	decisions := serverCtx.Metrics.DecisionsTotal
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("none", "entity", "none", "none", "false", ReasonTimeout)))
+}
+
+func TestAuthZENDecisionHandler_ClientDisconnect(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	ca, err := testutil.NewCA("Disconnect CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+	serverCtx.Lock()
+	serverCtx.PipelineContext.CertPool = ca.Pool()
+	serverCtx.Metrics = NewMetrics()
+	serverCtx.Unlock()
+
+	// The client is gone before the chain is verified
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, leaf.Base64())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)).WithContext(ctx))
+
+	assert.Equal(t, StatusClientClosedRequest, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(serverCtx.Metrics.DecisionTimeoutsTotal.WithLabelValues(TimeoutCauseCanceled)))
+	assert.Equal(t, 0, promtestutil.CollectAndCount(serverCtx.Metrics.DecisionsTotal))
+
+	// The same request from a connected client is decided
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp authzen.EvaluationResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Decision)
+}
+
+func TestDecisionContext(t *testing.T) {
+	ctx, cancel := decisionContext(context.Background(), 0)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(DefaultDecisionTimeout), deadline, time.Second)
+
+	ctx, cancel = decisionContext(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Phases of a trust decision, the "phase" label of the decision phase
+// duration metric and the metric names of the Server-Timing header.
+const (
+	PhaseBind    = "bind"    // Binding and validating the request JSON
+	PhaseParse   = "parse"   // Parsing the certificates in resource.key
+	PhaseLookup  = "lookup"  // Selecting the registries and certificate pool
+	PhasePolicy  = "policy"  // Tenant, territory and override checks
+	PhaseVerify  = "verify"  // Chain verification or registry evaluation
+	PhaseRespond = "respond" // Encoding and writing the response
+)
+
+// ServerTimingHeader is the response header reporting the phase durations of
+// a decision when server timing is enabled.
+const ServerTimingHeader = "Server-Timing"
+
+// phaseDuration is the time spent in one phase of a decision.
+type phaseDuration struct {
+	phase    string
+	duration time.Duration
+}
+
+// decisionTimer splits the time spent on a decision into phases. Each call to
+// mark attributes the time since the previous mark to a phase; phases marked
+// more than once accumulate.
+type decisionTimer struct {
+	last   time.Time
+	phases []phaseDuration
+}
+
+// newDecisionTimer returns a decisionTimer whose first phase starts now.
+func newDecisionTimer() *decisionTimer {
+	return &decisionTimer{last: time.Now()}
+}
+
+// mark ends the current phase, attributing the time since the previous mark
+// to phase.
+func (t *decisionTimer) mark(phase string) {
+	now := time.Now()
+	elapsed := now.Sub(t.last)
+	t.last = now
+	for i := range t.phases {
+		if t.phases[i].phase == phase {
+			t.phases[i].duration += elapsed
+			return
+		}
+	}
+	t.phases = append(t.phases, phaseDuration{phase: phase, duration: elapsed})
+}
+
+// serverTiming returns the Server-Timing header value of the phases marked so
+// far, with durations in milliseconds.
+func (t *decisionTimer) serverTiming() string {
+	parts := make([]string, 0, len(t.phases))
+	for _, p := range t.phases {
+		parts = append(parts, fmt.Sprintf("%s;dur=%.3f", p.phase, float64(p.duration)/float64(time.Millisecond)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// record records the duration of every phase marked so far.
+func (t *decisionTimer) record(m *Metrics) {
+	if m == nil {
+		return
+	}
+	for _, p := range t.phases {
+		m.RecordDecisionPhase(p.phase, p.duration)
+	}
+}
+
+// serverTimingWriter wraps a gin.ResponseWriter and adds the Server-Timing
+// header of a decision just before the response headers are sent. The respond
+// phase itself is not included, since it ends after the headers are sent.
+type serverTimingWriter struct {
+	gin.ResponseWriter
+	timer *decisionTimer
+	done  bool
+}
+
+// setHeader sets the Server-Timing header once, before the headers are sent.
+func (w *serverTimingWriter) setHeader() {
+	if w.done || w.Written() {
+		return
+	}
+	w.done = true
+	if v := w.timer.serverTiming(); v != "" {
+		w.Header().Set(ServerTimingHeader, v)
+	}
+}
+
+// WriteHeaderNow sets the Server-Timing header and sends the headers.
+func (w *serverTimingWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Write sets the Server-Timing header and writes b into the response body.
+func (w *serverTimingWriter) Write(b []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteString sets the Server-Timing header and writes s into the response body.
+func (w *serverTimingWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+// timeDecision starts timing the decision answered by c. Time not marked as
+// another phase when the handler returns is recorded as the respond phase.
+// The returned function must be deferred by the handler; it records the
+// phase durations in the server's metrics. If serverTiming is true, the
+// phases up to the response are also reported in the Server-Timing header.
+func timeDecision(c *gin.Context, serverCtx *ServerContext, serverTiming bool) (*decisionTimer, func()) {
+	timer := newDecisionTimer()
+	var tw *serverTimingWriter
+	if serverTiming {
+		tw = &serverTimingWriter{ResponseWriter: c.Writer, timer: timer}
+		c.Writer = tw
+	}
+	return timer, func() {
+		timer.mark(PhaseRespond)
+		timer.record(serverCtx.Metrics)
+		if tw != nil {
+			c.Writer = tw.ResponseWriter
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthZENDecisionHandler_PhaseMetrics(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	ca, err := testutil.NewCA("Timing CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+	serverCtx.Lock()
+	serverCtx.PipelineContext.CertPool = ca.Pool()
+	serverCtx.Metrics = NewMetrics()
+	serverCtx.Unlock()
+
+	body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, leaf.Base64())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(ServerTimingHeader), "Server-Timing is off by default")
+
+	// A decided request passes through every phase
+	assert.Equal(t, 6, promtestutil.CollectAndCount(serverCtx.Metrics.DecisionPhaseDuration))
+
+	// A malformed request ends after binding
+	metrics := NewMetrics()
+	serverCtx.Lock()
+	serverCtx.Metrics = metrics
+	serverCtx.Unlock()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader("{")))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 2, promtestutil.CollectAndCount(metrics.DecisionPhaseDuration))
+}
+
+func TestAuthZENDecisionHandler_ServerTiming(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	ca, err := testutil.NewCA("Timing CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+	serverCtx.Lock()
+	serverCtx.PipelineContext.CertPool = ca.Pool()
+	serverCtx.ServerTiming = true
+	serverCtx.Unlock()
+
+	body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, leaf.Base64())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	header := w.Header().Get(ServerTimingHeader)
+	assert.Regexp(t, regexp.MustCompile(`^bind;dur=[0-9.]+, parse;dur=[0-9.]+, lookup;dur=[0-9.]+, policy;dur=[0-9.]+, verify;dur=[0-9.]+$`), header)
+	assert.NotContains(t, header, PhaseRespond)
+}
+
+func TestDecisionTimer(t *testing.T) {
+	timer := newDecisionTimer()
+	timer.mark(PhaseBind)
+	time.Sleep(2 * time.Millisecond)
+	timer.mark(PhasePolicy)
+	timer.mark(PhaseVerify)
+	time.Sleep(2 * time.Millisecond)
+	timer.mark(PhasePolicy)
+
+	require.Len(t, timer.phases, 3, "repeated phases accumulate")
+	assert.Equal(t, PhasePolicy, timer.phases[1].phase)
+	assert.GreaterOrEqual(t, timer.phases[1].duration, 4*time.Millisecond)
+	assert.True(t, strings.HasPrefix(timer.serverTiming(), "bind;dur="))
+}
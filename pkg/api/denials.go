@@ -0,0 +1,108 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultDenialLogSize is the number of denials kept by a DenialLog created
+// with a non-positive size.
+const DefaultDenialLogSize = 50
+
+// Denial is a negative AuthZEN decision, as kept by a DenialLog.
+type Denial struct {
+	Time         time.Time `json:"time"`                  // When the decision was made
+	RequestID    string    `json:"request_id,omitempty"`  // X-Request-ID of the request
+	SubjectID    string    `json:"subject_id"`            // Name whose key binding was denied
+	ResourceType string    `json:"resource_type"`         // Resource type label (x5c, jwk, entity or other)
+	Action       string    `json:"action,omitempty"`      // Requested action, if any
+	Tenant       string    `json:"tenant,omitempty"`      // Tenant label of the request
+	Reason       string    `json:"reason"`                // Normalized reason code (see DecisionReasonCode)
+	Message      string    `json:"message,omitempty"`     // Reason given in the decision
+	Generation   uint64    `json:"pool_generation"`       // Pool generation the request was decided against, 0 if none
+	DurationMS   int64     `json:"duration_ms,omitempty"` // Time taken to decide
+}
+
+// DenialLog keeps the most recent denied decisions in memory, so that
+// operators can see why clients are refused without searching the logs. It is
+// safe for concurrent use, and independent of the ServerContext lock so that
+// recording a denial never waits for a pipeline update.
+type DenialLog struct {
+	mu      sync.Mutex
+	entries []Denial // Ring buffer of at most size entries
+	next    int      // Index of the slot the next denial is written to
+	size    int
+}
+
+// NewDenialLog returns a DenialLog keeping the last size denials, or the last
+// DefaultDenialLogSize if size is not positive.
+func NewDenialLog(size int) *DenialLog {
+	if size <= 0 {
+		size = DefaultDenialLogSize
+	}
+	return &DenialLog{size: size}
+}
+
+// Record adds a denial, discarding the oldest once the log is full. Recording
+// on a nil DenialLog does nothing.
+func (l *DenialLog) Record(d Denial) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.entries) < l.size {
+		l.entries = append(l.entries, d)
+	} else {
+		l.entries[l.next] = d
+	}
+	l.next = (l.next + 1) % l.size
+}
+
+// Recent returns the recorded denials, newest first.
+func (l *DenialLog) Recent() []Denial {
+	if l == nil {
+		return []Denial{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := make([]Denial, 0, len(l.entries))
+	for i := 1; i <= len(l.entries); i++ {
+		recent = append(recent, l.entries[(l.next-i+len(l.entries))%len(l.entries)])
+	}
+	return recent
+}
+
+// newDenial returns the Denial of req, denied with the reason code in labels
+// and the given message.
+func newDenial(c *gin.Context, req *authzen.EvaluationRequest, labels DecisionLabels, message string) Denial {
+	return Denial{
+		Time:         time.Now(),
+		RequestID:    RequestID(c),
+		SubjectID:    req.Subject.ID,
+		ResourceType: resourceTypeLabel(req.Resource.Type),
+		Action:       labels.Action,
+		Tenant:       labels.Tenant,
+		Reason:       labels.Reason,
+		Message:      message,
+	}
+}
+
+// reasonMessage returns the "error" or "message" entry of the reason of resp,
+// or "" if it has neither.
+func reasonMessage(resp *authzen.EvaluationResponse) string {
+	if resp == nil || resp.Context == nil {
+		return ""
+	}
+	for _, key := range []string{"error", "message"} {
+		if msg, ok := resp.Context.Reason[key].(string); ok && msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
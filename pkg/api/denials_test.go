@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDenialLog(t *testing.T) {
+	log := NewDenialLog(3)
+	assert.Empty(t, log.Recent())
+
+	for i := 1; i <= 5; i++ {
+		log.Record(Denial{SubjectID: fmt.Sprintf("subject-%d", i)})
+	}
+
+	recent := log.Recent()
+	require.Len(t, recent, 3, "only the last 3 denials are kept")
+	assert.Equal(t, "subject-5", recent[0].SubjectID)
+	assert.Equal(t, "subject-4", recent[1].SubjectID)
+	assert.Equal(t, "subject-3", recent[2].SubjectID)
+
+	// A nil log records nothing
+	var none *DenialLog
+	none.Record(Denial{SubjectID: "ignored"})
+	assert.Empty(t, none.Recent())
+
+	assert.Equal(t, DefaultDenialLogSize, NewDenialLog(0).size)
+}
+
+func TestAuthZENDecisionHandler_RecordsDenials(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "untrusted.example.com")
+	require.NoError(t, err)
+
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.Denials = NewDenialLog(10)
+	serverCtx.PipelineContext.Generation = 7
+	serverCtx.Unlock()
+
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+		return w.Code
+	}
+
+	// Trusted and malformed requests are not denials
+	require.Equal(t, http.StatusOK, post(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":["`+testCertBase64+`"]}}`))
+	require.Equal(t, http.StatusBadRequest, post(`{"subject":{"type":"user","id":"alice"}}`))
+	assert.Empty(t, serverCtx.Denials.Recent())
+
+	require.Equal(t, http.StatusOK, post(`{"subject":{"type":"key","id":"mallory"},"resource":{"type":"x5c","id":"mallory","key":["`+leaf.Base64()+`"]},"action":{"name":"issuer"}}`))
+
+	recent := serverCtx.Denials.Recent()
+	require.Len(t, recent, 1)
+	denial := recent[0]
+	assert.Equal(t, "mallory", denial.SubjectID)
+	assert.Equal(t, ResourceTypeX5C, denial.ResourceType)
+	assert.Equal(t, "issuer", denial.Action)
+	assert.Equal(t, ReasonUnknownAuthority, denial.Reason)
+	assert.Contains(t, denial.Message, "unknown authority")
+	assert.Equal(t, uint64(7), denial.Generation)
+	assert.False(t, denial.Time.IsZero())
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"golang.org/x/time/rate"
+)
+
+// Defaults of a DenyWebhook for settings left at zero.
+const (
+	DefaultDenyWebhookBatchSize     = 50
+	DefaultDenyWebhookFlushInterval = 5 * time.Second
+	DefaultDenyWebhookMaxPerMinute  = 60
+	DefaultDenyWebhookMaxPending    = 1000
+	DefaultDenyWebhookTimeout       = 10 * time.Second
+)
+
+// DenyWebhookOptions configures a DenyWebhook.
+type DenyWebhookOptions struct {
+	URL           string        // Endpoint the batches are POSTed to
+	Actions       []string      // Actions whose denials are sent; empty sends all
+	Token         string        // Bearer token sent with each batch (optional)
+	BatchSize     int           // Most denials in one POST; 0 uses DefaultDenyWebhookBatchSize
+	FlushInterval time.Duration // Longest time a denial waits for a batch to fill; 0 uses DefaultDenyWebhookFlushInterval
+	MaxPerMinute  int           // Most POSTs per minute; 0 uses DefaultDenyWebhookMaxPerMinute
+	MaxPending    int           // Most denials waiting to be sent; 0 uses DefaultDenyWebhookMaxPending
+	Timeout       time.Duration // Timeout of each POST; 0 uses DefaultDenyWebhookTimeout
+}
+
+// DenyWebhookBatch is the JSON body POSTed by a DenyWebhook.
+type DenyWebhookBatch struct {
+	SentAt  time.Time `json:"sent_at"`
+	Denials []Denial  `json:"denials"`
+	Dropped int       `json:"dropped,omitempty"` // Denials not sent since the previous batch because too many were pending
+}
+
+// DenyWebhook POSTs denied decisions to an HTTP endpoint, so that security
+// teams can follow failed onboarding attempts as they happen. Denials are
+// sent in batches of up to BatchSize, at least every FlushInterval, and at
+// most MaxPerMinute times a minute; while the rate limit holds batches back,
+// up to MaxPending denials wait and further ones are dropped and counted in
+// the next batch. A batch the endpoint does not accept with a 2xx status is
+// logged and discarded. Notify is safe for concurrent use and never blocks.
+type DenyWebhook struct {
+	url       string
+	token     string
+	actions   map[string]bool
+	batchSize int
+	interval  time.Duration
+	limiter   *rate.Limiter
+	client    *http.Client
+	logger    logging.Logger
+
+	queue chan Denial   // Denials waiting to be sent
+	wake  chan struct{} // Signalled when a batch is full
+
+	mu          sync.Mutex
+	dropped     int       // Denials dropped since the last batch sent
+	lastSuccess time.Time // When a batch was last accepted
+	lastError   string    // Why the last batch failed
+	lastErrorAt time.Time // When a batch last failed
+}
+
+// NewDenyWebhook returns a DenyWebhook for opts. Call Start to begin sending.
+func NewDenyWebhook(opts DenyWebhookOptions, logger logging.Logger) (*DenyWebhook, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid deny webhook URL %q: must be an absolute http or https URL", opts.URL)
+	}
+	if opts.BatchSize < 0 || opts.FlushInterval < 0 || opts.MaxPerMinute < 0 || opts.MaxPending < 0 || opts.Timeout < 0 {
+		return nil, fmt.Errorf("deny webhook limits cannot be negative")
+	}
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	w := &DenyWebhook{
+		url:       opts.URL,
+		token:     opts.Token,
+		batchSize: opts.BatchSize,
+		interval:  opts.FlushInterval,
+		logger:    logger,
+	}
+	if w.batchSize == 0 {
+		w.batchSize = DefaultDenyWebhookBatchSize
+	}
+	if w.interval == 0 {
+		w.interval = DefaultDenyWebhookFlushInterval
+	}
+	perMinute := opts.MaxPerMinute
+	if perMinute == 0 {
+		perMinute = DefaultDenyWebhookMaxPerMinute
+	}
+	w.limiter = rate.NewLimiter(rate.Limit(float64(perMinute)/60), 1)
+	pending := opts.MaxPending
+	if pending == 0 {
+		pending = DefaultDenyWebhookMaxPending
+	}
+	w.queue = make(chan Denial, pending)
+	w.wake = make(chan struct{}, 1)
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultDenyWebhookTimeout
+	}
+	w.client = &http.Client{Timeout: timeout}
+	if len(opts.Actions) > 0 {
+		w.actions = make(map[string]bool, len(opts.Actions))
+		for _, action := range opts.Actions {
+			w.actions[action] = true
+		}
+	}
+	return w, nil
+}
+
+// Notify queues a denial to be sent if its action is one of the configured
+// actions. Notifying a nil DenyWebhook does nothing.
+func (w *DenyWebhook) Notify(d Denial) {
+	if w == nil || (w.actions != nil && !w.actions[d.Action]) {
+		return
+	}
+	select {
+	case w.queue <- d:
+	default:
+		w.mu.Lock()
+		w.dropped++
+		w.mu.Unlock()
+		return
+	}
+	if len(w.queue) >= w.batchSize {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start sends the queued denials in the background until ctx is cancelled,
+// then sends the denials still queued, regardless of the rate limit.
+func (w *DenyWebhook) Start(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				for len(w.queue) > 0 {
+					w.send(w.take())
+				}
+				return
+			case <-ticker.C:
+			case <-w.wake:
+			}
+			for len(w.queue) > 0 && w.limiter.Allow() {
+				w.send(w.take())
+			}
+		}
+	}()
+}
+
+// take removes up to a batch of denials from the queue.
+func (w *DenyWebhook) take() []Denial {
+	batch := make([]Denial, 0, w.batchSize)
+	for len(batch) < w.batchSize {
+		select {
+		case d := <-w.queue:
+			batch = append(batch, d)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// send POSTs a batch of denials, with the number of denials dropped since the
+// previous batch. A batch being sent when the webhook stops is completed,
+// within the request timeout.
+func (w *DenyWebhook) send(denials []Denial) {
+	if len(denials) == 0 {
+		return
+	}
+	w.mu.Lock()
+	dropped := w.dropped
+	w.dropped = 0
+	w.mu.Unlock()
+	if dropped > 0 {
+		w.logger.Warn("Deny webhook dropped denials",
+			logging.F("url", w.url),
+			logging.F("dropped", dropped))
+	}
+
+	body, err := json.Marshal(DenyWebhookBatch{SentAt: time.Now().UTC(), Denials: denials, Dropped: dropped})
+	if err != nil {
+		w.logger.Error("Failed to encode deny webhook batch",
+			logging.F("error", err.Error()))
+		w.recordResult(err.Error())
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		w.logger.Error("Failed to create deny webhook request",
+			logging.F("url", w.url),
+			logging.F("error", err.Error()))
+		w.recordResult(err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		w.logger.Warn("Deny webhook request failed",
+			logging.F("url", w.url),
+			logging.F("denials", len(denials)),
+			logging.F("error", err.Error()))
+		w.recordResult(err.Error())
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		w.logger.Warn("Deny webhook rejected batch",
+			logging.F("url", w.url),
+			logging.F("denials", len(denials)),
+			logging.F("status", resp.StatusCode))
+		w.recordResult(fmt.Sprintf("rejected with status %d", resp.StatusCode))
+		return
+	}
+	w.recordResult("")
+	w.logger.Debug("Sent deny webhook batch",
+		logging.F("url", w.url),
+		logging.F("denials", len(denials)))
+}
+
+// recordResult records the outcome of sending a batch: the error, or "" if
+// the endpoint accepted it.
+func (w *DenyWebhook) recordResult(errText string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if errText != "" {
+		w.lastError, w.lastErrorAt = errText, time.Now()
+	} else {
+		w.lastSuccess = time.Now()
+	}
+}
+
+// componentStatus returns the health of the webhook as a connectivity
+// component: down if the last batch failed.
+func (w *DenyWebhook) componentStatus() ComponentStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := ComponentStatus{Component: ComponentConnectivity, Name: "deny webhook", Target: w.url}
+	setOutcome(&status, w.lastSuccess, w.lastError, w.lastErrorAt)
+	return status
+}
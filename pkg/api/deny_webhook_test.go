@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver returns a server recording the batches POSTed to it, and
+// the channel they are delivered on.
+func webhookReceiver(t *testing.T) (*httptest.Server, chan DenyWebhookBatch) {
+	t.Helper()
+	batches := make(chan DenyWebhookBatch, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer hook-token", r.Header.Get("Authorization"))
+		var batch DenyWebhookBatch
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		batches <- batch
+	}))
+	t.Cleanup(srv.Close)
+	return srv, batches
+}
+
+// nextBatch returns the next batch received, failing after a second.
+func nextBatch(t *testing.T, batches chan DenyWebhookBatch) DenyWebhookBatch {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(time.Second):
+		t.Fatal("no deny webhook batch received")
+		return DenyWebhookBatch{}
+	}
+}
+
+func TestDenyWebhook_Batching(t *testing.T) {
+	srv, batches := webhookReceiver(t)
+	hook, err := NewDenyWebhook(DenyWebhookOptions{
+		URL:           srv.URL,
+		Actions:       []string{"wallet-provider"},
+		Token:         "hook-token",
+		BatchSize:     3,
+		FlushInterval: 10 * time.Millisecond,
+		MaxPerMinute:  6000,
+		MaxPending:    5,
+	}, logging.NewLogger(logging.DebugLevel))
+	require.NoError(t, err)
+
+	// Denials of other actions are not sent
+	hook.Notify(Denial{SubjectID: "other", Action: "issuer"})
+	for i := 1; i <= 8; i++ {
+		hook.Notify(Denial{SubjectID: fmt.Sprintf("wallet-%d", i), Action: "wallet-provider"})
+	}
+
+	// Five are pending before the webhook starts, the rest are dropped
+	ctx, cancel := context.WithCancel(context.Background())
+	hook.Start(ctx)
+	first := nextBatch(t, batches)
+	require.Len(t, first.Denials, 3)
+	assert.Equal(t, "wallet-1", first.Denials[0].SubjectID)
+	assert.Equal(t, 3, first.Dropped)
+	second := nextBatch(t, batches)
+	require.Len(t, second.Denials, 2)
+	assert.Zero(t, second.Dropped)
+
+	// Denials still queued are sent on shutdown
+	hook.Notify(Denial{SubjectID: "wallet-9", Action: "wallet-provider"})
+	cancel()
+	last := nextBatch(t, batches)
+	require.Len(t, last.Denials, 1)
+	assert.Equal(t, "wallet-9", last.Denials[0].SubjectID)
+
+	// A nil webhook does nothing
+	var none *DenyWebhook
+	none.Notify(Denial{SubjectID: "ignored"})
+	none.Start(context.Background())
+
+	_, err = NewDenyWebhook(DenyWebhookOptions{URL: "/relative"}, nil)
+	assert.Error(t, err)
+}
+
+func TestDenyWebhook_RateLimit(t *testing.T) {
+	srv, batches := webhookReceiver(t)
+	hook, err := NewDenyWebhook(DenyWebhookOptions{
+		URL:           srv.URL,
+		Token:         "hook-token",
+		BatchSize:     1,
+		FlushInterval: 10 * time.Millisecond,
+		MaxPerMinute:  1,
+	}, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hook.Start(ctx)
+
+	hook.Notify(Denial{SubjectID: "first"})
+	hook.Notify(Denial{SubjectID: "second"})
+	assert.Equal(t, "first", nextBatch(t, batches).Denials[0].SubjectID)
+
+	// The second batch waits for the next minute
+	select {
+	case batch := <-batches:
+		t.Fatalf("rate limit exceeded by batch %+v", batch)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestAuthZENDecisionHandler_DenyWebhook(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "wallet.example.com")
+	require.NoError(t, err)
+
+	srv, batches := webhookReceiver(t)
+	hook, err := NewDenyWebhook(DenyWebhookOptions{URL: srv.URL, Token: "hook-token", Actions: []string{"wallet-provider"}, BatchSize: 1}, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hook.Start(ctx)
+
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.DenyWebhook = hook
+	serverCtx.Unlock()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(
+		`{"subject":{"type":"key","id":"wallet"},"resource":{"type":"x5c","id":"wallet","key":["`+leaf.Base64()+`"]},"action":{"name":"wallet-provider"}}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	batch := nextBatch(t, batches)
+	require.Len(t, batch.Denials, 1)
+	assert.Equal(t, "wallet", batch.Denials[0].SubjectID)
+	assert.Equal(t, "wallet-provider", batch.Denials[0].Action)
+	assert.Equal(t, ReasonUnknownAuthority, batch.Denials[0].Reason)
+}
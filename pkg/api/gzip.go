@@ -0,0 +1,115 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipResponseWriter wraps a gin.ResponseWriter and compresses the response body.
+// The gzip stream is started lazily on the first write so that responses without
+// a body (304 Not Modified, 204 No Content) are passed through untouched.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+}
+
+// start prepares the response headers and creates the gzip writer. It returns
+// false if the response status does not permit a body.
+func (w *gzipResponseWriter) start() bool {
+	if w.gz != nil {
+		return true
+	}
+	status := w.Status()
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	weakenETag(h)
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	return true
+}
+
+// Write compresses b into the response body.
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.start() {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// WriteString compresses s into the response body.
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// GzipMiddleware returns a Gin middleware that gzip-compresses response bodies for
+// clients that advertise gzip support in Accept-Encoding. It is intended for
+// endpoints returning large JSON documents such as /info and /tsls.
+//
+// Compressed responses carry "Vary: Accept-Encoding", and any strong ETag set by the
+// handler is converted to a weak one since the encoded bytes differ from the
+// identity representation.
+//
+// Example usage:
+//
+//	router.GET("/info", GzipMiddleware(), InfoHandler(serverCtx))
+func GzipMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = gw
+		defer func() {
+			if gw.gz != nil {
+				_ = gw.gz.Close()
+			} else {
+				// No body was written (e.g. 304); keep the validator consistent
+				// with the one sent on the compressed 200 response.
+				weakenETag(gw.Header())
+			}
+			c.Writer = gw.ResponseWriter
+		}()
+
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header value allows gzip.
+// Codings explicitly disabled with q=0 are not accepted.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		disabled := false
+		for _, param := range params[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == "q=0" || strings.HasPrefix(param, "q=0.") && strings.Trim(param[4:], "0") == "" {
+				disabled = true
+			}
+		}
+		if !disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// weakenETag converts a strong ETag response header into a weak one.
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
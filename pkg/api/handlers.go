@@ -1,27 +1,42 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"time"
 
 	"crypto/x509"
 
+	"github.com/SUNET/g119612/pkg/etsi119612"
 	"github.com/SUNET/go-trust/pkg/authzen"
 	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/registry"
+	"github.com/SUNET/go-trust/pkg/registry/etsi"
 	"github.com/SUNET/go-trust/pkg/utils/x509util"
 	"github.com/gin-gonic/gin"
 )
 
 // StatusHandler godoc
 // @Summary Get server status (DEPRECATED - use GET /readyz)
-// @Description Returns the current server status including TSL count and last processing time
+// @Description Returns the current server status including TSL count, last processing time, the
+// @Description generation of the installed certificate pool and reports of the most recent pipeline
+// @Description runs (per-step timings, items, warnings, errors)
+// @Description
+// @Description Responses carry ETag and Last-Modified headers; conditional requests using
+// @Description If-None-Match or If-Modified-Since receive 304 Not Modified when nothing changed.
 // @Description
 // @Description DEPRECATED: This endpoint is deprecated. Use GET /readyz for health checks.
 // @Tags Status
 // @Deprecated true
 // @Produce json
-// @Success 200 {object} map[string]interface{} "tsl_count, last_processed"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param If-Modified-Since header string false "Last-Modified from a previous response"
+// @Success 200 {object} map[string]interface{} "tsl_count, last_processed, pool_generation, runs"
+// @Success 304 "Not modified since the cached copy"
 // @Router /status [get]
 func StatusHandler(serverCtx *ServerContext) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -32,6 +47,17 @@ func StatusHandler(serverCtx *ServerContext) gin.HandlerFunc {
 
 		serverCtx.RLock()
 		defer serverCtx.RUnlock()
+
+		// Failed runs change the run history without touching LastProcessed
+		var latestRun time.Time
+		if len(serverCtx.RunHistory) > 0 {
+			latestRun = serverCtx.RunHistory[0].Start
+		}
+		etag := contextETag("status", serverCtx.PipelineContext, serverCtx.LastProcessed, latestRun.UnixNano())
+		if checkNotModified(c, etag, serverCtx.LastProcessed) {
+			return
+		}
+
 		tslCount := 0
 		if serverCtx.PipelineContext != nil && serverCtx.PipelineContext.TSLs != nil {
 			tslCount = serverCtx.PipelineContext.TSLs.Size()
@@ -43,9 +69,16 @@ func StatusHandler(serverCtx *ServerContext) gin.HandlerFunc {
 			logging.F("tsl_count", tslCount),
 			logging.F("replacement", "GET /readyz"))
 
+		runs := serverCtx.RunHistory
+		if runs == nil {
+			runs = []*pipeline.RunReport{}
+		}
+
 		c.JSON(200, gin.H{
-			"tsl_count":      tslCount,
-			"last_processed": serverCtx.LastProcessed.Format("2006-01-02T15:04:05Z07:00"),
+			"tsl_count":       tslCount,
+			"last_processed":  serverCtx.LastProcessed.Format("2006-01-02T15:04:05Z07:00"),
+			"pool_generation": serverCtx.PoolGeneration(),
+			"runs":            runs,
 		})
 	}
 }
@@ -64,47 +97,253 @@ func StatusHandler(serverCtx *ServerContext) gin.HandlerFunc {
 // @Description - resource.type = "jwk" or "x5c" with resource.key containing the public key/certificates
 // @Description - resource.id MUST equal subject.id
 // @Description - action (optional) with name = the role being validated
+// @Description
+// @Description An OpenID Federation entity is evaluated with resource.type = "entity", subject.type =
+// @Description "entity" (or "key") and its entity identifier URL as subject.id and resource.id; no
+// @Description resource.key is needed. Such requests are routed to the OpenID Federation registry,
+// @Description which returns the resolved trust chain (trust_chain, trust_anchor) and the entity's
+// @Description metadata in context.reason.
+// @Description
+// @Description The request context may carry "tenant" and "purpose" identifiers. When a tenant
+// @Description allow-list is configured, requests whose tenant, purpose or action is not allowed
+// @Description are denied without evaluation. Tenant and purpose are recorded in the decision log.
+// @Description The tenant may also be selected with the X-Tenant header. Tenants configured with
+// @Description their own pipeline are evaluated against that pipeline's certificate pool.
+// @Description
+// @Description A positive decision for an ETSI TSL chain returns context.reason.trust_service: the
+// @Description provider, service, service type and status URIs, territory and source of the TSL
+// @Description listing the trust anchor, and the SHA-256 fingerprint of the trust anchor.
+// @Description
+// @Description With "qualification": true in the request context, a positive decision for an ETSI
+// @Description TSL chain also returns context.reason.qualification, classifying the trust service the
+// @Description chain is anchored in as "qualified" or "non-qualified" from its service type and status.
+// @Description
+// @Description With "territories": ["SE"] in the request context, or territories configured for the
+// @Description tenant, a chain is only trusted if its trust anchor is listed in a TSL of one of those
+// @Description scheme territories; otherwise the decision is false with a "territory mismatch" reason.
+// @Description
+// @Description Decisions made against a pipeline's certificate pool report the generation of that
+// @Description pool in context.pool_generation, which increases with every successful pipeline run.
+// @Description
+// @Description Certificates on the configured override deny-list are distrusted, also as trust anchors
+// @Description of a verified chain, and leaf certificates on the allow-list are trusted regardless of
+// @Description the registries. Such decisions report "override" and "fingerprint" in context.reason.
+// @Description
+// @Description Each decision has a deadline (server.decision_timeout, 10s by default). A request not
+// @Description decided in time is denied with a "decision timeout" reason; the evaluation of a request
+// @Description whose client disconnects is abandoned.
+// @Description
+// @Description With server.server_timing enabled, responses carry a Server-Timing header with the time
+// @Description spent in each phase of the decision (bind, parse, lookup, policy, verify), in milliseconds.
+// @Description
+// @Description With server.max_concurrent_evaluations set, requests beyond that many evaluations in
+// @Description progress are answered at once with a 503 problem and a Retry-After header.
+// @Description
+// @Description With security.client_binding, requests of the listed actions whose resource.key leaf is not the
+// @Description TLS client certificate of the caller (leaf), or not issued by its CA (chain), are denied.
+// @Description
+// @Description With security.name_matching, requests whose subject.id matches no subject alternative name
+// @Description (DNS, URI or email) of the resource.key leaf are denied with the "name_mismatch" reason code.
+// @Description
+// @Description Requests that cannot be evaluated are answered with an RFC 9457 problem
+// @Description (application/problem+json) instead of a decision: 400 for malformed JSON, requests
+// @Description failing Trust Registry Profile validation and unparseable or empty resource.key,
+// @Description 503 when no trust data is loaded yet, and 500 when evaluation fails. Requests that
+// @Description are understood but not trusted are answered with 200 and decision false.
 // @Tags AuthZEN
 // @Accept json
 // @Produce json
 // @Param request body authzen.EvaluationRequest true "AuthZEN Trust Registry Evaluation Request"
+// @Param X-Tenant header string false "Tenant selecting the trust configuration"
 // @Success 200 {object} authzen.EvaluationResponse "Trust decision (decision=true for trusted, false for untrusted)"
-// @Failure 400 {object} map[string]string "Invalid request format or validation error"
+// @Failure 400 {object} Problem "Malformed request, validation error or unparseable resource.key"
+// @Failure 500 {object} Problem "Evaluation failed"
+// @Failure 503 {object} Problem "No trust data loaded, or too many concurrent evaluations"
 // @Router /evaluation [post]
 func AuthZENDecisionHandler(serverCtx *ServerContext) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		serverCtx.RLock()
+		serverTiming := serverCtx.ServerTiming
+		serverCtx.RUnlock()
+		timer, recordPhases := timeDecision(c, serverCtx, serverTiming)
+		defer recordPhases()
+
 		var req authzen.EvaluationRequest
 		if err := c.BindJSON(&req); err != nil {
+			timer.mark(PhaseBind)
 			// Log invalid request with structured logging
 			serverCtx.Logger.Error("Invalid AuthZEN request",
 				logging.F("remote_ip", c.ClientIP()),
 				logging.F("error", err.Error()))
-			c.JSON(400, gin.H{"error": "invalid request"})
+			if serverCtx.Metrics != nil {
+				serverCtx.Metrics.RecordError("invalid_request", "authzen_decision")
+			}
+			writeProblem(c, http.StatusBadRequest, "invalid request: "+err.Error())
 			return
 		}
 
+		// Requests violating the Trust Registry Profile are refused before
+		// any policy or trust decision is made
+		err := validateRequest(&req)
+		timer.mark(PhaseBind)
+		var certs []*x509.Certificate
+		if err == nil {
+			certs, err = requestKeyCertificates(&req)
+			timer.mark(PhaseParse)
+		}
+		if err != nil {
+			serverCtx.Logger.Info("Invalid AuthZEN request",
+				logging.F("remote_ip", c.ClientIP()),
+				logging.F("request_id", RequestID(c)),
+				logging.F("subject_id", req.Subject.ID),
+				logging.F("resource_type", req.Resource.Type),
+				logging.F("error", err.Error()))
+			if serverCtx.Metrics != nil {
+				serverCtx.Metrics.RecordError("invalid_request", "authzen_decision")
+			}
+			writeProblem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		contextTenant, purpose := RequestPurpose(&req)
+		tenant, tenantErr := resolveTenant(c.GetHeader(TenantHeader), contextTenant)
+
 		// Log valid request
 		serverCtx.Logger.Debug("Processing AuthZEN request",
 			logging.F("remote_ip", c.ClientIP()),
 			logging.F("subject_id", req.Subject.ID),
-			logging.F("resource_type", req.Resource.Type))
+			logging.F("resource_type", req.Resource.Type),
+			logging.F("tenant", tenant),
+			logging.F("purpose", purpose))
 
-		start := time.Now()
-
-		// Use RegistryManager if available, fallback to legacy PipelineContext
+		// Use RegistryManager if available, fallback to legacy PipelineContext.
+		// The pipeline Context is taken in the same snapshot, so a concurrent
+		// update swaps it either before or after this request, never during it.
 		serverCtx.RLock()
 		registryMgr := serverCtx.RegistryManager
+		tenantPolicy := serverCtx.TenantPolicy
+		_, hasTenantPipeline := serverCtx.TenantContexts[tenant]
+		pipelineCtx := serverCtx.TenantPipelineContext(tenant)
+		overrides := serverCtx.Overrides
+		decisionTimeout := serverCtx.DecisionTimeout
+		denials := serverCtx.Denials
+		webhook := serverCtx.DenyWebhook
+		exporter := serverCtx.Audit
+		rollup := serverCtx.Analytics
+		userMessages := serverCtx.UserMessages
+		staged := serverCtx.StagedPolicy
+		binding := serverCtx.ClientBinding
+		names := serverCtx.NameMatcher
 		serverCtx.RUnlock()
+		timer.mark(PhaseLookup)
 
-		var resp *authzen.EvaluationResponse
-		var evalErr error
+		var generation uint64
+		if pipelineCtx != nil {
+			generation = pipelineCtx.Generation
+		}
 
-		if registryMgr != nil {
-			// New architecture: use RegistryManager
-			resp, evalErr = registryMgr.Evaluate(c.Request.Context(), &req)
-		} else {
-			// Legacy architecture: use direct validation (backward compatibility)
-			resp, evalErr = legacyEvaluate(serverCtx, &req)
+		tenantLabel, purposeLabel := tenantPolicy.Labels(tenant, purpose)
+		labels := DecisionLabels{
+			Action:       actionName(&req),
+			ResourceType: req.Resource.Type,
+			Tenant:       tenantLabel,
+			Purpose:      purposeLabel,
+		}
+
+		sources := decisionSources{
+			registryMgr:       registryMgr,
+			pipelineCtx:       pipelineCtx,
+			hasTenantPipeline: hasTenantPipeline,
+		}
+
+		// Requests of a caller asking about a key other than its own, where
+		// the action binds them to the caller, and requests naming a key by
+		// a name its certificate is not issued to, are denied under any policy
+		callerErr := tenantErr
+		if callerErr == nil {
+			callerErr = binding.Check(c.Request.TLS, labels.Action, certs)
+		}
+		if callerErr == nil {
+			callerErr = names.Check(&req, labels.Action, certs)
+		}
+		shadow := staged.shadow(c, serverCtx, &req, certs, tenant, purpose, callerErr, sources, decisionTimeout)
+		setAuditRequest(exporter, c, &req, tenant)
+
+		// Requests from tenants outside the allow-list are denied without evaluation
+		err, denyReason := callerErr, policyReason(callerErr)
+		if err == nil {
+			err = tenantPolicy.Check(tenant, purpose, labels.Action)
+		}
+		if err == nil {
+			// The tenant's territories restrict those the request may ask for
+			if err = restrictTerritories(&req, tenantPolicy.Territories(tenant)); err != nil {
+				denyReason = reasonCodeFor(err.Error())
+			}
+		}
+		if err != nil {
+			serverCtx.Logger.Info("AuthZEN request denied",
+				logging.F("remote_ip", c.ClientIP()),
+				logging.F("subject_id", req.Subject.ID),
+				logging.F("resource_type", req.Resource.Type),
+				logging.F("action", labels.Action),
+				logging.F("tenant", tenant),
+				logging.F("purpose", purpose),
+				logging.F("reason", denyReason),
+				logging.F("error", err.Error()))
+
+			labels.Reason = denyReason
+			if serverCtx.Metrics != nil {
+				serverCtx.Metrics.RecordDecision(labels)
+			}
+			denial := newDenial(c, &req, labels, err.Error())
+			denial.Generation = generation
+			denials.Record(denial)
+			webhook.Notify(denial)
+
+			resp := buildResponse(false, err.Error())
+			setUserReason(c, &resp, userMessages, denyReason)
+			auditDecision(exporter, c, &req, labels, &resp, err.Error(), generation, 0)
+			recordAnalytics(rollup, serverCtx.Metrics, labels, &resp)
+			shadow.compare(&resp, denyReason)
+			timer.mark(PhasePolicy)
+			c.JSON(200, resp)
+			return
+		}
+		timer.mark(PhasePolicy)
+
+		start := time.Now()
+
+		// Every stage of the evaluation ends when the decision deadline
+		// passes or the client disconnects
+		decisionCtx, cancel := decisionContext(c.Request.Context(), decisionTimeout)
+		defer cancel()
+
+		resp, evalErr := evaluateDecision(decisionCtx, sources, overrides, &req, certs, timer)
+
+		if err := decisionCtx.Err(); err != nil {
+			abandonDecision(c, serverCtx, labels, err, decisionTimeout, time.Since(start))
+			if errors.Is(err, context.DeadlineExceeded) {
+				labels.Reason = ReasonTimeout
+				denial := newDenial(c, &req, labels, "decision timeout")
+				denial.Generation = generation
+				denial.DurationMS = time.Since(start).Milliseconds()
+				denials.Record(denial)
+				webhook.Notify(denial)
+				auditDecision(exporter, c, &req, labels, nil, "decision timeout", generation, time.Since(start))
+				recordAnalytics(rollup, serverCtx.Metrics, labels, nil)
+			}
+			return
+		}
+
+		if kind, fp := overrideOf(resp); evalErr == nil && kind != "" {
+			serverCtx.Logger.Warn("Decision override applied",
+				logging.F("remote_ip", c.ClientIP()),
+				logging.F("request_id", RequestID(c)),
+				logging.F("subject_id", req.Subject.ID),
+				logging.F("resource_type", req.Resource.Type),
+				logging.F("tenant", tenant),
+				logging.F("override", kind),
+				logging.F("fingerprint", fp))
 		}
 
 		validationDuration := time.Since(start)
@@ -113,22 +352,42 @@ func AuthZENDecisionHandler(serverCtx *ServerContext) gin.HandlerFunc {
 			serverCtx.Logger.Error("AuthZEN evaluation error",
 				logging.F("remote_ip", c.ClientIP()),
 				logging.F("subject_id", req.Subject.ID),
+				logging.F("tenant", tenant),
+				logging.F("purpose", purpose),
 				logging.F("error", evalErr.Error()))
 
 			// Record error metrics
+			status := problemStatus(evalErr)
+			labels.Reason = ReasonError
+			if status == http.StatusServiceUnavailable {
+				labels.Reason = ReasonUnavailable
+			}
 			if serverCtx.Metrics != nil {
 				serverCtx.Metrics.RecordError("evaluation_error", "authzen_decision")
+				serverCtx.Metrics.RecordDecision(labels)
 			}
+			auditDecision(exporter, c, &req, labels, nil, evalErr.Error(), generation, validationDuration)
+			recordAnalytics(rollup, serverCtx.Metrics, labels, nil)
+			shadow.compare(nil, labels.Reason)
 
-			c.JSON(500, buildResponse(false, evalErr.Error()))
+			writeProblem(c, status, evalErr.Error())
 			return
 		}
 
+		labels.Decision = resp.Decision
+		labels.Reason = DecisionReasonCode(resp)
+		if serverCtx.Metrics != nil {
+			serverCtx.Metrics.RecordDecision(labels)
+		}
+
 		if resp.Decision {
 			serverCtx.Logger.Info("AuthZEN request approved",
 				logging.F("remote_ip", c.ClientIP()),
 				logging.F("subject_id", req.Subject.ID),
 				logging.F("resource_type", req.Resource.Type),
+				logging.F("action", labels.Action),
+				logging.F("tenant", tenant),
+				logging.F("purpose", purpose),
 				logging.F("duration_ms", validationDuration.Milliseconds()))
 
 			// Record successful validation metrics
@@ -140,112 +399,265 @@ func AuthZENDecisionHandler(serverCtx *ServerContext) gin.HandlerFunc {
 				logging.F("remote_ip", c.ClientIP()),
 				logging.F("subject_id", req.Subject.ID),
 				logging.F("resource_type", req.Resource.Type),
+				logging.F("action", labels.Action),
+				logging.F("tenant", tenant),
+				logging.F("purpose", purpose),
+				logging.F("reason", labels.Reason),
 				logging.F("duration_ms", validationDuration.Milliseconds()))
 
 			// Record failed validation metrics
 			if serverCtx.Metrics != nil {
 				serverCtx.Metrics.RecordCertValidation(validationDuration, false)
 			}
+			denial := newDenial(c, &req, labels, reasonMessage(resp))
+			if resp.Context != nil && resp.Context.PoolGeneration > 0 {
+				denial.Generation = resp.Context.PoolGeneration
+			}
+			denial.DurationMS = validationDuration.Milliseconds()
+			denials.Record(denial)
+			webhook.Notify(denial)
+		}
+		if resp.Context != nil && resp.Context.PoolGeneration > 0 {
+			generation = resp.Context.PoolGeneration
 		}
+		setUserReason(c, resp, userMessages, labels.Reason)
+		auditDecision(exporter, c, &req, labels, resp, reasonMessage(resp), generation, validationDuration)
+		recordAnalytics(rollup, serverCtx.Metrics, labels, resp)
+		shadow.compare(resp, labels.Reason)
 
 		c.JSON(200, resp)
 	}
 }
 
-// legacyEvaluate implements the old direct CertPool validation for backward compatibility
-func legacyEvaluate(serverCtx *ServerContext, req *authzen.EvaluationRequest) (*authzen.EvaluationResponse, error) {
-	// Validate request against AuthZEN Trust Registry Profile
-	if err := req.Validate(); err != nil {
-		return &authzen.EvaluationResponse{
-			Decision: false,
-			Context: &authzen.EvaluationResponseContext{
-				Reason: map[string]interface{}{
-					"error": fmt.Sprintf("validation error: %v", err),
-				},
-			},
-		}, nil
+// decisionSources are the trust sources a decision is made against, taken in
+// one snapshot of the ServerContext.
+type decisionSources struct {
+	registryMgr       *registry.RegistryManager
+	pipelineCtx       *pipeline.Context // Pipeline Context of the tenant, or the default one
+	hasTenantPipeline bool              // The tenant has a pipeline of its own
+}
+
+// evaluateDecision decides whether the resource of req, a request allowed by
+// the tenant policy with the certificates certs, is trusted by sources, with
+// the deny- and allow-lists of overrides applied. The phases of the decision
+// are marked in timer. If ctx is done before a decision is made, the caller
+// must abandon the decision.
+func evaluateDecision(ctx context.Context, sources decisionSources, overrides *Overrides, req *authzen.EvaluationRequest, certs []*x509.Certificate, timer *decisionTimer) (*authzen.EvaluationResponse, error) {
+	var resp *authzen.EvaluationResponse
+	var evalErr error
+
+	// Entity resources are only evaluated by registries. Key resources go
+	// to the registries if one supports their type and the tenant has no
+	// pipeline of its own.
+	entityRequest := req.Resource.Type == authzen.ResourceTypeEntity
+	registryMgr, pipelineCtx := sources.registryMgr, sources.pipelineCtx
+
+	// Presented certificates on the deny-list are distrusted without
+	// evaluation
+	if fp, ok := overrides.Denied(certs); ok {
+		resp = overrideResponse(OverrideDeny, fp)
+		timer.mark(PhasePolicy)
+	} else if registryMgr != nil && registryMgr.Supports(req.Resource.Type) && (entityRequest || !sources.hasTenantPipeline) {
+		// New architecture: use RegistryManager
+		resp, evalErr = registryMgr.Evaluate(ctx, req)
+	} else {
+		// Tenants with their own pipeline and the legacy architecture use
+		// direct validation against the pipeline's certificate pool
+		resp, evalErr = legacyEvaluate(ctx, pipelineCtx, overrides, req, certs)
+		if evalErr == nil && pipelineCtx != nil && pipelineCtx.Generation > 0 {
+			if resp.Context == nil {
+				resp.Context = &authzen.EvaluationResponseContext{}
+			}
+			resp.Context.PoolGeneration = pipelineCtx.Generation
+		}
+	}
+	timer.mark(PhaseVerify)
+	if ctx.Err() != nil {
+		return resp, evalErr
 	}
 
-	// Extract certificates from resource.key based on resource.type
-	var certs []*x509.Certificate
-	var parseErr error
+	// A valid request for an allow-listed leaf certificate is trusted
+	// even if no registry trusts it, unless the deny-list distrusts it
+	if evalErr == nil && !resp.Decision && len(certs) > 0 {
+		if kind, _ := overrideOf(resp); kind != OverrideDeny {
+			if fp, ok := overrides.Allowed(certs[0]); ok {
+				var overridden map[string]interface{}
+				if resp.Context != nil {
+					overridden = resp.Context.Reason
+				}
+				resp = overrideResponse(OverrideAllow, fp)
+				resp.Context.Reason["overridden_reason"] = overridden
+			}
+		}
+	}
+	timer.mark(PhasePolicy)
+	return resp, evalErr
+}
 
+// actionName returns the action name of an AuthZEN request, or "" if the
+// request has no action.
+func actionName(req *authzen.EvaluationRequest) string {
+	if req.Action == nil {
+		return ""
+	}
+	return req.Action.Name
+}
+
+// requestCertificates returns the certificates in resource.key of a request,
+// leaf first: an array of base64-encoded X.509 certificates for resource.type
+// "x5c", otherwise the x5c claim of a JWK.
+func requestCertificates(req *authzen.EvaluationRequest) ([]*x509.Certificate, error) {
 	if req.Resource.Type == "x5c" {
-		// resource.key is an array of base64-encoded X.509 certificates
-		certs, parseErr = x509util.ParseX5CFromArray(req.Resource.Key)
-	} else {
-		// resource.type == "jwk" - extract certificate from JWK x5c claim
-		certs, parseErr = x509util.ParseX5CFromJWK(req.Resource.Key)
+		return x509util.ParseX5CFromArray(req.Resource.Key)
 	}
+	return x509util.ParseX5CFromJWK(req.Resource.Key)
+}
 
-	if parseErr != nil {
-		return &authzen.EvaluationResponse{
-			Decision: false,
-			Context: &authzen.EvaluationResponseContext{
-				Reason: map[string]interface{}{
-					"error": parseErr.Error(),
-				},
-			},
-		}, nil
+// validateRequest validates req against the AuthZEN Trust Registry Profile
+// without parsing its resource.key. An invalid request yields a *RequestError.
+func validateRequest(req *authzen.EvaluationRequest) error {
+	if err := req.Validate(); err != nil {
+		return &RequestError{Err: fmt.Errorf("validation error: %w", err)}
 	}
+	if _, err := etsi.RequestedTerritories(req); err != nil {
+		return &RequestError{Err: fmt.Errorf("validation error: %w", err)}
+	}
+	return nil
+}
 
+// requestKeyCertificates returns the certificates in resource.key of a
+// validated request, leaf first; entity requests have none. An unparseable or
+// empty resource.key yields a *RequestError.
+func requestKeyCertificates(req *authzen.EvaluationRequest) ([]*x509.Certificate, error) {
+	if req.Resource.Type == authzen.ResourceTypeEntity {
+		return nil, nil
+	}
+
+	certs, err := requestCertificates(req)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
 	if len(certs) == 0 {
+		return nil, &RequestError{Err: errors.New("no certificates found in resource.key")}
+	}
+	return certs, nil
+}
+
+// legacyEvaluate implements the old direct CertPool validation for backward compatibility.
+// It validates certs, the certificates of req checked by checkRequest, against
+// the certificate pool of pipelineCtx, the snapshot of the tenant's pipeline
+// Context, or of the default one if the tenant has none. Chains through a
+// certificate on the deny-list of overrides are not trusted.
+// If there is no certificate pool, ErrNoTrustData is returned; if ctx is done
+// before the chain is verified, ctx.Err() is returned.
+func legacyEvaluate(ctx context.Context, pipelineCtx *pipeline.Context, overrides *Overrides, req *authzen.EvaluationRequest, certs []*x509.Certificate) (*authzen.EvaluationResponse, error) {
+	// Entities are resolved by an OpenID Federation registry, not from TSLs
+	if req.Resource.Type == authzen.ResourceTypeEntity {
 		return &authzen.EvaluationResponse{
 			Decision: false,
 			Context: &authzen.EvaluationResponseContext{
 				Reason: map[string]interface{}{
-					"error": "no certificates found in resource.key",
+					"error":         "no applicable registries for resource type",
+					"resource_type": req.Resource.Type,
 				},
 			},
 		}, nil
 	}
 
-	// Validate certificate chain against TSL certificate pool
-	serverCtx.RLock()
-	certPool := serverCtx.PipelineContext.CertPool
-	serverCtx.RUnlock()
+	territories, err := etsi.RequestedTerritories(req)
+	if err != nil {
+		return nil, &RequestError{Err: fmt.Errorf("validation error: %w", err)}
+	}
+	if len(certs) == 0 {
+		return nil, &RequestError{Err: errors.New("no certificates found in resource.key")}
+	}
 
+	// Validate certificate chain against TSL certificate pool
+	var certPool *x509.CertPool
+	if pipelineCtx != nil {
+		certPool = pipelineCtx.CertPool
+	}
 	if certPool == nil {
-		return &authzen.EvaluationResponse{
-			Decision: false,
-			Context: &authzen.EvaluationResponseContext{
-				Reason: map[string]interface{}{
-					"error": "CertPool is nil",
-				},
-			},
-		}, nil
+		return nil, ErrNoTrustData
 	}
 
 	opts := x509.VerifyOptions{
 		Roots: certPool,
 	}
-	_, err := certs[0].Verify(opts)
-
-	if err == nil {
-		resp := buildResponse(true, "")
-		return &resp, nil
-	} else {
+	chains, err := x509util.VerifyContext(ctx, certs[0], opts)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
 		resp := buildResponse(false, err.Error())
 		return &resp, nil
 	}
+
+	// Chains through a certificate on the deny-list, e.g. a compromised CA,
+	// are not trusted
+	chains, denied := overrides.trustedChains(chains)
+	if len(chains) == 0 {
+		return overrideResponse(OverrideDeny, denied), nil
+	}
+
+	// A trusted chain must also be anchored in an allowed territory, if requested
+	chain := chains[0]
+	if len(territories) > 0 {
+		chain, err = pipelineCtx.SelectChainByTerritory(chains, territories)
+		if err != nil {
+			resp := buildResponse(false, err.Error())
+			return &resp, nil
+		}
+	}
+
+	// Attribute the decision to the trust service the chain is anchored in
+	resp := buildResponse(true, "")
+	reason := map[string]interface{}{}
+	if ts := pipelineCtx.MatchTrustService(chain, territories); ts != nil {
+		reason[etsi.ReasonKeyTrustService] = ts
+	}
+	if etsi.QualificationRequested(req) {
+		reason[etsi.ContextKeyQualification] = pipelineCtx.QualifyChain(chain)
+	}
+	if len(reason) > 0 {
+		resp.Context = &authzen.EvaluationResponseContext{Reason: reason}
+	}
+	return &resp, nil
 }
 
 // InfoHandler godoc
 // @Summary Get TSL information (DEPRECATED - use GET /tsls)
 // @Description Returns detailed summaries of all loaded Trust Status Lists
 // @Description
+// @Description Responses carry ETag and Last-Modified headers; conditional requests using
+// @Description If-None-Match or If-Modified-Since receive 304 Not Modified when nothing changed.
+// @Description
 // @Description DEPRECATED: This endpoint is deprecated. Use GET /tsls instead.
 // @Description
+// @Description Large result sets can be paged with offset/limit and trimmed with fields=.
+// @Description Responses are gzip-compressed when the client sends Accept-Encoding: gzip.
+// @Description
 // @Description This endpoint provides comprehensive information about each TSL including:
 // @Description - Territory code
 // @Description - Sequence number
 // @Description - Issue date
 // @Description - Next update date
 // @Description - Number of services
+// @Description - Signature verification status, if the load step verified signatures
+// @Description - Source URL, distribution points and whether the TSL was loaded from one of them
+// @Description - Scheme operator electronic addresses and pointers to other TSLs
 // @Tags Status
 // @Deprecated true
 // @Produce json
-// @Success 200 {object} map[string]interface{} "tsl_summaries"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param If-Modified-Since header string false "Last-Modified from a previous response"
+// @Param offset query int false "Number of TSL summaries to skip"
+// @Param limit query int false "Maximum number of TSL summaries to return (0 = all)"
+// @Param fields query string false "Comma-separated summary keys to include (e.g. scheme_operator_name,num_trust_service_providers)"
+// @Param territory query string false "Comma-separated scheme territories to include (e.g. SE,EL); GR and GB select EL and UK"
+// @Success 200 {object} map[string]interface{} "tsl_summaries, total"
+// @Success 304 "Not modified since the cached copy"
+// @Failure 400 {object} map[string]string "Invalid pagination or territory parameters"
 // @Router /info [get]
 func InfoHandler(serverCtx *ServerContext) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -256,6 +668,24 @@ func InfoHandler(serverCtx *ServerContext) gin.HandlerFunc {
 
 		serverCtx.RLock()
 		defer serverCtx.RUnlock()
+
+		langs := requestLanguages(c)
+		etag := contextETag("info", serverCtx.PipelineContext, serverCtx.LastProcessed, langs)
+		if checkNotModified(c, etag, serverCtx.LastProcessed) {
+			return
+		}
+
+		params, err := parseListParams(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		territories, err := requestTerritories(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
 		summaries := make([]map[string]interface{}, 0)
 
 		// Add debug logging to inspect the pipeline context
@@ -269,23 +699,35 @@ func InfoHandler(serverCtx *ServerContext) gin.HandlerFunc {
 			logging.F("tsls_nil", serverCtx.PipelineContext == nil || serverCtx.PipelineContext.TSLs == nil),
 			logging.F("tsls_size", tslSize))
 
+		var tsls []*etsi119612.TSL
 		if serverCtx.PipelineContext != nil && serverCtx.PipelineContext.TSLs != nil {
 			for _, tsl := range serverCtx.PipelineContext.TSLs.ToSlice() {
-				if tsl != nil {
-					summaries = append(summaries, tsl.Summary())
+				if tsl != nil && inTerritories(tsl, territories) {
+					tsls = append(tsls, tsl)
 				}
 			}
 		}
 
+		// Only summarize the requested page; Summary() renders the full TSL as text
+		for _, tsl := range page(tsls, params) {
+			summaries = append(summaries, params.selectFields(tslSummary(serverCtx.PipelineContext, tsl, langs)))
+		}
+
 		// Log info request with structured logging
 		serverCtx.Logger.Warn("API info request (deprecated endpoint)",
 			logging.F("remote_ip", c.ClientIP()),
 			logging.F("summary_count", len(summaries)),
 			logging.F("replacement", "GET /tsls"))
 
-		c.JSON(200, gin.H{
+		response := gin.H{
 			"tsl_summaries": summaries,
-		})
+			"total":         len(tsls),
+		}
+		if params.paginated() {
+			response["offset"] = params.Offset
+			response["limit"] = params.Limit
+		}
+		c.JSON(200, response)
 	}
 }
 
@@ -298,27 +740,40 @@ func InfoHandler(serverCtx *ServerContext) gin.HandlerFunc {
 // @Description - Sequence numbers
 // @Description - Issue and next update dates
 // @Description - Service counts per TSL
+// @Description - Source URL, distribution points and whether the TSL was loaded from one of them
+// @Description - Scheme operator electronic addresses and pointers to other TSLs
 // @Description - Last processing timestamp
+// @Description
+// @Description The territory parameter restricts the list to TSLs of the given scheme territories.
 // @Tags TSLs
 // @Produce json
+// @Param territory query string false "Comma-separated scheme territories to include (e.g. SE,EL); GR and GB select EL and UK"
 // @Success 200 {object} map[string]interface{} "count, last_updated, tsls"
+// @Failure 400 {object} map[string]string "Unknown territory code"
 // @Router /tsls [get]
 func TSLsHandler(serverCtx *ServerContext) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		serverCtx.RLock()
 		defer serverCtx.RUnlock()
 
+		territories, err := requestTerritories(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		langs := requestLanguages(c)
 		summaries := make([]map[string]interface{}, 0)
 		tslCount := 0
 		lastUpdated := serverCtx.LastProcessed.Format(time.RFC3339)
 
 		if serverCtx.PipelineContext != nil && serverCtx.PipelineContext.TSLs != nil {
-			tslCount = serverCtx.PipelineContext.TSLs.Size()
 			for _, tsl := range serverCtx.PipelineContext.TSLs.ToSlice() {
-				if tsl != nil {
-					summaries = append(summaries, tsl.Summary())
+				if tsl != nil && inTerritories(tsl, territories) {
+					summaries = append(summaries, tslSummary(serverCtx.PipelineContext, tsl, langs))
 				}
 			}
+			tslCount = len(summaries)
 		}
 
 		serverCtx.Logger.Info("API /tsls request",
@@ -376,3 +831,14 @@ func TestShutdownHandler(serverCtx *ServerContext) gin.HandlerFunc {
 		}()
 	}
 }
+
+// tslSummary returns the summary of tsl served by /info and /tsls: the
+// pipeline.TSLSummary, with the signature verification status recorded in
+// pipelineCtx as "signature" if the load step verified it.
+func tslSummary(pipelineCtx *pipeline.Context, tsl *etsi119612.TSL, langs []string) map[string]interface{} {
+	summary := pipeline.TSLSummary(tsl, langs)
+	if sig := pipelineCtx.SignatureOf(tsl); sig != nil {
+		summary["signature"] = sig
+	}
+	return summary
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+// BenchmarkAuthZENDecisionHandler benchmarks POST /evaluation end to end,
+// from the request body to the encoded decision, against certificate pools
+// of increasing size.
+func BenchmarkAuthZENDecisionHandler(b *testing.B) {
+	for _, size := range []int{100, 10000} {
+		cas, err := testutil.NewCAs("Bench CA", size)
+		if err != nil {
+			b.Fatalf("Failed to generate CAs: %v", err)
+		}
+		pool := x509.NewCertPool()
+		for _, ca := range cas {
+			pool.AddCert(ca.Certificate)
+		}
+		leaf, err := testutil.NewLeaf(cas[size-1], "alice")
+		if err != nil {
+			b.Fatalf("Failed to generate leaf: %v", err)
+		}
+
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		ctx := pipeline.NewContext()
+		ctx.CertPool = pool
+		serverCtx := &ServerContext{
+			PipelineContext: ctx,
+			LastProcessed:   time.Now(),
+			Logger:          logging.NewLogger(logging.ErrorLevel),
+			Metrics:         NewMetrics(),
+		}
+		RegisterAPIRoutes(r, serverCtx)
+
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, leaf.Base64())
+		b.Run(fmt.Sprintf("roots=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+				if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"decision":true`) {
+					b.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+}
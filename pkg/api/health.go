@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,13 +16,15 @@ type HealthResponse struct {
 
 // ReadinessResponse represents the response from the readiness endpoint
 type ReadinessResponse struct {
-	Status        string                   `json:"status"`
-	Timestamp     time.Time                `json:"timestamp"`
-	TSLCount      int                      `json:"tsl_count"`
-	LastProcessed string                   `json:"last_processed,omitempty"`
-	Ready         bool                     `json:"ready"`
-	Message       string                   `json:"message,omitempty"`
-	TSLs          []map[string]interface{} `json:"tsls,omitempty"` // Only included with ?verbose=true
+	Status         string                   `json:"status"`
+	Timestamp      time.Time                `json:"timestamp"`
+	TSLCount       int                      `json:"tsl_count"`
+	LastProcessed  string                   `json:"last_processed,omitempty"`
+	PoolGeneration uint64                   `json:"pool_generation,omitempty"` // Generation of the installed certificate pool
+	Ready          bool                     `json:"ready"`
+	Message        string                   `json:"message,omitempty"`
+	TSLs           []map[string]interface{} `json:"tsls,omitempty"`    // Only included with ?verbose=true
+	Sources        []SourceStatus           `json:"sources,omitempty"` // Reachability of upstream TSL sources
 }
 
 // RegisterHealthEndpoints registers health check endpoints on the given Gin router.
@@ -43,6 +46,11 @@ type ReadinessResponse struct {
 //
 // If these conditions are not met, it returns 503 Service Unavailable.
 //
+// The /readyz response also lists every upstream TSL source fetched by the
+// pipeline with the time and outcome of its last fetch. A source is stale if
+// the last pipeline run failed to fetch it; stale sources do not make the
+// service unready, since the TSLs fetched earlier are still served.
+//
 // Use ?verbose=true on /readyz to include detailed TSL summaries in the response.
 func RegisterHealthEndpoints(r *gin.Engine, serverCtx *ServerContext) {
 	r.GET("/healthz", HealthHandler(serverCtx))
@@ -75,6 +83,7 @@ func HealthHandler(serverCtx *ServerContext) gin.HandlerFunc {
 // ReadinessHandler godoc
 // @Summary Readiness check
 // @Description Returns ready status if pipeline has been processed and TSLs are loaded
+// @Description The response lists the upstream TSL sources with the outcome of their last fetch
 // @Description
 // @Description Query Parameters:
 // @Description - verbose=true: Include detailed TSL information in the response
@@ -99,29 +108,37 @@ func ReadinessHandler(serverCtx *ServerContext) gin.HandlerFunc {
 		if pipelineProcessed {
 			lastProcessed = serverCtx.LastProcessed.Format(time.RFC3339)
 		}
+		poolGeneration := serverCtx.PoolGeneration()
+		sources := serverCtx.SourceStatuses()
+		staleSources := 0
+		for _, source := range sources {
+			if source.Stale {
+				staleSources++
+			}
+		}
 
 		// Collect detailed TSL summaries if verbose mode requested
 		var tslSummaries []map[string]interface{}
 		if verbose && serverCtx.PipelineContext != nil && serverCtx.PipelineContext.TSLs != nil {
+			langs := requestLanguages(c)
 			for _, tsl := range serverCtx.PipelineContext.TSLs.ToSlice() {
 				if tsl != nil {
-					tslSummaries = append(tslSummaries, tsl.Summary())
+					tslSummaries = append(tslSummaries, pipeline.TSLSummary(tsl, langs))
 				}
 			}
 		}
 		serverCtx.RUnlock()
 
-		// Service is ready if:
-		// 1. Pipeline has been processed at least once
-		// 2. At least one TSL is loaded (optional but recommended)
-		isReady := pipelineProcessed && tslCount > 0
+		isReady := notReadyReason(pipelineProcessed, tslCount) == ""
 
 		response := ReadinessResponse{
-			Timestamp:     time.Now(),
-			TSLCount:      tslCount,
-			LastProcessed: lastProcessed,
-			Ready:         isReady,
-			TSLs:          tslSummaries, // Only populated if verbose=true
+			Timestamp:      time.Now(),
+			TSLCount:       tslCount,
+			LastProcessed:  lastProcessed,
+			PoolGeneration: poolGeneration,
+			Ready:          isReady,
+			TSLs:           tslSummaries, // Only populated if verbose=true
+			Sources:        sources,
 		}
 
 		if isReady {
@@ -133,16 +150,13 @@ func ReadinessHandler(serverCtx *ServerContext) gin.HandlerFunc {
 				logging.F("endpoint", c.Request.URL.Path),
 				logging.F("verbose", verbose),
 				logging.F("tsl_count", tslCount),
+				logging.F("stale_sources", staleSources),
 				logging.F("last_processed", lastProcessed))
 
 			c.JSON(200, response)
 		} else {
 			response.Status = "not_ready"
-			if !pipelineProcessed {
-				response.Message = "Pipeline has not been processed yet"
-			} else if tslCount == 0 {
-				response.Message = "No TSLs loaded yet"
-			}
+			response.Message = notReadyReason(pipelineProcessed, tslCount)
 
 			serverCtx.Logger.Warn("Readiness check failed",
 				logging.F("remote_ip", c.ClientIP()),
@@ -156,3 +170,28 @@ func ReadinessHandler(serverCtx *ServerContext) gin.HandlerFunc {
 		}
 	}
 }
+
+// Ready reports whether the service is ready to accept traffic, as GET /readyz
+// does, and if not, why.
+func (serverCtx *ServerContext) Ready() (bool, string) {
+	serverCtx.RLock()
+	defer serverCtx.RUnlock()
+	tslCount := 0
+	if serverCtx.PipelineContext != nil && serverCtx.PipelineContext.TSLs != nil {
+		tslCount = serverCtx.PipelineContext.TSLs.Size()
+	}
+	reason := notReadyReason(!serverCtx.LastProcessed.IsZero(), tslCount)
+	return reason == "", reason
+}
+
+// notReadyReason returns why the service is not ready, or "" if it is: the
+// pipeline must have been processed at least once and loaded at least one TSL.
+func notReadyReason(pipelineProcessed bool, tslCount int) string {
+	switch {
+	case !pipelineProcessed:
+		return "Pipeline has not been processed yet"
+	case tslCount == 0:
+		return "No TSLs loaded yet"
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// healthPollInterval is how often a TCP health listener checks whether the
+// service has become ready.
+var healthPollInterval = time.Second
+
+// StartHealthListener starts a bare TCP health listener on addr for
+// orchestrators whose probes only open a connection, such as the TCP checks
+// of Nomad and Consul. The listener is opened once the service is ready (see
+// ServerContext.Ready), so connections are refused until then, and answers
+// each connection with "ready\n" before closing it. Since a failing pipeline
+// run keeps the TSLs of the last successful one, the service does not become
+// unready again.
+//
+// The listener is closed when ctx is cancelled. The returned channel receives
+// the address listened on once the listener is open, which tests use with
+// port 0, and is closed without a value if it cannot be opened.
+func StartHealthListener(ctx context.Context, addr string, serverCtx *ServerContext) <-chan net.Addr {
+	opened := make(chan net.Addr, 1)
+	go func() {
+		defer close(opened)
+		if !waitReady(ctx, serverCtx) {
+			return
+		}
+		ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+		if err != nil {
+			serverCtx.Logger.Error("Failed to open TCP health listener",
+				logging.F("address", addr),
+				logging.F("error", err.Error()))
+			return
+		}
+		serverCtx.Logger.Info("TCP health listener open",
+			logging.F("address", ln.Addr().String()))
+		opened <- ln.Addr()
+
+		go func() {
+			<-ctx.Done()
+			ln.Close()
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					serverCtx.Logger.Warn("TCP health listener failed",
+						logging.F("address", addr),
+						logging.F("error", err.Error()))
+				}
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			conn.Write([]byte("ready\n"))
+			conn.Close()
+		}
+	}()
+	return opened
+}
+
+// waitReady waits until the service is ready, and reports false if ctx is
+// cancelled first.
+func waitReady(ctx context.Context, serverCtx *ServerContext) bool {
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+	for {
+		if ready, _ := serverCtx.Ready(); ready {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerContext_Ready(t *testing.T) {
+	ready, reason := createTestContext(0, time.Time{}).Ready()
+	assert.False(t, ready)
+	assert.Equal(t, "Pipeline has not been processed yet", reason)
+
+	ready, reason = createTestContext(0, time.Now()).Ready()
+	assert.False(t, ready)
+	assert.Equal(t, "No TSLs loaded yet", reason)
+
+	ready, reason = createTestContext(1, time.Now()).Ready()
+	assert.True(t, ready)
+	assert.Empty(t, reason)
+}
+
+func TestStartHealthListener(t *testing.T) {
+	saved := healthPollInterval
+	healthPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { healthPollInterval = saved })
+
+	serverCtx := createTestContext(0, time.Time{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opened := StartHealthListener(ctx, "127.0.0.1:0", serverCtx)
+
+	select {
+	case <-opened:
+		t.Fatal("the listener opened before the service was ready")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	serverCtx.Lock()
+	serverCtx.PipelineContext.TSLs.Push(&etsi119612.TSL{})
+	serverCtx.LastProcessed = time.Now()
+	serverCtx.Unlock()
+
+	var addr net.Addr
+	select {
+	case addr = <-opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the listener did not open once the service was ready")
+	}
+	require.NotNil(t, addr)
+
+	conn, err := net.Dial("tcp", addr.String())
+	require.NoError(t, err)
+	answer, err := io.ReadAll(conn)
+	conn.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "ready\n", string(answer))
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr.String())
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond, "the listener is closed with its context")
+}
+
+func TestStartHealthListener_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opened := StartHealthListener(ctx, "127.0.0.1:0", createTestContext(0, time.Time{}))
+	cancel()
+	select {
+	case addr, ok := <-opened:
+		assert.False(t, ok, "no listener is opened: %v", addr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting for readiness did not stop")
+	}
+}
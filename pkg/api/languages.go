@@ -0,0 +1,15 @@
+package api
+
+import (
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// requestLanguages returns the language preference of the request answered by
+// c: the languages of its Accept-Language header, followed by the configured
+// preference (see i18n.SetPreferredLanguages). The response is marked as
+// varying by Accept-Language.
+func requestLanguages(c *gin.Context) []string {
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	return i18n.Negotiate(c.GetHeader("Accept-Language"))
+}
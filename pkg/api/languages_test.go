@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSLsEndpoint_AcceptLanguage(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	tsl := pltesting.NewTSL().WithOperatorName("Swedish Post and Telecom Authority").Build()
+	lang := etsi119612.Lang("sv")
+	name := etsi119612.NonEmptyNormalizedString("Post- och telestyrelsen")
+	names := tsl.StatusList.TslSchemeInformation.TslSchemeOperatorName
+	names.Name = append(names.Name, &etsi119612.MultiLangNormStringType{XmlLangAttr: &lang, NonEmptyNormalizedString: &name})
+	serverCtx.Lock()
+	serverCtx.PipelineContext.TSLs.Push(tsl)
+	serverCtx.Unlock()
+
+	operatorName := func(acceptLanguage string) (string, string) {
+		req := httptest.NewRequest(http.MethodGet, "/tsls", nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Values("Vary"), "Accept-Language")
+		var resp struct {
+			TSLs []map[string]interface{} `json:"tsls"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.TSLs, 1)
+		return resp.TSLs[0]["scheme_operator_name"].(string), resp.TSLs[0]["scheme_operator_name_lang"].(string)
+	}
+
+	got, gotLang := operatorName("")
+	assert.Equal(t, "Swedish Post and Telecom Authority", got)
+	assert.Equal(t, "en", gotLang)
+
+	got, gotLang = operatorName("de;q=0.9, sv-SE;q=0.8")
+	assert.Equal(t, "Post- och telestyrelsen", got)
+	assert.Equal(t, "sv", gotLang)
+
+	// The configured preference applies when the header has no match
+	i18n.SetPreferredLanguages([]string{"sv"})
+	defer i18n.SetPreferredLanguages(nil)
+	got, _ = operatorName("fi")
+	assert.Equal(t, "Post- och telestyrelsen", got)
+	got, _ = operatorName("en")
+	assert.Equal(t, "Swedish Post and Telecom Authority", got)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shedRetryAfter is the Retry-After, in seconds, of evaluation requests
+// rejected because the concurrency limit was reached.
+const shedRetryAfter = "1"
+
+// ConcurrencyLimiter caps the number of evaluation requests processed at once.
+// Requests beyond the cap are rejected at once with 503 Service Unavailable
+// and a Retry-After header rather than queued, so that a client retrying
+// aggressively, for instance while a pipeline swaps the certificate pool,
+// cannot build up latency for every other client.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter creates a limiter allowing max requests at once.
+//
+// Example:
+//
+//	limiter := NewConcurrencyLimiter(200)
+//	router.POST("/evaluation", limiter.Middleware(metrics), handler)
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// Max returns the number of requests the limiter allows at once.
+func (l *ConcurrencyLimiter) Max() int {
+	return cap(l.slots)
+}
+
+// InFlight returns the number of requests being processed.
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots)
+}
+
+// Middleware returns a Gin middleware that enforces the limit. Rejected
+// requests are answered with a Problem and counted in metrics, if not nil.
+func (l *ConcurrencyLimiter) Middleware(metrics *Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			if metrics != nil {
+				metrics.RecordEvaluationShed()
+			}
+			c.Header("Retry-After", shedRetryAfter)
+			writeProblem(c, http.StatusServiceUnavailable,
+				fmt.Sprintf("too many concurrent evaluations (limit %d)", l.Max()))
+			return
+		}
+		defer func() { <-l.slots }()
+
+		c.Next()
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/gin-gonic/gin"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewConcurrencyLimiter(2)
+	metrics := NewMetrics()
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router := gin.New()
+	router.POST("/evaluation", limiter.Middleware(metrics), func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	// Two requests in progress fill the limit
+	done := make(chan int, 2)
+	for range 2 {
+		go func() {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", nil))
+			done <- w.Code
+		}()
+		<-entered
+	}
+	assert.Equal(t, 2, limiter.InFlight())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "too many concurrent evaluations (limit 2)")
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(metrics.EvaluationsShedTotal))
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, 0, limiter.InFlight(), "finished requests free their slot")
+}
+
+func TestNewServer_MaxConcurrentEvaluations(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.MaxConcurrentEvaluations = 1
+	_, serverCtx := setupTestServer()
+	serverCtx.Metrics = NewMetrics()
+
+	r, err := NewServer(cfg, serverCtx)
+	require.NoError(t, err)
+	require.NotNil(t, serverCtx.EvaluationLimit)
+	assert.Equal(t, 1, serverCtx.EvaluationLimit.Max())
+
+	// A request occupying the only slot sheds the next one
+	serverCtx.EvaluationLimit.slots <- struct{}{}
+	body := `{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":["` + testCertBase64 + `"]}}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// Other endpoints are not limited
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	<-serverCtx.EvaluationLimit.slots
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -2,23 +2,42 @@ package api
 
 import (
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/SUNET/go-trust/pkg/pipeline"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// MaxActionLabels is the number of distinct AuthZEN action names that are
+// reported as their own "action" label value. Action names are chosen by
+// clients, so further names are reported as "other" to bound cardinality.
+const MaxActionLabels = 50
+
 // Metrics holds all Prometheus metrics for the API server
 type Metrics struct {
 	registry *prometheus.Registry // Private registry for this metrics instance
 
+	actionsMu sync.Mutex          // Protects actions
+	actions   map[string]struct{} // Action names with their own label value
+
 	// Pipeline metrics
 	PipelineExecutionDuration prometheus.Histogram
 	PipelineExecutionTotal    prometheus.Counter
 	PipelineExecutionErrors   prometheus.Counter
 	TSLCount                  prometheus.Gauge
+	PoolGeneration            prometheus.Gauge
+	PoolCertificates          prometheus.Gauge
+	PoolCertificateListings   prometheus.Gauge
 	TSLProcessingDuration     prometheus.Histogram
+	PipelinePanicsTotal       *prometheus.CounterVec
+	PipelineStepDuration      *prometheus.HistogramVec
+	PipelineStepWarnings      *prometheus.CounterVec
+	PipelineDownloadBytes     prometheus.Histogram
+	TSLDownloadBytesTotal     prometheus.Counter
+	TSLSignatureStatus        *prometheus.GaugeVec
 
 	// API request metrics
 	APIRequestsTotal    *prometheus.CounterVec
@@ -31,6 +50,13 @@ type Metrics struct {
 	// Certificate validation metrics
 	CertValidationTotal    *prometheus.CounterVec
 	CertValidationDuration prometheus.Histogram
+
+	// Trust decision metrics
+	DecisionsTotal        *prometheus.CounterVec
+	DecisionTimeoutsTotal *prometheus.CounterVec
+	DecisionPhaseDuration *prometheus.HistogramVec
+	EvaluationsShedTotal  prometheus.Counter
+	ShadowDecisionsTotal  *prometheus.CounterVec
 }
 
 // NewMetrics creates and registers all Prometheus metrics
@@ -39,6 +65,7 @@ func NewMetrics() *Metrics {
 
 	m := &Metrics{
 		registry: registry,
+		actions:  make(map[string]struct{}),
 
 		// Pipeline metrics
 		PipelineExecutionDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
@@ -58,11 +85,61 @@ func NewMetrics() *Metrics {
 			Name: "go_trust_tsl_count",
 			Help: "Current number of loaded Trust Status Lists",
 		}),
+		PoolGeneration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "go_trust_pool_generation",
+			Help: "Generation of the most recently installed certificate pool",
+		}),
+		PoolCertificates: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "go_trust_pool_certificates",
+			Help: "Distinct certificates in the certificate pool of the default pipeline",
+		}),
+		PoolCertificateListings: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "go_trust_pool_certificate_listings",
+			Help: "TSL service listings of the certificates in the certificate pool of the default pipeline",
+		}),
 		TSLProcessingDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
 			Name:    "go_trust_tsl_processing_duration_seconds",
 			Help:    "Duration of TSL processing in seconds",
 			Buckets: prometheus.DefBuckets,
 		}),
+		PipelinePanicsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_pipeline_panics_total",
+				Help: "Total number of panics recovered in pipeline steps",
+			},
+			[]string{"step"},
+		),
+		PipelineStepDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "go_trust_pipeline_step_duration_seconds",
+				Help:    "Duration of individual pipeline steps in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"step", "status"},
+		),
+		PipelineStepWarnings: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_pipeline_step_warnings_total",
+				Help: "Total number of warnings reported by pipeline steps",
+			},
+			[]string{"step"},
+		),
+		PipelineDownloadBytes: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "go_trust_pipeline_download_bytes",
+			Help:    "Bytes of TSL documents downloaded per pipeline run",
+			Buckets: prometheus.ExponentialBuckets(64*1024, 4, 10),
+		}),
+		TSLDownloadBytesTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "go_trust_tsl_download_bytes_total",
+			Help: "Total bytes of TSL documents downloaded by pipeline runs",
+		}),
+		TSLSignatureStatus: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "go_trust_tsl_signature_status",
+				Help: "Signature verification status of the TSLs fetched by the default pipeline, 1 for the status of each TSL",
+			},
+			[]string{"url", "status", "admitted"},
+		),
 
 		// API request metrics
 		APIRequestsTotal: prometheus.NewCounterVec(
@@ -107,6 +184,41 @@ func NewMetrics() *Metrics {
 			Help:    "Duration of certificate validation in seconds",
 			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5},
 		}),
+
+		// Trust decision metrics
+		DecisionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_decisions_total",
+				Help: "Total number of AuthZEN trust decisions by action, resource type, decision and reason",
+			},
+			[]string{"action", "resource_type", "tenant", "purpose", "decision", "reason"},
+		),
+		DecisionTimeoutsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_decision_timeouts_total",
+				Help: "Total number of AuthZEN decisions abandoned because the decision deadline passed or the client disconnected",
+			},
+			[]string{"cause"},
+		),
+		DecisionPhaseDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "go_trust_decision_phase_duration_seconds",
+				Help:    "Duration of the phases of AuthZEN trust decisions in seconds",
+				Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+			},
+			[]string{"phase"},
+		),
+		EvaluationsShedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "go_trust_evaluations_shed_total",
+			Help: "Total number of AuthZEN evaluation requests rejected because the concurrency limit was reached",
+		}),
+		ShadowDecisionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_shadow_decisions_total",
+				Help: "Total number of decisions compared with the staged policy, by active and staged decision (allow, deny, error or skipped)",
+			},
+			[]string{"active", "staged"},
+		),
 	}
 
 	// Register all metrics with the private registry
@@ -115,18 +227,43 @@ func NewMetrics() *Metrics {
 		m.PipelineExecutionTotal,
 		m.PipelineExecutionErrors,
 		m.TSLCount,
+		m.PoolGeneration,
+		m.PoolCertificates,
+		m.PoolCertificateListings,
 		m.TSLProcessingDuration,
+		m.PipelinePanicsTotal,
+		m.PipelineStepDuration,
+		m.PipelineStepWarnings,
+		m.PipelineDownloadBytes,
+		m.TSLDownloadBytesTotal,
+		m.TSLSignatureStatus,
 		m.APIRequestsTotal,
 		m.APIRequestDuration,
 		m.APIRequestsInFlight,
 		m.ErrorsTotal,
 		m.CertValidationTotal,
 		m.CertValidationDuration,
+		m.DecisionsTotal,
+		m.DecisionTimeoutsTotal,
+		m.DecisionPhaseDuration,
+		m.EvaluationsShedTotal,
+		m.ShadowDecisionsTotal,
 	)
 
 	return m
 }
 
+// Register registers additional collectors, such as those of trust
+// registries, to be exposed alongside the server's own metrics.
+func (m *Metrics) Register(collectors ...prometheus.Collector) error {
+	for _, c := range collectors {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // MetricsMiddleware creates a Gin middleware that records API metrics
 func (m *Metrics) MetricsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -168,11 +305,55 @@ func (m *Metrics) RecordPipelineExecution(duration time.Duration, tslCount int,
 	}
 }
 
+// RecordPool records the size of an installed certificate pool. Certificates
+// listed several times are counted once, so listings exceeding certificates
+// shows how many duplicates the TSLs contain.
+func (m *Metrics) RecordPool(ctx *pipeline.Context) {
+	certificates, listings := ctx.PoolSize()
+	m.PoolCertificates.Set(float64(certificates))
+	m.PoolCertificateListings.Set(float64(listings))
+}
+
+// RecordSignatures records the signature verification status of the TSLs
+// fetched for an installed context, replacing those of the previous one.
+func (m *Metrics) RecordSignatures(ctx *pipeline.Context) {
+	m.TSLSignatureStatus.Reset()
+	for tsl, sig := range ctx.Signatures {
+		m.TSLSignatureStatus.WithLabelValues(tsl.Source, sig.Status, strconv.FormatBool(sig.Admitted)).Set(1)
+	}
+}
+
 // RecordTSLProcessing records metrics for TSL processing
 func (m *Metrics) RecordTSLProcessing(duration time.Duration) {
 	m.TSLProcessingDuration.Observe(duration.Seconds())
 }
 
+// RecordPipelinePanic records a panic recovered in the named pipeline step
+func (m *Metrics) RecordPipelinePanic(step string) {
+	m.PipelinePanicsTotal.WithLabelValues(step).Inc()
+}
+
+// RecordRunReport records per-step duration and warning metrics, and the bytes
+// downloaded, from a pipeline run report
+func (m *Metrics) RecordRunReport(report *pipeline.RunReport) {
+	if report == nil {
+		return
+	}
+	downloaded := report.BytesDownloaded()
+	m.PipelineDownloadBytes.Observe(float64(downloaded))
+	m.TSLDownloadBytesTotal.Add(float64(downloaded))
+	for _, step := range report.Steps {
+		status := "success"
+		if step.Error != "" {
+			status = "error"
+		}
+		m.PipelineStepDuration.WithLabelValues(step.Name, status).Observe(step.Duration.Seconds())
+		if len(step.Warnings) > 0 {
+			m.PipelineStepWarnings.WithLabelValues(step.Name).Add(float64(len(step.Warnings)))
+		}
+	}
+}
+
 // RecordError records an error metric
 func (m *Metrics) RecordError(errorType, operation string) {
 	m.ErrorsTotal.WithLabelValues(errorType, operation).Inc()
@@ -188,24 +369,108 @@ func (m *Metrics) RecordCertValidation(duration time.Duration, success bool) {
 	m.CertValidationTotal.WithLabelValues(result).Inc()
 }
 
+// DecisionLabels holds the label values of a recorded AuthZEN trust decision.
+type DecisionLabels struct {
+	Action       string // Action name from the request, "" if none
+	ResourceType string // Resource type from the request
+	Tenant       string // Tenant label, see TenantPolicy.Labels; "" is reported as "none"
+	Purpose      string // Purpose label, see TenantPolicy.Labels; "" is reported as "none"
+	Decision     bool   // The decision
+	Reason       string // Normalized Reason* code, see DecisionReasonCode
+}
+
+// RecordDecision records an AuthZEN trust decision. An empty action is reported
+// as "none", and action names beyond MaxActionLabels as "other".
+func (m *Metrics) RecordDecision(l DecisionLabels) {
+	m.DecisionsTotal.WithLabelValues(
+		m.actionLabel(l.Action),
+		resourceTypeLabel(l.ResourceType),
+		labelOrNone(l.Tenant),
+		labelOrNone(l.Purpose),
+		strconv.FormatBool(l.Decision),
+		l.Reason,
+	).Inc()
+}
+
+// RecordDecisionTimeout records a decision abandoned for the given cause,
+// TimeoutCauseDeadline or TimeoutCauseCanceled.
+func (m *Metrics) RecordDecisionTimeout(cause string) {
+	m.DecisionTimeoutsTotal.WithLabelValues(cause).Inc()
+}
+
+// RecordDecisionPhase records the time spent in one phase of an AuthZEN
+// decision, one of the Phase* constants.
+func (m *Metrics) RecordDecisionPhase(phase string, duration time.Duration) {
+	m.DecisionPhaseDuration.WithLabelValues(phase).Observe(duration.Seconds())
+}
+
+// RecordEvaluationShed records an evaluation request rejected because the
+// concurrency limit was reached.
+func (m *Metrics) RecordEvaluationShed() {
+	m.EvaluationsShedTotal.Inc()
+}
+
+// RecordShadowDecision records the decision on a request under the staged
+// policy, compared with the active decision.
+func (m *Metrics) RecordShadowDecision(active, staged string) {
+	m.ShadowDecisionsTotal.WithLabelValues(active, staged).Inc()
+}
+
+// labelOrNone returns v, or "none" if v is empty.
+func labelOrNone(v string) string {
+	if v == "" {
+		return "none"
+	}
+	return v
+}
+
+// actionLabel returns the label value for an action name, admitting new names
+// until MaxActionLabels distinct names have been seen.
+func (m *Metrics) actionLabel(action string) string {
+	if action == "" {
+		return "none"
+	}
+
+	m.actionsMu.Lock()
+	defer m.actionsMu.Unlock()
+
+	if _, ok := m.actions[action]; ok {
+		return action
+	}
+	if len(m.actions) >= MaxActionLabels {
+		return "other"
+	}
+	m.actions[action] = struct{}{}
+	return action
+}
+
 // RegisterMetricsEndpoint registers the /metrics endpoint with the Gin router
 func RegisterMetricsEndpoint(r *gin.Engine, metrics *Metrics) {
 	// Add middleware to all routes
 	r.Use(metrics.MetricsMiddleware())
 
-	// Register Prometheus metrics endpoint with custom registry
-	// @Summary Prometheus metrics
-	// @Description Exposes Prometheus metrics for monitoring and alerting
-	// @Description
-	// @Description Metrics include:
-	// @Description - Pipeline execution duration and counts
-	// @Description - TSL processing metrics
-	// @Description - API request rates and latency
-	// @Description - Certificate validation metrics
-	// @Description - Error counts by type
-	// @Tags Metrics
-	// @Produce plain
-	// @Success 200 {string} string "Prometheus metrics in text format"
-	// @Router /metrics [get]
-	r.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{})))
+	r.GET("/metrics", MetricsHandler(metrics))
+}
+
+// MetricsHandler godoc
+// @Summary Prometheus metrics
+// @Description Exposes Prometheus metrics for monitoring and alerting
+// @Description
+// @Description Metrics include:
+// @Description - Pipeline execution duration and counts
+// @Description - TSL processing metrics
+// @Description - Signature verification status of the fetched TSLs
+// @Description - API request rates and latency
+// @Description - Certificate validation metrics
+// @Description - Trust decisions by action, resource type, tenant, purpose, decision and reason
+// @Description - Trust decision latency by phase (bind, parse, lookup, policy, verify, respond)
+// @Description - Error counts by type
+// @Description - Evaluations, decisions, latency, errors and health per trust registry
+// @Tags Metrics
+// @Produce plain
+// @Success 200 {string} string "Prometheus metrics in text format"
+// @Router /metrics [get]
+func MetricsHandler(metrics *Metrics) gin.HandlerFunc {
+	// Served from the private registry of this metrics instance
+	return gin.WrapH(promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}))
 }
@@ -1,14 +1,21 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	certs "github.com/SUNET/go-trust/pkg/testutil"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewMetrics(t *testing.T) {
@@ -19,12 +26,32 @@ func TestNewMetrics(t *testing.T) {
 	assert.NotNil(t, m.PipelineExecutionErrors)
 	assert.NotNil(t, m.TSLCount)
 	assert.NotNil(t, m.TSLProcessingDuration)
+	assert.NotNil(t, m.PipelinePanicsTotal)
 	assert.NotNil(t, m.APIRequestsTotal)
 	assert.NotNil(t, m.APIRequestDuration)
 	assert.NotNil(t, m.APIRequestsInFlight)
 	assert.NotNil(t, m.ErrorsTotal)
 	assert.NotNil(t, m.CertValidationTotal)
 	assert.NotNil(t, m.CertValidationDuration)
+	assert.NotNil(t, m.DecisionsTotal)
+}
+
+func TestMetricsRegister(t *testing.T) {
+	m := NewMetrics()
+	extra := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "go_trust_test_extra",
+		Help: "Collector registered in addition to the server's metrics",
+	})
+	extra.Set(42)
+	assert.NoError(t, m.Register(extra))
+
+	r := gin.New()
+	RegisterMetricsEndpoint(r, m)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "go_trust_test_extra 42")
+
+	assert.Error(t, m.Register(extra), "registering a collector twice fails")
 }
 
 func TestMetricsMiddleware(t *testing.T) {
@@ -147,6 +174,57 @@ func TestRecordError(t *testing.T) {
 	// No panics = success
 }
 
+func TestRecordPipelinePanic(t *testing.T) {
+	m := NewMetrics()
+
+	m.RecordPipelinePanic("load")
+	m.RecordPipelinePanic("load")
+	m.RecordPipelinePanic("select")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.PipelinePanicsTotal.WithLabelValues("load")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.PipelinePanicsTotal.WithLabelValues("select")))
+}
+
+func TestRecordRunReport(t *testing.T) {
+	m := NewMetrics()
+
+	m.RecordRunReport(nil)
+	m.RecordRunReport(&pipeline.RunReport{
+		Steps: []pipeline.StepReport{
+			{Name: "load", Duration: 2 * time.Second, Warnings: []string{"a", "b"}},
+			{Name: "select", Duration: time.Millisecond, Error: "no TSLs"},
+		},
+		Sources: []pipeline.SourceFetch{
+			{URL: "https://example.com/lotl.xml", Bytes: 1000},
+			{URL: "https://example.com/se.xml", Bytes: 500},
+			{URL: "https://example.com/no.xml", Cached: true},
+		},
+	})
+
+	assert.Equal(t, 1, testutil.CollectAndCount(m.PipelineStepDuration.WithLabelValues("load", "success").(prometheus.Histogram)))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.PipelineStepDuration.WithLabelValues("select", "error").(prometheus.Histogram)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.PipelineStepWarnings.WithLabelValues("load")))
+	assert.Equal(t, 1500.0, testutil.ToFloat64(m.TSLDownloadBytesTotal))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.PipelineDownloadBytes))
+}
+
+func TestRecordSignatures(t *testing.T) {
+	m := NewMetrics()
+	se := &etsi119612.TSL{Source: "https://example.com/se.xml"}
+	no := &etsi119612.TSL{Source: "https://example.com/no.xml"}
+
+	ctx := pipeline.NewContext()
+	ctx.RecordSignature(se, &pipeline.TSLSignature{Status: pipeline.SignatureVerified, Admitted: true})
+	ctx.RecordSignature(no, &pipeline.TSLSignature{Status: pipeline.SignatureUntrusted})
+	m.RecordSignatures(ctx)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.TSLSignatureStatus.WithLabelValues(se.Source, pipeline.SignatureVerified, "true")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.TSLSignatureStatus.WithLabelValues(no.Source, pipeline.SignatureUntrusted, "false")))
+
+	// A new context replaces the statuses of the previous one
+	m.RecordSignatures(pipeline.NewContext())
+	assert.Equal(t, 0, testutil.CollectAndCount(m.TSLSignatureStatus))
+}
+
 func TestRecordCertValidation(t *testing.T) {
 	m := NewMetrics()
 
@@ -159,6 +237,36 @@ func TestRecordCertValidation(t *testing.T) {
 	// No panics = success
 }
 
+func TestRecordDecision(t *testing.T) {
+	m := NewMetrics()
+
+	m.RecordDecision(DecisionLabels{Action: "issuer", ResourceType: "x5c", Decision: true, Reason: ReasonNone})
+	m.RecordDecision(DecisionLabels{Action: "issuer", ResourceType: "x5c", Decision: true, Reason: ReasonNone})
+	m.RecordDecision(DecisionLabels{ResourceType: "jwk", Decision: false, Reason: ReasonExpired})
+	m.RecordDecision(DecisionLabels{Action: "issuer", ResourceType: "did", Tenant: "bank", Purpose: "kyc", Reason: ReasonInvalidRequest})
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.DecisionsTotal.WithLabelValues("issuer", "x5c", "none", "none", "true", "none")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DecisionsTotal.WithLabelValues("none", "jwk", "none", "none", "false", "expired")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DecisionsTotal.WithLabelValues("issuer", "other", "bank", "kyc", "false", "invalid_request")))
+}
+
+func TestRecordDecision_ActionCardinality(t *testing.T) {
+	m := NewMetrics()
+
+	record := func(action string) {
+		m.RecordDecision(DecisionLabels{Action: action, ResourceType: "x5c", Decision: true, Reason: ReasonNone})
+	}
+	for i := 0; i < MaxActionLabels; i++ {
+		record(fmt.Sprintf("action-%d", i))
+	}
+	record("one-too-many")
+	record("action-0")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.DecisionsTotal.WithLabelValues("other", "x5c", "none", "none", "true", "none")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.DecisionsTotal.WithLabelValues("action-0", "x5c", "none", "none", "true", "none")))
+	assert.Equal(t, MaxActionLabels+1, testutil.CollectAndCount(m.DecisionsTotal))
+}
+
 func TestRegisterMetricsEndpoint(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -413,3 +521,16 @@ func BenchmarkRecordCertValidation(b *testing.B) {
 		m.RecordCertValidation(10*time.Millisecond, true)
 	}
 }
+
+func TestRecordPool(t *testing.T) {
+	m := NewMetrics()
+	ca, err := certs.NewCA("Pool CA")
+	require.NoError(t, err)
+	ctx := pipeline.NewContext()
+	ctx.AddPoolCertificate(ca.Certificate, pipeline.CertProvenance{Provider: "P", Service: "S1"})
+	ctx.AddPoolCertificate(ca.Certificate, pipeline.CertProvenance{Provider: "P", Service: "S2"})
+
+	m.RecordPool(ctx)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.PoolCertificates))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.PoolCertificateListings))
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+)
+
+// ErrNameMismatch is wrapped by the errors of requests whose subject.id is
+// none of the names of the leaf certificate of their resource.key.
+var ErrNameMismatch = errors.New("name mismatch")
+
+// Subject alternative name types compared by a NameRule.
+const (
+	NameTypeDNS   = "dns"
+	NameTypeURI   = "uri"
+	NameTypeEmail = "email"
+)
+
+// Matching modes of a NameRule. Each admits the names the previous one does.
+const (
+	NameMatchExact    = "exact"    // subject.id equals a name
+	NameMatchWildcard = "wildcard" // ... or a DNS name under a wildcard name such as *.example.com
+	NameMatchSuffix   = "suffix"   // ... or a DNS name under any DNS name of the certificate
+)
+
+// NameRule requires the subject.id of AuthZEN requests of some actions to
+// name the leaf certificate of their resource.key: to match one of its
+// subject alternative names of the given types. subject.id is compared with
+// DNS names as a host name, or by the host of subject.id if it is a URL,
+// case-insensitively; with URI and email names as is, except for the case of
+// the email domain. Wildcard and suffix matching only apply to DNS names.
+type NameRule struct {
+	Actions []string // Actions the rule applies to; empty applies to all
+	Types   []string // Name types compared: NameTypeDNS, NameTypeURI, NameTypeEmail; empty compares all
+	Match   string   // NameMatchExact (default), NameMatchWildcard or NameMatchSuffix
+}
+
+// NameMatcher checks AuthZEN requests against NameRules, closing the gap
+// that the Trust Registry Profile binds subject.id to a key without looking
+// at the names the certificate of the key is issued to.
+type NameMatcher struct {
+	rules []NameRule
+}
+
+// NewNameMatcher creates a NameMatcher with rules, of which the first that
+// applies to the action of a request is checked. It returns nil if rules is
+// empty, which checks no request.
+func NewNameMatcher(rules []NameRule) (*NameMatcher, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	m := &NameMatcher{rules: make([]NameRule, 0, len(rules))}
+	for i, rule := range rules {
+		switch rule.Match {
+		case "":
+			rule.Match = NameMatchExact
+		case NameMatchExact, NameMatchWildcard, NameMatchSuffix:
+		default:
+			return nil, fmt.Errorf("name rule %d: invalid match %q: must be %s, %s or %s", i+1, rule.Match, NameMatchExact, NameMatchWildcard, NameMatchSuffix)
+		}
+		for _, typ := range rule.Types {
+			if typ != NameTypeDNS && typ != NameTypeURI && typ != NameTypeEmail {
+				return nil, fmt.Errorf("name rule %d: invalid name type %q: must be %s, %s or %s", i+1, typ, NameTypeDNS, NameTypeURI, NameTypeEmail)
+			}
+		}
+		m.rules = append(m.rules, rule)
+	}
+	return m, nil
+}
+
+// Check returns an error wrapping ErrNameMismatch if the rule applying to
+// action requires the subject.id of req to name the leaf of certs and it
+// does not. Requests without certificates, such as those for federation
+// entities, are not checked. A nil NameMatcher checks no request.
+func (m *NameMatcher) Check(req *authzen.EvaluationRequest, action string, certs []*x509.Certificate) error {
+	if m == nil || len(certs) == 0 {
+		return nil
+	}
+	for _, rule := range m.rules {
+		if len(rule.Actions) > 0 && !slices.Contains(rule.Actions, action) {
+			continue
+		}
+		if !rule.matches(req.Subject.ID, certs[0]) {
+			return fmt.Errorf("%w: subject.id %q matches no %s name of the leaf certificate", ErrNameMismatch, req.Subject.ID, strings.Join(rule.types(), ", "))
+		}
+		return nil
+	}
+	return nil
+}
+
+// types returns the name types compared by r.
+func (r *NameRule) types() []string {
+	if len(r.Types) == 0 {
+		return []string{NameTypeDNS, NameTypeURI, NameTypeEmail}
+	}
+	return r.Types
+}
+
+// matches reports whether id is a name of cert under r.
+func (r *NameRule) matches(id string, cert *x509.Certificate) bool {
+	for _, typ := range r.types() {
+		switch typ {
+		case NameTypeDNS:
+			host := hostName(id)
+			for _, name := range cert.DNSNames {
+				if matchDNSName(host, normalizeDNSName(name), r.Match) {
+					return true
+				}
+			}
+		case NameTypeURI:
+			for _, uri := range cert.URIs {
+				if uri.String() == id {
+					return true
+				}
+			}
+		case NameTypeEmail:
+			for _, email := range cert.EmailAddresses {
+				if equalEmail(email, id) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hostName returns id as a DNS name: the host of id if it is a URL, id
+// otherwise.
+func hostName(id string) string {
+	if strings.Contains(id, "://") {
+		if u, err := url.Parse(id); err == nil {
+			id = u.Hostname()
+		}
+	}
+	return normalizeDNSName(id)
+}
+
+func normalizeDNSName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// matchDNSName reports whether host matches the DNS name of a certificate
+// under the matching mode. A wildcard matches exactly one leftmost label.
+func matchDNSName(host, name, mode string) bool {
+	if host == "" || name == "" {
+		return false
+	}
+	if host == name {
+		return true
+	}
+	switch mode {
+	case NameMatchWildcard:
+		parent, ok := strings.CutPrefix(name, "*.")
+		if !ok {
+			return false
+		}
+		label, rest, found := strings.Cut(host, ".")
+		return found && label != "" && rest == parent
+	case NameMatchSuffix:
+		parent := strings.TrimPrefix(name, "*.")
+		return host == parent || strings.HasSuffix(host, "."+parent)
+	}
+	return false
+}
+
+// equalEmail reports whether the email addresses a and b are equal, with
+// the domain compared case-insensitively.
+func equalEmail(a, b string) bool {
+	localA, domainA, okA := strings.Cut(a, "@")
+	localB, domainB, okB := strings.Cut(b, "@")
+	return okA && okB && localA == localB && strings.EqualFold(domainA, domainB)
+}
+
+// policyReason returns the reason code of a request denied by err before
+// evaluation: ReasonNameMismatch for name mismatches, ReasonPolicyMismatch
+// otherwise.
+func policyReason(err error) string {
+	if errors.Is(err, ErrNameMismatch) {
+		return ReasonNameMismatch
+	}
+	return ReasonPolicyMismatch
+}
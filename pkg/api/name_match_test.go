@@ -0,0 +1,129 @@
+package api
+
+import (
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameMatcher_Check(t *testing.T) {
+	issuer, err := url.Parse("https://issuer.example.com/oidc")
+	require.NoError(t, err)
+	cert := &x509.Certificate{
+		DNSNames:       []string{"wallet.example.com", "*.wallets.example.org"},
+		URIs:           []*url.URL{issuer},
+		EmailAddresses: []string{"pki@Example.com"},
+	}
+	certs := []*x509.Certificate{cert}
+	check := func(rule NameRule, id string) error {
+		m, err := NewNameMatcher([]NameRule{rule})
+		require.NoError(t, err)
+		req := &authzen.EvaluationRequest{Subject: authzen.Subject{Type: "key", ID: id}}
+		return m.Check(req, "wallet-provider", certs)
+	}
+
+	tests := []struct {
+		rule  NameRule
+		id    string
+		match bool
+	}{
+		{NameRule{}, "wallet.example.com", true},
+		{NameRule{}, "WALLET.example.com.", true},
+		{NameRule{}, "https://wallet.example.com/path", true},
+		{NameRule{}, "https://issuer.example.com/oidc", true},
+		{NameRule{}, "pki@example.com", true},
+		{NameRule{}, "PKI@example.com", false},
+		{NameRule{}, "other.example.com", false},
+		{NameRule{}, "a.wallets.example.org", false},
+		{NameRule{Match: NameMatchWildcard}, "a.wallets.example.org", true},
+		{NameRule{Match: NameMatchWildcard}, "a.b.wallets.example.org", false},
+		{NameRule{Match: NameMatchWildcard}, "wallets.example.org", false},
+		{NameRule{Match: NameMatchSuffix}, "a.b.wallets.example.org", true},
+		{NameRule{Match: NameMatchSuffix}, "eu.wallet.example.com", true},
+		{NameRule{Match: NameMatchSuffix}, "badwallet.example.com", false},
+		{NameRule{Types: []string{NameTypeURI}}, "wallet.example.com", false},
+		{NameRule{Types: []string{NameTypeDNS}}, "https://issuer.example.com/oidc", false},
+		{NameRule{Types: []string{NameTypeEmail}}, "pki@example.com", true},
+	}
+	for _, tt := range tests {
+		err := check(tt.rule, tt.id)
+		if tt.match {
+			assert.NoError(t, err, "%+v %s", tt.rule, tt.id)
+		} else {
+			assert.ErrorIs(t, err, ErrNameMismatch, "%+v %s", tt.rule, tt.id)
+		}
+	}
+
+	// Only the first rule applying to the action is checked
+	m, err := NewNameMatcher([]NameRule{{Actions: []string{"other"}, Match: NameMatchSuffix}, {Types: []string{NameTypeURI}}})
+	require.NoError(t, err)
+	req := &authzen.EvaluationRequest{Subject: authzen.Subject{Type: "key", ID: "eu.wallet.example.com"}}
+	assert.NoError(t, m.Check(req, "other", certs))
+	err = m.Check(req, "wallet-provider", certs)
+	assert.EqualError(t, err, `name mismatch: subject.id "eu.wallet.example.com" matches no uri name of the leaf certificate`)
+	assert.Equal(t, ReasonNameMismatch, reasonCodeFor(err.Error()))
+	assert.NoError(t, m.Check(req, "wallet-provider", nil), "entities are not checked")
+
+	var none *NameMatcher
+	assert.NoError(t, none.Check(req, "wallet-provider", certs))
+}
+
+func TestNewNameMatcher(t *testing.T) {
+	m, err := NewNameMatcher(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	_, err = NewNameMatcher([]NameRule{{Match: "regex"}})
+	assert.ErrorContains(t, err, `invalid match "regex"`)
+	_, err = NewNameMatcher([]NameRule{{Types: []string{"ip"}}})
+	assert.ErrorContains(t, err, `invalid name type "ip"`)
+}
+
+func TestPolicyReason(t *testing.T) {
+	assert.Equal(t, ReasonNameMismatch, policyReason(ErrNameMismatch))
+	assert.Equal(t, ReasonPolicyMismatch, policyReason(errors.New("tenant not allowed")))
+	assert.Equal(t, ReasonPolicyMismatch, policyReason(nil))
+}
+
+func TestAuthZENDecisionHandler_NameMatching(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "wallet.example.com", testutil.WithDNSNames("wallet.example.com"))
+	require.NoError(t, err)
+
+	// The leaf is allow-listed, so only the name check denies
+	allow, err := NewOverrides(nil, []string{pipeline.Fingerprint(leaf.Certificate)}, "")
+	require.NoError(t, err)
+	names, err := NewNameMatcher([]NameRule{{Types: []string{NameTypeDNS}}})
+	require.NoError(t, err)
+
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.Overrides = allow
+	serverCtx.NameMatcher = names
+	serverCtx.Unlock()
+
+	evaluate := func(id string) string {
+		req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(
+			`{"subject":{"type":"key","id":"`+id+`"},"resource":{"type":"x5c","id":"`+id+`","key":["`+leaf.Base64()+`"]}}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	assert.Contains(t, evaluate("wallet.example.com"), `"decision":true`)
+	resp := evaluate("other.example.com")
+	assert.Contains(t, resp, `"decision":false`)
+	assert.Contains(t, resp, `"code":"name_mismatch"`)
+}
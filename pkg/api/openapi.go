@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"github.com/swaggo/swag"
+)
+
+// OpenAPIPath is the path of the OpenAPI specification of the API.
+const OpenAPIPath = "/openapi.json"
+
+// RegisterOpenAPIEndpoints serves spec, the OpenAPI (Swagger 2.0)
+// specification generated from the handler annotations by swag, at
+// OpenAPIPath, and the Swagger UI under /swagger/ if swaggerUI is set.
+//
+// The host, scheme and base path of spec are set from serverCtx.BaseURL, so
+// that clients generated from the specification of a running instance call
+// that instance.
+func RegisterOpenAPIEndpoints(r *gin.Engine, serverCtx *ServerContext, spec *swag.Spec, swaggerUI bool) {
+	if u, err := url.Parse(serverCtx.BaseURL); err == nil && u.Host != "" {
+		spec.Host = u.Host
+		spec.Schemes = []string{u.Scheme}
+		spec.BasePath = u.Path
+		if spec.BasePath == "" {
+			spec.BasePath = "/"
+		}
+	}
+
+	r.GET(OpenAPIPath, OpenAPIHandler(spec))
+	endpoints := []string{OpenAPIPath}
+	if swaggerUI {
+		r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL(OpenAPIPath)))
+		endpoints = append(endpoints, "/swagger/index.html")
+	}
+
+	serverCtx.Logger.Info("API documentation endpoints registered",
+		logging.F("endpoints", endpoints))
+}
+
+// OpenAPIHandler godoc
+// @Summary OpenAPI specification
+// @Description Returns the OpenAPI (Swagger 2.0) specification of this API, generated from the handler
+// @Description annotations, with the host and scheme under which this instance is reached. Clients can
+// @Description be generated from it.
+// @Tags Docs
+// @Produce json
+// @Success 200 {object} map[string]interface{} "OpenAPI specification"
+// @Router /openapi.json [get]
+func OpenAPIHandler(spec *swag.Spec) gin.HandlerFunc {
+	doc := []byte(spec.ReadDoc())
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", doc)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/swaggo/swag"
+)
+
+// testSpec returns a minimal spec rendering the fields set from the base URL.
+func testSpec() *swag.Spec {
+	return &swag.Spec{
+		Host:             "localhost:6001",
+		BasePath:         "/",
+		Schemes:          []string{"http", "https"},
+		InfoInstanceName: "test",
+		SwaggerTemplate:  `{"host": "{{.Host}}", "basePath": "{{.BasePath}}", "schemes": {{ marshal .Schemes }}}`,
+	}
+}
+
+func TestRegisterOpenAPIEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serverCtx := NewServerContext(nil)
+	serverCtx.BaseURL = "https://pdp.example.com/trust"
+
+	r := gin.New()
+	RegisterOpenAPIEndpoints(r, serverCtx, testSpec(), false)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+
+	var doc struct {
+		Host     string   `json:"host"`
+		BasePath string   `json:"basePath"`
+		Schemes  []string `json:"schemes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "pdp.example.com", doc.Host)
+	assert.Equal(t, "/trust", doc.BasePath)
+	assert.Equal(t, []string{"https"}, doc.Schemes)
+
+	// The Swagger UI is opt-in
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRegisterOpenAPIEndpoints_SwaggerUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serverCtx := NewServerContext(nil)
+	serverCtx.BaseURL = "http://127.0.0.1:6001"
+
+	spec := testSpec()
+	r := gin.New()
+	RegisterOpenAPIEndpoints(r, serverCtx, spec, true)
+	assert.Equal(t, "/", spec.BasePath)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger/index.html", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The UI loads the specification from /openapi.json
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/swagger/swagger-initializer.js", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), OpenAPIPath)
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
+)
+
+// Override kinds, reported as "override" in the reason of decisions forced by
+// an override list.
+const (
+	OverrideDeny  = "deny"
+	OverrideAllow = "allow"
+)
+
+// overrideDenyMessage is the error of decisions denied by the deny-list.
+const overrideDenyMessage = "certificate distrusted by override"
+
+// Overrides are explicit decisions for certificates, by SHA-256 fingerprint,
+// that take precedence over the trust registries. A chain containing a
+// certificate on the deny-list is never trusted, even if its CA is listed in
+// a TSL; this allows distrusting a compromised CA at once, without waiting for
+// TSL updates. A leaf certificate on the allow-list is trusted even if no
+// registry trusts it. Deny takes precedence over allow.
+//
+// The lists combine fixed entries with those of an optional YAML file, which
+// is reloaded when it changes (see Watch). A nil Overrides has empty lists.
+type Overrides struct {
+	file        string
+	staticDeny  map[string]struct{}
+	staticAllow map[string]struct{}
+
+	mu      sync.RWMutex
+	deny    map[string]struct{}
+	allow   map[string]struct{}
+	modTime time.Time
+}
+
+// overridesFile is the format of the overrides file.
+type overridesFile struct {
+	Deny  []string `yaml:"deny"`
+	Allow []string `yaml:"allow"`
+}
+
+// NewOverrides creates Overrides from fixed deny and allow lists of SHA-256
+// certificate fingerprints, in hex with optional colons, and the file of
+// additional lists if file is not empty. It returns an error for an invalid
+// fingerprint or a file that cannot be read.
+func NewOverrides(deny, allow []string, file string) (*Overrides, error) {
+	staticDeny, err := fingerprintSet(deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny-list: %w", err)
+	}
+	staticAllow, err := fingerprintSet(allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow-list: %w", err)
+	}
+
+	o := &Overrides{file: file, staticDeny: staticDeny, staticAllow: staticAllow}
+	o.deny, o.allow = staticDeny, staticAllow
+	if file != "" {
+		if _, err := o.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// Reload reads the overrides file again if it changed since it was last read,
+// and reports whether it did. On error the previous lists stay in effect.
+func (o *Overrides) Reload() (bool, error) {
+	if o == nil || o.file == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(o.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read overrides file: %w", err)
+	}
+	o.mu.RLock()
+	unchanged := info.ModTime().Equal(o.modTime)
+	o.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(o.file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read overrides file: %w", err)
+	}
+	var lists overridesFile
+	if err := yamlutil.Unmarshal(data, &lists); err != nil {
+		return false, fmt.Errorf("failed to parse overrides file: %w", err)
+	}
+	deny, err := fingerprintSet(lists.Deny)
+	if err != nil {
+		return false, fmt.Errorf("overrides file deny-list: %w", err)
+	}
+	allow, err := fingerprintSet(lists.Allow)
+	if err != nil {
+		return false, fmt.Errorf("overrides file allow-list: %w", err)
+	}
+	for fp := range o.staticDeny {
+		deny[fp] = struct{}{}
+	}
+	for fp := range o.staticAllow {
+		allow[fp] = struct{}{}
+	}
+
+	o.mu.Lock()
+	o.deny, o.allow, o.modTime = deny, allow, info.ModTime()
+	o.mu.Unlock()
+	return true, nil
+}
+
+// Watch reloads the overrides file every interval in a background goroutine
+// until ctx is done, logging reloads and errors to logger.
+func (o *Overrides) Watch(ctx context.Context, interval time.Duration, logger logging.Logger) {
+	if o == nil || o.file == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reloaded, err := o.Reload()
+				if err != nil {
+					logger.Error("Failed to reload decision overrides",
+						logging.F("file", o.file),
+						logging.F("error", err.Error()))
+				} else if reloaded {
+					deny, allow := o.Len()
+					logger.Info("Decision overrides reloaded",
+						logging.F("file", o.file),
+						logging.F("deny", deny),
+						logging.F("allow", allow))
+				}
+			}
+		}
+	}()
+}
+
+// Len returns the number of fingerprints on the deny- and allow-lists.
+func (o *Overrides) Len() (deny, allow int) {
+	if o == nil {
+		return 0, 0
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.deny), len(o.allow)
+}
+
+// Denied returns the fingerprint of the first of certs on the deny-list.
+func (o *Overrides) Denied(certs []*x509.Certificate) (string, bool) {
+	if o == nil {
+		return "", false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for _, cert := range certs {
+		fp := pipeline.Fingerprint(cert)
+		if _, ok := o.deny[fp]; ok {
+			return fp, true
+		}
+	}
+	return "", false
+}
+
+// Allowed returns the fingerprint of leaf if it is on the allow-list.
+func (o *Overrides) Allowed(leaf *x509.Certificate) (string, bool) {
+	if o == nil || leaf == nil {
+		return "", false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	fp := pipeline.Fingerprint(leaf)
+	_, ok := o.allow[fp]
+	return fp, ok
+}
+
+// trustedChains returns the chains without a certificate on the deny-list,
+// and the fingerprint of a denied certificate if any chain had one.
+func (o *Overrides) trustedChains(chains [][]*x509.Certificate) ([][]*x509.Certificate, string) {
+	var trusted [][]*x509.Certificate
+	var denied string
+	for _, chain := range chains {
+		if fp, ok := o.Denied(chain); ok {
+			denied = fp
+			continue
+		}
+		trusted = append(trusted, chain)
+	}
+	return trusted, denied
+}
+
+// overrideResponse returns the decision forced by an override of the given
+// kind for the certificate with fingerprint fp.
+func overrideResponse(kind, fp string) *authzen.EvaluationResponse {
+	reason := map[string]interface{}{
+		"override":    kind,
+		"fingerprint": fp,
+	}
+	if kind == OverrideDeny {
+		reason["error"] = overrideDenyMessage
+	}
+	return &authzen.EvaluationResponse{
+		Decision: kind == OverrideAllow,
+		Context:  &authzen.EvaluationResponseContext{Reason: reason},
+	}
+}
+
+// overrideOf returns the kind and fingerprint of the override that decided
+// resp, or "" if no override did.
+func overrideOf(resp *authzen.EvaluationResponse) (kind, fp string) {
+	if resp == nil || resp.Context == nil {
+		return "", ""
+	}
+	kind, _ = resp.Context.Reason["override"].(string)
+	fp, _ = resp.Context.Reason["fingerprint"].(string)
+	return kind, fp
+}
+
+// fingerprintSet returns the set of normalized fingerprints.
+func fingerprintSet(fingerprints []string) (map[string]struct{}, error) {
+	set := make(map[string]struct{}, len(fingerprints))
+	for _, f := range fingerprints {
+		fp, err := NormalizeFingerprint(f)
+		if err != nil {
+			return nil, err
+		}
+		set[fp] = struct{}{}
+	}
+	return set, nil
+}
+
+// NormalizeFingerprint returns a SHA-256 fingerprint in lower-case hex without
+// separators, the form of pipeline.Fingerprint. It accepts upper case and
+// colon or space separators, and returns an error for anything that is not 32
+// bytes of hex.
+func NormalizeFingerprint(fingerprint string) (string, error) {
+	fp := strings.ToLower(strings.NewReplacer(":", "", " ", "").Replace(strings.TrimSpace(fingerprint)))
+	if b, err := hex.DecodeString(fp); err != nil || len(b) != 32 {
+		return "", fmt.Errorf("invalid SHA-256 fingerprint %q", fingerprint)
+	}
+	return fp, nil
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/registry"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeFingerprint(t *testing.T) {
+	want := strings.Repeat("ab", 32)
+
+	fp, err := NormalizeFingerprint(strings.ToUpper(strings.Repeat("ab:", 31) + "ab"))
+	require.NoError(t, err)
+	assert.Equal(t, want, fp)
+
+	fp, err = NormalizeFingerprint(" " + want + " ")
+	require.NoError(t, err)
+	assert.Equal(t, want, fp)
+
+	for _, invalid := range []string{"", "abcd", strings.Repeat("zz", 32), strings.Repeat("ab", 20)} {
+		_, err := NormalizeFingerprint(invalid)
+		assert.Error(t, err, "fingerprint %q", invalid)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	ca, err := testutil.NewCA("Override CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	o, err := NewOverrides([]string{pipeline.Fingerprint(ca.Certificate)}, []string{pipeline.Fingerprint(leaf.Certificate)}, "")
+	require.NoError(t, err)
+
+	fp, ok := o.Denied([]*x509.Certificate{leaf.Certificate, ca.Certificate})
+	assert.True(t, ok)
+	assert.Equal(t, pipeline.Fingerprint(ca.Certificate), fp)
+	_, ok = o.Denied([]*x509.Certificate{leaf.Certificate})
+	assert.False(t, ok)
+
+	fp, ok = o.Allowed(leaf.Certificate)
+	assert.True(t, ok)
+	assert.Equal(t, pipeline.Fingerprint(leaf.Certificate), fp)
+	_, ok = o.Allowed(ca.Certificate)
+	assert.False(t, ok)
+
+	// A nil Overrides has empty lists
+	var none *Overrides
+	_, ok = none.Denied([]*x509.Certificate{ca.Certificate})
+	assert.False(t, ok)
+	_, ok = none.Allowed(leaf.Certificate)
+	assert.False(t, ok)
+	reloaded, err := none.Reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	_, err = NewOverrides([]string{"not a fingerprint"}, nil, "")
+	assert.Error(t, err)
+	_, err = NewOverrides(nil, nil, filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestOverrides_Reload(t *testing.T) {
+	ca, err := testutil.NewCA("Reload CA")
+	require.NoError(t, err)
+	caFP := pipeline.Fingerprint(ca.Certificate)
+	static := strings.Repeat("01", 32)
+
+	file := filepath.Join(t.TempDir(), "overrides.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("deny: []\n"), 0o600))
+
+	o, err := NewOverrides([]string{static}, nil, file)
+	require.NoError(t, err)
+	deny, allow := o.Len()
+	assert.Equal(t, 1, deny)
+	assert.Equal(t, 0, allow)
+
+	// Unchanged files are not read again
+	reloaded, err := o.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// Fingerprints from the file are added to the fixed ones
+	require.NoError(t, os.WriteFile(file, []byte(fmt.Sprintf("deny:\n  - %q\n", caFP)), 0o600))
+	require.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Minute)))
+	reloaded, err = o.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	_, ok := o.Denied([]*x509.Certificate{ca.Certificate})
+	assert.True(t, ok)
+	deny, _ = o.Len()
+	assert.Equal(t, 2, deny)
+
+	// An invalid file keeps the previous lists
+	require.NoError(t, os.WriteFile(file, []byte("deny: [\"nope\"]\n"), 0o600))
+	require.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(2*time.Minute)))
+	_, err = o.Reload()
+	assert.Error(t, err)
+	_, ok = o.Denied([]*x509.Certificate{ca.Certificate})
+	assert.True(t, ok)
+}
+
+// trustAllRegistry is a TrustRegistry that trusts every x5c chain.
+type trustAllRegistry struct{}
+
+func (trustAllRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRequest) (*authzen.EvaluationResponse, error) {
+	return &authzen.EvaluationResponse{Decision: true}, nil
+}
+
+func (trustAllRegistry) SupportedResourceTypes() []string { return []string{"x5c"} }
+func (trustAllRegistry) Info() registry.RegistryInfo {
+	return registry.RegistryInfo{Name: "trust-all", Type: "mock"}
+}
+func (trustAllRegistry) Healthy() bool                     { return true }
+func (trustAllRegistry) Refresh(ctx context.Context) error { return nil }
+
+func TestAuthZENDecisionHandler_Overrides(t *testing.T) {
+	ca, err := testutil.NewCA("Override CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "trusted leaf")
+	require.NoError(t, err)
+	otherCA, err := testutil.NewCA("Other CA")
+	require.NoError(t, err)
+	untrusted, err := testutil.NewLeaf(otherCA, "untrusted leaf")
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &logs
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	serverCtx := NewServerContext(logging.NewLogrusAdapter(logger))
+	trusted := pipeline.NewContext()
+	trusted.CertPool = ca.Pool()
+	serverCtx.InstallContext("", trusted)
+	RegisterAPIRoutes(r, serverCtx)
+
+	setOverrides := func(deny, allow []string) {
+		o, err := NewOverrides(deny, allow, "")
+		require.NoError(t, err)
+		serverCtx.Lock()
+		serverCtx.Overrides = o
+		serverCtx.Unlock()
+	}
+	evaluate := func(cert *testutil.Cert) (bool, map[string]interface{}) {
+		t.Helper()
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, cert.Base64())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Decision bool `json:"decision"`
+			Context  struct {
+				Reason map[string]interface{} `json:"reason"`
+			} `json:"context"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Decision, resp.Context.Reason
+	}
+
+	decision, _ := evaluate(leaf)
+	require.True(t, decision)
+
+	// Distrusting the CA denies chains through it, though it is in the pool
+	setOverrides([]string{pipeline.Fingerprint(ca.Certificate)}, nil)
+	decision, reason := evaluate(leaf)
+	assert.False(t, decision)
+	assert.Equal(t, OverrideDeny, reason["override"])
+	assert.Equal(t, pipeline.Fingerprint(ca.Certificate), reason["fingerprint"])
+	assert.Contains(t, logs.String(), "Decision override applied")
+
+	// An allow-listed leaf is trusted without a trusted chain...
+	setOverrides(nil, []string{pipeline.Fingerprint(untrusted.Certificate)})
+	decision, reason = evaluate(untrusted)
+	assert.True(t, decision)
+	assert.Equal(t, OverrideAllow, reason["override"])
+	assert.NotNil(t, reason["overridden_reason"])
+
+	// ...unless the deny-list distrusts it
+	setOverrides([]string{pipeline.Fingerprint(untrusted.Certificate)}, []string{pipeline.Fingerprint(untrusted.Certificate)})
+	decision, reason = evaluate(untrusted)
+	assert.False(t, decision)
+	assert.Equal(t, OverrideDeny, reason["override"])
+
+	// Presented certificates on the deny-list are denied before the registries
+	mgr := registry.NewRegistryManager(registry.FirstMatch, time.Second)
+	mgr.Register(trustAllRegistry{})
+	serverCtx.Lock()
+	serverCtx.RegistryManager = mgr
+	serverCtx.Unlock()
+	setOverrides([]string{pipeline.Fingerprint(leaf.Certificate)}, nil)
+	decision, _ = evaluate(untrusted)
+	require.True(t, decision)
+	decision, reason = evaluate(leaf)
+	assert.False(t, decision)
+	assert.Equal(t, pipeline.Fingerprint(leaf.Certificate), reason["fingerprint"])
+}
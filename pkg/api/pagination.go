@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listParams holds the pagination and field selection parameters accepted by
+// list endpoints such as /info and /tsls.
+//
+// Query parameters:
+//   - offset: number of items to skip (default 0)
+//   - limit: maximum number of items to return (default 0, meaning no limit)
+//   - fields: comma-separated list of summary keys to include (default all)
+type listParams struct {
+	Offset int
+	Limit  int
+	Fields []string
+}
+
+// parseListParams extracts offset, limit and fields from the request query string.
+// It returns an error if offset or limit are not non-negative integers.
+func parseListParams(c *gin.Context) (listParams, error) {
+	var p listParams
+
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid offset %q: must be a non-negative integer", v)
+		}
+		p.Offset = n
+	}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid limit %q: must be a non-negative integer", v)
+		}
+		p.Limit = n
+	}
+
+	if v := c.Query("fields"); v != "" {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				p.Fields = append(p.Fields, f)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// paginated reports whether the client asked for a page rather than the full list.
+func (p listParams) paginated() bool {
+	return p.Offset > 0 || p.Limit > 0
+}
+
+// page returns the slice of items selected by offset and limit. An offset beyond
+// the end of the list yields an empty slice.
+func page[T any](items []T, p listParams) []T {
+	if p.Offset >= len(items) {
+		return items[:0]
+	}
+	items = items[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items
+}
+
+// selectFields returns a copy of m restricted to the requested keys. If no fields
+// were requested, m is returned unchanged. Unknown keys are silently ignored.
+func (p listParams) selectFields(m map[string]interface{}) map[string]interface{} {
+	if len(p.Fields) == 0 {
+		return m
+	}
+	out := make(map[string]interface{}, len(p.Fields))
+	for _, f := range p.Fields {
+		if v, ok := m[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    listParams
+		wantErr bool
+	}{
+		{name: "empty", query: "", want: listParams{}},
+		{name: "offset and limit", query: "offset=5&limit=10", want: listParams{Offset: 5, Limit: 10}},
+		{name: "fields", query: "fields=a,%20b,,c", want: listParams{Fields: []string{"a", "b", "c"}}},
+		{name: "negative offset", query: "offset=-1", wantErr: true},
+		{name: "non-numeric limit", query: "limit=ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest("GET", "/info?"+tt.query, nil)
+			got, err := parseListParams(c)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4}
+	assert.Equal(t, items, page(items, listParams{}))
+	assert.Equal(t, []int{2, 3, 4}, page(items, listParams{Offset: 2}))
+	assert.Equal(t, []int{1, 2}, page(items, listParams{Offset: 1, Limit: 2}))
+	assert.Equal(t, []int{3, 4}, page(items, listParams{Offset: 3, Limit: 10}))
+	assert.Empty(t, page(items, listParams{Offset: 5}))
+	assert.Empty(t, page(items, listParams{Offset: 50}))
+}
+
+func TestSelectFields(t *testing.T) {
+	m := map[string]interface{}{"a": 1, "b": 2, "c": 3}
+	assert.Equal(t, m, listParams{}.selectFields(m))
+	assert.Equal(t, map[string]interface{}{"a": 1, "c": 3}, listParams{Fields: []string{"a", "c", "missing"}}.selectFields(m))
+}
+
+func TestInfoEndpoint_Pagination(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.PipelineContext.TSLs = utils.NewStack[*etsi119612.TSL]()
+	for i := 0; i < 5; i++ {
+		serverCtx.PipelineContext.TSLs.Push(&etsi119612.TSL{})
+	}
+	serverCtx.Unlock()
+
+	get := func(query string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("GET", "/info"+query, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var body map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	code, body := get("")
+	require.Equal(t, 200, code)
+	assert.Len(t, body["tsl_summaries"], 5)
+	assert.EqualValues(t, 5, body["total"])
+	assert.NotContains(t, body, "offset")
+
+	code, body = get("?offset=1&limit=2")
+	require.Equal(t, 200, code)
+	assert.Len(t, body["tsl_summaries"], 2)
+	assert.EqualValues(t, 5, body["total"])
+	assert.EqualValues(t, 1, body["offset"])
+	assert.EqualValues(t, 2, body["limit"])
+
+	code, body = get("?fields=num_trust_service_providers")
+	require.Equal(t, 200, code)
+	summaries := body["tsl_summaries"].([]interface{})
+	require.Len(t, summaries, 5)
+	assert.Equal(t, map[string]interface{}{"num_trust_service_providers": float64(0)}, summaries[0])
+
+	code, body = get("?limit=abc")
+	assert.Equal(t, 400, code)
+	assert.Contains(t, body["error"], "invalid limit")
+}
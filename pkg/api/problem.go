@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of Problem responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 9457 problem details object. POST /evaluation answers
+// requests it cannot decide with a Problem instead of a decision:
+//   - 400 for requests that violate the protocol: malformed JSON, a request
+//     failing Trust Registry Profile validation, or key material that cannot
+//     be parsed
+//   - 503 when no trust data is loaded to decide against, or when too many
+//     evaluations are in progress (see ConcurrencyLimiter)
+//   - 500 when evaluation fails with an internal error
+//
+// A request that is understood but not trusted, for any reason, is answered
+// with 200 and decision false.
+type Problem struct {
+	Type      string `json:"type" example:"about:blank"`                            // Always "about:blank": the status code says it all
+	Title     string `json:"title" example:"Bad Request"`                           // HTTP status text
+	Status    int    `json:"status" example:"400"`                                  // HTTP status code
+	Detail    string `json:"detail,omitempty" example:"subject.id must be present"` // What was wrong
+	RequestID string `json:"request_id,omitempty" example:"3f2a..."`                // X-Request-ID of the request
+}
+
+// ErrNoTrustData is returned by evaluations that have no certificate pool to
+// decide against, typically before the first pipeline run completed.
+var ErrNoTrustData = errors.New("no certificate pool loaded")
+
+// RequestError is an error caused by the request rather than by evaluating it.
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string { return e.Err.Error() }
+func (e *RequestError) Unwrap() error { return e.Err }
+
+// problemStatus returns the HTTP status of a request that could not be
+// decided because of err.
+func problemStatus(err error) int {
+	var reqErr *RequestError
+	switch {
+	case errors.As(err, &reqErr):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNoTrustData):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeProblem aborts the request with a Problem of the given status.
+func writeProblem(c *gin.Context, status int, detail string) {
+	body, _ := json.Marshal(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		RequestID: RequestID(c),
+	})
+	c.Data(status, ProblemContentType, body)
+	c.Abort()
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAuthZENDecisionHandler_ErrorMapping covers how POST /evaluation answers
+// requests it refuses: protocol errors with a 400 problem, trust refusals with
+// decision false and internal failures with a 5xx problem.
+func TestAuthZENDecisionHandler_ErrorMapping(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	untrusted, err := testutil.NewLeaf(ca, "untrusted.example.com")
+	require.NoError(t, err)
+
+	keyRequest := func(resourceType, key string) string {
+		return fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":%q,"id":"alice","key":%s}}`, resourceType, key)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		noPool     bool
+		wantStatus int
+		wantDetail string // Substring of the problem detail, for problem responses
+		wantReason string // Reason code, for decisions
+	}{
+		{
+			name:       "malformed JSON",
+			body:       `{"subject":`,
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "invalid request",
+		},
+		{
+			name:       "validation error",
+			body:       `{"subject":{"type":"user","id":"alice"},"resource":{"type":"x5c","id":"alice","key":["` + testCertBase64 + `"]}}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "validation error: subject.type must be 'key'",
+		},
+		{
+			name:       "missing key",
+			body:       keyRequest("x5c", `[]`),
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "resource.key must be present",
+		},
+		{
+			name:       "invalid territories",
+			body:       `{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":["` + testCertBase64 + `"]},"context":{"territories":[1]}}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "validation error: context.territories",
+		},
+		{
+			name:       "unparseable certificate",
+			body:       keyRequest("x5c", `["bm90YWNlcnQ="]`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "JWK without certificates",
+			body:       keyRequest("jwk", `[{"kty":"EC"}]`),
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "x5c",
+		},
+		{
+			name:       "untrusted certificate",
+			body:       keyRequest("x5c", `["`+untrusted.Base64()+`"]`),
+			wantStatus: http.StatusOK,
+			wantReason: ReasonUnknownAuthority,
+		},
+		{
+			name:       "trusted certificate",
+			body:       keyRequest("x5c", `["`+testCertBase64+`"]`),
+			wantStatus: http.StatusOK,
+			wantReason: ReasonNone,
+		},
+		{
+			name:       "no certificate pool",
+			body:       keyRequest("x5c", `["`+testCertBase64+`"]`),
+			noPool:     true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantDetail: ErrNoTrustData.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, serverCtx := setupTestServer()
+			r := gin.New()
+			r.Use(RequestIDMiddleware())
+			RegisterAPIRoutes(r, serverCtx)
+			serverCtx.Lock()
+			serverCtx.Metrics = NewMetrics()
+			if tt.noPool {
+				serverCtx.PipelineContext.CertPool = nil
+			}
+			serverCtx.Unlock()
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(tt.body))
+			req.Header.Set(RequestIDHeader, "req-1")
+			r.ServeHTTP(w, req)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+				var resp authzen.EvaluationResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.Equal(t, tt.wantReason, DecisionReasonCode(&resp))
+				return
+			}
+
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+			var problem Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, "about:blank", problem.Type)
+			assert.Equal(t, tt.wantStatus, problem.Status)
+			assert.Equal(t, http.StatusText(tt.wantStatus), problem.Title)
+			assert.Contains(t, problem.Detail, tt.wantDetail)
+			assert.Equal(t, "req-1", problem.RequestID)
+
+			if tt.wantStatus == http.StatusBadRequest {
+				errs := serverCtx.Metrics.ErrorsTotal.WithLabelValues("invalid_request", "authzen_decision")
+				assert.Equal(t, 1.0, promtestutil.ToFloat64(errs))
+			}
+		})
+	}
+}
+
+func TestProblemStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{&RequestError{Err: errors.New("subject.id must be present")}, http.StatusBadRequest},
+		{fmt.Errorf("evaluating: %w", &RequestError{Err: errors.New("bad key")}), http.StatusBadRequest},
+		{ErrNoTrustData, http.StatusServiceUnavailable},
+		{fmt.Errorf("tenant acme: %w", ErrNoTrustData), http.StatusServiceUnavailable},
+		{errors.New("registry failure"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, problemStatus(tt.err), tt.err.Error())
+	}
+}
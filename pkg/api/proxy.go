@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultProxyMaxAge is the longest time clients of the TSL proxy are told to
+// cache a TSL when ProxyOptions.MaxAge is not set.
+const DefaultProxyMaxAge = time.Hour
+
+// ProxyPath is the path under which the TSL proxy serves the TSLs it fetched.
+const ProxyPath = "/proxy"
+
+// ProxyOptions configures the TSL proxy, which re-serves the TSLs fetched by
+// the default pipeline under stable local URLs (see RegisterAPIRoutes).
+type ProxyOptions struct {
+	AllowUnverified bool          // Serve TSLs whose signature the load step did not verify
+	MaxAge          time.Duration // Longest time clients may cache a TSL; 0 uses DefaultProxyMaxAge
+}
+
+// ProxiedTSL describes a TSL served by the TSL proxy.
+type ProxiedTSL struct {
+	URL             string    `json:"url"`                        // Local URL of the TSL, relative to the server
+	Source          string    `json:"source"`                     // Upstream URL the TSL was fetched from
+	Territory       string    `json:"territory,omitempty"`        // Scheme territory
+	SequenceNumber  int       `json:"sequence_number,omitempty"`  // TSL sequence number
+	SHA256          string    `json:"sha256"`                     // Hex SHA-256 of the document
+	FetchedAt       time.Time `json:"fetched_at"`                 // When the document was fetched
+	NextUpdate      time.Time `json:"next_update,omitempty"`      // NextUpdate of the TSL
+	SignatureStatus string    `json:"signature_status,omitempty"` // Signature verification status, if verified by the load step
+}
+
+// ProxyIndexResponse is the response of GET /proxy.
+type ProxyIndexResponse struct {
+	Count int          `json:"count"` // Number of TSLs served
+	TSLs  []ProxiedTSL `json:"tsls"`  // TSLs served, by local URL
+}
+
+// proxyPath returns the path of the TSL fetched from source below ProxyPath:
+// the host and path of its URL, so that the local URL is stable across runs.
+// It returns false for sources that are not HTTP(S) URLs.
+func proxyPath(source string) (string, bool) {
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	p := path.Clean("/" + u.Path)
+	if p == "/" {
+		return "", false
+	}
+	return "/" + strings.ToLower(u.Host) + p, true
+}
+
+// proxiedTSLs returns the TSLs of ctx that the proxy serves, by path below
+// ProxyPath: those whose document was retained and, unless opts allow
+// unverified TSLs, whose signature was verified.
+func proxiedTSLs(ctx *pipeline.Context, opts *ProxyOptions) map[string]*etsi119612.TSL {
+	served := make(map[string]*etsi119612.TSL)
+	if ctx == nil || ctx.TSLs == nil {
+		return served
+	}
+	for _, tsl := range ctx.TSLs.ToSlice() {
+		if tsl == nil || ctx.DocumentOf(tsl) == nil {
+			continue
+		}
+		if !opts.AllowUnverified && !ctx.SignatureOf(tsl).Verified() {
+			continue
+		}
+		if p, ok := proxyPath(tsl.Source); ok {
+			if _, taken := served[p]; !taken {
+				served[p] = tsl
+			}
+		}
+	}
+	return served
+}
+
+// ProxyIndexHandler godoc
+// @Summary List the TSLs served by the TSL proxy
+// @Description Lists the TSLs of the default pipeline that the TSL proxy re-serves, with the
+// @Description local URL of each, the upstream URL it was fetched from, its digest and its
+// @Description signature verification status. Only TSLs fetched over HTTP(S) and, unless
+// @Description server.proxy.allow_unverified is set, whose signature the load step verified
+// @Description are served.
+// @Tags Proxy
+// @Produce json
+// @Success 200 {object} ProxyIndexResponse "TSLs served"
+// @Router /proxy [get]
+func ProxyIndexHandler(serverCtx *ServerContext, opts *ProxyOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverCtx.RLock()
+		defer serverCtx.RUnlock()
+
+		ctx := serverCtx.PipelineContext
+		tsls := []ProxiedTSL{}
+		for p, tsl := range proxiedTSLs(ctx, opts) {
+			doc := ctx.DocumentOf(tsl)
+			entry := ProxiedTSL{
+				URL:        ProxyPath + p,
+				Source:     tsl.Source,
+				SHA256:     doc.SHA256,
+				FetchedAt:  doc.FetchedAt.UTC(),
+				NextUpdate: doc.NextUpdate.UTC(),
+			}
+			if si := tsl.StatusList.TslSchemeInformation; si != nil {
+				entry.Territory = si.TslSchemeTerritory
+				entry.SequenceNumber = si.TSLSequenceNumber
+			}
+			if sig := ctx.SignatureOf(tsl); sig != nil {
+				entry.SignatureStatus = sig.Status
+			}
+			tsls = append(tsls, entry)
+		}
+		sort.Slice(tsls, func(i, j int) bool { return tsls[i].URL < tsls[j].URL })
+
+		c.JSON(http.StatusOK, ProxyIndexResponse{Count: len(tsls), TSLs: tsls})
+	}
+}
+
+// ProxyTSLHandler godoc
+// @Summary Download a TSL through the TSL proxy
+// @Description Serves a TSL fetched by the default pipeline byte for byte, with its original
+// @Description signature, under the host and path of its upstream URL: the TSL fetched from
+// @Description https://example.org/tsl/se.xml is served as /proxy/example.org/tsl/se.xml.
+// @Description
+// @Description Responses carry an ETag (the SHA-256 of the document), Last-Modified (as sent
+// @Description upstream, or the fetch time), and Cache-Control allowing clients to cache the TSL
+// @Description until its NextUpdate, at most server.proxy.max_age. If-None-Match,
+// @Description If-Modified-Since, Range and HEAD are supported. X-TSL-Source-URL names the
+// @Description upstream URL, and X-TSL-Signature-Status the signature verification status.
+// @Tags Proxy
+// @Produce application/xml
+// @Param path path string true "Host and path of the upstream URL"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param If-Modified-Since header string false "Last-Modified from a previous response"
+// @Success 200 {file} file "TSL document"
+// @Success 304 "Not modified since the cached copy"
+// @Failure 404 {object} map[string]string "TSL not served"
+// @Router /proxy/{path} [get]
+// @Router /proxy/{path} [head]
+func ProxyTSLHandler(serverCtx *ServerContext, opts *ProxyOptions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := path.Clean("/" + strings.TrimPrefix(c.Param("path"), "/"))
+		if host, rest, ok := strings.Cut(strings.TrimPrefix(p, "/"), "/"); ok {
+			p = "/" + strings.ToLower(host) + "/" + rest
+		}
+
+		serverCtx.RLock()
+		ctx := serverCtx.PipelineContext
+		tsl, ok := proxiedTSLs(ctx, opts)[p]
+		var doc *pipeline.SourceDocument
+		var sig *pipeline.TSLSignature
+		if ok {
+			doc = ctx.DocumentOf(tsl)
+			sig = ctx.SignatureOf(tsl)
+		}
+		serverCtx.RUnlock()
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+
+		c.Header("Content-Type", "application/xml")
+		c.Header("ETag", `"`+doc.SHA256+`"`)
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(proxyMaxAge(doc, opts, time.Now())))
+		c.Header("X-TSL-Source-URL", doc.URL)
+		if si := tsl.StatusList.TslSchemeInformation; si != nil {
+			c.Header("X-TSL-Sequence-Number", strconv.Itoa(si.TSLSequenceNumber))
+			if si.ListIssueDateTime != "" {
+				c.Header("X-TSL-Issue-Date", si.ListIssueDateTime)
+			}
+		}
+		if !doc.NextUpdate.IsZero() {
+			c.Header("X-TSL-Next-Update", doc.NextUpdate.UTC().Format(time.RFC3339))
+		}
+		if sig != nil {
+			c.Header("X-TSL-Signature-Status", sig.Status)
+		}
+
+		modified := doc.LastModified
+		if modified.IsZero() {
+			modified = doc.FetchedAt
+		}
+		// ServeContent handles HEAD, Range and the conditional request headers
+		// against the ETag set above and the modification time.
+		http.ServeContent(c.Writer, c.Request, path.Base(p), modified, bytes.NewReader(doc.Data))
+	}
+}
+
+// proxyMaxAge returns the number of seconds clients may cache doc at now: until
+// the NextUpdate of the TSL, at most the MaxAge of opts, and 0 once NextUpdate
+// has passed.
+func proxyMaxAge(doc *pipeline.SourceDocument, opts *ProxyOptions, now time.Time) int {
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultProxyMaxAge
+	}
+	if !doc.NextUpdate.IsZero() {
+		maxAge = max(min(maxAge, doc.NextUpdate.Sub(now)), 0)
+	}
+	return int(maxAge / time.Second)
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupProxyServer returns a router with the TSL proxy enabled, serving a
+// verified TSL from https://TSL.example.org/se/tsl.xml and an unverified one
+// from https://example.org/no.xml.
+func setupProxyServer(t *testing.T, opts *ProxyOptions) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx := pipeline.NewContext()
+	nextUpdate := time.Now().Add(10 * time.Minute).UTC()
+
+	se := &etsi119612.TSL{
+		Source: "https://TSL.example.org/se/tsl.xml",
+		StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
+				TslSchemeTerritory: "SE",
+				TSLSequenceNumber:  42,
+				ListIssueDateTime:  "2026-10-01T00:00:00Z",
+			},
+		},
+	}
+	no := &etsi119612.TSL{Source: "https://example.org/no.xml"}
+	file := &etsi119612.TSL{Source: "/etc/tsl/dk.xml"}
+	for _, tsl := range []*etsi119612.TSL{se, no, file} {
+		ctx.AddTSL(tsl)
+	}
+	ctx.RecordDocument(se, &pipeline.SourceDocument{
+		URL:          se.Source,
+		Data:         []byte("<TrustServiceStatusList>se</TrustServiceStatusList>"),
+		SHA256:       "abc123",
+		FetchedAt:    time.Now().Add(-time.Minute),
+		LastModified: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		NextUpdate:   nextUpdate,
+	})
+	ctx.RecordDocument(no, &pipeline.SourceDocument{URL: no.Source, Data: []byte("<no/>"), SHA256: "def456", FetchedAt: time.Now()})
+	ctx.RecordSignature(se, &pipeline.TSLSignature{Status: pipeline.SignatureVerified, Admitted: true})
+	ctx.RecordSignature(no, &pipeline.TSLSignature{Status: pipeline.SignatureUntrusted, Admitted: true})
+
+	serverCtx := &ServerContext{
+		PipelineContext: ctx,
+		LastProcessed:   time.Now(),
+		Logger:          logging.DefaultLogger(),
+		Proxy:           opts,
+	}
+	r := gin.New()
+	RegisterAPIRoutes(r, serverCtx)
+	return r
+}
+
+func TestProxyPath(t *testing.T) {
+	for source, want := range map[string]string{
+		"https://TSL.example.org/se/tsl.xml":  "/tsl.example.org/se/tsl.xml",
+		"http://example.org:8080/a/../b.xml":  "/example.org:8080/b.xml",
+		"https://example.org/tsl.xml?lang=en": "/example.org/tsl.xml",
+	} {
+		got, ok := proxyPath(source)
+		assert.True(t, ok, source)
+		assert.Equal(t, want, got, source)
+	}
+	for _, source := range []string{"/etc/tsl/se.xml", "file:///etc/tsl/se.xml", "https://example.org/", "::"} {
+		_, ok := proxyPath(source)
+		assert.False(t, ok, source)
+	}
+}
+
+func TestProxyIndexHandler(t *testing.T) {
+	r := setupProxyServer(t, &ProxyOptions{})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp ProxyIndexResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 1, resp.Count)
+	assert.Equal(t, "/proxy/tsl.example.org/se/tsl.xml", resp.TSLs[0].URL)
+	assert.Equal(t, "https://TSL.example.org/se/tsl.xml", resp.TSLs[0].Source)
+	assert.Equal(t, "SE", resp.TSLs[0].Territory)
+	assert.Equal(t, 42, resp.TSLs[0].SequenceNumber)
+	assert.Equal(t, pipeline.SignatureVerified, resp.TSLs[0].SignatureStatus)
+
+	r = setupProxyServer(t, &ProxyOptions{AllowUnverified: true})
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy", nil))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Count, "unverified TSLs are listed when allowed, files never")
+}
+
+func TestProxyTSLHandler(t *testing.T) {
+	r := setupProxyServer(t, &ProxyOptions{MaxAge: time.Hour})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/TSL.example.org/se/tsl.xml", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<TrustServiceStatusList>se</TrustServiceStatusList>", w.Body.String())
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+	assert.Equal(t, "Thu, 01 Oct 2026 00:00:00 GMT", w.Header().Get("Last-Modified"))
+	assert.Regexp(t, `^public, max-age=(59\d|600)$`, w.Header().Get("Cache-Control"), "capped at NextUpdate")
+	assert.Equal(t, "https://TSL.example.org/se/tsl.xml", w.Header().Get("X-TSL-Source-URL"))
+	assert.Equal(t, "42", w.Header().Get("X-TSL-Sequence-Number"))
+	assert.Equal(t, "2026-10-01T00:00:00Z", w.Header().Get("X-TSL-Issue-Date"))
+	assert.NotEmpty(t, w.Header().Get("X-TSL-Next-Update"))
+	assert.Equal(t, pipeline.SignatureVerified, w.Header().Get("X-TSL-Signature-Status"))
+
+	t.Run("not modified", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/proxy/tsl.example.org/se/tsl.xml", nil)
+		req.Header.Set("If-None-Match", `"abc123"`)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("head", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/proxy/tsl.example.org/se/tsl.xml", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, `"abc123"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("not served", func(t *testing.T) {
+		for _, p := range []string{"/proxy/example.org/no.xml", "/proxy/example.org/missing.xml", "/proxy/etc/tsl/dk.xml"} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+			assert.Equal(t, http.StatusNotFound, w.Code, p)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		r, _ := setupTestServer()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/tsl.example.org/se/tsl.xml", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestProxyMaxAge(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	doc := &pipeline.SourceDocument{}
+	assert.Equal(t, 3600, proxyMaxAge(doc, &ProxyOptions{}, now))
+	assert.Equal(t, 300, proxyMaxAge(doc, &ProxyOptions{MaxAge: 5 * time.Minute}, now))
+
+	doc.NextUpdate = now.Add(2 * time.Minute)
+	assert.Equal(t, 120, proxyMaxAge(doc, &ProxyOptions{}, now))
+	doc.NextUpdate = now.Add(-time.Minute)
+	assert.Equal(t, 0, proxyMaxAge(doc, &ProxyOptions{}, now))
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// PublishedFileHandler godoc
+// @Summary Download a published file
+// @Description Serves files written by the publish step (TSL XML, .sha256 and .meta sidecars,
+// @Description HTML) from the configured publish directory.
+// @Description
+// @Description Responses carry an ETag (the SHA-256 of the file, taken from the .sha256 sidecar
+// @Description when present) and Last-Modified, and honour If-None-Match, If-Modified-Since and
+// @Description Range. HEAD is supported so that mirrors can check for changes without downloading.
+// @Description For TSL XML files with a .meta sidecar, X-TSL-Sequence-Number and X-TSL-Issue-Date
+// @Description headers are included.
+// @Tags Published
+// @Produce application/xml
+// @Param filepath path string true "Path of the file relative to the publish directory"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param If-Modified-Since header string false "Last-Modified from a previous response"
+// @Success 200 {file} file "File content"
+// @Success 304 "Not modified since the cached copy"
+// @Failure 404 {object} map[string]string "File not found"
+// @Router /published/{filepath} [get]
+// @Router /published/{filepath} [head]
+func PublishedFileHandler(serverCtx *ServerContext, dir string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path, ok := resolvePublishedPath(dir, c.Param("filepath"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+
+		digest, err := publishedDigest(path, f)
+		if err != nil {
+			serverCtx.Logger.Error("Failed to hash published file",
+				logging.F("file", path),
+				logging.F("error", err.Error()))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+			return
+		}
+		c.Header("ETag", `"`+digest+`"`)
+
+		if meta, err := pipeline.ReadPublishedMeta(path); err == nil {
+			c.Header("X-TSL-Sequence-Number", strconv.Itoa(meta.SequenceNumber))
+			if meta.IssueDate != "" {
+				c.Header("X-TSL-Issue-Date", meta.IssueDate)
+			}
+		}
+
+		switch filepath.Ext(path) {
+		case ".xml":
+			c.Header("Content-Type", "application/xml")
+		case pipeline.SHA256SidecarExt:
+			c.Header("Content-Type", "text/plain; charset=utf-8")
+		case pipeline.MetaSidecarExt:
+			c.Header("Content-Type", "application/json")
+		}
+
+		// ServeContent handles HEAD, Range and the conditional request headers
+		// against the ETag set above and the file modification time.
+		http.ServeContent(c.Writer, c.Request, filepath.Base(path), info.ModTime(), f)
+	}
+}
+
+// resolvePublishedPath maps a request path to a file inside dir. It rejects
+// paths that would escape dir.
+func resolvePublishedPath(dir, requested string) (string, bool) {
+	rel := filepath.Clean("/" + strings.TrimPrefix(requested, "/"))
+	if rel == "/" {
+		return "", false
+	}
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	within, err := filepath.Rel(dir, path)
+	if err != nil || within == ".." || strings.HasPrefix(within, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return path, true
+}
+
+// publishedDigest returns the hex SHA-256 of the file at path. The digest is
+// read from the .sha256 sidecar when it is present and at least as new as the
+// file; otherwise it is computed from f, which is rewound afterwards.
+func publishedDigest(path string, f *os.File) (string, error) {
+	if digest, ok := readSHA256Sidecar(path); ok {
+		return digest, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readSHA256Sidecar reads the digest from the sha256sum-format sidecar of path.
+func readSHA256Sidecar(path string) (string, bool) {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	sidecar := path + pipeline.SHA256SidecarExt
+	sidecarInfo, err := os.Stat(sidecar)
+	if err != nil || sidecarInfo.ModTime().Before(fileInfo.ModTime()) {
+		return "", false
+	}
+
+	f, err := os.Open(sidecar)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", false
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", false
+	}
+	return fields[0], true
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupPublishedRouter creates a publish directory with one TSL file and its
+// sidecars and returns a router serving it.
+func setupPublishedRouter(t *testing.T) (*gin.Engine, string, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	data := []byte("<TrustServiceStatusList></TrustServiceStatusList>")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tsl-se.xml"), data, 0644))
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tsl-se.xml.sha256"), []byte(digest+"  tsl-se.xml\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tsl-se.xml.meta"),
+		[]byte(`{"file":"tsl-se.xml","sequence_number":7,"issue_date":"2025-01-01T00:00:00Z"}`), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	serverCtx := NewServerContext(logging.NewLogger(logging.InfoLevel))
+	serverCtx.PipelineContext = pipeline.NewContext()
+	serverCtx.PublishDir = dir
+
+	router := gin.New()
+	RegisterAPIRoutes(router, serverCtx)
+	return router, dir, digest
+}
+
+func TestPublishedFileHandler_Get(t *testing.T) {
+	router, _, digest := setupPublishedRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/published/tsl-se.xml", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"`+digest+`"`, w.Header().Get("ETag"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "7", w.Header().Get("X-TSL-Sequence-Number"))
+	assert.Equal(t, "2025-01-01T00:00:00Z", w.Header().Get("X-TSL-Issue-Date"))
+	assert.Contains(t, w.Body.String(), "<TrustServiceStatusList>")
+}
+
+func TestPublishedFileHandler_Head(t *testing.T) {
+	router, _, digest := setupPublishedRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("HEAD", "/published/tsl-se.xml", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"`+digest+`"`, w.Header().Get("ETag"))
+	assert.Empty(t, w.Body.String())
+}
+
+func TestPublishedFileHandler_Conditional(t *testing.T) {
+	router, dir, digest := setupPublishedRouter(t)
+
+	req := httptest.NewRequest("GET", "/published/tsl-se.xml", nil)
+	req.Header.Set("If-None-Match", `"`+digest+`"`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	req = httptest.NewRequest("GET", "/published/tsl-se.xml", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	info, err := os.Stat(filepath.Join(dir, "tsl-se.xml"))
+	require.NoError(t, err)
+	req = httptest.NewRequest("GET", "/published/tsl-se.xml", nil)
+	req.Header.Set("If-Modified-Since", info.ModTime().Add(time.Second).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestPublishedFileHandler_ComputedETag(t *testing.T) {
+	router, dir, _ := setupPublishedRouter(t)
+
+	data := []byte("plain file without sidecar")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.xml"), data, 0644))
+	sum := sha256.Sum256(data)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/published/other.xml", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"`+hex.EncodeToString(sum[:])+`"`, w.Header().Get("ETag"))
+	assert.Equal(t, string(data), w.Body.String(), "body must be complete after hashing")
+	assert.Empty(t, w.Header().Get("X-TSL-Sequence-Number"))
+}
+
+func TestPublishedFileHandler_NotFound(t *testing.T) {
+	router, _, _ := setupPublishedRouter(t)
+
+	for _, path := range []string{"/published/missing.xml", "/published/sub", "/published/", "/published/../published.go"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
+
+func TestResolvePublishedPath(t *testing.T) {
+	dir := filepath.Join("srv", "published")
+
+	path, ok := resolvePublishedPath(dir, "/tsl.xml")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "tsl.xml"), path)
+
+	path, ok = resolvePublishedPath(dir, "/../../etc/passwd")
+	assert.True(t, ok, "cleaned paths stay within the directory")
+	assert.Equal(t, filepath.Join(dir, "etc", "passwd"), path)
+
+	_, ok = resolvePublishedPath(dir, "/")
+	assert.False(t, ok)
+}
+
+func TestPublishedRoutes_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serverCtx := NewServerContext(logging.NewLogger(logging.InfoLevel))
+	serverCtx.PipelineContext = pipeline.NewContext()
+
+	router := gin.New()
+	RegisterAPIRoutes(router, serverCtx)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/published/tsl.xml", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewRateLimiter(t *testing.T) {
@@ -176,3 +177,38 @@ func TestRateLimiter_CleanupOldLimiters(t *testing.T) {
 	// Limiters should still exist (cleanup is currently a no-op)
 	assert.Equal(t, 3, len(rl.limiters))
 }
+
+func TestRateLimiter_Middleware_TrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rl := NewRateLimiter(1, 1)
+
+	router := gin.New()
+	require.NoError(t, router.SetTrustedProxies([]string{"10.0.0.0/8"}))
+	router.Use(rl.Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.String(200, c.ClientIP())
+	})
+
+	request := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Behind the trusted proxy, each client gets its own limit
+	w := request("10.0.0.1:1234", "203.0.113.1")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "203.0.113.1", w.Body.String())
+	assert.Equal(t, 200, request("10.0.0.1:1234", "203.0.113.2").Code)
+	assert.Equal(t, 429, request("10.0.0.1:1234", "203.0.113.1").Code)
+
+	// An untrusted peer cannot choose its client IP
+	w = request("198.51.100.7:1234", "203.0.113.3")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "198.51.100.7", w.Body.String())
+	assert.Equal(t, 429, request("198.51.100.7:1234", "203.0.113.4").Code)
+}
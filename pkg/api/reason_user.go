@@ -0,0 +1,126 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUserMessages are the English end-user messages of the reason codes
+// of denied decisions. A message catalog can translate them and replace them.
+var defaultUserMessages = map[string]string{
+	ReasonExpired:           "The certificate has expired or is not yet valid.",
+	ReasonUnknownAuthority:  "The issuer is not recognised as trusted.",
+	ReasonRevoked:           "The issuer's trust status has been revoked or withdrawn.",
+	ReasonPolicyMismatch:    "The issuer is trusted, but not for this use.",
+	ReasonTerritoryMismatch: "The issuer is trusted, but not in the required country or region.",
+	ReasonNameMismatch:      "The certificate was not issued to the name it is presented for.",
+	ReasonInvalidRequest:    "The key or certificate could not be read.",
+	ReasonUnavailable:       "Trust information is temporarily unavailable. Please try again later.",
+	ReasonTimeout:           "The trust check took too long. Please try again later.",
+	ReasonOverride:          "The certificate has been distrusted by the operator.",
+	ReasonError:             "The trust check failed. Please try again later.",
+	ReasonOther:             "The issuer could not be verified as trusted.",
+}
+
+// MessageCatalog holds the end-user messages of the reason codes of denied
+// decisions, by language, returned as reason_user in AuthZEN responses so
+// that wallets can show a friendly explanation rather than the technical
+// reason. The English messages are built in; a catalog file adds other
+// languages and may replace them. A nil MessageCatalog has the built-in
+// messages only.
+type MessageCatalog struct {
+	messages map[string]map[string]string // Language, then reason code, to message
+}
+
+// LoadMessageCatalog returns the message catalog with the messages of the
+// YAML file at path, if path is not empty, over the built-in ones. The file
+// maps language tags to reason codes to messages:
+//
+//	sv:
+//	  expired: "Certifikatet har gått ut eller är ännu inte giltigt."
+//	  unknown_authority: "Utfärdaren är inte känd som betrodd."
+//
+// Codes a language has no message for fall back to the best matching
+// language that has one, and to English. It returns an error for a file that
+// cannot be read, an invalid language tag, an unknown reason code or an empty
+// message.
+func LoadMessageCatalog(path string) (*MessageCatalog, error) {
+	catalog := &MessageCatalog{messages: map[string]map[string]string{
+		i18n.DefaultLanguage: defaultUserMessages,
+	}}
+	if path == "" {
+		return catalog, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message catalog: %w", err)
+	}
+	var file map[string]map[string]string
+	if err := yamlutil.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse message catalog %s: %w", path, err)
+	}
+	for lang, messages := range file {
+		if err := i18n.ValidateLanguages([]string{lang}); err != nil {
+			return nil, fmt.Errorf("message catalog %s: %w", path, err)
+		}
+		merged := map[string]string{}
+		for code, msg := range catalog.messages[lang] {
+			merged[code] = msg
+		}
+		for code, msg := range messages {
+			if _, ok := defaultUserMessages[code]; !ok {
+				return nil, fmt.Errorf("message catalog %s: unknown reason code %q in language %q", path, code, lang)
+			}
+			if msg == "" {
+				return nil, fmt.Errorf("message catalog %s: empty message for %q in language %q", path, code, lang)
+			}
+			merged[code] = msg
+		}
+		catalog.messages[lang] = merged
+	}
+	return catalog, nil
+}
+
+// UserReason returns the end-user explanation of a denial with the given
+// reason code, in the language that best matches langs. Codes without a
+// message are explained as ReasonOther.
+func (c *MessageCatalog) UserReason(code string, langs []string) *authzen.UserReason {
+	messages := map[string]map[string]string{i18n.DefaultLanguage: defaultUserMessages}
+	if c != nil {
+		messages = c.messages
+	}
+	if _, ok := defaultUserMessages[code]; !ok {
+		code = ReasonOther
+	}
+
+	var available []string
+	for lang, msgs := range messages {
+		if msgs[code] != "" {
+			available = append(available, lang)
+		}
+	}
+	// Sorted, so that languages matching equally well are picked consistently.
+	// English always has a message, so one is found.
+	sort.Strings(available)
+	lang := i18n.Match(available, langs)
+	return &authzen.UserReason{Code: code, Message: messages[lang][code], Lang: lang}
+}
+
+// setUserReason explains resp, if it is a denial with the given reason code,
+// to the end user in the language of the request answered by c.
+func setUserReason(c *gin.Context, resp *authzen.EvaluationResponse, catalog *MessageCatalog, code string) {
+	if resp.Decision {
+		return
+	}
+	if resp.Context == nil {
+		resp.Context = &authzen.EvaluationResponseContext{}
+	}
+	resp.Context.ReasonUser = catalog.UserReason(code, requestLanguages(c))
+}
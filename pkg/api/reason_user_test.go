@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCatalog writes a message catalog file and returns its path.
+func writeCatalog(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "messages.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestMessageCatalog_UserReason(t *testing.T) {
+	catalog, err := LoadMessageCatalog(writeCatalog(t, `
+sv:
+  expired: "Certifikatet har gått ut."
+en:
+  revoked: "Revoked by the scheme operator."
+`))
+	require.NoError(t, err)
+
+	got := catalog.UserReason(ReasonExpired, []string{"sv-FI", "en"})
+	assert.Equal(t, &authzen.UserReason{Code: ReasonExpired, Message: "Certifikatet har gått ut.", Lang: "sv"}, got)
+
+	// Codes without a Swedish message fall back to English
+	got = catalog.UserReason(ReasonRevoked, []string{"sv"})
+	assert.Equal(t, &authzen.UserReason{Code: ReasonRevoked, Message: "Revoked by the scheme operator.", Lang: "en"}, got)
+	got = catalog.UserReason(ReasonTimeout, []string{"sv"})
+	assert.Equal(t, defaultUserMessages[ReasonTimeout], got.Message)
+
+	// Unknown codes are explained as other
+	got = catalog.UserReason("bogus", nil)
+	assert.Equal(t, ReasonOther, got.Code)
+	assert.Equal(t, defaultUserMessages[ReasonOther], got.Message)
+
+	// A nil catalog has the built-in messages
+	var none *MessageCatalog
+	got = none.UserReason(ReasonExpired, []string{"sv"})
+	assert.Equal(t, &authzen.UserReason{Code: ReasonExpired, Message: defaultUserMessages[ReasonExpired], Lang: "en"}, got)
+}
+
+func TestLoadMessageCatalog_Errors(t *testing.T) {
+	catalog, err := LoadMessageCatalog("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultUserMessages[ReasonExpired], catalog.UserReason(ReasonExpired, nil).Message)
+
+	for content, want := range map[string]string{
+		"sv_SE:\n  expired: x\n": "invalid language tag",
+		"sv:\n  bogus: x\n":      `unknown reason code "bogus"`,
+		"sv:\n  expired: \"\"\n": "empty message",
+		"sv: [x]\n":              "failed to parse message catalog",
+	} {
+		_, err := LoadMessageCatalog(writeCatalog(t, content))
+		assert.ErrorContains(t, err, want, content)
+	}
+	_, err = LoadMessageCatalog(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read message catalog")
+}
+
+func TestAuthZENDecisionHandler_ReasonUser(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	serverCtx.TenantPolicy = testTenantPolicy()
+	catalog, err := LoadMessageCatalog(writeCatalog(t, "sv:\n  policy_mismatch: \"Utfärdaren är inte betrodd för detta.\"\n"))
+	require.NoError(t, err)
+	serverCtx.UserMessages = catalog
+
+	untrustedCA, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	untrusted, err := testutil.NewLeaf(untrustedCA, "untrusted leaf")
+	require.NoError(t, err)
+
+	evaluate := func(cert, context, acceptLanguage string) authzen.EvaluationResponse {
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]},"action":{"name":"issuer"},"context":%s}`,
+			cert, context)
+		req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body))
+		req.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp authzen.EvaluationResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := evaluate(testCertBase64, `{"tenant":"bank","purpose":"kyc"}`, "sv")
+	assert.True(t, resp.Decision)
+	assert.Nil(t, resp.Context, "approvals have no reason")
+
+	resp = evaluate(testCertBase64, `{"tenant":"shop","purpose":"kyc"}`, "sv-SE, en;q=0.5")
+	require.NotNil(t, resp.Context.ReasonUser)
+	assert.Equal(t, authzen.UserReason{Code: ReasonPolicyMismatch, Message: "Utfärdaren är inte betrodd för detta.", Lang: "sv"}, *resp.Context.ReasonUser)
+	assert.Contains(t, resp.Context.Reason["error"], `tenant "shop" is not authorized`, "the admin reason is kept")
+
+	resp = evaluate(untrusted.Base64(), `{"tenant":"bank","purpose":"kyc"}`, "sv")
+	require.NotNil(t, resp.Context.ReasonUser)
+	assert.Equal(t, DecisionReasonCode(&resp), resp.Context.ReasonUser.Code)
+	assert.Equal(t, "en", resp.Context.ReasonUser.Lang)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/gin-gonic/gin"
+)
+
+// SchemasPath is the path under which the JSON Schemas of the AuthZEN
+// evaluation messages are served.
+const SchemasPath = "/schemas"
+
+// SchemaContentType is the media type of JSON Schema documents.
+const SchemaContentType = "application/schema+json"
+
+// evaluationSchemas are the schemas served under SchemasPath, by file name.
+var evaluationSchemas = map[string]func() map[string]interface{}{
+	"evaluation-request.json":  authzen.EvaluationRequestSchema,
+	"evaluation-response.json": authzen.EvaluationResponseSchema,
+}
+
+// SchemaInfo describes a JSON Schema served under SchemasPath.
+// @Description JSON Schema served by this instance
+type SchemaInfo struct {
+	Name        string `json:"name" example:"evaluation-request.json"`                                // File name under /schemas/
+	Title       string `json:"title" example:"EvaluationRequest"`                                     // Go type the schema is generated from
+	Description string `json:"description,omitempty"`                                                 // What the schema describes
+	URL         string `json:"url" example:"https://pdp.example.com/schemas/evaluation-request.json"` // Absolute URL and $id of the schema
+}
+
+// schemaDocuments returns the schemas of evaluationSchemas with their $id set
+// to their URL under baseURL, and their descriptions ordered by name.
+func schemaDocuments(baseURL string) (map[string][]byte, []SchemaInfo) {
+	docs := make(map[string][]byte, len(evaluationSchemas))
+	infos := make([]SchemaInfo, 0, len(evaluationSchemas))
+	for name, schemaOf := range evaluationSchemas {
+		schema := schemaOf()
+		url := baseURL + SchemasPath + "/" + name
+		schema["$id"] = url
+
+		// The schemas only contain JSON values
+		docs[name], _ = json.MarshalIndent(schema, "", "  ")
+
+		info := SchemaInfo{Name: name, URL: url}
+		info.Title, _ = schema["title"].(string)
+		info.Description, _ = schema["description"].(string)
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return docs, infos
+}
+
+// SchemasHandler godoc
+// @Summary List JSON Schemas
+// @Description Lists the JSON Schemas (draft 2020-12) of the AuthZEN Trust Registry Profile evaluation
+// @Description request and response implemented by this server. They are generated from the Go types
+// @Description of the messages, and can be used to validate clients or generate models in other
+// @Description languages.
+// @Tags AuthZEN
+// @Produce json
+// @Success 200 {object} map[string][]SchemaInfo "schemas"
+// @Router /schemas [get]
+func SchemasHandler(baseURL string) gin.HandlerFunc {
+	_, infos := schemaDocuments(baseURL)
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"schemas": infos})
+	}
+}
+
+// SchemaHandler godoc
+// @Summary Get a JSON Schema
+// @Description Returns a JSON Schema (draft 2020-12) listed by GET /schemas, such as
+// @Description evaluation-request.json or evaluation-response.json. The $id of the schema is its
+// @Description URL on this instance. The request schema includes the rules of the Trust Registry
+// @Description Profile, except that resource.id must equal subject.id.
+// @Tags AuthZEN
+// @Produce json
+// @Param name path string true "Schema file name" Enums(evaluation-request.json, evaluation-response.json)
+// @Success 200 {object} map[string]interface{} "JSON Schema"
+// @Failure 404 {object} map[string]string "Unknown schema"
+// @Router /schemas/{name} [get]
+func SchemaHandler(baseURL string) gin.HandlerFunc {
+	docs, _ := schemaDocuments(baseURL)
+	return func(c *gin.Context) {
+		doc, ok := docs[c.Param("name")]
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown schema"})
+			return
+		}
+		c.Data(http.StatusOK, SchemaContentType, doc)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemasHandler(t *testing.T) {
+	r, _ := setupTestServer()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schemas", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Schemas []SchemaInfo `json:"schemas"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Schemas, 2)
+	assert.Equal(t, SchemaInfo{
+		Name:        "evaluation-request.json",
+		Title:       "EvaluationRequest",
+		Description: authzen.EvaluationRequestSchema()["description"].(string),
+		URL:         "http://localhost:6001/schemas/evaluation-request.json",
+	}, resp.Schemas[0])
+	assert.Equal(t, "evaluation-response.json", resp.Schemas[1].Name)
+	assert.Equal(t, "EvaluationResponse", resp.Schemas[1].Title)
+
+	// Every listed schema is served at its URL
+	for _, info := range resp.Schemas {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schemas/"+info.Name, nil))
+		require.Equal(t, http.StatusOK, w.Code, info.Name)
+		assert.Equal(t, SchemaContentType, w.Header().Get("Content-Type"))
+
+		var schema map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+		assert.Equal(t, info.URL, schema["$id"])
+		assert.Equal(t, authzen.SchemaDialect, schema["$schema"])
+		assert.Equal(t, info.Title, schema["title"])
+	}
+}
+
+func TestSchemaHandler_Request(t *testing.T) {
+	r, _ := setupTestServer()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schemas/evaluation-request.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The served schema is the generated one, with its $id set
+	var served, generated map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	doc, err := json.Marshal(authzen.EvaluationRequestSchema())
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(doc, &generated))
+	generated["$id"] = "http://localhost:6001/schemas/evaluation-request.json"
+	assert.Equal(t, generated, served)
+}
+
+func TestSchemaHandler_Unknown(t *testing.T) {
+	r, _ := setupTestServer()
+
+	for _, path := range []string{"/schemas/unknown.json", "/schemas/evaluation-request"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+	}
+}
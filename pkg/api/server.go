@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// NewServer returns a gin engine serving the API of serverCtx, with the
+// middleware stack configured by cfg. Middleware runs in this order:
+//
+//  1. Recovery: panics are logged to serverCtx.Logger and answered with 500
+//  2. Request IDs (see RequestIDMiddleware)
+//  3. Request logging: to the access log if cfg.Logging.AccessLog is set,
+//     otherwise to serverCtx.Logger
+//  4. Metrics, with the /metrics endpoint, if serverCtx.Metrics is set
+//  5. The /healthz and /readyz probes, which are never rate limited
+//  6. CORS, if cfg.Security.EnableCORS is set
+//  7. Rate limiting, if cfg.Security.RateLimitRPS is positive or
+//     serverCtx.RateLimiter is already set
+//  8. The API routes, with admin authentication on admin endpoints and, if
+//     cfg.Server.MaxConcurrentEvaluations is positive or
+//     serverCtx.EvaluationLimit is already set, a concurrency limit on
+//     POST /evaluation
+//
+// Unless the GIN_MODE environment variable selects a mode, gin runs in
+// release mode, or in debug mode at the debug log level. Client IPs are
+// resolved using cfg.Server.TrustedProxies.
+//
+// Parameters:
+//   - cfg: The validated server configuration
+//   - serverCtx: The server context whose state the API serves
+//
+// Returns:
+//   - The engine, ready to be used as an http.Handler
+//   - An error if the trusted proxies or the access log cannot be set up
+func NewServer(cfg *config.Config, serverCtx *ServerContext) (*gin.Engine, error) {
+	if os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(ginMode(cfg.Logging.Level))
+	}
+
+	r := gin.New()
+	// Client IPs in logs, audit records and rate limiting are taken from
+	// X-Forwarded-For only for requests from trusted proxies
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	if len(cfg.Server.TrustedProxies) > 0 {
+		serverCtx.Logger.Info("Trusted proxies configured",
+			logging.F("proxies", cfg.Server.TrustedProxies))
+	}
+
+	r.Use(RecoveryMiddleware(serverCtx.Logger), RequestIDMiddleware())
+
+	// Requests are logged to the access log if one is configured, never
+	// interleaved with the application log
+	if cfg.Logging.AccessLog != "" {
+		out, err := logging.OpenOutput(cfg.Logging.AccessLog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open access log: %w", err)
+		}
+		accessLog, err := AccessLogMiddleware(out, cfg.Logging.AccessLogFormat)
+		if err != nil {
+			return nil, err
+		}
+		r.Use(accessLog)
+		serverCtx.Logger.Info("Access log enabled",
+			logging.F("output", cfg.Logging.AccessLog),
+			logging.F("format", cfg.Logging.AccessLogFormat))
+	} else {
+		r.Use(RequestLogMiddleware(serverCtx.Logger))
+	}
+
+	if serverCtx.Metrics != nil {
+		RegisterMetricsEndpoint(r, serverCtx.Metrics)
+	}
+
+	// Probes are registered before CORS and rate limiting so that a busy
+	// server is not restarted by its orchestrator
+	RegisterHealthEndpoints(r, serverCtx)
+
+	if cfg.Security.EnableCORS {
+		r.Use(CORSMiddleware(cfg.Security.AllowedOrigins))
+		serverCtx.Logger.Info("CORS enabled",
+			logging.F("allowed_origins", cfg.Security.AllowedOrigins))
+	}
+
+	if serverCtx.RateLimiter == nil && cfg.Security.RateLimitRPS > 0 {
+		serverCtx.RateLimiter = NewRateLimiter(cfg.Security.RateLimitRPS, rateLimitBurst(cfg.Security.RateLimitRPS))
+	}
+	if serverCtx.EvaluationLimit == nil && cfg.Server.MaxConcurrentEvaluations > 0 {
+		serverCtx.EvaluationLimit = NewConcurrencyLimiter(cfg.Server.MaxConcurrentEvaluations)
+	}
+	RegisterAPIRoutes(r, serverCtx)
+
+	return r, nil
+}
+
+// rateLimitBurst returns the burst size for a rate limit of rps requests per
+// second: 10% of rps, and at least 5.
+func rateLimitBurst(rps int) int {
+	return max(rps/10, 5)
+}
+
+// ginMode returns the gin mode for a log level.
+func ginMode(level string) string {
+	if strings.EqualFold(level, "debug") {
+		return gin.DebugMode
+	}
+	return gin.ReleaseMode
+}
+
+// RecoveryMiddleware recovers from panics in handlers, logging them with the
+// request ID and stack trace to logger, and answers 500 Internal Server Error
+// if nothing has been written yet.
+func RecoveryMiddleware(logger logging.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		logger.Error("Panic while handling request",
+			logging.F("error", fmt.Sprint(err)),
+			logging.F("method", c.Request.Method),
+			logging.F("path", c.Request.URL.Path),
+			logging.F("request_id", RequestID(c)),
+			logging.F("stack", string(debug.Stack())))
+		if !c.Writer.Written() {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		c.Abort()
+	})
+}
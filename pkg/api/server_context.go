@@ -4,6 +4,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/SUNET/go-trust/pkg/analytics"
+	"github.com/SUNET/go-trust/pkg/audit"
 	"github.com/SUNET/go-trust/pkg/logging"
 	"github.com/SUNET/go-trust/pkg/pipeline"
 	"github.com/SUNET/go-trust/pkg/registry"
@@ -20,14 +22,114 @@ import (
 // The ServerContext always has a configured Logger for API operations. If none is provided
 // during initialization, a default logger is used.
 type ServerContext struct {
-	mu              sync.RWMutex              // Mutex for thread-safe access
-	RegistryManager *registry.RegistryManager // Multi-registry manager (new architecture)
-	PipelineContext *pipeline.Context         // Legacy pipeline context (for backward compatibility)
-	LastProcessed   time.Time                 // Timestamp when data was last processed
-	Logger          logging.Logger            // Logger for API operations (never nil)
-	RateLimiter     *RateLimiter              // Rate limiter for API endpoints (optional)
-	Metrics         *Metrics                  // Prometheus metrics (optional)
-	BaseURL         string                    // Base URL for the PDP (e.g., "https://pdp.example.com") for .well-known discovery
+	mu              sync.RWMutex                 // Mutex for thread-safe access
+	RegistryManager *registry.RegistryManager    // Multi-registry manager (new architecture)
+	PipelineContext *pipeline.Context            // Legacy pipeline context (for backward compatibility)
+	LastProcessed   time.Time                    // Timestamp when data was last processed
+	Logger          logging.Logger               // Logger for API operations (never nil)
+	RateLimiter     *RateLimiter                 // Rate limiter for API endpoints (optional)
+	EvaluationLimit *ConcurrencyLimiter          // Cap on concurrent POST /evaluation requests (optional)
+	Metrics         *Metrics                     // Prometheus metrics (optional)
+	BaseURL         string                       // Base URL for the PDP (e.g., "https://pdp.example.com") for .well-known discovery
+	RunHistory      []*pipeline.RunReport        // Reports of the most recent pipeline runs, newest first
+	PublishDir      string                       // Directory served under /published (optional)
+	TenantPolicy    *TenantPolicy                // Allow-list of AuthZEN tenants and purposes (optional)
+	TenantContexts  map[string]*pipeline.Context // Pipeline contexts of tenants with their own pipeline
+	AdminToken      string                       // Bearer token for admin endpoints; empty disables them
+	Sources         map[string]*SourceStatus     // Reachability of upstream TSL sources by URL (see RecordSources)
+	BuildInfo       *BuildInfo                   // Build and feature information reported by GET /version (optional)
+	Overrides       *Overrides                   // Fingerprint deny- and allow-lists applied to decisions (optional)
+	DecisionTimeout time.Duration                // Deadline of each trust decision; 0 uses DefaultDecisionTimeout
+	ServerTiming    bool                         // Report decision phase durations in the Server-Timing header
+	Denials         *DenialLog                   // Most recent denied decisions, served by GET /admin/denials (optional)
+	DenyWebhook     *DenyWebhook                 // Sends denied decisions to an HTTP endpoint (optional)
+	Audit           *audit.Exporter              // Exports decisions and admin requests as audit events (optional)
+	Analytics       *analytics.Rollup            // Rolls up decision counts into files for offline analysis (optional)
+	Proxy           *ProxyOptions                // Re-serves the TSLs fetched by the default pipeline under /proxy (optional)
+	UserMessages    *MessageCatalog              // End-user messages of denial reasons; nil uses the built-in English ones
+	StagedPolicy    *StagedPolicy                // Policy requests are also evaluated against, without effect (optional)
+	ClientBinding   *ClientBinding               // Binds requests of some actions to the TLS client certificate (optional)
+	NameMatcher     *NameMatcher                 // Requires subject.id to name the leaf certificate of resource.key (optional)
+	ComponentChecks []ComponentCheck             // Health of further subsystems reported by GET /status/components (optional)
+
+	generation uint64               // Generation of the most recently installed pipeline Context (see InstallContext)
+	updaters   []*BackgroundUpdater // Updaters started for this ServerContext (see TriggerRefresh)
+}
+
+// TenantPipelineContext returns the pipeline Context used to evaluate requests
+// of the given tenant: the tenant's own Context if it has a pipeline, otherwise
+// the default PipelineContext. The caller must hold the read lock.
+func (s *ServerContext) TenantPipelineContext(tenant string) *pipeline.Context {
+	if tenant != "" {
+		if ctx, ok := s.TenantContexts[tenant]; ok {
+			return ctx
+		}
+	}
+	return s.PipelineContext
+}
+
+// InstallContext makes ctx the pipeline Context of the given tenant, or the
+// default PipelineContext if tenant is empty, and assigns it the next pool
+// generation. Because the swap happens under the write lock, an evaluation
+// that took its snapshot under the read lock uses either the old or the new
+// Context in full, and its decision reports that Context's generation. The
+// caller must hold the write lock.
+func (s *ServerContext) InstallContext(tenant string, ctx *pipeline.Context) {
+	s.generation++
+	ctx.Generation = s.generation
+	if tenant == "" {
+		s.PipelineContext = ctx
+		s.LastProcessed = time.Now()
+		return
+	}
+	if s.TenantContexts == nil {
+		s.TenantContexts = make(map[string]*pipeline.Context)
+	}
+	s.TenantContexts[tenant] = ctx
+}
+
+// PoolGeneration returns the generation of the most recently installed
+// pipeline Context, or 0 if none was installed. The caller must hold the
+// read lock.
+func (s *ServerContext) PoolGeneration() uint64 {
+	return s.generation
+}
+
+// TriggerRefresh asks every background updater started for this ServerContext
+// to run its pipeline now, without waiting for its next tick. Updaters that
+// already have a run pending are not asked again. The caller must hold the
+// read lock.
+//
+// Returns:
+//   - The number of updaters that will run their pipeline
+func (s *ServerContext) TriggerRefresh() int {
+	triggered := 0
+	for _, u := range s.updaters {
+		if u.Trigger() {
+			triggered++
+		}
+	}
+	return triggered
+}
+
+// maxRunHistory is the number of pipeline run reports kept in ServerContext.RunHistory.
+const maxRunHistory = 10
+
+// RecordRun adds a pipeline run report to the front of RunHistory, discarding the
+// oldest entry once maxRunHistory reports are kept. The caller must hold the write lock.
+func (s *ServerContext) RecordRun(report *pipeline.RunReport) {
+	if report == nil {
+		return
+	}
+	history := make([]*pipeline.RunReport, 0, maxRunHistory)
+	history = append(history, report)
+	for _, r := range s.RunHistory {
+		if len(history) == maxRunHistory {
+			break
+		}
+		history = append(history, r)
+	}
+	s.RunHistory = history
 }
 
 // Lock locks the ServerContext for writing.
@@ -76,5 +178,20 @@ func (s *ServerContext) WithLogger(logger logging.Logger) *ServerContext {
 		RateLimiter:     s.RateLimiter,
 		Metrics:         s.Metrics,
 		BaseURL:         s.BaseURL,
+		PublishDir:      s.PublishDir,
+		TenantPolicy:    s.TenantPolicy,
+		TenantContexts:  s.TenantContexts,
+		AdminToken:      s.AdminToken,
+		RunHistory:      s.RunHistory,
+		Sources:         s.Sources,
+		BuildInfo:       s.BuildInfo,
+		Overrides:       s.Overrides,
+		DecisionTimeout: s.DecisionTimeout,
+		Denials:         s.Denials,
+		DenyWebhook:     s.DenyWebhook,
+		Audit:           s.Audit,
+		Proxy:           s.Proxy,
+		generation:      s.generation,
+		updaters:        s.updaters,
 	}
 }
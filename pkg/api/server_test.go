@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/config"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer returns the engine built by NewServer for cfg, with the
+// application log written to logs.
+func newTestServer(t *testing.T, cfg *config.Config, logs *bytes.Buffer) (*gin.Engine, *ServerContext) {
+	t.Helper()
+	t.Setenv(gin.EnvGinMode, gin.TestMode)
+
+	l := logrus.New()
+	l.SetOutput(logs)
+	l.SetFormatter(&logrus.JSONFormatter{})
+	serverCtx := NewServerContext(logging.NewLogrusAdapter(l))
+	serverCtx.Metrics = NewMetrics()
+
+	r, err := NewServer(cfg, serverCtx)
+	require.NoError(t, err)
+	return r, serverCtx
+}
+
+func TestNewServer_MiddlewareStack(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Security.RateLimitRPS = 1
+	var logs bytes.Buffer
+	r, serverCtx := newTestServer(t, cfg, &logs)
+	require.NotNil(t, serverCtx.RateLimiter)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	assert.Contains(t, logs.String(), `"msg":"HTTP request"`)
+	assert.Contains(t, logs.String(), `"request_id":"`+w.Header().Get(RequestIDHeader)+`"`)
+
+	// API routes are rate limited, probes and metrics are not
+	limited := false
+	for i := 0; i < 10; i++ {
+		if get("/version").Code == http.StatusTooManyRequests {
+			limited = true
+			break
+		}
+	}
+	assert.True(t, limited, "API routes should be rate limited")
+	assert.Equal(t, http.StatusOK, get("/healthz").Code)
+	assert.Equal(t, http.StatusOK, get("/metrics").Code)
+	assert.Contains(t, get("/metrics").Body.String(), "go_trust_api_requests_total")
+}
+
+func TestNewServer_CORS(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Security.EnableCORS = true
+	cfg.Security.AllowedOrigins = []string{"https://app.example/"}
+	r, _ := newTestServer(t, cfg, &bytes.Buffer{})
+
+	req := httptest.NewRequest(http.MethodOptions, "/evaluation", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+
+	req = httptest.NewRequest(http.MethodGet, "/version", nil)
+	req.Header.Set("Origin", "https://other.example")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Without enable_cors no CORS headers are sent
+	r, _ = newTestServer(t, config.DefaultConfig(), &bytes.Buffer{})
+	req = httptest.NewRequest(http.MethodGet, "/version", nil)
+	req.Header.Set("Origin", "https://app.example")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestNewServer_Recovery(t *testing.T) {
+	var logs bytes.Buffer
+	r, _ := newTestServer(t, config.DefaultConfig(), &logs)
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, logs.String(), `"msg":"Panic while handling request"`)
+	assert.Contains(t, logs.String(), `"error":"boom"`)
+	assert.Contains(t, logs.String(), `"request_id":"req-1"`)
+}
+
+func TestNewServer_AccessLog(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Logging.AccessLog = t.TempDir() + "/access.log"
+	var logs bytes.Buffer
+	r, _ := newTestServer(t, cfg, &logs)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.NotContains(t, logs.String(), `"msg":"HTTP request"`, "requests go to the access log only")
+
+	cfg.Logging.AccessLogFormat = "combined"
+	_, err := NewServer(cfg, NewServerContext(nil))
+	assert.Error(t, err)
+}
+
+func TestNewServer_InvalidTrustedProxies(t *testing.T) {
+	t.Setenv(gin.EnvGinMode, gin.TestMode)
+	cfg := config.DefaultConfig()
+	cfg.Server.TrustedProxies = []string{"not-an-ip"}
+	_, err := NewServer(cfg, NewServerContext(nil))
+	assert.ErrorContains(t, err, "invalid trusted proxies")
+}
+
+func TestGinMode(t *testing.T) {
+	assert.Equal(t, gin.DebugMode, ginMode("DEBUG"))
+	assert.Equal(t, gin.ReleaseMode, ginMode("info"))
+	assert.Equal(t, 5, rateLimitBurst(1))
+	assert.Equal(t, 100, rateLimitBurst(1000))
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Decisions compared by shadow evaluation, the label values of
+// go_trust_shadow_decisions_total.
+const (
+	ShadowAllow   = "allow"
+	ShadowDeny    = "deny"
+	ShadowError   = "error"   // No decision could be made
+	ShadowSkipped = "skipped" // Not evaluated, as too many shadow evaluations were in progress
+)
+
+// MaxShadowEvaluations is the number of shadow evaluations run at once.
+// Requests arriving while that many are in progress are not evaluated under
+// the staged policy, so that shadow evaluation never builds up a backlog.
+const MaxShadowEvaluations = 16
+
+// StagedPolicy is a tenant policy and decision overrides staged to replace
+// the active ones. Every decided request is evaluated against it as well, in
+// the background, and decisions that differ from the active ones are logged
+// and counted in the go_trust_shadow_decisions_total metric, but only the
+// active decision is returned. This validates a policy change against live
+// traffic before cutover.
+type StagedPolicy struct {
+	tenantPolicy *TenantPolicy
+	overrides    *Overrides
+
+	slots chan struct{}  // Shadow evaluations in progress
+	wg    sync.WaitGroup // Tracks shadow evaluations, for tests
+}
+
+// NewStagedPolicy creates a StagedPolicy with the given tenant policy and
+// overrides. Pass the active ones for a part of the policy that is not
+// staged; a nil TenantPolicy disables tenant checks, as it does when active.
+func NewStagedPolicy(tenantPolicy *TenantPolicy, overrides *Overrides) *StagedPolicy {
+	return &StagedPolicy{
+		tenantPolicy: tenantPolicy,
+		overrides:    overrides,
+		slots:        make(chan struct{}, MaxShadowEvaluations),
+	}
+}
+
+// shadowEvaluation is a request to evaluate under a StagedPolicy, with the
+// trust sources its active decision is made against.
+type shadowEvaluation struct {
+	policy    *StagedPolicy
+	req       authzen.EvaluationRequest // As received, before the active tenant policy is applied
+	certs     []*x509.Certificate
+	tenant    string
+	purpose   string
+	tenantErr error // Error resolving the tenant, binding the request to the caller or matching its name, which denies under any policy
+	sources   decisionSources
+	timeout   time.Duration
+	requestID string
+	logger    logging.Logger
+	metrics   *Metrics
+}
+
+// shadow returns the evaluation of req under p, answered by c, or nil if p
+// is nil. It must be called before the active tenant policy restricts req.
+func (p *StagedPolicy) shadow(c *gin.Context, serverCtx *ServerContext, req *authzen.EvaluationRequest, certs []*x509.Certificate, tenant, purpose string, tenantErr error, sources decisionSources, timeout time.Duration) *shadowEvaluation {
+	if p == nil {
+		return nil
+	}
+	e := &shadowEvaluation{
+		policy:    p,
+		req:       *req,
+		certs:     certs,
+		tenant:    tenant,
+		purpose:   purpose,
+		tenantErr: tenantErr,
+		sources:   sources,
+		timeout:   timeout,
+		requestID: RequestID(c),
+		logger:    serverCtx.Logger,
+		metrics:   serverCtx.Metrics,
+	}
+	e.req.Context = maps.Clone(req.Context)
+	return e
+}
+
+// compare evaluates the request under the staged policy in the background
+// and records how the decision compares with the active one, resp, with
+// reason code activeReason. A nil resp is an active decision that could not
+// be made. Comparing a nil shadowEvaluation does nothing.
+func (e *shadowEvaluation) compare(resp *authzen.EvaluationResponse, activeReason string) {
+	if e == nil {
+		return
+	}
+	active := shadowDecision(resp)
+	select {
+	case e.policy.slots <- struct{}{}:
+	default:
+		if e.metrics != nil {
+			e.metrics.RecordShadowDecision(active, ShadowSkipped)
+		}
+		return
+	}
+
+	e.policy.wg.Add(1)
+	go func() {
+		defer e.policy.wg.Done()
+		defer func() { <-e.policy.slots }()
+
+		stagedResp, stagedReason := e.decide()
+		staged := shadowDecision(stagedResp)
+		if e.metrics != nil {
+			e.metrics.RecordShadowDecision(active, staged)
+		}
+		if staged != active {
+			e.logger.Info("Staged policy changes decision",
+				logging.F("request_id", e.requestID),
+				logging.F("subject_id", e.req.Subject.ID),
+				logging.F("resource_type", e.req.Resource.Type),
+				logging.F("action", actionName(&e.req)),
+				logging.F("tenant", e.tenant),
+				logging.F("purpose", e.purpose),
+				logging.F("active", active),
+				logging.F("active_reason", activeReason),
+				logging.F("staged", staged),
+				logging.F("staged_reason", stagedReason))
+		}
+	}()
+}
+
+// decide makes the decision on the request under the staged policy, as the
+// decision handler does under the active one. It returns the response, nil
+// if no decision could be made, and its reason code.
+func (e *shadowEvaluation) decide() (*authzen.EvaluationResponse, string) {
+	req := e.req
+	policy := e.policy.tenantPolicy
+	err, reason := e.tenantErr, policyReason(e.tenantErr)
+	if err == nil {
+		err = policy.Check(e.tenant, e.purpose, actionName(&req))
+	}
+	if err == nil {
+		if err = restrictTerritories(&req, policy.Territories(e.tenant)); err != nil {
+			reason = reasonCodeFor(err.Error())
+		}
+	}
+	if err != nil {
+		resp := buildResponse(false, err.Error())
+		return &resp, reason
+	}
+
+	ctx, cancel := decisionContext(context.Background(), e.timeout)
+	defer cancel()
+	resp, err := evaluateDecision(ctx, e.sources, e.policy.overrides, &req, e.certs, newDecisionTimer())
+	switch {
+	case ctx.Err() != nil:
+		return nil, ReasonTimeout
+	case err != nil && problemStatus(err) == http.StatusServiceUnavailable:
+		return nil, ReasonUnavailable
+	case err != nil:
+		return nil, ReasonError
+	}
+	return resp, DecisionReasonCode(resp)
+}
+
+// shadowDecision returns the decision of resp compared by shadow evaluation.
+func shadowDecision(resp *authzen.EvaluationResponse) string {
+	switch {
+	case resp == nil:
+		return ShadowError
+	case resp.Decision:
+		return ShadowAllow
+	default:
+		return ShadowDeny
+	}
+}
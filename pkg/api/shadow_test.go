@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/gin-gonic/gin"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthZENDecisionHandler_StagedPolicy(t *testing.T) {
+	ca, err := testutil.NewCA("Untrusted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "wallet.example.com")
+	require.NoError(t, err)
+
+	// The staged policy allow-lists the leaf and only admits tenant "acme"
+	allow, err := NewOverrides(nil, []string{pipeline.Fingerprint(leaf.Certificate)}, "")
+	require.NoError(t, err)
+	staged := NewStagedPolicy(NewTenantPolicy([]Tenant{{ID: "acme"}}), allow)
+
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	serverCtx.StagedPolicy = staged
+	serverCtx.Metrics = NewMetrics()
+	serverCtx.Unlock()
+
+	evaluate := func(tenant string) string {
+		req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(
+			`{"subject":{"type":"key","id":"wallet"},"resource":{"type":"x5c","id":"wallet","key":["`+leaf.Base64()+`"]}}`))
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	// Only the active decision is returned
+	assert.Contains(t, evaluate("acme"), `"decision":false`)
+	assert.Contains(t, evaluate(""), `"decision":false`)
+	staged.wg.Wait()
+
+	shadow := serverCtx.Metrics.ShadowDecisionsTotal
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(shadow.WithLabelValues(ShadowDeny, ShadowAllow)), "allow-listed under the staged policy")
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(shadow.WithLabelValues(ShadowDeny, ShadowDeny)), "no tenant under the staged policy")
+}
+
+func TestStagedPolicy_Skipped(t *testing.T) {
+	_, serverCtx := setupTestServer()
+	serverCtx.Metrics = NewMetrics()
+	staged := NewStagedPolicy(nil, nil)
+	for range MaxShadowEvaluations {
+		staged.slots <- struct{}{}
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/evaluation", nil)
+	req := &authzen.EvaluationRequest{
+		Subject:  authzen.Subject{Type: "key", ID: "wallet"},
+		Resource: authzen.Resource{Type: authzen.ResourceTypeX5C, ID: "wallet"},
+	}
+	e := staged.shadow(c, serverCtx, req, nil, "", "", nil, decisionSources{}, 0)
+	e.compare(nil, ReasonError)
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(serverCtx.Metrics.ShadowDecisionsTotal.WithLabelValues(ShadowError, ShadowSkipped)))
+
+	var none *StagedPolicy
+	assert.Nil(t, none.shadow(c, serverCtx, req, nil, "", "", nil, decisionSources{}, 0))
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"sort"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/pipeline"
+)
+
+// SourceStatus describes the reachability of an upstream TSL source, as seen
+// by the most recent pipeline runs. It is reported by the readiness endpoint.
+type SourceStatus struct {
+	URL         string     `json:"url"`                    // URL of the TSL
+	LastFetch   time.Time  `json:"last_fetch"`             // When the TSL was last requested
+	LastSuccess *time.Time `json:"last_success,omitempty"` // When the TSL was last fetched successfully
+	StatusCode  int        `json:"status_code,omitempty"`  // HTTP status code of the last request
+	Error       string     `json:"error,omitempty"`        // Error of the last request, if it failed
+	Stale       bool       `json:"stale"`                  // True if the last run did not fetch the TSL successfully
+}
+
+// RecordSources updates the source statuses with the fetches of a pipeline
+// run. Sources that the run fetched successfully are no longer stale; sources
+// whose fetch failed, and sources known from earlier runs that this run did
+// not fetch at all, are marked stale. The caller must hold the write lock.
+func (s *ServerContext) RecordSources(report *pipeline.RunReport) {
+	if report == nil {
+		return
+	}
+	if s.Sources == nil {
+		s.Sources = make(map[string]*SourceStatus)
+	}
+
+	fetched := make(map[string]bool)
+	for _, fetch := range report.Sources {
+		status, ok := s.Sources[fetch.URL]
+		if !ok {
+			status = &SourceStatus{URL: fetch.URL}
+			s.Sources[fetch.URL] = status
+		}
+		status.LastFetch = fetch.Time
+		status.StatusCode = fetch.StatusCode
+		status.Error = fetch.Error
+		status.Stale = fetch.Failed()
+		if !fetch.Failed() {
+			t := fetch.Time
+			status.LastSuccess = &t
+		}
+		fetched[fetch.URL] = true
+	}
+
+	for url, status := range s.Sources {
+		if !fetched[url] {
+			status.Stale = true
+		}
+	}
+}
+
+// SourceStatuses returns a copy of the source statuses, sorted by URL. The
+// caller must hold the read lock.
+func (s *ServerContext) SourceStatuses() []SourceStatus {
+	statuses := make([]SourceStatus, 0, len(s.Sources))
+	for _, status := range s.Sources {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].URL < statuses[j].URL
+	})
+	return statuses
+}
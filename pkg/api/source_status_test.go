@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSources(t *testing.T) {
+	ctx := createTestContext(1, time.Now())
+	t1 := time.Now().Add(-time.Hour)
+	t2 := time.Now()
+
+	ctx.RecordSources(&pipeline.RunReport{Sources: []pipeline.SourceFetch{
+		{URL: "https://a.example/tsl.xml", Time: t1, StatusCode: 200},
+		{URL: "https://b.example/tsl.xml", Time: t1, StatusCode: 200},
+		{URL: "https://c.example/tsl.xml", Time: t1, StatusCode: 200},
+	}})
+	ctx.RecordSources(&pipeline.RunReport{Sources: []pipeline.SourceFetch{
+		{URL: "https://a.example/tsl.xml", Time: t2, StatusCode: 200},
+		{URL: "https://b.example/tsl.xml", Time: t2, StatusCode: 503, Error: "Service Unavailable"},
+	}})
+	ctx.RecordSources(nil)
+
+	sources := ctx.SourceStatuses()
+	require.Len(t, sources, 3)
+
+	a, b, c := sources[0], sources[1], sources[2]
+	assert.Equal(t, "https://a.example/tsl.xml", a.URL)
+	assert.False(t, a.Stale)
+	assert.Equal(t, t2, *a.LastSuccess)
+
+	assert.Equal(t, "https://b.example/tsl.xml", b.URL)
+	assert.True(t, b.Stale, "the last fetch failed")
+	assert.Equal(t, 503, b.StatusCode)
+	assert.Equal(t, "Service Unavailable", b.Error)
+	assert.Equal(t, t2, b.LastFetch)
+	assert.Equal(t, t1, *b.LastSuccess)
+
+	assert.Equal(t, "https://c.example/tsl.xml", c.URL)
+	assert.True(t, c.Stale, "not fetched by the last run")
+	assert.Equal(t, t1, c.LastFetch)
+}
+
+func TestReadyEndpoint_Sources(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx := createTestContext(1, time.Now())
+	ctx.RecordSources(&pipeline.RunReport{Sources: []pipeline.SourceFetch{
+		{URL: "https://a.example/tsl.xml", Time: time.Now(), Error: "connection refused"},
+	}})
+
+	r := gin.New()
+	RegisterHealthEndpoints(r, ctx)
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code, "stale sources do not make the service unready")
+
+	var response ReadinessResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Sources, 1)
+	assert.Equal(t, "https://a.example/tsl.xml", response.Sources[0].URL)
+	assert.True(t, response.Sources[0].Stale)
+	assert.Equal(t, "connection refused", response.Sources[0].Error)
+	assert.Nil(t, response.Sources[0].LastSuccess)
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/registry/etsi"
+)
+
+// Request context keys carrying the purpose-of-use identifiers of an AuthZEN
+// evaluation request.
+const (
+	ContextKeyTenant  = "tenant"
+	ContextKeyPurpose = "purpose"
+)
+
+// TenantHeader is the HTTP header that selects the tenant of an AuthZEN
+// evaluation request, as an alternative to the "tenant" request context key.
+const TenantHeader = "X-Tenant"
+
+// Tenant is a relying party allowed to call the AuthZEN evaluation endpoint.
+type Tenant struct {
+	ID             string   // Value of context.tenant
+	Purposes       []string // Allowed values of context.purpose; empty allows any
+	AllowedActions []string // Allowed action names; empty allows any
+	Territories    []string // Allowed trust anchor scheme territories; empty allows any
+}
+
+// TenantPolicy is an allow-list of tenants, their purposes of use and the
+// actions they may ask about. It supports multi-tenant deployments where
+// different relying parties share one PDP but are allowed different actions.
+type TenantPolicy struct {
+	tenants map[string]tenantRules
+}
+
+type tenantRules struct {
+	purposes    map[string]bool
+	actions     map[string]bool
+	territories []string
+}
+
+// NewTenantPolicy creates a TenantPolicy from a list of tenants. It returns nil
+// if tenants is empty, which disables tenant checks.
+func NewTenantPolicy(tenants []Tenant) *TenantPolicy {
+	if len(tenants) == 0 {
+		return nil
+	}
+
+	p := &TenantPolicy{tenants: make(map[string]tenantRules, len(tenants))}
+	for _, t := range tenants {
+		p.tenants[t.ID] = tenantRules{
+			purposes:    toSet(t.Purposes),
+			actions:     toSet(t.AllowedActions),
+			territories: pipeline.NormalizeTerritories(t.Territories),
+		}
+	}
+	return p
+}
+
+// Check returns an error if the tenant, purpose and action combination is not
+// allowed. A nil policy allows everything.
+func (p *TenantPolicy) Check(tenant, purpose, action string) error {
+	if p == nil {
+		return nil
+	}
+	if tenant == "" {
+		return fmt.Errorf("missing tenant in request context")
+	}
+	rules, ok := p.tenants[tenant]
+	if !ok {
+		return fmt.Errorf("tenant %q is not authorized", tenant)
+	}
+	if rules.purposes != nil {
+		if purpose == "" {
+			return fmt.Errorf("missing purpose in request context for tenant %q", tenant)
+		}
+		if !rules.purposes[purpose] {
+			return fmt.Errorf("purpose %q is not authorized for tenant %q", purpose, tenant)
+		}
+	}
+	if rules.actions != nil && !rules.actions[action] {
+		return fmt.Errorf("action %q is not authorized for tenant %q", action, tenant)
+	}
+	return nil
+}
+
+// Territories returns the scheme territories the trust anchors of a tenant's
+// requests must be listed for, or nil if they are not restricted.
+func (p *TenantPolicy) Territories(tenant string) []string {
+	if p == nil {
+		return nil
+	}
+	return p.tenants[tenant].territories
+}
+
+// Labels returns the metric label values for a tenant and purpose. Only values
+// listed in the policy are used as labels, so that clients cannot create
+// arbitrary label values: unlisted values are reported as "other" and missing
+// ones as "none". With a nil policy every supplied value is unlisted.
+func (p *TenantPolicy) Labels(tenant, purpose string) (tenantLabel, purposeLabel string) {
+	tenantLabel, purposeLabel = "none", "none"
+	if tenant != "" {
+		tenantLabel = "other"
+	}
+	if purpose != "" {
+		purposeLabel = "other"
+	}
+	if p == nil {
+		return tenantLabel, purposeLabel
+	}
+
+	rules, ok := p.tenants[tenant]
+	if !ok {
+		return tenantLabel, purposeLabel
+	}
+	tenantLabel = tenant
+	if rules.purposes[purpose] {
+		purposeLabel = purpose
+	}
+	return tenantLabel, purposeLabel
+}
+
+// RequestPurpose returns the tenant and purpose from the context of an AuthZEN
+// request. Missing or non-string values are returned as empty strings.
+func RequestPurpose(req *authzen.EvaluationRequest) (tenant, purpose string) {
+	if req.Context == nil {
+		return "", ""
+	}
+	tenant, _ = req.Context[ContextKeyTenant].(string)
+	purpose, _ = req.Context[ContextKeyPurpose].(string)
+	return tenant, purpose
+}
+
+// restrictTerritories applies the territories allowed for a tenant to the
+// "territories" key of an AuthZEN request context, which registries enforce. A
+// request without territories is restricted to the allowed ones, and one with
+// territories to those that are also allowed. It returns an error if the
+// requested territories are malformed or none of them is allowed.
+func restrictTerritories(req *authzen.EvaluationRequest, allowed []string) error {
+	requested, err := etsi.RequestedTerritories(req)
+	if err != nil {
+		return fmt.Errorf("validation error: %v", err)
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	effective := allowed
+	if len(requested) > 0 {
+		allowedSet := toSet(allowed)
+		effective = nil
+		for _, t := range requested {
+			if allowedSet[t] {
+				effective = append(effective, t)
+			}
+		}
+		if len(effective) == 0 {
+			return fmt.Errorf("territory mismatch: requested territory %s is not allowed, only %s",
+				strings.Join(requested, ", "), strings.Join(allowed, ", "))
+		}
+	}
+
+	if req.Context == nil {
+		req.Context = make(map[string]interface{})
+	}
+	req.Context[etsi.ContextKeyTerritories] = effective
+	return nil
+}
+
+// resolveTenant returns the tenant selected by the TenantHeader header or the
+// "tenant" request context key. It returns an error if both are given and differ.
+func resolveTenant(header, contextTenant string) (string, error) {
+	switch {
+	case header == "":
+		return contextTenant, nil
+	case contextTenant == "" || contextTenant == header:
+		return header, nil
+	default:
+		return "", fmt.Errorf("tenant in %s header (%q) does not match request context (%q)", TenantHeader, header, contextTenant)
+	}
+}
+
+// toSet returns a set of the given values, or nil if there are none.
+func toSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTenantPolicy() *TenantPolicy {
+	return NewTenantPolicy([]Tenant{
+		{ID: "bank", Purposes: []string{"kyc", "payment"}, AllowedActions: []string{"issuer"}},
+		{ID: "portal"},
+	})
+}
+
+func TestNewTenantPolicy_Empty(t *testing.T) {
+	assert.Nil(t, NewTenantPolicy(nil))
+
+	var p *TenantPolicy
+	assert.NoError(t, p.Check("", "", ""))
+	assert.NoError(t, p.Check("anyone", "anything", "any-action"))
+}
+
+func TestTenantPolicy_Check(t *testing.T) {
+	p := testTenantPolicy()
+
+	tests := []struct {
+		name                    string
+		tenant, purpose, action string
+		wantErr                 string
+	}{
+		{"allowed", "bank", "kyc", "issuer", ""},
+		{"any purpose and action", "portal", "", "verifier", ""},
+		{"missing tenant", "", "kyc", "issuer", "missing tenant"},
+		{"unknown tenant", "shop", "kyc", "issuer", `tenant "shop" is not authorized`},
+		{"missing purpose", "bank", "", "issuer", "missing purpose"},
+		{"purpose not allowed", "bank", "marketing", "issuer", `purpose "marketing" is not authorized`},
+		{"action not allowed", "bank", "kyc", "verifier", `action "verifier" is not authorized`},
+		{"missing action", "bank", "kyc", "", `action "" is not authorized`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.tenant, tt.purpose, tt.action)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTenantPolicy_Labels(t *testing.T) {
+	p := testTenantPolicy()
+
+	tenant, purpose := p.Labels("bank", "kyc")
+	assert.Equal(t, "bank", tenant)
+	assert.Equal(t, "kyc", purpose)
+
+	tenant, purpose = p.Labels("portal", "free-text")
+	assert.Equal(t, "portal", tenant)
+	assert.Equal(t, "other", purpose, "purposes are not listed for portal")
+
+	tenant, purpose = p.Labels("shop", "kyc")
+	assert.Equal(t, "other", tenant)
+	assert.Equal(t, "other", purpose)
+
+	tenant, purpose = p.Labels("", "")
+	assert.Equal(t, "none", tenant)
+	assert.Equal(t, "none", purpose)
+
+	var nilPolicy *TenantPolicy
+	tenant, purpose = nilPolicy.Labels("bank", "")
+	assert.Equal(t, "other", tenant)
+	assert.Equal(t, "none", purpose)
+}
+
+func TestRequestPurpose(t *testing.T) {
+	tenant, purpose := RequestPurpose(&authzen.EvaluationRequest{})
+	assert.Empty(t, tenant)
+	assert.Empty(t, purpose)
+
+	tenant, purpose = RequestPurpose(&authzen.EvaluationRequest{
+		Context: map[string]interface{}{"tenant": "bank", "purpose": 42},
+	})
+	assert.Equal(t, "bank", tenant)
+	assert.Empty(t, purpose, "non-string purpose is ignored")
+}
+
+func TestAuthZENDecisionHandler_TenantPolicy(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	serverCtx.TenantPolicy = testTenantPolicy()
+	serverCtx.Metrics = NewMetrics()
+
+	evaluate := func(context string) map[string]interface{} {
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]},"action":{"name":"issuer"},"context":%s}`,
+			testCertBase64, context)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := evaluate(`{"tenant":"bank","purpose":"kyc"}`)
+	assert.Equal(t, true, resp["decision"])
+
+	resp = evaluate(`{"tenant":"shop","purpose":"kyc"}`)
+	assert.Equal(t, false, resp["decision"])
+	assert.Contains(t, fmt.Sprint(resp["context"]), `tenant "shop" is not authorized`)
+
+	resp = evaluate(`{"tenant":"bank","purpose":"marketing"}`)
+	assert.Equal(t, false, resp["decision"])
+
+	decisions := serverCtx.Metrics.DecisionsTotal
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("issuer", "x5c", "bank", "kyc", "true", ReasonNone)))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("issuer", "x5c", "other", "other", "false", ReasonPolicyMismatch)))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("issuer", "x5c", "bank", "other", "false", ReasonPolicyMismatch)))
+}
+
+func TestTenantPolicy_Territories(t *testing.T) {
+	p := NewTenantPolicy([]Tenant{{ID: "bank", Territories: []string{"se", "EU"}}, {ID: "portal"}})
+	assert.Equal(t, []string{"SE", "EU"}, p.Territories("bank"))
+	assert.Nil(t, p.Territories("portal"))
+	assert.Nil(t, p.Territories("unknown"))
+
+	var none *TenantPolicy
+	assert.Nil(t, none.Territories("bank"))
+}
+
+func TestRestrictTerritories(t *testing.T) {
+	request := func(territories interface{}) *authzen.EvaluationRequest {
+		if territories == nil {
+			return &authzen.EvaluationRequest{}
+		}
+		return &authzen.EvaluationRequest{Context: map[string]interface{}{"territories": territories}}
+	}
+
+	req := request(nil)
+	require.NoError(t, restrictTerritories(req, nil))
+	assert.Nil(t, req.Context, "unrestricted tenants leave the request unchanged")
+
+	req = request(nil)
+	require.NoError(t, restrictTerritories(req, []string{"SE"}))
+	assert.Equal(t, []string{"SE"}, req.Context["territories"])
+
+	req = request([]interface{}{"de", "se"})
+	require.NoError(t, restrictTerritories(req, []string{"SE", "EU"}))
+	assert.Equal(t, []string{"SE"}, req.Context["territories"])
+
+	err := restrictTerritories(request("DE"), []string{"SE"})
+	assert.EqualError(t, err, "territory mismatch: requested territory DE is not allowed, only SE")
+
+	err = restrictTerritories(request(42), nil)
+	assert.ErrorContains(t, err, "validation error")
+}
+
+func TestAuthZENDecisionHandler_Territories(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	serverCtx.Metrics = NewMetrics()
+	serverCtx.TenantPolicy = NewTenantPolicy([]Tenant{{ID: "bank", Territories: []string{"SE"}}, {ID: "portal"}})
+
+	ca, err := testutil.NewCA("German CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().WithTerritory("DE").
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("German Service").WithCert(ca))).
+		Build())
+	ctx.CertPool = ca.Pool()
+	serverCtx.Lock()
+	serverCtx.PipelineContext = ctx
+	serverCtx.Unlock()
+
+	evaluate := func(requestContext string) map[string]interface{} {
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]},"context":%s}`,
+			leaf.Base64(), requestContext)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body)))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+
+	resp := evaluate(`{"tenant":"portal"}`)
+	assert.Equal(t, true, resp["decision"])
+
+	resp = evaluate(`{"tenant":"portal","territories":["DE","AT"]}`)
+	assert.Equal(t, true, resp["decision"])
+
+	// The request restricts the territories
+	resp = evaluate(`{"tenant":"portal","territories":"SE"}`)
+	assert.Equal(t, false, resp["decision"])
+	assert.Contains(t, fmt.Sprint(resp["context"]), "territory mismatch: trust anchor is listed for territory DE, not SE")
+
+	// The tenant policy restricts the territories
+	resp = evaluate(`{"tenant":"bank"}`)
+	assert.Equal(t, false, resp["decision"])
+	assert.Contains(t, fmt.Sprint(resp["context"]), "territory mismatch")
+
+	// Requests cannot widen the tenant's territories
+	resp = evaluate(`{"tenant":"bank","territories":["DE"]}`)
+	assert.Equal(t, false, resp["decision"])
+	assert.Contains(t, fmt.Sprint(resp["context"]), "requested territory DE is not allowed")
+
+	decisions := serverCtx.Metrics.DecisionsTotal
+	assert.Equal(t, 2.0, promtestutil.ToFloat64(decisions.WithLabelValues("none", "x5c", "portal", "none", "true", ReasonNone)))
+	assert.Equal(t, 1.0, promtestutil.ToFloat64(decisions.WithLabelValues("none", "x5c", "portal", "none", "false", ReasonTerritoryMismatch)))
+	assert.Equal(t, 2.0, promtestutil.ToFloat64(decisions.WithLabelValues("none", "x5c", "bank", "none", "false", ReasonTerritoryMismatch)))
+}
+
+func TestResolveTenant(t *testing.T) {
+	tenant, err := resolveTenant("", "bank")
+	assert.NoError(t, err)
+	assert.Equal(t, "bank", tenant)
+
+	tenant, err = resolveTenant("bank", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "bank", tenant)
+
+	tenant, err = resolveTenant("bank", "bank")
+	assert.NoError(t, err)
+	assert.Equal(t, "bank", tenant)
+
+	_, err = resolveTenant("bank", "portal")
+	assert.ErrorContains(t, err, "does not match")
+}
+
+func TestAuthZENDecisionHandler_TenantPipeline(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	serverCtx.TenantPolicy = NewTenantPolicy([]Tenant{{ID: "bank"}, {ID: "portal"}})
+
+	// The bank tenant trusts its own CA only; the default pool trusts testCert
+	bankCA, err := testutil.NewCA("Bank CA")
+	require.NoError(t, err)
+	bankLeaf, err := testutil.NewLeaf(bankCA, "bank leaf")
+	require.NoError(t, err)
+	bankCtx := pipeline.NewContext()
+	bankCtx.CertPool = bankCA.Pool()
+	serverCtx.TenantContexts = map[string]*pipeline.Context{"bank": bankCtx}
+
+	evaluate := func(cert string, header string) bool {
+		body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]}}`, cert)
+		req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body))
+		if header != "" {
+			req.Header.Set(TenantHeader, header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp["decision"] == true
+	}
+
+	assert.True(t, evaluate(bankLeaf.Base64(), "bank"))
+	assert.False(t, evaluate(testCertBase64, "bank"), "bank tenant must not use the default pool")
+	assert.True(t, evaluate(testCertBase64, "portal"), "tenants without a pipeline use the default pool")
+	assert.False(t, evaluate(bankLeaf.Base64(), "portal"))
+	assert.False(t, evaluate(testCertBase64, "unknown"))
+
+	// Header and request context must agree
+	body := fmt.Sprintf(`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":[%q]},"context":{"tenant":"portal"}}`, bankLeaf.Base64())
+	req := httptest.NewRequest(http.MethodPost, "/evaluation", strings.NewReader(body))
+	req.Header.Set(TenantHeader, "bank")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"decision":false`)
+	assert.Contains(t, w.Body.String(), "does not match")
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// requestTerritories returns the scheme territories of the comma-separated
+// territory query parameter of the request answered by c, normalized so that
+// GR and GB select the EL and UK lists. It returns nil if the parameter is
+// absent and an error if it names an unknown territory.
+func requestTerritories(c *gin.Context) ([]string, error) {
+	v := c.Query("territory")
+	if v == "" {
+		return nil, nil
+	}
+	territories, err := pipeline.ValidateTerritories(strings.Split(v, ","))
+	if err != nil {
+		return nil, fmt.Errorf("invalid territory %q: %w", v, err)
+	}
+	return territories, nil
+}
+
+// inTerritories reports whether the scheme territory of tsl is one of the
+// normalized territories, or whether territories is empty.
+func inTerritories(tsl *etsi119612.TSL, territories []string) bool {
+	if len(territories) == 0 {
+		return true
+	}
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil {
+		return false
+	}
+	territory, _ := validation.NormalizeTerritory(si.TslSchemeTerritory)
+	return slices.Contains(territories, territory)
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSLsEndpoint_Territory(t *testing.T) {
+	r, serverCtx := setupTestServer()
+	serverCtx.Lock()
+	for _, territory := range []string{"SE", "EL", "UK"} {
+		serverCtx.PipelineContext.TSLs.Push(pltesting.NewTSL().WithTerritory(territory).WithOperatorName("Operator " + territory).Build())
+	}
+	serverCtx.Unlock()
+
+	operators := func(path, key string) []string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		var summaries []map[string]interface{}
+		require.NoError(t, json.Unmarshal(resp[key], &summaries))
+		var got []string // Scheme operators, named after their territories
+		for _, s := range summaries {
+			got = append(got, s["scheme_operator_name"].(string))
+		}
+		return got
+	}
+
+	assert.Len(t, operators("/tsls", "tsls"), 3)
+	assert.ElementsMatch(t, []string{"Operator EL", "Operator UK"}, operators("/tsls?territory=gr,GB", "tsls"))
+	assert.Equal(t, []string{"Operator SE"}, operators("/info?territory=se", "tsl_summaries"))
+
+	for _, path := range []string{"/tsls?territory=SE,XX", "/info?territory=XX"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), "unknown territory code", path)
+	}
+}
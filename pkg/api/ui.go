@@ -0,0 +1,38 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UIPath is the path of the operator dashboard.
+const UIPath = "/ui"
+
+//go:embed ui/index.html ui/ui.css ui/ui.js
+var uiFiles embed.FS
+
+// RegisterUIEndpoints serves the operator dashboard under UIPath. The
+// dashboard is a static page that renders, in the browser, the readiness
+// (/readyz), run history (/status), build information (/version) and, with the
+// admin token entered on the page, the recent denials (/admin/denials) of this
+// instance, and triggers pipeline runs with POST /admin/refresh. It holds no
+// state of its own; the admin token is kept in the browser's session storage.
+func RegisterUIEndpoints(r *gin.Engine) {
+	static, _ := fs.Sub(uiFiles, "ui")
+	ui := r.Group(UIPath, uiHeadersMiddleware())
+	ui.StaticFS("/", http.FS(static))
+}
+
+// uiHeadersMiddleware sets headers restricting the dashboard to its own
+// scripts, styles and API, and forbidding framing.
+func uiHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Referrer-Policy", "no-referrer")
+		c.Next()
+	}
+}
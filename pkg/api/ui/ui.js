@@ -0,0 +1,200 @@
+// Go-Trust operator dashboard. Everything shown is fetched from the API of
+// the instance serving this page; API paths are relative to /ui/ so that the
+// dashboard also works behind a path prefix.
+(function () {
+    'use strict';
+
+    const TOKEN_KEY = 'go-trust-admin-token';
+    const RELOAD_INTERVAL_MS = 60000;
+
+    const tokenInput = document.getElementById('token');
+    tokenInput.value = sessionStorage.getItem(TOKEN_KEY) || '';
+    tokenInput.addEventListener('change', () => {
+        sessionStorage.setItem(TOKEN_KEY, tokenInput.value.trim());
+        reload();
+    });
+
+    document.getElementById('reload').addEventListener('click', reload);
+    document.getElementById('refresh').addEventListener('click', refreshPipelines);
+
+    function adminHeaders() {
+        const token = tokenInput.value.trim();
+        return token ? { 'Authorization': 'Bearer ' + token } : {};
+    }
+
+    // fetchJSON returns the JSON body of a GET request; /readyz answers 503
+    // with a body while the service is not ready, which is still rendered.
+    async function fetchJSON(path, options) {
+        const resp = await fetch('../' + path, options);
+        if (!resp.ok && resp.status !== 503) {
+            throw new Error(path + ': ' + resp.status + ' ' + resp.statusText);
+        }
+        return resp.json();
+    }
+
+    function showMessage(text, isError) {
+        const el = document.getElementById('message');
+        el.textContent = text;
+        el.classList.toggle('error', !!isError);
+        el.hidden = !text;
+    }
+
+    function formatTime(value) {
+        if (!value) {
+            return '–';
+        }
+        const date = new Date(value);
+        return isNaN(date) || date.getFullYear() < 2 ? '–' : date.toLocaleString();
+    }
+
+    function formatAge(value) {
+        const date = new Date(value);
+        if (!value || isNaN(date)) {
+            return '';
+        }
+        const minutes = Math.round((Date.now() - date) / 60000);
+        if (minutes < 60) {
+            return minutes + ' min ago';
+        }
+        const hours = Math.round(minutes / 60);
+        return hours < 48 ? hours + ' h ago' : Math.round(hours / 24) + ' days ago';
+    }
+
+    function formatDuration(ns) {
+        const ms = ns / 1e6;
+        return ms < 1000 ? Math.round(ms) + ' ms' : (ms / 1000).toFixed(1) + ' s';
+    }
+
+    function cell(text, className) {
+        const td = document.createElement('td');
+        td.textContent = text === undefined || text === null || text === '' ? '–' : String(text);
+        if (className) {
+            td.className = className;
+        }
+        return td;
+    }
+
+    // renderRows replaces the rows of a table body; rows are arrays of cells.
+    function renderRows(id, rows, columns, emptyText) {
+        const body = document.getElementById(id);
+        body.replaceChildren();
+        if (rows.length === 0) {
+            const tr = document.createElement('tr');
+            const td = cell(emptyText, 'empty');
+            td.colSpan = columns;
+            tr.appendChild(td);
+            body.appendChild(tr);
+            return;
+        }
+        for (const cells of rows) {
+            const tr = document.createElement('tr');
+            cells.forEach(td => tr.appendChild(td));
+            body.appendChild(tr);
+        }
+    }
+
+    function renderReadiness(readiness) {
+        const ready = document.getElementById('ready');
+        ready.textContent = readiness.ready ? 'Ready' : 'Not ready';
+        ready.className = 'value ' + (readiness.ready ? 'ok' : 'bad');
+        ready.title = readiness.message || '';
+        document.getElementById('generation').textContent = readiness.pool_generation || 0;
+        document.getElementById('tsl-count').textContent = readiness.tsl_count;
+        document.getElementById('last-processed').textContent = formatTime(readiness.last_processed);
+
+        renderRows('sources', (readiness.sources || []).map(source => [
+            cell(source.url, 'wrap'),
+            cell(formatTime(source.last_fetch)),
+            cell(formatTime(source.last_success) + (source.last_success ? ' (' + formatAge(source.last_success) + ')' : '')),
+            cell(source.error || source.status_code, source.error ? 'bad wrap' : ''),
+            cell(source.stale ? 'Stale' : 'Fresh', source.stale ? 'warn' : 'ok'),
+        ]), 5, 'No TSL sources fetched yet');
+    }
+
+    const healthClass = { ok: 'ok', degraded: 'warn', down: 'bad' };
+
+    function renderComponents(resp) {
+        renderRows('components', (resp.components || []).map(component => [
+            cell(component.component),
+            cell(component.name + (component.target ? ' (' + component.target + ')' : ''), 'wrap'),
+            cell(formatTime(component.last_success)),
+            cell(component.last_error ? component.last_error + ' (' + formatAge(component.last_error_at) + ')' : '', 'wrap'),
+            cell(component.status + (component.message ? ': ' + component.message : ''), healthClass[component.status] || ''),
+        ]), 5, 'No components reported');
+    }
+
+    function renderRuns(status) {
+        renderRows('runs', (status.runs || []).map(run => {
+            const warnings = run.steps.reduce((n, step) => n + (step.warnings ? step.warnings.length : 0), 0);
+            return [
+                cell(formatTime(run.start)),
+                cell(formatDuration(run.duration_ns)),
+                cell(run.steps.length),
+                cell(warnings, warnings > 0 ? 'warn' : ''),
+                cell(run.error || 'OK', run.error ? 'bad' : 'ok'),
+            ];
+        }), 5, 'No pipeline runs yet');
+    }
+
+    function renderDenials(denials) {
+        renderRows('denials', denials.map(denial => [
+            cell(formatTime(denial.time)),
+            cell(denial.subject_id, 'wrap'),
+            cell(denial.resource_type),
+            cell(denial.tenant),
+            cell(denial.reason),
+            cell(denial.message, 'wrap'),
+        ]), 6, 'No denials since the server started');
+    }
+
+    async function reload() {
+        showMessage('');
+        try {
+            const [readiness, status, version, components] = await Promise.all([
+                fetchJSON('readyz'),
+                fetchJSON('status'),
+                fetchJSON('version'),
+                fetchJSON('status/components'),
+            ]);
+            renderReadiness(readiness);
+            renderRuns(status);
+            renderComponents(components);
+            document.getElementById('version').textContent = version.version;
+        } catch (err) {
+            showMessage('Failed to load status: ' + err.message, true);
+        }
+
+        if (!tokenInput.value.trim()) {
+            renderRows('denials', [], 6, 'Enter the admin token to see recent denials');
+            return;
+        }
+        try {
+            const resp = await fetchJSON('admin/denials', { headers: adminHeaders() });
+            renderDenials(resp.denials || []);
+        } catch (err) {
+            renderRows('denials', [], 6, 'Recent denials are unavailable: ' + err.message);
+        }
+    }
+
+    async function refreshPipelines() {
+        const button = document.getElementById('refresh');
+        button.disabled = true;
+        try {
+            const resp = await fetch('../admin/refresh', { method: 'POST', headers: adminHeaders() });
+            if (!resp.ok) {
+                throw new Error(resp.status === 401 || resp.status === 404
+                    ? 'a valid admin token is required'
+                    : resp.status + ' ' + resp.statusText);
+            }
+            const body = await resp.json();
+            showMessage('Pipeline refresh requested for ' + body.triggered + ' pipeline(s); reload to see the result.');
+        } catch (err) {
+            showMessage('Refresh failed: ' + err.message, true);
+        } finally {
+            button.disabled = false;
+        }
+    }
+
+    reload();
+    setInterval(reload, RELOAD_INTERVAL_MS);
+})();
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterUIEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterUIEndpoints(r)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// /ui redirects to the directory, whose index is the dashboard
+	w := get("/ui")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/ui/", w.Header().Get("Location"))
+
+	w = get("/ui/")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Go-Trust Operator Dashboard")
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), "default-src 'self'")
+
+	// The page only refers to the embedded assets and the API
+	for _, asset := range []string{"/ui/ui.js", "/ui/ui.css"} {
+		w := get(asset)
+		assert.Equal(t, http.StatusOK, w.Code, asset)
+		assert.NotEmpty(t, w.Body.String(), asset)
+	}
+	assert.Contains(t, get("/ui/ui.js").Body.String(), "admin/refresh")
+
+	assert.Equal(t, http.StatusNotFound, get("/ui/missing.js").Code)
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+)
+
+// OverlapPolicy determines what the background updater does when a scheduled run
+// comes due while the previous pipeline run is still in progress.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip drops ticks that fire during a run; the next run happens at the
+	// following regular tick. This is the default.
+	OverlapSkip OverlapPolicy = iota
+
+	// OverlapQueue remembers at most one missed tick and starts another run as soon
+	// as the current one completes.
+	OverlapQueue
+)
+
+// String returns the name of the overlap policy.
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	default:
+		return "unknown"
+	}
+}
+
+// UpdaterOption configures a BackgroundUpdater.
+type UpdaterOption func(*BackgroundUpdater)
+
+// WithJitter adds a random delay in [0, jitter) before every scheduled run so that
+// several instances started at the same time do not fetch TSLs in lockstep.
+func WithJitter(jitter time.Duration) UpdaterOption {
+	return func(u *BackgroundUpdater) {
+		u.jitter = jitter
+	}
+}
+
+// WithOverlapPolicy sets how ticks that fire during a still-running pipeline are handled.
+func WithOverlapPolicy(policy OverlapPolicy) UpdaterOption {
+	return func(u *BackgroundUpdater) {
+		u.overlap = policy
+	}
+}
+
+// ForTenant makes the updater install pipeline results as the Context of the
+// named tenant (see ServerContext.TenantContexts) instead of the default
+// PipelineContext. Tenant runs are not added to the run history.
+func ForTenant(tenant string) UpdaterOption {
+	return func(u *BackgroundUpdater) {
+		u.tenant = tenant
+	}
+}
+
+// WithBaseContext makes every run start from a clone of base instead of an
+// empty Context, so that state prepared once, such as fetch options or trust
+// anchors loaded at startup, is available to each run. Runs never modify base.
+func WithBaseContext(base *pipeline.Context) UpdaterOption {
+	return func(u *BackgroundUpdater) {
+		u.base = base
+	}
+}
+
+// BackgroundUpdater periodically processes a pipeline and installs the resulting
+// Context in a ServerContext. It is created by StartBackgroundUpdater and runs until
+// Stop is called or the context passed to StartBackgroundUpdater is cancelled.
+//
+// Pipeline runs never overlap: the updater uses a single goroutine, and ticks that
+// fire while a run is in progress are handled according to the OverlapPolicy.
+type BackgroundUpdater struct {
+	pl        *pipeline.Pipeline
+	serverCtx *ServerContext
+	freq      time.Duration
+	jitter    time.Duration
+	overlap   OverlapPolicy
+	tenant    string            // Tenant whose Context is updated; "" for the default PipelineContext
+	base      *pipeline.Context // Context each run starts from a clone of; nil for an empty one
+	warmUp    int               // Synthetic evaluations run against a new pool before it is installed
+
+	cancel   context.CancelFunc
+	done     chan struct{}
+	trigger  chan struct{} // Pending run requested with Trigger
+	stopOnce sync.Once
+}
+
+// StartBackgroundUpdater runs the pipeline at regular intervals and updates the server context.
+// This function starts a goroutine that processes the pipeline at the specified frequency
+// and updates the ServerContext with the new pipeline results. The updated context is then
+// used by API handlers to respond to requests with fresh data.
+//
+// The pipeline is processed immediately upon calling this function, before starting the
+// background updates. This ensures TSLs are available as soon as the server starts.
+//
+// Success and failure events are logged using the ServerContext's structured logger:
+// - On success: An info-level message with the update frequency
+// - On failure: An error-level message with the error details and frequency
+//
+// Parameters:
+//   - ctx: Cancelling this context stops the updater, like calling Stop
+//   - pl: The pipeline to process periodically
+//   - serverCtx: The server context to update with pipeline results (must have a valid logger)
+//   - freq: The frequency at which to process the pipeline (e.g., 5m for every 5 minutes)
+//   - opts: Optional settings such as WithJitter, WithOverlapPolicy and WithWarmUp
+//
+// Returns a handle whose Stop method terminates the background goroutine, or an
+// error if freq is not positive.
+//
+// This function is typically called at server startup to ensure TSLs are kept up-to-date.
+func StartBackgroundUpdater(ctx context.Context, pl *pipeline.Pipeline, serverCtx *ServerContext, freq time.Duration, opts ...UpdaterOption) (*BackgroundUpdater, error) {
+	if freq <= 0 {
+		return nil, fmt.Errorf("update frequency must be positive, got %s", freq)
+	}
+
+	u := &BackgroundUpdater{
+		pl:        pl,
+		serverCtx: serverCtx,
+		freq:      freq,
+		overlap:   OverlapSkip,
+		warmUp:    DefaultWarmUpSamples,
+		done:      make(chan struct{}),
+		trigger:   make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+
+	serverCtx.Lock()
+	serverCtx.updaters = append(serverCtx.updaters, u)
+	serverCtx.Unlock()
+
+	// Until its first successful run a tenant gets an empty Context, so that its
+	// requests are denied rather than evaluated against the default trust anchors
+	if u.tenant != "" {
+		serverCtx.Lock()
+		if serverCtx.TenantContexts == nil {
+			serverCtx.TenantContexts = make(map[string]*pipeline.Context)
+		}
+		if _, ok := serverCtx.TenantContexts[u.tenant]; !ok {
+			serverCtx.TenantContexts[u.tenant] = pipeline.NewContext()
+		}
+		serverCtx.Unlock()
+	}
+
+	// Process pipeline immediately to ensure TSLs are loaded without waiting
+	u.runOnce(true)
+
+	ctx, u.cancel = context.WithCancel(ctx)
+	go u.loop(ctx)
+
+	return u, nil
+}
+
+// Stop terminates the background updater and waits for its goroutine to exit.
+// A pipeline run that is already in progress is allowed to finish first.
+// Stop is safe to call more than once and from multiple goroutines.
+func (u *BackgroundUpdater) Stop() {
+	u.stopOnce.Do(u.cancel)
+	<-u.done
+}
+
+// Trigger requests a pipeline run as soon as the current one, if any, has
+// finished, independent of the update frequency. At most one requested run is
+// pending at a time.
+//
+// Returns:
+//   - true if a run was requested, false if one was already pending
+func (u *BackgroundUpdater) Trigger() bool {
+	select {
+	case u.trigger <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done returns a channel that is closed once the updater goroutine has exited.
+func (u *BackgroundUpdater) Done() <-chan struct{} {
+	return u.done
+}
+
+// loop is the updater goroutine. It waits for ticks, applies jitter and runs the
+// pipeline until ctx is cancelled.
+func (u *BackgroundUpdater) loop(ctx context.Context) {
+	defer close(u.done)
+
+	ticker := time.NewTicker(u.freq)
+	defer ticker.Stop()
+
+	for {
+		triggered := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-u.trigger:
+			triggered = true
+		}
+
+		// Requested runs start right away, without jitter
+		if u.jitter > 0 && !triggered {
+			delay := time.Duration(rand.Int64N(int64(u.jitter)))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		u.runOnce(false)
+
+		// A tick that fired while the pipeline was running is still buffered in the
+		// ticker channel. Under OverlapQueue it triggers the next iteration right away;
+		// under OverlapSkip it is drained so the next run waits for a fresh tick.
+		if u.overlap == OverlapSkip {
+			select {
+			case <-ticker.C:
+				u.serverCtx.Logger.Warn("Pipeline run exceeded update frequency, skipping missed run",
+					logging.F("frequency", u.freq.String()))
+			default:
+			}
+		}
+	}
+}
+
+// runOnce processes the pipeline a single time and, on success, warms up the
+// certificate pool of the new Context (see WithWarmUp) and installs it in the
+// ServerContext under the next pool generation (see
+// ServerContext.InstallContext). On failure, including a panic recovered from a
+// pipeline step, the previously installed Context stays active. Every run is added
+// to the ServerContext run history and updates its source statuses, and metrics
+// are recorded if configured.
+func (u *BackgroundUpdater) runOnce(initial bool) {
+	serverCtx := u.serverCtx
+
+	start := pipeline.NewContext()
+	if u.base != nil {
+		start = u.base.Clone()
+	}
+	newCtx, report, err := u.pl.ProcessWithReport(start)
+	duration := report.Duration
+
+	// The new pool is warmed up before it is installed, so that the first
+	// decisions made against it are not slowed down
+	if err == nil && newCtx != nil && u.warmUp > 0 {
+		warmStart := time.Now()
+		if n := warmUp(newCtx, u.warmUp); n > 0 {
+			serverCtx.Logger.Debug("Certificate pool warmed up",
+				logging.F("tenant", u.tenant),
+				logging.F("evaluations", n),
+				logging.F("duration_ms", time.Since(warmStart).Milliseconds()))
+		}
+	}
+
+	serverCtx.Lock()
+	if u.tenant == "" {
+		serverCtx.RecordRun(report)
+		serverCtx.RecordSources(report)
+	}
+	if err == nil && newCtx != nil {
+		serverCtx.InstallContext(u.tenant, newCtx)
+	}
+	generation := serverCtx.PoolGeneration()
+	serverCtx.Unlock()
+
+	if serverCtx.Metrics != nil {
+		serverCtx.Metrics.RecordRunReport(report)
+		serverCtx.Metrics.PoolGeneration.Set(float64(generation))
+	}
+
+	if u.tenant != "" {
+		u.logTenantRun(initial, newCtx, err)
+		return
+	}
+
+	if err != nil {
+		if initial {
+			serverCtx.Logger.Error("Initial pipeline processing failed",
+				logging.F("error", err.Error()))
+		} else {
+			serverCtx.Logger.Error("Pipeline processing failed",
+				logging.F("error", err.Error()),
+				logging.F("frequency", u.freq.String()))
+		}
+
+		// Record error metrics if available
+		if serverCtx.Metrics != nil {
+			serverCtx.Metrics.RecordPipelineExecution(duration, 0, err)
+
+			var panicErr *pipeline.StepPanicError
+			if errors.As(err, &panicErr) {
+				serverCtx.Metrics.RecordPipelinePanic(panicErr.StepName)
+			}
+		}
+		return
+	}
+
+	tslCount := countTSLs(newCtx)
+	if initial {
+		serverCtx.Logger.Info("Initial pipeline processing successful",
+			logging.F("tsl_count", tslCount))
+	} else {
+		serverCtx.Logger.Info("Pipeline processed successfully",
+			logging.F("frequency", u.freq.String()),
+			logging.F("tsl_count", tslCount))
+	}
+
+	// Record metrics if available
+	if serverCtx.Metrics != nil {
+		serverCtx.Metrics.RecordPipelineExecution(duration, tslCount, nil)
+		serverCtx.Metrics.RecordPool(newCtx)
+		serverCtx.Metrics.RecordSignatures(newCtx)
+	}
+}
+
+// logTenantRun logs the outcome of a tenant pipeline run. Tenant runs do not
+// update the pipeline execution metrics, which describe the default pipeline;
+// failures are counted as errors of operation "tenant_pipeline".
+func (u *BackgroundUpdater) logTenantRun(initial bool, newCtx *pipeline.Context, err error) {
+	serverCtx := u.serverCtx
+
+	if err != nil {
+		serverCtx.Logger.Error("Tenant pipeline processing failed",
+			logging.F("tenant", u.tenant),
+			logging.F("initial", initial),
+			logging.F("error", err.Error()))
+		if serverCtx.Metrics != nil {
+			serverCtx.Metrics.RecordError("pipeline_execution", "tenant_pipeline")
+		}
+		return
+	}
+
+	serverCtx.Logger.Info("Tenant pipeline processed successfully",
+		logging.F("tenant", u.tenant),
+		logging.F("initial", initial),
+		logging.F("tsl_count", countTSLs(newCtx)))
+}
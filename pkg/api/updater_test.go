@@ -0,0 +1,370 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingPipeline registers a step under name that counts its invocations and
+// returns a pipeline running only that step.
+func newCountingPipeline(name string, fn func(n int32) error) (*pipeline.Pipeline, *atomic.Int32) {
+	var calls atomic.Int32
+	pipeline.RegisterFunction(name, func(pl *pipeline.Pipeline, ctx *pipeline.Context, args ...string) (*pipeline.Context, error) {
+		n := calls.Add(1)
+		if fn != nil {
+			if err := fn(n); err != nil {
+				return ctx, err
+			}
+		}
+		return ctx, nil
+	})
+	return &pipeline.Pipeline{
+		Pipes:  []pipeline.Pipe{{MethodName: name}},
+		Logger: logging.DefaultLogger(),
+	}, &calls
+}
+
+func TestStartBackgroundUpdater_InvalidFrequency(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_invalid_freq", nil)
+	serverCtx := NewServerContext(nil)
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, 0)
+	assert.Error(t, err)
+	assert.Nil(t, updater)
+	assert.Equal(t, int32(0), calls.Load(), "pipeline must not run when the frequency is invalid")
+}
+
+func TestBackgroundUpdater_Stop(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_stop", nil)
+	serverCtx := NewServerContext(nil)
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, 5*time.Millisecond)
+	require.NoError(t, err)
+
+	// Initial run happens synchronously
+	assert.Equal(t, int32(1), calls.Load())
+	serverCtx.RLock()
+	assert.NotNil(t, serverCtx.PipelineContext)
+	assert.False(t, serverCtx.LastProcessed.IsZero())
+	serverCtx.RUnlock()
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, time.Millisecond)
+
+	updater.Stop()
+	select {
+	case <-updater.Done():
+	default:
+		t.Fatal("Done channel should be closed after Stop")
+	}
+
+	stopped := calls.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, stopped, calls.Load(), "pipeline must not run after Stop")
+
+	// Stop is idempotent
+	updater.Stop()
+}
+
+func TestBackgroundUpdater_ContextCancel(t *testing.T) {
+	pl, _ := newCountingPipeline("updater_ctx_cancel", nil)
+	serverCtx := NewServerContext(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updater, err := StartBackgroundUpdater(ctx, pl, serverCtx, 5*time.Millisecond)
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case <-updater.Done():
+	case <-time.After(time.Second):
+		t.Fatal("updater did not stop after context cancellation")
+	}
+}
+
+func TestBackgroundUpdater_FailedRunKeepsPreviousContext(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_failure", func(n int32) error {
+		if n > 1 {
+			return errors.New("fetch failed")
+		}
+		return nil
+	})
+	serverCtx := NewServerContext(nil)
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, 5*time.Millisecond)
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	serverCtx.RLock()
+	good := serverCtx.PipelineContext
+	lastProcessed := serverCtx.LastProcessed
+	serverCtx.RUnlock()
+	require.NotNil(t, good)
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, time.Millisecond)
+
+	serverCtx.RLock()
+	defer serverCtx.RUnlock()
+	assert.Same(t, good, serverCtx.PipelineContext)
+	assert.Equal(t, lastProcessed, serverCtx.LastProcessed)
+}
+
+func TestBackgroundUpdater_StepPanic(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_panic", func(n int32) error {
+		if n > 1 {
+			panic("step exploded")
+		}
+		return nil
+	})
+	serverCtx := NewServerContext(nil)
+	serverCtx.Metrics = NewMetrics()
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, 5*time.Millisecond)
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	serverCtx.RLock()
+	good := serverCtx.PipelineContext
+	serverCtx.RUnlock()
+	require.NotNil(t, good)
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(serverCtx.Metrics.PipelinePanicsTotal.WithLabelValues("updater_panic")) >= 2
+	}, time.Second, time.Millisecond)
+	assert.GreaterOrEqual(t, calls.Load(), int32(3))
+
+	serverCtx.RLock()
+	defer serverCtx.RUnlock()
+	assert.Same(t, good, serverCtx.PipelineContext, "a panicking run must not replace the active context")
+}
+
+func TestBackgroundUpdater_RunHistory(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_history", nil)
+	serverCtx := NewServerContext(nil)
+	serverCtx.Metrics = NewMetrics()
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, 2*time.Millisecond)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return calls.Load() > maxRunHistory+2 }, 2*time.Second, time.Millisecond)
+	updater.Stop()
+
+	serverCtx.RLock()
+	defer serverCtx.RUnlock()
+	require.Len(t, serverCtx.RunHistory, maxRunHistory)
+	for i := 1; i < len(serverCtx.RunHistory); i++ {
+		assert.True(t, serverCtx.RunHistory[i-1].Start.After(serverCtx.RunHistory[i].Start), "history must be newest first")
+	}
+	require.Len(t, serverCtx.RunHistory[0].Steps, 1)
+	assert.Equal(t, "updater_history", serverCtx.RunHistory[0].Steps[0].Name)
+	assert.Equal(t, 1, testutil.CollectAndCount(serverCtx.Metrics.PipelineStepDuration))
+}
+
+func TestBackgroundUpdater_Jitter(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_jitter", nil)
+	serverCtx := NewServerContext(nil)
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, 5*time.Millisecond,
+		WithJitter(time.Hour))
+	require.NoError(t, err)
+
+	// The first tick fires but the run is delayed by the jitter; Stop must not
+	// wait for the delay to elapse.
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+
+	stopped := make(chan struct{})
+	go func() {
+		updater.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked on jitter delay")
+	}
+}
+
+func TestBackgroundUpdater_OverlapPolicy(t *testing.T) {
+	const freq = 100 * time.Millisecond
+
+	// measure returns how long after the long second run finished the third run started
+	measure := func(t *testing.T, name string, policy OverlapPolicy) time.Duration {
+		release := make(chan struct{})
+		started := make(chan time.Time, 10)
+		pl, _ := newCountingPipeline(name, func(n int32) error {
+			started <- time.Now()
+			if n == 2 {
+				<-release
+			}
+			return nil
+		})
+		serverCtx := NewServerContext(nil)
+
+		updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, freq,
+			WithOverlapPolicy(policy))
+		require.NoError(t, err)
+		defer updater.Stop()
+
+		<-started // initial run
+		<-started // first tick, blocks until released
+
+		// Let the blocked run span more than one tick, releasing halfway between ticks
+		time.Sleep(freq + freq/2)
+		releasedAt := time.Now()
+		close(release)
+
+		select {
+		case third := <-started:
+			return third.Sub(releasedAt)
+		case <-time.After(5 * freq):
+			t.Fatal("third run never started")
+			return 0
+		}
+	}
+
+	t.Run("queue", func(t *testing.T) {
+		assert.Less(t, measure(t, "updater_overlap_queue", OverlapQueue), freq/4)
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		assert.Greater(t, measure(t, "updater_overlap_skip", OverlapSkip), freq/4)
+	})
+}
+
+func TestOverlapPolicy_String(t *testing.T) {
+	assert.Equal(t, "skip", OverlapSkip.String())
+	assert.Equal(t, "queue", OverlapQueue.String())
+	assert.Equal(t, "unknown", OverlapPolicy(42).String())
+}
+
+func TestBackgroundUpdater_ForTenant(t *testing.T) {
+	pl, _ := newCountingPipeline("updater_for_tenant", nil)
+	serverCtx := NewServerContext(nil)
+	defaultCtx := pipeline.NewContext()
+	serverCtx.PipelineContext = defaultCtx
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, time.Hour, ForTenant("bank"))
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	serverCtx.RLock()
+	defer serverCtx.RUnlock()
+	assert.Same(t, defaultCtx, serverCtx.PipelineContext, "tenant runs must not replace the default context")
+	assert.True(t, serverCtx.LastProcessed.IsZero())
+	assert.Empty(t, serverCtx.RunHistory)
+	require.Contains(t, serverCtx.TenantContexts, "bank")
+	assert.NotSame(t, defaultCtx, serverCtx.TenantPipelineContext("bank"))
+	assert.Same(t, defaultCtx, serverCtx.TenantPipelineContext("other"))
+	assert.Same(t, defaultCtx, serverCtx.TenantPipelineContext(""))
+}
+
+func TestBackgroundUpdater_WithBaseContext(t *testing.T) {
+	var seen atomic.Value
+	pipeline.RegisterFunction("updater_base_context", func(pl *pipeline.Pipeline, ctx *pipeline.Context, args ...string) (*pipeline.Context, error) {
+		seen.Store(ctx.Data["origin"])
+		ctx.Data["origin"] = "modified by the run"
+		return ctx, nil
+	})
+	pl := &pipeline.Pipeline{
+		Pipes:  []pipeline.Pipe{{MethodName: "updater_base_context"}},
+		Logger: logging.DefaultLogger(),
+	}
+	base := pipeline.NewContext()
+	base.Data["origin"] = "base"
+	serverCtx := NewServerContext(nil)
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, time.Hour, WithBaseContext(base))
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	assert.Equal(t, "base", seen.Load())
+	assert.Equal(t, "base", base.Data["origin"], "runs must not modify the base context")
+	serverCtx.RLock()
+	defer serverCtx.RUnlock()
+	assert.NotSame(t, base, serverCtx.PipelineContext)
+	assert.Equal(t, "modified by the run", serverCtx.PipelineContext.Data["origin"])
+}
+
+func TestBackgroundUpdater_ForTenantInitialFailure(t *testing.T) {
+	pl, _ := newCountingPipeline("updater_for_tenant_fail", func(int32) error { return errors.New("fetch failed") })
+	serverCtx := NewServerContext(nil)
+	serverCtx.PipelineContext = pipeline.NewContext()
+	serverCtx.Metrics = NewMetrics()
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, time.Hour, ForTenant("bank"))
+	require.NoError(t, err)
+	defer updater.Stop()
+
+	serverCtx.RLock()
+	tenantCtx := serverCtx.TenantPipelineContext("bank")
+	serverCtx.RUnlock()
+
+	// The tenant must not fall back to the default trust anchors
+	require.NotNil(t, tenantCtx)
+	assert.NotSame(t, serverCtx.PipelineContext, tenantCtx)
+	assert.Nil(t, tenantCtx.CertPool)
+	assert.Equal(t, 1.0, testutil.ToFloat64(serverCtx.Metrics.ErrorsTotal.WithLabelValues("pipeline_execution", "tenant_pipeline")))
+}
+
+func TestBackgroundUpdater_PoolGeneration(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_generation", func(n int32) error {
+		if n == 2 {
+			return errors.New("fetch failed")
+		}
+		return nil
+	})
+	serverCtx := NewServerContext(nil)
+	serverCtx.Metrics = NewMetrics()
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, 5*time.Millisecond)
+	require.NoError(t, err)
+
+	serverCtx.RLock()
+	assert.Equal(t, uint64(1), serverCtx.PoolGeneration())
+	assert.Equal(t, uint64(1), serverCtx.PipelineContext.Generation)
+	serverCtx.RUnlock()
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 4 }, time.Second, time.Millisecond)
+	updater.Stop()
+
+	serverCtx.RLock()
+	defer serverCtx.RUnlock()
+	runs := uint64(calls.Load())
+	assert.Equal(t, runs-1, serverCtx.PoolGeneration(), "the failed run does not install a context")
+	assert.Equal(t, serverCtx.PoolGeneration(), serverCtx.PipelineContext.Generation)
+	assert.Equal(t, float64(runs-1), testutil.ToFloat64(serverCtx.Metrics.PoolGeneration))
+}
+
+func TestBackgroundUpdater_Trigger(t *testing.T) {
+	pl, calls := newCountingPipeline("updater_trigger", nil)
+	serverCtx := NewServerContext(nil)
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, time.Hour, WithJitter(time.Hour))
+	require.NoError(t, err)
+	defer updater.Stop()
+	require.Equal(t, int32(1), calls.Load())
+
+	// A triggered run starts without waiting for the tick or the jitter
+	serverCtx.RLock()
+	assert.Equal(t, 1, serverCtx.TriggerRefresh())
+	serverCtx.RUnlock()
+	assert.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	serverCtx.RLock()
+	assert.Equal(t, uint64(2), serverCtx.PoolGeneration())
+	serverCtx.RUnlock()
+
+	// Only one run is pending at a time
+	updater.Stop()
+	assert.True(t, updater.Trigger())
+	assert.False(t, updater.Trigger())
+}
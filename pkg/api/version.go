@@ -0,0 +1,117 @@
+package api
+
+import (
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// XSLTEngine is the external program used by the transform step.
+const XSLTEngine = "xsltproc"
+
+// BuildInfo describes the build of a running instance and the features its
+// configuration enables. It is set on the ServerContext at startup and
+// reported by GET /version.
+type BuildInfo struct {
+	Version      string   // Release version, e.g. "v1.2.0"
+	Commit       string   // Git commit the binary was built from
+	BuildDate    string   // When the binary was built
+	GoVersion    string   // Go toolchain the binary was built with
+	PipelineHash string   // Hex-encoded SHA-256 of the active pipeline files
+	Signing      []string // Signing backends configured in the pipeline, e.g. "file", "pkcs11"
+}
+
+// NewBuildInfo returns the BuildInfo of the running binary. The commit and
+// build date are taken from the Go build information when they are not set
+// at build time with -ldflags.
+func NewBuildInfo(version, commit, buildDate string) *BuildInfo {
+	info := &BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// VersionResponse represents the response from the version endpoint
+type VersionResponse struct {
+	Version      string          `json:"version"`
+	Commit       string          `json:"commit,omitempty"`
+	BuildDate    string          `json:"build_date,omitempty"`
+	GoVersion    string          `json:"go_version"`
+	Features     VersionFeatures `json:"features"`
+	PipelineHash string          `json:"pipeline_hash,omitempty"`
+}
+
+// VersionFeatures lists the features enabled in a running instance
+type VersionFeatures struct {
+	Signing       []string `json:"signing"`        // Signing backends configured in the pipeline
+	Registries    []string `json:"registries"`     // Types of the configured trust registries
+	XSLTEngine    string   `json:"xslt_engine"`    // Program used by the transform step
+	XSLTAvailable bool     `json:"xslt_available"` // Whether the XSLT engine is on the PATH
+}
+
+// VersionHandler godoc
+// @Summary Build information
+// @Description Returns the version, git commit, build date and Go version of the running instance,
+// @Description the features its configuration enables and the SHA-256 hash of the active pipeline file
+// @Tags Health
+// @Produce json
+// @Success 200 {object} VersionResponse
+// @Router /version [get]
+func VersionHandler(serverCtx *ServerContext) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverCtx.RLock()
+		info := serverCtx.BuildInfo
+		registryMgr := serverCtx.RegistryManager
+		serverCtx.RUnlock()
+
+		if info == nil {
+			info = NewBuildInfo("dev", "", "")
+		}
+
+		response := VersionResponse{
+			Version:      info.Version,
+			Commit:       info.Commit,
+			BuildDate:    info.BuildDate,
+			GoVersion:    info.GoVersion,
+			PipelineHash: info.PipelineHash,
+			Features: VersionFeatures{
+				Signing:    info.Signing,
+				Registries: []string{},
+				XSLTEngine: XSLTEngine,
+			},
+		}
+		if response.Features.Signing == nil {
+			response.Features.Signing = []string{}
+		}
+		if registryMgr != nil {
+			for _, reg := range registryMgr.Registries() {
+				response.Features.Registries = append(response.Features.Registries, reg.Type)
+			}
+		}
+		if _, err := exec.LookPath(XSLTEngine); err == nil {
+			response.Features.XSLTAvailable = true
+		}
+
+		serverCtx.Logger.Debug("Version requested",
+			logging.F("remote_ip", c.ClientIP()),
+			logging.F("version", response.Version))
+
+		c.JSON(200, response)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/registry"
+	"github.com/SUNET/go-trust/pkg/registry/etsi"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBuildInfo(t *testing.T) {
+	info := NewBuildInfo("v1.2.3", "abc123", "2026-01-02T03:04:05Z")
+	assert.Equal(t, "v1.2.3", info.Version)
+	assert.Equal(t, "abc123", info.Commit)
+	assert.Equal(t, "2026-01-02T03:04:05Z", info.BuildDate)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serverCtx := NewServerContext(logging.NewLogger(logging.DebugLevel))
+	serverCtx.BuildInfo = NewBuildInfo("v1.2.3", "abc123", "2026-01-02T03:04:05Z")
+	serverCtx.BuildInfo.PipelineHash = "b26644e5cd802002a5fa5ca879d6bd982b53991f01b103b87e6b53175e62d488"
+	serverCtx.BuildInfo.Signing = []string{pipeline.SigningBackendPKCS11}
+	serverCtx.RegistryManager = registry.NewRegistryManager(registry.FirstMatch, time.Second)
+	serverCtx.RegistryManager.Register(etsi.NewTSLRegistry(pipeline.NewContext(), "EU"))
+
+	r := gin.New()
+	RegisterAPIRoutes(r, serverCtx)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response VersionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "v1.2.3", response.Version)
+	assert.Equal(t, "abc123", response.Commit)
+	assert.Equal(t, "2026-01-02T03:04:05Z", response.BuildDate)
+	assert.Equal(t, runtime.Version(), response.GoVersion)
+	assert.Equal(t, serverCtx.BuildInfo.PipelineHash, response.PipelineHash)
+	assert.Equal(t, []string{"pkcs11"}, response.Features.Signing)
+	assert.Equal(t, []string{"etsi_tsl"}, response.Features.Registries)
+	assert.Equal(t, XSLTEngine, response.Features.XSLTEngine)
+}
+
+func TestVersionEndpoint_Defaults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	RegisterAPIRoutes(r, NewServerContext(nil))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "dev", body["version"])
+	assert.NotContains(t, body, "pipeline_hash")
+	features := body["features"].(map[string]any)
+	assert.Equal(t, []any{}, features["signing"], "empty lists, not null")
+	assert.Equal(t, []any{}, features["registries"])
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+)
+
+// DefaultWarmUpSamples is the number of synthetic evaluations run against a
+// new certificate pool before it is installed, unless set with WithWarmUp.
+const DefaultWarmUpSamples = 8
+
+// warmUpTimeout bounds the time spent warming up a certificate pool, so that a
+// slow warm-up never delays installing it by much.
+const warmUpTimeout = 5 * time.Second
+
+// WithWarmUp sets the number of synthetic evaluations run against the
+// certificate pool of a new Context before it is installed (see warmUp). 0
+// disables the warm-up.
+func WithWarmUp(samples int) UpdaterOption {
+	return func(u *BackgroundUpdater) {
+		u.warmUp = samples
+	}
+}
+
+// warmUp evaluates up to samples certificates of the certificate pool of
+// pipelineCtx, spread over the pool, as the decision handler does, and
+// discards the decisions. The first decisions after a pool swap are otherwise
+// slow, as the pool builds its internal indexes and the provenance lookups
+// are cold on first use. It returns the number of evaluations run.
+func warmUp(pipelineCtx *pipeline.Context, samples int) int {
+	if pipelineCtx == nil || pipelineCtx.CertPool == nil || samples <= 0 {
+		return 0
+	}
+	certs := pipelineCtx.PoolCertificates()
+	if len(certs) == 0 {
+		return 0
+	}
+	samples = min(samples, len(certs))
+
+	ctx, cancel := context.WithTimeout(context.Background(), warmUpTimeout)
+	defer cancel()
+	run := 0
+	for i := range samples {
+		if ctx.Err() != nil {
+			break
+		}
+		cert := certs[i*len(certs)/samples].Certificate
+		req := authzen.EvaluationRequest{
+			Subject: authzen.Subject{Type: "key", ID: "warm-up"},
+			Resource: authzen.Resource{
+				Type: authzen.ResourceTypeX5C,
+				ID:   "warm-up",
+				Key:  []interface{}{base64.StdEncoding.EncodeToString(cert.Raw)},
+			},
+		}
+		legacyEvaluate(ctx, pipelineCtx, nil, &req, []*x509.Certificate{cert})
+		run++
+	}
+	return run
+}
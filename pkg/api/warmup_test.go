@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmUp(t *testing.T) {
+	cas, err := testutil.NewCAs("Warm-up CA", 20)
+	require.NoError(t, err)
+	ctx := pipeline.NewContext()
+	for _, ca := range cas {
+		ctx.AddPoolCertificate(ca.Certificate, pipeline.CertProvenance{Provider: "P", Service: ca.Certificate.Subject.CommonName})
+	}
+
+	assert.Equal(t, 8, warmUp(ctx, 8))
+	assert.Equal(t, 20, warmUp(ctx, 100), "at most every certificate is evaluated")
+	assert.Equal(t, 0, warmUp(ctx, 0))
+	assert.Equal(t, 0, warmUp(pipeline.NewContext(), 8), "no pool, nothing to warm up")
+	assert.Equal(t, 0, warmUp(nil, 8))
+}
+
+func TestBackgroundUpdater_WithWarmUp(t *testing.T) {
+	pl := &pipeline.Pipeline{Logger: logging.DefaultLogger()}
+	serverCtx := NewServerContext(nil)
+
+	updater, err := StartBackgroundUpdater(context.Background(), pl, serverCtx, time.Hour)
+	require.NoError(t, err)
+	updater.Stop()
+	assert.Equal(t, DefaultWarmUpSamples, updater.warmUp)
+
+	updater, err = StartBackgroundUpdater(context.Background(), pl, serverCtx, time.Hour, WithWarmUp(0))
+	require.NoError(t, err)
+	updater.Stop()
+	assert.Equal(t, 0, updater.warmUp)
+}
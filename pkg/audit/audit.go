@@ -0,0 +1,344 @@
+// Package audit exports audit events, such as trust decisions and admin
+// actions, in formats a SIEM can ingest without parsing free-form log lines:
+// ArcSight Common Event Format (CEF) or JSON following the Elastic Common
+// Schema (ECS). Events are written to a file, standard output or a syslog
+// collector over UDP, TCP or TLS.
+//
+// An Exporter queues events and writes them in the background, so recording
+// an event never waits for a slow collector unless it is configured to.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// Kinds of audit events.
+const (
+	KindDecision = "trust-decision" // An AuthZEN trust decision
+	KindOverride = "override"       // A decision forced by a fingerprint override
+	KindAdmin    = "admin"          // A request to an admin endpoint
+)
+
+// Outcomes of audit events, in the ECS event.outcome vocabulary.
+const (
+	OutcomeSuccess = "success" // A trusted key or a successful admin request
+	OutcomeFailure = "failure" // A denied key or a failed admin request
+	OutcomeUnknown = "unknown" // A decision that could not be made
+)
+
+// Decisions of trust decision and override events.
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// Event is an audit event. Fields left empty are not exported.
+type Event struct {
+	Time         time.Time
+	Kind         string // KindDecision, KindOverride or KindAdmin
+	Outcome      string // OutcomeSuccess, OutcomeFailure or OutcomeUnknown
+	Decision     string // DecisionAllow or DecisionDeny, for decisions and overrides
+	RequestID    string
+	ClientIP     string
+	SubjectID    string // Name whose key binding was decided
+	ResourceType string
+	Action       string
+	Tenant       string
+	Purpose      string
+	Reason       string // Normalized reason code of the decision
+	Message      string
+	Fingerprint  string // SHA-256 fingerprint of the certificate an override matched
+	Generation   uint64 // Pool generation the decision was made against
+	Duration     time.Duration
+	Method       string // HTTP method of an admin request
+	Path         string // Path of an admin request
+	Status       int    // HTTP status of an admin request
+
+	Request json.RawMessage // AuthZEN request of a decision, if the Exporter includes requests
+}
+
+// Field names of Event, as used in field mappings. A mapping assigns each
+// field the key it is exported under; FieldTime is always exported.
+const (
+	FieldTime         = "time"
+	FieldKind         = "kind"
+	FieldOutcome      = "outcome"
+	FieldDecision     = "decision"
+	FieldRequestID    = "request_id"
+	FieldClientIP     = "client_ip"
+	FieldSubjectID    = "subject_id"
+	FieldResourceType = "resource_type"
+	FieldAction       = "action"
+	FieldTenant       = "tenant"
+	FieldPurpose      = "purpose"
+	FieldReason       = "reason"
+	FieldMessage      = "message"
+	FieldFingerprint  = "fingerprint"
+	FieldGeneration   = "pool_generation"
+	FieldDuration     = "duration_ms"
+	FieldMethod       = "method"
+	FieldPath         = "path"
+	FieldStatus       = "status"
+	FieldRequest      = "request"
+)
+
+// field is a field of an event with its value, a string, int64, time.Time or
+// json.RawMessage.
+type field struct {
+	name  string
+	value interface{}
+}
+
+// fields returns the fields of e that are set, in a fixed order.
+func (e *Event) fields() []field {
+	fields := []field{{FieldTime, e.Time}}
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, field{name, value})
+		}
+	}
+	add(FieldKind, e.Kind)
+	add(FieldOutcome, e.Outcome)
+	add(FieldDecision, e.Decision)
+	add(FieldRequestID, e.RequestID)
+	add(FieldClientIP, e.ClientIP)
+	add(FieldSubjectID, e.SubjectID)
+	add(FieldResourceType, e.ResourceType)
+	add(FieldAction, e.Action)
+	add(FieldTenant, e.Tenant)
+	add(FieldPurpose, e.Purpose)
+	add(FieldReason, e.Reason)
+	add(FieldMessage, e.Message)
+	add(FieldFingerprint, e.Fingerprint)
+	if e.Generation > 0 {
+		fields = append(fields, field{FieldGeneration, int64(e.Generation)})
+	}
+	if e.Duration > 0 {
+		fields = append(fields, field{FieldDuration, e.Duration.Milliseconds()})
+	}
+	add(FieldMethod, e.Method)
+	add(FieldPath, e.Path)
+	if e.Status > 0 {
+		fields = append(fields, field{FieldStatus, int64(e.Status)})
+	}
+	if len(e.Request) > 0 {
+		fields = append(fields, field{FieldRequest, e.Request})
+	}
+	return fields
+}
+
+// severity returns the syslog severity of e: err for decisions that could not
+// be made, warning for denials, notice for overrides and admin requests, and
+// info otherwise.
+func (e *Event) severity() int {
+	switch {
+	case e.Kind == KindDecision && e.Outcome == OutcomeUnknown:
+		return 3
+	case e.Kind == KindDecision && e.Decision == DecisionDeny:
+		return 4
+	case e.Kind == KindOverride || e.Kind == KindAdmin:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// Behaviours of an Exporter whose queue is full.
+const (
+	OnFullDrop  = "drop"  // Discard the event and count it
+	OnFullBlock = "block" // Wait for room in the queue
+)
+
+// DefaultQueueSize is the number of events an Exporter queues when created
+// with a non-positive queue size.
+const DefaultQueueSize = 10000
+
+// Options configures an Exporter.
+type Options struct {
+	Target   string            // "stdout", "stderr", a file path, or udp://, tcp:// or tls://host:port of a syslog collector
+	Format   string            // FormatCEF or FormatECS
+	Fields   map[string]string // Keys of event fields replacing the defaults of the format; "" leaves a field out
+	Facility string            // Syslog facility of network targets; "" uses local0
+	TLS      TLSOptions        // Client TLS settings of a tls:// target
+	Queue    int               // Events queued before OnFull applies; 0 uses DefaultQueueSize
+	OnFull   string            // OnFullDrop (default) or OnFullBlock
+	Version  string            // Version of go-trust reported in CEF headers and ECS observer.version
+
+	// IncludeRequests adds the AuthZEN request of each decision, with its
+	// keys, to decision events, so that the decisions can be replayed
+	IncludeRequests bool
+}
+
+// Exporter writes audit events to a target in the background. Record is safe
+// for concurrent use.
+type Exporter struct {
+	format formatter
+	sink   sink
+	queue  chan Event
+	block  bool
+	logger logging.Logger
+
+	includeRequests bool
+
+	mu          sync.Mutex
+	dropped     int       // Events dropped since last reported
+	failing     bool      // Whether the last write failed
+	lastSuccess time.Time // When an event was last written
+	lastError   string    // Error of the last failed write
+	lastErrorAt time.Time // When a write last failed
+}
+
+// Health reports the outcome of the most recent writes of an Exporter.
+type Health struct {
+	Target      string    // Target events are written to
+	Failing     bool      // Whether the last write failed
+	LastSuccess time.Time // When an event was last written; zero if none was
+	LastError   string    // Error of the last failed write
+	LastErrorAt time.Time // When a write last failed; zero if none did
+}
+
+// Health returns the outcome of the most recent writes of the Exporter. A
+// nil Exporter returns the zero Health.
+func (x *Exporter) Health() Health {
+	if x == nil {
+		return Health{}
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return Health{
+		Target:      x.sink.String(),
+		Failing:     x.failing,
+		LastSuccess: x.lastSuccess,
+		LastError:   x.lastError,
+		LastErrorAt: x.lastErrorAt,
+	}
+}
+
+// New returns an Exporter for opts. Network targets are connected on the
+// first event. Call Start to begin writing.
+func New(opts Options, logger logging.Logger) (*Exporter, error) {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+	format, err := newFormatter(opts.Format, opts.Fields, opts.Version)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSink(opts.Target, opts.Facility, opts.TLS)
+	if err != nil {
+		return nil, err
+	}
+	size := opts.Queue
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	var block bool
+	switch strings.ToLower(opts.OnFull) {
+	case "", OnFullDrop:
+	case OnFullBlock:
+		block = true
+	default:
+		return nil, fmt.Errorf("invalid audit on_full %q: must be %s or %s", opts.OnFull, OnFullDrop, OnFullBlock)
+	}
+	return &Exporter{
+		format: format,
+		sink:   s,
+		queue:  make(chan Event, size),
+		block:  block,
+		logger: logger,
+
+		includeRequests: opts.IncludeRequests,
+	}, nil
+}
+
+// IncludesRequests reports whether decision events carry the AuthZEN request
+// (see Options.IncludeRequests). A nil Exporter includes nothing.
+func (x *Exporter) IncludesRequests() bool {
+	return x != nil && x.includeRequests
+}
+
+// Record queues e for export. If the queue is full, e is dropped, or, if the
+// Exporter blocks when full, Record waits for room. Recording on a nil
+// Exporter does nothing.
+func (x *Exporter) Record(e Event) {
+	if x == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if x.block {
+		x.queue <- e
+		return
+	}
+	select {
+	case x.queue <- e:
+	default:
+		x.mu.Lock()
+		x.dropped++
+		x.mu.Unlock()
+	}
+}
+
+// Start writes the queued events in the background until ctx is cancelled,
+// then writes the events still queued and closes the target. Dropped events
+// and write failures are reported to the logger.
+func (x *Exporter) Start(ctx context.Context) {
+	if x == nil {
+		return
+	}
+	go func() {
+		defer x.sink.Close()
+		for {
+			select {
+			case e := <-x.queue:
+				x.write(&e)
+			case <-ctx.Done():
+				for len(x.queue) > 0 {
+					e := <-x.queue
+					x.write(&e)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// write formats e and writes it to the target.
+func (x *Exporter) write(e *Event) {
+	x.mu.Lock()
+	dropped := x.dropped
+	x.dropped = 0
+	x.mu.Unlock()
+	if dropped > 0 {
+		x.logger.Warn("Audit events dropped, queue full",
+			logging.F("dropped", dropped))
+	}
+
+	err := x.sink.Write(e, x.format.format(e))
+	x.mu.Lock()
+	wasFailing := x.failing
+	x.failing = err != nil
+	if err != nil {
+		x.lastError, x.lastErrorAt = err.Error(), time.Now()
+	} else {
+		x.lastSuccess = time.Now()
+	}
+	x.mu.Unlock()
+	switch {
+	case err != nil && !wasFailing:
+		x.logger.Error("Failed to export audit event",
+			logging.F("target", x.sink.String()),
+			logging.F("error", err.Error()))
+	case err == nil && wasFailing:
+		x.logger.Info("Audit export recovered",
+			logging.F("target", x.sink.String()))
+	}
+}
@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readLines waits until path holds n lines and returns them, failing after a
+// second.
+func readLines(t *testing.T, path string, n int) []string {
+	t.Helper()
+	var lines []string
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		return len(lines) >= n && lines[0] != ""
+	}, time.Second, 10*time.Millisecond)
+	return lines
+}
+
+func TestExporter_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	x, err := New(Options{Target: path, Format: FormatCEF}, logging.NewLogger(logging.DebugLevel))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	x.Start(ctx)
+
+	x.Record(*testEvent())
+	x.Record(Event{Kind: KindAdmin, Outcome: OutcomeSuccess, Method: "POST", Path: "/admin/reload", Status: 200})
+
+	lines := readLines(t, path, 2)
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "|trust-decision:deny|")
+	assert.Contains(t, lines[1], "|admin:success|Admin request|5|")
+	assert.Contains(t, lines[1], "requestMethod=POST request=/admin/reload cn3=200")
+
+	require.Eventually(t, func() bool { return !x.Health().LastSuccess.IsZero() }, time.Second, 10*time.Millisecond)
+	health := x.Health()
+	assert.Equal(t, path, health.Target)
+	assert.False(t, health.Failing)
+	assert.Empty(t, health.LastError)
+}
+
+func TestExporter_DropWhenFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	x, err := New(Options{Target: path, Queue: 2}, logging.NewLogger(logging.DebugLevel))
+	require.NoError(t, err)
+
+	// Events beyond the queue are dropped until the exporter starts
+	for i := 0; i < 5; i++ {
+		x.Record(*testEvent())
+	}
+	assert.Equal(t, 3, x.dropped)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	x.Start(ctx)
+	readLines(t, path, 2)
+	cancel()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	assert.Zero(t, x.dropped, "dropped events are reported with the next write")
+}
+
+func TestExporter_DrainsOnStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	x, err := New(Options{Target: path}, nil)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		x.Record(*testEvent())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	x.Start(ctx)
+	assert.Len(t, readLines(t, path, 3), 3)
+}
+
+func TestExporter_NilRecord(t *testing.T) {
+	var x *Exporter
+	x.Record(*testEvent())
+	x.Start(context.Background())
+}
+
+func TestExporter_TCPSyslog(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		// Octet-counting framing: "<length> <message>"
+		length, err := r.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			return
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err == nil {
+			messages <- string(buf)
+		}
+	}()
+
+	x, err := New(Options{Target: "tcp://" + ln.Addr().String(), Facility: "auth"}, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	x.Start(ctx)
+	x.Record(*testEvent())
+
+	select {
+	case msg := <-messages:
+		// auth (4) * 8 + warning (4)
+		assert.True(t, strings.HasPrefix(msg, "<36>1 2026-03-01T12:00:00Z "), msg)
+		assert.Contains(t, msg, " go-trust ")
+		assert.Contains(t, msg, " trust-decision - {")
+		assert.Contains(t, msg, `"outcome":"failure"`)
+	case <-time.After(time.Second):
+		t.Fatal("no syslog message received")
+	}
+}
+
+func TestNew_InvalidOptions(t *testing.T) {
+	_, err := New(Options{}, nil)
+	assert.Error(t, err, "missing target")
+	_, err = New(Options{Target: "http://siem.example.com:514"}, nil)
+	assert.Error(t, err, "unsupported scheme")
+	_, err = New(Options{Target: "udp://siem.example.com"}, nil)
+	assert.Error(t, err, "missing port")
+	_, err = New(Options{Target: "udp://siem.example.com:514", Facility: "mail2"}, nil)
+	assert.Error(t, err, "unknown facility")
+	_, err = New(Options{Target: "stdout", OnFull: "wait"}, nil)
+	assert.Error(t, err, "unknown on_full")
+}
@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audit event formats.
+const (
+	FormatCEF = "cef" // ArcSight Common Event Format
+	FormatECS = "ecs" // JSON following the Elastic Common Schema
+)
+
+// ECSVersion is the version of the Elastic Common Schema the ECS format follows.
+const ECSVersion = "8.11.0"
+
+// DefaultCEFFields are the CEF extension keys of the event fields. Fields
+// exported as custom strings or numbers (cs1 to cs6, cn1 to cn3) are
+// labelled with their field name (cs1Label=tenant).
+var DefaultCEFFields = map[string]string{
+	FieldTime:         "rt",
+	FieldKind:         "cat",
+	FieldOutcome:      "outcome",
+	FieldDecision:     "act",
+	FieldRequestID:    "externalId",
+	FieldClientIP:     "src",
+	FieldSubjectID:    "suser",
+	FieldResourceType: "cs3",
+	FieldAction:       "cs2",
+	FieldTenant:       "cs1",
+	FieldPurpose:      "cs4",
+	FieldReason:       "reason",
+	FieldMessage:      "msg",
+	FieldFingerprint:  "cs5",
+	FieldGeneration:   "cn1",
+	FieldDuration:     "cn2",
+	FieldMethod:       "requestMethod",
+	FieldPath:         "request",
+	FieldStatus:       "cn3",
+	FieldRequest:      "cs6",
+}
+
+// DefaultECSFields are the ECS keys of the event fields. Dotted keys are
+// written as nested objects.
+var DefaultECSFields = map[string]string{
+	FieldTime:         "@timestamp",
+	FieldKind:         "event.action",
+	FieldOutcome:      "event.outcome",
+	FieldDecision:     "go_trust.decision",
+	FieldRequestID:    "http.request.id",
+	FieldClientIP:     "source.ip",
+	FieldSubjectID:    "user.name",
+	FieldResourceType: "go_trust.resource_type",
+	FieldAction:       "go_trust.action",
+	FieldTenant:       "go_trust.tenant",
+	FieldPurpose:      "go_trust.purpose",
+	FieldReason:       "event.reason",
+	FieldMessage:      "message",
+	FieldFingerprint:  "tls.client.hash.sha256",
+	FieldGeneration:   "go_trust.pool_generation",
+	FieldDuration:     "go_trust.duration_ms",
+	FieldMethod:       "http.request.method",
+	FieldPath:         "url.path",
+	FieldStatus:       "http.response.status_code",
+	FieldRequest:      "go_trust.request",
+}
+
+// formatter turns an event into a record of the export format.
+type formatter interface {
+	format(e *Event) []byte
+}
+
+// newFormatter returns the formatter of format with the default keys of its
+// fields replaced by those in fields.
+func newFormatter(format string, fields map[string]string, version string) (formatter, error) {
+	var defaults map[string]string
+	switch strings.ToLower(format) {
+	case FormatCEF:
+		defaults = DefaultCEFFields
+	case "", FormatECS:
+		defaults = DefaultECSFields
+	default:
+		return nil, fmt.Errorf("unknown audit format %q: must be %s or %s", format, FormatCEF, FormatECS)
+	}
+
+	keys := make(map[string]string, len(defaults))
+	for name, key := range defaults {
+		keys[name] = key
+	}
+	for name, key := range fields {
+		if _, ok := defaults[name]; !ok {
+			return nil, fmt.Errorf("unknown audit field %q", name)
+		}
+		if name == FieldTime && key == "" {
+			return nil, fmt.Errorf("audit field %q cannot be left out", name)
+		}
+		keys[name] = key
+	}
+	if version == "" {
+		version = "dev"
+	}
+
+	if strings.ToLower(format) == FormatCEF {
+		return &cefFormatter{keys: keys, version: version}, nil
+	}
+	return &ecsFormatter{keys: keys, version: version}, nil
+}
+
+// cefFormatter formats events as CEF:0 records.
+type cefFormatter struct {
+	keys    map[string]string
+	version string
+}
+
+// cefNames are the CEF event names by kind and decision.
+var cefNames = map[string]string{
+	KindDecision + ":" + DecisionAllow: "Trust decision allowed",
+	KindDecision + ":" + DecisionDeny:  "Trust decision denied",
+	KindDecision + ":":                 "Trust decision failed",
+	KindOverride + ":" + DecisionAllow: "Trust decision allowed by override",
+	KindOverride + ":" + DecisionDeny:  "Trust decision denied by override",
+	KindAdmin + ":":                    "Admin request",
+}
+
+func (f *cefFormatter) format(e *Event) []byte {
+	signature := e.Kind + ":" + e.Decision
+	name, ok := cefNames[signature]
+	if !ok {
+		name = e.Kind
+	}
+	if e.Kind == KindAdmin {
+		signature = e.Kind + ":" + e.Outcome
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|SUNET|go-trust|%s|%s|%s|%d|",
+		cefHeader(f.version), cefHeader(signature), cefHeader(name), cefSeverity(e))
+	var ext []string
+	for _, fl := range e.fields() {
+		key := f.keys[fl.name]
+		if key == "" {
+			continue
+		}
+		var value string
+		switch v := fl.value.(type) {
+		case time.Time:
+			value = strconv.FormatInt(v.UnixMilli(), 10)
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case json.RawMessage:
+			value = string(v)
+		default:
+			value = fmt.Sprint(v)
+		}
+		ext = append(ext, key+"="+cefValue(value))
+		if isCEFCustom(key) {
+			ext = append(ext, key+"Label="+cefValue(fl.name))
+		}
+	}
+	b.WriteString(strings.Join(ext, " "))
+	return []byte(b.String())
+}
+
+// cefSeverity returns the CEF severity, 0 to 10, of e.
+func cefSeverity(e *Event) int {
+	switch e.severity() {
+	case 3:
+		return 7
+	case 4:
+		return 6
+	case 5:
+		if e.Kind == KindOverride {
+			return 8
+		}
+		return 5
+	default:
+		return 3
+	}
+}
+
+// isCEFCustom reports whether key is one of the custom string or number keys
+// that take a label.
+func isCEFCustom(key string) bool {
+	if len(key) != 3 || (key[:2] != "cs" && key[:2] != "cn") {
+		return false
+	}
+	return key[2] >= '1' && key[2] <= '6'
+}
+
+// cefHeader escapes a CEF header field.
+func cefHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+// cefValue escapes a CEF extension value.
+func cefValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// ecsFormatter formats events as ECS JSON objects.
+type ecsFormatter struct {
+	keys    map[string]string
+	version string
+}
+
+func (f *ecsFormatter) format(e *Event) []byte {
+	doc := map[string]interface{}{}
+	set(doc, "ecs.version", ECSVersion)
+	set(doc, "event.kind", "event")
+	set(doc, "event.module", "go_trust")
+	set(doc, "event.dataset", "go_trust.audit")
+	set(doc, "observer.vendor", "SUNET")
+	set(doc, "observer.product", "go-trust")
+	set(doc, "observer.version", f.version)
+	set(doc, "log.syslog.severity.code", e.severity())
+	for _, fl := range e.fields() {
+		key := f.keys[fl.name]
+		if key == "" {
+			continue
+		}
+		value := fl.value
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC().Format(time.RFC3339Nano)
+		}
+		set(doc, key, value)
+	}
+	// Values are strings, numbers, valid JSON and nested maps of them, which
+	// always marshal
+	data, _ := json.Marshal(doc)
+	return data
+}
+
+// set sets the dotted key of doc to value, creating the nested objects on the
+// way. A key that runs into a value that is not an object is set as is.
+func set(doc map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	for i, part := range parts[:len(parts)-1] {
+		next, ok := doc[part]
+		if !ok {
+			child := map[string]interface{}{}
+			doc[part] = child
+			doc = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			doc[strings.Join(parts[i:], ".")] = value
+			return
+		}
+		doc = child
+	}
+	doc[parts[len(parts)-1]] = value
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEvent returns a denied trust decision.
+func testEvent() *Event {
+	return &Event{
+		Time:         time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Kind:         KindDecision,
+		Outcome:      OutcomeFailure,
+		Decision:     DecisionDeny,
+		RequestID:    "req-1",
+		ClientIP:     "192.0.2.10",
+		SubjectID:    "https://wallet.example.com",
+		ResourceType: "x5c",
+		Action:       "wallet-provider",
+		Tenant:       "acme",
+		Reason:       "unknown_authority",
+		Message:      "certificate signed by unknown authority",
+		Generation:   7,
+		Duration:     12 * time.Millisecond,
+	}
+}
+
+func TestFormat_CEF(t *testing.T) {
+	f, err := newFormatter(FormatCEF, nil, "1.2.3")
+	require.NoError(t, err)
+	record := string(f.format(testEvent()))
+
+	assert.True(t, strings.HasPrefix(record,
+		"CEF:0|SUNET|go-trust|1.2.3|trust-decision:deny|Trust decision denied|6|"), record)
+	assert.Contains(t, record, "rt=1772366400000 ")
+	assert.Contains(t, record, "act=deny ")
+	assert.Contains(t, record, "suser=https://wallet.example.com ")
+	assert.Contains(t, record, "cs2=wallet-provider cs2Label=action ")
+	assert.Contains(t, record, "cs1=acme cs1Label=tenant ")
+	assert.Contains(t, record, "cn1=7 cn1Label=pool_generation ")
+	assert.Contains(t, record, "cn2=12 cn2Label=duration_ms")
+	assert.NotContains(t, record, "requestMethod")
+}
+
+func TestFormat_CEFEscaping(t *testing.T) {
+	f, err := newFormatter(FormatCEF, nil, "a|b")
+	require.NoError(t, err)
+	e := testEvent()
+	e.Message = "a=b\\c\nd"
+	record := string(f.format(e))
+
+	assert.Contains(t, record, `|a\|b|`)
+	assert.Contains(t, record, `msg=a\=b\\c\nd`)
+}
+
+func TestFormat_ECS(t *testing.T) {
+	f, err := newFormatter(FormatECS, nil, "1.2.3")
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(f.format(testEvent()), &doc))
+
+	assert.Equal(t, "2026-03-01T12:00:00Z", doc["@timestamp"])
+	assert.Equal(t, ECSVersion, doc["ecs"].(map[string]interface{})["version"])
+	event := doc["event"].(map[string]interface{})
+	assert.Equal(t, KindDecision, event["action"])
+	assert.Equal(t, OutcomeFailure, event["outcome"])
+	assert.Equal(t, "unknown_authority", event["reason"])
+	assert.Equal(t, "192.0.2.10", doc["source"].(map[string]interface{})["ip"])
+	goTrust := doc["go_trust"].(map[string]interface{})
+	assert.Equal(t, "deny", goTrust["decision"])
+	assert.EqualValues(t, 7, goTrust["pool_generation"])
+	assert.Equal(t, "1.2.3", doc["observer"].(map[string]interface{})["version"])
+	assert.EqualValues(t, 4, doc["log"].(map[string]interface{})["syslog"].(map[string]interface{})["severity"].(map[string]interface{})["code"])
+	assert.NotContains(t, doc, "url")
+}
+
+func TestFormat_Request(t *testing.T) {
+	e := testEvent()
+	e.Request = json.RawMessage(`{"subject":{"type":"key","id":"wallet"}}`)
+
+	f, err := newFormatter(FormatECS, nil, "")
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(f.format(e), &doc))
+	request := doc["go_trust"].(map[string]interface{})["request"].(map[string]interface{})
+	assert.Equal(t, "wallet", request["subject"].(map[string]interface{})["id"])
+
+	f, err = newFormatter(FormatCEF, nil, "")
+	require.NoError(t, err)
+	assert.Contains(t, string(f.format(e)), `cs6={"subject":{"type":"key","id":"wallet"}} cs6Label=request`)
+}
+
+func TestFormat_FieldMapping(t *testing.T) {
+	f, err := newFormatter(FormatECS, map[string]string{
+		FieldSubjectID: "client.name",
+		FieldMessage:   "",
+	}, "")
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(f.format(testEvent()), &doc))
+
+	assert.Equal(t, "https://wallet.example.com", doc["client"].(map[string]interface{})["name"])
+	assert.NotContains(t, doc, "user")
+	assert.NotContains(t, doc, "message")
+	assert.Equal(t, "dev", doc["observer"].(map[string]interface{})["version"])
+}
+
+func TestFormat_Invalid(t *testing.T) {
+	_, err := newFormatter("leef", nil, "")
+	assert.Error(t, err)
+	_, err = newFormatter(FormatCEF, map[string]string{"subject": "suser"}, "")
+	assert.Error(t, err)
+	_, err = newFormatter(FormatCEF, map[string]string{FieldTime: ""}, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package audit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// TLSOptions are the client TLS settings of a tls:// target.
+type TLSOptions struct {
+	CAFile   string // PEM bundle of CAs trusted for the collector; "" uses the system roots
+	CertFile string // PEM client certificate (optional)
+	KeyFile  string // PEM key of the client certificate
+}
+
+// dialTimeout bounds connecting to a syslog collector, and writeTimeout
+// writing a record to it. After a failed connection attempt, records fail
+// without a new attempt for reconnectDelay, so that an unreachable collector
+// does not slow the export down to one record per dialTimeout.
+const (
+	dialTimeout    = 5 * time.Second
+	writeTimeout   = 5 * time.Second
+	reconnectDelay = 5 * time.Second
+)
+
+// syslogFacilities are the syslog facility codes by name.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5, "authpriv": 10,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// sink is the target an Exporter writes records to.
+type sink interface {
+	Write(e *Event, record []byte) error
+	Close() error
+	String() string
+}
+
+// newSink returns the sink of target: a syslog collector for udp://, tcp://
+// and tls:// URLs, or else the log output named by target.
+func newSink(target, facility string, tlsOpts TLSOptions) (sink, error) {
+	if target == "" {
+		return nil, fmt.Errorf("missing audit target")
+	}
+	if !strings.Contains(target, "://") {
+		w, err := logging.OpenOutput(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit target: %w", err)
+		}
+		return &writerSink{w: w, name: target}, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || u.Port() == "" {
+		return nil, fmt.Errorf("invalid audit target %q: must be udp://, tcp:// or tls://host:port", target)
+	}
+	s := &syslogSink{network: u.Scheme, addr: u.Host, name: target}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "tls":
+		s.network = "tcp"
+		if s.tls, err = tlsConfig(u.Hostname(), tlsOpts); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid audit target %q: unsupported scheme %q", target, u.Scheme)
+	}
+	if facility == "" {
+		facility = "local0"
+	}
+	code, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+	s.facility = code
+	if s.hostname, err = os.Hostname(); err != nil || s.hostname == "" {
+		s.hostname = "-"
+	}
+	return s, nil
+}
+
+// tlsConfig returns the client TLS configuration for a collector at host.
+func tlsConfig(host string, opts TLSOptions) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit TLS CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in audit TLS CA file %s", opts.CAFile)
+		}
+	}
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load audit TLS client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// writerSink writes records as lines to a file or standard stream.
+type writerSink struct {
+	w    io.Writer
+	name string
+}
+
+func (s *writerSink) Write(_ *Event, record []byte) error {
+	_, err := s.w.Write(append(record, '\n'))
+	return err
+}
+
+func (s *writerSink) Close() error {
+	if f, ok := s.w.(*os.File); ok && f != os.Stdout && f != os.Stderr {
+		return f.Close()
+	}
+	return nil
+}
+
+func (s *writerSink) String() string {
+	return s.name
+}
+
+// syslogSink sends records to a syslog collector as RFC 5424 messages: one
+// per datagram over UDP, and with octet-counting framing (RFC 6587) over TCP
+// and TLS. The connection is opened on the first write and opened again on
+// the next write after a failure, but at most once every reconnectDelay.
+type syslogSink struct {
+	network  string
+	addr     string
+	tls      *tls.Config
+	facility int
+	hostname string
+	name     string
+
+	conn    net.Conn
+	retryAt time.Time // No connection attempt before
+}
+
+func (s *syslogSink) Write(e *Event, record []byte) error {
+	msg := fmt.Sprintf("<%d>1 %s %s go-trust %d %s - %s",
+		s.facility*8+e.severity(),
+		e.Time.UTC().Format(time.RFC3339Nano),
+		s.hostname,
+		os.Getpid(),
+		e.Kind,
+		record)
+	if s.network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := io.WriteString(s.conn, msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+// connect opens the connection to the collector.
+func (s *syslogSink) connect() error {
+	if time.Now().Before(s.retryAt) {
+		return fmt.Errorf("%s unavailable, not reconnecting before %s", s.name, s.retryAt.Format(time.RFC3339))
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	var err error
+	if s.tls != nil {
+		s.conn, err = tls.DialWithDialer(dialer, s.network, s.addr, s.tls)
+	} else {
+		s.conn, err = dialer.Dial(s.network, s.addr)
+	}
+	if err != nil {
+		s.conn = nil
+		s.retryAt = time.Now().Add(reconnectDelay)
+		return fmt.Errorf("failed to connect to %s: %w", s.name, err)
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *syslogSink) String() string {
+	return s.name
+}
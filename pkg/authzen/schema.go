@@ -0,0 +1,227 @@
+package authzen
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaDialect is the JSON Schema dialect of the schemas returned by Schema.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns the JSON Schema of the JSON encoding of v, generated from
+// its Go type by reflection. Structs are described by their exported fields
+// under their json tag names, with untagged embedded structs flattened: fields without omitempty that are not pointers
+// are required, example tags become examples and enums tags (comma-separated,
+// as used by swag) restrict the values of string fields. Structs other than
+// the type of v itself are placed in $defs and referenced by type name.
+//
+// Parameters:
+//   - v: A value of the type to describe, such as EvaluationRequest{}
+//
+// Returns:
+//   - The schema as a JSON object
+func Schema(v interface{}) map[string]interface{} {
+	g := &schemaGenerator{defs: map[string]interface{}{}}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	schema := map[string]interface{}{"$schema": SchemaDialect}
+	if t.Kind() == reflect.Struct {
+		schema["title"] = t.Name()
+		for k, v := range g.structSchema(t) {
+			schema[k] = v
+		}
+	} else {
+		for k, v := range g.typeSchema(t) {
+			schema[k] = v
+		}
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema
+}
+
+// EvaluationRequestSchema returns the JSON Schema of EvaluationRequest with the
+// rules of the Trust Registry Profile that Validate checks: subject.type is
+// "key", resource.type is "jwk" or "x5c" with a non-empty resource.key, or for
+// entity resources, subject.type is "key" or "entity" and resource.id an
+// entity identifier URL. That resource.id equals subject.id cannot be
+// expressed in JSON Schema and is only stated in the description.
+func EvaluationRequestSchema() map[string]interface{} {
+	schema := Schema(EvaluationRequest{})
+	schema["description"] = "AuthZEN Trust Registry Profile evaluation request (draft-johansson-authzen-trust). " +
+		"resource.id MUST be equal to subject.id."
+
+	defs := schema["$defs"].(map[string]interface{})
+	resource := defs["Resource"].(map[string]interface{})
+	resource["required"] = []string{"type", "id"}
+
+	schema["if"] = map[string]interface{}{
+		"properties": map[string]interface{}{
+			"resource": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{"const": ResourceTypeEntity},
+				},
+			},
+		},
+	}
+	schema["then"] = map[string]interface{}{
+		"properties": map[string]interface{}{
+			"resource": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{"pattern": "^https?://"},
+				},
+			},
+		},
+	}
+	schema["else"] = map[string]interface{}{
+		"properties": map[string]interface{}{
+			"subject": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"type": map[string]interface{}{"const": "key"},
+				},
+			},
+			"resource": map[string]interface{}{
+				"required": []string{"key"},
+				"properties": map[string]interface{}{
+					"key": map[string]interface{}{"minItems": 1},
+				},
+			},
+		},
+	}
+	return schema
+}
+
+// EvaluationResponseSchema returns the JSON Schema of EvaluationResponse.
+func EvaluationResponseSchema() map[string]interface{} {
+	schema := Schema(EvaluationResponse{})
+	schema["description"] = "AuthZEN evaluation response with the trust decision"
+	return schema
+}
+
+// schemaGenerator collects the definitions of the structs referenced by a
+// schema.
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+// typeSchema returns the schema of values of type t.
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		schema := map[string]interface{}{"type": "array"}
+		if items := g.typeSchema(t.Elem()); len(items) > 0 {
+			schema["items"] = items
+		}
+		return schema
+	case reflect.Map:
+		schema := map[string]interface{}{"type": "object"}
+		if values := g.typeSchema(t.Elem()); len(values) > 0 {
+			schema["additionalProperties"] = values
+		}
+		return schema
+	case reflect.Struct:
+		if _, ok := g.defs[t.Name()]; !ok {
+			g.defs[t.Name()] = nil // Placeholder for recursive types
+			g.defs[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	default:
+		// Interfaces accept any JSON value
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema returns the object schema of struct type t.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		// Untagged embedded structs are flattened, as by encoding/json
+		embedded := field.Type
+		for embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if field.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			inner := g.structSchema(embedded)
+			for k, v := range inner["properties"].(map[string]interface{}) {
+				properties[k] = v
+			}
+			if field.Type.Kind() != reflect.Pointer {
+				innerRequired, _ := inner["required"].([]string)
+				required = append(required, innerRequired...)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := g.typeSchema(field.Type)
+		if enums := field.Tag.Get("enums"); enums != "" && field.Type.Kind() == reflect.String {
+			prop["enum"] = strings.Split(enums, ",")
+		}
+		if example, ok := field.Tag.Lookup("example"); ok {
+			prop["examples"] = []interface{}{exampleValue(field.Type, example)}
+		}
+		properties[name] = prop
+
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// exampleValue converts the example tag of a field of type t to a JSON value
+// of that type, keeping it a string if it cannot be converted.
+func exampleValue(t reflect.Type, example string) interface{} {
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(example); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(example, 10, 64); err == nil {
+			return n
+		}
+	}
+	return example
+}
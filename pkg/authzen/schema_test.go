@@ -0,0 +1,147 @@
+package authzen
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// property returns the schema of property name of the object schema obj.
+func property(t *testing.T, obj map[string]interface{}, name string) map[string]interface{} {
+	t.Helper()
+	props, ok := obj["properties"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("schema has no properties: %v", obj)
+	}
+	prop, ok := props[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("schema has no property %q: %v", name, props)
+	}
+	return prop
+}
+
+func TestSchema(t *testing.T) {
+	type inner struct {
+		Count uint64 `json:"count" example:"42"`
+	}
+	type embedded struct {
+		Source string `json:"source"`
+	}
+	type sample struct {
+		embedded
+		Name     string            `json:"name" enums:"a,b"`
+		Enabled  bool              `json:"enabled,omitempty" example:"true"`
+		Inner    *inner            `json:"inner"`
+		Items    []inner           `json:"items,omitempty"`
+		Labels   map[string]string `json:"labels,omitempty"`
+		Any      interface{}       `json:"any,omitempty"`
+		Skipped  string            `json:"-"`
+		internal string
+	}
+
+	schema := Schema(&sample{})
+	if schema["$schema"] != SchemaDialect || schema["title"] != "sample" || schema["type"] != "object" {
+		t.Errorf("unexpected schema header: %v", schema)
+	}
+	if got, want := schema["required"], []string{"source", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("required = %v, want %v", got, want)
+	}
+
+	props := schema["properties"].(map[string]interface{})
+	for _, name := range []string{"Skipped", "-", "internal"} {
+		if _, ok := props[name]; ok {
+			t.Errorf("property %q must not be described", name)
+		}
+	}
+	if len(props) != 7 {
+		t.Errorf("got %d properties, want 7: %v", len(props), props)
+	}
+
+	if got := property(t, schema, "name")["enum"]; !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("name enum = %v", got)
+	}
+	if got := property(t, schema, "enabled"); got["type"] != "boolean" || !reflect.DeepEqual(got["examples"], []interface{}{true}) {
+		t.Errorf("enabled = %v", got)
+	}
+	if got := property(t, schema, "inner")["$ref"]; got != "#/$defs/inner" {
+		t.Errorf("inner $ref = %v", got)
+	}
+	if got := property(t, schema, "items")["items"]; !reflect.DeepEqual(got, map[string]interface{}{"$ref": "#/$defs/inner"}) {
+		t.Errorf("items items = %v", got)
+	}
+	if got := property(t, schema, "labels")["additionalProperties"]; !reflect.DeepEqual(got, map[string]interface{}{"type": "string"}) {
+		t.Errorf("labels additionalProperties = %v", got)
+	}
+	if got := property(t, schema, "any"); len(got) != 0 {
+		t.Errorf("any = %v, want an unconstrained schema", got)
+	}
+
+	defs := schema["$defs"].(map[string]interface{})
+	count := property(t, defs["inner"].(map[string]interface{}), "count")
+	if count["type"] != "integer" || count["minimum"] != 0 || !reflect.DeepEqual(count["examples"], []interface{}{int64(42)}) {
+		t.Errorf("count = %v", count)
+	}
+}
+
+func TestEvaluationRequestSchema(t *testing.T) {
+	schema := EvaluationRequestSchema()
+
+	// The schema must be encodable as JSON
+	if _, err := json.Marshal(schema); err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	if got, want := schema["required"], []string{"subject", "resource"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("required = %v, want %v", got, want)
+	}
+	for _, name := range []string{"subject", "resource", "action", "context"} {
+		property(t, schema, name)
+	}
+
+	defs := schema["$defs"].(map[string]interface{})
+	subject := defs["Subject"].(map[string]interface{})
+	if got := property(t, subject, "type")["enum"]; !reflect.DeepEqual(got, []string{"key", "entity"}) {
+		t.Errorf("subject.type enum = %v", got)
+	}
+	resource := defs["Resource"].(map[string]interface{})
+	if got := property(t, resource, "type")["enum"]; !reflect.DeepEqual(got, []string{ResourceTypeJWK, ResourceTypeX5C, ResourceTypeEntity}) {
+		t.Errorf("resource.type enum = %v", got)
+	}
+
+	// resource.key is only required for key resources
+	if got := resource["required"]; !reflect.DeepEqual(got, []string{"type", "id"}) {
+		t.Errorf("resource required = %v", got)
+	}
+	keyRules := property(t, schema["else"].(map[string]interface{}), "resource")
+	if got := keyRules["required"]; !reflect.DeepEqual(got, []string{"key"}) {
+		t.Errorf("else resource required = %v", got)
+	}
+	if schema["if"] == nil || schema["then"] == nil {
+		t.Error("entity rules are missing")
+	}
+}
+
+func TestEvaluationResponseSchema(t *testing.T) {
+	schema := EvaluationResponseSchema()
+
+	if got, want := schema["required"], []string{"decision"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("required = %v, want %v", got, want)
+	}
+	if got := property(t, schema, "decision")["type"]; got != "boolean" {
+		t.Errorf("decision type = %v", got)
+	}
+
+	defs := schema["$defs"].(map[string]interface{})
+	ctx := defs["EvaluationResponseContext"].(map[string]interface{})
+	for _, name := range []string{"id", "reason", "pool_generation"} {
+		property(t, ctx, name)
+	}
+	if _, ok := ctx["required"]; ok {
+		t.Errorf("context has no required properties, got %v", ctx["required"])
+	}
+
+	user := defs["UserReason"].(map[string]interface{})
+	if got, want := user["required"], []string{"code", "message"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reason_user required = %v, want %v", got, want)
+	}
+}
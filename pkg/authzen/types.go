@@ -6,28 +6,42 @@
 // draft-johansson-authzen-trust: https://leifj.github.io/draft-johansson-authzen-trust/
 package authzen
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+// Resource types. The Trust Registry Profile defines "jwk" and "x5c", whose
+// resource.key carries the public key. "entity" names an OpenID Federation
+// entity by its entity identifier (an http or https URL) in subject.id and
+// resource.id; its keys are resolved from the federation, so resource.key is
+// optional.
+const (
+	ResourceTypeJWK    = "jwk"
+	ResourceTypeX5C    = "x5c"
+	ResourceTypeEntity = "entity"
+)
 
 // Subject represents the name part of the name-to-key binding in a trust evaluation request.
 // According to the AuthZEN Trust Registry Profile:
-// - type MUST be the constant string "key"
+// - type MUST be the constant string "key" ("key" or "entity" for entity resources)
 // - id MUST be the name bound to the public key to be validated
 // @Description Subject in an AuthZEN trust evaluation request
 type Subject struct {
-	Type string `json:"type" example:"key"`           // MUST be "key"
-	ID   string `json:"id" example:"did:example:123"` // The name bound to the public key
+	Type string `json:"type" example:"key" enums:"key,entity"` // MUST be "key", or "entity" for entity resources
+	ID   string `json:"id" example:"did:example:123"`          // The name bound to the public key
 }
 
 // Resource represents the public key part of the name-to-key binding in a trust evaluation request.
 // According to the AuthZEN Trust Registry Profile:
-// - type MUST be one of "jwk" or "x5c"
+// - type MUST be one of "jwk" or "x5c" (or "entity", see ResourceTypeEntity)
 // - id MUST be the same as subject.id
 // - key MUST contain the public key in the format specified by type
 // @Description Resource (public key) in an AuthZEN trust evaluation request
 type Resource struct {
-	Type string        `json:"type" example:"x5c"`             // MUST be "jwk" or "x5c"
-	ID   string        `json:"id" example:"did:example:123"`   // MUST match subject.id
-	Key  []interface{} `json:"key" swaggertype:"array,string"` // Public key data (JWK object or x5c array)
+	Type string        `json:"type" example:"x5c" enums:"jwk,x5c,entity"` // MUST be "jwk", "x5c" or "entity"
+	ID   string        `json:"id" example:"did:example:123"`              // MUST match subject.id
+	Key  []interface{} `json:"key" swaggertype:"array,string"`            // Public key data (JWK object or x5c array)
 }
 
 // Action represents the role associated with the name-to-key binding.
@@ -64,13 +78,34 @@ type EvaluationResponse struct {
 type EvaluationResponseContext struct {
 	ID     string                 `json:"id,omitempty" example:"decision-123"`   // Optional identifier for the decision
 	Reason map[string]interface{} `json:"reason,omitempty" swaggertype:"object"` // Reason information (user or admin)
+
+	// PoolGeneration identifies the certificate pool snapshot the decision was
+	// made against, so that it can be tied to a specific pipeline run
+	PoolGeneration uint64 `json:"pool_generation,omitempty" example:"42"`
+
+	// ReasonUser explains a denial to the end user, such as a wallet holder,
+	// in their language, while Reason holds the technical details for
+	// administrators
+	ReasonUser *UserReason `json:"reason_user,omitempty"`
+}
+
+// UserReason is the end-user explanation of a denied decision: a stable code,
+// for clients that show their own text, and a message from the server's
+// message catalog in the language that best matches the request.
+// @Description End-user explanation of a denied decision
+type UserReason struct {
+	Code    string `json:"code" example:"expired"`                                             // Stable reason code
+	Message string `json:"message" example:"The certificate has expired or is not yet valid."` // Human-readable message
+	Lang    string `json:"lang,omitempty" example:"en"`                                        // Language of the message
 }
 
 // Validate checks if the EvaluationRequest is compliant with the AuthZEN Trust Registry Profile.
 // Returns an error if the request doesn't meet the specification requirements.
 func (r *EvaluationRequest) Validate() error {
-	// Subject.type MUST be "key"
-	if r.Subject.Type != "key" {
+	entity := r.Resource.Type == ResourceTypeEntity
+
+	// Subject.type MUST be "key", or "key" or "entity" for entity resources
+	if r.Subject.Type != "key" && !(entity && r.Subject.Type == "entity") {
 		return fmt.Errorf("subject.type must be 'key', got '%s'", r.Subject.Type)
 	}
 
@@ -79,9 +114,9 @@ func (r *EvaluationRequest) Validate() error {
 		return fmt.Errorf("subject.id must be present")
 	}
 
-	// Resource.type MUST be "jwk" or "x5c"
-	if r.Resource.Type != "jwk" && r.Resource.Type != "x5c" {
-		return fmt.Errorf("resource.type must be 'jwk' or 'x5c', got '%s'", r.Resource.Type)
+	// Resource.type MUST be "jwk", "x5c" or "entity"
+	if r.Resource.Type != ResourceTypeJWK && r.Resource.Type != ResourceTypeX5C && !entity {
+		return fmt.Errorf("resource.type must be 'jwk', 'x5c' or 'entity', got '%s'", r.Resource.Type)
 	}
 
 	// Resource.id MUST be present and MUST match subject.id
@@ -92,6 +127,15 @@ func (r *EvaluationRequest) Validate() error {
 		return fmt.Errorf("resource.id (%s) must match subject.id (%s)", r.Resource.ID, r.Subject.ID)
 	}
 
+	// An entity is named by its entity identifier, and its keys come from
+	// the federation
+	if entity {
+		if !strings.HasPrefix(r.Resource.ID, "https://") && !strings.HasPrefix(r.Resource.ID, "http://") {
+			return fmt.Errorf("resource.id must be an entity identifier URL for resource.type 'entity', got '%s'", r.Resource.ID)
+		}
+		return nil
+	}
+
 	// Resource.key MUST be present
 	if len(r.Resource.Key) == 0 {
 		return fmt.Errorf("resource.key must be present and non-empty")
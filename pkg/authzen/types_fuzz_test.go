@@ -0,0 +1,44 @@
+package authzen
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// FuzzEvaluationRequestValidate checks that Validate accepts or rejects any
+// decoded request without panicking, and that its verdict survives a round
+// trip through JSON.
+func FuzzEvaluationRequestValidate(f *testing.F) {
+	for _, seed := range []string{
+		`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"alice","key":["MIIB"]}}`,
+		`{"subject":{"type":"key","id":"alice"},"resource":{"type":"jwk","id":"alice","key":[{"kty":"EC","x5c":["MIIB"]}]},"action":{"name":"issuer"}}`,
+		`{"subject":{"type":"entity","id":"https://rp.example.com"},"resource":{"type":"entity","id":"https://rp.example.com"}}`,
+		`{"subject":{"type":"key","id":"alice"},"resource":{"type":"x5c","id":"bob","key":["MIIB"]},"context":{"territories":["SE"],"qualification":true}}`,
+		`{"subject":{"type":"key","id":""},"resource":{"type":"x5c","id":"","key":[]}}`,
+		`{"subject":{"type":"user","id":"alice"},"resource":{"type":"other","id":"alice","key":[null]}}`,
+		`{"action":null,"context":null}`,
+		`{"subject":{"type":"key","id":"` + strings.Repeat("a", 4096) + `"}}`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var req EvaluationRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			t.Skip()
+		}
+		err := req.Validate()
+
+		encoded, marshalErr := json.Marshal(&req)
+		if marshalErr != nil {
+			t.Fatalf("decoded request does not encode: %v", marshalErr)
+		}
+		var decoded EvaluationRequest
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("encoded request does not decode: %v", err)
+		}
+		if again := decoded.Validate(); (again == nil) != (err == nil) {
+			t.Fatalf("validation changed after a round trip: %v, then %v", err, again)
+		}
+	})
+}
@@ -39,6 +39,49 @@ func TestEvaluationRequestValidation(t *testing.T) {
 			wantError: true,
 			errorMsg:  "resource.id (bob) must match subject.id (alice)",
 		},
+		{
+			name: "valid entity request",
+			request: EvaluationRequest{
+				Subject:  Subject{Type: "entity", ID: "https://rp.example.com"},
+				Resource: Resource{Type: "entity", ID: "https://rp.example.com"},
+			},
+			wantError: false,
+		},
+		{
+			name: "entity request with key subject",
+			request: EvaluationRequest{
+				Subject:  Subject{Type: "key", ID: "https://rp.example.com"},
+				Resource: Resource{Type: "entity", ID: "https://rp.example.com"},
+			},
+			wantError: false,
+		},
+		{
+			name: "entity subject with x5c resource",
+			request: EvaluationRequest{
+				Subject:  Subject{Type: "entity", ID: "https://rp.example.com"},
+				Resource: Resource{Type: "x5c", ID: "https://rp.example.com", Key: []interface{}{"cert"}},
+			},
+			wantError: true,
+			errorMsg:  "subject.type must be 'key'",
+		},
+		{
+			name: "entity identifier is not a URL",
+			request: EvaluationRequest{
+				Subject:  Subject{Type: "entity", ID: "rp.example.com"},
+				Resource: Resource{Type: "entity", ID: "rp.example.com"},
+			},
+			wantError: true,
+			errorMsg:  "resource.id must be an entity identifier URL",
+		},
+		{
+			name: "x5c request without key",
+			request: EvaluationRequest{
+				Subject:  Subject{Type: "key", ID: "alice"},
+				Resource: Resource{Type: "x5c", ID: "alice"},
+			},
+			wantError: true,
+			errorMsg:  "resource.key must be present",
+		},
 	}
 
 	for _, tt := range tests {
@@ -4,30 +4,94 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
 	"github.com/SUNET/go-trust/pkg/validation"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the application configuration structure.
-// It includes settings for the server, logging, pipeline processing, and security.
+// It includes settings for the server, logging, pipeline processing, security,
+// and additional trust registries.
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Logging  LoggingConfig  `yaml:"logging"`
-	Pipeline PipelineConfig `yaml:"pipeline"`
-	Security SecurityConfig `yaml:"security"`
+	Server     ServerConfig     `yaml:"server"`
+	Logging    LoggingConfig    `yaml:"logging"`
+	Pipeline   PipelineConfig   `yaml:"pipeline"`
+	Security   SecurityConfig   `yaml:"security"`
+	Registries RegistriesConfig `yaml:"registries"`
+
+	Profiles map[string]yaml.Node `yaml:"profiles"` // Configuration values of named profiles, applied over the rest of the file when active
+	Profile  string               `yaml:"-"`        // Name of the active profile, empty if none
 }
 
 // ServerConfig contains HTTP server configuration settings.
 type ServerConfig struct {
-	Host        string        `yaml:"host"`
-	Port        string        `yaml:"port"`
-	Frequency   time.Duration `yaml:"frequency"`
-	ExternalURL string        `yaml:"external_url"` // External URL for PDP discovery (e.g., https://pdp.example.com)
+	Host            string        `yaml:"host"`
+	Port            string        `yaml:"port"`
+	Frequency       time.Duration `yaml:"frequency"`
+	FrequencyJitter time.Duration `yaml:"frequency_jitter"` // Random delay added before each scheduled pipeline run
+	ExternalURL     string        `yaml:"external_url"`     // External URL for PDP discovery (e.g., https://pdp.example.com)
+	PublishDir      string        `yaml:"publish_dir"`      // Directory of published TSLs to serve under /published (optional)
+	TrustedProxies  []string      `yaml:"trusted_proxies"`  // IPs or CIDRs of reverse proxies whose X-Forwarded-For is trusted; empty trusts none
+	Swagger         bool          `yaml:"swagger"`          // Serve the Swagger UI under /swagger/
+	DecisionTimeout time.Duration `yaml:"decision_timeout"` // Deadline of each AuthZEN trust decision
+	ServerTiming    bool          `yaml:"server_timing"`    // Report decision phase durations in a Server-Timing response header
+	Languages       []string      `yaml:"languages"`        // Preferred languages of TSL names in API responses and published HTML, most preferred first
+
+	// URL under which the output of generate-provider-pages is published,
+	// absolute or a path on this server such as /published; API responses
+	// naming a trust service provider link to its page (optional)
+	ProviderPagesURL string `yaml:"provider_pages_url"`
+
+	// Most AuthZEN evaluations processed at once; further requests are
+	// answered with 503 and Retry-After at once rather than queued. 0 is
+	// unlimited
+	MaxConcurrentEvaluations int `yaml:"max_concurrent_evaluations"`
+
+	// Synthetic evaluations run against the certificate pool of each pipeline
+	// run before it is installed, so that the first decisions after a swap
+	// are not slowed down by cold pool internals; 0 disables the warm-up
+	WarmUpSamples int `yaml:"warm_up_samples"`
+
+	// YAML file of end-user messages of denial reasons by language, returned
+	// as reason_user in AuthZEN responses; English messages are built in
+	// (optional)
+	UserMessages string `yaml:"user_messages"`
+
+	// Address of a bare TCP health listener, such as 127.0.0.1:6002, that
+	// accepts connections once the service is ready, for orchestrators whose
+	// probes only open a connection (optional)
+	HealthAddr string `yaml:"health_addr"`
+
+	Proxy ProxyConfig `yaml:"proxy"` // Re-serve the TSLs fetched by the pipeline under /proxy
+
+	TLS ServerTLSConfig `yaml:"tls"` // Serve HTTPS, optionally authenticating clients by certificate
+}
+
+// ServerTLSConfig configures HTTPS for the API server. An empty certificate
+// file serves plain HTTP. With a client CA file, clients authenticate with
+// certificates issued by those CAs (mTLS).
+type ServerTLSConfig struct {
+	CertFile     string `yaml:"cert_file"`      // PEM server certificate chain (optional)
+	KeyFile      string `yaml:"key_file"`       // PEM key of the server certificate
+	ClientCAFile string `yaml:"client_ca_file"` // PEM CA bundle client certificates are verified against; empty asks for none
+	ClientAuth   string `yaml:"client_auth"`    // require (default) or optional: whether clients without a certificate are refused
+}
+
+// ProxyConfig configures the TSL proxy, which re-serves the TSLs fetched by
+// the pipeline, with their original signatures, so that internal consumers
+// need not fetch them from the internet.
+type ProxyConfig struct {
+	Enabled         bool          `yaml:"enabled"`          // Serve the fetched TSLs under /proxy
+	AllowUnverified bool          `yaml:"allow_unverified"` // Also serve TSLs whose signature the load step did not verify
+	MaxAge          time.Duration `yaml:"max_age"`          // Longest time clients may cache a TSL; 0 uses the default of 1h
 }
 
 // LoggingConfig contains logging configuration settings.
@@ -35,6 +99,44 @@ type LoggingConfig struct {
 	Level  string `yaml:"level"`
 	Format string `yaml:"format"`
 	Output string `yaml:"output"`
+
+	AccessLog       string `yaml:"access_log"`        // HTTP access log output: stdout, stderr or file path; empty disables it
+	AccessLogFormat string `yaml:"access_log_format"` // Access log format: json or common
+
+	Audit AuditConfig `yaml:"audit"` // SIEM export of trust decisions and admin requests
+
+	Analytics AnalyticsConfig `yaml:"analytics"` // Rollups of decision counts for offline analysis
+}
+
+// AnalyticsConfig configures the rollup of trust decisions into counts by
+// hour, action, resource type, tenant, territory, decision and reason,
+// written to CSV or Parquet files. An empty directory disables it.
+type AnalyticsConfig struct {
+	Dir      string        `yaml:"dir"`      // Directory the files are written to (optional)
+	Format   string        `yaml:"format"`   // File format: csv (default) or parquet
+	Interval time.Duration `yaml:"interval"` // How often a file is written; 0 uses the default of 24h
+}
+
+// AuditConfig configures the export of audit events, one per trust decision
+// and admin request, in CEF or ECS JSON to a file or syslog collector. An
+// empty target disables it.
+type AuditConfig struct {
+	Target    string            `yaml:"target"`     // stdout, stderr, a file path, or udp://, tcp:// or tls://host:port of a syslog collector (optional)
+	Format    string            `yaml:"format"`     // Record format: ecs (default) or cef
+	Fields    map[string]string `yaml:"fields"`     // Keys of event fields replacing the defaults of the format; an empty key leaves a field out
+	Facility  string            `yaml:"facility"`   // Syslog facility of network targets; empty uses local0
+	TLS       AuditTLSConfig    `yaml:"tls"`        // Client TLS settings of a tls:// target
+	QueueSize int               `yaml:"queue_size"` // Events waiting to be written; 0 uses the default of 10000
+	OnFull    string            `yaml:"on_full"`    // When the queue is full: drop (default) or block
+
+	IncludeRequests bool `yaml:"include_requests"` // Add the AuthZEN request of each decision, to replay them with gt replay
+}
+
+// AuditTLSConfig contains the client TLS settings for a tls:// audit target.
+type AuditTLSConfig struct {
+	CAFile   string `yaml:"ca_file"`   // PEM CA bundle trusted for the collector; empty uses the system roots
+	CertFile string `yaml:"cert_file"` // PEM client certificate (optional)
+	KeyFile  string `yaml:"key_file"`  // PEM key of the client certificate
 }
 
 // PipelineConfig contains pipeline processing configuration settings.
@@ -43,27 +145,148 @@ type PipelineConfig struct {
 	MaxRequestSize int64         `yaml:"max_request_size"`
 	MaxRedirects   int           `yaml:"max_redirects"`
 	AllowedHosts   []string      `yaml:"allowed_hosts"`
+
+	MaxDownloadSize   int64 `yaml:"max_download_size"`   // Largest TSL document downloaded, in bytes; 0 uses the default of 100MB
+	DownloadRateLimit int64 `yaml:"download_rate_limit"` // Combined download rate of all pipelines, in bytes per second; 0 is unlimited
+	MaxReferencedTSLs int   `yaml:"max_referenced_tsls"` // Most TSLs a load step fetches by following references; 0 uses the default of 200
+
+	ClockCheckThreshold time.Duration `yaml:"clock_check_threshold"` // Warn if TSL servers' Date headers deviate from the system clock by more; 0 disables
+
+	MaxPoolSize int `yaml:"max_pool_size"` // Most distinct certificates a select step adds to a certificate pool; 0 is unlimited
 }
 
 // SecurityConfig contains security-related configuration settings.
 type SecurityConfig struct {
-	RateLimitRPS   int      `yaml:"rate_limit_rps"`
-	EnableCORS     bool     `yaml:"enable_cors"`
-	AllowedOrigins []string `yaml:"allowed_origins"`
+	RateLimitRPS   int             `yaml:"rate_limit_rps"`
+	EnableCORS     bool            `yaml:"enable_cors"`
+	AllowedOrigins []string        `yaml:"allowed_origins"`
+	Tenants        []TenantConfig  `yaml:"tenants"`     // Allow-list of AuthZEN tenants; empty disables tenant checks
+	AdminToken     string          `yaml:"admin_token"` // Bearer token for admin endpoints; empty disables them
+	Overrides      OverridesConfig `yaml:"overrides"`   // Fingerprint deny- and allow-lists applied to trust decisions
+	ClockSkew      time.Duration   `yaml:"clock_skew"`  // Allowance for clock differences in certificate validity and TSL date checks
+
+	DenyWebhook DenyWebhookConfig `yaml:"deny_webhook"` // Endpoint notified of denied trust decisions
+
+	StagedPolicy *StagedPolicyConfig `yaml:"staged_policy"` // Policy requests are also evaluated against, without effect (optional)
+
+	// Actions whose AuthZEN requests must be bound to the TLS client
+	// certificate of the caller, by action name or "*" for any other
+	// action: leaf requires the leaf certificate of resource.key to be the
+	// client certificate, chain one issued by the same CA. Requires
+	// server.tls.client_ca_file
+	ClientBinding map[string]string `yaml:"client_binding"`
+
+	// Rules requiring the subject.id of AuthZEN requests to match a subject
+	// alternative name of the leaf certificate of resource.key; the first
+	// rule applying to the action of a request is checked (optional)
+	NameMatching []NameMatchConfig `yaml:"name_matching"`
+}
+
+// NameMatchConfig is a rule matching the subject.id of AuthZEN requests
+// against the DNS, URI and email subject alternative names of the leaf
+// certificate they present. Requests whose subject.id matches none are
+// denied with the name_mismatch reason.
+type NameMatchConfig struct {
+	Actions []string `yaml:"actions"` // Actions the rule applies to; empty applies to all
+	Types   []string `yaml:"types"`   // Name types compared: dns, uri, email; empty compares all
+	Match   string   `yaml:"match"`   // exact (default), wildcard or suffix; wildcard and suffix apply to DNS names
+}
+
+// StagedPolicyConfig configures a tenant allow-list and decision overrides
+// staged to replace the active ones. Requests are evaluated against both,
+// and decisions that differ are logged and counted, but only the active
+// decision is returned.
+type StagedPolicyConfig struct {
+	Tenants   []TenantConfig   `yaml:"tenants"`   // Staged tenant allow-list; empty keeps the active one. Pipelines are ignored
+	Overrides *OverridesConfig `yaml:"overrides"` // Staged decision overrides; omitted keeps the active ones
+}
+
+// DenyWebhookConfig configures an HTTP endpoint to which denied trust
+// decisions are POSTed as JSON, in batches and at a limited rate, so that
+// failed onboarding attempts can be investigated as they happen. An empty URL
+// disables it.
+type DenyWebhookConfig struct {
+	URL           string        `yaml:"url"`            // Endpoint the denials are POSTed to (optional)
+	Actions       []string      `yaml:"actions"`        // Actions whose denials are sent, e.g. wallet-provider; empty sends all
+	Token         string        `yaml:"token"`          // Bearer token sent with each batch (optional)
+	BatchSize     int           `yaml:"batch_size"`     // Most denials in one POST; 0 uses the default of 50
+	FlushInterval time.Duration `yaml:"flush_interval"` // Longest time a denial waits for a batch to fill; 0 uses the default of 5s
+	MaxPerMinute  int           `yaml:"max_per_minute"` // Most POSTs per minute; 0 uses the default of 60
+	MaxPending    int           `yaml:"max_pending"`    // Most denials waiting to be sent before further ones are dropped; 0 uses the default of 1000
+}
+
+// OverridesConfig lists certificates, by SHA-256 fingerprint, whose trust
+// decisions are forced regardless of the trust registries. Chains through a
+// certificate on the deny-list are never trusted; leaf certificates on the
+// allow-list are trusted even if no registry trusts them. The lists of File
+// are added to Deny and Allow, and reloaded when the file changes.
+type OverridesConfig struct {
+	File           string        `yaml:"file"`            // YAML file with deny and allow lists (optional)
+	Deny           []string      `yaml:"deny"`            // Fingerprints of distrusted certificates
+	Allow          []string      `yaml:"allow"`           // Fingerprints of trusted leaf certificates
+	ReloadInterval time.Duration `yaml:"reload_interval"` // How often File is checked for changes; 0 uses the default of 30s
+}
+
+// TenantConfig describes a relying party allowed to call the AuthZEN evaluation
+// endpoint. Requests identify the tenant through the X-Tenant header or the
+// "tenant" key of the request context, and the purpose of use through the
+// "purpose" key. A tenant with its own pipeline is evaluated against the
+// certificate pool built by that pipeline instead of the default one. A tenant
+// with territories only trusts anchors listed in TSLs of those territories.
+type TenantConfig struct {
+	ID             string   `yaml:"id"`              // Value of X-Tenant or context.tenant
+	Purposes       []string `yaml:"purposes"`        // Allowed values of context.purpose; empty allows any
+	AllowedActions []string `yaml:"allowed_actions"` // Allowed action names; empty allows any
+	Pipeline       string   `yaml:"pipeline"`        // Pipeline YAML file or directory for the tenant's trust anchors (optional)
+	Territories    []string `yaml:"territories"`     // Allowed trust anchor scheme territories; empty allows any
+}
+
+// RegistriesConfig configures trust registries consulted in addition to the
+// certificate pool built by the pipeline.
+type RegistriesConfig struct {
+	OIDFed *OIDFedConfig `yaml:"oidfed"` // OpenID Federation registry for resource.type "entity" (optional)
+}
+
+// OIDFedConfig configures an OpenID Federation trust registry. Entities are
+// trusted if a trust chain to one of the trust anchors can be resolved.
+type OIDFedConfig struct {
+	TrustAnchors       []string `yaml:"trust_anchors"`        // Entity IDs (URLs) of the trust anchors
+	RequiredTrustMarks []string `yaml:"required_trust_marks"` // Trust mark types entities must hold (optional)
+	EntityTypes        []string `yaml:"entity_types"`         // Accepted entity types, e.g. openid_provider (optional)
+
+	CacheTTL         time.Duration `yaml:"cache_ttl"`          // Longest time resolved trust chains are reused; 0 uses the default of 1h
+	NegativeCacheTTL time.Duration `yaml:"negative_cache_ttl"` // How long entities without a trust chain are remembered; 0 uses the default of 1m
+
+	MetadataConstraints []MetadataConstraintConfig `yaml:"metadata_constraints"` // Requirements on the metadata of evaluated entities (optional)
+}
+
+// MetadataConstraintConfig is a requirement on one metadata claim of entities
+// evaluated by the OpenID Federation registry, checked after their trust
+// chain is resolved. Entities that fail it are denied.
+type MetadataConstraintConfig struct {
+	EntityType string   `yaml:"entity_type"` // Metadata section, e.g. openid_relying_party
+	Claim      string   `yaml:"claim"`       // Claim in the section, e.g. redirect_uris
+	Required   bool     `yaml:"required"`    // The claim must be present
+	OneOf      []string `yaml:"one_of"`      // Every value of the claim must be one of these
+	Includes   []string `yaml:"includes"`    // The claim must include all of these values
+	URISchemes []string `yaml:"uri_schemes"` // Every value of the claim must be a URI with one of these schemes
 }
 
 // DefaultConfig returns a Config with sensible default values.
 func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host:      "127.0.0.1",
-			Port:      "6001",
-			Frequency: 5 * time.Minute,
+			Host:            "127.0.0.1",
+			Port:            "6001",
+			Frequency:       5 * time.Minute,
+			DecisionTimeout: 10 * time.Second,
+			WarmUpSamples:   8,
 		},
 		Logging: LoggingConfig{
-			Level:  "info",
-			Format: "text",
-			Output: "stdout",
+			Level:           "info",
+			Format:          "text",
+			Output:          "stdout",
+			AccessLogFormat: "json",
 		},
 		Pipeline: PipelineConfig{
 			Timeout:        30 * time.Second,
@@ -83,36 +306,43 @@ func DefaultConfig() *Config {
 // It returns the merged configuration or an error if loading fails.
 //
 // Environment variables override configuration file values using the GT_ prefix:
-//   - GT_HOST, GT_PORT, GT_FREQUENCY for server settings
-//   - GT_LOG_LEVEL, GT_LOG_FORMAT, GT_LOG_OUTPUT for logging
-//   - GT_RATE_LIMIT_RPS for security settings
+//   - GT_HOST, GT_PORT, GT_FREQUENCY, GT_FREQUENCY_JITTER, GT_PUBLISH_DIR, GT_TRUSTED_PROXIES, GT_MAX_CONCURRENT_EVALUATIONS, GT_WARM_UP_SAMPLES, GT_USER_MESSAGES, GT_HEALTH_ADDR, GT_PROXY, GT_TLS_CERT_FILE, GT_TLS_KEY_FILE, GT_TLS_CLIENT_CA_FILE for server settings
+//   - GT_LOG_LEVEL, GT_LOG_FORMAT, GT_LOG_OUTPUT, GT_ACCESS_LOG, GT_ACCESS_LOG_FORMAT, GT_AUDIT_TARGET, GT_AUDIT_FORMAT, GT_ANALYTICS_DIR, GT_ANALYTICS_FORMAT for logging
+//   - GT_RATE_LIMIT_RPS, GT_ADMIN_TOKEN, GT_DENY_WEBHOOK_URL, GT_DENY_WEBHOOK_ACTIONS, GT_DENY_WEBHOOK_TOKEN for security settings
+//   - GT_PROFILE to activate a profile (see LoadConfigProfile)
 //
 // If configPath is empty, only default values and environment variables are used.
 func LoadConfig(configPath string) (*Config, error) {
-	// Start with defaults
-	cfg := DefaultConfig()
-
-	// Load from file if path provided
-	if configPath != "" {
-		// Validate config path before loading
-		if err := validation.ValidateConfigPath(configPath); err != nil {
-			return nil, fmt.Errorf("invalid config path: %w", err)
-		}
+	return LoadConfigProfile(configPath, "")
+}
 
-		data, err := os.ReadFile(configPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read config file: %w", err)
-		}
+// loadConfigFile reads the configuration file at configPath over cfg. Keys
+// that are not configuration fields, here or in a profile section, are
+// errors. It does nothing if configPath is empty.
+func loadConfigFile(configPath string, cfg *Config) error {
+	if configPath == "" {
+		return nil
+	}
 
-		if err := yaml.Unmarshal(data, cfg); err != nil {
-			return nil, fmt.Errorf("failed to parse config file: %w", err)
-		}
+	// Validate config path before loading
+	if err := validation.ValidateConfigPath(configPath); err != nil {
+		return fmt.Errorf("invalid config path: %w", err)
 	}
 
-	// Apply environment variable overrides
-	applyEnvOverrides(cfg)
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
 
-	return cfg, nil
+	if err := yamlutil.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+	for name, node := range cfg.Profiles {
+		if err := yamlutil.CheckFields(&node, cfg); err != nil {
+			return fmt.Errorf("failed to parse profile %s of config file %s: %w", name, configPath, err)
+		}
+	}
+	return nil
 }
 
 // applyEnvOverrides applies environment variable overrides to the configuration.
@@ -130,6 +360,68 @@ func applyEnvOverrides(cfg *Config) {
 			cfg.Server.Frequency = d
 		}
 	}
+	if v := os.Getenv("GT_FREQUENCY_JITTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Server.FrequencyJitter = d
+		}
+	}
+	if v := os.Getenv("GT_EXTERNAL_URL"); v != "" {
+		cfg.Server.ExternalURL = v
+	} else if v := os.Getenv("GO_TRUST_EXTERNAL_URL"); v != "" {
+		// Name used by the former standalone server binary
+		cfg.Server.ExternalURL = v
+	}
+	if v := os.Getenv("GT_PUBLISH_DIR"); v != "" {
+		cfg.Server.PublishDir = v
+	}
+	if v := os.Getenv("GT_TRUSTED_PROXIES"); v != "" {
+		cfg.Server.TrustedProxies = strings.Split(v, ",")
+	}
+	if v := os.Getenv("GT_SWAGGER"); v != "" {
+		cfg.Server.Swagger = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("GT_DECISION_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Server.DecisionTimeout = d
+		}
+	}
+	if v := os.Getenv("GT_MAX_CONCURRENT_EVALUATIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Server.MaxConcurrentEvaluations = n
+		}
+	}
+	if v := os.Getenv("GT_SERVER_TIMING"); v != "" {
+		cfg.Server.ServerTiming = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("GT_LANGUAGES"); v != "" {
+		cfg.Server.Languages = strings.Split(v, ",")
+	}
+	if v := os.Getenv("GT_PROVIDER_PAGES_URL"); v != "" {
+		cfg.Server.ProviderPagesURL = v
+	}
+	if v := os.Getenv("GT_WARM_UP_SAMPLES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Server.WarmUpSamples = n
+		}
+	}
+	if v := os.Getenv("GT_USER_MESSAGES"); v != "" {
+		cfg.Server.UserMessages = v
+	}
+	if v := os.Getenv("GT_HEALTH_ADDR"); v != "" {
+		cfg.Server.HealthAddr = v
+	}
+	if v := os.Getenv("GT_PROXY"); v != "" {
+		cfg.Server.Proxy.Enabled = strings.ToLower(v) == "true" || v == "1"
+	}
+	if v := os.Getenv("GT_TLS_CERT_FILE"); v != "" {
+		cfg.Server.TLS.CertFile = v
+	}
+	if v := os.Getenv("GT_TLS_KEY_FILE"); v != "" {
+		cfg.Server.TLS.KeyFile = v
+	}
+	if v := os.Getenv("GT_TLS_CLIENT_CA_FILE"); v != "" {
+		cfg.Server.TLS.ClientCAFile = v
+	}
 
 	// Logging configuration
 	if v := os.Getenv("GT_LOG_LEVEL"); v != "" {
@@ -141,6 +433,24 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("GT_LOG_OUTPUT"); v != "" {
 		cfg.Logging.Output = v
 	}
+	if v := os.Getenv("GT_ACCESS_LOG"); v != "" {
+		cfg.Logging.AccessLog = v
+	}
+	if v := os.Getenv("GT_ACCESS_LOG_FORMAT"); v != "" {
+		cfg.Logging.AccessLogFormat = v
+	}
+	if v := os.Getenv("GT_AUDIT_TARGET"); v != "" {
+		cfg.Logging.Audit.Target = v
+	}
+	if v := os.Getenv("GT_AUDIT_FORMAT"); v != "" {
+		cfg.Logging.Audit.Format = v
+	}
+	if v := os.Getenv("GT_ANALYTICS_DIR"); v != "" {
+		cfg.Logging.Analytics.Dir = v
+	}
+	if v := os.Getenv("GT_ANALYTICS_FORMAT"); v != "" {
+		cfg.Logging.Analytics.Format = v
+	}
 
 	// Pipeline configuration
 	if v := os.Getenv("GT_PIPELINE_TIMEOUT"); v != "" {
@@ -161,6 +471,31 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("GT_ALLOWED_HOSTS"); v != "" {
 		cfg.Pipeline.AllowedHosts = strings.Split(v, ",")
 	}
+	if v := os.Getenv("GT_MAX_DOWNLOAD_SIZE"); v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+			cfg.Pipeline.MaxDownloadSize = size
+		}
+	}
+	if v := os.Getenv("GT_DOWNLOAD_RATE_LIMIT"); v != "" {
+		if limit, err := strconv.ParseInt(v, 10, 64); err == nil {
+			cfg.Pipeline.DownloadRateLimit = limit
+		}
+	}
+	if v := os.Getenv("GT_MAX_REFERENCED_TSLS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Pipeline.MaxReferencedTSLs = n
+		}
+	}
+	if v := os.Getenv("GT_CLOCK_CHECK_THRESHOLD"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Pipeline.ClockCheckThreshold = d
+		}
+	}
+	if v := os.Getenv("GT_MAX_POOL_SIZE"); v != "" {
+		if size, err := strconv.Atoi(v); err == nil {
+			cfg.Pipeline.MaxPoolSize = size
+		}
+	}
 
 	// Security configuration
 	if v := os.Getenv("GT_RATE_LIMIT_RPS"); v != "" {
@@ -174,6 +509,23 @@ func applyEnvOverrides(cfg *Config) {
 	if v := os.Getenv("GT_ALLOWED_ORIGINS"); v != "" {
 		cfg.Security.AllowedOrigins = strings.Split(v, ",")
 	}
+	if v := os.Getenv("GT_ADMIN_TOKEN"); v != "" {
+		cfg.Security.AdminToken = v
+	}
+	if v := os.Getenv("GT_CLOCK_SKEW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Security.ClockSkew = d
+		}
+	}
+	if v := os.Getenv("GT_DENY_WEBHOOK_URL"); v != "" {
+		cfg.Security.DenyWebhook.URL = v
+	}
+	if v := os.Getenv("GT_DENY_WEBHOOK_ACTIONS"); v != "" {
+		cfg.Security.DenyWebhook.Actions = strings.Split(v, ",")
+	}
+	if v := os.Getenv("GT_DENY_WEBHOOK_TOKEN"); v != "" {
+		cfg.Security.DenyWebhook.Token = v
+	}
 }
 
 // Validate checks if the configuration is valid.
@@ -186,6 +538,57 @@ func (c *Config) Validate() error {
 	if c.Server.Frequency <= 0 {
 		return fmt.Errorf("server frequency must be positive")
 	}
+	if c.Server.FrequencyJitter < 0 {
+		return fmt.Errorf("server frequency jitter cannot be negative")
+	}
+	if c.Server.DecisionTimeout < 0 {
+		return fmt.Errorf("decision timeout cannot be negative")
+	}
+	if c.Server.MaxConcurrentEvaluations < 0 {
+		return fmt.Errorf("max concurrent evaluations cannot be negative")
+	}
+	if c.Server.WarmUpSamples < 0 {
+		return fmt.Errorf("warm-up samples cannot be negative")
+	}
+	if c.Server.ExternalURL != "" {
+		u, err := url.Parse(c.Server.ExternalURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid external URL %q: must be an absolute http or https URL", c.Server.ExternalURL)
+		}
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid trusted proxy %q: must be an IP address or CIDR", proxy)
+		}
+	}
+	if err := i18n.ValidateLanguages(c.Server.Languages); err != nil {
+		return fmt.Errorf("invalid languages: %w", err)
+	}
+	if c.Server.ProviderPagesURL != "" {
+		u, err := url.Parse(c.Server.ProviderPagesURL)
+		absolute := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+		local := err == nil && u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/")
+		if !absolute && !local {
+			return fmt.Errorf("invalid provider pages URL %q: must be an absolute http or https URL or a path starting with /", c.Server.ProviderPagesURL)
+		}
+	}
+	if c.Server.HealthAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Server.HealthAddr); err != nil {
+			return fmt.Errorf("invalid health listener address %q: %w", c.Server.HealthAddr, err)
+		}
+	}
+	if c.Server.Proxy.MaxAge < 0 {
+		return fmt.Errorf("proxy max age cannot be negative")
+	}
+	if tls := c.Server.TLS; tls.CertFile != "" || tls.KeyFile != "" || tls.ClientCAFile != "" {
+		if tls.CertFile == "" || tls.KeyFile == "" {
+			return fmt.Errorf("server TLS requires both cert_file and key_file")
+		}
+		validClientAuth := map[string]bool{"": true, "require": true, "optional": true}
+		if !validClientAuth[strings.ToLower(tls.ClientAuth)] {
+			return fmt.Errorf("invalid server TLS client_auth: %s", tls.ClientAuth)
+		}
+	}
 
 	// Validate logging configuration
 	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "fatal": true}
@@ -197,6 +600,38 @@ func (c *Config) Validate() error {
 	if !validFormats[strings.ToLower(c.Logging.Format)] {
 		return fmt.Errorf("invalid log format: %s", c.Logging.Format)
 	}
+	validAccessLogFormats := map[string]bool{"": true, "json": true, "common": true}
+	if !validAccessLogFormats[strings.ToLower(c.Logging.AccessLogFormat)] {
+		return fmt.Errorf("invalid access log format: %s", c.Logging.AccessLogFormat)
+	}
+	if audit := c.Logging.Audit; audit.Target != "" {
+		validAuditFormats := map[string]bool{"": true, "ecs": true, "cef": true}
+		if !validAuditFormats[strings.ToLower(audit.Format)] {
+			return fmt.Errorf("invalid audit format: %s", audit.Format)
+		}
+		validOnFull := map[string]bool{"": true, "drop": true, "block": true}
+		if !validOnFull[strings.ToLower(audit.OnFull)] {
+			return fmt.Errorf("invalid audit on_full: %s", audit.OnFull)
+		}
+		if audit.QueueSize < 0 {
+			return fmt.Errorf("audit queue size cannot be negative")
+		}
+		if strings.Contains(audit.Target, "://") {
+			u, err := url.Parse(audit.Target)
+			if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp" && u.Scheme != "tls") || u.Port() == "" {
+				return fmt.Errorf("invalid audit target %q: must be udp://, tcp:// or tls://host:port", audit.Target)
+			}
+		}
+	}
+	if analytics := c.Logging.Analytics; analytics.Dir != "" {
+		validAnalyticsFormats := map[string]bool{"": true, "csv": true, "parquet": true}
+		if !validAnalyticsFormats[strings.ToLower(analytics.Format)] {
+			return fmt.Errorf("invalid analytics format: %s", analytics.Format)
+		}
+		if analytics.Interval < 0 {
+			return fmt.Errorf("analytics interval cannot be negative")
+		}
+	}
 
 	// Validate pipeline configuration
 	if c.Pipeline.Timeout <= 0 {
@@ -208,11 +643,120 @@ func (c *Config) Validate() error {
 	if c.Pipeline.MaxRedirects < 0 {
 		return fmt.Errorf("max redirects cannot be negative")
 	}
+	if c.Pipeline.MaxDownloadSize < 0 {
+		return fmt.Errorf("max download size cannot be negative")
+	}
+	if c.Pipeline.DownloadRateLimit < 0 {
+		return fmt.Errorf("download rate limit cannot be negative")
+	}
+	if c.Pipeline.MaxReferencedTSLs < 0 {
+		return fmt.Errorf("max referenced TSLs cannot be negative")
+	}
+	if c.Pipeline.ClockCheckThreshold < 0 {
+		return fmt.Errorf("clock check threshold cannot be negative")
+	}
+	if c.Pipeline.MaxPoolSize < 0 {
+		return fmt.Errorf("max pool size cannot be negative")
+	}
 
 	// Validate security configuration
 	if c.Security.RateLimitRPS <= 0 {
 		return fmt.Errorf("rate limit RPS must be positive")
 	}
+	if c.Security.ClockSkew < 0 {
+		return fmt.Errorf("clock skew cannot be negative")
+	}
+	if err := validateTenants(c.Security.Tenants); err != nil {
+		return err
+	}
 
+	if c.Security.Overrides.ReloadInterval < 0 {
+		return fmt.Errorf("overrides reload interval cannot be negative")
+	}
+	if staged := c.Security.StagedPolicy; staged != nil {
+		if err := validateTenants(staged.Tenants); err != nil {
+			return fmt.Errorf("staged policy: %w", err)
+		}
+		if staged.Overrides != nil && staged.Overrides.ReloadInterval < 0 {
+			return fmt.Errorf("staged policy: overrides reload interval cannot be negative")
+		}
+	}
+	if len(c.Security.ClientBinding) > 0 && c.Server.TLS.ClientCAFile == "" {
+		return fmt.Errorf("client binding requires server.tls.client_ca_file")
+	}
+	for action, mode := range c.Security.ClientBinding {
+		if mode != "leaf" && mode != "chain" {
+			return fmt.Errorf("invalid client binding %q of action %q: must be leaf or chain", mode, action)
+		}
+	}
+	for i, rule := range c.Security.NameMatching {
+		validMatches := map[string]bool{"": true, "exact": true, "wildcard": true, "suffix": true}
+		if !validMatches[rule.Match] {
+			return fmt.Errorf("name matching rule %d: invalid match %q: must be exact, wildcard or suffix", i+1, rule.Match)
+		}
+		for _, typ := range rule.Types {
+			if typ != "dns" && typ != "uri" && typ != "email" {
+				return fmt.Errorf("name matching rule %d: invalid name type %q: must be dns, uri or email", i+1, typ)
+			}
+		}
+	}
+	if hook := c.Security.DenyWebhook; hook.URL != "" {
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid deny webhook URL %q: must be an absolute http or https URL", hook.URL)
+		}
+		if hook.BatchSize < 0 || hook.FlushInterval < 0 || hook.MaxPerMinute < 0 || hook.MaxPending < 0 {
+			return fmt.Errorf("deny webhook limits cannot be negative")
+		}
+	}
+
+	// Validate registry configuration
+	if oidfed := c.Registries.OIDFed; oidfed != nil {
+		if len(oidfed.TrustAnchors) == 0 {
+			return fmt.Errorf("oidfed registry: at least one trust anchor must be configured")
+		}
+		for _, anchor := range oidfed.TrustAnchors {
+			u, err := url.Parse(anchor)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("oidfed registry: invalid trust anchor %q: must be an absolute http or https URL", anchor)
+			}
+		}
+		if oidfed.CacheTTL < 0 || oidfed.NegativeCacheTTL < 0 {
+			return fmt.Errorf("oidfed registry: cache TTLs cannot be negative")
+		}
+		for i, c := range oidfed.MetadataConstraints {
+			if c.EntityType == "" || c.Claim == "" {
+				return fmt.Errorf("oidfed registry: metadata constraint %d: entity_type and claim are required", i)
+			}
+			if !c.Required && len(c.OneOf) == 0 && len(c.Includes) == 0 && len(c.URISchemes) == 0 {
+				return fmt.Errorf("oidfed registry: metadata constraint %d: no check configured for %s.%s", i, c.EntityType, c.Claim)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateTenants checks that tenants have unique, non-empty IDs and known
+// territory codes.
+func validateTenants(tenants []TenantConfig) error {
+	seen := make(map[string]bool)
+	for i, tenant := range tenants {
+		if tenant.ID == "" {
+			return fmt.Errorf("tenant %d: id cannot be empty", i)
+		}
+		if seen[tenant.ID] {
+			return fmt.Errorf("duplicate tenant id: %s", tenant.ID)
+		}
+		seen[tenant.ID] = true
+		for _, territory := range tenant.Territories {
+			if strings.TrimSpace(territory) == "" {
+				return fmt.Errorf("tenant %s: territory cannot be empty", tenant.ID)
+			}
+			if _, err := validation.NormalizeTerritory(territory); err != nil {
+				return fmt.Errorf("tenant %s: %w", tenant.ID, err)
+			}
+		}
+	}
 	return nil
 }
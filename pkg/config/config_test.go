@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -20,6 +21,12 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.Server.Frequency != 5*time.Minute {
 		t.Errorf("Default frequency = %v, want %v", cfg.Server.Frequency, 5*time.Minute)
 	}
+	if cfg.Server.DecisionTimeout != 10*time.Second {
+		t.Errorf("Default decision timeout = %v, want %v", cfg.Server.DecisionTimeout, 10*time.Second)
+	}
+	if cfg.Server.WarmUpSamples != 8 {
+		t.Errorf("Default warm-up samples = %v, want %v", cfg.Server.WarmUpSamples, 8)
+	}
 
 	// Test logging defaults
 	if cfg.Logging.Level != "info" {
@@ -82,6 +89,12 @@ security:
   allowed_origins:
     - "https://example.com"
     - "https://test.com"
+  tenants:
+    - id: "bank-a"
+      purposes: ["account-opening"]
+      allowed_actions: ["http://ec.europa.eu/NS/wallet-provider"]
+      pipeline: "/etc/go-trust/bank-a.yaml"
+      territories: ["SE"]
 `
 
 	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
@@ -139,6 +152,19 @@ security:
 	if len(cfg.Security.AllowedOrigins) != 2 {
 		t.Errorf("Allowed origins count = %v, want %v", len(cfg.Security.AllowedOrigins), 2)
 	}
+	if len(cfg.Security.Tenants) != 1 {
+		t.Fatalf("Tenants count = %v, want %v", len(cfg.Security.Tenants), 1)
+	}
+	tenant := cfg.Security.Tenants[0]
+	if tenant.ID != "bank-a" || len(tenant.Purposes) != 1 || len(tenant.AllowedActions) != 1 {
+		t.Errorf("Tenant = %+v, want bank-a with one purpose and one action", tenant)
+	}
+	if tenant.Pipeline != "/etc/go-trust/bank-a.yaml" {
+		t.Errorf("Tenant pipeline = %v, want %v", tenant.Pipeline, "/etc/go-trust/bank-a.yaml")
+	}
+	if len(tenant.Territories) != 1 || tenant.Territories[0] != "SE" {
+		t.Errorf("Tenant territories = %v, want %v", tenant.Territories, []string{"SE"})
+	}
 }
 
 func TestLoadConfigWithEnvOverrides(t *testing.T) {
@@ -146,22 +172,82 @@ func TestLoadConfigWithEnvOverrides(t *testing.T) {
 	os.Setenv("GT_HOST", "192.168.1.1")
 	os.Setenv("GT_PORT", "9000")
 	os.Setenv("GT_FREQUENCY", "15m")
+	os.Setenv("GT_FREQUENCY_JITTER", "30s")
+	os.Setenv("GT_PUBLISH_DIR", "/var/lib/go-trust/published")
+	os.Setenv("GT_TRUSTED_PROXIES", "10.0.0.0/8,192.168.1.10")
+	os.Setenv("GT_SWAGGER", "1")
+	os.Setenv("GT_DECISION_TIMEOUT", "3s")
+	os.Setenv("GT_SERVER_TIMING", "true")
+	os.Setenv("GT_MAX_CONCURRENT_EVALUATIONS", "200")
+	os.Setenv("GT_WARM_UP_SAMPLES", "0")
+	os.Setenv("GT_LANGUAGES", "sv,en")
+	os.Setenv("GT_PROVIDER_PAGES_URL", "/published")
+	os.Setenv("GT_USER_MESSAGES", "/etc/go-trust/messages.yaml")
+	os.Setenv("GT_HEALTH_ADDR", "127.0.0.1:6002")
+	os.Setenv("GT_PROXY", "true")
+	os.Setenv("GT_TLS_CERT_FILE", "/etc/go-trust/tls.pem")
+	os.Setenv("GT_TLS_KEY_FILE", "/etc/go-trust/tls.key")
+	os.Setenv("GT_TLS_CLIENT_CA_FILE", "/etc/go-trust/clients.pem")
 	os.Setenv("GT_LOG_LEVEL", "warn")
 	os.Setenv("GT_LOG_FORMAT", "json")
 	os.Setenv("GT_LOG_OUTPUT", "stderr")
+	os.Setenv("GT_ACCESS_LOG", "/var/log/go-trust/access.log")
+	os.Setenv("GT_ACCESS_LOG_FORMAT", "common")
+	os.Setenv("GT_AUDIT_TARGET", "tls://siem.example.com:6514")
+	os.Setenv("GT_AUDIT_FORMAT", "cef")
+	os.Setenv("GT_ANALYTICS_DIR", "/var/lib/go-trust/analytics")
+	os.Setenv("GT_ANALYTICS_FORMAT", "parquet")
 	os.Setenv("GT_RATE_LIMIT_RPS", "500")
 	os.Setenv("GT_ENABLE_CORS", "true")
+	os.Setenv("GT_ADMIN_TOKEN", "s3cret")
+	os.Setenv("GT_CLOCK_SKEW", "2m")
+	os.Setenv("GT_CLOCK_CHECK_THRESHOLD", "30s")
+	os.Setenv("GT_MAX_POOL_SIZE", "5000")
+	os.Setenv("GT_MAX_REFERENCED_TSLS", "100")
+	os.Setenv("GT_DENY_WEBHOOK_URL", "https://siem.example.com/hooks/deny")
+	os.Setenv("GT_DENY_WEBHOOK_ACTIONS", "wallet-provider,credential-issuer")
+	os.Setenv("GT_DENY_WEBHOOK_TOKEN", "hook-token")
 
 	defer func() {
 		// Clean up environment variables
 		os.Unsetenv("GT_HOST")
 		os.Unsetenv("GT_PORT")
 		os.Unsetenv("GT_FREQUENCY")
+		os.Unsetenv("GT_FREQUENCY_JITTER")
+		os.Unsetenv("GT_PUBLISH_DIR")
+		os.Unsetenv("GT_TRUSTED_PROXIES")
+		os.Unsetenv("GT_SWAGGER")
+		os.Unsetenv("GT_DECISION_TIMEOUT")
+		os.Unsetenv("GT_SERVER_TIMING")
+		os.Unsetenv("GT_MAX_CONCURRENT_EVALUATIONS")
+		os.Unsetenv("GT_WARM_UP_SAMPLES")
+		os.Unsetenv("GT_LANGUAGES")
+		os.Unsetenv("GT_PROVIDER_PAGES_URL")
+		os.Unsetenv("GT_USER_MESSAGES")
+		os.Unsetenv("GT_HEALTH_ADDR")
+		os.Unsetenv("GT_PROXY")
+		os.Unsetenv("GT_TLS_CERT_FILE")
+		os.Unsetenv("GT_TLS_KEY_FILE")
+		os.Unsetenv("GT_TLS_CLIENT_CA_FILE")
 		os.Unsetenv("GT_LOG_LEVEL")
 		os.Unsetenv("GT_LOG_FORMAT")
 		os.Unsetenv("GT_LOG_OUTPUT")
+		os.Unsetenv("GT_ACCESS_LOG")
+		os.Unsetenv("GT_ACCESS_LOG_FORMAT")
+		os.Unsetenv("GT_AUDIT_TARGET")
+		os.Unsetenv("GT_AUDIT_FORMAT")
+		os.Unsetenv("GT_ANALYTICS_DIR")
+		os.Unsetenv("GT_ANALYTICS_FORMAT")
 		os.Unsetenv("GT_RATE_LIMIT_RPS")
 		os.Unsetenv("GT_ENABLE_CORS")
+		os.Unsetenv("GT_ADMIN_TOKEN")
+		os.Unsetenv("GT_CLOCK_SKEW")
+		os.Unsetenv("GT_CLOCK_CHECK_THRESHOLD")
+		os.Unsetenv("GT_MAX_POOL_SIZE")
+		os.Unsetenv("GT_MAX_REFERENCED_TSLS")
+		os.Unsetenv("GT_DENY_WEBHOOK_URL")
+		os.Unsetenv("GT_DENY_WEBHOOK_ACTIONS")
+		os.Unsetenv("GT_DENY_WEBHOOK_TOKEN")
 	}()
 
 	cfg, err := LoadConfig("")
@@ -179,6 +265,48 @@ func TestLoadConfigWithEnvOverrides(t *testing.T) {
 	if cfg.Server.Frequency != 15*time.Minute {
 		t.Errorf("Frequency = %v, want %v", cfg.Server.Frequency, 15*time.Minute)
 	}
+	if cfg.Server.FrequencyJitter != 30*time.Second {
+		t.Errorf("FrequencyJitter = %v, want %v", cfg.Server.FrequencyJitter, 30*time.Second)
+	}
+	if cfg.Server.PublishDir != "/var/lib/go-trust/published" {
+		t.Errorf("PublishDir = %v, want %v", cfg.Server.PublishDir, "/var/lib/go-trust/published")
+	}
+	if len(cfg.Server.TrustedProxies) != 2 || cfg.Server.TrustedProxies[0] != "10.0.0.0/8" || cfg.Server.TrustedProxies[1] != "192.168.1.10" {
+		t.Errorf("TrustedProxies = %v, want [10.0.0.0/8 192.168.1.10]", cfg.Server.TrustedProxies)
+	}
+	if !cfg.Server.Swagger {
+		t.Error("Swagger UI should be enabled")
+	}
+	if cfg.Server.DecisionTimeout != 3*time.Second {
+		t.Errorf("DecisionTimeout = %v, want %v", cfg.Server.DecisionTimeout, 3*time.Second)
+	}
+	if len(cfg.Server.Languages) != 2 || cfg.Server.Languages[0] != "sv" {
+		t.Errorf("Languages = %v, want [sv en]", cfg.Server.Languages)
+	}
+	if cfg.Server.ProviderPagesURL != "/published" {
+		t.Errorf("ProviderPagesURL = %v, want %v", cfg.Server.ProviderPagesURL, "/published")
+	}
+	if cfg.Server.UserMessages != "/etc/go-trust/messages.yaml" {
+		t.Errorf("UserMessages = %v, want %v", cfg.Server.UserMessages, "/etc/go-trust/messages.yaml")
+	}
+	if cfg.Server.HealthAddr != "127.0.0.1:6002" {
+		t.Errorf("HealthAddr = %v, want %v", cfg.Server.HealthAddr, "127.0.0.1:6002")
+	}
+	if !cfg.Server.Proxy.Enabled {
+		t.Error("TSL proxy should be enabled")
+	}
+	if tls := cfg.Server.TLS; tls.CertFile != "/etc/go-trust/tls.pem" || tls.KeyFile != "/etc/go-trust/tls.key" || tls.ClientCAFile != "/etc/go-trust/clients.pem" {
+		t.Errorf("TLS = %+v, want the files of GT_TLS_CERT_FILE, GT_TLS_KEY_FILE and GT_TLS_CLIENT_CA_FILE", tls)
+	}
+	if !cfg.Server.ServerTiming {
+		t.Error("Server timing should be enabled")
+	}
+	if cfg.Server.MaxConcurrentEvaluations != 200 {
+		t.Errorf("MaxConcurrentEvaluations = %v, want %v", cfg.Server.MaxConcurrentEvaluations, 200)
+	}
+	if cfg.Server.WarmUpSamples != 0 {
+		t.Errorf("WarmUpSamples = %v, want %v", cfg.Server.WarmUpSamples, 0)
+	}
 	if cfg.Logging.Level != "warn" {
 		t.Errorf("Log level = %v, want %v", cfg.Logging.Level, "warn")
 	}
@@ -188,12 +316,62 @@ func TestLoadConfigWithEnvOverrides(t *testing.T) {
 	if cfg.Logging.Output != "stderr" {
 		t.Errorf("Log output = %v, want %v", cfg.Logging.Output, "stderr")
 	}
+	if cfg.Logging.AccessLog != "/var/log/go-trust/access.log" {
+		t.Errorf("Access log = %v, want %v", cfg.Logging.AccessLog, "/var/log/go-trust/access.log")
+	}
+	if cfg.Logging.AccessLogFormat != "common" {
+		t.Errorf("Access log format = %v, want %v", cfg.Logging.AccessLogFormat, "common")
+	}
+	if audit := cfg.Logging.Audit; audit.Target != "tls://siem.example.com:6514" || audit.Format != "cef" {
+		t.Errorf("Audit = %+v, want target tls://siem.example.com:6514 and format cef", audit)
+	}
+	if analytics := cfg.Logging.Analytics; analytics.Dir != "/var/lib/go-trust/analytics" || analytics.Format != "parquet" {
+		t.Errorf("Analytics = %+v, want dir /var/lib/go-trust/analytics and format parquet", analytics)
+	}
 	if cfg.Security.RateLimitRPS != 500 {
 		t.Errorf("Rate limit RPS = %v, want %v", cfg.Security.RateLimitRPS, 500)
 	}
 	if !cfg.Security.EnableCORS {
 		t.Error("CORS should be enabled")
 	}
+	if cfg.Security.AdminToken != "s3cret" {
+		t.Errorf("AdminToken = %v, want %v", cfg.Security.AdminToken, "s3cret")
+	}
+	if cfg.Security.ClockSkew != 2*time.Minute {
+		t.Errorf("ClockSkew = %v, want %v", cfg.Security.ClockSkew, 2*time.Minute)
+	}
+	if cfg.Pipeline.ClockCheckThreshold != 30*time.Second {
+		t.Errorf("ClockCheckThreshold = %v, want %v", cfg.Pipeline.ClockCheckThreshold, 30*time.Second)
+	}
+	if cfg.Pipeline.MaxPoolSize != 5000 {
+		t.Errorf("MaxPoolSize = %v, want %v", cfg.Pipeline.MaxPoolSize, 5000)
+	}
+	if cfg.Pipeline.MaxReferencedTSLs != 100 {
+		t.Errorf("MaxReferencedTSLs = %v, want %v", cfg.Pipeline.MaxReferencedTSLs, 100)
+	}
+	if hook := cfg.Security.DenyWebhook; hook.URL != "https://siem.example.com/hooks/deny" || len(hook.Actions) != 2 || hook.Actions[0] != "wallet-provider" || hook.Token != "hook-token" {
+		t.Errorf("DenyWebhook = %+v, want URL, actions and token from the environment", hook)
+	}
+}
+
+func TestLoadConfigExternalURLEnv(t *testing.T) {
+	t.Setenv("GO_TRUST_EXTERNAL_URL", "https://legacy.example.com")
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Server.ExternalURL != "https://legacy.example.com" {
+		t.Errorf("ExternalURL = %v, want the GO_TRUST_EXTERNAL_URL value", cfg.Server.ExternalURL)
+	}
+
+	t.Setenv("GT_EXTERNAL_URL", "https://pdp.example.com")
+	cfg, err = LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Server.ExternalURL != "https://pdp.example.com" {
+		t.Errorf("ExternalURL = %v, want GT_EXTERNAL_URL to take precedence", cfg.Server.ExternalURL)
+	}
 }
 
 func TestLoadConfigInvalidFile(t *testing.T) {
@@ -217,6 +395,27 @@ func TestLoadConfigInvalidYAML(t *testing.T) {
 	}
 }
 
+func TestLoadConfigUnknownField(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	if err := os.WriteFile(configPath, []byte("server:\n  port: \"8080\"\n  frequncy: 10m\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	_, err := LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), `unknown field "frequncy" at line 3, did you mean "frequency"?`) {
+		t.Errorf("LoadConfig() error = %v, want an unknown field error", err)
+	}
+
+	if err := os.WriteFile(configPath, []byte("profiles:\n  staging:\n    logging:\n      levle: debug\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	_, err = LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), `profile staging`) || !strings.Contains(err.Error(), `unknown field "levle" at line 4, did you mean "level"?`) {
+		t.Errorf("LoadConfig() error = %v, want an unknown field error in the profile", err)
+	}
+}
+
 func TestValidateConfig(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -248,6 +447,324 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Negative proxy max age",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, Proxy: ProxyConfig{Enabled: true, MaxAge: -time.Minute}},
+				Logging:  LoggingConfig{Level: "info", Format: "text"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative frequency jitter",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, FrequencyJitter: -1 * time.Second},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative decision timeout",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, DecisionTimeout: -1 * time.Second},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid trusted proxies",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, TrustedProxies: []string{"10.0.0.0/8", "::1", "192.168.1.10"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid trusted proxy",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, TrustedProxies: []string{"proxy.example.com"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid language",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, Languages: []string{"en", "sv_SE"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Relative deny webhook URL",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, DenyWebhook: DenyWebhookConfig{URL: "/hooks/deny"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative deny webhook batch size",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, DenyWebhook: DenyWebhookConfig{URL: "https://siem.example.com/deny", BatchSize: -1}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown audit format",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout", Audit: AuditConfig{Target: "stdout", Format: "leef"}},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unsupported audit target scheme",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout", Audit: AuditConfig{Target: "https://siem.example.com/audit"}},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid syslog audit target",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout", Audit: AuditConfig{Target: "udp://127.0.0.1:514", Format: "cef", OnFull: "block"}},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Unknown analytics format",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout", Analytics: AnalyticsConfig{Dir: "/var/lib/go-trust/analytics", Format: "json"}},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative analytics interval",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout", Analytics: AnalyticsConfig{Dir: "/var/lib/go-trust/analytics", Interval: -time.Hour}},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid parquet analytics",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout", Analytics: AnalyticsConfig{Dir: "/var/lib/go-trust/analytics", Format: "parquet", Interval: time.Hour}},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Relative provider pages URL",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, ProviderPagesURL: "html/providers"},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative max concurrent evaluations",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, MaxConcurrentEvaluations: -1},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative warm-up samples",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, WarmUpSamples: -1},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Health listener address without port",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, HealthAddr: "127.0.0.1"},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid external URL",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, ExternalURL: "https://pdp.example.com"},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Negative max download size",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3, MaxDownloadSize: -1},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative download rate limit",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3, DownloadRateLimit: -1},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative clock check threshold",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3, ClockCheckThreshold: -time.Second},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative max referenced TSLs",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3, MaxReferencedTSLs: -1},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative max pool size",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3, MaxPoolSize: -1},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative clock skew",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, ClockSkew: -time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid OIDFed registry",
+			config: &Config{
+				Server:     ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:    LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline:   PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security:   SecurityConfig{RateLimitRPS: 100},
+				Registries: RegistriesConfig{OIDFed: &OIDFedConfig{TrustAnchors: []string{"https://ta.example.com"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "OIDFed registry without trust anchors",
+			config: &Config{
+				Server:     ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:    LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline:   PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security:   SecurityConfig{RateLimitRPS: 100},
+				Registries: RegistriesConfig{OIDFed: &OIDFedConfig{}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "OIDFed registry with negative cache TTL",
+			config: &Config{
+				Server:     ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:    LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline:   PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security:   SecurityConfig{RateLimitRPS: 100},
+				Registries: RegistriesConfig{OIDFed: &OIDFedConfig{TrustAnchors: []string{"https://ta.example.com"}, CacheTTL: -time.Minute}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "OIDFed metadata constraint without check",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+				Registries: RegistriesConfig{OIDFed: &OIDFedConfig{
+					TrustAnchors:        []string{"https://ta.example.com"},
+					MetadataConstraints: []MetadataConstraintConfig{{EntityType: "openid_relying_party", Claim: "redirect_uris"}},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "OIDFed trust anchor that is not a URL",
+			config: &Config{
+				Server:     ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:    LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline:   PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security:   SecurityConfig{RateLimitRPS: 100},
+				Registries: RegistriesConfig{OIDFed: &OIDFedConfig{TrustAnchors: []string{"ta.example.com"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Relative external URL",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, ExternalURL: "pdp.example.com"},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Invalid log level",
 			config: &Config{
@@ -268,6 +785,16 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Invalid access log format",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout", AccessLog: "stdout", AccessLogFormat: "combined"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Negative timeout",
 			config: &Config{
@@ -298,6 +825,166 @@ func TestValidateConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Tenant without id",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, Tenants: []TenantConfig{{Purposes: []string{"kyc"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate tenant",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, Tenants: []TenantConfig{{ID: "bank"}, {ID: "bank"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Duplicate staged tenant",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, StagedPolicy: &StagedPolicyConfig{Tenants: []TenantConfig{{ID: "bank"}, {ID: "bank"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid client binding",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, TLS: ServerTLSConfig{CertFile: "/etc/go-trust/tls.pem", KeyFile: "/etc/go-trust/tls.key", ClientCAFile: "/etc/go-trust/clients.pem", ClientAuth: "optional"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, ClientBinding: map[string]string{"wallet-provider": "leaf", "*": "chain"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Server TLS without key",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, TLS: ServerTLSConfig{CertFile: "/etc/go-trust/tls.pem"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid server TLS client auth",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, TLS: ServerTLSConfig{CertFile: "/etc/go-trust/tls.pem", KeyFile: "/etc/go-trust/tls.key", ClientAuth: "request"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Client binding without client CA",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, TLS: ServerTLSConfig{CertFile: "/etc/go-trust/tls.pem", KeyFile: "/etc/go-trust/tls.key"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, ClientBinding: map[string]string{"wallet-provider": "leaf"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid client binding mode",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute, TLS: ServerTLSConfig{CertFile: "/etc/go-trust/tls.pem", KeyFile: "/etc/go-trust/tls.key", ClientCAFile: "/etc/go-trust/clients.pem"}},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, ClientBinding: map[string]string{"wallet-provider": "subject"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid name matching",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, NameMatching: []NameMatchConfig{{Actions: []string{"wallet-provider"}, Types: []string{"dns", "uri"}, Match: "wildcard"}, {}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid name matching mode",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, NameMatching: []NameMatchConfig{{Match: "regex"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid name matching type",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, NameMatching: []NameMatchConfig{{Types: []string{"ip"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid staged policy",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, StagedPolicy: &StagedPolicyConfig{Tenants: []TenantConfig{{ID: "bank", Territories: []string{"SE"}}}, Overrides: &OverridesConfig{File: "/etc/go-trust/overrides-staged.yaml"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Tenant with empty territory",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, Tenants: []TenantConfig{{ID: "bank", Territories: []string{"SE", " "}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Tenant with unknown territory",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, Tenants: []TenantConfig{{ID: "bank", Territories: []string{"SE", "XX"}}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Valid tenants",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, Tenants: []TenantConfig{{ID: "bank", Territories: []string{"SE"}}, {ID: "health"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Negative overrides reload interval",
+			config: &Config{
+				Server:   ServerConfig{Host: "127.0.0.1", Port: "6001", Frequency: 5 * time.Minute},
+				Logging:  LoggingConfig{Level: "info", Format: "text", Output: "stdout"},
+				Pipeline: PipelineConfig{Timeout: 30 * time.Second, MaxRequestSize: 1024, MaxRedirects: 3},
+				Security: SecurityConfig{RateLimitRPS: 100, Overrides: OverridesConfig{File: "overrides.yaml", ReloadInterval: -time.Second}},
+			},
+			wantErr: true,
+		},
 		{
 			name: "Non-positive rate limit",
 			config: &Config{
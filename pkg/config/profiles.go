@@ -0,0 +1,104 @@
+package config
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
+)
+
+// bundledProfiles holds the profiles shipped with go-trust, one YAML file
+// per profile with the layout of the configuration file.
+//
+//go:embed profiles/*.yaml
+var bundledProfiles embed.FS
+
+// bundledProfile returns the configuration of the bundled profile name, or
+// nil if there is none.
+func bundledProfile(name string) []byte {
+	data, err := bundledProfiles.ReadFile(path.Join("profiles", name+".yaml"))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// BundledProfiles returns the names of the profiles shipped with go-trust,
+// sorted.
+func BundledProfiles() []string {
+	entries, _ := bundledProfiles.ReadDir("profiles")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profileNames returns the names of the bundled profiles and of the profiles
+// of cfg, sorted.
+func profileNames(cfg *Config) []string {
+	names := BundledProfiles()
+	for name := range cfg.Profiles {
+		if bundledProfile(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadConfigProfile loads configuration like LoadConfig, with the named
+// profile active. An empty profile uses GT_PROFILE, and no profile if that
+// is not set either.
+//
+// A profile is a set of configuration values for one kind of environment.
+// Values are applied in this order, each overriding the ones before:
+//  1. Built-in defaults
+//  2. The bundled profile of that name (production, airgapped or dev), if any
+//  3. The configuration file
+//  4. The profile of that name in the profiles section of the configuration file, if any
+//  5. Environment variables
+//
+// It is an error if neither a bundled profile nor the configuration file
+// defines the profile.
+func LoadConfigProfile(configPath, profile string) (*Config, error) {
+	if profile == "" {
+		profile = os.Getenv("GT_PROFILE")
+	}
+
+	cfg := DefaultConfig()
+	if profile != "" {
+		if data := bundledProfile(profile); data != nil {
+			if err := yamlutil.Unmarshal(data, cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse bundled profile %s: %w", profile, err)
+			}
+		}
+	}
+
+	if err := loadConfigFile(configPath, cfg); err != nil {
+		return nil, err
+	}
+
+	if profile != "" {
+		node, ok := cfg.Profiles[profile]
+		switch {
+		case ok:
+			if err := node.Decode(cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse profile %s: %w", profile, err)
+			}
+		case bundledProfile(profile) == nil:
+			return nil, fmt.Errorf("unknown profile %q: must be one of %s", profile, strings.Join(profileNames(cfg), ", "))
+		}
+		cfg.Profile = profile
+	}
+
+	// Apply environment variable overrides
+	applyEnvOverrides(cfg)
+
+	return cfg, nil
+}
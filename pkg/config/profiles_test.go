@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundledProfiles(t *testing.T) {
+	want := []string{"airgapped", "dev", "production"}
+	if got := BundledProfiles(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("BundledProfiles() = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		cfg, err := LoadConfigProfile("", name)
+		if err != nil {
+			t.Fatalf("LoadConfigProfile(%q) error = %v", name, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("profile %s is not valid: %v", name, err)
+		}
+		if cfg.Profile != name {
+			t.Errorf("Profile = %q, want %q", cfg.Profile, name)
+		}
+	}
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+server:
+  port: "8080"
+logging:
+  format: "text"
+profiles:
+  production:
+    server:
+      external_url: "https://pdp.example.com"
+  staging:
+    logging:
+      level: "warn"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	// Bundled defaults, overridden by the file, extended by its profile section
+	cfg, err := LoadConfigProfile(configPath, "production")
+	if err != nil {
+		t.Fatalf("LoadConfigProfile() error = %v", err)
+	}
+	if cfg.Server.FrequencyJitter != 30*time.Second {
+		t.Errorf("FrequencyJitter = %v, want the bundled 30s", cfg.Server.FrequencyJitter)
+	}
+	if cfg.Logging.Format != "text" {
+		t.Errorf("Format = %v, want text from the file over the bundled json", cfg.Logging.Format)
+	}
+	if cfg.Server.ExternalURL != "https://pdp.example.com" {
+		t.Errorf("ExternalURL = %v, want the value of the profile section", cfg.Server.ExternalURL)
+	}
+	if cfg.Server.Port != "8080" {
+		t.Errorf("Port = %v, want 8080", cfg.Server.Port)
+	}
+
+	// A profile defined only in the file
+	cfg, err = LoadConfigProfile(configPath, "staging")
+	if err != nil {
+		t.Fatalf("LoadConfigProfile() error = %v", err)
+	}
+	if cfg.Logging.Level != "warn" {
+		t.Errorf("Level = %v, want warn", cfg.Logging.Level)
+	}
+	if cfg.Server.FrequencyJitter != 0 {
+		t.Errorf("FrequencyJitter = %v, want no bundled defaults", cfg.Server.FrequencyJitter)
+	}
+
+	// No profile
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Profile != "" || cfg.Server.ExternalURL != "" {
+		t.Errorf("no profile should be active, got %q", cfg.Profile)
+	}
+
+	// Environment variables override the profile
+	os.Setenv("GT_PROFILE", "staging")
+	os.Setenv("GT_LOG_LEVEL", "error")
+	defer os.Unsetenv("GT_PROFILE")
+	defer os.Unsetenv("GT_LOG_LEVEL")
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Profile != "staging" {
+		t.Errorf("Profile = %q, want staging from GT_PROFILE", cfg.Profile)
+	}
+	if cfg.Logging.Level != "error" {
+		t.Errorf("Level = %v, want error from GT_LOG_LEVEL", cfg.Logging.Level)
+	}
+}
+
+func TestLoadConfigProfileUnknown(t *testing.T) {
+	_, err := LoadConfigProfile("", "prod")
+	if err == nil {
+		t.Fatal("LoadConfigProfile() should fail with an unknown profile")
+	}
+	if !strings.Contains(err.Error(), "airgapped, dev, production") {
+		t.Errorf("error should list the profiles, got %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package dsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// Ed25519SignatureMethod is the XML-DSIG signature method of Ed25519 keys
+// (RFC 9231, section 2.3.10).
+const Ed25519SignatureMethod = "http://www.w3.org/2021/04/xmldsig-more#eddsa-ed25519"
+
+// digestMethods maps the XML-DSIG digest methods accepted in signatures
+// verified by VerifyXML outside goxmldsig to their hash functions.
+var digestMethods = map[string]crypto.Hash{
+	"http://www.w3.org/2001/04/xmlenc#sha256":       crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#sha384": crypto.SHA384,
+	"http://www.w3.org/2001/04/xmlenc#sha512":       crypto.SHA512,
+}
+
+// hashSigner is implemented by xmldsig.Signers whose signature method
+// implies a digest other than SHA-256, which SignXML then uses for the
+// references and the SignedInfo.
+type hashSigner interface {
+	Hash() crypto.Hash
+}
+
+// messageSigner is implemented by xmldsig.Signers of schemes that sign the
+// canonical SignedInfo itself rather than a digest of it, such as Ed25519.
+// goxmldsig only hands signers a digest, so SignXML signs the SignedInfo of
+// such signers once goxmldsig has built the signature.
+type messageSigner interface {
+	SignMessage(msg []byte) ([]byte, error)
+}
+
+// newXMLDSigSigner returns the xmldsig.Signer of key and its certificate,
+// selecting the signature method and digest by key type: RSA keys sign
+// with rsa-sha256, ECDSA P-256 keys with ecdsa-sha256, P-384 keys with
+// ecdsa-sha384 and Ed25519 keys with eddsa-ed25519 over SHA-256 references.
+func newXMLDSigSigner(key crypto.Signer, cert *x509.Certificate) (xmldsig.Signer, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return xmldsig.NewFileSigner(k, cert.Raw, crypto.SHA256)
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return &ecdsaSigner{key: k, cert: cert.Raw, hash: crypto.SHA256}, nil
+		case elliptic.P384():
+			return &ecdsaSigner{key: k, cert: cert.Raw, hash: crypto.SHA384}, nil
+		}
+		return nil, fmt.Errorf("unsupported ECDSA curve %s: only P-256 and P-384 are supported", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return &ed25519Signer{key: k, cert: cert.Raw}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// ecdsaSigner implements xmldsig.Signer with an ECDSA key. Signatures are
+// encoded as the fixed-size R || S that XML-DSIG requires, not as ASN.1.
+type ecdsaSigner struct {
+	key  *ecdsa.PrivateKey
+	cert []byte
+	hash crypto.Hash
+}
+
+// Sign signs digest, which must have been computed with the hash of s.
+func (s *ecdsaSigner) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	der, err := ecdsa.SignASN1(rand.Reader, s.key, digest)
+	if err != nil {
+		return nil, err
+	}
+	return ecdsaRawSignature(der, ecdsaCoordinateSize(&s.key.PublicKey))
+}
+
+// Algorithm returns the ecdsa-sha256 or ecdsa-sha384 signature method.
+func (s *ecdsaSigner) Algorithm() xmldsig.SignatureAlgorithm {
+	if s.hash == crypto.SHA384 {
+		return xmldsig.ECDSASHA384SignatureMethod
+	}
+	return xmldsig.ECDSASHA256SignatureMethod
+}
+
+// GetCertificate returns the DER certificate of s.
+func (s *ecdsaSigner) GetCertificate() ([]byte, error) {
+	return s.cert, nil
+}
+
+// Hash returns the hash of the signature method of s.
+func (s *ecdsaSigner) Hash() crypto.Hash {
+	return s.hash
+}
+
+// ed25519Signer implements xmldsig.Signer and messageSigner with an Ed25519
+// key. It only signs through SignXML, as Ed25519 signs messages, not
+// digests.
+type ed25519Signer struct {
+	key  ed25519.PrivateKey
+	cert []byte
+}
+
+// Sign fails: Ed25519 signatures are made by SignMessage.
+func (s *ed25519Signer) Sign(_ io.Reader, _ []byte, _ crypto.SignerOpts) ([]byte, error) {
+	return nil, errors.New("Ed25519 keys sign the SignedInfo, not its digest: sign with SignXML")
+}
+
+// SignMessage signs the canonical SignedInfo msg.
+func (s *ed25519Signer) SignMessage(msg []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, msg), nil
+}
+
+// Algorithm returns the eddsa-ed25519 signature method.
+func (s *ed25519Signer) Algorithm() xmldsig.SignatureAlgorithm {
+	return Ed25519SignatureMethod
+}
+
+// GetCertificate returns the DER certificate of s.
+func (s *ed25519Signer) GetCertificate() ([]byte, error) {
+	return s.cert, nil
+}
+
+// placeholderSigner stands in for a messageSigner while goxmldsig builds
+// the signature, leaving the SignatureValue empty until it is signed.
+type placeholderSigner struct {
+	xmldsig.Signer
+}
+
+// Sign returns an empty signature.
+func (placeholderSigner) Sign(_ io.Reader, _ []byte, _ crypto.SignerOpts) ([]byte, error) {
+	return nil, nil
+}
+
+// signSignedInfo sets the SignatureValue of the enveloped signature of the
+// signed document data to the signature of its canonical SignedInfo by
+// signer.
+func signSignedInfo(data []byte, signer messageSigner) ([]byte, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, err
+	}
+	sig, err := signatureElement(doc.Root())
+	if err != nil {
+		return nil, err
+	}
+	signedInfo, err := canonicalSignedInfo(sig)
+	if err != nil {
+		return nil, err
+	}
+	value, err := signer.SignMessage(signedInfo)
+	if err != nil {
+		return nil, err
+	}
+	el := sig.SelectElement(xmldsig.SignatureValueTag)
+	if el == nil {
+		return nil, fmt.Errorf("signature has no SignatureValue")
+	}
+	el.SetText(base64.StdEncoding.EncodeToString(value))
+	return doc.WriteToBytes()
+}
+
+// canonicalSignedInfo returns the SignedInfo of the signature element sig,
+// canonicalized by its CanonicalizationMethod in the namespace context of
+// sig, as signed by messageSigners.
+func canonicalSignedInfo(sig *etree.Element) ([]byte, error) {
+	signedInfo := sig.SelectElement(xmldsig.SignedInfoTag)
+	if signedInfo == nil {
+		return nil, fmt.Errorf("signature has no SignedInfo")
+	}
+	method := signedInfo.SelectElement(xmldsig.CanonicalizationMethodTag)
+	if method == nil {
+		return nil, fmt.Errorf("SignedInfo has no CanonicalizationMethod")
+	}
+	canonicalizer, err := canonicalizerFor(method)
+	if err != nil {
+		return nil, err
+	}
+
+	nsCtx, err := etreeutils.NSBuildParentContext(sig)
+	if err != nil {
+		return nil, err
+	}
+	if nsCtx, err = nsCtx.SubContext(sig); err != nil {
+		return nil, err
+	}
+	detached, err := etreeutils.NSDetatch(nsCtx, signedInfo)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalizer.Canonicalize(detached)
+}
+
+// canonicalizerFor returns the canonicalizer of the Algorithm of a
+// CanonicalizationMethod or Transform element.
+func canonicalizerFor(el *etree.Element) (xmldsig.Canonicalizer, error) {
+	var prefixList string
+	if ns := el.SelectElement(xmldsig.InclusiveNamespacesTag); ns != nil {
+		prefixList = ns.SelectAttrValue(xmldsig.PrefixListAttr, "")
+	}
+	switch algorithm := xmldsig.AlgorithmID(el.SelectAttrValue(xmldsig.AlgorithmAttr, "")); algorithm {
+	case xmldsig.CanonicalXML10ExclusiveAlgorithmId:
+		return xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList(prefixList), nil
+	case xmldsig.CanonicalXML10ExclusiveWithCommentsAlgorithmId:
+		return xmldsig.MakeC14N10ExclusiveWithCommentsCanonicalizerWithPrefixList(prefixList), nil
+	case xmldsig.CanonicalXML11AlgorithmId:
+		return xmldsig.MakeC14N11Canonicalizer(), nil
+	case xmldsig.CanonicalXML11WithCommentsAlgorithmId:
+		return xmldsig.MakeC14N11WithCommentsCanonicalizer(), nil
+	case xmldsig.CanonicalXML10RecAlgorithmId:
+		return xmldsig.MakeC14N10RecCanonicalizer(), nil
+	case xmldsig.CanonicalXML10WithCommentsAlgorithmId:
+		return xmldsig.MakeC14N10WithCommentsCanonicalizer(), nil
+	default:
+		return nil, fmt.Errorf("unsupported canonicalization method %q", algorithm)
+	}
+}
+
+// ecdsaCoordinateSize returns the size in bytes of R and S in the R || S
+// encoding of signatures by pub.
+func ecdsaCoordinateSize(pub *ecdsa.PublicKey) int {
+	return (pub.Curve.Params().BitSize + 7) / 8
+}
+
+// ecdsaASN1Signature converts an ECDSA signature in R || S form, with size
+// bytes for each of R and S, to ASN.1. Signatures of another length are
+// taken to be ASN.1 already and returned as is.
+func ecdsaASN1Signature(sig []byte, size int) ([]byte, error) {
+	if len(sig) != 2*size {
+		return sig, nil
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{
+		new(big.Int).SetBytes(sig[:size]),
+		new(big.Int).SetBytes(sig[size:]),
+	})
+}
@@ -0,0 +1,97 @@
+package dsig
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// CredentialChecker is implemented by signers that can check their signing
+// credentials without signing anything, so that a misconfigured key is
+// reported when the configuration is loaded rather than at the first signature.
+type CredentialChecker interface {
+	// CheckCredentials loads the signing key and certificate and checks that
+	// they belong together and that the certificate is valid at now. It
+	// returns the signing certificate.
+	CheckCredentials(now time.Time) (*x509.Certificate, error)
+}
+
+// CheckKeyPair checks that pub is the public key of cert and that cert is
+// valid at now.
+//
+// Parameters:
+//   - cert: The signing certificate
+//   - pub: The public key of the signing key
+//   - now: The time at which cert must be valid
+//
+// Returns:
+//   - An error describing the mismatch or the validity period violated
+func CheckKeyPair(cert *x509.Certificate, pub crypto.PublicKey, now time.Time) error {
+	key, ok := pub.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !key.Equal(cert.PublicKey) {
+		return fmt.Errorf("private key does not match certificate %q", cert.Subject.String())
+	}
+	if now.Before(cert.NotBefore) {
+		return fmt.Errorf("certificate %q is not valid before %s", cert.Subject.String(), cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("certificate %q expired at %s", cert.Subject.String(), cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// CheckCredentials implements CredentialChecker: it loads the certificate and
+// key files, checks that Sign supports the key type and checks them with
+// CheckKeyPair.
+func (fs *FileSigner) CheckCredentials(now time.Time) (*x509.Certificate, error) {
+	cert, key, err := fs.load()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := newXMLDSigSigner(key, cert); err != nil {
+		return nil, err
+	}
+	if err := CheckKeyPair(cert, key.Public(), now); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// CheckCredentials implements CredentialChecker: it logs in to the token,
+// finds the key and certificate like Sign does and checks them with
+// CheckKeyPair. The signer is closed afterwards.
+func (ps *PKCS11Signer) CheckCredentials(now time.Time) (*x509.Certificate, error) {
+	if err := ps.initialize(); err != nil {
+		return nil, err
+	}
+	defer ps.Close()
+	idBytes, err := hexToBytes(ps.keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert key ID to bytes: %w", err)
+	}
+
+	// crypto11 returns no error, and no object, when nothing matches
+	key, err := ps.context.FindKeyPair(idBytes, []byte(ps.keyLabel))
+	if err != nil || key == nil {
+		return nil, fmt.Errorf("failed to find private key with label '%s' and ID '%s': %v",
+			ps.keyLabel, ps.keyID, errOrNotFound(err))
+	}
+	cert, err := ps.context.FindCertificate(idBytes, []byte(ps.certLabel), nil)
+	if err != nil || cert == nil {
+		return nil, fmt.Errorf("failed to find certificate with label '%s' and ID '%s': %v",
+			ps.certLabel, ps.keyID, errOrNotFound(err))
+	}
+	if err := CheckKeyPair(cert, key.Public(), now); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// errOrNotFound returns err, or a "not found" error if err is nil.
+func errOrNotFound(err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("not found")
+}
@@ -0,0 +1,89 @@
+package dsig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+// writeSignerFiles writes the certificate of cert and the key of key to a
+// temporary directory and returns a FileSigner for them.
+func writeSignerFiles(t *testing.T, cert, key *testutil.Cert) *FileSigner {
+	t.Helper()
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	keyPEM, err := key.KeyPEM()
+	if err != nil {
+		t.Fatalf("Failed to encode key: %v", err)
+	}
+	if err := os.WriteFile(certFile, cert.CertPEM(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return NewFileSigner(certFile, keyFile)
+}
+
+func TestFileSigner_CheckCredentials(t *testing.T) {
+	signer, err := testutil.NewSelfSigned("Test Signer", testutil.WithRSAKey(2048))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	other, err := testutil.NewSelfSigned("Other Signer", testutil.WithRSAKey(2048))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	expired, err := testutil.NewSelfSigned("Expired Signer", testutil.WithRSAKey(2048), testutil.Expired())
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	p521Key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	p521, err := testutil.NewSelfSigned("P-521 Signer", testutil.WithKey(p521Key))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	cert, err := writeSignerFiles(t, signer, signer).CheckCredentials(time.Now())
+	if err != nil {
+		t.Fatalf("CheckCredentials() error = %v", err)
+	}
+	if !cert.Equal(signer.Certificate) {
+		t.Errorf("CheckCredentials() returned %s, want %s", cert.Subject, signer.Certificate.Subject)
+	}
+
+	tests := []struct {
+		name   string
+		signer *FileSigner
+		want   string
+	}{
+		{"mismatched key", writeSignerFiles(t, signer, other), "does not match certificate"},
+		{"expired certificate", writeSignerFiles(t, expired, expired), "expired at"},
+		{"unsupported curve", writeSignerFiles(t, p521, p521), "unsupported ECDSA curve P-521"},
+		{"missing key file", NewFileSigner(writeSignerFiles(t, signer, signer).CertFile, "/nonexistent/key.pem"), "failed to read key file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.signer.CheckCredentials(time.Now())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CheckCredentials() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// A certificate that is valid now is not yet valid a year ago
+	if err := CheckKeyPair(signer.Certificate, signer.Key.Public(), time.Now().AddDate(-1, 0, 0)); err == nil || !strings.Contains(err.Error(), "not valid before") {
+		t.Errorf("CheckKeyPair() error = %v, want not valid before", err)
+	}
+}
@@ -2,6 +2,8 @@ package dsig
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -18,7 +20,9 @@ type FileSigner struct {
 	// CertFile is the path to the X.509 certificate file in PEM format
 	CertFile string
 
-	// KeyFile is the path to the private key file in PEM format (PKCS#1 or PKCS#8)
+	// KeyFile is the path to the private key file in PEM format: an RSA
+	// (PKCS#1), ECDSA P-256 or P-384 (SEC 1) or any of these or an Ed25519
+	// key in PKCS#8 format
 	KeyFile string
 }
 
@@ -42,7 +46,9 @@ func NewFileSigner(certFile, keyFile string) *FileSigner {
 // This method loads the certificate and private key from files,
 // creates an XML digital signature, and returns the signed XML document.
 //
-// The method supports both PKCS#1 and PKCS#8 formatted private keys.
+// The signature method follows the key type: rsa-sha256 for RSA keys,
+// ecdsa-sha256 for P-256 keys, ecdsa-sha384 (with SHA-384 digests) for
+// P-384 keys and eddsa-ed25519 for Ed25519 keys.
 //
 // Parameters:
 //   - xmlData: Raw XML bytes to sign
@@ -51,61 +57,78 @@ func NewFileSigner(certFile, keyFile string) *FileSigner {
 //   - The signed XML document as bytes
 //   - An error if reading files, parsing certificates/keys, or signing fails
 func (fs *FileSigner) Sign(xmlData []byte) ([]byte, error) {
-	// Load the certificate and private key
+	cert, privateKey, err := fs.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if rsaKey, ok := privateKey.(*rsa.PrivateKey); ok {
+		// Create a key store from the loaded certificate and private key
+		keyStore := &fileKeyStore{
+			cert: cert,
+			key:  rsaKey,
+		}
+		return SignXMLWithKeyStore(xmlData, keyStore)
+	}
+
+	signer, err := newXMLDSigSigner(privateKey, cert)
+	if err != nil {
+		return nil, err
+	}
+	return SignXML(xmlData, signer)
+}
+
+// load reads and parses the certificate and the private key of the
+// FileSigner. RSA keys may be in PKCS#1, ECDSA keys in SEC 1 and all keys
+// in PKCS#8 format.
+func (fs *FileSigner) load() (*x509.Certificate, crypto.Signer, error) {
 	certData, err := os.ReadFile(fs.CertFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read certificate file: %w", err)
 	}
 
 	keyData, err := os.ReadFile(fs.KeyFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read key file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read key file: %w", err)
 	}
 
 	// Parse the certificate
 	certBlock, _ := pem.Decode(certData)
 	if certBlock == nil {
-		return nil, fmt.Errorf("failed to decode certificate PEM")
+		return nil, nil, fmt.Errorf("failed to decode certificate PEM")
 	}
 
 	cert, err := x509.ParseCertificate(certBlock.Bytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
 	// Parse the private key
 	keyBlock, _ := pem.Decode(keyData)
 	if keyBlock == nil {
-		return nil, fmt.Errorf("failed to decode key PEM")
+		return nil, nil, fmt.Errorf("failed to decode key PEM")
 	}
 
-	// Try to parse the key in different formats
-	var privateKey *rsa.PrivateKey
-	var privateKeyAny interface{}
-
-	// Try PKCS1 format
-	privateKey, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes); err == nil {
+		return cert, key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(keyBlock.Bytes); err == nil {
+		return cert, key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
 	if err != nil {
-		// Try PKCS8 format
-		privateKeyAny, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse private key: %w", err)
-		}
-
-		var ok bool
-		privateKey, ok = privateKeyAny.(*rsa.PrivateKey)
-		if !ok {
-			return nil, fmt.Errorf("private key is not RSA")
-		}
+		return nil, nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
-
-	// Create a key store from the loaded certificate and private key
-	keyStore := &fileKeyStore{
-		cert: cert,
-		key:  privateKey,
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return cert, key, nil
+	case *ecdsa.PrivateKey:
+		return cert, key, nil
+	case ed25519.PrivateKey:
+		return cert, key, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported private key type %T", key)
 	}
-
-	return SignXMLWithKeyStore(xmlData, keyStore)
 }
 
 // fileKeyStore implements the xmldsig.X509KeyStore interface.
@@ -131,62 +154,18 @@ func (ks *fileKeyStore) GetKeyPair() (*rsa.PrivateKey, []byte, error) {
 // This method loads the certificate and private key from files and creates
 // an xmldsig.Signer that can be used with the goxmldsig library directly.
 //
-// The method supports both PKCS#1 and PKCS#8 formatted private keys and
-// configures the signer to use SHA-256 for signatures.
+// The signature method is selected by key type as for Sign. Signers of
+// Ed25519 keys only sign through SignXML, as goxmldsig signs digests and
+// Ed25519 signs the SignedInfo itself.
 //
 // Returns:
 //   - An xmldsig.Signer implementation using the file-based certificate and key
 //   - An error if reading files, parsing certificates/keys fails
 func (fs *FileSigner) ToXMLDSigSigner() (xmldsig.Signer, error) {
-	// Load the certificate and private key
-	certData, err := os.ReadFile(fs.CertFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read certificate file: %w", err)
-	}
-
-	keyData, err := os.ReadFile(fs.KeyFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read key file: %w", err)
-	}
-
-	// Parse the certificate
-	certBlock, _ := pem.Decode(certData)
-	if certBlock == nil {
-		return nil, fmt.Errorf("failed to decode certificate PEM")
-	}
-
-	cert, err := x509.ParseCertificate(certBlock.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse certificate: %w", err)
-	}
-
-	// Parse the private key
-	keyBlock, _ := pem.Decode(keyData)
-	if keyBlock == nil {
-		return nil, fmt.Errorf("failed to decode key PEM")
-	}
-
-	// Try to parse the key in different formats
-	var privateKey *rsa.PrivateKey
-	var privateKeyAny interface{}
-
-	// Try PKCS1 format
-	privateKey, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	cert, privateKey, err := fs.load()
 	if err != nil {
-		// Try PKCS8 format
-		privateKeyAny, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse private key: %w", err)
-		}
-
-		var ok bool
-		privateKey, ok = privateKeyAny.(*rsa.PrivateKey)
-		if !ok {
-			return nil, fmt.Errorf("private key is not RSA")
-		}
+		return nil, err
 	}
 
-	// Use the file private key with certificate to create a new xmldsig.Signer
-	// Default to SHA256 for the signing algorithm
-	return xmldsig.NewFileSigner(privateKey, cert.Raw, crypto.SHA256)
+	return newXMLDSigSigner(privateKey, cert)
 }
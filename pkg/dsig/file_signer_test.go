@@ -1,10 +1,23 @@
 package dsig
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
 )
 
 func TestFileSigner(t *testing.T) {
@@ -233,3 +246,109 @@ func TestToXMLDSigSigner(t *testing.T) {
 		}
 	})
 }
+
+func TestFileSigner_KeyTypes(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		opts            []testutil.Option
+		sec1            bool
+		signatureMethod string
+		digestMethod    string
+		signatureSize   int
+	}{
+		{"RSA", []testutil.Option{testutil.WithRSAKey(2048)}, false, xmldsig.RSASHA256SignatureMethod, "http://www.w3.org/2001/04/xmlenc#sha256", 256},
+		{"ECDSA P-256", nil, false, xmldsig.ECDSASHA256SignatureMethod, "http://www.w3.org/2001/04/xmlenc#sha256", 64},
+		{"ECDSA P-256 SEC 1", nil, true, xmldsig.ECDSASHA256SignatureMethod, "http://www.w3.org/2001/04/xmlenc#sha256", 64},
+		{"ECDSA P-384", []testutil.Option{testutil.WithKey(p384)}, false, xmldsig.ECDSASHA384SignatureMethod, "http://www.w3.org/2001/04/xmldsig-more#sha384", 96},
+		{"Ed25519", []testutil.Option{testutil.WithKey(ed)}, false, Ed25519SignatureMethod, "http://www.w3.org/2001/04/xmlenc#sha256", 64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := testutil.NewSelfSigned("Test Signer", tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to generate certificate: %v", err)
+			}
+			signer := writeSignerFiles(t, c, c)
+			if tt.sec1 {
+				der, err := x509.MarshalECPrivateKey(c.Key.(*ecdsa.PrivateKey))
+				if err != nil {
+					t.Fatalf("Failed to encode key: %v", err)
+				}
+				if err := os.WriteFile(signer.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			signed, err := signer.Sign([]byte(`<list Id="tsl"><item>one</item></list>`))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			doc := etree.NewDocument()
+			if err := doc.ReadFromBytes(signed); err != nil {
+				t.Fatalf("Failed to parse signed document: %v", err)
+			}
+			if got := doc.FindElement("//SignatureMethod").SelectAttrValue("Algorithm", ""); got != tt.signatureMethod {
+				t.Errorf("SignatureMethod = %s, want %s", got, tt.signatureMethod)
+			}
+			if got := doc.FindElement("//DigestMethod").SelectAttrValue("Algorithm", ""); got != tt.digestMethod {
+				t.Errorf("DigestMethod = %s, want %s", got, tt.digestMethod)
+			}
+			value, err := base64.StdEncoding.DecodeString(doc.FindElement("//SignatureValue").Text())
+			if err != nil || len(value) != tt.signatureSize {
+				t.Errorf("SignatureValue is %d bytes (%v), want %d", len(value), err, tt.signatureSize)
+			}
+
+			cert, err := VerifyXML(signed)
+			if err != nil {
+				t.Fatalf("VerifyXML() error = %v", err)
+			}
+			if !cert.Equal(c.Certificate) {
+				t.Errorf("VerifyXML() returned %s, want %s", cert.Subject, c.Certificate.Subject)
+			}
+			tampered := bytes.Replace(signed, []byte("one"), []byte("two"), 1)
+			if _, err := VerifyXML(tampered); err == nil {
+				t.Error("Expected a tampered document to fail verification")
+			}
+
+			// The xmldsig.Signer signs the same way through SignXML
+			xmldsigSigner, err := signer.ToXMLDSigSigner()
+			if err != nil {
+				t.Fatalf("ToXMLDSigSigner() error = %v", err)
+			}
+			if string(xmldsigSigner.Algorithm()) != tt.signatureMethod {
+				t.Errorf("Algorithm() = %s, want %s", xmldsigSigner.Algorithm(), tt.signatureMethod)
+			}
+			signed, err = SignXML([]byte(`<list><item>one</item></list>`), xmldsigSigner)
+			if err != nil {
+				t.Fatalf("SignXML() error = %v", err)
+			}
+			if _, err := VerifyXML(signed); err != nil {
+				t.Errorf("VerifyXML() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFileSigner_UnsupportedCurve(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	c, err := testutil.NewSelfSigned("Test Signer", testutil.WithKey(key))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	_, err = writeSignerFiles(t, c, c).Sign([]byte(`<list/>`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported ECDSA curve P-521") {
+		t.Errorf("Sign() error = %v, want unsupported curve", err)
+	}
+}
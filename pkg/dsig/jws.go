@@ -0,0 +1,173 @@
+package dsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// jwsHeader is the protected header of JSON Web Signatures made by SignJWS.
+// The signing certificate is carried in x5c, so that the signature can be
+// checked without further configuration, as with the KeyInfo of XML-DSIG.
+type jwsHeader struct {
+	Alg string   `json:"alg"`
+	Typ string   `json:"typ,omitempty"`
+	X5C []string `json:"x5c"`
+}
+
+// SignJWS signs payload as a JSON Web Signature (RFC 7515) in compact
+// serialization, for JSON documents that cannot carry an XML-DSIG signature.
+// RSA keys sign with RS256 and P-256 ECDSA keys with ES256. The certificate
+// is included in the x5c header.
+//
+// Parameters:
+//   - payload: The document to sign
+//   - typ: The media type of the payload for the typ header, or "" for none
+//   - key: The private key matching cert
+//   - cert: The signing certificate
+//
+// Returns:
+//   - The compact JWS, holding the payload
+//   - An error if the key type is not supported or signing fails
+func SignJWS(payload []byte, typ string, key crypto.Signer, cert *x509.Certificate) ([]byte, error) {
+	var alg string
+	switch k := key.Public().(type) {
+	case *rsa.PublicKey:
+		alg = "RS256"
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ECDSA curve %s: only P-256 is supported", k.Curve.Params().Name)
+		}
+		alg = "ES256"
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k)
+	}
+
+	header, err := json.Marshal(jwsHeader{
+		Alg: alg,
+		Typ: typ,
+		X5C: []string{base64.StdEncoding.EncodeToString(cert.Raw)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode JWS header: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+	if alg == "ES256" {
+		// JWS uses the fixed-size R || S encoding rather than ASN.1
+		if sig, err = ecdsaRawSignature(sig, 32); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)), nil
+}
+
+// VerifyJWS verifies a compact JSON Web Signature made by SignJWS against the
+// certificate in its x5c header. Like VerifyXML, it establishes that the
+// payload is intact and that the signing key matches the certificate, not
+// that the certificate is trusted.
+//
+// Parameters:
+//   - jws: The compact JWS
+//
+// Returns:
+//   - The signed payload
+//   - The certificate the payload was signed with
+//   - An error if the JWS is malformed or the signature is invalid
+func VerifyJWS(jws []byte) ([]byte, *x509.Certificate, error) {
+	parts := bytes.Split(bytes.TrimSpace(jws), []byte("."))
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("malformed JWS: expected 3 parts, got %d", len(parts))
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, nil, fmt.Errorf("malformed JWS header: %w", err)
+	}
+	if len(header.X5C) == 0 {
+		return nil, nil, fmt.Errorf("JWS header has no x5c certificate")
+	}
+	der, err := base64.StdEncoding.DecodeString(header.X5C[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode x5c certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse x5c certificate: %w", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed JWS payload: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(string(parts[2]))
+	if err != nil {
+		return nil, nil, fmt.Errorf("malformed JWS signature: %w", err)
+	}
+
+	digest := sha256.Sum256(bytes.Join(parts[:2], []byte(".")))
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return nil, nil, fmt.Errorf("JWS algorithm %q does not match the RSA certificate", header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig); err != nil {
+			return nil, nil, fmt.Errorf("invalid signature: %w", err)
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 {
+			return nil, nil, fmt.Errorf("JWS algorithm %q does not match the ECDSA certificate", header.Alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return nil, nil, fmt.Errorf("invalid signature")
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported certificate key type %T", pub)
+	}
+	return payload, cert, nil
+}
+
+// SignJWS implements JSON signing with the certificate and key files of the
+// FileSigner; see the package function SignJWS. Only RSA and P-256 ECDSA
+// keys are accepted.
+func (fs *FileSigner) SignJWS(payload []byte, typ string) ([]byte, error) {
+	cert, key, err := fs.load()
+	if err != nil {
+		return nil, err
+	}
+	return SignJWS(payload, typ, key, cert)
+}
+
+// ecdsaRawSignature converts an ASN.1 ECDSA signature to the fixed-size
+// R || S form used by JWS and XML-DSIG, with size bytes for each of R and S.
+func ecdsaRawSignature(der []byte, size int) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("failed to decode ECDSA signature: %w", err)
+	}
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	return raw, nil
+}
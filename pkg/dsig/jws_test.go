@@ -0,0 +1,109 @@
+package dsig
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+func TestSignJWS(t *testing.T) {
+	rsaCert, err := testutil.NewSelfSigned("RSA Signer", testutil.WithRSAKey(2048))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	ecCert, err := testutil.NewSelfSigned("EC Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	payload := []byte(`{"version":1,"serial":7}`)
+
+	for _, signer := range []*testutil.Cert{rsaCert, ecCert} {
+		t.Run(signer.Certificate.Subject.CommonName, func(t *testing.T) {
+			jws, err := SignJWS(payload, "application/json", signer.Key, signer.Certificate)
+			if err != nil {
+				t.Fatalf("SignJWS() error = %v", err)
+			}
+
+			got, cert, err := VerifyJWS(jws)
+			if err != nil {
+				t.Fatalf("VerifyJWS() error = %v", err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("VerifyJWS() payload = %s, want %s", got, payload)
+			}
+			if !cert.Equal(signer.Certificate) {
+				t.Errorf("VerifyJWS() certificate = %s, want %s", cert.Subject, signer.Certificate.Subject)
+			}
+
+			// Replacing the payload breaks the signature
+			parts := strings.Split(string(jws), ".")
+			other, _ := SignJWS([]byte(`{"version":1,"serial":8}`), "", signer.Key, signer.Certificate)
+			parts[1] = strings.Split(string(other), ".")[1]
+			if _, _, err := VerifyJWS([]byte(strings.Join(parts, "."))); err == nil {
+				t.Error("Expected a tampered payload to fail verification")
+			}
+		})
+	}
+}
+
+func TestSignJWS_KeyCertificateMismatch(t *testing.T) {
+	published, err := testutil.NewSelfSigned("Published Certificate")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	other, err := testutil.NewSelfSigned("Other Key")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	jws, err := SignJWS([]byte(`{}`), "", other.Key, published.Certificate)
+	if err != nil {
+		t.Fatalf("SignJWS() error = %v", err)
+	}
+	if _, _, err := VerifyJWS(jws); err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Errorf("VerifyJWS() error = %v, want an invalid signature", err)
+	}
+}
+
+func TestVerifyJWS_Malformed(t *testing.T) {
+	for _, jws := range []string{"", "a.b", "a.b.c", "e30.e30.AA"} {
+		if _, _, err := VerifyJWS([]byte(jws)); err == nil {
+			t.Errorf("VerifyJWS(%q) should fail", jws)
+		}
+	}
+}
+
+func TestFileSigner_SignJWS(t *testing.T) {
+	signer, err := testutil.NewSelfSigned("File Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	keyPEM, err := signer.KeyPEM()
+	if err != nil {
+		t.Fatalf("KeyPEM() error = %v", err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, signer.CertPEM(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	jws, err := NewFileSigner(certFile, keyFile).SignJWS([]byte(`{}`), "")
+	if err != nil {
+		t.Fatalf("SignJWS() error = %v", err)
+	}
+	if _, cert, err := VerifyJWS(jws); err != nil || !cert.Equal(signer.Certificate) {
+		t.Errorf("VerifyJWS() = %v, %v", cert, err)
+	}
+
+	if _, err := NewFileSigner(certFile, filepath.Join(dir, "missing.pem")).SignJWS([]byte(`{}`), ""); err == nil {
+		t.Error("Expected a missing key file to fail")
+	}
+}
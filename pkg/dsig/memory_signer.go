@@ -0,0 +1,115 @@
+package dsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// EphemeralSignerCommonName is the subject common name of the certificates
+// of NewEphemeralSigner, marking lists signed with them as not for
+// production.
+const EphemeralSignerCommonName = "go-trust ephemeral signer (NOT FOR PRODUCTION)"
+
+// EphemeralSignerValidity is how long the certificates of NewEphemeralSigner
+// are valid.
+const EphemeralSignerValidity = 365 * 24 * time.Hour
+
+// MemorySigner implements XMLSigner with a certificate and key held in
+// memory, for tests and for environments that sign with a key generated at
+// startup rather than one loaded from files or a token.
+type MemorySigner struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// NewMemorySigner creates a MemorySigner with a certificate and its key.
+// The signature method follows the key type as for FileSigner.
+//
+// Parameters:
+//   - cert: The signing certificate
+//   - key: The private key of cert: RSA, ECDSA P-256 or P-384, or Ed25519
+//
+// Returns:
+//   - A new MemorySigner signing with key
+func NewMemorySigner(cert *x509.Certificate, key crypto.Signer) *MemorySigner {
+	return &MemorySigner{cert: cert, key: key}
+}
+
+// NewEphemeralSigner creates a MemorySigner with a new ECDSA P-256 key and
+// a self-signed certificate named EphemeralSignerCommonName, valid for
+// EphemeralSignerValidity. Relying parties cannot trust its signatures in
+// advance: it exercises the signed publish path in CI and demo
+// environments without key files or an HSM, and is not for production.
+//
+// Returns:
+//   - A MemorySigner with a key that exists only in memory
+//   - An error if the key or certificate cannot be generated
+func NewEphemeralSigner() (*MemorySigner, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: EphemeralSignerCommonName, Organization: []string{"go-trust"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(EphemeralSignerValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ephemeral certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ephemeral certificate: %w", err)
+	}
+	return NewMemorySigner(cert, key), nil
+}
+
+// Certificate returns the signing certificate of the MemorySigner.
+func (ms *MemorySigner) Certificate() *x509.Certificate {
+	return ms.cert
+}
+
+// Sign implements XMLSigner.Sign with the key of the MemorySigner.
+//
+// Parameters:
+//   - xmlData: Raw XML bytes to sign
+//
+// Returns:
+//   - The signed XML document as bytes
+//   - An error if the key type is not supported or signing fails
+func (ms *MemorySigner) Sign(xmlData []byte) ([]byte, error) {
+	signer, err := newXMLDSigSigner(ms.key, ms.cert)
+	if err != nil {
+		return nil, err
+	}
+	return SignXML(xmlData, signer)
+}
+
+// CheckCredentials implements CredentialChecker: it checks that Sign
+// supports the key type and checks the certificate and key with
+// CheckKeyPair.
+func (ms *MemorySigner) CheckCredentials(now time.Time) (*x509.Certificate, error) {
+	if _, err := newXMLDSigSigner(ms.key, ms.cert); err != nil {
+		return nil, err
+	}
+	if err := CheckKeyPair(ms.cert, ms.key.Public(), now); err != nil {
+		return nil, err
+	}
+	return ms.cert, nil
+}
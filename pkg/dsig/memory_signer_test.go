@@ -0,0 +1,99 @@
+package dsig
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+func TestMemorySigner(t *testing.T) {
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	for name, opts := range map[string][]testutil.Option{
+		"RSA":     {testutil.WithRSAKey(2048)},
+		"ECDSA":   nil,
+		"Ed25519": {testutil.WithKey(ed)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := testutil.NewSelfSigned("Memory Signer", opts...)
+			if err != nil {
+				t.Fatalf("Failed to generate certificate: %v", err)
+			}
+			signer := NewMemorySigner(c.Certificate, c.Key)
+			signed, err := signer.Sign([]byte(`<list><item>one</item></list>`))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			cert, err := VerifyXML(signed)
+			if err != nil {
+				t.Fatalf("VerifyXML() error = %v", err)
+			}
+			if !cert.Equal(c.Certificate) {
+				t.Errorf("VerifyXML() returned %s, want %s", cert.Subject, c.Certificate.Subject)
+			}
+			if _, err := signer.CheckCredentials(time.Now()); err != nil {
+				t.Errorf("CheckCredentials() error = %v", err)
+			}
+		})
+	}
+
+	c, err := testutil.NewSelfSigned("Memory Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	other, err := testutil.NewSelfSigned("Other Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	_, err = NewMemorySigner(c.Certificate, other.Key).CheckCredentials(time.Now())
+	if err == nil || !strings.Contains(err.Error(), "private key does not match certificate") {
+		t.Errorf("CheckCredentials() error = %v, want key mismatch", err)
+	}
+}
+
+func TestNewEphemeralSigner(t *testing.T) {
+	signer, err := NewEphemeralSigner()
+	if err != nil {
+		t.Fatalf("NewEphemeralSigner() error = %v", err)
+	}
+	cert := signer.Certificate()
+	if cert.Subject.CommonName != EphemeralSignerCommonName {
+		t.Errorf("CommonName = %q, want %q", cert.Subject.CommonName, EphemeralSignerCommonName)
+	}
+	if !bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		t.Error("Expected a self-signed certificate")
+	}
+	if _, ok := cert.PublicKey.(*ecdsa.PublicKey); !ok {
+		t.Errorf("PublicKey is %T, want ECDSA", cert.PublicKey)
+	}
+	if _, err := signer.CheckCredentials(time.Now()); err != nil {
+		t.Errorf("CheckCredentials() error = %v", err)
+	}
+	if _, err := signer.CheckCredentials(time.Now().Add(EphemeralSignerValidity + time.Hour)); err == nil {
+		t.Error("Expected the certificate to expire after EphemeralSignerValidity")
+	}
+
+	signed, err := signer.Sign([]byte(`<list/>`))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err := VerifyXML(signed); err != nil {
+		t.Errorf("VerifyXML() error = %v", err)
+	}
+
+	again, err := NewEphemeralSigner()
+	if err != nil {
+		t.Fatalf("NewEphemeralSigner() error = %v", err)
+	}
+	if again.Certificate().Equal(cert) {
+		t.Error("Expected a new key for every ephemeral signer")
+	}
+}
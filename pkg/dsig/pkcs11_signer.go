@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/ThalesGroup/crypto11"
+	"github.com/miekg/pkcs11"
 	xmldsig "github.com/russellhaering/goxmldsig"
 )
 
@@ -97,21 +98,20 @@ func (ps *PKCS11Signer) initialize() error {
 }
 
 // Close cleans up any resources associated with the signer.
-// This method prepares the signer for garbage collection by resetting
-// its internal state. Note that the crypto11 context doesn't currently
-// have an explicit Close method, but this function is provided for
-// future-proofing.
+// This method closes the crypto11 context, which unloads the PKCS#11
+// module once no other context uses it, and resets the signer's internal
+// state so that the next signing operation connects again.
 //
 // Returns:
-//   - Always returns nil error (reserved for future implementations)
+//   - An error if the crypto11 context could not be closed
 func (ps *PKCS11Signer) Close() error {
-	if ps.context != nil {
-		// Context doesn't have a Close method in crypto11,
-		// but we can add it here for future-proofing
-		ps.initialized = false
-		ps.context = nil
+	if ps.context == nil {
+		return nil
 	}
-	return nil
+	err := ps.context.Close()
+	ps.initialized = false
+	ps.context = nil
+	return err
 }
 
 // SetKeyID sets the ID to use for key and certificate lookups.
@@ -258,3 +258,25 @@ func ExtractPKCS11Config(pkcs11URI string) *crypto11.Config {
 
 	return config
 }
+
+// CheckPKCS11Module reports whether the PKCS#11 module at path can be loaded
+// and initialized. It does not open a session or log in to a token, so it
+// needs no PIN.
+//
+// Parameters:
+//   - path: Path to the PKCS#11 module shared library
+//
+// Returns:
+//   - An error if the module cannot be loaded or initialized
+func CheckPKCS11Module(path string) error {
+	module := pkcs11.New(path)
+	if module == nil {
+		return fmt.Errorf("failed to load PKCS#11 module %s", path)
+	}
+	defer module.Destroy()
+
+	if err := module.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize PKCS#11 module %s: %w", path, err)
+	}
+	return module.Finalize()
+}
@@ -96,3 +96,15 @@ func TestPKCS11SignerWithSoftHSM(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckPKCS11Module(t *testing.T) {
+	if err := CheckPKCS11Module("/nonexistent/libpkcs11.so"); err == nil {
+		t.Error("Expected an error for a missing module")
+	}
+
+	if helper := test.SkipIfSoftHSMUnavailable(t); helper != nil {
+		if err := CheckPKCS11Module(helper.LibPath); err != nil {
+			t.Errorf("Expected SoftHSM module to load: %v", err)
+		}
+	}
+}
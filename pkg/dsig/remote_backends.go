@@ -0,0 +1,244 @@
+package dsig
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Metadata service URLs of instance tokens for the cloud signing backends.
+const (
+	gcpTokenURL   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	azureTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=https%3A%2F%2Fvault.azure.net"
+)
+
+// remoteSignRequest is the request body of the generic http backend: the
+// JWS name of the signature algorithm and the base64 digest. The service
+// answers with {"signature": "<base64>"}, PKCS#1 v1.5 for RSA and ASN.1 or
+// R || S for ECDSA.
+type remoteSignRequest struct {
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+}
+
+// newSignRequest returns the request of the backend of rs that signs digest
+// with alg.
+func (rs *RemoteSigner) newSignRequest(ctx context.Context, alg remoteAlgorithm, digest []byte) (*http.Request, error) {
+	var (
+		target string
+		body   any
+	)
+	switch rs.Backend {
+	case RemoteBackendHTTP:
+		target = rs.Key
+		body = remoteSignRequest{Algorithm: alg.jws, Digest: base64.StdEncoding.EncodeToString(digest)}
+	case RemoteBackendAWSKMS:
+		return rs.newAWSSignRequest(ctx, alg, digest)
+	case RemoteBackendGCPKMS:
+		endpoint := rs.Endpoint
+		if endpoint == "" {
+			endpoint = "https://cloudkms.googleapis.com"
+		}
+		target = strings.TrimSuffix(endpoint, "/") + "/v1/" + rs.Key + ":asymmetricSign"
+		hashName := "sha256"
+		if alg.size == 48 {
+			hashName = "sha384"
+		}
+		body = map[string]any{"digest": map[string]string{hashName: base64.StdEncoding.EncodeToString(digest)}}
+	case RemoteBackendAzureKeyVault:
+		target = strings.TrimSuffix(rs.Key, "/") + "/sign?api-version=7.4"
+		body = map[string]string{"alg": alg.jws, "value": base64.RawURLEncoding.EncodeToString(digest)}
+	default:
+		return nil, fmt.Errorf("unknown remote signing backend %q", rs.Backend)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token, err := rs.bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// parseSignResponse returns the signature in the response body of the
+// backend of rs.
+func (rs *RemoteSigner) parseSignResponse(body []byte) ([]byte, error) {
+	var (
+		resp map[string]any
+		sig  []byte
+		err  error
+	)
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	field := map[string]string{
+		RemoteBackendHTTP:          "signature",
+		RemoteBackendAWSKMS:        "Signature",
+		RemoteBackendGCPKMS:        "signature",
+		RemoteBackendAzureKeyVault: "value",
+	}[rs.Backend]
+	value, _ := resp[field].(string)
+	if value == "" {
+		return nil, fmt.Errorf("invalid response: no %s", field)
+	}
+	if rs.Backend == RemoteBackendAzureKeyVault {
+		sig, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	} else {
+		sig, err = base64.StdEncoding.DecodeString(value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %s is not base64: %w", field, err)
+	}
+	return sig, nil
+}
+
+// metadataToken requests an access token for the backend of rs from the
+// metadata service of the instance it runs on.
+func (rs *RemoteSigner) metadataToken(ctx context.Context) (string, error) {
+	tokenURL, header, value := gcpTokenURL, "Metadata-Flavor", "Google"
+	if rs.Backend == RemoteBackendAzureKeyVault {
+		tokenURL, header, value = azureTokenURL, "Metadata", "true"
+	}
+	if rs.tokenURL != "" {
+		tokenURL = rs.tokenURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(header, value)
+
+	body, err := rs.do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token from the metadata service: %w", err)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("failed to get token from the metadata service: no access_token in response")
+	}
+	return token.AccessToken, nil
+}
+
+// newAWSSignRequest returns the AWS KMS Sign request of digest with alg,
+// signed with Signature Version 4 using the credentials of the AWS_*
+// environment variables.
+func (rs *RemoteSigner) newAWSSignRequest(ctx context.Context, alg remoteAlgorithm, digest []byte) (*http.Request, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	region := awsRegion(rs.Key)
+	if region == "" {
+		return nil, fmt.Errorf("no AWS region: use a key ARN or set AWS_REGION")
+	}
+	endpoint := rs.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"KeyId":            rs.Key,
+		"Message":          base64.StdEncoding.EncodeToString(digest),
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": alg.aws,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Sign")
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	signAWSRequest(req, payload, accessKey, secretKey, region, "kms", time.Now())
+	return req, nil
+}
+
+// awsRegion returns the region of an AWS KMS key ARN, or that of the
+// AWS_REGION or AWS_DEFAULT_REGION environment variable for key IDs.
+func awsRegion(key string) string {
+	if parts := strings.Split(key, ":"); len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// signAWSRequest adds the X-Amz-Date and Authorization headers of AWS
+// Signature Version 4 to req, which has body payload and no query.
+func signAWSRequest(req *http.Request, payload []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,400 @@
+package dsig
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// Remote signing backends.
+const (
+	RemoteBackendHTTP          = "http"     // Generic HTTP signing API
+	RemoteBackendAWSKMS        = "aws-kms"  // AWS KMS
+	RemoteBackendGCPKMS        = "gcp-kms"  // Google Cloud KMS
+	RemoteBackendAzureKeyVault = "azure-kv" // Azure Key Vault
+)
+
+// Defaults of RemoteSigner.
+const (
+	DefaultRemoteRetries = 2                // Retries after a failed signing request
+	DefaultRemoteTimeout = 10 * time.Second // Timeout of each signing request
+)
+
+// remoteRetryBackoff is the wait before the first retry of a signing
+// request; it doubles with every further retry.
+var remoteRetryBackoff = 200 * time.Millisecond
+
+var (
+	remoteSignDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "go_trust_remote_sign_duration_seconds",
+			Help:    "Duration of requests to remote signing services by backend and status in seconds",
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"backend", "status"},
+	)
+	remoteSignRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "go_trust_remote_sign_retries_total",
+			Help: "Total number of signing requests to remote signing services that were retried, by backend",
+		},
+		[]string{"backend"},
+	)
+)
+
+// RemoteSignerCollectors returns the Prometheus collectors of the requests
+// of all RemoteSigners, to be registered alongside the server's metrics.
+func RemoteSignerCollectors() []prometheus.Collector {
+	return []prometheus.Collector{remoteSignDuration, remoteSignRetries}
+}
+
+// RemoteSigner implements XMLSigner with a key held by a remote signing
+// service, for operators without a local HSM. The XML signature is built
+// locally and only the digest of its SignedInfo is sent to the service, so
+// the key never leaves it. The certificate of the key is read from a file.
+//
+// RSA keys sign with rsa-sha256, ECDSA P-256 keys with ecdsa-sha256 and
+// P-384 keys with ecdsa-sha384; the key type is taken from the certificate.
+// Requests failing with a network error, 429 or a 5xx status are retried.
+type RemoteSigner struct {
+	// Backend is the signing service: RemoteBackendHTTP, RemoteBackendAWSKMS,
+	// RemoteBackendGCPKMS or RemoteBackendAzureKeyVault
+	Backend string
+
+	// Key identifies the key: the URL of the signing endpoint (http), a key
+	// ID or ARN (aws-kms), a CryptoKeyVersion resource name (gcp-kms) or a
+	// key URL with version (azure-kv)
+	Key string
+
+	// CertFile is the path to the certificate of the key in PEM format
+	CertFile string
+
+	// Endpoint overrides the service endpoint of aws-kms and gcp-kms
+	Endpoint string
+
+	// Token is the bearer token of http, gcp-kms and azure-kv requests
+	Token string
+
+	// TokenFile is read for the bearer token at every request, for tokens
+	// refreshed by another process. Without Token and TokenFile, gcp-kms
+	// and azure-kv request a token from the metadata service of the
+	// instance (managed identity)
+	TokenFile string
+
+	// Retries is the number of retries of a failed request
+	Retries int
+
+	// Timeout is the timeout of each request
+	Timeout time.Duration
+
+	// Client sends the requests; http.DefaultClient if nil
+	Client *http.Client
+
+	// tokenURL overrides the metadata service URL of tokens
+	tokenURL string
+}
+
+// NewRemoteSignerFromURI creates a RemoteSigner from a remote signer URI
+// and the certificate file of its key. The URI is "remote:" followed by the
+// backend and the key, as in
+//
+//	remote:aws-kms:arn:aws:kms:eu-north-1:111122223333:key/1234abcd-...
+//	remote:gcp-kms:projects/P/locations/L/keyRings/R/cryptoKeys/K/cryptoKeyVersions/1
+//	remote:azure-kv:https://VAULT.vault.azure.net/keys/NAME/VERSION
+//	remote:https://signer.example.com/v1/sign
+//
+// The bearer token is taken from GT_SIGNER_TOKEN, or read from the file
+// named by GT_SIGNER_TOKEN_FILE at every request. AWS credentials and the
+// region are taken from the standard AWS_* environment variables.
+//
+// Parameters:
+//   - uri: The remote signer URI
+//   - certFile: Path to the certificate of the key in PEM format
+//
+// Returns:
+//   - A RemoteSigner with the default retries and timeout
+//   - An error if the URI is invalid
+func NewRemoteSignerFromURI(uri, certFile string) (*RemoteSigner, error) {
+	rest, ok := strings.CutPrefix(uri, "remote:")
+	if !ok {
+		return nil, fmt.Errorf("invalid remote signer URI %q: must start with remote:", uri)
+	}
+	rs := &RemoteSigner{
+		CertFile:  certFile,
+		Token:     os.Getenv("GT_SIGNER_TOKEN"),
+		TokenFile: os.Getenv("GT_SIGNER_TOKEN_FILE"),
+		Retries:   DefaultRemoteRetries,
+		Timeout:   DefaultRemoteTimeout,
+	}
+	switch {
+	case strings.HasPrefix(rest, "http://"), strings.HasPrefix(rest, "https://"):
+		rs.Backend, rs.Key = RemoteBackendHTTP, rest
+	default:
+		rs.Backend, rs.Key, _ = strings.Cut(rest, ":")
+	}
+	switch rs.Backend {
+	case RemoteBackendHTTP, RemoteBackendAWSKMS, RemoteBackendGCPKMS, RemoteBackendAzureKeyVault:
+	default:
+		return nil, fmt.Errorf("invalid remote signer URI %q: unknown backend %q", uri, rs.Backend)
+	}
+	if rs.Key == "" {
+		return nil, fmt.Errorf("invalid remote signer URI %q: no key", uri)
+	}
+	return rs, nil
+}
+
+// Sign implements XMLSigner.Sign: it builds the XML signature with the
+// certificate of the RemoteSigner and has the remote service sign its
+// SignedInfo.
+//
+// Parameters:
+//   - xmlData: Raw XML bytes to sign
+//
+// Returns:
+//   - The signed XML document as bytes
+//   - An error if the certificate cannot be read or the service fails
+func (rs *RemoteSigner) Sign(xmlData []byte) ([]byte, error) {
+	signer, err := rs.xmlDSigSigner()
+	if err != nil {
+		return nil, err
+	}
+	return SignXML(xmlData, signer)
+}
+
+// CheckCredentials implements CredentialChecker: it reads the certificate,
+// has the service sign a test digest and checks that the signature verifies
+// with the certificate, so that a wrong key or missing permissions are
+// reported at startup. The certificate must also be valid at now.
+func (rs *RemoteSigner) CheckCredentials(now time.Time) (*x509.Certificate, error) {
+	signer, err := rs.xmlDSigSigner()
+	if err != nil {
+		return nil, err
+	}
+	alg := signer.alg
+	h := alg.hash.New()
+	h.Write([]byte("go-trust remote signer credential check"))
+	digest := h.Sum(nil)
+	sig, err := rs.signDigest(alg, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub := signer.cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(pub, alg.hash, digest, sig)
+	case *ecdsa.PublicKey:
+		var der []byte
+		if der, err = ecdsaASN1Signature(sig, alg.size); err == nil && !ecdsa.VerifyASN1(pub, digest, der) {
+			err = errors.New("verification failed")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("remote key does not match certificate %q: %w", signer.cert.Subject.String(), err)
+	}
+	if err := CheckKeyPair(signer.cert, signer.cert.PublicKey, now); err != nil {
+		return nil, err
+	}
+	return signer.cert, nil
+}
+
+// remoteAlgorithm is a signature algorithm of remote keys.
+type remoteAlgorithm struct {
+	method string      // XML-DSIG signature method
+	hash   crypto.Hash // Digest signed
+	jws    string      // JWS name, as used by the http and azure-kv backends
+	aws    string      // AWS KMS SigningAlgorithm
+	size   int         // Size of R and S of ECDSA signatures, 0 for RSA
+}
+
+// remoteAlgorithmFor returns the signature algorithm of the key of cert.
+func remoteAlgorithmFor(cert *x509.Certificate) (remoteAlgorithm, error) {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return remoteAlgorithm{xmldsig.RSASHA256SignatureMethod, crypto.SHA256, "RS256", "RSASSA_PKCS1_V1_5_SHA_256", 0}, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return remoteAlgorithm{xmldsig.ECDSASHA256SignatureMethod, crypto.SHA256, "ES256", "ECDSA_SHA_256", 32}, nil
+		case elliptic.P384():
+			return remoteAlgorithm{xmldsig.ECDSASHA384SignatureMethod, crypto.SHA384, "ES384", "ECDSA_SHA_384", 48}, nil
+		}
+		return remoteAlgorithm{}, fmt.Errorf("unsupported ECDSA curve %s: only P-256 and P-384 are supported", pub.Curve.Params().Name)
+	default:
+		return remoteAlgorithm{}, fmt.Errorf("unsupported remote key type %T: only RSA and ECDSA keys are supported", pub)
+	}
+}
+
+// xmlDSigSigner reads the certificate of rs and returns the xmldsig.Signer
+// signing with rs.
+func (rs *RemoteSigner) xmlDSigSigner() (*remoteXMLSigner, error) {
+	data, err := os.ReadFile(rs.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	alg, err := remoteAlgorithmFor(cert)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteXMLSigner{rs: rs, cert: cert, alg: alg}, nil
+}
+
+// remoteXMLSigner implements xmldsig.Signer with a RemoteSigner.
+type remoteXMLSigner struct {
+	rs   *RemoteSigner
+	cert *x509.Certificate
+	alg  remoteAlgorithm
+}
+
+// Sign has the remote service sign digest. ECDSA signatures are returned as
+// R || S.
+func (s *remoteXMLSigner) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	sig, err := s.rs.signDigest(s.alg, digest)
+	if err != nil || s.alg.size == 0 || len(sig) == 2*s.alg.size {
+		return sig, err
+	}
+	return ecdsaRawSignature(sig, s.alg.size)
+}
+
+// Algorithm returns the signature method of the key.
+func (s *remoteXMLSigner) Algorithm() xmldsig.SignatureAlgorithm {
+	return xmldsig.SignatureAlgorithm(s.alg.method)
+}
+
+// GetCertificate returns the DER certificate of the key.
+func (s *remoteXMLSigner) GetCertificate() ([]byte, error) {
+	return s.cert.Raw, nil
+}
+
+// Hash returns the hash of the signature method.
+func (s *remoteXMLSigner) Hash() crypto.Hash {
+	return s.alg.hash
+}
+
+// remoteStatusError is the error of a signing request answered with an
+// unexpected status.
+type remoteStatusError struct {
+	status int
+	body   string
+}
+
+func (e *remoteStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.status, e.body)
+}
+
+// retryable reports whether a request that failed with err may succeed
+// when retried: if it failed to reach the service or was answered with 429
+// or a 5xx status.
+func retryable(err error) bool {
+	var statusErr *remoteStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status == http.StatusTooManyRequests || statusErr.status >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// signDigest has the remote service sign digest with alg, retrying failed
+// requests. It returns the signature as returned by the service: PKCS#1
+// v1.5 for RSA, and ASN.1 or R || S for ECDSA.
+func (rs *RemoteSigner) signDigest(alg remoteAlgorithm, digest []byte) ([]byte, error) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		var sig []byte
+		start := time.Now()
+		sig, err = rs.signOnce(alg, digest)
+		status := "success"
+		if err != nil {
+			status = "error"
+		}
+		remoteSignDuration.WithLabelValues(rs.Backend, status).Observe(time.Since(start).Seconds())
+		if err == nil {
+			return sig, nil
+		}
+		if attempt >= rs.Retries || !retryable(err) {
+			break
+		}
+		remoteSignRetries.WithLabelValues(rs.Backend).Inc()
+		time.Sleep(remoteRetryBackoff << attempt)
+	}
+	return nil, fmt.Errorf("remote signing with %s failed: %w", rs.Backend, err)
+}
+
+// signOnce sends a single signing request.
+func (rs *RemoteSigner) signOnce(alg remoteAlgorithm, digest []byte) ([]byte, error) {
+	timeout := rs.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRemoteTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := rs.newSignRequest(ctx, alg, digest)
+	if err != nil {
+		return nil, err
+	}
+	body, err := rs.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return rs.parseSignResponse(body)
+}
+
+// do sends req and returns the body of a 200 response.
+func (rs *RemoteSigner) do(req *http.Request) ([]byte, error) {
+	client := rs.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &remoteStatusError{resp.StatusCode, string(bytes.TrimSpace(body))}
+	}
+	return body, nil
+}
+
+// bearerToken returns the bearer token of requests to the service.
+func (rs *RemoteSigner) bearerToken(ctx context.Context) (string, error) {
+	if rs.TokenFile != "" {
+		data, err := os.ReadFile(rs.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if rs.Token != "" || rs.Backend == RemoteBackendHTTP {
+		return rs.Token, nil
+	}
+	return rs.metadataToken(ctx)
+}
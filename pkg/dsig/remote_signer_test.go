@@ -0,0 +1,302 @@
+package dsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// remoteService is a fake remote signing service of every backend signing
+// with key. Requests are answered with the statuses of fail first.
+type remoteService struct {
+	t        *testing.T
+	key      crypto.Signer
+	fail     []int
+	requests int
+}
+
+func (s *remoteService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	if len(s.fail) > 0 {
+		status := s.fail[0]
+		s.fail = s.fail[1:]
+		http.Error(w, "unavailable", status)
+		return
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.t.Errorf("Invalid request body: %v", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var (
+		digest []byte
+		err    error
+		field  = "signature"
+		raw    bool
+	)
+	switch {
+	case r.Header.Get("X-Amz-Target") == "TrentService.Sign":
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			s.t.Errorf("Authorization = %q, want AWS Signature Version 4", auth)
+		}
+		if body["MessageType"] != "DIGEST" {
+			s.t.Errorf("MessageType = %v, want DIGEST", body["MessageType"])
+		}
+		digest, err = base64.StdEncoding.DecodeString(body["Message"].(string))
+		field = "Signature"
+	case strings.HasSuffix(r.URL.Path, ":asymmetricSign"):
+		for _, v := range body["digest"].(map[string]any) {
+			digest, err = base64.StdEncoding.DecodeString(v.(string))
+		}
+	case strings.HasSuffix(r.URL.Path, "/sign") && r.URL.Query().Get("api-version") != "":
+		digest, err = base64.RawURLEncoding.DecodeString(body["value"].(string))
+		field, raw = "value", true
+	default:
+		digest, err = base64.StdEncoding.DecodeString(body["digest"].(string))
+	}
+	if err != nil {
+		s.t.Errorf("Invalid digest: %v", err)
+	}
+	if r.Header.Get("X-Amz-Target") == "" && r.Header.Get("Authorization") != "Bearer secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var sig []byte
+	switch key := s.key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	case *ecdsa.PrivateKey:
+		sig, err = ecdsa.SignASN1(rand.Reader, key, digest)
+		if err == nil && raw {
+			sig, err = ecdsaRawSignature(sig, ecdsaCoordinateSize(&key.PublicKey))
+		}
+	}
+	if err != nil {
+		s.t.Errorf("Failed to sign: %v", err)
+	}
+	value := base64.StdEncoding.EncodeToString(sig)
+	if raw {
+		value = base64.RawURLEncoding.EncodeToString(sig)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{field: value})
+}
+
+// newRemoteSigner starts a remoteService for c and returns a RemoteSigner
+// of backend using it.
+func newRemoteSigner(t *testing.T, backend string, c *testutil.Cert) (*RemoteSigner, *remoteService) {
+	t.Helper()
+	service := &remoteService{t: t, key: c.Key}
+	server := httptest.NewServer(service)
+	t.Cleanup(server.Close)
+
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(certFile, c.CertPEM(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rs := &RemoteSigner{Backend: backend, CertFile: certFile, Token: "secret", Timeout: time.Second}
+	switch backend {
+	case RemoteBackendHTTP:
+		rs.Key = server.URL + "/v1/sign"
+	case RemoteBackendAWSKMS:
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+		rs.Key, rs.Endpoint = "arn:aws:kms:eu-north-1:111122223333:key/1234abcd", server.URL
+	case RemoteBackendGCPKMS:
+		rs.Key, rs.Endpoint = "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", server.URL
+	case RemoteBackendAzureKeyVault:
+		rs.Key = server.URL + "/keys/signer/1"
+	}
+	return rs, service
+}
+
+func TestRemoteSigner_Backends(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		backend string
+		opts    []testutil.Option
+	}{
+		{RemoteBackendHTTP, []testutil.Option{testutil.WithRSAKey(2048)}},
+		{RemoteBackendAWSKMS, nil},
+		{RemoteBackendGCPKMS, []testutil.Option{testutil.WithKey(p384)}},
+		{RemoteBackendAzureKeyVault, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			c, err := testutil.NewSelfSigned("Remote Signer", tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to generate certificate: %v", err)
+			}
+			rs, _ := newRemoteSigner(t, tt.backend, c)
+
+			signed, err := rs.Sign([]byte(`<list Id="tsl"><item>one</item></list>`))
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			cert, err := VerifyXML(signed)
+			if err != nil {
+				t.Fatalf("VerifyXML() error = %v", err)
+			}
+			if !cert.Equal(c.Certificate) {
+				t.Errorf("VerifyXML() returned %s, want %s", cert.Subject, c.Certificate.Subject)
+			}
+			if _, err := rs.CheckCredentials(time.Now()); err != nil {
+				t.Errorf("CheckCredentials() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRemoteSigner_Retry(t *testing.T) {
+	remoteRetryBackoff = time.Millisecond
+	t.Cleanup(func() { remoteRetryBackoff = 200 * time.Millisecond })
+
+	c, err := testutil.NewSelfSigned("Remote Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	rs, service := newRemoteSigner(t, RemoteBackendGCPKMS, c)
+	rs.Retries = 2
+	retries := promtestutil.ToFloat64(remoteSignRetries.WithLabelValues(RemoteBackendGCPKMS))
+
+	// Unavailable services are retried
+	service.fail = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	if _, err := rs.Sign([]byte(`<list/>`)); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if service.requests != 3 {
+		t.Errorf("requests = %d, want 3", service.requests)
+	}
+	if got := promtestutil.ToFloat64(remoteSignRetries.WithLabelValues(RemoteBackendGCPKMS)) - retries; got != 2 {
+		t.Errorf("retries = %v, want 2", got)
+	}
+
+	// ... up to Retries times
+	service.requests = 0
+	service.fail = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+	if _, err := rs.Sign([]byte(`<list/>`)); err == nil || !strings.Contains(err.Error(), "status 502") {
+		t.Errorf("Sign() error = %v, want status 502", err)
+	}
+	if service.requests != 3 {
+		t.Errorf("requests = %d, want 3", service.requests)
+	}
+
+	// Denied requests are not
+	service.requests = 0
+	service.fail = []int{http.StatusForbidden}
+	if _, err := rs.Sign([]byte(`<list/>`)); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("Sign() error = %v, want status 403", err)
+	}
+	if service.requests != 1 {
+		t.Errorf("requests = %d, want 1", service.requests)
+	}
+}
+
+func TestRemoteSigner_MetadataToken(t *testing.T) {
+	c, err := testutil.NewSelfSigned("Remote Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" {
+			http.Error(w, "no metadata header", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"secret","token_type":"Bearer"}`))
+	}))
+	defer metadata.Close()
+
+	rs, _ := newRemoteSigner(t, RemoteBackendAzureKeyVault, c)
+	rs.Token, rs.tokenURL = "", metadata.URL
+	if _, err := rs.Sign([]byte(`<list/>`)); err != nil {
+		t.Errorf("Sign() error = %v", err)
+	}
+
+	// A token file takes precedence
+	rs.TokenFile = filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(rs.TokenFile, []byte("wrong\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rs.Sign([]byte(`<list/>`)); err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Errorf("Sign() error = %v, want status 401 for the token of the token file", err)
+	}
+}
+
+func TestRemoteSigner_CheckCredentials(t *testing.T) {
+	c, err := testutil.NewSelfSigned("Remote Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	other, err := testutil.NewSelfSigned("Other Signer")
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+
+	rs, service := newRemoteSigner(t, RemoteBackendHTTP, c)
+	service.key = other.Key
+	_, err = rs.CheckCredentials(time.Now())
+	if err == nil || !strings.Contains(err.Error(), "remote key does not match certificate") {
+		t.Errorf("CheckCredentials() error = %v, want key mismatch", err)
+	}
+
+	rs.CertFile = filepath.Join(t.TempDir(), "missing.pem")
+	if _, err := rs.CheckCredentials(time.Now()); err == nil {
+		t.Error("Expected an error for a missing certificate")
+	}
+}
+
+func TestNewRemoteSignerFromURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		backend string
+		key     string
+		wantErr string
+	}{
+		{"remote:https://signer.example.com/v1/sign", RemoteBackendHTTP, "https://signer.example.com/v1/sign", ""},
+		{"remote:aws-kms:arn:aws:kms:eu-north-1:111122223333:key/1234abcd", RemoteBackendAWSKMS, "arn:aws:kms:eu-north-1:111122223333:key/1234abcd", ""},
+		{"remote:gcp-kms:projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", RemoteBackendGCPKMS, "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", ""},
+		{"remote:azure-kv:https://vault.vault.azure.net/keys/signer/1", RemoteBackendAzureKeyVault, "https://vault.vault.azure.net/keys/signer/1", ""},
+		{"pkcs11:module=/lib/softhsm.so", "", "", "must start with remote:"},
+		{"remote:vault:transit/keys/signer", "", "", `unknown backend "vault"`},
+		{"remote:aws-kms:", "", "", "no key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			t.Setenv("GT_SIGNER_TOKEN", "secret")
+			rs, err := NewRemoteSignerFromURI(tt.uri, "cert.pem")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("NewRemoteSignerFromURI() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRemoteSignerFromURI() error = %v", err)
+			}
+			if rs.Backend != tt.backend || rs.Key != tt.key || rs.Token != "secret" || rs.Retries != DefaultRemoteRetries {
+				t.Errorf("NewRemoteSignerFromURI() = %+v", rs)
+			}
+		})
+	}
+}
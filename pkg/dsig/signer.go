@@ -1,7 +1,8 @@
 // Package dsig provides XML Digital Signature (XML-DSIG) functionality
 // for signing Trust Status Lists (TSLs) and other XML documents.
 // It supports multiple signing mechanisms including file-based keys
-// and PKCS#11 hardware security modules.
+// and PKCS#11 hardware security modules. JSON documents are signed as
+// JSON Web Signatures instead (see SignJWS).
 package dsig
 
 import (
@@ -49,6 +50,10 @@ type X509KeyStore interface {
 // 3. Signs the document with an enveloped signature
 // 4. Returns the signed document
 //
+// Digests are SHA-256, unless the signature method of the signer implies
+// another hash, as ecdsa-sha384 does. Ed25519 signers made by
+// FileSigner.ToXMLDSigSigner sign the canonical SignedInfo itself.
+//
 // Parameters:
 //   - xmlData: Raw XML bytes to sign
 //   - signer: An implementation of xmldsig.Signer to perform the signing operation
@@ -59,6 +64,13 @@ type X509KeyStore interface {
 func SignXML(xmlData []byte, signer xmldsig.Signer) ([]byte, error) {
 	// Create the signing context with our signer
 	ctx := xmldsig.NewDefaultSigningContextWithSigner(signer)
+	if hs, ok := signer.(hashSigner); ok {
+		ctx.Hash = hs.Hash()
+	}
+	ms, signsMessage := signer.(messageSigner)
+	if signsMessage {
+		ctx.Signer = placeholderSigner{signer}
+	}
 
 	// Use exclusive canonicalization (C14N)
 	ctx.Canonicalizer = xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
@@ -78,7 +90,11 @@ func SignXML(xmlData []byte, signer xmldsig.Signer) ([]byte, error) {
 	// Return the signed XML
 	doc2 := etree.NewDocument()
 	doc2.SetRoot(signedDoc)
-	return doc2.WriteToBytes()
+	signed, err := doc2.WriteToBytes()
+	if err != nil || !signsMessage {
+		return signed, err
+	}
+	return signSignedInfo(signed, ms)
 }
 
 // SignXMLWithKeyStore signs XML data using the provided X509KeyStore.
@@ -0,0 +1,199 @@
+package dsig
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// ErrNotSigned is returned by VerifyXML for documents without an enveloped
+// signature.
+var ErrNotSigned = errors.New("document is not signed")
+
+// VerifyXML verifies the enveloped XML-DSIG signature of a document signed by
+// SignXML or an XMLSigner. The signature is checked against the certificate in
+// its own KeyInfo, so VerifyXML establishes that the document is intact and
+// that the signing key matches the certificate it was published with, not
+// that the certificate is trusted. It catches signers whose key and
+// certificate do not belong together, such as a misconfigured HSM slot.
+//
+// RSA, ECDSA (with R || S or ASN.1 signature values) and Ed25519 signatures
+// are accepted.
+//
+// Parameters:
+//   - xmlData: The signed XML document
+//
+// Returns:
+//   - The certificate the document was signed with
+//   - An error if the document is not signed (ErrNotSigned), or the signature
+//     or the certificate is invalid
+func VerifyXML(xmlData []byte) (*x509.Certificate, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(xmlData); err != nil {
+		return nil, fmt.Errorf("failed to parse XML: %w", err)
+	}
+	root := doc.Root()
+	if root == nil {
+		return nil, fmt.Errorf("document has no root element")
+	}
+
+	sig, err := signatureElement(root)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := signatureCertificate(sig)
+	if err != nil {
+		return nil, err
+	}
+
+	var method string
+	if el := sig.FindElement("./SignedInfo/SignatureMethod"); el != nil {
+		method = el.SelectAttrValue(xmldsig.AlgorithmAttr, "")
+	}
+	if method == Ed25519SignatureMethod {
+		// goxmldsig does not know Ed25519
+		if err := verifyEd25519(root, sig, cert); err != nil {
+			return nil, fmt.Errorf("invalid signature: %w", err)
+		}
+		return cert, nil
+	}
+	if pub, ok := cert.PublicKey.(*ecdsa.PublicKey); ok {
+		if err := asn1SignatureValue(sig, pub); err != nil {
+			return nil, fmt.Errorf("invalid signature: %w", err)
+		}
+	}
+
+	ctx := xmldsig.NewDefaultValidationContext(&xmldsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{cert},
+	})
+	if _, err := ctx.Validate(root); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	return cert, nil
+}
+
+// signatureElement returns the enveloped signature of root, or
+// ErrNotSigned if it has none.
+func signatureElement(root *etree.Element) (*etree.Element, error) {
+	for _, el := range root.ChildElements() {
+		if el.Tag == xmldsig.SignatureTag {
+			return el, nil
+		}
+	}
+	return nil, ErrNotSigned
+}
+
+// signatureCertificate returns the certificate in the KeyInfo of the
+// signature element sig.
+func signatureCertificate(sig *etree.Element) (*x509.Certificate, error) {
+	el := sig.FindElement("./KeyInfo/X509Data/X509Certificate")
+	if el == nil {
+		return nil, fmt.Errorf("signature has no X509Certificate in KeyInfo")
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(el.Text()), ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signing certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// asn1SignatureValue rewrites an R || S ECDSA SignatureValue of sig, as
+// required by XML-DSIG and made by SignXML, to the ASN.1 form goxmldsig
+// verifies. The SignatureValue is not signed, so this does not affect the
+// signature. ASN.1 values, as made by some other signers, are left as is.
+func asn1SignatureValue(sig *etree.Element, pub *ecdsa.PublicKey) error {
+	el := sig.SelectElement(xmldsig.SignatureValueTag)
+	if el == nil {
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(el.Text()), ""))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature value: %w", err)
+	}
+	der, err := ecdsaASN1Signature(raw, ecdsaCoordinateSize(pub))
+	if err != nil {
+		return err
+	}
+	el.SetText(base64.StdEncoding.EncodeToString(der))
+	return nil
+}
+
+// verifyEd25519 verifies the Ed25519 signature element sig of root against
+// cert: the signature of its canonical SignedInfo and the digest of its
+// single reference, which must be root.
+func verifyEd25519(root, sig *etree.Element, cert *x509.Certificate) error {
+	pub, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return fmt.Errorf("signature method %s does not match the %T certificate key", Ed25519SignatureMethod, cert.PublicKey)
+	}
+	signedInfo, err := canonicalSignedInfo(sig)
+	if err != nil {
+		return err
+	}
+	el := sig.SelectElement(xmldsig.SignatureValueTag)
+	if el == nil {
+		return fmt.Errorf("signature has no SignatureValue")
+	}
+	value, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(el.Text()), ""))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature value: %w", err)
+	}
+	if !ed25519.Verify(pub, signedInfo, value) {
+		return errors.New("Ed25519 signature does not match the SignedInfo")
+	}
+
+	refs := sig.FindElements("./SignedInfo/Reference")
+	if len(refs) != 1 {
+		return fmt.Errorf("expected one Reference, got %d", len(refs))
+	}
+	ref := refs[0]
+	if uri := ref.SelectAttrValue(xmldsig.URIAttr, ""); uri != "" && uri != "#"+root.SelectAttrValue(xmldsig.DefaultIdAttr, "") {
+		return fmt.Errorf("reference %q is not the signed document", uri)
+	}
+
+	// Apply the transforms to a copy of root
+	doc := root.Copy()
+	var canonicalizer xmldsig.Canonicalizer = xmldsig.MakeC14N10RecCanonicalizer()
+	for _, transform := range ref.FindElements("./Transforms/Transform") {
+		if xmldsig.AlgorithmID(transform.SelectAttrValue(xmldsig.AlgorithmAttr, "")) == xmldsig.EnvelopedSignatureAltorithmId {
+			doc.RemoveChildAt(sig.Index())
+			continue
+		}
+		if canonicalizer, err = canonicalizerFor(transform); err != nil {
+			return err
+		}
+	}
+	canonical, err := canonicalizer.Canonicalize(doc)
+	if err != nil {
+		return err
+	}
+
+	var digestMethod, digestValue string
+	if el := ref.SelectElement(xmldsig.DigestMethodTag); el != nil {
+		digestMethod = el.SelectAttrValue(xmldsig.AlgorithmAttr, "")
+	}
+	if el := ref.SelectElement(xmldsig.DigestValueTag); el != nil {
+		digestValue = strings.Join(strings.Fields(el.Text()), "")
+	}
+	hash, ok := digestMethods[digestMethod]
+	if !ok {
+		return fmt.Errorf("unsupported digest method %q", digestMethod)
+	}
+	h := hash.New()
+	h.Write(canonical)
+	if base64.StdEncoding.EncodeToString(h.Sum(nil)) != digestValue {
+		return errors.New("reference digest does not match the document")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package dsig
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+// newTestKeyStore returns a key store for a self-signed RSA certificate.
+func newTestKeyStore(t *testing.T, commonName string) *fileKeyStore {
+	t.Helper()
+	cert, err := testutil.NewSelfSigned(commonName, testutil.WithRSAKey(2048))
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	return &fileKeyStore{cert: cert.Certificate, key: cert.Key.(*rsa.PrivateKey)}
+}
+
+func TestVerifyXML(t *testing.T) {
+	ks := newTestKeyStore(t, "Test Signer")
+	signed, err := SignXMLWithKeyStore([]byte(`<list><item>one</item></list>`), ks)
+	if err != nil {
+		t.Fatalf("Signing failed: %v", err)
+	}
+
+	cert, err := VerifyXML(signed)
+	if err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+	if !cert.Equal(ks.cert) {
+		t.Errorf("VerifyXML() returned %s, want %s", cert.Subject, ks.cert.Subject)
+	}
+
+	tampered := bytes.Replace(signed, []byte("one"), []byte("two"), 1)
+	if _, err := VerifyXML(tampered); err == nil {
+		t.Error("Expected a tampered document to fail verification")
+	}
+}
+
+func TestVerifyXML_KeyCertificateMismatch(t *testing.T) {
+	ks := newTestKeyStore(t, "Published Certificate")
+	other := newTestKeyStore(t, "Other Key")
+	mismatched := &fileKeyStore{cert: ks.cert, key: other.key}
+
+	signed, err := SignXMLWithKeyStore([]byte(`<list><item>one</item></list>`), mismatched)
+	if err != nil {
+		t.Fatalf("Signing failed: %v", err)
+	}
+	_, err = VerifyXML(signed)
+	if err == nil || !strings.Contains(err.Error(), "invalid signature") {
+		t.Errorf("VerifyXML() error = %v, want an invalid signature", err)
+	}
+}
+
+func TestVerifyXML_Unsigned(t *testing.T) {
+	for _, data := range []string{`<list><item>one</item></list>`, `<list>`, ``} {
+		if _, err := VerifyXML([]byte(data)); err == nil {
+			t.Errorf("VerifyXML(%q) should fail", data)
+		}
+	}
+	_, err := VerifyXML([]byte(`<list><item>one</item></list>`))
+	if err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("VerifyXML() error = %v, want not signed", err)
+	}
+	if !errors.Is(err, ErrNotSigned) {
+		t.Errorf("VerifyXML() error = %v, want ErrNotSigned", err)
+	}
+}
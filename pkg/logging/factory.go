@@ -1,7 +1,11 @@
 package logging
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
@@ -34,3 +38,23 @@ func JSONLogger(level LogLevel) Logger {
 	l.SetLevel(level)
 	return l
 }
+
+// OpenOutput returns the writer for a log output setting: "stdout", "stderr"
+// or the path of a file, which is created along with its directory if needed
+// and appended to.
+func OpenOutput(output string) (io.Writer, error) {
+	switch strings.ToLower(output) {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	return file, nil
+}
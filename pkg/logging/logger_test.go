@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -162,3 +164,28 @@ func TestFactoryMethods(t *testing.T) {
 		t.Errorf("Expected level %d, got %d", DebugLevel, got)
 	}
 }
+
+func TestOpenOutput(t *testing.T) {
+	if w, err := OpenOutput("STDERR"); err != nil || w != os.Stderr {
+		t.Errorf("OpenOutput(STDERR) = %v, %v; want os.Stderr", w, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	w, err := OpenOutput(path)
+	if err != nil {
+		t.Fatalf("OpenOutput(%s) failed: %v", path, err)
+	}
+	if _, err := w.Write([]byte("line\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.(*os.File).Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "line\n" {
+		t.Errorf("log file contains %q, %v", data, err)
+	}
+
+	if _, err := OpenOutput(filepath.Join(path, "nested.log")); err == nil {
+		t.Error("OpenOutput below a file should fail")
+	}
+}
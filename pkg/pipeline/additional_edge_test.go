@@ -312,9 +312,9 @@ func TestAddProviderCertificates_NoServiceNames(t *testing.T) {
 
 	// Create metadata without service names
 	yamlFile := filepath.Join(tmpDir, "cert.yaml")
-	yamlContent := `service_type: "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	yamlContent := `serviceType: "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
 status: "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"
-service_names: []`
+serviceNames: []`
 	err = os.WriteFile(yamlFile, []byte(yamlContent), 0644)
 	assert.NoError(t, err)
 
@@ -378,6 +378,23 @@ func TestPublishTSLToFile_InvalidPath(t *testing.T) {
 
 	// Try to write to an invalid path (e.g., a directory that doesn't exist and can't be created)
 	invalidPath := "/proc/nonexistent/impossible/path/file.xml"
-	err = publishTSLToFile(pl, tsl, invalidPath, nil)
+	err = publishTSLToFile(pl, nil, tsl, invalidPath, nil)
+	assert.Error(t, err)
+}
+
+// TestAddProviderCertificates_UnknownField tests error when certificate metadata has a misspelled key
+func TestAddProviderCertificates_UnknownField(t *testing.T) {
+	tmpDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tmpDir, "cert.pem"), []byte("test cert"), 0644)
+	assert.NoError(t, err)
+	yamlContent := `serviceType: "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+service_names:
+  - language: en
+    value: "Test Service"`
+	err = os.WriteFile(filepath.Join(tmpDir, "cert.yaml"), []byte(yamlContent), 0644)
+	assert.NoError(t, err)
+
+	err = addProviderCertificates(tmpDir, &etsi119612.TSPType{})
 	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown field "service_names" at line 2, did you mean "serviceNames"?`)
 }
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/utils/x509util"
+)
+
+// clockCheckThreshold is how far the Date headers of TSL servers may deviate
+// from the system clock before a warning is logged, in nanoseconds; 0
+// disables the check.
+var clockCheckThreshold atomic.Int64
+
+// SetClockCheckThreshold enables checking the system clock against the Date
+// headers of the servers TSLs are fetched from: if a server's clock deviates
+// by more than threshold, the load step logs a warning. A skewed clock makes
+// valid certificates look expired or not yet valid, so trust decisions fail
+// for no visible reason. A threshold of 0 or less disables the check.
+func SetClockCheckThreshold(threshold time.Duration) {
+	clockCheckThreshold.Store(int64(max(threshold, 0)))
+}
+
+// serverDate is the Date header of a response, and when it was received.
+type serverDate struct {
+	url      string
+	date     time.Time
+	received time.Time
+}
+
+// offset returns how far the server clock was ahead of the system clock.
+// Date headers have a resolution of one second, which the offset ignores.
+func (d serverDate) offset() time.Duration {
+	return d.date.Sub(d.received.Truncate(time.Second))
+}
+
+// responseDate returns the Date header of resp, received at received.
+func responseDate(url string, resp *http.Response, received time.Time) (serverDate, bool) {
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return serverDate{}, false
+	}
+	return serverDate{url: url, date: date, received: received}, true
+}
+
+// checkClock warns if the system clock deviates from the clock of a server in
+// dates by more than the threshold set with SetClockCheckThreshold. It warns
+// once, for the largest deviation.
+func checkClock(pl *Pipeline, ctx *Context, dates []serverDate) {
+	threshold := time.Duration(clockCheckThreshold.Load())
+	if threshold <= 0 {
+		return
+	}
+	var worst *serverDate
+	for i, d := range dates {
+		if worst == nil || d.offset().Abs() > worst.offset().Abs() {
+			worst = &dates[i]
+		}
+	}
+	if worst == nil || worst.offset().Abs() <= threshold {
+		return
+	}
+	pl.Logger.Warn("System clock deviates from TSL server clock",
+		logging.F("url", worst.url),
+		logging.F("server_time", worst.date.UTC().Format(time.RFC3339)),
+		logging.F("offset", worst.offset().String()),
+		logging.F("threshold", threshold.String()))
+	ctx.AddWarning("system clock deviates from the clock of %s by %s", worst.url, worst.offset())
+}
+
+// checkTSLDates warns about a TSL whose ListIssueDateTime is in the future or
+// whose NextUpdate has passed at now, allowing for the clock skew set with
+// x509util.SetClockSkew. Such TSLs are still loaded: a list past its next
+// update is usually only late to be republished.
+func checkTSLDates(pl *Pipeline, ctx *Context, tsl *etsi119612.TSL, now time.Time) {
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil {
+		return
+	}
+	skew := x509util.ClockSkew()
+	if issued, ok := parseTSLTime(si.ListIssueDateTime); ok && issued.After(now.Add(skew)) {
+		pl.Logger.Warn("TSL is issued in the future",
+			logging.F("url", tsl.Source),
+			logging.F("issue_date", si.ListIssueDateTime))
+		ctx.AddWarning("TSL %s is issued in the future (%s)", tsl.Source, si.ListIssueDateTime)
+	}
+	if si.TslNextUpdate == nil {
+		return
+	}
+	if next, ok := parseTSLTime(si.TslNextUpdate.DateTime); ok && next.Before(now.Add(-skew)) {
+		pl.Logger.Warn("TSL is past its next update",
+			logging.F("url", tsl.Source),
+			logging.F("next_update", si.TslNextUpdate.DateTime))
+		ctx.AddWarning("TSL %s is past its next update (%s)", tsl.Source, si.TslNextUpdate.DateTime)
+	}
+}
+
+// parseTSLTime parses a date and time of a TSL.
+func parseTSLTime(s string) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	return t, err == nil
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/utils/x509util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTSLDates(t *testing.T) {
+	defer x509util.SetClockSkew(0)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	pl := createTestPipeline(nil)
+
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.Source = "https://tsl.example.com/se.xml"
+	si := tsl.StatusList.TslSchemeInformation
+	si.ListIssueDateTime = "2026-06-01T12:03:00Z"
+	si.TslNextUpdate = &etsi119612.NextUpdateType{DateTime: "2026-06-01T11:57:00Z"}
+
+	ctx := NewContext()
+	checkTSLDates(pl, ctx, tsl, now)
+	warnings, _ := ctx.takeStepStats()
+	assert.Equal(t, []string{
+		"TSL https://tsl.example.com/se.xml is issued in the future (2026-06-01T12:03:00Z)",
+		"TSL https://tsl.example.com/se.xml is past its next update (2026-06-01T11:57:00Z)",
+	}, warnings)
+
+	// Within the clock skew both dates are accepted
+	x509util.SetClockSkew(5 * time.Minute)
+	checkTSLDates(pl, ctx, tsl, now)
+	warnings, _ = ctx.takeStepStats()
+	assert.Empty(t, warnings)
+
+	// Dates that do not parse are not checked
+	si.ListIssueDateTime = "tomorrow"
+	si.TslNextUpdate = nil
+	checkTSLDates(pl, ctx, tsl, now.Add(24*time.Hour))
+	warnings, _ = ctx.takeStepStats()
+	assert.Empty(t, warnings)
+}
+
+func TestLoadTSL_ClockCheck(t *testing.T) {
+	defer SetClockCheckThreshold(0)
+	f := renderExtensionsTSL(t)
+	offset := time.Hour
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+		_, _ = w.Write(f.Data)
+	}))
+	defer server.Close()
+
+	load := func() []string {
+		ctx := NewContext()
+		_, err := LoadTSL(createTestPipeline(nil), ctx, server.URL+"/tsl.xml")
+		require.NoError(t, err)
+		warnings, _ := ctx.takeStepStats()
+		return warnings
+	}
+
+	// The check is disabled by default
+	assert.Empty(t, load())
+
+	SetClockCheckThreshold(time.Minute)
+	warnings := load()
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "system clock deviates from the clock of "+server.URL+"/tsl.xml")
+
+	offset = 10 * time.Second
+	assert.Empty(t, load())
+}
@@ -2,9 +2,11 @@ package pipeline
 
 import (
 	"crypto/x509"
+	"slices"
 	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/tslpool"
 	"github.com/SUNET/go-trust/pkg/utils"
 )
 
@@ -17,6 +19,35 @@ type Context struct {
 	CertPool        *x509.CertPool                // Certificate pool for trust verification
 	Data            map[string]any                // Data store for sharing information between pipeline steps
 	TSLFetchOptions *etsi119612.TSLFetchOptions   // Options for fetching Trust Status Lists
+
+	// Service information extensions parsed by LoadTSL, keyed by trust service
+	ServiceExtensions map[*etsi119612.TSPServiceType]*ServiceExtensions
+
+	// Content of the TSL extensions fetched by LoadTSL, which the TSL model
+	// does not retain, keyed by extension (see MarshalTSL)
+	Extensions map[*etsi119612.ExtensionType]*ExtensionContent
+
+	// Signature verification status of the TSLs fetched by LoadTSL with
+	// verify: enabled, including those it rejected
+	Signatures map[*etsi119612.TSL]*TSLSignature
+
+	// Documents of the TSLs fetched by LoadTSL, kept if enabled with
+	// SetRetainSourceDocuments
+	Documents map[*etsi119612.TSL]*SourceDocument
+
+	// Generation identifies the trust snapshot once the Context is installed
+	// by the API server; it is 0 for Contexts that were never installed and
+	// is not carried over by Copy.
+	Generation uint64
+
+	warnings      []string // Warnings reported by the current step (see AddWarning)
+	itemsReported *int     // Item count reported by the current step (see ReportItems)
+
+	sourceFetches []SourceFetch // TSL fetches made by the current step (see RecordSourceFetch)
+
+	pool *tslpool.Pool // Provenance of CertPool certificates by fingerprint (see AddPoolCertificate)
+
+	locks *lockOwner // Owner of the source and output locks of the current run (see lockOutput)
 }
 
 // EnsureTSLTrees ensures that the TSL tree stack is initialized.
@@ -140,7 +171,8 @@ func (ctx *Context) EnsureTSLFetchOptions() *Context {
 }
 
 // InitCertPool creates a new certificate pool in the context.
-// This replaces any existing certificate pool with a fresh, empty one.
+// This replaces any existing certificate pool, and the provenance recorded
+// for its certificates, with a fresh, empty one.
 //
 // This method is typically called before adding trusted certificates
 // from Trust Status Lists to build a new trust store.
@@ -149,12 +181,45 @@ func (ctx *Context) EnsureTSLFetchOptions() *Context {
 //   - The Context itself for method chaining
 func (ctx *Context) InitCertPool() *Context {
 	ctx.CertPool = x509.NewCertPool()
+	ctx.pool = nil
 	return ctx
 }
 
+// AllTSLs returns the TSLs in the legacy TSL stack together with the TSLs they
+// reference, each TSL once, in stack order.
+//
+// Returns:
+//   - A slice of TSLs, empty if none are loaded
+func (ctx *Context) AllTSLs() []*etsi119612.TSL {
+	if ctx == nil || ctx.TSLs == nil {
+		return nil
+	}
+
+	var tsls []*etsi119612.TSL
+	seen := make(map[*etsi119612.TSL]bool)
+	var add func(tsl *etsi119612.TSL)
+	add = func(tsl *etsi119612.TSL) {
+		if tsl == nil || seen[tsl] {
+			return
+		}
+		seen[tsl] = true
+		tsls = append(tsls, tsl)
+		for _, ref := range tsl.Referenced {
+			add(ref)
+		}
+	}
+	for _, tsl := range ctx.TSLs.ToSlice() {
+		add(tsl)
+	}
+	return tsls
+}
+
 // Copy creates a deep copy of the Context.
 // This is useful for pipeline steps that need to create a modified context
 // without affecting the original one, such as for testing or branching pipelines.
+// The copy starts with an empty certificate pool and shares the Data values
+// and TSLFetchOptions of the original; use Clone for a copy that keeps the
+// certificate pool and can be modified concurrently with the original.
 //
 // The copy includes:
 // - A new stack of TSL trees with the same trees
@@ -162,6 +227,7 @@ func (ctx *Context) InitCertPool() *Context {
 // - A new certificate pool with the same certificates (if present)
 // - A new Data map with the same contents
 // - The same TSLFetchOptions reference (since it's typically read-only)
+// - A new map of the same service extensions
 //
 // Returns:
 //   - A new Context instance with copied contents
@@ -203,9 +269,105 @@ func (ctx *Context) Copy() *Context {
 	// Share the TSLFetchOptions reference
 	newCtx.TSLFetchOptions = ctx.TSLFetchOptions
 
+	// The copy belongs to the same run
+	newCtx.locks = ctx.locks
+
+	// Copy the service extensions, which belong to the shared TSLs
+	if ctx.ServiceExtensions != nil {
+		newCtx.ServiceExtensions = make(map[*etsi119612.TSPServiceType]*ServiceExtensions, len(ctx.ServiceExtensions))
+		for svc, ext := range ctx.ServiceExtensions {
+			newCtx.ServiceExtensions[svc] = ext
+		}
+	}
+
+	// Copy the extension contents, which belong to the shared TSLs
+	if ctx.Extensions != nil {
+		newCtx.Extensions = make(map[*etsi119612.ExtensionType]*ExtensionContent, len(ctx.Extensions))
+		for ext, content := range ctx.Extensions {
+			newCtx.Extensions[ext] = content
+		}
+	}
+
+	// Copy the signature statuses, which belong to the shared TSLs
+	if ctx.Signatures != nil {
+		newCtx.Signatures = make(map[*etsi119612.TSL]*TSLSignature, len(ctx.Signatures))
+		for tsl, sig := range ctx.Signatures {
+			newCtx.Signatures[tsl] = sig
+		}
+	}
+
+	// Copy the source documents, which belong to the shared TSLs
+	if ctx.Documents != nil {
+		newCtx.Documents = make(map[*etsi119612.TSL]*SourceDocument, len(ctx.Documents))
+		for tsl, doc := range ctx.Documents {
+			newCtx.Documents[tsl] = doc
+		}
+	}
+
+	return newCtx
+}
+
+// Clone returns a copy of the Context that can be modified independently of,
+// and concurrently with, the original and any other clone. It is how the
+// background updater, parallel pipeline branches and tests derive a Context
+// from another without steps of one affecting the other.
+//
+// The clone has:
+//   - New stacks of TSL trees and TSLs holding the same trees and TSLs
+//   - A clone of the certificate pool and of the provenance of its certificates
+//   - A new Data map; filter lists (map[string][]string) and string slices are
+//     copied, other values are shared
+//   - A copy of the TSLFetchOptions, sharing only the HTTP client
+//   - New maps of the same service extensions, extension contents,
+//     signature statuses and source documents
+//
+// TSL documents, trees and service extensions are shared copy-on-write: steps
+// must replace them rather than modify them in place. The Generation and the
+// statistics of the current step are not carried over. The original must not
+// be modified while it is being cloned.
+//
+// Returns:
+//   - A new Context instance with cloned contents
+func (ctx *Context) Clone() *Context {
+	newCtx := ctx.Copy()
+	newCtx.Data = make(map[string]any, len(ctx.Data))
+	for k, v := range ctx.Data {
+		newCtx.Data[k] = cloneDataValue(v)
+	}
+
+	newCtx.CertPool = nil
+	if index := ctx.certPoolIndex(); index != nil {
+		newCtx.pool = index.Clone()
+		newCtx.CertPool = newCtx.pool.CertPool()
+	} else if ctx.CertPool != nil {
+		newCtx.CertPool = ctx.CertPool.Clone()
+	}
+
+	if ctx.TSLFetchOptions != nil {
+		opts := *ctx.TSLFetchOptions
+		opts.AcceptHeaders = slices.Clone(opts.AcceptHeaders)
+		newCtx.TSLFetchOptions = &opts
+	}
 	return newCtx
 }
 
+// cloneDataValue returns a copy of a Data value of a type that steps modify in
+// place, or v itself.
+func cloneDataValue(v any) any {
+	switch v := v.(type) {
+	case map[string][]string:
+		clone := make(map[string][]string, len(v))
+		for k, values := range v {
+			clone[k] = slices.Clone(values)
+		}
+		return clone
+	case []string:
+		return slices.Clone(v)
+	default:
+		return v
+	}
+}
+
 // NewContext creates a new pipeline context with initialized fields.
 // The returned Context has a pre-initialized TSL tree stack ready to use,
 // but no certificate pool (which should be created with InitCertPool when needed).
@@ -2,10 +2,13 @@ package pipeline
 
 import (
 	"crypto/x509"
+	"fmt"
+	"sync"
 	"testing"
 
 	etsi119612 "github.com/SUNET/g119612/pkg/etsi119612"
 	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/testutil"
 	"github.com/SUNET/go-trust/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -192,3 +195,72 @@ func TestContext_Copy_DeepCopy(t *testing.T) {
 		assert.NotSame(t, original.CertPool, copied.CertPool)
 	})
 }
+
+func TestContext_Clone(t *testing.T) {
+	ca, err := testutil.NewCA("Clone CA")
+	require.NoError(t, err)
+	other, err := testutil.NewCA("Other CA")
+	require.NoError(t, err)
+	pl := createTestPipeline(nil)
+
+	original := NewContext()
+	original.AddTSL(&etsi119612.TSL{Source: "https://tsl.example.com/se.xml"})
+	original.AddPoolCertificate(ca.Certificate, CertProvenance{Source: "https://tsl.example.com/se.xml"})
+	original.Generation = 5
+	_, err = SetFetchOptions(pl, original, "filter-territory:SE", "user-agent:Original/1.0")
+	require.NoError(t, err)
+
+	clone := original.Clone()
+	assert.Zero(t, clone.Generation)
+	assert.Equal(t, original.TSLs.Size(), clone.TSLs.Size())
+	assert.Equal(t, original.TSLTrees.Size(), clone.TSLTrees.Size())
+	require.NotNil(t, clone.CertPool)
+	assert.True(t, clone.CertPool.Equal(original.CertPool))
+	assert.Len(t, clone.CertProvenance(ca.Certificate), 1)
+	assert.Equal(t, "Original/1.0", clone.TSLFetchOptions.UserAgent)
+
+	// Modifying the clone leaves the original as it was
+	_, err = SetFetchOptions(pl, clone, "filter-territory:DE", "user-agent:Clone/1.0")
+	require.NoError(t, err)
+	clone.AddPoolCertificate(other.Certificate, CertProvenance{Source: "https://tsl.example.com/de.xml"})
+	clone.AddPoolCertificate(ca.Certificate, CertProvenance{Source: "https://tsl.example.com/de.xml"})
+	clone.AddTSL(&etsi119612.TSL{Source: "https://tsl.example.com/de.xml"})
+
+	assert.Equal(t, []string{"SE"}, original.Data["tsl_filters"].(map[string][]string)["territory"])
+	assert.Equal(t, "Original/1.0", original.TSLFetchOptions.UserAgent)
+	assert.Nil(t, original.PoolCertificate(Fingerprint(other.Certificate)))
+	assert.Len(t, original.CertProvenance(ca.Certificate), 1)
+	assert.Len(t, clone.CertProvenance(ca.Certificate), 2)
+	assert.False(t, clone.CertPool.Equal(original.CertPool))
+	assert.Less(t, original.TSLs.Size(), clone.TSLs.Size())
+
+	// A Context without a pool clones to one without a pool
+	assert.Nil(t, NewContext().Clone().CertPool)
+}
+
+func TestContext_Clone_Concurrent(t *testing.T) {
+	pl := createTestPipeline(nil)
+	original := NewContext()
+	_, err := SetFetchOptions(pl, original, "filter-territory:SE")
+	require.NoError(t, err)
+	original.InitCertPool()
+
+	// Branches modify their clones concurrently; run with -race to check
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			branch := original.Clone()
+			_, _ = SetFetchOptions(pl, branch, fmt.Sprintf("filter-territory:T%d", i), "timeout:1s")
+			ca, err := testutil.NewCA(fmt.Sprintf("Branch %d", i))
+			if assert.NoError(t, err) {
+				branch.AddPoolCertificate(ca.Certificate, CertProvenance{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, []string{"SE"}, original.Data["tsl_filters"].(map[string][]string)["territory"])
+	assert.Empty(t, original.PoolCertificates())
+}
@@ -183,3 +183,36 @@ func NewPipelineStepError(stepName string, stepIndex int, args []string, err err
 		Err:       err,
 	}
 }
+
+// StepPanicError represents a panic that was recovered while executing a pipeline step.
+// The panic value and the goroutine stack at the time of the panic are preserved so
+// that the failure can be diagnosed without taking down the process.
+type StepPanicError struct {
+	StepName  string      // Name of the pipeline step
+	StepIndex int         // Index of the step in the pipeline
+	Value     interface{} // The value passed to panic()
+	Stack     []byte      // Stack trace captured when the panic was recovered
+}
+
+func (e *StepPanicError) Error() string {
+	return fmt.Sprintf("step %d (%s) panicked: %v", e.StepIndex, e.StepName, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error, so that errors.Is and errors.As
+// can inspect panics raised with an error value.
+func (e *StepPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// NewStepPanicError creates a new StepPanicError.
+func NewStepPanicError(stepName string, stepIndex int, value interface{}, stack []byte) *StepPanicError {
+	return &StepPanicError{
+		StepName:  stepName,
+		StepIndex: stepIndex,
+		Value:     value,
+		Stack:     stack,
+	}
+}
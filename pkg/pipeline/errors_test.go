@@ -217,6 +217,26 @@ func TestPipelineStepError(t *testing.T) {
 	})
 }
 
+func TestStepPanicError(t *testing.T) {
+	t.Run("String value", func(t *testing.T) {
+		err := NewStepPanicError("load", 1, "something broke", []byte("goroutine 1 [running]:"))
+
+		assert.Contains(t, err.Error(), "step 1")
+		assert.Contains(t, err.Error(), "load")
+		assert.Contains(t, err.Error(), "panicked")
+		assert.Contains(t, err.Error(), "something broke")
+		assert.Nil(t, errors.Unwrap(err))
+		assert.Equal(t, "goroutine 1 [running]:", string(err.Stack))
+	})
+
+	t.Run("Error value", func(t *testing.T) {
+		baseErr := errors.New("index out of range")
+		err := NewStepPanicError("select", 0, baseErr, nil)
+
+		assert.ErrorIs(t, err, baseErr)
+	})
+}
+
 // Test error wrapping and unwrapping chains
 func TestErrorChaining(t *testing.T) {
 	t.Run("Nested error unwrapping", func(t *testing.T) {
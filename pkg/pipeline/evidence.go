@@ -0,0 +1,267 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+)
+
+// ctx.Data keys of the evidence fetch options
+const (
+	evidenceDirKey       = "evidence_dir"
+	evidenceRetentionKey = "evidence_retention"
+)
+
+// EvidenceRecord describes one fetch of a TSL document archived in an
+// EvidenceStore: where and when it was fetched, what the server answered,
+// and whether its signature verified. The document itself is stored under
+// its SHA-256 digest.
+type EvidenceRecord struct {
+	URL            string            `json:"url"`                       // Requested URL
+	FetchedAt      time.Time         `json:"fetched_at"`                // When the request was made
+	StatusCode     int               `json:"status_code"`               // HTTP status code of the response
+	Headers        http.Header       `json:"headers,omitempty"`         // HTTP headers of the response
+	Digest         string            `json:"sha256"`                    // Hex SHA-256 of the document, its key in the store
+	Size           int64             `json:"size"`                      // Length of the document in bytes
+	Cached         bool              `json:"cached,omitempty"`          // A freshness probe found the earlier download unchanged
+	Territory      string            `json:"territory,omitempty"`       // Scheme territory of the TSL
+	SequenceNumber string            `json:"sequence_number,omitempty"` // Sequence number of the TSL
+	Signature      EvidenceSignature `json:"signature"`                 // Result of verifying the document's signature
+}
+
+// EvidenceSignature is the result of verifying the XML-DSIG signature of an
+// archived document against the certificate it carries. Whether that
+// certificate is trusted is decided separately, for example by the list of
+// lists pointing to the TSL.
+type EvidenceSignature struct {
+	Signed            bool   `json:"signed"`                       // The document carries an enveloped signature
+	Valid             bool   `json:"valid"`                        // The signature verified
+	Signer            string `json:"signer,omitempty"`             // Subject of the signing certificate
+	SignerFingerprint string `json:"signer_fingerprint,omitempty"` // Hex SHA-256 fingerprint of the signing certificate
+	Error             string `json:"error,omitempty"`              // Why the signature did not verify
+}
+
+// EvidenceStore is a content-addressed archive of fetched TSL documents, so
+// that disputes about what was trusted at a given time can be resolved later.
+// Documents are stored once under objects/, named by their SHA-256 digest,
+// and every fetch adds a record under records/, grouped by day. Records older
+// than the retention period are removed by Prune, together with the
+// documents no remaining record refers to.
+type EvidenceStore struct {
+	Dir       string        // Root directory of the store
+	Retention time.Duration // How long records are kept; 0 keeps them forever
+}
+
+// NewEvidenceStore returns an evidence store in dir.
+func NewEvidenceStore(dir string, retention time.Duration) *EvidenceStore {
+	return &EvidenceStore{Dir: dir, Retention: retention}
+}
+
+// evidenceStore returns the evidence store configured on ctx with the
+// evidence-dir: fetch option, or nil.
+func evidenceStore(ctx *Context) *EvidenceStore {
+	dir, _ := ctx.Data[evidenceDirKey].(string)
+	if dir == "" {
+		return nil
+	}
+	retention, _ := ctx.Data[evidenceRetentionKey].(time.Duration)
+	return NewEvidenceStore(dir, retention)
+}
+
+// Archive stores data, fetched from url at fetchedAt with the response
+// status code and header, and records the fetch. The signature of data is
+// verified and the result recorded.
+func (s *EvidenceStore) Archive(url string, fetchedAt time.Time, statusCode int, header http.Header, data []byte, cached bool) (*EvidenceRecord, error) {
+	sum := sha256.Sum256(data)
+	record := &EvidenceRecord{
+		URL:        url,
+		FetchedAt:  fetchedAt.UTC(),
+		StatusCode: statusCode,
+		Headers:    header,
+		Digest:     hex.EncodeToString(sum[:]),
+		Size:       int64(len(data)),
+		Cached:     cached,
+	}
+	if outline, err := outlinePublished(data); err != nil {
+		record.Signature.Error = err.Error()
+	} else {
+		record.Territory = outline.Territory
+		record.SequenceNumber = outline.SequenceNumber
+		record.Signature = verifyEvidence(data)
+	}
+
+	object := s.objectPath(record.Digest)
+	if _, err := os.Stat(object); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(object), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create evidence directory: %w", err)
+		}
+		if err := writeFileAtomic(object, data); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to check evidence object: %w", err)
+	}
+
+	recordJSON, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode evidence record: %w", err)
+	}
+	path := s.recordPath(record)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create evidence directory: %w", err)
+	}
+	if err := writeFileAtomic(path, append(recordJSON, '\n')); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Object returns the document stored under the hex SHA-256 digest.
+func (s *EvidenceStore) Object(digest string) ([]byte, error) {
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+		return nil, fmt.Errorf("invalid digest %q", digest)
+	}
+	return os.ReadFile(s.objectPath(digest))
+}
+
+// Records returns the records of the store, oldest first.
+func (s *EvidenceStore) Records() ([]*EvidenceRecord, error) {
+	var records []*EvidenceRecord
+	err := s.walkRecords(func(path string, record *EvidenceRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(records, func(a, b *EvidenceRecord) int {
+		return a.FetchedAt.Compare(b.FetchedAt)
+	})
+	return records, nil
+}
+
+// At returns the most recent record of a successful fetch of url made at or
+// before t: the document that was in use at time t. It returns nil if there
+// is none.
+func (s *EvidenceStore) At(url string, t time.Time) (*EvidenceRecord, error) {
+	records, err := s.Records()
+	if err != nil {
+		return nil, err
+	}
+	var found *EvidenceRecord
+	for _, record := range records {
+		if record.URL == url && record.StatusCode == http.StatusOK && !record.FetchedAt.After(t) {
+			found = record
+		}
+	}
+	return found, nil
+}
+
+// Prune removes the records older than the retention period at now, and the
+// documents no remaining record refers to. It returns the number of records
+// and documents removed.
+func (s *EvidenceStore) Prune(now time.Time) (records, objects int, err error) {
+	if s.Retention <= 0 {
+		return 0, 0, nil
+	}
+	cutoff := now.Add(-s.Retention)
+	referenced := make(map[string]bool)
+	err = s.walkRecords(func(path string, record *EvidenceRecord) error {
+		if record.FetchedAt.Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove evidence record: %w", err)
+			}
+			records++
+			// Remove the day directory once it is empty
+			_ = os.Remove(filepath.Dir(path))
+			return nil
+		}
+		referenced[record.Digest] = true
+		return nil
+	})
+	if err != nil {
+		return records, 0, err
+	}
+
+	err = filepath.WalkDir(filepath.Join(s.Dir, "objects"), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || referenced[d.Name()] || strings.HasSuffix(d.Name(), ".tmp") {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove evidence object: %w", err)
+		}
+		objects++
+		_ = os.Remove(filepath.Dir(path))
+		return nil
+	})
+	return records, objects, err
+}
+
+// walkRecords calls fn for every record of the store.
+func (s *EvidenceStore) walkRecords(fn func(path string, record *EvidenceRecord) error) error {
+	return filepath.WalkDir(filepath.Join(s.Dir, "records"), func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read evidence record: %w", err)
+		}
+		var record EvidenceRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return fmt.Errorf("failed to parse evidence record %s: %w", path, err)
+		}
+		return fn(path, &record)
+	})
+}
+
+// objectPath returns where the document with the hex SHA-256 digest is stored.
+func (s *EvidenceStore) objectPath(digest string) string {
+	return filepath.Join(s.Dir, "objects", digest[:2], digest)
+}
+
+// recordPath returns where record is stored: under the day of the fetch,
+// named by the time of the fetch, the URL and the document.
+func (s *EvidenceStore) recordPath(record *EvidenceRecord) string {
+	urlSum := sha256.Sum256([]byte(record.URL))
+	name := fmt.Sprintf("%s-%s-%s.json",
+		record.FetchedAt.Format("20060102T150405.000000000Z"),
+		hex.EncodeToString(urlSum[:4]),
+		record.Digest[:16])
+	return filepath.Join(s.Dir, "records", record.FetchedAt.Format("2006-01-02"), name)
+}
+
+// verifyEvidence verifies the signature of an archived XML document.
+func verifyEvidence(data []byte) EvidenceSignature {
+	cert, err := dsig.VerifyXML(data)
+	switch {
+	case errors.Is(err, dsig.ErrNotSigned):
+		return EvidenceSignature{}
+	case err != nil:
+		return EvidenceSignature{Signed: true, Error: err.Error()}
+	default:
+		return EvidenceSignature{
+			Signed:            true,
+			Valid:             true,
+			Signer:            cert.Subject.String(),
+			SignerFingerprint: Fingerprint(cert),
+		}
+	}
+}
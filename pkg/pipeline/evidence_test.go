@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedTestTSL returns the XML of a TSL and the same TSL signed.
+func signedTestTSL(t *testing.T) (unsigned, signed []byte) {
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	keyFile := filepath.Join(certDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	tsl, _ := dryRunContext(7, statusGranted).TSLs.Peek()
+	unsigned, err := xml.Marshal(tsl.StatusList)
+	require.NoError(t, err)
+	signed, err = dsig.NewFileSigner(certFile, keyFile).Sign(unsigned)
+	require.NoError(t, err)
+	return unsigned, signed
+}
+
+func TestEvidenceStore_Archive(t *testing.T) {
+	unsigned, signed := signedTestTSL(t)
+	store := NewEvidenceStore(t.TempDir(), 0)
+	fetched := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	header := http.Header{"Last-Modified": {"Sun, 01 Mar 2026 00:00:00 GMT"}}
+
+	record, err := store.Archive("https://tsl.example.com/se.xml", fetched, http.StatusOK, header, signed, false)
+	require.NoError(t, err)
+	assert.Len(t, record.Digest, 64)
+	assert.Equal(t, int64(len(signed)), record.Size)
+	assert.Equal(t, "SE", record.Territory)
+	assert.Equal(t, "7", record.SequenceNumber)
+	assert.True(t, record.Signature.Signed)
+	assert.True(t, record.Signature.Valid)
+	assert.Contains(t, record.Signature.Signer, "Test Certificate")
+	assert.Len(t, record.Signature.SignerFingerprint, 64)
+
+	data, err := store.Object(record.Digest)
+	require.NoError(t, err)
+	assert.Equal(t, signed, data)
+
+	// The same document fetched again is stored once
+	_, err = store.Archive("https://tsl.example.com/se.xml", fetched.Add(time.Hour), http.StatusOK, header, signed, true)
+	require.NoError(t, err)
+	objects, err := filepath.Glob(filepath.Join(store.Dir, "objects", "*", "*"))
+	require.NoError(t, err)
+	assert.Len(t, objects, 1)
+
+	unsignedRecord, err := store.Archive("https://tsl.example.com/se.xml", fetched.Add(2*time.Hour), http.StatusOK, nil, unsigned, false)
+	require.NoError(t, err)
+	assert.False(t, unsignedRecord.Signature.Signed)
+	assert.False(t, unsignedRecord.Signature.Valid)
+
+	records, err := store.Records()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.True(t, records[1].Cached)
+	assert.Equal(t, "Sun, 01 Mar 2026 00:00:00 GMT", records[0].Headers.Get("Last-Modified"))
+
+	// The document in use at a given time
+	at, err := store.At("https://tsl.example.com/se.xml", fetched.Add(90*time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, at)
+	assert.Equal(t, fetched.Add(time.Hour), at.FetchedAt)
+	at, err = store.At("https://tsl.example.com/se.xml", fetched.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, at)
+
+	_, err = store.Object("../../etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestEvidenceStore_Prune(t *testing.T) {
+	unsigned, signed := signedTestTSL(t)
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := NewEvidenceStore(t.TempDir(), 30*24*time.Hour)
+
+	old, err := store.Archive("https://tsl.example.com/se.xml", now.Add(-60*24*time.Hour), http.StatusOK, nil, unsigned, false)
+	require.NoError(t, err)
+	shared, err := store.Archive("https://tsl.example.com/se.xml", now.Add(-40*24*time.Hour), http.StatusOK, nil, signed, false)
+	require.NoError(t, err)
+	_, err = store.Archive("https://tsl.example.com/se.xml", now.Add(-time.Hour), http.StatusOK, nil, signed, false)
+	require.NoError(t, err)
+
+	records, objects, err := store.Prune(now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, records)
+	assert.Equal(t, 1, objects, "the document of the recent record is kept")
+
+	_, err = store.Object(old.Digest)
+	assert.True(t, os.IsNotExist(err))
+	_, err = store.Object(shared.Digest)
+	assert.NoError(t, err)
+	remaining, err := store.Records()
+	require.NoError(t, err)
+	assert.Len(t, remaining, 1)
+
+	// Without a retention period nothing is removed
+	store.Retention = 0
+	records, objects, err = store.Prune(now.Add(365 * 24 * time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, records+objects)
+}
+
+func TestLoadTSL_Evidence(t *testing.T) {
+	f := renderExtensionsTSL(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Sun, 01 Mar 2026 00:00:00 GMT")
+		_, _ = w.Write(f.Data)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	pl := createTestPipeline(nil)
+	ctx, err := SetFetchOptions(pl, NewContext(), "evidence-dir:"+dir, "evidence-retention:365d")
+	require.NoError(t, err)
+	_, err = LoadTSL(pl, ctx, server.URL+"/tsl.xml")
+	require.NoError(t, err)
+
+	records, err := NewEvidenceStore(dir, 0).Records()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, server.URL+"/tsl.xml", records[0].URL)
+	assert.Equal(t, http.StatusOK, records[0].StatusCode)
+	assert.Equal(t, "Sun, 01 Mar 2026 00:00:00 GMT", records[0].Headers.Get("Last-Modified"))
+	assert.False(t, records[0].Signature.Signed)
+	assert.False(t, records[0].FetchedAt.IsZero())
+
+	_, err = SetFetchOptions(pl, NewContext(), "evidence-retention:forever")
+	assert.Error(t, err)
+}
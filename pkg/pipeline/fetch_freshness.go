@@ -0,0 +1,178 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FreshnessProbe selects how the load step checks whether a TSL it fetched
+// before has changed, before downloading it again.
+type FreshnessProbe string
+
+const (
+	// FreshnessOff always downloads the TSL.
+	FreshnessOff FreshnessProbe = "off"
+	// FreshnessHead sends a HEAD request.
+	FreshnessHead FreshnessProbe = "head"
+	// FreshnessRange sends a GET request for the first byte, for servers
+	// that do not answer HEAD requests properly.
+	FreshnessRange FreshnessProbe = "range"
+)
+
+// parseFreshnessProbe parses the value of a freshness: option.
+func parseFreshnessProbe(value string) (FreshnessProbe, error) {
+	switch probe := FreshnessProbe(strings.ToLower(value)); probe {
+	case FreshnessOff, FreshnessHead, FreshnessRange:
+		return probe, nil
+	default:
+		return "", fmt.Errorf("invalid freshness probe %q: must be head, range or off", value)
+	}
+}
+
+// fetchCacheEntry is a TSL document downloaded by the load step, with the
+// validators its server sent for it.
+type fetchCacheEntry struct {
+	lastModified string      // Last-Modified of the response
+	length       int64       // Length of the document in bytes
+	header       http.Header // Headers of the response
+	body         []byte      // The document
+}
+
+// unchanged reports whether a probe answered with lastModified and length,
+// where length is -1 if unknown, describes the cached document. Documents
+// without Last-Modified are never considered unchanged.
+func (e *fetchCacheEntry) unchanged(lastModified string, length int64) bool {
+	if lastModified == "" || lastModified != e.lastModified {
+		return false
+	}
+	return length < 0 || length == e.length
+}
+
+// response returns a response to req serving the cached document.
+func (e *fetchCacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: e.length,
+		Request:       req,
+	}
+}
+
+// fetchCache keeps the TSL documents downloaded with freshness probing
+// enabled, so that later runs of the pipeline can skip unchanged documents.
+type fetchCache struct {
+	mu      sync.RWMutex
+	entries map[string]*fetchCacheEntry
+}
+
+// Global cache of downloaded TSL documents, shared by the pipeline runs of
+// the process
+var globalFetchCache = &fetchCache{
+	entries: make(map[string]*fetchCacheEntry),
+}
+
+// get returns the cached document for url.
+func (c *fetchCache) get(url string) (*fetchCacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[url]
+	return entry, ok
+}
+
+// store caches body, downloaded from url with resp. Documents without
+// Last-Modified cannot be probed and are not cached.
+func (c *fetchCache) store(url string, resp *http.Response, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lastModified := resp.Header.Get("Last-Modified")
+	if lastModified == "" {
+		delete(c.entries, url)
+		return
+	}
+	header := resp.Header.Clone()
+	// The body is stored decoded, whatever the transport received
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	c.entries[url] = &fetchCacheEntry{
+		lastModified: lastModified,
+		length:       int64(len(body)),
+		header:       header,
+		body:         body,
+	}
+}
+
+// clear removes all entries from the cache (useful for testing)
+func (c *fetchCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*fetchCacheEntry)
+}
+
+// probeLength returns the length of the full document from the response to
+// a probe, or -1 if the server did not say.
+func probeLength(resp *http.Response) int64 {
+	if resp.StatusCode != http.StatusPartialContent {
+		return resp.ContentLength
+	}
+	// Content-Range: bytes 0-0/12345
+	_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	if !ok {
+		return -1
+	}
+	length, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return length
+}
+
+// fetch sends req, probing first whether the document cached for it is still
+// current. It returns the response and whether it was served from the cache.
+// A probe that fails or is not understood falls back to a full download.
+func (t *captureTransport) fetch(req *http.Request) (*http.Response, bool, error) {
+	if t.freshness == FreshnessOff || t.freshness == "" || req.Method != http.MethodGet {
+		resp, err := t.base.RoundTrip(req)
+		return resp, false, err
+	}
+	entry, ok := t.cache.get(req.URL.String())
+	if !ok {
+		resp, err := t.base.RoundTrip(req)
+		return resp, false, err
+	}
+
+	probe := req.Clone(req.Context())
+	if t.freshness == FreshnessHead {
+		probe.Method = http.MethodHead
+	} else {
+		probe.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := t.base.RoundTrip(probe)
+	if err != nil {
+		resp, err := t.base.RoundTrip(req)
+		return resp, false, err
+	}
+	if t.freshness == FreshnessRange && resp.StatusCode == http.StatusOK {
+		// The server ignored the range and sent the whole document
+		resp.Request = req
+		return resp, false, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if (resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent) &&
+		entry.unchanged(resp.Header.Get("Last-Modified"), probeLength(resp)) {
+		return entry.response(req), true, nil
+	}
+	resp, err = t.base.RoundTrip(req)
+	return resp, false, err
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freshnessServer serves a TSL with Last-Modified and counts the requests
+// by kind.
+type freshnessServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	data     []byte
+	modified time.Time
+	requests map[string]int // "GET", "HEAD" or "RANGE"
+}
+
+func newFreshnessServer(t *testing.T, data []byte) *freshnessServer {
+	s := &freshnessServer{
+		data:     data,
+		modified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		requests: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		kind := r.Method
+		if r.Header.Get("Range") != "" {
+			kind = "RANGE"
+		}
+		s.requests[kind]++
+		http.ServeContent(w, r, "tsl.xml", s.modified, bytes.NewReader(s.data))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *freshnessServer) count(kind string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[kind]
+}
+
+func (s *freshnessServer) update(data []byte, modified time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.modified = data, modified
+}
+
+func TestLoadTSL_Freshness(t *testing.T) {
+	data := renderExtensionsTSL(t).Data
+
+	for _, probe := range []FreshnessProbe{FreshnessHead, FreshnessRange} {
+		t.Run(string(probe), func(t *testing.T) {
+			globalFetchCache.clear()
+			defer globalFetchCache.clear()
+			server := newFreshnessServer(t, data)
+			url := server.URL + "/tsl.xml"
+			kind := map[FreshnessProbe]string{FreshnessHead: "HEAD", FreshnessRange: "RANGE"}[probe]
+
+			pl := createTestPipeline(nil)
+			_, err := LoadTSL(pl, NewContext(), url, "freshness:"+string(probe))
+			require.NoError(t, err)
+			assert.Equal(t, 1, server.count("GET"))
+			assert.Equal(t, 0, server.count(kind), "nothing to probe on the first fetch")
+
+			// Unchanged: probed, not downloaded
+			ctx, err := LoadTSL(pl, NewContext(), url, "freshness:"+string(probe))
+			require.NoError(t, err)
+			assert.Equal(t, 1, server.count("GET"))
+			assert.Equal(t, 1, server.count(kind))
+			require.Equal(t, 1, ctx.TSLs.Size())
+			fetches := ctx.takeSourceFetches()
+			require.Len(t, fetches, 1)
+			assert.True(t, fetches[0].Cached)
+			assert.False(t, fetches[0].Failed())
+
+			// A new Last-Modified means a new download
+			server.update(data, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+			ctx, err = LoadTSL(pl, NewContext(), url, "freshness:"+string(probe))
+			require.NoError(t, err)
+			assert.Equal(t, 2, server.count("GET"))
+			assert.False(t, ctx.takeSourceFetches()[0].Cached)
+
+			// So does a new length with the same Last-Modified
+			server.update(append(bytes.Clone(data), '\n'), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+			_, err = LoadTSL(pl, NewContext(), url, "freshness:"+string(probe))
+			require.NoError(t, err)
+			assert.Equal(t, 3, server.count("GET"))
+		})
+	}
+}
+
+func TestLoadTSL_FreshnessOff(t *testing.T) {
+	globalFetchCache.clear()
+	defer globalFetchCache.clear()
+	server := newFreshnessServer(t, renderExtensionsTSL(t).Data)
+
+	pl := createTestPipeline(nil)
+	for range 2 {
+		_, err := LoadTSL(pl, NewContext(), server.URL+"/tsl.xml")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, server.count("GET"))
+	assert.Equal(t, 0, server.count("HEAD"))
+
+	_, err := LoadTSL(pl, NewContext(), server.URL+"/tsl.xml", "freshness:sometimes")
+	assert.Error(t, err)
+}
+
+func TestFetchCacheEntry_Unchanged(t *testing.T) {
+	entry := &fetchCacheEntry{lastModified: "Thu, 01 Jan 2026 00:00:00 GMT", length: 100}
+
+	assert.True(t, entry.unchanged("Thu, 01 Jan 2026 00:00:00 GMT", 100))
+	assert.True(t, entry.unchanged("Thu, 01 Jan 2026 00:00:00 GMT", -1), "unknown lengths are not compared")
+	assert.False(t, entry.unchanged("Thu, 01 Jan 2026 00:00:00 GMT", 101))
+	assert.False(t, entry.unchanged("Sun, 01 Feb 2026 00:00:00 GMT", 100))
+	assert.False(t, entry.unchanged("", 100))
+}
+
+func TestProbeLength(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusPartialContent, Header: http.Header{}}
+	resp.Header.Set("Content-Range", "bytes 0-0/12345")
+	assert.Equal(t, int64(12345), probeLength(resp))
+	resp.Header.Set("Content-Range", "bytes 0-0/*")
+	assert.Equal(t, int64(-1), probeLength(resp))
+
+	assert.Equal(t, int64(42), probeLength(&http.Response{StatusCode: http.StatusOK, ContentLength: 42}))
+}
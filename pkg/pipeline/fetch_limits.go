@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultMaxDownloadSize is the largest TSL document the load step downloads
+// unless SetMaxDownloadSize or the max-size: fetch option says otherwise.
+// Real TSLs are a few megabytes at most.
+const DefaultMaxDownloadSize int64 = 100 << 20
+
+// DefaultMaxReferencedTSLs is the most TSLs the load step fetches by
+// following the references of one root TSL, unless SetMaxReferencedTSLs or
+// the max-referenced: fetch option says otherwise. The EU LOTL references
+// about 30.
+const DefaultMaxReferencedTSLs = 200
+
+// maxDownloadSizeKey is the ctx.Data key of the max-size: fetch option.
+const maxDownloadSizeKey = "max_download_size"
+
+// maxReferencedKey is the ctx.Data key of the max-referenced: fetch option.
+const maxReferencedKey = "max_referenced_tsls"
+
+// ErrDownloadTooLarge is returned for TSL documents larger than the maximum
+// download size.
+var ErrDownloadTooLarge = errors.New("download exceeds the maximum size")
+
+// downloadLimits holds the process-wide limits on TSL downloads.
+type downloadLimits struct {
+	mu            sync.RWMutex
+	maxSize       int64         // Largest document downloaded
+	maxReferenced int           // Most TSLs fetched by following references
+	limiter       *rate.Limiter // Shared by all downloads; nil for no limit
+}
+
+// Global limits on TSL downloads
+var globalDownloadLimits = &downloadLimits{maxSize: DefaultMaxDownloadSize, maxReferenced: DefaultMaxReferencedTSLs}
+
+// SetMaxDownloadSize sets the largest TSL document, in bytes, that pipelines
+// download; larger responses are rejected. Pipelines can lower or raise it
+// with the max-size: fetch option. A size of 0 or less restores
+// DefaultMaxDownloadSize.
+func SetMaxDownloadSize(size int64) {
+	if size <= 0 {
+		size = DefaultMaxDownloadSize
+	}
+	globalDownloadLimits.mu.Lock()
+	defer globalDownloadLimits.mu.Unlock()
+	globalDownloadLimits.maxSize = size
+}
+
+// SetMaxReferencedTSLs sets the most TSLs that the load step fetches by
+// following the references of one root TSL, whatever the reference depth.
+// Pipelines can lower or raise it with the max-referenced: fetch option. A
+// limit of 0 or less restores DefaultMaxReferencedTSLs.
+func SetMaxReferencedTSLs(n int) {
+	if n <= 0 {
+		n = DefaultMaxReferencedTSLs
+	}
+	globalDownloadLimits.mu.Lock()
+	defer globalDownloadLimits.mu.Unlock()
+	globalDownloadLimits.maxReferenced = n
+}
+
+// SetDownloadRateLimit limits the combined rate, in bytes per second, at
+// which all pipelines of the process download TSL documents. A limit of 0 or
+// less removes the limit.
+func SetDownloadRateLimit(bytesPerSecond int64) {
+	globalDownloadLimits.mu.Lock()
+	defer globalDownloadLimits.mu.Unlock()
+	if bytesPerSecond <= 0 {
+		globalDownloadLimits.limiter = nil
+		return
+	}
+	// A burst of one second of downloading, so that reads are not split too finely
+	burst := int(min(bytesPerSecond, math.MaxInt32))
+	globalDownloadLimits.limiter = rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+}
+
+// maxDownloadSize returns the largest document ctx downloads.
+func maxDownloadSize(ctx *Context) int64 {
+	if ctx != nil {
+		if size, ok := ctx.Data[maxDownloadSizeKey].(int64); ok && size > 0 {
+			return size
+		}
+	}
+	globalDownloadLimits.mu.RLock()
+	defer globalDownloadLimits.mu.RUnlock()
+	return globalDownloadLimits.maxSize
+}
+
+// maxReferencedTSLs returns the most referenced TSLs a load step of ctx
+// fetches.
+func maxReferencedTSLs(ctx *Context) int {
+	if ctx != nil {
+		if n, ok := ctx.Data[maxReferencedKey].(int); ok && n > 0 {
+			return n
+		}
+	}
+	globalDownloadLimits.mu.RLock()
+	defer globalDownloadLimits.mu.RUnlock()
+	return globalDownloadLimits.maxReferenced
+}
+
+// downloadLimiter returns the process-wide download rate limiter, or nil.
+func downloadLimiter() *rate.Limiter {
+	globalDownloadLimits.mu.RLock()
+	defer globalDownloadLimits.mu.RUnlock()
+	return globalDownloadLimits.limiter
+}
+
+// readDownload reads the body of resp, at most maxSize bytes, at the
+// process-wide download rate. Responses that declare a larger Content-Length
+// are rejected before anything is read.
+func readDownload(resp *http.Response, maxSize int64) ([]byte, error) {
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrDownloadTooLarge, resp.ContentLength, maxSize)
+	}
+	var r io.Reader = io.LimitReader(resp.Body, maxSize+1)
+	if limiter := downloadLimiter(); limiter != nil {
+		ctx := context.Background()
+		if resp.Request != nil {
+			ctx = resp.Request.Context()
+		}
+		r = &rateLimitedReader{r: r, limiter: limiter, ctx: ctx}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: limit %d bytes", ErrDownloadTooLarge, maxSize)
+	}
+	return data, nil
+}
+
+// rateLimitedReader is an io.Reader that waits for the limiter before
+// returning what it read.
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter *rate.Limiter
+	ctx     context.Context
+}
+
+// Read implements io.Reader.
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// ParseByteSize parses a size in bytes with an optional KB, MB or GB suffix
+// (powers of 1024), such as "512KB" or "10MB".
+func ParseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if rest, ok := strings.CutSuffix(value, unit.suffix); ok {
+			value, multiplier = strings.TrimSpace(rest), unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1024", 1024},
+		{"512B", 512},
+		{"64kb", 64 << 10},
+		{"20MB", 20 << 20},
+		{" 2 GB ", 2 << 30},
+		{"0", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{"", "MB", "-1", "1.5MB", "10TB", "9999999999999GB"} {
+		_, err := ParseByteSize(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestLoadTSL_MaxDownloadSize(t *testing.T) {
+	data := renderExtensionsTSL(t).Data
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked.xml" {
+			// No Content-Length: the limit applies while reading
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	pl := createTestPipeline(nil)
+
+	ctx, err := LoadTSL(pl, NewContext(), server.URL+"/tsl.xml")
+	require.NoError(t, err)
+	fetches := ctx.takeSourceFetches()
+	require.Len(t, fetches, 1)
+	assert.Equal(t, int64(len(data)), fetches[0].Bytes)
+
+	for _, path := range []string{"/tsl.xml", "/chunked.xml"} {
+		ctx, err := SetFetchOptions(pl, NewContext(), "max-size:1KB")
+		require.NoError(t, err)
+		ctx, err = LoadTSL(pl, ctx, server.URL+path)
+		require.Error(t, err, path)
+		assert.True(t, errors.Is(err, ErrDownloadTooLarge), "%s: %v", path, err)
+		fetches := ctx.takeSourceFetches()
+		require.NotEmpty(t, fetches)
+		assert.True(t, fetches[0].Failed())
+	}
+
+	// The process-wide limit applies without the fetch option
+	SetMaxDownloadSize(1024)
+	defer SetMaxDownloadSize(0)
+	_, err = LoadTSL(pl, NewContext(), server.URL+"/tsl.xml")
+	assert.True(t, errors.Is(err, ErrDownloadTooLarge), "%v", err)
+
+	_, err = SetFetchOptions(pl, NewContext(), "max-size:lots")
+	assert.Error(t, err)
+	_, err = SetFetchOptions(pl, NewContext(), "max-size:0")
+	assert.Error(t, err)
+}
+
+func TestReadDownload_RateLimit(t *testing.T) {
+	SetDownloadRateLimit(64 << 10)
+	defer SetDownloadRateLimit(0)
+
+	// The first second of data is the burst; the rest waits for the limiter
+	body := bytes.Repeat([]byte("x"), 96<<10)
+	resp := &http.Response{Body: io.NopCloser(bytes.NewReader(body)), ContentLength: -1}
+	start := time.Now()
+	data, err := readDownload(resp, DefaultMaxDownloadSize)
+	require.NoError(t, err)
+	assert.Len(t, data, len(body))
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	// Without a limit, reading is not delayed
+	SetDownloadRateLimit(0)
+	resp = &http.Response{Body: io.NopCloser(strings.NewReader(string(body))), ContentLength: -1}
+	start = time.Now()
+	_, err = readDownload(resp, DefaultMaxDownloadSize)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 300*time.Millisecond)
+}
+
+func TestRunReport_BytesDownloaded(t *testing.T) {
+	report := &RunReport{Sources: []SourceFetch{{Bytes: 100}, {Cached: true}, {Bytes: 50}}}
+	assert.Equal(t, int64(150), report.BytesDownloaded())
+	assert.Equal(t, int64(0), (&RunReport{}).BytesDownloaded())
+}
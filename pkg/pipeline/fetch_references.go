@@ -0,0 +1,259 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// referenceWalk follows the pointers to other TSLs from a root TSL. It
+// remembers every TSL fetched, by location and by content, so that lists
+// pointing at themselves or at each other are fetched once, and it stops
+// after a fixed number of referenced TSLs however deep the references go.
+type referenceWalk struct {
+	pl            *Pipeline
+	ctx           *Context
+	opts          etsi119612.TSLFetchOptions
+	capture       *captureTransport
+	maxReferenced int
+	verifier      *signatureVerifier  // Verifies signatures, nil if not configured
+	visited       map[string]string   // Locations fetched or tried, by referenceKey
+	digests       map[[32]byte]string // Locations of the documents fetched, by SHA-256
+	loops         int                 // Pointers not followed since they lead back
+}
+
+// tslReference is a pointer to another TSL waiting to be followed.
+type tslReference struct {
+	from     *etsi119612.TSL
+	location string
+	depth    int
+	certs    []*x509.Certificate // Certificates the pointer says the TSL is signed with
+}
+
+// fetchTSLWithReferences fetches the TSL at location and, breadth first, the
+// TSLs it points to, up to opts.MaxDereferenceDepth levels (all levels if
+// negative) and at most maxReferenced referenced TSLs in all. A pointer to a
+// TSL that was already fetched, under the same location, another spelling
+// of it or a different location serving the same document, is a reference
+// loop and is not followed. Referenced TSLs that fail to load are logged and
+// skipped, as are the references beyond the limit, which are also reported as
+// a warning of the run.
+//
+// With a verifier, the signature of the root TSL is verified against the
+// certificates configured for it and the signature of each referenced TSL
+// against the certificates of the pointer that led to it. In SignatureEnforce
+// mode a root TSL that fails verification is an error, and a referenced TSL
+// that fails is skipped along with the TSLs it points to.
+//
+// The root TSL comes first in the returned slice, followed by the referenced
+// TSLs in the order fetched. Each referenced TSL is added to the Referenced
+// list of the TSL that first pointed to it, so the references form a tree.
+func fetchTSLWithReferences(pl *Pipeline, ctx *Context, location string, opts etsi119612.TSLFetchOptions, capture *captureTransport, maxReferenced int, verifier *signatureVerifier) ([]*etsi119612.TSL, error) {
+	root, err := etsi119612.FetchTSLWithOptions(location, opts)
+	if err != nil {
+		return nil, err
+	}
+	if verifier != nil {
+		if sig := verifier.check(pl, ctx, capture, root, verifier.anchors, "configuration"); !sig.Admitted {
+			return nil, fmt.Errorf("signature of %s not verified (%s): %s", location, sig.Status, sig.Error)
+		}
+	}
+
+	w := &referenceWalk{
+		pl:            pl,
+		ctx:           ctx,
+		opts:          opts,
+		capture:       capture,
+		maxReferenced: maxReferenced,
+		verifier:      verifier,
+		visited:       make(map[string]string),
+		digests:       make(map[[32]byte]string),
+	}
+	w.visited[referenceKey(location)] = location
+	w.remember(root)
+
+	tsls := []*etsi119612.TSL{root}
+	queue := w.pointers(root, 1)
+	for len(queue) > 0 {
+		ref := queue[0]
+		queue = queue[1:]
+		if w.seen(ref) {
+			continue
+		}
+		if len(tsls)-1 >= w.maxReferenced {
+			w.stop(location, ref, queue)
+			break
+		}
+
+		tsl := w.fetch(ref)
+		if tsl == nil || w.duplicate(ref, tsl) || !w.admit(ref, tsl) {
+			continue
+		}
+		ref.from.AddReferencedTSL(tsl)
+		tsls = append(tsls, tsl)
+		queue = append(queue, w.pointers(tsl, ref.depth+1)...)
+	}
+
+	if w.loops > 0 {
+		pl.Logger.Debug("Skipped TSL reference loops",
+			logging.F("url", location),
+			logging.F("loops", w.loops))
+	}
+	return tsls, nil
+}
+
+// pointers returns the references of tsl, at depth, that are within the
+// maximum dereference depth.
+func (w *referenceWalk) pointers(tsl *etsi119612.TSL, depth int) []tslReference {
+	if w.opts.MaxDereferenceDepth == 0 || (w.opts.MaxDereferenceDepth > 0 && depth > w.opts.MaxDereferenceDepth) {
+		return nil
+	}
+	info := tsl.StatusList.TslSchemeInformation
+	if info == nil || info.TslPointersToOtherTSL == nil {
+		return nil
+	}
+	var refs []tslReference
+	for _, p := range info.TslPointersToOtherTSL.TslOtherTSLPointer {
+		if p == nil || strings.TrimSpace(p.TSLLocation) == "" {
+			continue
+		}
+		refs = append(refs, tslReference{from: tsl, location: strings.TrimSpace(p.TSLLocation), depth: depth, certs: pointerCertificates(p)})
+	}
+	return refs
+}
+
+// seen reports whether ref points at a location fetched or tried before, and
+// marks it as tried otherwise.
+func (w *referenceWalk) seen(ref tslReference) bool {
+	key := referenceKey(ref.location)
+	if first, ok := w.visited[key]; ok {
+		w.loop(ref, first)
+		return true
+	}
+	w.visited[key] = ref.location
+	return false
+}
+
+// fetch fetches the TSL ref points at, trying the XML version of a PDF
+// location that fails. It returns nil if the TSL cannot be loaded.
+func (w *referenceWalk) fetch(ref tslReference) *etsi119612.TSL {
+	tsl, err := etsi119612.FetchTSLWithOptions(ref.location, w.opts)
+	if err != nil && strings.HasSuffix(strings.ToLower(ref.location), ".pdf") {
+		xmlLocation := ref.location[:len(ref.location)-len(".pdf")] + ".xml"
+		if first, ok := w.visited[referenceKey(xmlLocation)]; ok {
+			w.loop(ref, first)
+			return nil
+		}
+		w.visited[referenceKey(xmlLocation)] = xmlLocation
+		var xmlErr error
+		if tsl, xmlErr = etsi119612.FetchTSLWithOptions(xmlLocation, w.opts); xmlErr == nil {
+			err = nil
+			w.pl.Logger.Info("Fetched XML version of referenced TSL",
+				logging.F("url", ref.location),
+				logging.F("xml_url", xmlLocation))
+		}
+	}
+	if err != nil {
+		w.pl.Logger.Warn("Failed to fetch referenced TSL",
+			logging.F("url", ref.location),
+			logging.F("referenced_by", ref.from.Source),
+			logging.F("error", err.Error()))
+		return nil
+	}
+	return tsl
+}
+
+// duplicate reports whether tsl, fetched for ref, is a document already
+// fetched from another location, and remembers it otherwise.
+func (w *referenceWalk) duplicate(ref tslReference, tsl *etsi119612.TSL) bool {
+	body, err := w.capture.body(tsl.Source)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256(body)
+	if first, ok := w.digests[digest]; ok {
+		w.loop(ref, first)
+		return true
+	}
+	w.digests[digest] = tsl.Source
+	return false
+}
+
+// admit verifies the signature of tsl, fetched for ref, against the
+// certificates of ref if signatures are verified, and reports whether tsl is
+// admitted to the tree.
+func (w *referenceWalk) admit(ref tslReference, tsl *etsi119612.TSL) bool {
+	if w.verifier == nil {
+		return true
+	}
+	return w.verifier.check(w.pl, w.ctx, w.capture, tsl, ref.certs, ref.from.Source).Admitted
+}
+
+// remember records the content of tsl, so that other locations serving the
+// same document are recognized.
+func (w *referenceWalk) remember(tsl *etsi119612.TSL) {
+	if body, err := w.capture.body(tsl.Source); err == nil {
+		w.digests[sha256.Sum256(body)] = tsl.Source
+	}
+}
+
+// loop records a pointer that leads back to the TSL first fetched from first.
+func (w *referenceWalk) loop(ref tslReference, first string) {
+	w.loops++
+	w.pl.Logger.Debug("Not following TSL reference loop",
+		logging.F("url", ref.location),
+		logging.F("referenced_by", ref.from.Source),
+		logging.F("fetched_as", first))
+}
+
+// stop reports that the references from the root TSL at location were not all
+// followed, ref being the first pointer not followed and queue the rest.
+func (w *referenceWalk) stop(location string, ref tslReference, queue []tslReference) {
+	pending := map[string]bool{referenceKey(ref.location): true}
+	for _, next := range queue {
+		if _, ok := w.visited[referenceKey(next.location)]; !ok {
+			pending[referenceKey(next.location)] = true
+		}
+	}
+	w.pl.Logger.Warn("Too many referenced TSLs, not following the remaining references",
+		logging.F("url", location),
+		logging.F("max_referenced", w.maxReferenced),
+		logging.F("pending", len(pending)))
+	w.ctx.AddWarning("stopped following the references of %s after %d referenced TSLs, %d not fetched", location, w.maxReferenced, len(pending))
+}
+
+// referenceKey returns the form of a TSL location used to recognize the same
+// TSL under different spellings: file locations as absolute paths, and URLs
+// without fragment, default port, case differences in scheme and host, or
+// dot segments in the path.
+func referenceKey(location string) string {
+	if p, ok := strings.CutPrefix(location, "file://"); ok {
+		if abs, err := filepath.Abs(p); err == nil {
+			return "file://" + abs
+		}
+		return location
+	}
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" {
+		return location
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if u.Path != "" {
+		u.Path = path.Clean(u.Path)
+		u.RawPath = ""
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadedNames returns the scheme operator names of the TSLs loaded on ctx,
+// root first.
+func loadedNames(ctx *Context) []string {
+	var names []string
+	for _, tsl := range ctx.TSLs.ToSlice() {
+		names = append(names, string(*tsl.StatusList.TslSchemeInformation.TslSchemeOperatorName.Name[0].NonEmptyNormalizedString))
+	}
+	return names
+}
+
+func TestLoadTSL_ReferenceLoops(t *testing.T) {
+	pl := createTestPipeline(nil)
+
+	tests := []struct {
+		name  string
+		path  string
+		names []string
+	}{
+		{"self reference under other spellings", "testdata/loops/self.xml", []string{"Self"}},
+		{"cycle between three lists", "testdata/loops/a.xml", []string{"Cycle A", "Cycle B", "Cycle C"}},
+		{"same document at two locations", "testdata/loops/mirrors.xml", []string{"Mirrors", "Mirrored"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := SetFetchOptions(pl, NewContext(), "max-depth:-1")
+			require.NoError(t, err)
+			ctx, err = LoadTSL(pl, ctx, tt.path)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.names, loadedNames(ctx))
+			warnings, _ := ctx.takeStepStats()
+			assert.Empty(t, warnings)
+		})
+	}
+
+	// The cycle is loaded as a tree: A references B, which references C
+	ctx, err := SetFetchOptions(pl, NewContext(), "max-depth:-1")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, "testdata/loops/a.xml")
+	require.NoError(t, err)
+	tree, ok := ctx.TSLTrees.Peek()
+	require.True(t, ok)
+	root := tree.Root.TSL
+	require.Len(t, root.Referenced, 1)
+	require.Len(t, root.Referenced[0].Referenced, 1)
+	assert.Empty(t, root.Referenced[0].Referenced[0].Referenced)
+}
+
+// writeReferenceChain writes n TSLs to dir, each pointing at the next and,
+// from the second on, back at the first, and returns the path of the first.
+func writeReferenceChain(t *testing.T, dir string, n int) string {
+	t.Helper()
+	path := func(i int) string { return filepath.Join(dir, fmt.Sprintf("list-%d.xml", i)) }
+	for i := range n {
+		var pointers []string
+		for _, target := range []int{i + 1, 0} {
+			if target < n && target != i {
+				pointers = append(pointers, fmt.Sprintf(
+					"<tsl:OtherTSLPointer><tsl:TSLLocation>file://%s</tsl:TSLLocation></tsl:OtherTSLPointer>", path(target)))
+			}
+		}
+		doc := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#" xmlns:xml="http://www.w3.org/XML/1998/namespace">
+  <tsl:SchemeInformation>
+    <tsl:SchemeOperatorName><tsl:Name xml:lang="en">List %d</tsl:Name></tsl:SchemeOperatorName>
+    <tsl:PointersToOtherTSL>%s</tsl:PointersToOtherTSL>
+  </tsl:SchemeInformation>
+</tsl:TrustServiceStatusList>`, i, strings.Join(pointers, ""))
+		require.NoError(t, os.WriteFile(path(i), []byte(doc), 0600))
+	}
+	return path(0)
+}
+
+func TestLoadTSL_MaxReferenced(t *testing.T) {
+	pl := createTestPipeline(nil)
+	root := writeReferenceChain(t, t.TempDir(), 50)
+
+	// Unlimited depth is still bounded by the number of referenced TSLs
+	ctx, err := SetFetchOptions(pl, NewContext(), "max-depth:-1", "max-referenced:10")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, root)
+	require.NoError(t, err)
+	assert.Equal(t, 11, ctx.TSLs.Size())
+	warnings, _ := ctx.takeStepStats()
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "after 10 referenced TSLs, 1 not fetched")
+
+	// The process-wide limit applies without the fetch option
+	SetMaxReferencedTSLs(5)
+	defer SetMaxReferencedTSLs(0)
+	ctx, err = SetFetchOptions(pl, NewContext(), "max-depth:-1")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, root)
+	require.NoError(t, err)
+	assert.Equal(t, 6, ctx.TSLs.Size())
+
+	// The depth limit still applies below the cap
+	ctx, err = SetFetchOptions(pl, NewContext(), "max-depth:2", "max-referenced:100")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, root)
+	require.NoError(t, err)
+	assert.Equal(t, 3, ctx.TSLs.Size())
+	warnings, _ = ctx.takeStepStats()
+	assert.Empty(t, warnings)
+
+	for _, arg := range []string{"max-referenced:0", "max-referenced:-1", "max-referenced:many"} {
+		_, err = SetFetchOptions(pl, NewContext(), arg)
+		assert.Error(t, err, arg)
+	}
+}
+
+func TestFetchTSLWithReferences_TreeOrder(t *testing.T) {
+	pl := createTestPipeline(nil)
+	root := writeReferenceChain(t, t.TempDir(), 4)
+	opts, capture := captureFetchOptions(etsi119612.TSLFetchOptions{MaxDereferenceDepth: -1}, FreshnessOff, DefaultMaxDownloadSize)
+
+	tsls, err := fetchTSLWithReferences(pl, NewContext(), "file://"+root, opts, capture, DefaultMaxReferencedTSLs, nil)
+	require.NoError(t, err)
+	require.Len(t, tsls, 4)
+	for i, tsl := range tsls {
+		assert.True(t, strings.HasSuffix(tsl.Source, fmt.Sprintf("list-%d.xml", i)), tsl.Source)
+		if i < len(tsls)-1 {
+			require.Len(t, tsl.Referenced, 1)
+			assert.Same(t, tsls[i+1], tsl.Referenced[0])
+		}
+	}
+
+	_, err = fetchTSLWithReferences(pl, NewContext(), "file:///nonexistent/tsl.xml", opts, capture, DefaultMaxReferencedTSLs, nil)
+	assert.Error(t, err)
+}
+
+func TestReferenceKey(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	tests := []struct {
+		location string
+		key      string
+	}{
+		{"https://Example.COM:443/lists/./tsl.xml#top", "https://example.com/lists/tsl.xml"},
+		{"http://example.com:80/a/../tsl.xml", "http://example.com/tsl.xml"},
+		{"https://example.com:8443/tsl.xml", "https://example.com:8443/tsl.xml"},
+		{"https://example.com/tsl.xml?v=1", "https://example.com/tsl.xml?v=1"},
+		{"file://testdata/loops/../loops/a.xml", "file://" + filepath.Join(cwd, "testdata/loops/a.xml")},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.key, referenceKey(tt.location), tt.location)
+	}
+}
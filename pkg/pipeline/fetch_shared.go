@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// sharedDocument is a TSL document downloaded by one run and handed with its
+// source lock to the concurrent runs that waited to fetch the same URL.
+type sharedDocument struct {
+	accept string      // Accept header of the request
+	header http.Header // Header of the response
+	body   []byte      // Decoded body of the response
+}
+
+// newSharedDocument returns the sharedDocument of body, received for req
+// with resp, or nil if req was not a plain GET request.
+func newSharedDocument(req *http.Request, resp *http.Response, body []byte) *sharedDocument {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return nil
+	}
+	header := resp.Header.Clone()
+	// The body is kept decoded, whatever the transport received
+	header.Del("Content-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &sharedDocument{accept: req.Header.Get("Accept"), header: header, body: body}
+}
+
+// canReuse reports whether doc answers req as a download by t would: req
+// is a plain GET request with the same Accept header, and doc is not larger
+// than t allows.
+func (t *captureTransport) canReuse(req *http.Request, doc *sharedDocument) bool {
+	return req.Method == http.MethodGet && req.Header.Get("Range") == "" &&
+		req.Header.Get("Accept") == doc.accept &&
+		(t.maxSize <= 0 || int64(len(doc.body)) <= t.maxSize)
+}
+
+// reuse returns the response of req from doc and records it as a shared
+// fetch.
+func (t *captureTransport) reuse(req *http.Request, doc *sharedDocument) *http.Response {
+	source := req.URL.String()
+	fetch := SourceFetch{URL: source, Time: time.Now(), StatusCode: http.StatusOK, Shared: true}
+	t.record(fetch)
+	sharedFetches.Inc()
+
+	t.mu.Lock()
+	t.bodies[source] = doc.body
+	t.documents = append(t.documents, fetchedDocument{fetch: fetch, header: doc.header.Clone(), body: doc.body})
+	t.mu.Unlock()
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        doc.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(doc.body)),
+		ContentLength: int64(len(doc.body)),
+		Request:       req,
+	}
+}
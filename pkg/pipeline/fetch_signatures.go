@@ -0,0 +1,205 @@
+package pipeline
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// SignatureMode selects whether the load step verifies the signatures of the
+// TSLs it fetches against the certificates of their scheme operators.
+type SignatureMode string
+
+const (
+	// SignatureOff does not verify signatures.
+	SignatureOff SignatureMode = "off"
+	// SignatureReport verifies signatures and records the outcome, but admits
+	// every TSL.
+	SignatureReport SignatureMode = "report"
+	// SignatureEnforce admits only TSLs signed by one of the certificates of
+	// their scheme operator.
+	SignatureEnforce SignatureMode = "enforce"
+)
+
+// parseSignatureMode parses the value of a verify: option.
+func parseSignatureMode(value string) (SignatureMode, error) {
+	switch mode := SignatureMode(strings.ToLower(value)); mode {
+	case SignatureOff, SignatureReport, SignatureEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid signature verification mode %q: must be enforce, report or off", value)
+	}
+}
+
+// Signature verification statuses of a loaded TSL.
+const (
+	SignatureVerified  = "verified"         // Signed by a certificate of its scheme operator
+	SignatureUntrusted = "untrusted_signer" // Intact, but signed by another certificate
+	SignatureInvalid   = "invalid"          // The signature does not verify
+	SignatureUnsigned  = "unsigned"         // No enveloped signature
+	SignatureNoAnchor  = "no_anchor"        // No scheme operator certificates to check the signer against
+)
+
+// TSLSignature is the outcome of verifying the signature of a fetched TSL.
+type TSLSignature struct {
+	Status   string `json:"status"`           // One of the Signature* statuses
+	Anchor   string `json:"anchor,omitempty"` // Where the expected certificates came from: "configuration" or the URL of the pointing TSL
+	Signer   string `json:"signer,omitempty"` // Subject of the signing certificate
+	SHA256   string `json:"sha256,omitempty"` // Hex SHA-256 fingerprint of the signing certificate
+	Error    string `json:"error,omitempty"`  // Why the TSL was not verified
+	Admitted bool   `json:"admitted"`         // Whether the TSL was added to the tree
+}
+
+// Verified reports whether the TSL was signed by a certificate of its scheme
+// operator.
+func (s *TSLSignature) Verified() bool {
+	return s != nil && s.Status == SignatureVerified
+}
+
+// RecordSignature records the signature verification status of tsl.
+func (ctx *Context) RecordSignature(tsl *etsi119612.TSL, sig *TSLSignature) {
+	if ctx.Signatures == nil {
+		ctx.Signatures = make(map[*etsi119612.TSL]*TSLSignature)
+	}
+	ctx.Signatures[tsl] = sig
+}
+
+// SignatureOf returns the signature verification status of tsl, or nil if
+// its signature was not verified.
+func (ctx *Context) SignatureOf(tsl *etsi119612.TSL) *TSLSignature {
+	if ctx == nil || ctx.Signatures == nil {
+		return nil
+	}
+	return ctx.Signatures[tsl]
+}
+
+// signatureVerifier verifies the signatures of the TSLs fetched by a load
+// step: the root TSL against the certificates configured with signer-cert:,
+// and referenced TSLs against the ServiceDigitalIdentities of the pointer
+// that led to them.
+type signatureVerifier struct {
+	mode    SignatureMode
+	anchors []*x509.Certificate // Certificates expected to sign the root TSL
+}
+
+// newSignatureVerifier returns a verifier for mode, or nil if mode is
+// SignatureOff. The root TSL is expected to be signed by one of anchors.
+func newSignatureVerifier(mode SignatureMode, anchors []*x509.Certificate) *signatureVerifier {
+	if mode == "" || mode == SignatureOff {
+		return nil
+	}
+	return &signatureVerifier{mode: mode, anchors: anchors}
+}
+
+// verify verifies the signature of the TSL document data against the
+// certificates expected, found at anchor. It returns the status of the TSL,
+// admitted unless the mode is SignatureEnforce and it was not verified.
+func (v *signatureVerifier) verify(data []byte, expected []*x509.Certificate, anchor string) *TSLSignature {
+	sig := &TSLSignature{Anchor: anchor}
+	cert, err := dsig.VerifyXML(data)
+	switch {
+	case errors.Is(err, dsig.ErrNotSigned):
+		sig.Status = SignatureUnsigned
+		sig.Error = err.Error()
+	case err != nil:
+		sig.Status = SignatureInvalid
+		sig.Error = err.Error()
+	case len(expected) == 0:
+		sig.Status = SignatureNoAnchor
+		sig.Error = "no scheme operator certificates to verify the signer against"
+	case !signedByAny(cert, expected):
+		sig.Status = SignatureUntrusted
+		sig.Error = fmt.Sprintf("signed by %q, which is not a certificate of the scheme operator", cert.Subject.String())
+	default:
+		sig.Status = SignatureVerified
+	}
+	if cert != nil {
+		sig.Signer = cert.Subject.String()
+		sig.SHA256 = Fingerprint(cert)
+	}
+	sig.Admitted = sig.Verified() || v.mode != SignatureEnforce
+	return sig
+}
+
+// signedByAny reports whether cert is one of expected, or has the public key
+// of one of them, as a scheme operator certificate renewed for the same key.
+func signedByAny(cert *x509.Certificate, expected []*x509.Certificate) bool {
+	for _, e := range expected {
+		if cert.Equal(e) {
+			return true
+		}
+		if key, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && key.Equal(e.PublicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// check verifies the signature of tsl, fetched by a load step, records its
+// status in ctx and logs and reports TSLs that are not verified. The status
+// returned tells whether tsl is admitted to the tree.
+func (v *signatureVerifier) check(pl *Pipeline, ctx *Context, capture *captureTransport, tsl *etsi119612.TSL, expected []*x509.Certificate, anchor string) *TSLSignature {
+	data, err := capture.body(tsl.Source)
+	var sig *TSLSignature
+	if err != nil {
+		sig = &TSLSignature{Status: SignatureInvalid, Anchor: anchor, Error: err.Error(), Admitted: v.mode != SignatureEnforce}
+	} else {
+		sig = v.verify(data, expected, anchor)
+	}
+	ctx.RecordSignature(tsl, sig)
+
+	if sig.Verified() {
+		pl.Logger.Debug("Verified TSL signature",
+			logging.F("url", tsl.Source),
+			logging.F("signer", sig.Signer),
+			logging.F("anchor", anchor))
+		return sig
+	}
+	pl.Logger.Warn("TSL signature not verified",
+		logging.F("url", tsl.Source),
+		logging.F("status", sig.Status),
+		logging.F("anchor", anchor),
+		logging.F("admitted", sig.Admitted),
+		logging.F("error", sig.Error))
+	if sig.Admitted {
+		ctx.AddWarning("signature of %s not verified (%s): %s", tsl.Source, sig.Status, sig.Error)
+	} else {
+		ctx.AddWarning("rejected %s: signature not verified (%s): %s", tsl.Source, sig.Status, sig.Error)
+	}
+	return sig
+}
+
+// pointerCertificates returns the certificates of the ServiceDigitalIdentities
+// of a pointer to another TSL: the certificates its scheme operator signs it
+// with. Identities that are not valid certificates are skipped.
+func pointerCertificates(p *etsi119612.OtherTSLPointerType) []*x509.Certificate {
+	if p == nil || p.TslServiceDigitalIdentities == nil {
+		return nil
+	}
+	var certs []*x509.Certificate
+	for _, sdi := range p.TslServiceDigitalIdentities.TslServiceDigitalIdentity {
+		if sdi == nil {
+			continue
+		}
+		for _, id := range sdi.DigitalId {
+			if id == nil || strings.TrimSpace(id.X509Certificate) == "" {
+				continue
+			}
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(id.X509Certificate), ""))
+			if err != nil {
+				continue
+			}
+			if cert, err := x509.ParseCertificate(der); err == nil {
+				certs = append(certs, cert)
+			}
+		}
+	}
+	return certs
+}
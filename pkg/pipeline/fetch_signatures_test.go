@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// schemeSigner is the signing key of a scheme operator in the tests.
+type schemeSigner struct {
+	cert   *testutil.Cert
+	signer *dsig.FileSigner
+}
+
+// newSchemeSigner generates a scheme operator signing key.
+func newSchemeSigner(t *testing.T, name string) *schemeSigner {
+	t.Helper()
+	cert, err := testutil.NewSelfSigned(name, testutil.WithRSAKey(2048))
+	require.NoError(t, err)
+	keyPEM, err := cert.KeyPEM()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, cert.CertPEM(), 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+	return &schemeSigner{cert: cert, signer: dsig.NewFileSigner(certFile, keyFile)}
+}
+
+// writeSchemeTSL writes a TSL named name to path, with a pointer to each of
+// pointers, keyed by location, listing the certificate of its signer. The
+// TSL is signed by signer unless it is nil.
+func writeSchemeTSL(t *testing.T, path, name string, signer *schemeSigner, pointers map[string]*schemeSigner) {
+	t.Helper()
+	var refs []string
+	for location, s := range pointers {
+		refs = append(refs, fmt.Sprintf(`<tsl:OtherTSLPointer><tsl:ServiceDigitalIdentities><tsl:ServiceDigitalIdentity><tsl:DigitalId><tsl:X509Certificate>%s</tsl:X509Certificate></tsl:DigitalId></tsl:ServiceDigitalIdentity></tsl:ServiceDigitalIdentities><tsl:TSLLocation>file://%s</tsl:TSLLocation></tsl:OtherTSLPointer>`,
+			s.cert.Base64(), location))
+	}
+	doc := []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#" xmlns:xml="http://www.w3.org/XML/1998/namespace" Id="tsl">
+  <tsl:SchemeInformation>
+    <tsl:SchemeOperatorName><tsl:Name xml:lang="en">%s</tsl:Name></tsl:SchemeOperatorName>
+    <tsl:PointersToOtherTSL>%s</tsl:PointersToOtherTSL>
+  </tsl:SchemeInformation>
+</tsl:TrustServiceStatusList>`, name, strings.Join(refs, "")))
+	if signer != nil {
+		var err error
+		doc, err = signer.signer.Sign(doc)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.WriteFile(path, doc, 0600))
+}
+
+func TestLoadTSL_VerifySignatures(t *testing.T) {
+	pl := createTestPipeline(nil)
+	lotlSigner := newSchemeSigner(t, "LOTL Operator")
+	seSigner := newSchemeSigner(t, "SE Operator")
+	noSigner := newSchemeSigner(t, "NO Operator")
+	rogue := newSchemeSigner(t, "Rogue")
+
+	dir := t.TempDir()
+	lotl := filepath.Join(dir, "lotl.xml")
+	se := filepath.Join(dir, "se.xml")
+	no := filepath.Join(dir, "no.xml")
+	dk := filepath.Join(dir, "dk.xml")
+	writeSchemeTSL(t, se, "SE", seSigner, nil)
+	writeSchemeTSL(t, no, "NO", rogue, nil)
+	writeSchemeTSL(t, dk, "DK", nil, nil)
+	writeSchemeTSL(t, lotl, "LOTL", lotlSigner, map[string]*schemeSigner{se: seSigner, no: noSigner, dk: seSigner})
+
+	anchors := filepath.Join(dir, "lotl-signers.pem")
+	require.NoError(t, os.WriteFile(anchors, lotlSigner.cert.CertPEM(), 0600))
+
+	load := func(args ...string) (*Context, error) {
+		ctx, err := SetFetchOptions(pl, NewContext(), "max-depth:1")
+		require.NoError(t, err)
+		return LoadTSL(pl, ctx, append([]string{lotl}, args...)...)
+	}
+	status := func(ctx *Context) map[string]*TSLSignature {
+		statuses := make(map[string]*TSLSignature)
+		for tsl, sig := range ctx.Signatures {
+			statuses[filepath.Base(tsl.Source)] = sig
+		}
+		return statuses
+	}
+
+	t.Run("report", func(t *testing.T) {
+		ctx, err := load("verify:report", "signer-cert:"+anchors)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"LOTL", "SE", "NO", "DK"}, loadedNames(ctx))
+		statuses := status(ctx)
+		assert.Equal(t, SignatureVerified, statuses["lotl.xml"].Status)
+		assert.Equal(t, "configuration", statuses["lotl.xml"].Anchor)
+		assert.Equal(t, SignatureVerified, statuses["se.xml"].Status)
+		assert.Equal(t, "CN=SE Operator", statuses["se.xml"].Signer)
+		assert.Equal(t, SignatureUntrusted, statuses["no.xml"].Status)
+		assert.True(t, statuses["no.xml"].Admitted)
+		assert.Equal(t, SignatureUnsigned, statuses["dk.xml"].Status)
+		warnings, _ := ctx.takeStepStats()
+		assert.Len(t, warnings, 2)
+	})
+
+	t.Run("enforce", func(t *testing.T) {
+		ctx, err := load("verify:enforce", "signer-cert:"+anchors)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"LOTL", "SE"}, loadedNames(ctx))
+		statuses := status(ctx)
+		assert.False(t, statuses["no.xml"].Admitted)
+		assert.False(t, statuses["dk.xml"].Admitted)
+		assert.True(t, statuses["se.xml"].Admitted)
+		warnings, _ := ctx.takeStepStats()
+		require.Len(t, warnings, 2)
+		assert.Contains(t, strings.Join(warnings, "\n"), "rejected file://"+no)
+	})
+
+	t.Run("root signed by another key", func(t *testing.T) {
+		other := filepath.Join(dir, "other-signers.pem")
+		require.NoError(t, os.WriteFile(other, rogue.cert.CertPEM(), 0600))
+		_, err := load("verify:enforce", "signer-cert:"+other)
+		assert.ErrorContains(t, err, "untrusted_signer")
+
+		ctx, err := load("verify:report")
+		require.NoError(t, err)
+		assert.Equal(t, SignatureNoAnchor, status(ctx)["lotl.xml"].Status)
+	})
+
+	t.Run("off", func(t *testing.T) {
+		ctx, err := load()
+		require.NoError(t, err)
+		assert.Len(t, loadedNames(ctx), 4)
+		assert.Nil(t, ctx.Signatures)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := load("verify:enforce")
+		assert.ErrorContains(t, err, "needs the certificates of the root TSL")
+		_, err = load("verify:always")
+		assert.ErrorContains(t, err, "invalid signature verification mode")
+		_, err = load("verify:report", "signer-cert:"+filepath.Join(dir, "missing.pem"))
+		assert.Error(t, err)
+	})
+}
+
+func TestVerifySignature_Tampered(t *testing.T) {
+	signer := newSchemeSigner(t, "SE Operator")
+	path := filepath.Join(t.TempDir(), "se.xml")
+	writeSchemeTSL(t, path, "SE", signer, nil)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	tampered := []byte(strings.Replace(string(data), ">SE<", ">XX<", 1))
+
+	v := newSignatureVerifier(SignatureEnforce, nil)
+	sig := v.verify(tampered, nil, "configuration")
+	assert.Equal(t, SignatureInvalid, sig.Status)
+	assert.False(t, sig.Admitted)
+
+	assert.Nil(t, newSignatureVerifier(SignatureOff, nil))
+}
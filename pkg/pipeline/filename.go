@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+)
+
+// maxFileNameLength is the longest file name, in bytes, that the publish and
+// transform steps derive. Most filesystems allow 255.
+const maxFileNameLength = 255
+
+// windowsReservedNames are device names that cannot be used as file names on
+// Windows, with or without an extension.
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// distributionPointName returns a safe file name derived from the first
+// distribution point of tsl, or "" if it has none or none can be derived.
+// The query and fragment are ignored and the last path segment is
+// percent-decoded before it is sanitized, so
+// "https://example.com/tsl/S%C3%A9.xml?v=2" gives "Sé.xml".
+func distributionPointName(tsl *etsi119612.TSL) string {
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil || si.TslDistributionPoints == nil || len(si.TslDistributionPoints.URI) == 0 {
+		return ""
+	}
+	return uriFileName(si.TslDistributionPoints.URI[0])
+}
+
+// uriFileName returns a safe file name derived from the last path segment of
+// uri, or "" if there is none.
+func uriFileName(uri string) string {
+	uri = strings.TrimSpace(uri)
+	segment := uri
+	if u, err := url.Parse(uri); err == nil {
+		// EscapedPath keeps an encoded "/" within a segment apart from the separators
+		segment = u.EscapedPath()
+		if segment == "" {
+			segment = u.Opaque
+		}
+	} else if i := strings.IndexAny(segment, "?#"); i >= 0 {
+		segment = segment[:i]
+	}
+	if i := strings.LastIndexAny(segment, `/\`); i >= 0 {
+		segment = segment[i+1:]
+	}
+	if decoded, err := url.PathUnescape(segment); err == nil {
+		segment = decoded
+	}
+	return sanitizeFileName(segment)
+}
+
+// sanitizeFileName makes name safe to use as a single file name on Linux,
+// macOS and Windows. Path separators, characters that Windows does not allow
+// and control characters are replaced with "_", leading and trailing spaces
+// and dots are removed, Windows device names are prefixed with "_" and the
+// name is shortened to maxFileNameLength bytes. It returns "" if nothing
+// usable remains.
+func sanitizeFileName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			sb.WriteRune('_')
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			sb.WriteRune('_')
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	clean := strings.Trim(sb.String(), " .")
+	if clean == "" {
+		return ""
+	}
+
+	stem := strings.ToUpper(strings.TrimSuffix(clean, filepath.Ext(clean)))
+	if windowsReservedNames[strings.TrimRight(stem, " ")] {
+		clean = "_" + clean
+	}
+
+	if len(clean) > maxFileNameLength {
+		ext := filepath.Ext(clean)
+		if len(ext) > maxFileNameLength/2 {
+			ext = ""
+		}
+		stem := clean[:len(clean)-len(ext)]
+		cut := maxFileNameLength - len(ext)
+		// Do not split a multi-byte character
+		for cut > 0 && !isRuneStart(stem[cut]) {
+			cut--
+		}
+		clean = stem[:cut] + ext
+	}
+	return clean
+}
+
+// isRuneStart reports whether b can start a UTF-8 encoded character.
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// fileNameClaims hands out file names within a step run so that two TSLs
+// that derive the same name do not overwrite each other. Names are compared
+// case-insensitively, as on the default Windows and macOS filesystems.
+type fileNameClaims map[string]bool
+
+// claim returns path if no earlier claim took it, or otherwise path with
+// "-2", "-3" and so on inserted before the extension.
+func (c fileNameClaims) claim(path string) string {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	candidate := path
+	for n := 2; c[strings.ToLower(candidate)]; n++ {
+		candidate = filepath.Join(dir, fmt.Sprintf("%s-%d%s", stem, n, ext))
+	}
+	c[strings.ToLower(candidate)] = true
+	return candidate
+}
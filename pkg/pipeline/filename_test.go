@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURIFileName(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"https://tsl.example.com/se.xml", "se.xml"},
+		{"https://tsl.example.com/tsl/se.xml?format=xml&v=2", "se.xml"},
+		{"https://tsl.example.com/se.xml#latest", "se.xml"},
+		{"https://tsl.example.com/S%C3%A9%20list.xml", "Sé list.xml"},
+		{"https://tsl.example.com/a%2Fb.xml", "a_b.xml"},
+		{"https://tsl.example.com/..%2F..%2Fetc%2Fpasswd", "_.._etc_passwd"},
+		{"https://tsl.example.com/%2E%2E", ""},
+		{"https://tsl.example.com/tsl/..", ""},
+		{"https://tsl.example.com/", ""},
+		{"https://tsl.example.com", ""},
+		{"https://tsl.example.com/list:v2*.xml", "list_v2_.xml"},
+		{"https://tsl.example.com/con.xml", "_con.xml"},
+		{"https://tsl.example.com/bad%zz.xml", "bad%zz.xml"},
+		{`C:\tsl\se.xml`, "se.xml"},
+		{"  https://tsl.example.com/se.xml  ", "se.xml"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, uriFileName(tt.uri), tt.uri)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	assert.Equal(t, "se.xml", sanitizeFileName("se.xml"))
+	assert.Equal(t, "a_b_c.xml", sanitizeFileName("a<b>c.xml"))
+	assert.Equal(t, "tab_.xml", sanitizeFileName("tab\t.xml"))
+	assert.Equal(t, "list", sanitizeFileName(" list. "))
+	assert.Equal(t, "_NUL", sanitizeFileName("NUL"))
+	assert.Equal(t, "_lpt1.txt", sanitizeFileName("lpt1.txt"))
+	assert.Equal(t, "com10.xml", sanitizeFileName("com10.xml"))
+	assert.Equal(t, "", sanitizeFileName(".."))
+
+	long := sanitizeFileName(strings.Repeat("é", 200) + ".xml")
+	assert.LessOrEqual(t, len(long), maxFileNameLength)
+	assert.True(t, utf8.ValidString(long))
+	assert.True(t, strings.HasSuffix(long, ".xml"))
+}
+
+func TestFileNameClaims(t *testing.T) {
+	claims := make(fileNameClaims)
+	dir := filepath.Join("out", "tsl")
+	assert.Equal(t, filepath.Join(dir, "se.xml"), claims.claim(filepath.Join(dir, "se.xml")))
+	assert.Equal(t, filepath.Join(dir, "se-2.xml"), claims.claim(filepath.Join(dir, "se.xml")))
+	assert.Equal(t, filepath.Join(dir, "SE-3.xml"), claims.claim(filepath.Join(dir, "SE.xml")), "case-insensitive")
+	assert.Equal(t, filepath.Join(dir, "no.xml"), claims.claim(filepath.Join(dir, "no.xml")))
+	assert.Equal(t, "plain", claims.claim("plain"))
+	assert.Equal(t, "plain-2", claims.claim("plain"))
+}
+
+func TestPublishTSL_FileNameCollision(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+
+	ctx := dryRunContext(1, statusGranted)
+	other := dryRunContext(1, statusWithdrawn).TSLs.ToSlice()[0]
+	other.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+		URI: []string{"https://mirror.example.com/lists/SE.xml?mirror=1"},
+	}
+	ctx.TSLs.Push(other)
+
+	_, err := PublishTSL(pl, ctx, dir)
+	require.NoError(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"se.xml", "SE-2.xml"}, names)
+}
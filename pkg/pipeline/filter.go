@@ -1,75 +1,178 @@
 package pipeline
 
 import (
+	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
 )
 
+// tslFiltersKey is the ctx.Data key of the TSL filter rules, a
+// map[string][]string from rule to values set by the filter and
+// set-fetch-options steps.
+const tslFiltersKey = "tsl_filters"
+
+// TSL filter rules. An include rule keeps only the TSLs that match one of its
+// values; the exclude rule of the same name, prefixed with "exclude-", drops
+// the TSLs that match any of its values. A TSL must pass every rule.
+const (
+	FilterTerritory   = "territory"    // Scheme territory, normalized so GR and GB match EL and UK
+	FilterSchemeType  = "scheme-type"  // Substring of the TSL type URI
+	FilterServiceType = "service-type" // Substring of the type URI of any service of the TSL
+	FilterOperator    = "operator"     // Regular expression matching any scheme operator name
+	FilterURL         = "url"          // Pattern matching the URL of the TSL, where * matches any characters
+
+	excludePrefix = "exclude-"
+)
+
+// filterRules lists the TSL filter rules in the order they are checked.
+var filterRules = []string{FilterTerritory, FilterSchemeType, FilterServiceType, FilterOperator, FilterURL}
+
 // FilterTSLs applies filters to the TSLs based on the filters defined in the context.
 // It returns a new slice containing only the TSLs that match the filters.
 func FilterTSLs(ctx *Context, tsls []*etsi119612.TSL) []*etsi119612.TSL {
-	// Get filters from context
-	filtersAny, ok := ctx.Data["tsl_filters"]
-	if !ok {
-		// No filters defined, return the original slice
-		return tsls
-	}
+	return filterTSLs(nil, ctx, tsls)
+}
 
-	filters, ok := filtersAny.(map[string][]string)
-	if !ok || len(filters) == 0 {
-		// No valid filters, return the original slice
+// filterTSLs is FilterTSLs logging every TSL filtered out, and why, if pl is
+// not nil.
+func filterTSLs(pl *Pipeline, ctx *Context, tsls []*etsi119612.TSL) []*etsi119612.TSL {
+	filters := contextFilters(ctx)
+	if len(filters) == 0 {
+		// No filters defined, return the original slice
 		return tsls
 	}
 
 	// Apply filters
 	result := make([]*etsi119612.TSL, 0, len(tsls))
 	for _, tsl := range tsls {
-		if matchesFilters(tsl, filters) {
+		reason := rejectReason(tsl, filters)
+		if reason == "" {
 			result = append(result, tsl)
+			continue
+		}
+		if pl != nil {
+			pl.Logger.Info("Filtered out TSL",
+				logging.F("url", tsl.Source),
+				logging.F("reason", reason))
 		}
 	}
 
 	return result
 }
 
+// contextFilters returns the TSL filter rules of ctx, or nil.
+func contextFilters(ctx *Context) map[string][]string {
+	if ctx == nil {
+		return nil
+	}
+	filters, _ := ctx.Data[tslFiltersKey].(map[string][]string)
+	return filters
+}
+
 // matchesFilters checks if a TSL matches all the specified filters
 func matchesFilters(tsl *etsi119612.TSL, filters map[string][]string) bool {
-	// Check territory filter
-	if territories, ok := filters["territory"]; ok && len(territories) > 0 {
-		if !matchesTerritory(tsl, territories) {
-			return false
-		}
-	}
+	return rejectReason(tsl, filters) == ""
+}
 
-	// Check service type filter
-	if serviceTypes, ok := filters["service-type"]; ok && len(serviceTypes) > 0 {
-		if !matchesServiceType(tsl, serviceTypes) {
-			return false
+// rejectReason returns why tsl does not pass filters, or "" if it does.
+func rejectReason(tsl *etsi119612.TSL, filters map[string][]string) string {
+	for _, rule := range filterRules {
+		if values := filters[rule]; len(values) > 0 && !matchesRule(tsl, rule, values) {
+			return fmt.Sprintf("does not match %s %s", rule, strings.Join(values, ","))
+		}
+		if values := filters[excludePrefix+rule]; len(values) > 0 && matchesRule(tsl, rule, values) {
+			return fmt.Sprintf("matches %s%s %s", excludePrefix, rule, strings.Join(values, ","))
 		}
 	}
 
 	// All filters passed
-	return true
+	return ""
 }
 
-// matchesTerritory checks if a TSL's territory matches any of the specified territories
-func matchesTerritory(tsl *etsi119612.TSL, territories []string) bool {
+// matchesRule reports whether tsl matches any of the values of a filter rule.
+func matchesRule(tsl *etsi119612.TSL, rule string, values []string) bool {
+	switch rule {
+	case FilterTerritory:
+		return matchesTerritory(tsl, values)
+	case FilterSchemeType:
+		return matchesSchemeType(tsl, values)
+	case FilterServiceType:
+		return matchesServiceType(tsl, values)
+	case FilterOperator:
+		return matchesOperator(tsl, values)
+	case FilterURL:
+		return matchesURL(tsl, values)
+	default:
+		return false
+	}
+}
+
+// matchesSchemeType checks if a TSL's type contains any of the specified types
+func matchesSchemeType(tsl *etsi119612.TSL, schemeTypes []string) bool {
 	if tsl.StatusList.TslSchemeInformation == nil {
 		return false
 	}
+	for _, filter := range schemeTypes {
+		if strings.Contains(tsl.StatusList.TslSchemeInformation.TslTSLType, filter) {
+			return true
+		}
+	}
+	return false
+}
 
-	territory := tsl.StatusList.TslSchemeInformation.TslSchemeTerritory
+// matchesOperator checks if any scheme operator name of a TSL, in any
+// language, matches any of the regular expressions. Invalid expressions are
+// rejected when the filter is set and match nothing here.
+func matchesOperator(tsl *etsi119612.TSL, expressions []string) bool {
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil || si.TslSchemeOperatorName == nil {
+		return false
+	}
+	for _, expr := range expressions {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			continue
+		}
+		for _, name := range si.TslSchemeOperatorName.Name {
+			if name != nil && name.NonEmptyNormalizedString != nil && re.MatchString(string(*name.NonEmptyNormalizedString)) {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	for _, filter := range territories {
-		if strings.EqualFold(territory, filter) {
+// matchesURL checks if the URL of a TSL matches any of the patterns
+func matchesURL(tsl *etsi119612.TSL, patterns []string) bool {
+	for _, pattern := range patterns {
+		if urlPattern(pattern).MatchString(tsl.Source) {
 			return true
 		}
 	}
-
 	return false
 }
 
+// urlPattern returns a regular expression matching the whole of a URL against
+// pattern, in which * matches any characters, slashes included.
+func urlPattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// matchesTerritory checks if a TSL's territory matches any of the specified
+// territories, after normalizing both, so that GR matches a TSL of EL
+func matchesTerritory(tsl *etsi119612.TSL, territories []string) bool {
+	territory := territoryOf(tsl)
+	return territory != "" && slices.Contains(NormalizeTerritories(territories), territory)
+}
+
 // matchesServiceType checks if a TSL has any service that matches the specified types
 func matchesServiceType(tsl *etsi119612.TSL, serviceTypes []string) bool {
 	if tsl.StatusList.TslTrustServiceProviderList == nil {
@@ -140,6 +140,20 @@ func TestMatchesTerritory_EdgeCases(t *testing.T) {
 		assert.True(t, matchesTerritory(tsl, []string{"Se"}))
 	})
 
+	t.Run("ISO codes match EU trusted list codes", func(t *testing.T) {
+		el := &etsi119612.TSL{StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{TslSchemeTerritory: "EL"},
+		}}
+		gb := &etsi119612.TSL{StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{TslSchemeTerritory: "GB"},
+		}}
+
+		assert.True(t, matchesTerritory(el, []string{"GR"}))
+		assert.True(t, matchesTerritory(el, []string{"el"}))
+		assert.True(t, matchesTerritory(gb, []string{"UK"}))
+		assert.False(t, matchesTerritory(gb, []string{"SE"}))
+	})
+
 	t.Run("Returns false for nil scheme information", func(t *testing.T) {
 		tsl := &etsi119612.TSL{
 			StatusList: etsi119612.TrustStatusListType{
@@ -0,0 +1,216 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+)
+
+const (
+	// tslVersionIdentifier is the TSLVersionIdentifier of generated TSLs, as
+	// required by ETSI TS 119 612 v2.
+	tslVersionIdentifier = 5
+
+	// tslDateTimeLayout is the layout of ListIssueDateTime and NextUpdate in
+	// generated TSLs.
+	tslDateTimeLayout = "2006-01-02T15:04:05Z"
+)
+
+// generateOptions are the sequence number and date options of the generate
+// and generate-lotl steps.
+type generateOptions struct {
+	previous   string        // Previously published TSL to continue the sequence of
+	state      string        // State file recording the last generated sequence number
+	nextUpdate time.Duration // Period from the issue date to NextUpdate, overriding scheme.yaml
+}
+
+// GenerateState is the content of the state file of the generate steps. It
+// records the last generated sequence number so that the next run can
+// continue from it.
+type GenerateState struct {
+	SequenceNumber int    `json:"sequence_number"`       // Last generated TSL sequence number
+	IssueDate      string `json:"issue_date"`            // ListIssueDateTime of the last generated TSL
+	NextUpdate     string `json:"next_update,omitempty"` // NextUpdate of the last generated TSL
+}
+
+// parseGenerateOptions extracts the previous:, state: and next-update:
+// options from the step arguments after args[0]. The remaining arguments are
+// returned in order.
+func parseGenerateOptions(args []string) ([]string, *generateOptions, error) {
+	opts := &generateOptions{}
+	var rest []string
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "previous:"):
+			opts.previous = strings.TrimPrefix(arg, "previous:")
+		case strings.HasPrefix(arg, "state:"):
+			opts.state = strings.TrimPrefix(arg, "state:")
+		case strings.HasPrefix(arg, "next-update:"):
+			period, err := parsePeriod(strings.TrimPrefix(arg, "next-update:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.nextUpdate = period
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, opts, nil
+}
+
+// parsePeriod parses a next update period. It accepts Go durations such as
+// "720h" and whole days such as "90d".
+func parsePeriod(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	} else if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid next update period %q: use a positive duration such as 720h or 90d", s)
+}
+
+// readPreviousSequenceNumber returns the TSLSequenceNumber of a previously
+// published TSL. A missing file is not an error and yields 0.
+func readPreviousSequenceNumber(path string) (int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read previous TSL %s: %w", path, err)
+	}
+	defer f.Close()
+
+	decoder := xml.NewDecoder(f)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return 0, fmt.Errorf("previous TSL %s has no TSLSequenceNumber", path)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse previous TSL %s: %w", path, err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "TSLSequenceNumber" {
+			continue
+		}
+		var value string
+		if err := decoder.DecodeElement(&value, &start); err != nil {
+			return 0, fmt.Errorf("failed to parse previous TSL %s: %w", path, err)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("invalid TSLSequenceNumber %q in previous TSL %s", value, path)
+		}
+		return n, nil
+	}
+}
+
+// readGenerateState reads a state file. A missing file is not an error and
+// yields an empty state.
+func readGenerateState(path string) (*GenerateState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &GenerateState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+	var state GenerateState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+// writeGenerateState writes a state file through a temporary file, so that an
+// interrupted run leaves the previous state intact.
+func writeGenerateState(path string, state *GenerateState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write state file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyIssueState sets the sequence number, ListIssueDateTime and NextUpdate
+// of a generated TSL's scheme information.
+//
+// The sequence number is the one from scheme.yaml, or one more than the
+// sequence number of the previously published TSL or the state file if that
+// is higher. ListIssueDateTime is set to now and NextUpdate to now plus the
+// next-update: option or the nextUpdatePeriod of scheme.yaml, if either is set.
+// If a state file is configured, the new sequence number and dates are
+// written to it.
+//
+// It returns the assigned sequence number.
+func applyIssueState(si *etsi119612.TSLSchemeInformationType, metadata *SchemeMetadata, opts *generateOptions, now time.Time) (int, error) {
+	last := 0
+	if opts.previous != "" {
+		n, err := readPreviousSequenceNumber(opts.previous)
+		if err != nil {
+			return 0, err
+		}
+		last = n
+	}
+	if opts.state != "" {
+		state, err := readGenerateState(opts.state)
+		if err != nil {
+			return 0, err
+		}
+		last = max(last, state.SequenceNumber)
+	}
+	if last > 0 {
+		si.TSLSequenceNumber = max(si.TSLSequenceNumber, last+1)
+	}
+
+	period := opts.nextUpdate
+	if period == 0 && metadata.NextUpdatePeriod != "" {
+		p, err := parsePeriod(metadata.NextUpdatePeriod)
+		if err != nil {
+			return 0, fmt.Errorf("invalid scheme metadata: %w", err)
+		}
+		period = p
+	}
+
+	now = now.UTC()
+	si.ListIssueDateTime = now.Format(tslDateTimeLayout)
+	if period > 0 {
+		si.TslNextUpdate = &etsi119612.NextUpdateType{DateTime: now.Add(period).Format(tslDateTimeLayout)}
+	}
+
+	if opts.state != "" {
+		state := &GenerateState{SequenceNumber: si.TSLSequenceNumber, IssueDate: si.ListIssueDateTime}
+		if si.TslNextUpdate != nil {
+			state.NextUpdate = si.TslNextUpdate.DateTime
+		}
+		if err := writeGenerateState(opts.state, state); err != nil {
+			return 0, err
+		}
+	}
+	return si.TSLSequenceNumber, nil
+}
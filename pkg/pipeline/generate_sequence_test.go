@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePeriod(t *testing.T) {
+	d, err := parsePeriod("90d")
+	require.NoError(t, err)
+	assert.Equal(t, 90*24*time.Hour, d)
+
+	d, err = parsePeriod("720h")
+	require.NoError(t, err)
+	assert.Equal(t, 720*time.Hour, d)
+
+	for _, bad := range []string{"", "0d", "-1h", "d", "soon"} {
+		_, err := parsePeriod(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseGenerateOptions(t *testing.T) {
+	rest, opts, err := parseGenerateOptions([]string{"cert:a.pem", "previous:out/se.xml", "state:se.json", "next-update:30d"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cert:a.pem"}, rest)
+	assert.Equal(t, "out/se.xml", opts.previous)
+	assert.Equal(t, "se.json", opts.state)
+	assert.Equal(t, 30*24*time.Hour, opts.nextUpdate)
+
+	_, _, err = parseGenerateOptions([]string{"next-update:never"})
+	assert.Error(t, err)
+}
+
+func TestReadPreviousSequenceNumber(t *testing.T) {
+	dir := t.TempDir()
+
+	n, err := readPreviousSequenceNumber(filepath.Join(dir, "missing.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// As written by publish, with the list wrapped and namespaced
+	published := filepath.Join(dir, "se.xml")
+	require.NoError(t, os.WriteFile(published, []byte(`<TrustServiceStatusList><List>
+<tsl:SchemeInformation xmlns:tsl="http://uri.etsi.org/02231/v2#">
+<tsl:TSLVersionIdentifier>5</tsl:TSLVersionIdentifier>
+<tsl:TSLSequenceNumber> 41 </tsl:TSLSequenceNumber>
+</tsl:SchemeInformation></List></TrustServiceStatusList>`), 0644))
+	n, err = readPreviousSequenceNumber(published)
+	require.NoError(t, err)
+	assert.Equal(t, 41, n)
+
+	noSequence := filepath.Join(dir, "empty.xml")
+	require.NoError(t, os.WriteFile(noSequence, []byte(`<TrustServiceStatusList/>`), 0644))
+	_, err = readPreviousSequenceNumber(noSequence)
+	assert.ErrorContains(t, err, "has no TSLSequenceNumber")
+}
+
+func TestGenerateTSL_SequenceNumberAndDates(t *testing.T) {
+	pl := createTestPipeline(nil)
+	dir := writeSchemeDir(t, nationalScheme("SE", "https://tsl.example.com/se.xml"))
+
+	before := time.Now().UTC().Truncate(time.Second)
+	ctx, err := GenerateTSL(pl, NewContext(), dir, "next-update:30d")
+	require.NoError(t, err)
+	after := time.Now().UTC()
+
+	tsl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	si := tsl.StatusList.TslSchemeInformation
+	assert.Equal(t, 5, si.TSLVersionIdentifier)
+	assert.Equal(t, 3, si.TSLSequenceNumber, "sequence number from scheme.yaml")
+
+	issued, err := time.Parse(tslDateTimeLayout, si.ListIssueDateTime)
+	require.NoError(t, err)
+	assert.False(t, issued.Before(before))
+	assert.False(t, issued.After(after))
+	require.NotNil(t, si.TslNextUpdate)
+	nextUpdate, err := time.Parse(tslDateTimeLayout, si.TslNextUpdate.DateTime)
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, nextUpdate.Sub(issued))
+}
+
+func TestGenerateTSL_PreviousSequenceNumber(t *testing.T) {
+	pl := createTestPipeline(nil)
+	dir := writeSchemeDir(t, nationalScheme("SE", "https://tsl.example.com/se.xml"))
+	output := t.TempDir()
+	previous := "previous:" + filepath.Join(output, "se.xml")
+
+	// First run: nothing published yet
+	ctx, err := GenerateTSL(pl, NewContext(), dir, previous)
+	require.NoError(t, err)
+	tsl, _ := ctx.TSLs.Peek()
+	assert.Equal(t, 3, tsl.StatusList.TslSchemeInformation.TSLSequenceNumber)
+	assert.Nil(t, tsl.StatusList.TslSchemeInformation.TslNextUpdate)
+
+	_, err = PublishTSL(pl, ctx, output)
+	require.NoError(t, err)
+
+	// Later runs continue from the published file
+	for _, want := range []int{4, 5} {
+		ctx, err = GenerateTSL(pl, NewContext(), dir, previous)
+		require.NoError(t, err)
+		tsl, _ = ctx.TSLs.Peek()
+		assert.Equal(t, want, tsl.StatusList.TslSchemeInformation.TSLSequenceNumber)
+		_, err = PublishTSL(pl, ctx, output)
+		require.NoError(t, err)
+	}
+}
+
+func TestGenerateTSL_StateFile(t *testing.T) {
+	pl := createTestPipeline(nil)
+	dir := writeSchemeDir(t, nationalScheme("SE", "https://tsl.example.com/se.xml")+"nextUpdatePeriod: \"720h\"\n")
+	statePath := filepath.Join(t.TempDir(), "state", "se.json")
+
+	for _, want := range []int{3, 4, 5} {
+		ctx, err := GenerateTSL(pl, NewContext(), dir, "state:"+statePath)
+		require.NoError(t, err)
+		tsl, _ := ctx.TSLs.Peek()
+		si := tsl.StatusList.TslSchemeInformation
+		assert.Equal(t, want, si.TSLSequenceNumber)
+		require.NotNil(t, si.TslNextUpdate, "period from scheme.yaml")
+
+		state, err := readGenerateState(statePath)
+		require.NoError(t, err)
+		assert.Equal(t, want, state.SequenceNumber)
+		assert.Equal(t, si.ListIssueDateTime, state.IssueDate)
+		assert.Equal(t, si.TslNextUpdate.DateTime, state.NextUpdate)
+	}
+
+	// A higher scheme.yaml sequence number takes precedence
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "scheme.yaml"),
+		[]byte(`operatorNames:
+  - language: en
+    value: "SE Operator"
+type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric"
+sequenceNumber: 10
+`), 0644))
+	ctx, err := GenerateTSL(pl, NewContext(), dir, "state:"+statePath)
+	require.NoError(t, err)
+	tsl, _ := ctx.TSLs.Peek()
+	assert.Equal(t, 10, tsl.StatusList.TslSchemeInformation.TSLSequenceNumber)
+
+	require.NoError(t, os.WriteFile(statePath, []byte("{"), 0644))
+	_, err = GenerateTSL(pl, NewContext(), dir, "state:"+statePath)
+	assert.ErrorContains(t, err, "failed to parse state file")
+}
+
+func TestGenerateTSL_InvalidNextUpdatePeriod(t *testing.T) {
+	pl := createTestPipeline(nil)
+	dir := writeSchemeDir(t, nationalScheme("SE", "https://tsl.example.com/se.xml")+"nextUpdatePeriod: \"soon\"\n")
+
+	_, err := GenerateTSL(pl, NewContext(), dir)
+	assert.ErrorContains(t, err, "invalid next update period")
+
+	_, err = GenerateTSL(pl, NewContext(), writeSchemeDir(t, lotlScheme), "next-update:0d")
+	assert.ErrorContains(t, err, "invalid next update period")
+}
+
+func TestGenerateLOTL_StateFile(t *testing.T) {
+	pl := createTestPipeline(nil)
+	statePath := filepath.Join(t.TempDir(), "lotl.json")
+	require.NoError(t, writeGenerateState(statePath, &GenerateState{SequenceNumber: 7}))
+
+	ctx, err := GenerateTSL(pl, NewContext(), writeSchemeDir(t, nationalScheme("SE", "https://tsl.example.com/se.xml")))
+	require.NoError(t, err)
+	ctx, err = GenerateLOTL(pl, ctx, writeSchemeDir(t, lotlScheme), "state:"+statePath, "next-update:7d")
+	require.NoError(t, err)
+
+	lotl, _ := ctx.TSLs.Peek()
+	si := lotl.StatusList.TslSchemeInformation
+	assert.Equal(t, 8, si.TSLSequenceNumber)
+	require.NotNil(t, si.TslNextUpdate)
+	assert.NotEmpty(t, si.ListIssueDateTime)
+}
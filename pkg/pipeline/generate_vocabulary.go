@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
+)
+
+// serviceTypes are the service type identifiers of ETSI TS 119 612 (clause
+// 5.5.1), without the "http://uri.etsi.org/TrstSvc/Svctype/" prefix. The
+// qualified ones are listed in qualifiedServiceTypes.
+var serviceTypes = []string{
+	"CA/QC", "CA/PKC", "NationalRootCA-QC",
+	"Certstatus/OCSP", "Certstatus/OCSP/QC", "Certstatus/CRL", "Certstatus/CRL/QC",
+	"TSA", "TSA/QTST", "TSA/TSS-QC", "TSA/TSS-AdESQCandQES",
+	"EDS", "EDS/Q", "EDS/REM", "EDS/REM/Q",
+	"PSES", "PSES/Q", "QESValidation/Q", "AdESValidation", "AdESGeneration",
+	"RemoteQSigCDManagement/Q", "RemoteQSealCDManagement/Q",
+	"EAA", "EAA/Q", "ElectronicArchiving", "ElectronicArchiving/Q", "Ledgers", "Ledgers/Q",
+	"RA", "RA/nothavingPKIid", "ACA", "SignaturePolicyAuthority",
+	"Archiv", "Archiv/nothavingPKIid", "IdV", "IdV/nothavingPKIid",
+	"KEscrow", "KEscrow/nothavingPKIid", "PPwd", "PPwd/nothavingPKIid",
+	"TLIssuer", "unspecified",
+}
+
+// serviceStatuses are the service status identifiers of ETSI TS 119 612
+// (clause 5.5.4), without the "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/"
+// prefix: the current EU statuses followed by the historical ones.
+var serviceStatuses = []string{
+	"granted", "withdrawn", "recognisedatnationallevel", "deprecatedatnationallevel",
+	"undersupervision", "supervisionincessation", "supervisionceased", "supervisionrevoked",
+	"accredited", "accreditationceased", "accreditationrevoked",
+	"setbynationallaw", "deprecatedbynationallaw",
+}
+
+// serviceTypeShorthands are the shorthands for service types beyond those
+// derived from the identifiers themselves (see vocabularyAlias).
+var serviceTypeShorthands = map[string]string{
+	"ocsp":    "Certstatus/OCSP",
+	"ocsp-qc": "Certstatus/OCSP/QC",
+	"crl":     "Certstatus/CRL",
+	"crl-qc":  "Certstatus/CRL/QC",
+	"qtst":    "TSA/QTST",
+}
+
+// vocabulary is a set of ETSI identifiers sharing a URI prefix, with the
+// shorthands that expand to them.
+type vocabulary struct {
+	name    string            // Name of the metadata field, for error messages
+	prefix  string            // URI prefix of the identifiers, without scheme
+	aliases map[string]string // Shorthand to identifier suffix
+	known   map[string]string // Normalized URI (see normalizeURI) to identifier suffix
+}
+
+// newVocabulary returns the vocabulary of the identifiers suffixes below
+// prefix. Each identifier gets its suffix, lowercased with slashes replaced
+// by dashes, as shorthand ("CA/QC" is "ca-qc"), in addition to shorthands.
+func newVocabulary(name, prefix string, suffixes []string, shorthands map[string]string) *vocabulary {
+	v := &vocabulary{
+		name:    name,
+		prefix:  prefix,
+		aliases: make(map[string]string),
+		known:   make(map[string]string),
+	}
+	for _, suffix := range suffixes {
+		v.aliases[vocabularyAlias(suffix)] = suffix
+		v.known[normalizeURI(prefix+suffix)] = suffix
+	}
+	for alias, suffix := range shorthands {
+		v.aliases[alias] = suffix
+	}
+	return v
+}
+
+// vocabularyAlias returns the shorthand of an identifier suffix.
+func vocabularyAlias(suffix string) string {
+	return strings.ReplaceAll(strings.ToLower(suffix), "/", "-")
+}
+
+var (
+	serviceTypeVocabulary   = newVocabulary("serviceType", serviceTypePrefix, serviceTypes, serviceTypeShorthands)
+	serviceStatusVocabulary = newVocabulary("status", serviceStatusPrefix, serviceStatuses, nil)
+)
+
+// expand returns the URI value stands for: the ETSI URI of a shorthand, or
+// value itself if it is one of the URIs of the vocabulary, with or without
+// https and a trailing slash. Other values are rejected, with a suggestion,
+// unless allowCustom is set and value is an absolute URI.
+func (v *vocabulary) expand(value string, allowCustom bool) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("%s is required", v.name)
+	}
+	if suffix, ok := v.aliases[strings.ToLower(value)]; ok {
+		return "http://" + v.prefix + suffix, nil
+	}
+	if _, ok := v.known[normalizeURI(value)]; ok {
+		return value, nil
+	}
+	if allowCustom {
+		if u, err := url.Parse(value); err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "") {
+			return value, nil
+		}
+		return "", fmt.Errorf("invalid %s %q: custom values must be absolute URIs", v.name, value)
+	}
+
+	msg := fmt.Sprintf("unknown %s %q: not an ETSI TS 119 612 identifier or shorthand", v.name, value)
+	if suggestion := v.suggest(value); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	return "", fmt.Errorf("%s (set allowCustomURIs: true to use other URIs)", msg)
+}
+
+// suggest returns the shorthand or URI of the vocabulary closest to value,
+// or "" if none is close.
+func (v *vocabulary) suggest(value string) string {
+	normalized := normalizeURI(value)
+	if strings.HasPrefix(strings.ToLower(normalized), strings.ToLower(v.prefix)) {
+		suffixes := make([]string, 0, len(v.known))
+		for _, suffix := range v.known {
+			suffixes = append(suffixes, suffix)
+		}
+		sort.Strings(suffixes)
+		if suffix := yamlutil.Suggest(normalized[len(v.prefix):], suffixes); suffix != "" {
+			return "http://" + v.prefix + suffix
+		}
+		return ""
+	}
+	aliases := make([]string, 0, len(v.aliases))
+	for alias := range v.aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return yamlutil.Suggest(strings.ToLower(value), aliases)
+}
+
+// expandServiceURIs validates the service type and status of metadata and
+// replaces shorthands with the URIs they stand for.
+func (metadata *CertificateMetadata) expandServiceURIs() error {
+	serviceType, err := serviceTypeVocabulary.expand(metadata.ServiceType, metadata.AllowCustomURIs)
+	if err != nil {
+		return err
+	}
+	status, err := serviceStatusVocabulary.expand(metadata.Status, metadata.AllowCustomURIs)
+	if err != nil {
+		return err
+	}
+	metadata.ServiceType, metadata.Status = serviceType, status
+	return nil
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVocabularyExpand(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"ca-qc", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"},
+		{"CA-QC", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"},
+		{"ocsp-qc", "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP/QC"},
+		{"certstatus-ocsp-qc", "http://uri.etsi.org/TrstSvc/Svctype/Certstatus/OCSP/QC"},
+		{"tsa-qtst", "http://uri.etsi.org/TrstSvc/Svctype/TSA/QTST"},
+		{"http://uri.etsi.org/TrstSvc/Svctype/CA/PKC", "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"},
+		{"https://uri.etsi.org/TrstSvc/Svctype/CA/PKC/", "https://uri.etsi.org/TrstSvc/Svctype/CA/PKC/"},
+	}
+	for _, tt := range tests {
+		got, err := serviceTypeVocabulary.expand(tt.value, false)
+		require.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, got, tt.value)
+	}
+
+	got, err := serviceStatusVocabulary.expand("granted", false)
+	require.NoError(t, err)
+	assert.Equal(t, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted", got)
+	got, err = serviceStatusVocabulary.expand(etsi119612.ServiceStatusGranted, false)
+	require.NoError(t, err)
+	assert.Equal(t, etsi119612.ServiceStatusGranted, got)
+
+	for suffix := range qualifiedServiceTypes {
+		_, err := serviceTypeVocabulary.expand(vocabularyAlias(suffix), false)
+		assert.NoError(t, err, suffix)
+	}
+}
+
+func TestVocabularyExpand_Invalid(t *testing.T) {
+	_, err := serviceTypeVocabulary.expand("ca-qq", false)
+	assert.EqualError(t, err, `unknown serviceType "ca-qq": not an ETSI TS 119 612 identifier or shorthand, did you mean "ca-qc"? (set allowCustomURIs: true to use other URIs)`)
+
+	_, err = serviceTypeVocabulary.expand("http://uri.etsi.org/TrstSvc/Svctype/CA/QQ", false)
+	assert.ErrorContains(t, err, `did you mean "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"?`)
+
+	_, err = serviceStatusVocabulary.expand("https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/grantd/", false)
+	assert.ErrorContains(t, err, `did you mean "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"?`)
+
+	_, err = serviceStatusVocabulary.expand("", false)
+	assert.EqualError(t, err, "status is required")
+
+	_, err = serviceStatusVocabulary.expand("http://example.com/status/active", false)
+	assert.ErrorContains(t, err, "unknown status")
+
+	got, err := serviceStatusVocabulary.expand("http://example.com/status/active", true)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com/status/active", got)
+	_, err = serviceStatusVocabulary.expand("active", true)
+	assert.ErrorContains(t, err, "custom values must be absolute URIs")
+}
+
+func TestAddProviderCertificates_ServiceURIs(t *testing.T) {
+	cert, err := testutil.NewSelfSigned("Example CA")
+	require.NoError(t, err)
+
+	write := func(t *testing.T, metadata string) string {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), cert.CertPEM(), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.yaml"), []byte("serviceNames:\n  - language: en\n    value: Example CA\n"+metadata), 0644))
+		return dir
+	}
+
+	t.Run("shorthands", func(t *testing.T) {
+		provider := &etsi119612.TSPType{TslTSPServices: &etsi119612.TSPServicesListType{}}
+		require.NoError(t, addProviderCertificates(write(t, "serviceType: ca-qc\nstatus: granted\n"), provider))
+		info := provider.TslTSPServices.TslTSPService[0].TslServiceInformation
+		assert.Equal(t, "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", info.TslServiceTypeIdentifier)
+		assert.Equal(t, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted", info.TslServiceStatus)
+	})
+
+	t.Run("unknown status", func(t *testing.T) {
+		dir := write(t, "serviceType: ca-qc\nstatus: grnted\n")
+		err := addProviderCertificates(dir, &etsi119612.TSPType{TslTSPServices: &etsi119612.TSPServicesListType{}})
+		assert.ErrorContains(t, err, "invalid certificate metadata in "+filepath.Join(dir, "ca.yaml"))
+		assert.ErrorContains(t, err, `unknown status "grnted"`)
+		assert.ErrorContains(t, err, `did you mean "granted"?`)
+	})
+
+	t.Run("missing service type", func(t *testing.T) {
+		err := addProviderCertificates(write(t, "status: granted\n"), &etsi119612.TSPType{TslTSPServices: &etsi119612.TSPServicesListType{}})
+		assert.ErrorContains(t, err, "serviceType is required")
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		provider := &etsi119612.TSPType{TslTSPServices: &etsi119612.TSPServicesListType{}}
+		require.NoError(t, addProviderCertificates(write(t, "serviceType: https://example.com/svctype/wallet\nstatus: granted\nallowCustomURIs: true\n"), provider))
+		assert.Equal(t, "https://example.com/svctype/wallet", provider.TslTSPServices.TslTSPService[0].TslServiceInformation.TslServiceTypeIdentifier)
+	})
+}
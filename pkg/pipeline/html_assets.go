@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Ways the HTML pages of generate_index, generate-provider-pages and
+// transform load their stylesheets and scripts, selected with the "assets:"
+// option of these steps.
+const (
+	AssetsCDN    = "cdn"    // Link Pico CSS on its CDN, embed the page's own styles and scripts (default)
+	AssetsInline = "inline" // Embed all styles and scripts in each page
+	AssetsCopy   = "copy"   // Write them to versioned files in the assets directory and link them
+)
+
+// assetsDir is the directory, below the output directory of a step, that
+// the assets of its pages are copied to with "assets:copy".
+const assetsDir = "assets"
+
+// picoCSSURL is the Pico CSS stylesheet the pages link with "assets:cdn".
+const picoCSSURL = "https://cdn.jsdelivr.net/npm/@picocss/pico@1/css/pico.min.css"
+
+//go:embed templates/base.css
+var baseCSS string
+
+// htmlAssets holds the "assets:" and "stylesheet:" options of a step writing
+// HTML pages.
+type htmlAssets struct {
+	mode       string // AssetsCDN, AssetsInline or AssetsCopy; "" is AssetsCDN
+	stylesheet string // File replacing the built-in base stylesheet, such as a local copy of pico.min.css
+}
+
+// parseOption applies arg to a if it is an "assets:" or "stylesheet:"
+// option, and reports whether it was.
+func (a *htmlAssets) parseOption(arg string) (bool, error) {
+	switch {
+	case strings.HasPrefix(arg, "assets:"):
+		mode := strings.TrimPrefix(arg, "assets:")
+		if mode != AssetsCDN && mode != AssetsInline && mode != AssetsCopy {
+			return true, fmt.Errorf("invalid option %s: expected assets:%s, assets:%s or assets:%s", arg, AssetsCDN, AssetsInline, AssetsCopy)
+		}
+		a.mode = mode
+	case strings.HasPrefix(arg, "stylesheet:"):
+		a.stylesheet = strings.TrimPrefix(arg, "stylesheet:")
+		if a.stylesheet == "" {
+			return true, fmt.Errorf("invalid option %s: the stylesheet path is empty", arg)
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// check reports options that do not go together.
+func (a htmlAssets) check() error {
+	if a.stylesheet != "" && !a.selfContained() {
+		return fmt.Errorf("the stylesheet: option requires assets:%s or assets:%s", AssetsInline, AssetsCopy)
+	}
+	return nil
+}
+
+// selfContained reports whether pages load nothing from a CDN.
+func (a htmlAssets) selfContained() bool {
+	return a.mode == AssetsInline || a.mode == AssetsCopy
+}
+
+// pageAssets are the stylesheets and scripts of a page, as template data.
+type pageAssets struct {
+	Stylesheets []string     // URLs of the stylesheets to link
+	CSS         template.CSS // Styles to embed
+	Scripts     []string     // URLs of the scripts to load
+	JavaScript  template.JS  // Script to embed
+
+	files map[string][]byte // Files to write below the output directory, by path
+}
+
+// page returns the assets of pages with the styles css and the script js,
+// named name when copied. With the built-in base stylesheet or the one of
+// the stylesheet: option, the pages need no CDN. Asset URLs are relative to
+// the output directory; see relativeTo.
+func (a htmlAssets) page(name, css, js string) (pageAssets, error) {
+	var p pageAssets
+	if !a.selfContained() {
+		p.Stylesheets = []string{picoCSSURL}
+		p.CSS = template.CSS(css)
+		p.JavaScript = template.JS(js)
+		return p, nil
+	}
+
+	baseName, base := "base", baseCSS
+	if a.stylesheet != "" {
+		data, err := os.ReadFile(a.stylesheet)
+		if err != nil {
+			return p, fmt.Errorf("failed to read stylesheet: %w", err)
+		}
+		baseName = strings.TrimSuffix(filepath.Base(a.stylesheet), ".css")
+		base = string(data)
+	}
+
+	if a.mode == AssetsInline {
+		p.CSS = template.CSS(joinNonEmpty(base, css))
+		p.JavaScript = template.JS(js)
+		return p, nil
+	}
+
+	p.files = make(map[string][]byte)
+	p.Stylesheets = append(p.Stylesheets, p.addFile(baseName, "css", base))
+	if css != "" {
+		p.Stylesheets = append(p.Stylesheets, p.addFile(name, "css", css))
+	}
+	if js != "" {
+		p.Scripts = append(p.Scripts, p.addFile(name, "js", js))
+	}
+	return p, nil
+}
+
+// addFile adds content to the files of p, named after name and the start of
+// its SHA-256 digest so that a changed asset gets a new URL, and returns its
+// path.
+func (p *pageAssets) addFile(name, ext, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	file := path.Join(assetsDir, fmt.Sprintf("%s-%s.%s", name, hex.EncodeToString(sum[:6]), ext))
+	p.files[file] = []byte(content)
+	return file
+}
+
+// relativeTo returns p with the URLs of copied assets prefixed by prefix, the
+// path from a page to the output directory, such as "../../".
+func (p pageAssets) relativeTo(prefix string) pageAssets {
+	if prefix == "" || len(p.files) == 0 {
+		return p
+	}
+	prefixed := func(urls []string) []string {
+		out := make([]string, len(urls))
+		for i, u := range urls {
+			if _, ok := p.files[u]; ok {
+				u = prefix + u
+			}
+			out[i] = u
+		}
+		return out
+	}
+	p.Stylesheets = prefixed(p.Stylesheets)
+	p.Scripts = prefixed(p.Scripts)
+	return p
+}
+
+// write writes the copied assets of p below outputDir. Assets already there
+// are kept, as their names change with their content.
+func (p pageAssets) write(outputDir string) error {
+	for file, data := range p.files {
+		target := filepath.Join(outputDir, filepath.FromSlash(file))
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create assets directory: %w", err)
+		}
+		if err := writeFileAtomic(target, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinNonEmpty joins the non-empty parts with newlines.
+func joinNonEmpty(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "\n")
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLAssets_ParseOption(t *testing.T) {
+	var assets htmlAssets
+	for _, arg := range []string{"assets:copy", "stylesheet:/usr/share/pico/pico.min.css"} {
+		ok, err := assets.parseOption(arg)
+		require.NoError(t, err, arg)
+		assert.True(t, ok, arg)
+	}
+	assert.Equal(t, htmlAssets{mode: AssetsCopy, stylesheet: "/usr/share/pico/pico.min.css"}, assets)
+	assert.NoError(t, assets.check())
+
+	ok, err := assets.parseOption("Trust lists")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, bad := range []string{"assets:offline", "assets:", "stylesheet:"} {
+		_, err := new(htmlAssets).parseOption(bad)
+		assert.ErrorContains(t, err, "invalid option", bad)
+	}
+	assert.Error(t, htmlAssets{stylesheet: "pico.min.css"}.check(), "a stylesheet is not used with the CDN")
+}
+
+func TestHTMLAssets_Page(t *testing.T) {
+	t.Run("CDN", func(t *testing.T) {
+		page, err := htmlAssets{}.page("index", "h1 {}", "run()")
+		require.NoError(t, err)
+		assert.Equal(t, []string{picoCSSURL}, page.Stylesheets)
+		assert.Equal(t, "h1 {}", string(page.CSS))
+		assert.Equal(t, "run()", string(page.JavaScript))
+		assert.Empty(t, page.files)
+	})
+
+	t.Run("Inline", func(t *testing.T) {
+		page, err := htmlAssets{mode: AssetsInline}.page("index", "h1 {}", "run()")
+		require.NoError(t, err)
+		assert.Empty(t, page.Stylesheets)
+		assert.True(t, strings.HasPrefix(string(page.CSS), baseCSS))
+		assert.True(t, strings.HasSuffix(string(page.CSS), "h1 {}"))
+		assert.Equal(t, "run()", string(page.JavaScript))
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		stylesheet := filepath.Join(t.TempDir(), "pico.min.css")
+		require.NoError(t, os.WriteFile(stylesheet, []byte("body {}"), 0644))
+		page, err := htmlAssets{mode: AssetsCopy, stylesheet: stylesheet}.page("index", "h1 {}", "run()")
+		require.NoError(t, err)
+		assert.Empty(t, page.CSS)
+		assert.Empty(t, page.JavaScript)
+		require.Len(t, page.Stylesheets, 2)
+		assert.Regexp(t, `^assets/pico\.min-[0-9a-f]{12}\.css$`, page.Stylesheets[0])
+		assert.Regexp(t, `^assets/index-[0-9a-f]{12}\.css$`, page.Stylesheets[1])
+		require.Len(t, page.Scripts, 1)
+		assert.Regexp(t, `^assets/index-[0-9a-f]{12}\.js$`, page.Scripts[0])
+
+		changed, err := htmlAssets{mode: AssetsCopy}.page("index", "h2 {}", "run()")
+		require.NoError(t, err)
+		assert.NotEqual(t, page.Stylesheets[1], changed.Stylesheets[1], "changed styles get a new URL")
+		assert.Equal(t, page.Scripts, changed.Scripts)
+
+		dir := t.TempDir()
+		require.NoError(t, page.write(dir))
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(page.Stylesheets[0])))
+		require.NoError(t, err)
+		assert.Equal(t, "body {}", string(data))
+
+		nested := page.relativeTo("../../")
+		assert.Equal(t, "../../"+page.Stylesheets[0], nested.Stylesheets[0])
+		assert.Equal(t, "../../"+page.Scripts[0], nested.Scripts[0])
+	})
+
+	t.Run("Missing stylesheet", func(t *testing.T) {
+		_, err := htmlAssets{mode: AssetsInline, stylesheet: filepath.Join(t.TempDir(), "missing.css")}.page("index", "", "")
+		assert.ErrorContains(t, err, "failed to read stylesheet")
+	})
+}
@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
+)
+
+//go:embed templates/messages.yaml
+var htmlMessagesYAML []byte
+
+// builtinHTMLMessages are the labels of the HTML pages of generate_index and
+// generate-provider-pages, by language and then key.
+var builtinHTMLMessages = func() map[string]map[string]string {
+	var messages map[string]map[string]string
+	if err := yamlutil.Unmarshal(htmlMessagesYAML, &messages); err != nil {
+		panic(fmt.Sprintf("invalid embedded HTML messages: %v", err))
+	}
+	return messages
+}()
+
+// messageVerbs matches the fmt verbs of a message.
+var messageVerbs = regexp.MustCompile(`%[a-z]`)
+
+// htmlPageOptions holds the options of the steps writing HTML pages with
+// their own templates: the "assets:" and "stylesheet:" options of
+// htmlAssets, "lang:" and "messages:".
+type htmlPageOptions struct {
+	assets   htmlAssets
+	lang     string // Language of the labels; the configured language preference if empty
+	messages string // Message catalog file over the built-in labels
+}
+
+// parseOption applies arg to o if it is one of its options, and reports
+// whether it was.
+func (o *htmlPageOptions) parseOption(arg string) (bool, error) {
+	if ok, err := o.assets.parseOption(arg); ok {
+		return ok, err
+	}
+	switch {
+	case strings.HasPrefix(arg, "lang:"):
+		o.lang = strings.TrimPrefix(arg, "lang:")
+		if err := i18n.ValidateLanguages([]string{o.lang}); err != nil {
+			return true, fmt.Errorf("invalid option %s: %w", arg, err)
+		}
+	case strings.HasPrefix(arg, "messages:"):
+		o.messages = strings.TrimPrefix(arg, "messages:")
+		if o.messages == "" {
+			return true, fmt.Errorf("invalid option %s: the message catalog path is empty", arg)
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// check reports options that do not go together.
+func (o htmlPageOptions) check() error {
+	return o.assets.check()
+}
+
+// labels returns the labels of pages in the language of the "lang:" option,
+// or else of the configured language preference (see
+// i18n.SetPreferredLanguages), from the built-in messages and those of the
+// "messages:" option.
+func (o htmlPageOptions) labels() (*pageLabels, error) {
+	messages, err := loadHTMLMessages(o.messages)
+	if err != nil {
+		return nil, err
+	}
+	langs := i18n.PreferredLanguages()
+	if o.lang != "" {
+		langs = []string{o.lang}
+	}
+	return newPageLabels(messages, langs), nil
+}
+
+// loadHTMLMessages returns the built-in page labels with those of the YAML
+// file at path, if path is not empty, over them. The file maps language tags
+// to keys to messages, like templates/messages.yaml:
+//
+//	nb:
+//	  total_tsls: "Tillitslister"
+//	  footer_tsls: "%d tillitslister"
+//
+// It returns an error for a file that cannot be read, an invalid language
+// tag, an unknown key, an empty message or a message whose %d and %s verbs
+// differ from those of the English one.
+func loadHTMLMessages(path string) (map[string]map[string]string, error) {
+	messages := make(map[string]map[string]string, len(builtinHTMLMessages))
+	for lang, msgs := range builtinHTMLMessages {
+		messages[lang] = msgs
+	}
+	if path == "" {
+		return messages, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message catalog: %w", err)
+	}
+	var file map[string]map[string]string
+	if err := yamlutil.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse message catalog %s: %w", path, err)
+	}
+	english := builtinHTMLMessages[i18n.DefaultLanguage]
+	for lang, msgs := range file {
+		if err := i18n.ValidateLanguages([]string{lang}); err != nil {
+			return nil, fmt.Errorf("message catalog %s: %w", path, err)
+		}
+		merged := make(map[string]string, len(english))
+		for key, msg := range messages[lang] {
+			merged[key] = msg
+		}
+		for key, msg := range msgs {
+			original, ok := english[key]
+			if !ok {
+				return nil, fmt.Errorf("message catalog %s: unknown key %q in language %q", path, key, lang)
+			}
+			if msg == "" {
+				return nil, fmt.Errorf("message catalog %s: empty message for %q in language %q", path, key, lang)
+			}
+			if want, got := messageVerbs.FindAllString(original, -1), messageVerbs.FindAllString(msg, -1); strings.Join(want, "") != strings.Join(got, "") {
+				return nil, fmt.Errorf("message catalog %s: message for %q in language %q must have the verbs %v of %q", path, key, lang, want, original)
+			}
+			merged[key] = msg
+		}
+		messages[lang] = merged
+	}
+	return messages, nil
+}
+
+// pageLabels are the labels of a page in the languages best matching a
+// preference, each label falling back to English if its best language has
+// none.
+type pageLabels struct {
+	lang     string            // Language of the page
+	messages map[string]string // Label by key
+}
+
+// newPageLabels returns the labels of messages best matching langs.
+func newPageLabels(messages map[string]map[string]string, langs []string) *pageLabels {
+	available := make([]string, 0, len(messages))
+	for lang := range messages {
+		available = append(available, lang)
+	}
+	// Sorted, so that languages matching equally well are picked consistently
+	sort.Strings(available)
+
+	labels := &pageLabels{lang: i18n.Match(available, langs), messages: make(map[string]string)}
+	for key := range messages[i18n.DefaultLanguage] {
+		var have []string
+		for _, lang := range available {
+			if messages[lang][key] != "" {
+				have = append(have, lang)
+			}
+		}
+		labels.messages[key] = messages[i18n.Match(have, langs)][key]
+	}
+	return labels
+}
+
+// text returns the label of key, formatted with args if given. It is the "t"
+// function of page templates.
+func (l *pageLabels) text(key string, args ...any) (string, error) {
+	msg, ok := l.messages[key]
+	if !ok {
+		return "", fmt.Errorf("unknown label %q", key)
+	}
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	return msg, nil
+}
+
+// funcs returns the template functions of the labels.
+func (l *pageLabels) funcs() template.FuncMap {
+	return template.FuncMap{"t": l.text}
+}
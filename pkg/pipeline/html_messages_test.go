@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinHTMLMessages(t *testing.T) {
+	english := builtinHTMLMessages[i18n.DefaultLanguage]
+	require.NotEmpty(t, english)
+	for lang, messages := range builtinHTMLMessages {
+		for key, msg := range messages {
+			original, ok := english[key]
+			if !assert.True(t, ok, "%s: unknown key %s", lang, key) {
+				continue
+			}
+			assert.NotEmpty(t, msg, "%s: %s", lang, key)
+			assert.Equal(t, messageVerbs.FindAllString(original, -1), messageVerbs.FindAllString(msg, -1), "%s: %s", lang, key)
+		}
+	}
+	for _, tmpl := range []string{indexHTMLTemplate, providerHTMLTemplate} {
+		for _, key := range messageKeys(tmpl) {
+			assert.Contains(t, english, key, "templates use known labels")
+		}
+	}
+}
+
+// messageKeys returns the keys of the labels a template uses.
+func messageKeys(tmpl string) []string {
+	var keys []string
+	for _, part := range strings.Split(tmpl, `{{ t "`)[1:] {
+		key, _, _ := strings.Cut(part, `"`)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestLoadHTMLMessages(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "messages.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	messages, err := loadHTMLMessages(write(`
+nb:
+  total_tsls: "Tillitslister"
+  footer_tsls: "%d tillitslister"
+sv:
+  total_tsls: "Listor"
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "Tillitslister", messages["nb"]["total_tsls"])
+	assert.Equal(t, "Listor", messages["sv"]["total_tsls"], "a catalog replaces built-in labels")
+	assert.Equal(t, builtinHTMLMessages["sv"]["trust_services"], messages["sv"]["trust_services"], "and keeps the others")
+	assert.Equal(t, "Förtroendelistor", builtinHTMLMessages["sv"]["total_tsls"], "the built-in labels are unchanged")
+
+	for content, want := range map[string]string{
+		"nb:\n  tsls: \"Lister\"\n":                    "unknown key",
+		"nb:\n  total_tsls: \"\"\n":                    "empty message",
+		"nb:\n  footer_tsls: \"Lister\"\n":             "verbs",
+		"nb:\n  footer_tsls: \"%s lister\"\n":          "verbs",
+		"not a language!:\n  total_tsls: \"Lister\"\n": "invalid language tag",
+		"nb: [\n": "failed to parse",
+	} {
+		_, err := loadHTMLMessages(write(content))
+		assert.ErrorContains(t, err, want, content)
+	}
+	_, err = loadHTMLMessages(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read message catalog")
+}
+
+func TestPageLabels(t *testing.T) {
+	messages := map[string]map[string]string{
+		"en": {"total_tsls": "Total TSLs", "footer_tsls": "%d Trust Status Lists"},
+		"nb": {"total_tsls": "Tillitslister"},
+	}
+
+	labels := newPageLabels(messages, []string{"nb-NO"})
+	assert.Equal(t, "nb", labels.lang)
+	text, err := labels.text("total_tsls")
+	require.NoError(t, err)
+	assert.Equal(t, "Tillitslister", text)
+	text, err = labels.text("footer_tsls", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "3 Trust Status Lists", text, "missing labels fall back to English")
+	_, err = labels.text("missing")
+	assert.Error(t, err)
+
+	labels = newPageLabels(messages, []string{"fi"})
+	assert.Equal(t, "en", labels.lang)
+}
+
+func TestHTMLPageOptions_ParseOption(t *testing.T) {
+	var opts htmlPageOptions
+	for _, arg := range []string{"lang:sv", "messages:/etc/go-trust/messages.yaml", "assets:inline"} {
+		ok, err := opts.parseOption(arg)
+		require.NoError(t, err, arg)
+		assert.True(t, ok, arg)
+	}
+	assert.Equal(t, htmlPageOptions{assets: htmlAssets{mode: AssetsInline}, lang: "sv", messages: "/etc/go-trust/messages.yaml"}, opts)
+
+	for _, bad := range []string{"lang:", "lang:not a language!", "messages:"} {
+		_, err := new(htmlPageOptions).parseOption(bad)
+		assert.ErrorContains(t, err, "invalid option", bad)
+	}
+}
@@ -4,16 +4,20 @@ package pipeline
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/validation"
 )
 
 //go:embed templates/index.html
@@ -25,32 +29,126 @@ var indexCSS string
 //go:embed templates/index.js
 var indexJavaScript string
 
+// indexManifestFile is the machine-readable manifest generate_index writes
+// next to index.html.
+const indexManifestFile = "index.json"
+
+// indexColumns are the columns of the TSL table of the index, in their
+// default order, that the "columns:" option of generate_index selects from.
+// Each is also the key of its header label.
+var indexColumns = []string{
+	"territory",          // Territory badge and title, linking the TSL page
+	"sequence",           // Sequence number
+	"issued",             // Issue date
+	"next_update",        // Next update date
+	"services",           // Number of trust services
+	"providers",          // Number of trust service providers
+	"qualified_services", // Number of qualified trust services
+	"signature",          // Signature verification status of the loaded TSL
+	"source",             // URL the loaded TSL was fetched from
+}
+
+// defaultIndexColumns are the columns of the TSL table without a "columns:"
+// option.
+var defaultIndexColumns = indexColumns[:5]
+
 // TSLIndexEntry represents a single Trust Service List entry in the index
 type TSLIndexEntry struct {
-	Filename     string // Name of the HTML file
-	Title        string // Title of the TSL (usually country name)
-	SchemeType   string // Type of the TSL scheme
-	Territory    string // Territory code
-	Sequence     string // Sequence number
-	IssueDate    string // Issue date of the TSL
-	NextUpdate   string // Next update date
-	URL          string // Link to the HTML file
-	TrustService int    // Number of trust services in the TSL
+	Filename          string `json:"filename"`             // Name of the HTML file
+	Title             string `json:"title"`                // Title of the TSL (usually country name)
+	SchemeType        string `json:"scheme_type"`          // Type of the TSL scheme
+	Territory         string `json:"territory"`            // Territory code
+	Sequence          string `json:"sequence"`             // Sequence number
+	IssueDate         string `json:"issue_date"`           // Issue date of the TSL
+	NextUpdate        string `json:"next_update"`          // Next update date
+	URL               string `json:"url"`                  // Link to the HTML file
+	TrustService      int    `json:"trust_services"`       // Number of trust services in the TSL
+	Providers         int    `json:"providers"`            // Number of trust service providers in the TSL
+	QualifiedServices int    `json:"qualified_services"`   // Number of qualified trust services in the TSL
+	Signature         string `json:"signature,omitempty"`  // Signature status of the loaded TSL (see TSLSignature), if verified
+	SourceURL         string `json:"source_url,omitempty"` // URL the loaded TSL was fetched from, if loaded
+}
+
+// ProviderIndexEntry represents the page of a trust service provider, written
+// by generate-provider-pages, in the index
+type ProviderIndexEntry struct {
+	Name         string `json:"name"`         // Name of the provider
+	Territory    string `json:"territory"`    // Territory code
+	URL          string `json:"url"`          // Link to the provider page
+	Services     int    `json:"services"`     // Number of trust services of the provider
+	Certificates int    `json:"certificates"` // Number of certificates of the services
+}
+
+// IndexManifest is the index.json file written by generate_index next to
+// index.html. It lists the same TSLs and provider pages with all their
+// metadata, whatever the columns of the page, for portals presenting them
+// in their own way.
+type IndexManifest struct {
+	Title     string               `json:"title"`     // Title of the index page
+	Generated time.Time            `json:"generated"` // When the index was generated
+	Columns   []string             `json:"columns"`   // Columns of the TSL table of the page
+	TSLs      []TSLIndexEntry      `json:"tsls"`      // TSL pages, ordered by territory
+	Providers []ProviderIndexEntry `json:"providers"` // Provider pages, ordered by territory and name
 }
 
 // GenerateIndex creates an index.html file in the specified directory.
 // The index page lists all TSL HTML files in the directory with metadata and links.
-// The index uses PicoCSS for styling to match the TSL HTML files.
+// Provider pages written to the directory by generate-provider-pages are listed
+// below the TSLs. The index uses PicoCSS for styling to match the TSL HTML files.
+// An index.json manifest (see IndexManifest) with the metadata of all
+// columns is written next to index.html.
+//
+// Provider and qualified service counts are read from the TSL HTML files.
+// The signature status and source URL are those of the TSL loaded in the
+// pipeline with the same territory and sequence number, if any.
 //
 // Arguments:
 //   - arg[0]: Directory path containing TSL HTML files
-//   - arg[1]: (Optional) Title for the index page (default: "Trust Service Lists Index")
+//   - arg[1]: (Optional) Title for the index page (default: "Trust Service Lists Index",
+//     in the language of the labels)
+//   - "assets:cdn", "assets:inline" or "assets:copy": (Optional) Link PicoCSS on
+//     its CDN (default), or, for deployments without Internet access, embed a
+//     built-in base stylesheet and the index's styles and script in the page
+//     or copy them to versioned files in the assets directory
+//   - "stylesheet:PATH": (Optional) With assets:inline or assets:copy, use
+//     this stylesheet, such as a local copy of pico.min.css, instead of the
+//     built-in one
+//   - "lang:TAG": (Optional) Language of the labels, such as "sv" (default: the
+//     configured language preference, see i18n.SetPreferredLanguages). Labels
+//     are built in for English, Swedish, German and French
+//   - "messages:PATH": (Optional) YAML message catalog adding languages or
+//     replacing built-in labels (see loadHTMLMessages)
+//   - "columns:NAME,...": (Optional) Columns of the TSL table, in order, from
+//     territory, sequence, issued, next_update, services, providers,
+//     qualified_services, signature and source (default: the first five).
+//     The territory column, which links the TSL pages, is required
 //
 // Example usage in pipeline YAML:
 //
 //   - generate_index:
 //   - /path/to/output/directory
 //   - "EU Trust Lists - Index"
+//
+// OR for an air-gapped deployment:
+//
+//   - generate_index:
+//   - /path/to/output/directory
+//   - "EU Trust Lists - Index"
+//   - assets:copy
+//   - stylesheet:/usr/share/pico/pico.min.css
+//
+// OR in Norwegian, from a catalog of Norwegian labels:
+//
+//   - generate_index:
+//   - /path/to/output/directory
+//   - lang:nb
+//   - messages:/etc/go-trust/messages-nb.yaml
+//
+// OR with the signature status and source of each TSL:
+//
+//   - generate_index:
+//   - /path/to/output/directory
+//   - columns:territory,sequence,issued,next_update,providers,qualified_services,signature,source
 func GenerateIndex(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	if len(args) < 1 {
 		return ctx, fmt.Errorf("missing required directory path argument")
@@ -58,9 +156,40 @@ func GenerateIndex(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 
 	// Parse arguments
 	dirPath := args[0]
-	title := "Trust Service Lists Index"
-	if len(args) >= 2 {
-		title = args[1]
+	title := ""
+	var opts htmlPageOptions
+	columns := defaultIndexColumns
+	positional := 0
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, "columns:") {
+			var err error
+			if columns, err = parseIndexColumns(strings.TrimPrefix(arg, "columns:")); err != nil {
+				return ctx, fmt.Errorf("invalid option %s: %w", arg, err)
+			}
+			continue
+		}
+		ok, err := opts.parseOption(arg)
+		if err != nil {
+			return ctx, err
+		}
+		if ok {
+			continue
+		}
+		if positional > 0 {
+			return ctx, fmt.Errorf("unexpected argument %q", arg)
+		}
+		title = arg
+		positional++
+	}
+	if err := opts.check(); err != nil {
+		return ctx, err
+	}
+	labels, err := opts.labels()
+	if err != nil {
+		return ctx, err
+	}
+	if title == "" {
+		title, _ = labels.text("index_title")
 	}
 
 	// Check if the directory exists
@@ -74,6 +203,11 @@ func GenerateIndex(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 	if !info.IsDir() {
 		return ctx, fmt.Errorf("%s is not a directory", dirPath)
 	}
+	unlock, err := ctx.lockOutput(dirPath)
+	if err != nil {
+		return ctx, err
+	}
+	defer unlock()
 
 	// Find all HTML files in the directory
 	entries, err := findTSLHtmlFiles(dirPath)
@@ -85,15 +219,95 @@ func GenerateIndex(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 		return ctx, fmt.Errorf("no TSL HTML files found in %s", dirPath)
 	}
 
+	providers, err := findProviderPages(dirPath)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to read provider pages: %w", err)
+	}
+
+	annotateIndexEntries(ctx, entries)
+
 	// Generate the index.html file
-	err = generateIndexHTML(dirPath, entries, title)
+	now := time.Now()
+	err = generateIndexHTML(dirPath, entries, providers, title, columns, now, opts.assets, labels)
 	if err != nil {
 		return ctx, fmt.Errorf("failed to generate index.html: %w", err)
 	}
 
+	manifest := IndexManifest{
+		Title:     title,
+		Generated: now.UTC(),
+		Columns:   columns,
+		TSLs:      entries,
+		Providers: providers,
+	}
+	if manifest.Providers == nil {
+		manifest.Providers = []ProviderIndexEntry{}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return ctx, fmt.Errorf("failed to encode %s: %w", indexManifestFile, err)
+	}
+	if err := writeFileAtomic(filepath.Join(dirPath, indexManifestFile), append(data, '\n')); err != nil {
+		return ctx, err
+	}
+
 	return ctx, nil
 }
 
+// parseIndexColumns parses the comma-separated column names of the
+// "columns:" option of generate_index.
+func parseIndexColumns(spec string) ([]string, error) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if !containsString(indexColumns, name) {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", name, strings.Join(indexColumns, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+		columns = append(columns, name)
+	}
+	if !seen["territory"] {
+		return nil, fmt.Errorf("the territory column is required")
+	}
+	return columns, nil
+}
+
+// annotateIndexEntries sets the signature status and source URL of the
+// entries from the TSLs loaded in ctx with the same territory and sequence
+// number. Entries of TSLs that are not loaded are left unchanged.
+func annotateIndexEntries(ctx *Context, entries []TSLIndexEntry) {
+	loaded := make(map[string]*etsi119612.TSL)
+	for _, tsl := range ctx.AllTSLs() {
+		si := tsl.StatusList.TslSchemeInformation
+		if si == nil {
+			continue
+		}
+		key := territoryOf(tsl) + "/" + strconv.Itoa(si.TSLSequenceNumber)
+		if _, ok := loaded[key]; !ok {
+			loaded[key] = tsl
+		}
+	}
+	if len(loaded) == 0 {
+		return
+	}
+
+	for i := range entries {
+		territory, _ := validation.NormalizeTerritory(entries[i].Territory)
+		tsl, ok := loaded[territory+"/"+entries[i].Sequence]
+		if !ok {
+			continue
+		}
+		entries[i].SourceURL = tsl.Source
+		if sig := ctx.SignatureOf(tsl); sig != nil {
+			entries[i].Signature = sig.Status
+		}
+	}
+}
+
 // findTSLHtmlFiles scans a directory for TSL HTML files and extracts metadata from them
 func findTSLHtmlFiles(dirPath string) ([]TSLIndexEntry, error) {
 	var entries []TSLIndexEntry
@@ -103,17 +317,22 @@ func findTSLHtmlFiles(dirPath string) ([]TSLIndexEntry, error) {
 			return err
 		}
 
-		// Skip directories and non-HTML files
-		if d.IsDir() || filepath.Ext(path) != ".html" || filepath.Base(path) == "index.html" {
-			return nil
-		}
-
 		// Get the relative path for the URL
 		relPath, err := filepath.Rel(dirPath, path)
 		if err != nil {
 			return err
 		}
 
+		// Provider pages are listed separately
+		if d.IsDir() && relPath == providerPagesDir {
+			return filepath.SkipDir
+		}
+
+		// Skip directories and non-HTML files
+		if d.IsDir() || filepath.Ext(path) != ".html" || filepath.Base(path) == "index.html" {
+			return nil
+		}
+
 		// Extract metadata from the HTML file
 		entry, err := extractMetadataFromHTML(path, relPath)
 		if err != nil {
@@ -186,58 +405,127 @@ func extractMetadataFromHTML(filePath, relPath string) (TSLIndexEntry, error) {
 	// Extract TSL type
 	entry.SchemeType = doc.Find(".tsl-meta code").First().Text()
 
-	// Extract sequence number
-	seq := doc.Find(".tsl-meta:contains('TSL Sequence')").Text()
-	if idx := strings.Index(seq, "TSL Sequence #:"); idx != -1 {
-		parts := strings.Split(seq[idx:], "|")
-		if len(parts) > 0 {
-			entry.Sequence = strings.TrimSpace(strings.TrimPrefix(parts[0], "TSL Sequence #:"))
-		}
+	// Extract sequence number and dates
+	meta := doc.Find(".tsl-meta").Text()
+	entry.Sequence = tslMetaField(meta, "TSL Sequence #:")
+	entry.IssueDate = tslMetaField(meta, "Issue Date:")
+	entry.NextUpdate = tslMetaField(meta, "Next Update:")
+
+	// Count trust services, providers and qualified services
+	entry.TrustService = doc.Find(".service-card").Length()
+	entry.Providers = doc.Find(".provider-card").Length()
+	entry.QualifiedServices = doc.Find(".service-card .badge-qualified").Length()
+
+	return entry, nil
+}
+
+// tslMetaField returns the value following label in the metadata text of a
+// TSL HTML file, up to the next "|" separator or line break.
+func tslMetaField(meta, label string) string {
+	idx := strings.Index(meta, label)
+	if idx == -1 {
+		return ""
+	}
+	value := meta[idx+len(label):]
+	if end := strings.IndexAny(value, "|\n"); end != -1 {
+		value = value[:end]
 	}
+	return strings.TrimSpace(value)
+}
 
-	// Extract issue date
-	issue := doc.Find(".tsl-meta:contains('Issue Date')").Text()
-	if idx := strings.Index(issue, "Issue Date:"); idx != -1 {
-		parts := strings.Split(issue[idx:], "|")
-		if len(parts) > 0 {
-			entry.IssueDate = strings.TrimSpace(strings.TrimPrefix(parts[0], "Issue Date:"))
-		}
+// findProviderPages scans the provider pages directory below dirPath for pages
+// written by generate-provider-pages, ordered by territory and name
+func findProviderPages(dirPath string) ([]ProviderIndexEntry, error) {
+	var entries []ProviderIndexEntry
+
+	root := filepath.Join(dirPath, providerPagesDir)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
 	}
 
-	// Extract next update date
-	next := doc.Find(".tsl-meta:contains('Next Update')").Text()
-	if idx := strings.Index(next, "Next Update:"); idx != -1 {
-		parts := strings.Split(next[idx:], "|")
-		if len(parts) > 0 {
-			entry.NextUpdate = strings.TrimSpace(strings.TrimPrefix(parts[0], "Next Update:"))
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".html" {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
 		}
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+		if err != nil {
+			return nil
+		}
+
+		// Skip files that are not provider pages
+		body := doc.Find("body.provider-page")
+		if body.Length() == 0 {
+			return nil
+		}
+		entry := ProviderIndexEntry{
+			Name:      strings.TrimSpace(doc.Find("h1 .provider-name").Text()),
+			Territory: body.AttrOr("data-territory", ""),
+			URL:       filepath.ToSlash(relPath),
+		}
+		entry.Services, _ = strconv.Atoi(body.AttrOr("data-services", "0"))
+		entry.Certificates, _ = strconv.Atoi(body.AttrOr("data-certificates", "0"))
+
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Count trust services
-	entry.TrustService = doc.Find(".service-card").Length()
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Territory != entries[j].Territory {
+			return entries[i].Territory < entries[j].Territory
+		}
+		return entries[i].Name < entries[j].Name
+	})
 
-	return entry, nil
+	return entries, nil
 }
 
 // generateIndexHTML creates an index.html file with links to all TSL HTML files using embedded templates
-func generateIndexHTML(dirPath string, entries []TSLIndexEntry, title string) error {
+func generateIndexHTML(dirPath string, entries []TSLIndexEntry, providers []ProviderIndexEntry, title string, columns []string, now time.Time, assets htmlAssets, labels *pageLabels) error {
+	page, err := assets.page("index", indexCSS, indexJavaScript)
+	if err != nil {
+		return err
+	}
+	if err := page.write(dirPath); err != nil {
+		return err
+	}
+
 	// Prepare template data
 	data := struct {
+		Lang          string
 		Title         string
+		Columns       []string
 		Entries       []TSLIndexEntry
+		Providers     []ProviderIndexEntry
 		GeneratedDate string
-		CSS           template.CSS
-		JavaScript    template.JS
+		Assets        pageAssets
 	}{
+		Lang:          labels.lang,
 		Title:         title,
+		Columns:       columns,
 		Entries:       entries,
-		GeneratedDate: time.Now().Format("2006-01-02"),
-		CSS:           template.CSS(indexCSS),
-		JavaScript:    template.JS(indexJavaScript),
+		Providers:     providers,
+		GeneratedDate: now.Format("2006-01-02"),
+		Assets:        page,
 	}
 
 	// Parse and execute the template
-	tmpl, err := template.New("index").Parse(indexHTMLTemplate)
+	tmpl, err := template.New("index").Funcs(labels.funcs()).Parse(indexHTMLTemplate)
 	if err != nil {
 		return fmt.Errorf("failed to parse template: %w", err)
 	}
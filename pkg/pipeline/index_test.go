@@ -1,10 +1,13 @@
 package pipeline
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/SUNET/g119612/pkg/etsi119612"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -91,6 +94,172 @@ func TestGenerateIndex(t *testing.T) {
 		// Check if the title is correct
 		assert.Contains(t, string(content), customTitle)
 	})
+
+	t.Run("Language", func(t *testing.T) {
+		ctx := NewContext()
+
+		_, err := GenerateIndex(nil, ctx, htmlDir, "lang:sv")
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(htmlDir, "index.html"))
+		require.NoError(t, err)
+		indexHTML := string(content)
+		assert.Contains(t, indexHTML, `<html lang="sv"`)
+		assert.Contains(t, indexHTML, "<h1>Förteckning över förtroendelistor</h1>", "the default title is translated")
+		assert.Contains(t, indexHTML, ">Nästa uppdatering</th>")
+		assert.Contains(t, indexHTML, "4 förtroendelistor")
+		assert.NotContains(t, indexHTML, "Next Update")
+
+		catalog := filepath.Join(tempDir, "messages-nb.yaml")
+		require.NoError(t, os.WriteFile(catalog, []byte("nb:\n  next_update: \"Neste oppdatering\"\n"), 0644))
+		_, err = GenerateIndex(nil, ctx, htmlDir, "Tillitslister", "lang:nb", "messages:"+catalog)
+		require.NoError(t, err)
+		content, err = os.ReadFile(filepath.Join(htmlDir, "index.html"))
+		require.NoError(t, err)
+		indexHTML = string(content)
+		assert.Contains(t, indexHTML, `<html lang="nb"`)
+		assert.Contains(t, indexHTML, "<h1>Tillitslister</h1>")
+		assert.Contains(t, indexHTML, ">Neste oppdatering</th>")
+		assert.Contains(t, indexHTML, ">Issued</th>", "labels missing from the catalog are English")
+
+		_, err = GenerateIndex(nil, ctx, htmlDir, "messages:"+filepath.Join(tempDir, "missing.yaml"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Self-contained Assets", func(t *testing.T) {
+		ctx := NewContext()
+
+		_, err := GenerateIndex(nil, ctx, htmlDir, "Offline Index", "assets:copy")
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(htmlDir, "index.html"))
+		require.NoError(t, err)
+		indexHTML := string(content)
+		assert.Contains(t, indexHTML, "Offline Index")
+		assert.NotContains(t, indexHTML, "cdn.jsdelivr.net")
+		assets, err := filepath.Glob(filepath.Join(htmlDir, "assets", "*"))
+		require.NoError(t, err)
+		assert.Len(t, assets, 3, "base and index stylesheets and the index script")
+		for _, asset := range assets {
+			assert.Contains(t, indexHTML, `"assets/`+filepath.Base(asset)+`"`)
+		}
+
+		_, err = GenerateIndex(nil, ctx, htmlDir, "assets:inline")
+		require.NoError(t, err)
+		content, err = os.ReadFile(filepath.Join(htmlDir, "index.html"))
+		require.NoError(t, err)
+		assert.NotContains(t, string(content), "cdn.jsdelivr.net")
+		assert.NotContains(t, string(content), `"assets/`)
+		assert.Contains(t, string(content), "Trust Service Lists Index")
+
+		_, err = GenerateIndex(nil, ctx, htmlDir, "assets:offline")
+		assert.Error(t, err)
+		_, err = GenerateIndex(nil, ctx, htmlDir, "stylesheet:pico.min.css")
+		assert.Error(t, err, "a stylesheet requires self-contained assets")
+		_, err = GenerateIndex(nil, ctx, htmlDir, "One", "Two")
+		assert.Error(t, err)
+	})
+}
+
+func TestGenerateIndex_ColumnsAndManifest(t *testing.T) {
+	dir := t.TempDir()
+	createSampleTSLHTML(t, dir, "SE-TL.html", "Sweden", "SE", "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric", "42", "2025-09-15", "2025-12-15", 1)
+	createSampleTSLHTML(t, dir, "EL-TL.html", "Greece", "EL", "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric", "7", "2025-09-01", "2025-12-01", 0)
+
+	// Providers and qualified services of the Swedish TSL
+	sePath := filepath.Join(dir, "SE-TL.html")
+	content, err := os.ReadFile(sePath)
+	require.NoError(t, err)
+	cards := `
+        <article class="provider-card"><h3>Provider A</h3>
+            <article class="service-card"><span class="badge badge-qualified">Qualified</span></article>
+            <article class="service-card"><span class="badge badge-qualified">Qualified</span></article>
+        </article>
+        <article class="provider-card"><h3>Provider B</h3>
+            <article class="service-card"><span class="badge badge-nonqualified">Non-Qualified</span></article>
+        </article>
+    </main>`
+	require.NoError(t, os.WriteFile(sePath, []byte(strings.Replace(string(content), "</main>", cards, 1)), 0644))
+
+	// The loaded Swedish TSL, with a verified signature
+	ctx := NewContext()
+	se := &etsi119612.TSL{
+		Source: "https://se.example/tsl.xml",
+		StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{TslSchemeTerritory: "SE", TSLSequenceNumber: 42},
+		},
+	}
+	ctx.AddTSL(se)
+	ctx.RecordSignature(se, &TSLSignature{Status: SignatureVerified, Admitted: true})
+
+	t.Run("Default Columns", func(t *testing.T) {
+		_, err := GenerateIndex(nil, ctx, dir)
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(dir, "index.html"))
+		require.NoError(t, err)
+		indexHTML := string(content)
+		assert.Contains(t, indexHTML, `data-column="next_update"`)
+		assert.NotContains(t, indexHTML, `data-column="signature"`)
+		assert.NotContains(t, indexHTML, "https://se.example/tsl.xml")
+
+		var manifest IndexManifest
+		content, err = os.ReadFile(filepath.Join(dir, indexManifestFile))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(content, &manifest))
+		assert.Equal(t, "Trust Service Lists Index", manifest.Title)
+		assert.Equal(t, defaultIndexColumns, manifest.Columns)
+		assert.NotNil(t, manifest.Providers)
+		require.Len(t, manifest.TSLs, 2)
+
+		el, se := manifest.TSLs[0], manifest.TSLs[1]
+		assert.Equal(t, "EL", el.Territory)
+		assert.Empty(t, el.Signature, "not loaded")
+		assert.Empty(t, el.SourceURL, "not loaded")
+		assert.Equal(t, "SE", se.Territory)
+		assert.Equal(t, "42", se.Sequence)
+		assert.Equal(t, 4, se.TrustService)
+		assert.Equal(t, 2, se.Providers)
+		assert.Equal(t, 2, se.QualifiedServices)
+		assert.Equal(t, SignatureVerified, se.Signature)
+		assert.Equal(t, "https://se.example/tsl.xml", se.SourceURL)
+	})
+
+	t.Run("Selected Columns", func(t *testing.T) {
+		_, err := GenerateIndex(nil, ctx, dir, "columns:territory, signature,source,qualified_services", "lang:sv")
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(dir, "index.html"))
+		require.NoError(t, err)
+		indexHTML := string(content)
+		assert.Contains(t, indexHTML, `data-column="signature"`)
+		assert.Contains(t, indexHTML, ">Signatur</th>")
+		assert.Contains(t, indexHTML, `<span class="badge signature-verified">verifierad</span>`)
+		assert.Contains(t, indexHTML, `<a href="https://se.example/tsl.xml"><code>https://se.example/tsl.xml</code></a>`)
+		assert.Contains(t, indexHTML, `<td class="col-qualified_services">2</td>`)
+		assert.Contains(t, indexHTML, `data-services="4"`, "total services are counted in any case")
+		assert.NotContains(t, indexHTML, `data-column="sequence"`)
+
+		var manifest IndexManifest
+		content, err = os.ReadFile(filepath.Join(dir, indexManifestFile))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(content, &manifest))
+		assert.Equal(t, []string{"territory", "signature", "source", "qualified_services"}, manifest.Columns)
+		assert.Equal(t, "2025-12-15", manifest.TSLs[1].NextUpdate, "the manifest has all metadata")
+	})
+
+	t.Run("Invalid Columns", func(t *testing.T) {
+		for _, arg := range []string{"columns:", "columns:territory,country", "columns:territory,sequence,territory", "columns:sequence,issued"} {
+			_, err := GenerateIndex(nil, ctx, dir, arg)
+			assert.ErrorContains(t, err, "invalid option columns:", arg)
+		}
+	})
+}
+
+func TestIndexColumnLabels(t *testing.T) {
+	english := builtinHTMLMessages["en"]
+	for _, column := range indexColumns {
+		assert.Contains(t, english, column)
+	}
+	for _, status := range []string{SignatureVerified, SignatureUntrusted, SignatureInvalid, SignatureUnsigned, SignatureNoAnchor} {
+		assert.Contains(t, english, "signature_"+status)
+	}
 }
 
 // Helper function to create sample TSL HTML files for testing
@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+// writeBenchTSL writes a TSL with one provider offering services granted
+// services, each with its own certificate, and returns its path.
+func writeBenchTSL(b *testing.B, services int) string {
+	cas, err := testutil.NewCAs("Bench CA", services)
+	if err != nil {
+		b.Fatalf("Failed to generate CAs: %v", err)
+	}
+	tsl := generateTSL("Bench Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{cas[0].Base64()})
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	tsp := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0]
+	template := tsp.TslTSPServices.TslTSPService[0]
+	for _, ca := range cas[1:] {
+		info := *template.TslServiceInformation
+		info.TslServiceDigitalIdentity = &etsi119612.DigitalIdentityListType{
+			DigitalId: []*etsi119612.DigitalIdentityType{{X509Certificate: ca.Base64()}},
+		}
+		tsp.TslTSPServices.TslTSPService = append(tsp.TslTSPServices.TslTSPService, &etsi119612.TSPServiceType{TslServiceInformation: &info})
+	}
+
+	data, err := xml.Marshal(tsl.StatusList)
+	if err != nil {
+		b.Fatalf("Failed to marshal TSL: %v", err)
+	}
+	path := filepath.Join(b.TempDir(), "tsl.xml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatalf("Failed to write TSL: %v", err)
+	}
+	return path
+}
+
+// BenchmarkLoadTSL benchmarks the load step parsing TSLs of increasing size
+// from a file, without references.
+func BenchmarkLoadTSL(b *testing.B) {
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.WarnLevel)
+	defer logrus.SetLevel(level)
+
+	for _, services := range []int{10, 100, 1000} {
+		path := writeBenchTSL(b, services)
+		b.Run(fmt.Sprintf("services=%d", services), func(b *testing.B) {
+			pl := &Pipeline{Logger: logging.NewLogger(logging.WarnLevel)}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ctx := NewContext()
+				if _, err := LoadTSL(pl, ctx, path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// offlineTransport fails every HTTP request, so that fuzzed TSLs cannot make
+// the load step reach the network.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("network disabled while fuzzing")
+}
+
+// fuzzTSL is a minimal TSL whose pointers to other TSLs are the placeholders
+// @SELF@ and @OTHER@, see FuzzLoadTSL.
+const fuzzTSL = `<?xml version="1.0" encoding="UTF-8"?>
+<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#" Id="tsl">
+  <SchemeInformation>
+    <TSLVersionIdentifier>5</TSLVersionIdentifier>
+    <TSLSequenceNumber>1</TSLSequenceNumber>
+    <SchemeTerritory>SE</SchemeTerritory>
+    <PointersToOtherTSL>
+      <OtherTSLPointer><TSLLocation>@SELF@</TSLLocation></OtherTSLPointer>
+      <OtherTSLPointer><TSLLocation>@OTHER@</TSLLocation></OtherTSLPointer>
+    </PointersToOtherTSL>
+  </SchemeInformation>
+  <TrustServiceProviderList>
+    <TrustServiceProvider>
+      <TSPInformation><TSPName><Name xml:lang="en">Provider</Name></TSPName></TSPInformation>
+      <TSPServices>
+        <TSPService>
+          <ServiceInformation>
+            <ServiceTypeIdentifier>http://uri.etsi.org/TrstSvc/Svctype/CA/QC</ServiceTypeIdentifier>
+            <ServiceDigitalIdentity><DigitalId><X509Certificate>@CERT@</X509Certificate></DigitalId></ServiceDigitalIdentity>
+          </ServiceInformation>
+        </TSPService>
+      </TSPServices>
+    </TrustServiceProvider>
+  </TrustServiceProviderList>
+</TrustServiceStatusList>`
+
+// FuzzLoadTSL checks that the load step rejects or loads any document without
+// panicking or looping. The document is written to two files; in each,
+// @SELF@ is replaced by the file's own URL and @OTHER@ by the other's, so
+// that pointers form self-references and cycles between lists.
+func FuzzLoadTSL(f *testing.F) {
+	for _, seed := range []string{
+		fuzzTSL,
+		strings.Replace(fuzzTSL, "@CERT@", TestCertBase64, 1),
+		strings.Replace(fuzzTSL, `Id="tsl"`, `Id="`+strings.Repeat("x", 1<<16)+`"`, 1),
+		strings.Replace(fuzzTSL, "<Name xml:lang=\"en\">Provider</Name>", strings.Repeat("<Name xml:lang=\"en\">Provider</Name>", 1000), 1),
+		fuzzTSL[:len(fuzzTSL)/2],
+		`<TrustServiceStatusList><SchemeInformation><PointersToOtherTSL>` + strings.Repeat("<OtherTSLPointer><TSLLocation>@SELF@</TSLLocation></OtherTSLPointer>", 100) + `</PointersToOtherTSL></SchemeInformation></TrustServiceStatusList>`,
+		`<TrustServiceStatusList>` + strings.Repeat("<a>", 10000) + `</TrustServiceStatusList>`,
+		`<!DOCTYPE x [<!ENTITY a "aaaaaaaaaa"><!ENTITY b "&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;">]><TrustServiceStatusList>&b;</TrustServiceStatusList>`,
+		`<TrustServiceStatusList><Signature></Signature></TrustServiceStatusList>`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.ErrorLevel)
+	f.Cleanup(func() { logrus.SetLevel(level) })
+	pl := &Pipeline{Logger: logging.NewLogger(logging.FatalLevel)}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dir := t.TempDir()
+		paths := []string{filepath.Join(dir, "a.xml"), filepath.Join(dir, "b.xml")}
+		for i, path := range paths {
+			doc := bytes.ReplaceAll(data, []byte("@SELF@"), []byte("file://"+path))
+			doc = bytes.ReplaceAll(doc, []byte("@OTHER@"), []byte("file://"+paths[1-i]))
+			if err := os.WriteFile(path, doc, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		ctx := NewContext()
+		ctx.EnsureTSLFetchOptions()
+		ctx.TSLFetchOptions.Client = &http.Client{Transport: offlineTransport{}}
+		result, err := LoadTSL(pl, ctx, paths[0])
+		if err != nil {
+			return
+		}
+		if result.TSLs.Size() == 0 {
+			t.Fatal("the load step succeeded without loading a TSL")
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// StdinSource is the load step source that reads the TSL from the standard
+// input, as in "cat tsl.xml | gt --no-server pipeline.yaml".
+const StdinSource = "-"
+
+// stdinURL is the URL a TSL read from the standard input is loaded from and
+// reported with.
+const stdinURL = "stdin:"
+
+// inputOnce reads a reader once, so that a pipeline run again loads the same
+// document rather than the empty rest of the standard input.
+type inputOnce struct {
+	r    io.Reader
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// read returns the content of the reader, reading it on the first call.
+func (in *inputOnce) read() ([]byte, error) {
+	in.once.Do(func() {
+		in.data, in.err = io.ReadAll(in.r)
+		if in.err == nil && len(bytes.TrimSpace(in.data)) == 0 {
+			in.err = fmt.Errorf("standard input is empty")
+		}
+	})
+	return in.data, in.err
+}
+
+// stdin is the standard input read by load steps whose source is StdinSource.
+var stdin = &inputOnce{r: os.Stdin}
+
+// isInlineSource reports whether a load step URL is served from the standard
+// input or the URL itself rather than fetched.
+func isInlineSource(source string) bool {
+	return source == stdinURL || strings.HasPrefix(source, "data:")
+}
+
+// resolveLoadSource returns the URL a load step loads source from. Pipeline
+// variables in source, as in "{{.Vars.source}}", are substituted first, so
+// that the source can be set with --set; StdinSource becomes stdinURL,
+// data: URLs are kept, and other sources without an http or https scheme
+// are file paths.
+func resolveLoadSource(ctx *Context, source string) (string, error) {
+	if strings.Contains(source, "{{") {
+		tmpl, err := template.New("source").Option("missingkey=error").Parse(source)
+		if err != nil {
+			return "", fmt.Errorf("invalid TSL source template: %w", err)
+		}
+		vars := ctx.Vars()
+		if vars == nil {
+			vars = map[string]string{}
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, struct{ Vars map[string]string }{vars}); err != nil {
+			return "", fmt.Errorf("failed to execute TSL source template: %w", err)
+		}
+		source = strings.TrimSpace(buf.String())
+	}
+
+	switch {
+	case source == "":
+		return "", fmt.Errorf("empty TSL source")
+	case source == StdinSource:
+		return stdinURL, nil
+	case strings.HasPrefix(source, "data:"), strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		return source, nil
+	}
+	return "file://" + source, nil
+}
+
+// decodeDataURL returns the content of an RFC 2397 data: URL, such as
+// "data:application/xml;base64,PD94bWwg..." or "data:,%3CTrustServiceStatusList...".
+func decodeDataURL(raw string) ([]byte, error) {
+	rest, ok := strings.CutPrefix(raw, "data:")
+	if !ok {
+		return nil, fmt.Errorf("not a data: URL")
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data: URL: missing comma")
+	}
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		data, err := url.PathUnescape(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data: URL: %w", err)
+		}
+		data = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+				return -1
+			}
+			return r
+		}, data)
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			decoded, err = base64.RawStdEncoding.DecodeString(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 in data: URL: %w", err)
+		}
+		return decoded, nil
+	}
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data: URL: %w", err)
+	}
+	return []byte(decoded), nil
+}
+
+// inlineSourceName returns source for messages, without the content of a
+// data: URL.
+func inlineSourceName(source string) string {
+	if strings.HasPrefix(source, "data:") {
+		return "data:"
+	}
+	return source
+}
+
+// serveInline serves a request for the inline source of a load step from the
+// standard input or the URL itself. The document is kept like a downloaded
+// one, but not recorded in the source status report, like files.
+func (t *captureTransport) serveInline(req *http.Request) (*http.Response, error) {
+	source := req.URL.String()
+	var body []byte
+	var err error
+	if source == stdinURL {
+		body, err = stdin.read()
+	} else {
+		body, err = decodeDataURL(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.maxSize > 0 && int64(len(body)) > t.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrDownloadTooLarge, len(body), t.maxSize)
+	}
+
+	t.mu.Lock()
+	t.bodies[source] = body
+	t.mu.Unlock()
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/xml"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
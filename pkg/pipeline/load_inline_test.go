@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withStdin makes load steps read data as the standard input until the test
+// ends.
+func withStdin(t *testing.T, data string) {
+	t.Helper()
+	saved := stdin
+	stdin = &inputOnce{r: strings.NewReader(data)}
+	t.Cleanup(func() { stdin = saved })
+}
+
+func loadedProvider(t *testing.T, ctx *Context) string {
+	t.Helper()
+	require.Equal(t, 1, ctx.TSLs.Size())
+	tsl := ctx.TSLs.ToSlice()[0]
+	require.NotEmpty(t, tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider)
+	name := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPInformation.TSPName.Name[0]
+	require.NotNil(t, name.NonEmptyNormalizedString)
+	return string(*name.NonEmptyNormalizedString)
+}
+
+func TestLoadTSL_Stdin(t *testing.T) {
+	data, err := os.ReadFile("testdata/test-tsl.xml")
+	require.NoError(t, err)
+	withStdin(t, string(data))
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+
+	ctx, err := LoadTSL(pl, NewContext(), StdinSource)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Provider", loadedProvider(t, ctx))
+	assert.Equal(t, stdinURL, ctx.TSLs.ToSlice()[0].Source)
+	assert.Empty(t, ctx.takeSourceFetches(), "the standard input is not a fetched source")
+
+	// The standard input is read once; a pipeline run again loads it again
+	ctx, err = LoadTSL(pl, NewContext(), StdinSource)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Provider", loadedProvider(t, ctx))
+
+	withStdin(t, "  \n")
+	_, err = LoadTSL(pl, NewContext(), StdinSource)
+	assert.ErrorContains(t, err, "standard input is empty")
+}
+
+func TestLoadTSL_DataURL(t *testing.T) {
+	data, err := os.ReadFile("testdata/test-tsl.xml")
+	require.NoError(t, err)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+
+	for name, source := range map[string]string{
+		"base64":          "data:application/xml;base64," + base64.StdEncoding.EncodeToString(data),
+		"percent-encoded": "data:," + url.PathEscape(string(data)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, err := LoadTSL(pl, NewContext(), source)
+			require.NoError(t, err)
+			assert.Equal(t, "Test Provider", loadedProvider(t, ctx))
+		})
+	}
+
+	_, err = LoadTSL(pl, NewContext(), "data:application/xml;base64,!!!")
+	assert.ErrorContains(t, err, "invalid base64")
+
+	ctx := NewContext()
+	ctx.Data = map[string]any{maxDownloadSizeKey: int64(16)}
+	_, err = LoadTSL(pl, ctx, "data:,"+url.PathEscape(string(data)))
+	assert.ErrorIs(t, err, ErrDownloadTooLarge)
+}
+
+// unreadable is a standard input that fails the test when it is read.
+type unreadable struct{ t *testing.T }
+
+func (r unreadable) Read([]byte) (int, error) {
+	r.t.Error("the standard input was read")
+	return 0, io.EOF
+}
+
+func TestLoadTSL_InlinePointersRefused(t *testing.T) {
+	data, err := os.ReadFile("testdata/test-tsl.xml")
+	require.NoError(t, err)
+	saved := stdin
+	stdin = &inputOnce{r: unreadable{t}}
+	t.Cleanup(func() { stdin = saved })
+
+	// A fetched TSL pointing at the standard input and at an inline document
+	dataURL := "data:application/xml;base64," + base64.StdEncoding.EncodeToString(data)
+	root := filepath.Join(t.TempDir(), "root.xml")
+	require.NoError(t, os.WriteFile(root, []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#" xmlns:xml="http://www.w3.org/XML/1998/namespace">
+  <tsl:SchemeInformation>
+    <tsl:SchemeOperatorName><tsl:Name xml:lang="en">Root</tsl:Name></tsl:SchemeOperatorName>
+    <tsl:PointersToOtherTSL>
+      <tsl:OtherTSLPointer><tsl:TSLLocation>%s</tsl:TSLLocation></tsl:OtherTSLPointer>
+      <tsl:OtherTSLPointer><tsl:TSLLocation>%s</tsl:TSLLocation></tsl:OtherTSLPointer>
+    </tsl:PointersToOtherTSL>
+  </tsl:SchemeInformation>
+</tsl:TrustServiceStatusList>`, stdinURL, dataURL)), 0600))
+
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	ctx, err := SetFetchOptions(pl, NewContext(), "max-depth:1")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, root)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ctx.TSLs.Size(), "only the root TSL is loaded")
+
+	refused := map[string]string{}
+	for _, fetch := range ctx.takeSourceFetches() {
+		refused[fetch.URL] = fetch.Error
+	}
+	assert.Contains(t, refused[stdinURL], "inline source stdin: is only allowed as the source of a load step")
+	assert.Contains(t, refused[dataURL], "inline source data: is only allowed as the source of a load step")
+}
+
+func TestLoadTSL_SourceVariable(t *testing.T) {
+	data, err := os.ReadFile("testdata/test-tsl.xml")
+	require.NoError(t, err)
+	withStdin(t, string(data))
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+
+	ctx, err := SetVars(pl, NewContext(), "source=-")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, "{{.Vars.source}}")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Provider", loadedProvider(t, ctx))
+
+	ctx, err = SetVars(pl, NewContext(), "dir=testdata")
+	require.NoError(t, err)
+	ctx, err = LoadTSL(pl, ctx, "{{.Vars.dir}}/test-tsl.xml")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Provider", loadedProvider(t, ctx))
+
+	_, err = LoadTSL(pl, NewContext(), "{{.Vars.source}}")
+	assert.ErrorContains(t, err, "failed to execute TSL source template")
+	_, err = LoadTSL(pl, NewContext(), "{{.Vars.source")
+	assert.ErrorContains(t, err, "invalid TSL source template")
+}
+
+func TestDecodeDataURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"data:,hello%20world", "hello world"},
+		{"data:text/xml;charset=utf-8,%3Ca%2F%3E", "<a/>"},
+		{"data:;base64,aGVsbG8=", "hello"},
+		{"data:application/xml;BASE64,aGVs%0AbG8", "hello"},
+	}
+	for _, tt := range tests {
+		got, err := decodeDataURL(tt.raw)
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, string(got), tt.raw)
+	}
+
+	_, err := decodeDataURL("data:text/xml")
+	assert.ErrorContains(t, err, "missing comma")
+	_, err = decodeDataURL("data:,%zz")
+	assert.Error(t, err)
+}
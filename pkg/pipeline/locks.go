@@ -0,0 +1,173 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kinds of the named locks taken by pipeline runs, so that the pipelines of
+// a multi-pipeline setup that share sources or output directories do not
+// download the same TSL twice or write into the same directory at once.
+const (
+	LockSource = "source" // A TSL URL, held while it is downloaded
+	LockOutput = "output" // An output directory, held while a step writes to it
+)
+
+// ErrLockDeadlock is returned when taking a named lock would deadlock: the
+// run holding it waits, directly or through other runs, for a lock held by
+// the run asking for it.
+var ErrLockDeadlock = errors.New("lock would deadlock")
+
+var (
+	lockWaitDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "go_trust_pipeline_lock_wait_seconds",
+			Help:    "Time pipeline runs waited for source and output locks held by other runs, by kind, in seconds",
+			Buckets: []float64{.001, .01, .1, .5, 1, 2.5, 5, 10, 30, 60, 300},
+		},
+		[]string{"kind"},
+	)
+	lockDeadlocks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "go_trust_pipeline_lock_deadlocks_total",
+			Help: "Total number of source and output locks refused because waiting for them would deadlock, by kind",
+		},
+		[]string{"kind"},
+	)
+	sharedFetches = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "go_trust_pipeline_shared_fetches_total",
+			Help: "Total number of TSL downloads reused from a concurrent run that fetched the same URL",
+		},
+	)
+)
+
+// LockCollectors returns the Prometheus collectors of the source and output
+// locks of all pipeline runs, to be registered alongside the server's
+// metrics.
+func LockCollectors() []prometheus.Collector {
+	return []prometheus.Collector{lockWaitDuration, lockDeadlocks, sharedFetches}
+}
+
+// lockOwner identifies the pipeline run holding or waiting for named locks.
+type lockOwner struct {
+	waiting *namedLock // Lock the owner waits for, guarded by lockTable.mu
+}
+
+// namedLock is a held lock of a lockTable.
+type namedLock struct {
+	owner    *lockOwner    // Run holding the lock
+	depth    int           // Times the owner took the lock
+	released chan struct{} // Closed when the owner releases the lock
+	value    any           // Value the lock was released with
+}
+
+// lockTable holds the named locks of the pipeline runs of the process. A
+// lock is held by one run at a time and may be taken again by the run
+// holding it. A run that waits for a lock receives the value the previous
+// holder released it with, so that it can reuse the work done under it.
+type lockTable struct {
+	mu    sync.Mutex
+	locks map[string]*namedLock
+}
+
+// Named locks shared by the pipeline runs of the process
+var pipelineLocks = &lockTable{
+	locks: make(map[string]*namedLock),
+}
+
+// acquire takes the lock of kind on name for owner, waiting while another
+// owner holds it. It returns the function releasing the lock with a value
+// for the next holder and, if it waited, the value the last holder released
+// it with. It fails with ErrLockDeadlock, without waiting, if the holder
+// waits for a lock of owner. A nil owner takes the lock for itself alone.
+func (t *lockTable) acquire(owner *lockOwner, kind, name string) (func(value any), any, error) {
+	if owner == nil {
+		owner = &lockOwner{}
+	}
+	key := kind + " " + name
+	start := time.Now()
+	var shared any
+
+	t.mu.Lock()
+	for {
+		l := t.locks[key]
+		if l == nil {
+			l = &namedLock{owner: owner, depth: 1, released: make(chan struct{})}
+			t.locks[key] = l
+			t.mu.Unlock()
+			lockWaitDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+			return func(value any) { t.release(key, l, value) }, shared, nil
+		}
+		if l.owner == owner {
+			l.depth++
+			t.mu.Unlock()
+			return func(value any) { t.release(key, l, value) }, nil, nil
+		}
+		if t.waitsFor(l.owner, owner) {
+			t.mu.Unlock()
+			lockDeadlocks.WithLabelValues(kind).Inc()
+			return nil, nil, fmt.Errorf("%w: the run holding the %s lock of %s waits for a lock of this run", ErrLockDeadlock, kind, name)
+		}
+		owner.waiting = l
+		t.mu.Unlock()
+
+		<-l.released
+
+		t.mu.Lock()
+		owner.waiting = nil
+		shared = l.value
+	}
+}
+
+// waitsFor reports whether owner waits, directly or through the owners of
+// the locks it waits for, for a lock held by other. The caller must hold
+// t.mu.
+func (t *lockTable) waitsFor(owner, other *lockOwner) bool {
+	for range len(t.locks) + 1 {
+		if owner == other {
+			return true
+		}
+		if owner.waiting == nil {
+			return false
+		}
+		owner = owner.waiting.owner
+	}
+	return false
+}
+
+// release releases l, held under key, once as often as it was taken.
+func (t *lockTable) release(key string, l *namedLock, value any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l.depth--
+	if l.depth > 0 {
+		return
+	}
+	l.value = value
+	delete(t.locks, key)
+	close(l.released)
+}
+
+// lockOutput takes the output lock of directory dir for the run of ctx,
+// waiting while a concurrent run writes into it. Call the returned function
+// once the step has finished writing. A Context used outside a pipeline run
+// becomes the owner of its locks itself.
+func (ctx *Context) lockOutput(dir string) (func(), error) {
+	if ctx.locks == nil {
+		ctx.locks = &lockOwner{}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	release, _, err := pipelineLocks.acquire(ctx.locks, LockOutput, filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+	return func() { release(nil) }, nil
+}
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isWaiting reports whether owner waits for a lock of pipelineLocks.
+func isWaiting(owner *lockOwner) bool {
+	pipelineLocks.mu.Lock()
+	defer pipelineLocks.mu.Unlock()
+	return owner.waiting != nil
+}
+
+// awaitWaiting waits until owner waits for a lock of pipelineLocks.
+func awaitWaiting(t *testing.T, owner *lockOwner) {
+	t.Helper()
+	require.Eventually(t, func() bool { return isWaiting(owner) }, time.Second, time.Millisecond)
+}
+
+func TestLockTable_Reentrant(t *testing.T) {
+	owner := &lockOwner{}
+	release1, _, err := pipelineLocks.acquire(owner, LockOutput, "/reentrant")
+	require.NoError(t, err)
+	release2, _, err := pipelineLocks.acquire(owner, LockOutput, "/reentrant")
+	require.NoError(t, err)
+
+	release2(nil)
+	pipelineLocks.mu.Lock()
+	assert.Contains(t, pipelineLocks.locks, LockOutput+" /reentrant", "held until released as often as taken")
+	pipelineLocks.mu.Unlock()
+	release1(nil)
+
+	release, shared, err := pipelineLocks.acquire(&lockOwner{}, LockOutput, "/reentrant")
+	require.NoError(t, err)
+	assert.Nil(t, shared, "no value without waiting")
+	release(nil)
+}
+
+func TestLockTable_WaitShares(t *testing.T) {
+	holder, waiter := &lockOwner{}, &lockOwner{}
+	release, _, err := pipelineLocks.acquire(holder, LockSource, "https://example.com/shared.xml")
+	require.NoError(t, err)
+
+	done := make(chan any)
+	go func() {
+		release, shared, err := pipelineLocks.acquire(waiter, LockSource, "https://example.com/shared.xml")
+		if err == nil {
+			release(nil)
+		}
+		done <- shared
+	}()
+	awaitWaiting(t, waiter)
+	release("document")
+	assert.Equal(t, "document", <-done)
+}
+
+func TestLockTable_Deadlock(t *testing.T) {
+	a, b := &lockOwner{}, &lockOwner{}
+	releaseX, _, err := pipelineLocks.acquire(a, LockOutput, "/x")
+	require.NoError(t, err)
+	releaseY, _, err := pipelineLocks.acquire(b, LockOutput, "/y")
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		release, _, err := pipelineLocks.acquire(b, LockOutput, "/x")
+		if err == nil {
+			release(nil)
+		}
+		done <- err
+	}()
+	awaitWaiting(t, b)
+
+	_, _, err = pipelineLocks.acquire(a, LockOutput, "/y")
+	require.ErrorIs(t, err, ErrLockDeadlock)
+	assert.Contains(t, err.Error(), "output lock of /y")
+
+	releaseX(nil)
+	require.NoError(t, <-done, "b takes /x once a gives up")
+	releaseY(nil)
+}
+
+func TestLoadTSL_SharedFetch(t *testing.T) {
+	data := renderExtensionsTSL(t).Data
+	waiter := &lockOwner{}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Answer once the second run waits for the download of the first
+		for deadline := time.Now().Add(time.Second); !isWaiting(waiter) && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	url := server.URL + "/tsl.xml"
+
+	pl := createTestPipeline(nil)
+	first := make(chan error)
+	go func() {
+		_, err := LoadTSL(pl, NewContext(), url)
+		first <- err
+	}()
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx := NewContext()
+	ctx.locks = waiter
+	ctx, err := LoadTSL(pl, ctx, url)
+	require.NoError(t, err)
+	require.NoError(t, <-first)
+
+	assert.Equal(t, int32(1), requests.Load(), "the URL is fetched once")
+	require.Equal(t, 1, ctx.TSLs.Size())
+	fetches := ctx.takeSourceFetches()
+	require.Len(t, fetches, 1)
+	assert.True(t, fetches[0].Shared)
+	assert.False(t, fetches[0].Failed())
+}
+
+func TestLockOutput(t *testing.T) {
+	dir := t.TempDir()
+	holder, waiter := NewContext(), NewContext()
+	waiter.locks = &lockOwner{}
+	unlock, err := holder.lockOutput(dir)
+	require.NoError(t, err)
+	again, err := holder.lockOutput(filepath.Join(dir, "."))
+	require.NoError(t, err, "the same run takes its lock again")
+	again()
+
+	done := make(chan error)
+	go func() {
+		unlock, err := waiter.lockOutput(dir + "/")
+		if err == nil {
+			unlock()
+		}
+		done <- err
+	}()
+	awaitWaiting(t, waiter.locks)
+	select {
+	case <-done:
+		t.Fatal("a concurrent run wrote into a locked directory")
+	default:
+	}
+	unlock()
+	require.NoError(t, <-done)
+}
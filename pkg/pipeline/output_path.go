@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// pipelineVarsKey is the Context.Data key of the pipeline variables set by
+// vars steps and recorded by publish and transform steps.
+const pipelineVarsKey = "pipeline_vars"
+
+// Pipeline variables recorded by steps for later steps.
+const (
+	VarPublishDir   = "publish_dir"   // Output directory of the last publish step
+	VarTransformDir = "transform_dir" // Output directory of the last transform step
+)
+
+// LatestLink is the name of the symlink that the "latest" option of publish
+// and transform steps points to their output directory.
+const LatestLink = "latest"
+
+// OutputPathData is the data a publish or transform output directory
+// containing a Go text/template action, such as
+// "/srv/tsl/{{.Territory}}/{{.Sequence}}", is executed with.
+type OutputPathData struct {
+	Territory string            // Scheme territory of the first TSL the step writes, e.g. "SE"
+	Sequence  int               // Sequence number of that TSL
+	Date      string            // Date of the run in UTC, e.g. "2026-10-16"
+	Time      time.Time         // Time of the run in UTC, for other layouts: {{.Time.Format "20060102"}}
+	Vars      map[string]string // Pipeline variables (see Vars)
+}
+
+// Vars returns the pipeline variables of ctx: those set by vars steps and
+// the output directories recorded by publish and transform steps. The map
+// must not be modified.
+func (ctx *Context) Vars() map[string]string {
+	if ctx == nil || ctx.Data == nil {
+		return nil
+	}
+	vars, _ := ctx.Data[pipelineVarsKey].(map[string]string)
+	return vars
+}
+
+// SetVar sets a pipeline variable. The variables are copied rather than
+// modified, as Context.Copy shares them.
+func (ctx *Context) SetVar(name, value string) {
+	if ctx.Data == nil {
+		ctx.Data = make(map[string]any)
+	}
+	vars := maps.Clone(ctx.Vars())
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	vars[name] = value
+	ctx.Data[pipelineVarsKey] = vars
+}
+
+// SetVars is a pipeline step that sets pipeline variables, which publish and
+// transform steps can use in their output directories as {{.Vars.name}}.
+//
+// Arguments are "name=value" pairs; names are letters, digits, "_" and "-",
+// starting with a letter. Later steps may set a variable again.
+//
+// Example usage in pipeline YAML:
+//
+//   - vars:
+//   - release=2026.10
+//   - env=prod
+//   - publish:
+//   - "/srv/tsl/{{.Vars.env}}/{{.Territory}}/{{.Sequence}}"
+//   - latest
+func SetVars(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	if len(args) == 0 {
+		return ctx, fmt.Errorf("missing argument: name=value")
+	}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !xsltParamName.MatchString(name) {
+			return ctx, fmt.Errorf("invalid variable %q: expected name=value", arg)
+		}
+		ctx.SetVar(name, value)
+		pl.Logger.Debug("Set pipeline variable", logging.F("name", name), logging.F("value", value))
+	}
+	return ctx, nil
+}
+
+// isOutputPathTemplate reports whether an output directory is a template.
+func isOutputPathTemplate(path string) bool {
+	return strings.Contains(path, "{{")
+}
+
+// StaticOutputDir returns the part of an output directory that does not
+// depend on its template: the directory above the first template action, or
+// path itself if it is not a template.
+func StaticOutputDir(path string) string {
+	i := strings.Index(path, "{{")
+	if i < 0 {
+		return path
+	}
+	dir := filepath.Dir(path[:i] + "x")
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+// resolveOutputPath returns the output directory path of a step that writes
+// tsl, executing it as a template with OutputPathData if it contains a
+// template action. The result must not contain empty or ".." elements, so
+// that variables cannot lead the output elsewhere.
+func resolveOutputPath(ctx *Context, path string, tsl *etsi119612.TSL, now time.Time) (string, error) {
+	if !isOutputPathTemplate(path) {
+		return path, nil
+	}
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid output directory template: %w", err)
+	}
+
+	now = now.UTC()
+	data := OutputPathData{Date: now.Format(time.DateOnly), Time: now, Vars: ctx.Vars()}
+	if data.Vars == nil {
+		data.Vars = map[string]string{}
+	}
+	if tsl != nil && tsl.StatusList.TslSchemeInformation != nil {
+		data.Territory = tsl.StatusList.TslSchemeInformation.TslSchemeTerritory
+		data.Sequence = tsl.StatusList.TslSchemeInformation.TSLSequenceNumber
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute output directory template: %w", err)
+	}
+
+	resolved := buf.String()
+	for i, element := range strings.Split(strings.TrimSuffix(filepath.ToSlash(resolved), "/"), "/") {
+		if element == ".." || (element == "" && i > 0) {
+			return "", fmt.Errorf("output directory template %q produced invalid path %q", path, resolved)
+		}
+	}
+	return resolved, nil
+}
+
+// parseLatestOption parses the "latest" and "latest:PATH" options of publish
+// and transform steps. It returns the path of the symlink, which may be a
+// template, or "" for a LatestLink next to the output directory.
+func parseLatestOption(arg string) (string, bool) {
+	if arg == "latest" {
+		return "", true
+	}
+	if path, ok := strings.CutPrefix(arg, "latest:"); ok && path != "" {
+		return path, true
+	}
+	return "", false
+}
+
+// latestLinkPath returns the path of the symlink the "latest" option of a
+// step writing to dir swaps: link, resolved like the output directory, or
+// LatestLink next to dir.
+func latestLinkPath(ctx *Context, link, dir string, tsl *etsi119612.TSL, now time.Time) (string, error) {
+	if link == "" {
+		return filepath.Join(filepath.Dir(filepath.Clean(dir)), LatestLink), nil
+	}
+	return resolveOutputPath(ctx, link, tsl, now)
+}
+
+// swapLatest atomically points the symlink link to dir, with a relative
+// target, by renaming a new symlink over it. An existing link that is not a
+// symlink is an error, so that existing output is never replaced.
+func swapLatest(pl *Pipeline, link, dir string) error {
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s is not a symlink; move it aside to point it to the latest output", link)
+	}
+	target, err := filepath.Rel(filepath.Dir(link), dir)
+	if err != nil {
+		target, err = filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", dir, err)
+		}
+	}
+	if err := swapSymlink(link, target); err != nil {
+		return err
+	}
+	pl.Logger.Info("Pointed latest output link",
+		logging.F("link", link),
+		logging.F("target", target))
+	return nil
+}
+
+// swapSymlink atomically points the symlink link to target by renaming a new
+// symlink over it.
+func swapSymlink(link, target string) error {
+	swap := filepath.Clean(link) + ".swap"
+	if err := os.Remove(swap); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale symlink %s: %w", swap, err)
+	}
+	if err := os.Symlink(target, swap); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", swap, err)
+	}
+	if err := os.Rename(swap, link); err != nil {
+		os.Remove(swap)
+		return fmt.Errorf("failed to swap symlink %s: %w", link, err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveOutputPath(t *testing.T) {
+	ctx := dryRunContext(42, statusGranted)
+	ctx.SetVar("env", "prod")
+	tsl := firstPublishedTSL(ctx)
+	now := time.Date(2026, 10, 16, 23, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/srv/tsl", "/srv/tsl"},
+		{"/srv/tsl/{{.Territory}}/{{.Sequence}}/", "/srv/tsl/SE/42/"},
+		{"/srv/{{.Vars.env}}/{{.Date}}", "/srv/prod/2026-10-16"},
+		{`out/{{.Time.Format "20060102T1504"}}`, "out/20261016T2130"},
+	}
+	for _, tt := range tests {
+		got, err := resolveOutputPath(ctx, tt.path, tsl, now)
+		require.NoError(t, err, tt.path)
+		assert.Equal(t, tt.want, got, tt.path)
+	}
+
+	_, err := resolveOutputPath(ctx, "/srv/{{.Vars.missing}}", tsl, now)
+	assert.ErrorContains(t, err, "failed to execute output directory template")
+	_, err = resolveOutputPath(ctx, "/srv/{{.Territory", tsl, now)
+	assert.ErrorContains(t, err, "invalid output directory template")
+	_, err = resolveOutputPath(ctx, "/srv/{{.Territory}}/x", nil, now)
+	assert.ErrorContains(t, err, `produced invalid path "/srv//x"`)
+	ctx.SetVar("env", "../../etc")
+	_, err = resolveOutputPath(ctx, "/srv/{{.Vars.env}}", tsl, now)
+	assert.ErrorContains(t, err, "produced invalid path")
+}
+
+func TestStaticOutputDir(t *testing.T) {
+	assert.Equal(t, "/srv/tsl", StaticOutputDir("/srv/tsl"))
+	assert.Equal(t, "/srv/tsl", StaticOutputDir("/srv/tsl/{{.Territory}}/{{.Sequence}}"))
+	assert.Equal(t, "/srv", StaticOutputDir("/srv/tsl-{{.Date}}"))
+	assert.Equal(t, ".", StaticOutputDir("{{.Date}}"))
+}
+
+func TestSetVars(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	ctx, err := SetVars(pl, NewContext(), "release=2026.10", "env=")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"release": "2026.10", "env": ""}, ctx.Vars())
+
+	copied := ctx.Copy()
+	copied.SetVar("release", "2026.11")
+	assert.Equal(t, "2026.10", ctx.Vars()["release"], "a copy does not change the variables of the original")
+
+	for _, bad := range []string{"release", "=x", "9lives=x", "a b=c"} {
+		_, err := SetVars(pl, NewContext(), bad)
+		assert.ErrorContains(t, err, "invalid variable", bad)
+	}
+	_, err = SetVars(pl, NewContext())
+	assert.Error(t, err)
+}
+
+func TestPublishTSL_OutputPathTemplate(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	root := t.TempDir()
+	template := filepath.Join(root, "{{.Vars.env}}", "{{.Territory}}", "{{.Sequence}}")
+
+	publish := func(sequence int) *Context {
+		ctx := dryRunContext(sequence, statusGranted)
+		ctx.SetVar("env", "prod")
+		ctx, err := PublishTSL(pl, ctx, template, "latest")
+		require.NoError(t, err)
+		return ctx
+	}
+
+	ctx := publish(1)
+	assert.FileExists(t, filepath.Join(root, "prod", "SE", "1", "se.xml"))
+	assert.Equal(t, filepath.Join(root, "prod", "SE", "1"), ctx.Vars()[VarPublishDir])
+	latest := filepath.Join(root, "prod", "SE", LatestLink)
+	target, err := os.Readlink(latest)
+	require.NoError(t, err)
+	assert.Equal(t, "1", target)
+
+	publish(2)
+	target, err = os.Readlink(latest)
+	require.NoError(t, err)
+	assert.Equal(t, "2", target)
+	assert.FileExists(t, filepath.Join(latest, "se.xml"))
+	assert.FileExists(t, filepath.Join(root, "prod", "SE", "1", "se.xml"), "earlier directories are kept")
+
+	t.Run("latest path", func(t *testing.T) {
+		link := filepath.Join(root, "current-{{.Territory}}")
+		_, err := PublishTSL(pl, dryRunContext(3, statusGranted), filepath.Join(root, "{{.Sequence}}"), "latest:"+link)
+		require.NoError(t, err)
+		target, err := os.Readlink(filepath.Join(root, "current-SE"))
+		require.NoError(t, err)
+		assert.Equal(t, "3", target)
+	})
+
+	t.Run("latest is not a symlink", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, LatestLink), 0755))
+		_, err := PublishTSL(pl, dryRunContext(1, statusGranted), filepath.Join(dir, "{{.Sequence}}"), "latest")
+		assert.ErrorContains(t, err, "is not a symlink")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dir := t.TempDir()
+		ctx, err := PublishTSL(pl, dryRunContext(1, statusGranted), filepath.Join(dir, "{{.Sequence}}"), "latest", "dry-run")
+		assert.True(t, IsPublishDiff(err))
+		assert.NoFileExists(t, filepath.Join(dir, LatestLink))
+		assert.Empty(t, ctx.Vars()[VarPublishDir])
+	})
+
+	_, _, err = parsePublishOptions([]string{"/tmp/out", "latest:"})
+	assert.ErrorContains(t, err, "invalid publish option latest:")
+}
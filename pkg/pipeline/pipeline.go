@@ -3,8 +3,17 @@
 package pipeline
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
 
 	"github.com/SUNET/go-trust/pkg/logging"
 	"gopkg.in/yaml.v3"
@@ -22,12 +31,19 @@ import (
 type Pipeline struct {
 	Pipes  []Pipe         // The ordered list of pipeline steps to execute
 	Logger logging.Logger // Logger for pipeline operations (never nil)
+	Hash   string         // Hex-encoded SHA-256 of the YAML files the pipeline was loaded from, if any
 }
 
 // Process executes all the steps in the pipeline in sequence, passing the Context from one step to the next.
 // Each step modifies the Context and returns either a modified Context or an error.
 // If a step returns an error, pipeline processing stops and the error is returned.
 //
+// A panic inside a step is recovered and converted into a *StepPanicError, which is
+// logged together with its stack trace. Processing stops at the panicking step and
+// the Context passed into that step is returned alongside the error.
+//
+// Process is equivalent to ProcessWithReport with the report discarded.
+//
 // Parameters:
 //   - ctx: The initial Context to pass to the first step of the pipeline
 //
@@ -35,20 +51,141 @@ type Pipeline struct {
 //   - A pointer to the final Context after all steps have been executed
 //   - An error if any step fails
 func (pl *Pipeline) Process(ctx *Context) (*Context, error) {
+	ctx, _, err := pl.ProcessWithReport(ctx)
+	return ctx, err
+}
+
+// ProcessWithReport executes the pipeline like Process and additionally returns a
+// RunReport with per-step timings, item counts, warnings and errors. The report is
+// always non-nil, including when the run fails; it covers every step that was
+// attempted.
+//
+// Each step is logged at debug level as it completes, and a summary of the run is
+// logged at info level (or error level if the run failed).
+func (pl *Pipeline) ProcessWithReport(ctx *Context) (*Context, *RunReport, error) {
+	report := &RunReport{Start: time.Now()}
+
+	ctx, err := pl.process(ctx, report)
+	report.Duration = time.Since(report.Start)
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	pl.logReport(report)
+	return ctx, report, err
+}
+
+// process runs the steps and appends a StepReport to report for each one.
+func (pl *Pipeline) process(ctx *Context, report *RunReport) (*Context, error) {
+	run := &lockOwner{}
 	for i, pipe := range pl.Pipes {
+		step := StepReport{Index: i, Name: pipe.MethodName, Start: time.Now()}
+		if ctx != nil {
+			ctx.locks = run
+		}
+
 		fn, ok := GetFunctionByName(pipe.MethodName)
 		if !ok {
-			return nil, fmt.Errorf("step %d: unknown methodName '%s'", i, pipe.MethodName)
+			err := unknownStepError(i, pipe)
+			step.Error = err.Error()
+			report.Steps = append(report.Steps, step)
+			return nil, err
+		}
+
+		next, err := pl.runStep(i, pipe, fn, ctx)
+		step.Duration = time.Since(step.Start)
+
+		// Collect stats from the input context and, if the step replaced it, the output
+		warnings, items := ctx.takeStepStats()
+		if next != ctx {
+			moreWarnings, moreItems := next.takeStepStats()
+			warnings = append(warnings, moreWarnings...)
+			if moreItems != nil {
+				items = moreItems
+			}
+		}
+		step.Warnings = warnings
+		report.Sources = append(report.Sources, ctx.takeSourceFetches()...)
+		if next != ctx {
+			report.Sources = append(report.Sources, next.takeSourceFetches()...)
 		}
-		var err error
-		ctx, err = fn(pl, ctx, pipe.MethodArguments...)
+		if items != nil {
+			step.ItemsProcessed = *items
+		} else if next != nil && next.TSLs != nil {
+			step.ItemsProcessed = next.TSLs.Size()
+		}
+
 		if err != nil {
-			return ctx, fmt.Errorf("step %d (%s) failed: %w", i, pipe.MethodName, err)
+			step.Error = err.Error()
+			var panicErr *StepPanicError
+			if errors.As(err, &panicErr) {
+				step.Panicked = true
+				report.Steps = append(report.Steps, step)
+				return ctx, err
+			}
+			report.Steps = append(report.Steps, step)
+			return next, fmt.Errorf("step %d (%s) failed: %w", i, pipe.MethodName, err)
 		}
+
+		report.Steps = append(report.Steps, step)
+		ctx = next
 	}
 	return ctx, nil
 }
 
+// logReport writes the per-step and summary log entries for a completed run.
+func (pl *Pipeline) logReport(report *RunReport) {
+	if pl.Logger == nil {
+		return
+	}
+
+	for _, step := range report.Steps {
+		pl.Logger.Debug("Pipeline step completed",
+			logging.F("step", step.Name),
+			logging.F("index", step.Index),
+			logging.F("duration_ms", step.Duration.Milliseconds()),
+			logging.F("items", step.ItemsProcessed),
+			logging.F("warnings", len(step.Warnings)),
+			logging.F("error", step.Error))
+		for _, w := range step.Warnings {
+			pl.Logger.Warn("Pipeline step warning",
+				logging.F("step", step.Name),
+				logging.F("index", step.Index),
+				logging.F("warning", w))
+		}
+	}
+
+	fields := []logging.Field{
+		logging.F("steps", len(report.Steps)),
+		logging.F("duration_ms", report.Duration.Milliseconds()),
+		logging.F("warnings", len(report.Warnings())),
+	}
+	if report.Succeeded() {
+		pl.Logger.Info("Pipeline run completed", fields...)
+	} else {
+		pl.Logger.Error("Pipeline run failed", append(fields, logging.F("error", report.Error))...)
+	}
+}
+
+// runStep invokes a single step function, recovering from any panic it raises.
+// A recovered panic is logged with its stack trace and returned as a *StepPanicError.
+func (pl *Pipeline) runStep(index int, pipe Pipe, fn StepFunc, ctx *Context) (next *Context, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr := NewStepPanicError(pipe.MethodName, index, r, debug.Stack())
+			if pl.Logger != nil {
+				pl.Logger.Error("Pipeline step panicked",
+					logging.F("step", pipe.MethodName),
+					logging.F("index", index),
+					logging.F("panic", fmt.Sprint(r)),
+					logging.F("stack", string(panicErr.Stack)))
+			}
+			next, err = nil, panicErr
+		}
+	}()
+	return fn(pl, ctx, pipe.MethodArguments...)
+}
+
 // NewPipeline loads a pipeline from a YAML file and returns a new Pipeline instance.
 // The YAML file must contain a sequence of steps, where each step is a map with a single key
 // (the method name) and a list of string arguments.
@@ -66,46 +203,134 @@ func (pl *Pipeline) Process(ctx *Context) (*Context, error) {
 //	- publish:
 //		- /path/to/output
 //
+// A file may hold several YAML documents separated by "---", whose steps are
+// run in order. filename may also be a directory, see NewPipelineFiles.
+//
 // Parameters:
-//   - filename: Path to the YAML pipeline file
+//   - filename: Path to the YAML pipeline file, or a directory of them
 //
 // Returns:
 //   - A new Pipeline instance with the steps loaded from the YAML file
 //   - An error if the file cannot be opened or parsed
 func NewPipeline(filename string) (*Pipeline, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+	return NewPipelineFiles(filename)
+}
+
+// NewPipelineFiles creates a new Pipeline from the steps of several YAML
+// pipeline files, run in the order given. A directory stands for the *.yaml
+// and *.yml files in it, in lexical order, so that a large pipeline can be
+// split into files such as 00-fetch.yaml, 10-load.yaml and 20-publish.yaml.
+// The Hash of the pipeline covers all files.
+func NewPipelineFiles(paths ...string) (*Pipeline, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		dirFiles, err := pipelineDirFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no pipeline files given")
 	}
-	defer file.Close()
 
 	// Always use the default logger - configuration should come from cmdline args, not pipeline files
 	logger := logging.DefaultLogger()
 
-	// Parse the pipeline as a simple list of pipes (no config sections)
 	var pipes []Pipe
-	decoder := yaml.NewDecoder(file)
-	if err := decoder.Decode(&pipes); err != nil {
-		return nil, fmt.Errorf("failed to parse pipeline YAML: %w", err)
+	hash := sha256.New()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(data)
+		filePipes, err := parsePipelineFile(data)
+		if err != nil {
+			if len(files) > 1 {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			return nil, err
+		}
+		if len(files) > 1 {
+			for i := range filePipes {
+				filePipes[i].File = file
+			}
+		}
+		pipes = append(pipes, filePipes...)
 	}
 
-	// Create a new pipeline with the parsed pipes
 	return &Pipeline{
 		Pipes:  pipes,
 		Logger: logger,
+		Hash:   hex.EncodeToString(hash.Sum(nil)),
 	}, nil
 }
 
+// pipelineDirFiles returns the *.yaml and *.yml files in dir, in lexical
+// order. Subdirectories and hidden files are ignored.
+func pipelineDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if ext := filepath.Ext(name); ext == ".yaml" || ext == ".yml" {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no pipeline files (*.yaml, *.yml) in directory %s", dir)
+	}
+	return files, nil
+}
+
+// parsePipelineFile parses the steps of a pipeline file: a list of pipes, or
+// several lists in documents separated by "---". Empty documents are skipped.
+func parsePipelineFile(data []byte) ([]Pipe, error) {
+	// Parse the pipeline as a simple list of pipes (no config sections)
+	var pipes []Pipe
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc []Pipe
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return pipes, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pipeline YAML: %w", err)
+		}
+		pipes = append(pipes, doc...)
+	}
+}
+
 // Pipe represents a single step in the pipeline with its method name and arguments.
 // It provides custom YAML unmarshalling to parse the pipeline configuration format.
 // Each Pipe corresponds to a registered StepFunc that will be executed during pipeline processing.
 type Pipe struct {
 	MethodName      string   // The name of the registered function to call
 	MethodArguments []string // The arguments to pass to the function
+	Line            int      // Line of the step in the pipeline file, 0 if not read from one
+	File            string   // Pipeline file of the step, if the pipeline was read from several
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface for custom YAML parsing.
 // It expects a mapping node with exactly one key (the method name) and one value (a sequence of arguments).
+// Arguments must be scalars: a mapping or sequence, such as "- status: granted" with a space after the
+// colon, is an error rather than an empty argument.
 //
 // Example YAML structure:
 //
@@ -121,16 +346,20 @@ type Pipe struct {
 //   - An error if the YAML structure doesn't match the expected format
 func (p *Pipe) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.MappingNode || len(value.Content) != 2 {
-		return &yaml.TypeError{Errors: []string{"Pipe must be a map with a single key (method name) and a list of arguments"}}
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: Pipe must be a map with a single key (method name) and a list of arguments", value.Line)}}
 	}
 	methodNode := value.Content[0]
 	argsNode := value.Content[1]
 	p.MethodName = methodNode.Value
+	p.Line = methodNode.Line
 	if argsNode.Kind != yaml.SequenceNode {
-		return &yaml.TypeError{Errors: []string{"Pipe arguments must be a sequence"}}
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: Pipe arguments must be a sequence", argsNode.Line)}}
 	}
 	p.MethodArguments = make([]string, len(argsNode.Content))
 	for i, arg := range argsNode.Content {
+		if arg.Kind != yaml.ScalarNode {
+			return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: argument %d of %s must be a string; quote it if it contains \": \"", arg.Line, i+1, p.MethodName)}}
+		}
 		p.MethodArguments[i] = arg.Value
 	}
 	return nil
@@ -152,5 +381,6 @@ func (pl *Pipeline) WithLogger(logger logging.Logger) *Pipeline {
 	return &Pipeline{
 		Pipes:  pl.Pipes,
 		Logger: logger,
+		Hash:   pl.Hash,
 	}
 }
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"crypto/x509"
 	"os"
+	"path/filepath"
 	"testing"
 	"text/template"
 	"time"
@@ -63,6 +64,27 @@ func TestPipeline_Process_UnknownMethod(t *testing.T) {
 	assert.Contains(t, err.Error(), "unknown methodName")
 }
 
+func TestPipeline_Process_UnknownMethodSuggestion(t *testing.T) {
+	var pipes []Pipe
+	require.NoError(t, yaml.Unmarshal([]byte("- load: [se.xml]\n- selct: [all]\n"), &pipes))
+	assert.Equal(t, 2, pipes[1].Line)
+	_, err := createTestPipeline(pipes[1:]).Process(&Context{})
+	assert.EqualError(t, err, "step 0: unknown methodName 'selct' at line 2, did you mean 'select'?")
+	assert.Equal(t, "", SuggestStep("no-such-step"))
+}
+
+func TestPipe_UnmarshalYAML_Strict(t *testing.T) {
+	var pipes []Pipe
+	err := yaml.Unmarshal([]byte("- select:\n    - status: granted\n"), &pipes)
+	assert.ErrorContains(t, err, "line 2: argument 1 of select must be a string")
+
+	err = yaml.Unmarshal([]byte("- select: all\n"), &pipes)
+	assert.ErrorContains(t, err, "line 1: Pipe arguments must be a sequence")
+
+	require.NoError(t, yaml.Unmarshal([]byte("- select:\n    - \"status: granted\"\n"), &pipes))
+	assert.Equal(t, []string{"status: granted"}, pipes[0].MethodArguments)
+}
+
 func TestPipeline_Process_FuncError(t *testing.T) {
 	RegisterFunction("failfunc", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		return ctx, os.ErrPermission
@@ -81,6 +103,41 @@ func TestPipeline_Process_FuncError(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed")
 }
 
+func TestPipeline_Process_StepPanic(t *testing.T) {
+	RegisterFunction("panicfunc", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		var m map[string]int
+		m["boom"] = 1 // nil map assignment panics
+		return ctx, nil
+	})
+	ranAfter := false
+	RegisterFunction("afterpanic", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		ranAfter = true
+		return ctx, nil
+	})
+
+	pl := createTestPipeline([]Pipe{
+		{MethodName: "panicfunc", MethodArguments: []string{}},
+		{MethodName: "afterpanic", MethodArguments: []string{}},
+	})
+	input := NewContext()
+
+	var ctx *Context
+	var err error
+	require.NotPanics(t, func() {
+		ctx, err = pl.Process(input)
+	})
+	require.Error(t, err)
+	assert.Same(t, input, ctx, "the context passed into the panicking step should be returned")
+	assert.False(t, ranAfter, "steps after a panic must not run")
+
+	var panicErr *StepPanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "panicfunc", panicErr.StepName)
+	assert.Equal(t, 0, panicErr.StepIndex)
+	assert.Contains(t, err.Error(), "panicked")
+	assert.Contains(t, string(panicErr.Stack), "TestPipeline_Process_StepPanic")
+}
+
 // TestPipeline_SelectStep tests the select pipeline step with a local test TSL XML file.
 func TestPipeline_SelectStep(t *testing.T) {
 	// Render the XML template with the generated test certificate
@@ -134,6 +191,13 @@ func TestNewPipeline(t *testing.T) {
 	if len(pl.Pipes) != 1 || pl.Pipes[0].MethodName != "echo" {
 		t.Errorf("Expected one echo step, got: %+v", pl.Pipes)
 	}
+	// SHA-256 of the YAML content
+	if pl.Hash != "b26644e5cd802002a5fa5ca879d6bd982b53991f01b103b87e6b53175e62d488" {
+		t.Errorf("Expected a SHA-256 hash of the pipeline file, got %q", pl.Hash)
+	}
+	if pl.WithLogger(nil).Hash != pl.Hash {
+		t.Errorf("WithLogger should keep the pipeline hash")
+	}
 
 	// Test error case: file does not exist
 	_, err = NewPipeline("/nonexistent/file.yaml")
@@ -190,6 +254,51 @@ func TestNewPipeline_EdgeCases(t *testing.T) {
 	})
 }
 
+func TestNewPipelineFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	write("steps/20-publish.yaml", "- publish: [./out]\n")
+	write("steps/00-fetch.yaml", "- set-fetch-options: [timeout:10s]\n---\n# load the list\n- load: [tsl.xml]\n")
+	write("steps/10-select.yml", "- select: [all]\n")
+	write("steps/README.md", "not a pipeline")
+	write("steps/.10-backup.yaml", "- echo: []\n")
+	write("steps/nested/30-echo.yaml", "- echo: []\n")
+	extra := write("extra.yaml", "# nothing yet\n---\n- echo: [done]\n")
+
+	pl, err := NewPipelineFiles(filepath.Join(dir, "steps"), extra)
+	require.NoError(t, err)
+	var steps []string
+	for _, pipe := range pl.Pipes {
+		steps = append(steps, pipe.MethodName)
+	}
+	assert.Equal(t, []string{"set-fetch-options", "load", "select", "publish", "echo"}, steps)
+	assert.Equal(t, filepath.Join(dir, "steps", "00-fetch.yaml"), pl.Pipes[1].File)
+	assert.Equal(t, 4, pl.Pipes[1].Line)
+	assert.Equal(t, extra, pl.Pipes[4].File)
+	assert.Len(t, pl.Hash, 64)
+
+	single, err := NewPipeline(filepath.Join(dir, "steps", "00-fetch.yaml"))
+	require.NoError(t, err)
+	assert.Len(t, single.Pipes, 2)
+	assert.Empty(t, single.Pipes[0].File)
+
+	_, err = NewPipeline(filepath.Join(dir, "steps", "nested", "missing"))
+	assert.Error(t, err)
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.Mkdir(empty, 0755))
+	_, err = NewPipeline(empty)
+	assert.ErrorContains(t, err, "no pipeline files (*.yaml, *.yml) in directory")
+
+	write("broken/00-bad.yaml", "- select: [all]\n---\n- selct: all\n")
+	_, err = NewPipelineFiles(filepath.Join(dir, "broken"), extra)
+	assert.ErrorContains(t, err, filepath.Join(dir, "broken", "00-bad.yaml")+": failed to parse pipeline YAML")
+}
+
 func TestSelectCertPool_EdgeCases(t *testing.T) {
 	// No TSLs
 	ctx := &Context{TSLs: nil}
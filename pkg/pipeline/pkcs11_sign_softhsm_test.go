@@ -96,7 +96,7 @@ func TestPKCS11SignerWithSoftHSM(t *testing.T) {
 	assert.NoError(t, err, "Creating output directory should succeed")
 
 	// Publish the TSL with PKCS11 signing
-	_, err = PublishTSL(pipeline, ctx, outputDir, pkcs11URI, keyLabel, certLabel, keyID)
+	_, err = PublishTSL(pipeline, ctx, outputDir, pkcs11URI, keyLabel, certLabel, keyID, "name-template:test-tsl.xml")
 	assert.NoError(t, err, "Publishing TSL with PKCS11 signing should succeed")
 
 	// Check that the file was created
@@ -0,0 +1,28 @@
+package pipeline
+
+import "sync/atomic"
+
+// defaultMaxPoolSize is the largest number of distinct certificates the select
+// step adds to a certificate pool, unless the step sets its own limit; 0 is
+// unlimited.
+var defaultMaxPoolSize atomic.Int64
+
+// SetMaxPoolSize limits the number of distinct certificates the select step
+// adds to a certificate pool, for all pipelines. Certificates beyond the limit
+// are left out with a warning; select steps with a max-pool-size: option use
+// their own limit. A size of 0 or less removes the limit.
+func SetMaxPoolSize(size int) {
+	defaultMaxPoolSize.Store(int64(max(size, 0)))
+}
+
+// PoolSize returns the number of distinct certificates in the certificate pool
+// and the number of TSL service listings they were selected from. A
+// certificate listed in several TSLs, or under several services, is counted
+// once in certificates and once per listing in listings.
+//
+// Returns:
+//   - certificates: Distinct certificates added with AddPoolCertificate
+//   - listings: Provenance entries of those certificates
+func (ctx *Context) PoolSize() (certificates, listings int) {
+	return ctx.certPoolIndex().Size()
+}
@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poolSizeContext returns a context with two TSLs listing three distinct CA
+// certificates, one of them in both TSLs.
+func poolSizeContext(t *testing.T) *Context {
+	var cas []*testutil.Cert
+	for _, name := range []string{"CA 1", "CA 2", "CA 3"} {
+		ca, err := testutil.NewCA(name)
+		require.NoError(t, err)
+		cas = append(cas, ca)
+	}
+	se := generateTSL("QC Service", testTypeCAQC, []string{cas[0].Base64(), cas[1].Base64()})
+	fi := generateTSL("PKC Service", testTypeCAPKC, []string{cas[1].Base64(), cas[2].Base64()})
+
+	ctx := NewContext()
+	ctx.EnsureTSLStack()
+	ctx.TSLs.Push(se)
+	ctx.TSLs.Push(fi)
+	return ctx
+}
+
+func TestSelectCertPool_Deduplicates(t *testing.T) {
+	ctx, err := SelectCertPool(createTestPipeline(nil), poolSizeContext(t), "reference-depth:1")
+	require.NoError(t, err)
+
+	certificates, listings := ctx.PoolSize()
+	assert.Equal(t, 3, certificates)
+	assert.Equal(t, 4, listings)
+	_, items := ctx.takeStepStats()
+	require.NotNil(t, items)
+	assert.Equal(t, 3, *items, "the step reports distinct certificates")
+}
+
+func TestSelectCertPool_MaxPoolSize(t *testing.T) {
+	ctx, err := SelectCertPool(createTestPipeline(nil), poolSizeContext(t), "reference-depth:1", "max-pool-size:2")
+	require.NoError(t, err)
+
+	certificates, listings := ctx.PoolSize()
+	assert.Equal(t, 2, certificates)
+	assert.Equal(t, 3, listings, "listings of certificates already in a full pool are recorded")
+	assert.Len(t, ctx.CertProvenance(ctx.PoolCertificates()[1].Certificate), 2)
+	warnings, _ := ctx.takeStepStats()
+	assert.Equal(t, []string{"certificate pool limit of 2 reached: 1 certificates left out"}, warnings)
+
+	// The process-wide limit applies unless the step sets its own
+	SetMaxPoolSize(1)
+	defer SetMaxPoolSize(0)
+	ctx, err = SelectCertPool(createTestPipeline(nil), poolSizeContext(t), "reference-depth:1")
+	require.NoError(t, err)
+	certificates, _ = ctx.PoolSize()
+	assert.Equal(t, 1, certificates)
+
+	ctx, err = SelectCertPool(createTestPipeline(nil), poolSizeContext(t), "reference-depth:1", "max-pool-size:0")
+	require.NoError(t, err)
+	certificates, _ = ctx.PoolSize()
+	assert.Equal(t, 3, certificates)
+	warnings, _ = ctx.takeStepStats()
+	assert.Empty(t, warnings)
+}
+
+func TestAddPoolCertificate_ReportsNew(t *testing.T) {
+	ca, err := testutil.NewCA("CA")
+	require.NoError(t, err)
+
+	ctx := NewContext()
+	assert.True(t, ctx.AddPoolCertificate(ca.Certificate, CertProvenance{Provider: "P", Service: "S1"}))
+	assert.False(t, ctx.AddPoolCertificate(ca.Certificate, CertProvenance{Provider: "P", Service: "S2"}))
+	assert.False(t, ctx.AddPoolCertificate(ca.Certificate, CertProvenance{Provider: "P", Service: "S2"}))
+
+	certificates, listings := ctx.PoolSize()
+	assert.Equal(t, 1, certificates)
+	assert.Equal(t, 2, listings)
+
+	var nilCtx *Context
+	certificates, listings = nilCtx.PoolSize()
+	assert.Zero(t, certificates)
+	assert.Zero(t, listings)
+}
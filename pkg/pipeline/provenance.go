@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"crypto/x509"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/tslpool"
+)
+
+// CertProvenance records where a certificate of the certificate pool came
+// from: the TSL it is listed in and the trust service it belongs to. An
+// x509.CertPool does not retain this, so SelectCertPool records it alongside.
+type CertProvenance = tslpool.Provenance
+
+// PoolCertificate is a certificate of the certificate pool together with the
+// trust services it was selected from. A certificate listed under several
+// services, or in several TSLs, has one provenance entry for each.
+type PoolCertificate = tslpool.Certificate
+
+// NewCertProvenance returns the provenance of a certificate listed under the
+// trust service svc of provider tsp in tsl.
+func NewCertProvenance(tsl *etsi119612.TSL, tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) CertProvenance {
+	return tslpool.NewProvenance(tsl, tsp, svc)
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of the DER encoding of cert,
+// which identifies certificates of the pool.
+func Fingerprint(cert *x509.Certificate) string {
+	return tslpool.Fingerprint(cert)
+}
+
+// certPoolIndex returns the provenance index of the certificate pool, or nil
+// if none was recorded for the current CertPool, for instance because it was
+// replaced by assigning the field.
+func (ctx *Context) certPoolIndex() *tslpool.Pool {
+	if ctx == nil || ctx.pool == nil || ctx.pool.CertPool() != ctx.CertPool {
+		return nil
+	}
+	return ctx.pool
+}
+
+// AddPoolCertificate adds cert to the certificate pool, creating the pool if
+// needed, and records prov as one of its origins. Certificates are identified
+// by fingerprint, so a certificate already in the pool is not added again.
+//
+// Parameters:
+//   - cert: The certificate to trust
+//   - prov: The TSL service the certificate was selected from
+//
+// Returns:
+//   - true if cert was not in the pool before
+func (ctx *Context) AddPoolCertificate(cert *x509.Certificate, prov CertProvenance) bool {
+	if ctx.CertPool == nil {
+		ctx.InitCertPool()
+	}
+	if ctx.certPoolIndex() == nil {
+		ctx.pool = tslpool.NewPool(ctx.CertPool)
+	}
+	return ctx.pool.Add(cert, prov)
+}
+
+// CertProvenance returns the TSL services cert was selected from, or nil if
+// it is not in the certificate pool or was added without provenance.
+func (ctx *Context) CertProvenance(cert *x509.Certificate) []CertProvenance {
+	return ctx.certPoolIndex().Provenance(cert)
+}
+
+// PoolCertificate returns the certificate of the pool with the given hex
+// SHA-256 fingerprint, or nil if there is none.
+func (ctx *Context) PoolCertificate(fingerprint string) *PoolCertificate {
+	return ctx.certPoolIndex().Certificate(fingerprint)
+}
+
+// PoolCertificates returns the certificates added with AddPoolCertificate,
+// ordered by subject and then by fingerprint.
+//
+// Returns:
+//   - A slice of pool certificates, empty if none were added
+func (ctx *Context) PoolCertificates() []*PoolCertificate {
+	return ctx.certPoolIndex().Certificates()
+}
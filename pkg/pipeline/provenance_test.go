@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectCertPool_Provenance(t *testing.T) {
+	sharedCA, err := testutil.NewCA("Shared CA")
+	require.NoError(t, err)
+	onlyCA, err := testutil.NewCA("Only CA")
+	require.NoError(t, err)
+	unlisted, err := testutil.NewCA("Unlisted CA")
+	require.NoError(t, err)
+
+	se := generateTSL("QC Service", testTypeCAQC, []string{sharedCA.Base64(), onlyCA.Base64()})
+	se.Source = "https://se.example/tsl.xml"
+	se.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	fi := generateTSL("PKC Service", testTypeCAPKC, []string{sharedCA.Base64()})
+	fi.Source = "https://fi.example/tsl.xml"
+	fi.StatusList.TslSchemeInformation.TslSchemeTerritory = "FI"
+
+	ctx := NewContext()
+	ctx.EnsureTSLStack()
+	ctx.TSLs.Push(se)
+	ctx.TSLs.Push(fi)
+	ctx, err = SelectCertPool(createTestPipeline(nil), ctx, "reference-depth:1")
+	require.NoError(t, err)
+
+	assert.Equal(t, []CertProvenance{{
+		Source:      "https://se.example/tsl.xml",
+		Territory:   "SE",
+		Provider:    "Test Provider",
+		Service:     "QC Service",
+		ServiceType: testTypeCAQC,
+		Status:      etsi119612.ServiceStatusGranted,
+	}}, ctx.CertProvenance(onlyCA.Certificate))
+
+	shared := ctx.CertProvenance(sharedCA.Certificate)
+	require.Len(t, shared, 2, "one entry per listing service")
+	assert.ElementsMatch(t, []string{"SE", "FI"}, []string{shared[0].Territory, shared[1].Territory})
+
+	assert.Nil(t, ctx.CertProvenance(unlisted.Certificate))
+
+	certs := ctx.PoolCertificates()
+	require.Len(t, certs, 2)
+	assert.Equal(t, "Only CA", certs[0].Certificate.Subject.CommonName, "ordered by subject")
+	assert.Equal(t, Fingerprint(onlyCA.Certificate), certs[0].Fingerprint)
+	assert.Same(t, certs[1], ctx.PoolCertificate(Fingerprint(sharedCA.Certificate)))
+
+	// Selecting again starts a fresh pool without the previous provenance
+	ctx, err = SelectCertPool(createTestPipeline(nil), ctx, "reference-depth:1", "service-type:"+testTypeCAPKC)
+	require.NoError(t, err)
+	assert.Nil(t, ctx.CertProvenance(onlyCA.Certificate))
+	assert.Len(t, ctx.CertProvenance(sharedCA.Certificate), 1)
+}
+
+func TestAddPoolCertificate_Deduplicates(t *testing.T) {
+	ca, err := testutil.NewCA("CA")
+	require.NoError(t, err)
+
+	ctx := NewContext()
+	prov := CertProvenance{Provider: "P", Service: "S"}
+	ctx.AddPoolCertificate(ca.Certificate, prov)
+	ctx.AddPoolCertificate(ca.Certificate, prov)
+
+	require.NotNil(t, ctx.CertPool)
+	assert.Len(t, ctx.PoolCertificates(), 1)
+	assert.Equal(t, []CertProvenance{prov}, ctx.CertProvenance(ca.Certificate))
+
+	var nilCtx *Context
+	assert.Empty(t, nilCtx.PoolCertificates())
+	assert.Nil(t, nilCtx.PoolCertificate(Fingerprint(ca.Certificate)))
+}
@@ -0,0 +1,457 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+)
+
+//go:embed templates/provider.html
+var providerHTMLTemplate string
+
+// providerPagesDir is the directory, below the output directory of
+// generate-provider-pages, that holds the provider pages.
+const providerPagesDir = "providers"
+
+// Base URL of the published provider pages, linked from API responses
+var (
+	providerPagesMu  sync.RWMutex
+	providerPagesURL string
+)
+
+// SetProviderPagesURL sets the URL under which the output directory of
+// generate-provider-pages is published, such as
+// "https://tsl.example.com/html" or "/published". API responses naming a
+// trust service provider then link to its page. An empty URL disables the
+// links.
+func SetProviderPagesURL(base string) {
+	providerPagesMu.Lock()
+	defer providerPagesMu.Unlock()
+	providerPagesURL = strings.TrimSuffix(base, "/")
+}
+
+// ProviderPagePath returns the path of the page of a trust service provider,
+// relative to the output directory of generate-provider-pages. The path is
+// derived from the territory and the display name of the provider, as in
+// CertProvenance, so providers of a territory with the same name share a page.
+func ProviderPagePath(territory, provider string) string {
+	return path.Join(providerPagesDir, territorySlug(territory), providerSlug(provider)+".html")
+}
+
+// ProviderID returns the identifier of a trust service provider in the static
+// JSON API written by publish: the territory and the display name of the
+// provider as in ProviderPagePath, joined by a dash.
+func ProviderID(territory, provider string) string {
+	return territorySlug(territory) + "-" + providerSlug(provider)
+}
+
+// territorySlug returns the lower case territory code, or "unknown" if
+// territory is not a plain code.
+func territorySlug(territory string) string {
+	slug := strings.ToLower(strings.TrimSpace(territory))
+	if !isSlug(slug) {
+		return "unknown"
+	}
+	return slug
+}
+
+// ProviderPageURL returns the URL of the page of a trust service provider, or
+// "" if no provider pages URL is set. A non-empty fingerprint links to the
+// certificate with that hex SHA-256 fingerprint on the page.
+func ProviderPageURL(territory, provider, fingerprint string) string {
+	providerPagesMu.RLock()
+	base := providerPagesURL
+	providerPagesMu.RUnlock()
+	if base == "" {
+		return ""
+	}
+	link := base + "/" + ProviderPagePath(territory, provider)
+	if fingerprint != "" {
+		link += "#" + certAnchor(fingerprint)
+	}
+	return link
+}
+
+// LinkProviderPages returns a copy of provs with the provider page of each
+// entry set, linking to the certificate with the given fingerprint, or provs
+// itself if no provider pages URL is set.
+func LinkProviderPages(provs []CertProvenance, fingerprint string) []CertProvenance {
+	if len(provs) == 0 || ProviderPageURL("", "", "") == "" {
+		return provs
+	}
+	linked := make([]CertProvenance, len(provs))
+	for i, prov := range provs {
+		prov.ProviderPage = ProviderPageURL(prov.Territory, prov.Provider, fingerprint)
+		linked[i] = prov
+	}
+	return linked
+}
+
+// providerSlug returns the file name, without extension, of the page of the
+// provider with the given name: the name in lower case with runs of other
+// characters than ASCII letters and digits replaced by a dash. Names without
+// any such character are named by a hash of the name.
+func providerSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 80 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		sum := sha256.Sum256([]byte(name))
+		return "provider-" + hex.EncodeToString(sum[:4])
+	}
+	return b.String()
+}
+
+// isSlug reports whether s is a non-empty string of ASCII letters and digits.
+func isSlug(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// certAnchor returns the id of the certificate with the given fingerprint on
+// a provider page.
+func certAnchor(fingerprint string) string {
+	return "cert-" + strings.ToLower(fingerprint)
+}
+
+// providerPage is the content of the page of a trust service provider, and of
+// its document in the static JSON API.
+type providerPage struct {
+	ID              string                `json:"id"`
+	Path            string                `json:"-"`
+	Name            string                `json:"name"`
+	Territory       string                `json:"territory,omitempty"`
+	OtherNames      []string              `json:"other_names,omitempty"` // Names in other languages, as "lang: name"
+	TradeNames      []string              `json:"trade_names,omitempty"`
+	InformationURIs []string              `json:"information_uris,omitempty"`
+	Sources         []string              `json:"sources,omitempty"` // URLs or files of the TSLs listing the provider
+	Services        []providerPageService `json:"services"`
+	Certificates    int                   `json:"num_certificates"`
+}
+
+// providerPageService is a trust service on a provider page.
+type providerPageService struct {
+	Name               string               `json:"name"`
+	Type               string               `json:"type"`
+	Status             string               `json:"status"`
+	StatusStartingTime string               `json:"status_starting_time,omitempty"`
+	History            []providerPageStatus `json:"history,omitempty"` // Earlier statuses, as listed in the TSL
+	Certificates       []providerPageCert   `json:"certificates"`
+}
+
+// providerPageStatus is an earlier status of a trust service.
+type providerPageStatus struct {
+	Name         string `json:"name,omitempty"`
+	Type         string `json:"type"`
+	Status       string `json:"status"`
+	StartingTime string `json:"status_starting_time"`
+}
+
+// providerPageCert is a certificate of a trust service on a provider page.
+type providerPageCert struct {
+	Anchor      string `json:"-"`
+	Fingerprint string `json:"sha256"`
+	Subject     string `json:"subject"`
+	Issuer      string `json:"issuer"`
+	Serial      string `json:"serial"`
+	NotBefore   string `json:"not_before"`
+	NotAfter    string `json:"not_after"`
+	Validity    string `json:"validity"` // "valid", "expired" or "not yet valid" when the page was generated
+	Class       string `json:"-"`        // CSS class of the validity badge
+}
+
+// ValidityKey returns the label key of the validity of c.
+func (c providerPageCert) ValidityKey() string {
+	return strings.ReplaceAll(c.Class, "-", "_")
+}
+
+// GenerateProviderPages is a pipeline step that writes an HTML page for every
+// trust service provider in the loaded TSLs and the TSLs they reference, so
+// that a specific CA can be audited without reading the whole TSL. A page
+// lists the services of the provider with their type, current status and
+// status history, and the certificates of each service with their subject,
+// issuer, serial number, validity and SHA-256 fingerprint.
+//
+// Arguments:
+//   - arg[0]: Output directory; the pages are written to
+//     providers/<territory>/<provider>.html below it (see ProviderPagePath)
+//   - "assets:cdn", "assets:inline" or "assets:copy" and "stylesheet:PATH":
+//     (Optional) How the pages load their styles, as for generate_index
+//   - "lang:TAG" and "messages:PATH": (Optional) Language and message catalog
+//     of the labels, as for generate_index
+//
+// generate_index, run on the same directory, lists the provider pages below
+// the TSLs. With SetProviderPagesURL (server.provider_pages_url), the trust
+// service attribution of decisions, /certificates and /debug/verify link to
+// the page of the provider, at the certificate.
+//
+// Example usage in pipeline YAML:
+//
+//   - generate-provider-pages:
+//   - /var/www/html
+//   - generate_index:
+//   - /var/www/html
+func GenerateProviderPages(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	if len(args) < 1 {
+		return ctx, fmt.Errorf("missing required output directory argument")
+	}
+	outputDir := args[0]
+	var opts htmlPageOptions
+	for _, arg := range args[1:] {
+		ok, err := opts.parseOption(arg)
+		if err != nil {
+			return ctx, err
+		}
+		if !ok {
+			return ctx, fmt.Errorf("unknown generate-provider-pages option %q", arg)
+		}
+	}
+	if err := opts.check(); err != nil {
+		return ctx, err
+	}
+	labels, err := opts.labels()
+	if err != nil {
+		return ctx, err
+	}
+
+	tsls := ctx.AllTSLs()
+	if len(tsls) == 0 {
+		return ctx, fmt.Errorf("no TSLs to generate provider pages for")
+	}
+
+	pages := collectProviderPages(tsls, time.Now())
+
+	tmpl, err := template.New("provider").Funcs(labels.funcs()).Parse(providerHTMLTemplate)
+	if err != nil {
+		return ctx, fmt.Errorf("failed to parse template: %w", err)
+	}
+	styles, err := opts.assets.page("provider", indexCSS, "")
+	if err != nil {
+		return ctx, err
+	}
+	if err := styles.write(outputDir); err != nil {
+		return ctx, err
+	}
+	// Pages are two levels below the output directory
+	styles = styles.relativeTo("../../")
+	generated := time.Now().Format("2006-01-02")
+	for _, page := range pages {
+		if err := writeProviderPage(tmpl, outputDir, page, generated, styles, labels.lang); err != nil {
+			return ctx, err
+		}
+	}
+
+	if pl != nil {
+		pl.Logger.Info("Generated provider pages",
+			logging.F("directory", outputDir),
+			logging.F("pages", len(pages)))
+	}
+	return ctx, nil
+}
+
+// collectProviderPages returns the provider pages of the providers in tsls,
+// ordered by path. Certificate validity is given as of now.
+func collectProviderPages(tsls []*etsi119612.TSL, now time.Time) []*providerPage {
+	langs := i18n.PreferredLanguages()
+	pages := make(map[string]*providerPage)
+	for _, tsl := range tsls {
+		if tsl == nil || tsl.StatusList.TslTrustServiceProviderList == nil {
+			continue
+		}
+		territory := ""
+		if si := tsl.StatusList.TslSchemeInformation; si != nil {
+			territory = si.TslSchemeTerritory
+		}
+		for _, tsp := range tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider {
+			if tsp == nil {
+				continue
+			}
+			name := NewCertProvenance(tsl, tsp, nil).Provider
+			pagePath := ProviderPagePath(territory, name)
+			page, ok := pages[pagePath]
+			if !ok {
+				page = &providerPage{ID: ProviderID(territory, name), Path: pagePath, Name: name, Territory: territory}
+				if info := tsp.TslTSPInformation; info != nil {
+					page.OtherNames = otherNames(info.TSPName, name)
+					page.TradeNames = allNames(info.TSPTradeName)
+					if info.TSPInformationURI != nil {
+						for _, uri := range info.TSPInformationURI.URI {
+							if uri != nil && uri.Value != "" {
+								page.InformationURIs = append(page.InformationURIs, uri.Value)
+							}
+						}
+					}
+				}
+				pages[pagePath] = page
+			}
+			if tsl.Source != "" && !containsString(page.Sources, tsl.Source) {
+				page.Sources = append(page.Sources, tsl.Source)
+			}
+			if tsp.TslTSPServices == nil {
+				continue
+			}
+			for _, svc := range tsp.TslTSPServices.TslTSPService {
+				if svc == nil || svc.TslServiceInformation == nil {
+					continue
+				}
+				service := describePageService(svc, langs, now)
+				page.Certificates += len(service.Certificates)
+				page.Services = append(page.Services, service)
+			}
+		}
+	}
+
+	sorted := make([]*providerPage, 0, len(pages))
+	for _, page := range pages {
+		sorted = append(sorted, page)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	return sorted
+}
+
+// describePageService returns the providerPageService of svc, with names in
+// the languages that best match langs.
+func describePageService(svc *etsi119612.TSPServiceType, langs []string, now time.Time) providerPageService {
+	info := svc.TslServiceInformation
+	service := providerPageService{
+		Name:               i18n.Name(info.ServiceName, langs, "Unknown"),
+		Type:               info.TslServiceTypeIdentifier,
+		Status:             info.TslServiceStatus,
+		StatusStartingTime: info.StatusStartingTime,
+	}
+	if svc.TslServiceHistory != nil {
+		for _, h := range svc.TslServiceHistory.TslServiceHistoryInstance {
+			if h == nil {
+				continue
+			}
+			service.History = append(service.History, providerPageStatus{
+				Name:         i18n.Name(h.ServiceName, langs, ""),
+				Type:         h.TslServiceTypeIdentifier,
+				Status:       h.TslServiceStatus,
+				StartingTime: h.StatusStartingTime,
+			})
+		}
+	}
+	svc.WithCertificates(func(cert *x509.Certificate) {
+		fingerprint := Fingerprint(cert)
+		validity, class := "valid", "cert-valid"
+		if now.Before(cert.NotBefore) {
+			validity, class = "not yet valid", "cert-pending"
+		} else if now.After(cert.NotAfter) {
+			validity, class = "expired", "cert-expired"
+		}
+		service.Certificates = append(service.Certificates, providerPageCert{
+			Anchor:      certAnchor(fingerprint),
+			Fingerprint: fingerprint,
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			Serial:      cert.SerialNumber.String(),
+			NotBefore:   cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:    cert.NotAfter.UTC().Format(time.RFC3339),
+			Validity:    validity,
+			Class:       class,
+		})
+	})
+	return service
+}
+
+// otherNames returns the names in names other than shown, as "lang: name".
+func otherNames(names *etsi119612.InternationalNamesType, shown string) []string {
+	var other []string
+	if names == nil {
+		return nil
+	}
+	for _, n := range names.Name {
+		if n == nil || n.NonEmptyNormalizedString == nil || string(*n.NonEmptyNormalizedString) == shown {
+			continue
+		}
+		if n.XmlLangAttr != nil && *n.XmlLangAttr != "" {
+			other = append(other, fmt.Sprintf("%s: %s", *n.XmlLangAttr, *n.NonEmptyNormalizedString))
+		} else {
+			other = append(other, string(*n.NonEmptyNormalizedString))
+		}
+	}
+	return other
+}
+
+// allNames returns the text of every name in names.
+func allNames(names *etsi119612.InternationalNamesType) []string {
+	return otherNames(names, "")
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// writeProviderPage renders page below outputDir.
+func writeProviderPage(tmpl *template.Template, outputDir string, page *providerPage, generated string, assets pageAssets, lang string) error {
+	file := filepath.Join(outputDir, filepath.FromSlash(page.Path))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", page.Path, err)
+	}
+
+	data := struct {
+		*providerPage
+		Lang          string
+		IndexURL      string
+		GeneratedDate string
+		Assets        pageAssets
+	}{
+		providerPage: page,
+		Lang:         lang,
+		// Pages are two levels below the output directory, where the index is
+		IndexURL:      "../../index.html",
+		GeneratedDate: generated,
+		Assets:        assets,
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", file, err)
+	}
+	defer f.Close()
+	if err := tmpl.Execute(f, data); err != nil {
+		return fmt.Errorf("failed to execute template for %s: %w", page.Path, err)
+	}
+	return nil
+}
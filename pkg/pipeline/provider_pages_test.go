@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateProviderPages(t *testing.T) {
+	ca, err := testutil.NewCA("Provider CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	tsl := generateTSL("QC Service", testTypeCAQC, []string{ca.Base64()})
+	tsl.Source = "https://se.example/tsl.xml"
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	svc := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0]
+	svc.TslServiceHistory = &etsi119612.ServiceHistoryType{
+		TslServiceHistoryInstance: []*etsi119612.ServiceHistoryInstanceType{{
+			TslServiceTypeIdentifier: testTypeCAQC,
+			TslServiceStatus:         "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/undersupervision",
+			StatusStartingTime:       "2016-06-30T22:00:00Z",
+		}},
+	}
+	ctx := NewContext()
+	ctx.AddTSL(tsl)
+
+	dir := t.TempDir()
+	_, err = GenerateProviderPages(createTestPipeline(nil), ctx, dir)
+	require.NoError(t, err)
+
+	pagePath := ProviderPagePath("SE", "Test Provider")
+	assert.Equal(t, "providers/se/test-provider.html", pagePath)
+	content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(pagePath)))
+	require.NoError(t, err)
+	page := string(content)
+	fingerprint := Fingerprint(ca.Certificate)
+	assert.Contains(t, page, `id="cert-`+fingerprint+`"`)
+	assert.Contains(t, page, "QC Service")
+	assert.Contains(t, page, "Svcstatus/undersupervision")
+	assert.Contains(t, page, "2016-06-30T22:00:00Z")
+	assert.Contains(t, page, "CN=Provider CA")
+	assert.Contains(t, page, ">valid<")
+
+	// The index lists the provider page below the TSLs
+	createSampleTSLHTML(t, dir, "SE-TL.html", "Sweden", "SE", "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric", "1", "2025-09-15", "2025-12-15", 1)
+	_, err = GenerateIndex(nil, ctx, dir)
+	require.NoError(t, err)
+	providers, err := findProviderPages(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []ProviderIndexEntry{{
+		Name: "Test Provider", Territory: "SE", URL: pagePath, Services: 1, Certificates: 1,
+	}}, providers)
+	entries, err := findTSLHtmlFiles(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "provider pages are not listed as TSLs")
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `href="`+pagePath+`"`)
+
+	// Decisions link to the page once the pages URL is set
+	chain := []*x509.Certificate{leaf.Certificate, ca.Certificate}
+	assert.Empty(t, ctx.MatchTrustService(chain, nil).ProviderPage)
+	SetProviderPagesURL("https://tsl.example.com/html/")
+	defer SetProviderPagesURL("")
+	assert.Equal(t, "https://tsl.example.com/html/providers/se/test-provider.html#cert-"+fingerprint,
+		ctx.MatchTrustService(chain, nil).ProviderPage)
+
+	// Self-contained pages link the copied stylesheets from two levels down
+	_, err = GenerateProviderPages(createTestPipeline(nil), ctx, dir, "assets:copy")
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(dir, filepath.FromSlash(pagePath)))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "cdn.jsdelivr.net")
+	assert.Regexp(t, `href="\.\./\.\./assets/base-[0-9a-f]{12}\.css"`, string(content))
+
+	// Labels in the language of the lang: option
+	_, err = GenerateProviderPages(createTestPipeline(nil), ctx, dir, "lang:de")
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(dir, filepath.FromSlash(pagePath)))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `<html lang="de"`)
+	assert.Contains(t, string(content), ">gültig<")
+	assert.Contains(t, string(content), "Statusverlauf (1)")
+
+	_, err = GenerateProviderPages(createTestPipeline(nil), ctx, dir, "assets:offline")
+	assert.Error(t, err)
+	_, err = GenerateProviderPages(createTestPipeline(nil), NewContext(), dir)
+	assert.Error(t, err)
+	_, err = GenerateProviderPages(createTestPipeline(nil), ctx)
+	assert.Error(t, err)
+}
+
+func TestProviderPagePath(t *testing.T) {
+	tests := []struct {
+		territory, provider, path string
+	}{
+		{"SE", "Example Trust Services AB", "providers/se/example-trust-services-ab.html"},
+		{"de", "  D-Trust GmbH (Bundesdruckerei)  ", "providers/de/d-trust-gmbh-bundesdruckerei.html"},
+		{"", "Société Générale", "providers/unknown/soci-t-g-n-rale.html"},
+		{"../x", "Provider", "providers/unknown/provider.html"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.path, ProviderPagePath(tt.territory, tt.provider), tt.provider)
+	}
+	// Names without ASCII letters or digits are named by a hash
+	assert.Regexp(t, `^providers/gr/provider-[0-9a-f]{8}\.html$`, ProviderPagePath("GR", "Ελληνική Δημοκρατία"))
+	assert.NotEqual(t, ProviderPagePath("GR", "Ελληνική Δημοκρατία"), ProviderPagePath("GR", "Ελλάδα"))
+}
@@ -1,20 +1,18 @@
 package pipeline
 
 import (
-	"encoding/xml"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
-	"github.com/SUNET/go-trust/pkg/dsig"
 	"github.com/SUNET/go-trust/pkg/logging"
 )
 
 // processTreeForPublishing processes a TSL tree for publishing,
 // maintaining the tree structure in the file system
-func processTreeForPublishing(pl *Pipeline, ctx *Context, tree *TSLTree, baseDir string, treeIndex int, subdirFormat string, signer dsig.XMLSigner) error {
+func processTreeForPublishing(pl *Pipeline, ctx *Context, tree *TSLTree, baseDir string, treeIndex int, subdirFormat string, opts *publishOptions) error {
 	if tree == nil || tree.Root == nil {
 		return nil
 	}
@@ -44,58 +42,129 @@ func processTreeForPublishing(pl *Pipeline, ctx *Context, tree *TSLTree, baseDir
 		logging.F("directory", treeDir),
 		logging.F("territory", rootTSL.StatusList.TslSchemeInformation.TslSchemeTerritory),
 		logging.F("format", subdirFormat))
-	if err := os.MkdirAll(treeDir, 0755); err != nil {
-		return fmt.Errorf("failed to create tree directory %s: %w", treeDir, err)
+	if opts.writes() {
+		if err := opts.mkdirAll(treeDir); err != nil {
+			return fmt.Errorf("failed to create tree directory %s: %w", treeDir, err)
+		}
 	}
 
 	// Process the tree recursively
-	return processNodeForPublishing(pl, ctx, tree.Root, treeDir, 0, signer)
+	return processNodeForPublishing(pl, ctx, tree.Root, treeDir, 0, opts)
 }
 
-// publishTSLToFile writes a TSL to a file, optionally signing it
-func publishTSLToFile(pl *Pipeline, tsl *etsi119612.TSL, filePath string, signer dsig.XMLSigner) error {
+// publishTSLToFile writes a TSL to a file, optionally signing it and writing
+// sidecar files as configured in opts. A nil opts publishes unsigned XML only.
+func publishTSLToFile(pl *Pipeline, ctx *Context, tsl *etsi119612.TSL, filePath string, opts *publishOptions) error {
 	if tsl == nil {
 		return fmt.Errorf("cannot publish nil TSL")
 	}
+	if opts == nil {
+		opts = &publishOptions{}
+	}
 
-	// Create XML representation with root element
-	type TrustStatusListWrapper struct {
-		XMLName xml.Name                       `xml:"TrustServiceStatusList"`
-		List    etsi119612.TrustStatusListType `xml:",innerxml"`
+	var extensions map[*etsi119612.ExtensionType]*ExtensionContent
+	if ctx != nil {
+		extensions = ctx.Extensions
 	}
-	wrapper := TrustStatusListWrapper{List: tsl.StatusList}
-	xmlData, err := xml.MarshalIndent(wrapper, "", "  ")
+	xmlData, err := marshalTSLWithExtensions(tsl, extensions, pl.Logger)
 	if err != nil {
-		return fmt.Errorf("failed to marshal TSL to XML: %w", err)
+		return err
+	}
+
+	// A dry run compares the unsigned XML with the published file, ignoring its signature
+	if opts.dryRun {
+		change, err := comparePublished(filePath, xmlData)
+		if err != nil {
+			return err
+		}
+		opts.changes = append(opts.changes, change)
+		pl.Logger.Info("Compared TSL with published file",
+			logging.F("file", filePath),
+			logging.F("status", change.Status),
+			logging.F("old_size", change.OldSize),
+			logging.F("new_size", change.NewSize),
+			logging.F("details", strings.Join(change.Details, "; ")))
+		return nil
+	}
+
+	// A reproducible publish keeps a file whose content has not changed, so
+	// that its bytes, and those of its sidecars, stay the same
+	if opts.reproducible {
+		kept, err := keepPublished(filePath, xmlData, tsl, opts.signer != nil, time.Now())
+		if err != nil {
+			return err
+		}
+		if kept != nil {
+			opts.published = append(opts.published, publishedFile{tsl: kept, path: filePath})
+			pl.Logger.Info("Kept unchanged TSL",
+				logging.F("file", filePath),
+				logging.F("sequence_number", kept.StatusList.TslSchemeInformation.TSLSequenceNumber))
+			return nil
+		}
 	}
 
-	// Add XML header
-	xmlData = append([]byte(xml.Header), xmlData...)
+	unsigned := xmlData
 
 	// Sign the XML if a signer is provided
-	if signer != nil {
-		xmlData, err = signer.Sign(xmlData)
+	if opts.signer != nil {
+		xmlData, err = opts.signer.Sign(xmlData)
 		if err != nil {
-			return fmt.Errorf("failed to sign XML: %w", err)
+			return fmt.Errorf("failed to sign TSL: %w", err)
 		}
 	}
 
+	// Canonicalizing keeps the signature valid, and the signed bytes are
+	// verified as written
+	if opts.c14n {
+		xmlData, err = canonicalXML(xmlData)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.signer != nil {
+		// Never publish a list that does not verify
+		cert, err := verifySigned(tsl, xmlData)
+		if err != nil {
+			return fmt.Errorf("signed TSL for %s failed verification: %w", filePath, err)
+		}
+		pl.Logger.Debug("Verified TSL signature",
+			logging.F("file", filePath),
+			logging.F("signer", cert.Subject.String()))
+	}
+
 	// Write to file
-	if err := os.WriteFile(filePath, xmlData, 0644); err != nil {
+	if err := opts.writeFile(filePath, xmlData); err != nil {
 		return fmt.Errorf("failed to write TSL to file %s: %w", filePath, err)
 	}
+	opts.published = append(opts.published, publishedFile{tsl: tsl, path: filePath})
+
+	if opts.sidecars {
+		if err := writeSidecars(filePath, tsl, xmlData, opts); err != nil {
+			return err
+		}
+	}
+
+	// During a key rollover, a copy is signed with the next key
+	if opts.nextSigner != nil {
+		if err := publishNextSigned(pl, tsl, filePath, unsigned, opts); err != nil {
+			return err
+		}
+	}
 
 	// Log success
 	pl.Logger.Info("Published TSL",
 		logging.F("file", filePath),
-		logging.F("signed", signer != nil),
+		logging.F("signed", opts.signer != nil),
+		logging.F("next_signed", opts.nextSigner != nil),
+		logging.F("sidecars", opts.sidecars),
 		logging.F("size", len(xmlData)))
 
 	return nil
 }
 
 // processNodeForPublishing recursively processes a TSL node for publishing
-func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath string, depth int, signer dsig.XMLSigner) error {
+func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath string, depth int, opts *publishOptions) error {
 	if node == nil || node.TSL == nil {
 		return nil
 	}
@@ -103,25 +172,14 @@ func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath
 	// Publish this node's TSL
 	tsl := node.TSL
 
-	// Determine filename
-	filename := fmt.Sprintf("tsl-depth-%d.xml", depth)
-	if tsl.StatusList.TslSchemeInformation != nil {
-		// Use scheme territory if available
-		territory := tsl.StatusList.TslSchemeInformation.TslSchemeTerritory
-		if territory != "" {
-			filename = fmt.Sprintf("%s.xml", territory)
-		}
-
-		// Use distribution point if available
-		if tsl.StatusList.TslSchemeInformation.TslDistributionPoints != nil &&
-			len(tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI) > 0 {
-
-			uri := tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI[0]
-			parts := strings.Split(uri, "/")
-			if len(parts) > 0 && parts[len(parts)-1] != "" {
-				filename = parts[len(parts)-1]
-			}
-		}
+	// Determine filename, falling back to the scheme territory
+	fallback := fmt.Sprintf("tsl-depth-%d.xml", depth)
+	if si := tsl.StatusList.TslSchemeInformation; si != nil && si.TslSchemeTerritory != "" {
+		fallback = fmt.Sprintf("%s.xml", si.TslSchemeTerritory)
+	}
+	filename, err := publishFileName(tsl, depth, fallback, opts)
+	if err != nil {
+		return err
 	}
 
 	// For referenced TSLs at deeper levels, create subdirectories
@@ -129,8 +187,10 @@ func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath
 	if depth > 0 {
 		// Create a depth-based subdirectory
 		nodePath = filepath.Join(dirPath, fmt.Sprintf("refs-%d", depth))
-		if err := os.MkdirAll(nodePath, 0755); err != nil {
-			return fmt.Errorf("failed to create depth directory %s: %w", nodePath, err)
+		if opts.writes() {
+			if err := opts.mkdirAll(nodePath); err != nil {
+				return fmt.Errorf("failed to create depth directory %s: %w", nodePath, err)
+			}
 		}
 
 		// Add depth prefix to filename for clarity
@@ -140,25 +200,25 @@ func processNodeForPublishing(pl *Pipeline, ctx *Context, node *TSLNode, dirPath
 	}
 
 	// Publish the TSL
-	filePath := filepath.Join(nodePath, filename)
-	if err := publishTSLToFile(pl, tsl, filePath, signer); err != nil {
+	filePath := claimPublishPath(pl, opts, filepath.Join(nodePath, filename))
+	if err := publishTSLToFile(pl, ctx, tsl, filePath, opts); err != nil {
 		return fmt.Errorf("failed to publish TSL to %s: %w", filePath, err)
 	}
 
 	// Create an index file that shows the tree structure
-	if depth == 0 {
+	if depth == 0 && opts.writes() {
 		// Find the tree that contains this node (for the index)
 		nodeTree := &TSLTree{Root: node}
 		indexContent := generateTreeIndex(nodeTree)
 		indexPath := filepath.Join(dirPath, "index.txt")
-		if err := os.WriteFile(indexPath, []byte(indexContent), 0644); err != nil {
+		if err := opts.writeFile(indexPath, []byte(indexContent)); err != nil {
 			pl.Logger.Warn("Failed to write tree index", logging.F("path", indexPath), logging.F("error", err))
 		}
 	}
 
 	// Process all child nodes
 	for i, child := range node.Children {
-		if err := processNodeForPublishing(pl, ctx, child, dirPath, depth+1, signer); err != nil {
+		if err := processNodeForPublishing(pl, ctx, child, dirPath, depth+1, opts); err != nil {
 			return fmt.Errorf("failed to process child %d: %w", i, err)
 		}
 	}
@@ -0,0 +1,293 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+)
+
+// StaticAPIDir is the subdirectory of the output directory that the "api"
+// publish option writes the static JSON API to.
+const StaticAPIDir = "api"
+
+// publishedFile is a TSL written by a publish step, and where.
+type publishedFile struct {
+	tsl  *etsi119612.TSL
+	path string
+}
+
+// TSLPointerSummary is a pointer of a TSL to another TSL, as listed in the
+// "pointers" of its summary.
+type TSLPointerSummary struct {
+	Location       string `json:"location"`                  // TSLLocation of the pointer
+	Loaded         bool   `json:"loaded"`                    // Whether the TSL at Location was loaded as a reference
+	Territory      string `json:"territory,omitempty"`       // Scheme territory of the loaded TSL
+	SequenceNumber int    `json:"sequence_number,omitempty"` // Sequence number of the loaded TSL
+}
+
+// TSLSummary returns the summary of tsl as served by the API, with the scheme
+// operator name in the language that best matches langs and that language as
+// scheme_operator_name_lang. So that monitoring can check where a TSL came
+// from, it adds, when known:
+//   - source_url: the URL the TSL was loaded from
+//   - distribution_points: the distribution points the TSL lists
+//   - loaded_from_distribution_point: whether source_url is one of them
+//   - scheme_operator_electronic_addresses: the electronic addresses of the
+//     scheme operator, such as mailto: and https: URIs
+//   - pointers: the pointers to other TSLs, as TSLPointerSummary
+func TSLSummary(tsl *etsi119612.TSL, langs []string) map[string]interface{} {
+	summary := tsl.Summary()
+	if tsl == nil {
+		return summary
+	}
+	if tsl.Source != "" {
+		summary["source_url"] = tsl.Source
+	}
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil {
+		return summary
+	}
+	if si.TslSchemeOperatorName != nil {
+		summary["scheme_operator_name"] = i18n.Name(si.TslSchemeOperatorName, langs, "Unknown scheme operator")
+		if lang := i18n.Language(si.TslSchemeOperatorName, langs); lang != "" {
+			summary["scheme_operator_name_lang"] = lang
+		}
+	}
+	if si.TslDistributionPoints != nil && len(si.TslDistributionPoints.URI) > 0 {
+		points := make([]string, 0, len(si.TslDistributionPoints.URI))
+		fromPoint := false
+		for _, uri := range si.TslDistributionPoints.URI {
+			uri = strings.TrimSpace(uri)
+			points = append(points, uri)
+			fromPoint = fromPoint || sameURL(uri, tsl.Source)
+		}
+		summary["distribution_points"] = points
+		if tsl.Source != "" {
+			summary["loaded_from_distribution_point"] = fromPoint
+		}
+	}
+	if addr := si.SchemeOperatorAddress; addr != nil && addr.TslElectronicAddress != nil {
+		var addresses []string
+		for _, uri := range addr.TslElectronicAddress.URI {
+			if uri != nil && strings.TrimSpace(uri.Value) != "" {
+				addresses = append(addresses, strings.TrimSpace(uri.Value))
+			}
+		}
+		if len(addresses) > 0 {
+			summary["scheme_operator_electronic_addresses"] = addresses
+		}
+	}
+	if si.TslPointersToOtherTSL != nil {
+		var pointers []TSLPointerSummary
+		for _, p := range si.TslPointersToOtherTSL.TslOtherTSLPointer {
+			if p == nil || strings.TrimSpace(p.TSLLocation) == "" {
+				continue
+			}
+			pointer := TSLPointerSummary{Location: strings.TrimSpace(p.TSLLocation)}
+			for _, ref := range tsl.Referenced {
+				if ref == nil || !sameURL(ref.Source, pointer.Location) {
+					continue
+				}
+				pointer.Loaded = true
+				if refSI := ref.StatusList.TslSchemeInformation; refSI != nil {
+					pointer.Territory = refSI.TslSchemeTerritory
+					pointer.SequenceNumber = refSI.TSLSequenceNumber
+				}
+				break
+			}
+			pointers = append(pointers, pointer)
+		}
+		if len(pointers) > 0 {
+			summary["pointers"] = pointers
+		}
+	}
+	return summary
+}
+
+// sameURL reports whether a and b are the same URL, ignoring surrounding
+// space and the case of the scheme and host. Empty URLs are never the same.
+func sameURL(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host) &&
+		ua.EscapedPath() == ub.EscapedPath() && ua.RawQuery == ub.RawQuery
+}
+
+// staticIndex is api/index.json, the counterpart of GET /tsls.
+type staticIndex struct {
+	Count       int                      `json:"count"`
+	LastUpdated string                   `json:"last_updated"`
+	TSLs        []map[string]interface{} `json:"tsls"`
+	Territories []staticTerritoryRef     `json:"territories"`
+}
+
+// staticTerritoryRef points at the document of a territory from api/index.json.
+type staticTerritoryRef struct {
+	Territory string `json:"territory"`
+	URL       string `json:"url"`
+	TSLs      int    `json:"num_tsls"`
+	Providers int    `json:"num_trust_service_providers"`
+}
+
+// staticTerritory is api/{territory}.json: the TSLs of a territory and its
+// trust service providers.
+type staticTerritory struct {
+	Territory   string                   `json:"territory"`
+	LastUpdated string                   `json:"last_updated"`
+	TSLs        []map[string]interface{} `json:"tsls"`
+	Providers   []staticProviderRef      `json:"trust_service_providers"`
+}
+
+// staticProviderRef points at the document of a provider from a territory.
+type staticProviderRef struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Services     int    `json:"num_services"`
+	Certificates int    `json:"num_certificates"`
+	URL          string `json:"url"`
+	Page         string `json:"page,omitempty"`
+}
+
+// staticProvider is api/providers/{id}.json, the content of the provider page.
+type staticProvider struct {
+	*providerPage
+	LastUpdated string `json:"last_updated"`
+	Page        string `json:"page,omitempty"`
+}
+
+// writeStaticAPI writes the static JSON API for the TSLs published to dirPath
+// by this run: api/index.json listing every TSL, api/{territory}.json with the
+// TSLs and providers of each territory, and api/providers/{id}.json with the
+// services and certificates of each provider, as on its provider page. URLs in
+// the documents are relative to dirPath. The api directory is replaced as a
+// whole, so providers that are no longer listed disappear.
+func writeStaticAPI(pl *Pipeline, dirPath string, opts *publishOptions, now time.Time) error {
+	apiDir := filepath.Join(dirPath, StaticAPIDir)
+	if err := os.RemoveAll(apiDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", apiDir, err)
+	}
+	for _, dir := range []string{apiDir, filepath.Join(apiDir, providerPagesDir)} {
+		if err := opts.mkdirAll(dir); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	langs := i18n.PreferredLanguages()
+	lastUpdated := now.UTC().Format(time.RFC3339)
+	index := staticIndex{LastUpdated: lastUpdated, TSLs: []map[string]interface{}{}, Territories: []staticTerritoryRef{}}
+	territories := make(map[string]*staticTerritory)
+	territory := func(slug string) *staticTerritory {
+		t, ok := territories[slug]
+		if !ok {
+			t = &staticTerritory{Territory: slug, LastUpdated: lastUpdated, TSLs: []map[string]interface{}{}, Providers: []staticProviderRef{}}
+			territories[slug] = t
+		}
+		return t
+	}
+
+	var tsls []*etsi119612.TSL
+	for _, pub := range opts.published {
+		summary := TSLSummary(pub.tsl, langs)
+		if rel, err := filepath.Rel(dirPath, pub.path); err == nil {
+			summary["file"] = filepath.ToSlash(rel)
+		}
+		slug := "unknown"
+		if si := pub.tsl.StatusList.TslSchemeInformation; si != nil {
+			slug = territorySlug(si.TslSchemeTerritory)
+			summary["territory"] = si.TslSchemeTerritory
+			summary["sequence_number"] = si.TSLSequenceNumber
+			summary["issue_date"] = si.ListIssueDateTime
+			if si.TslNextUpdate != nil {
+				summary["next_update"] = si.TslNextUpdate.DateTime
+			}
+		}
+		index.TSLs = append(index.TSLs, summary)
+		t := territory(slug)
+		t.TSLs = append(t.TSLs, summary)
+		tsls = append(tsls, pub.tsl)
+	}
+	index.Count = len(index.TSLs)
+
+	pages := collectProviderPages(tsls, now)
+	for _, page := range pages {
+		doc := staticProvider{providerPage: page, LastUpdated: lastUpdated}
+		if _, err := os.Stat(filepath.Join(dirPath, filepath.FromSlash(page.Path))); err == nil {
+			doc.Page = page.Path
+		}
+		url := path.Join(StaticAPIDir, providerPagesDir, page.ID+".json")
+		if err := writeStaticJSON(dirPath, url, doc, opts); err != nil {
+			return err
+		}
+		t := territory(territorySlug(page.Territory))
+		t.Providers = append(t.Providers, staticProviderRef{
+			ID:           page.ID,
+			Name:         page.Name,
+			Services:     len(page.Services),
+			Certificates: page.Certificates,
+			URL:          url,
+			Page:         doc.Page,
+		})
+	}
+
+	slugs := make([]string, 0, len(territories))
+	for slug := range territories {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		t := territories[slug]
+		url := path.Join(StaticAPIDir, slug+".json")
+		if err := writeStaticJSON(dirPath, url, t, opts); err != nil {
+			return err
+		}
+		index.Territories = append(index.Territories, staticTerritoryRef{
+			Territory: slug,
+			URL:       url,
+			TSLs:      len(t.TSLs),
+			Providers: len(t.Providers),
+		})
+	}
+	if err := writeStaticJSON(dirPath, path.Join(StaticAPIDir, "index.json"), index, opts); err != nil {
+		return err
+	}
+
+	pl.Logger.Info("Published static API",
+		logging.F("directory", apiDir),
+		logging.F("tsls", index.Count),
+		logging.F("territories", len(territories)),
+		logging.F("providers", len(pages)))
+	return nil
+}
+
+// writeStaticJSON writes v as indented JSON to the file at the slash-separated
+// path rel below dirPath.
+func writeStaticJSON(dirPath, rel string, v interface{}, opts *publishOptions) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", rel, err)
+	}
+	if err := opts.writeFile(filepath.Join(dirPath, filepath.FromSlash(rel)), append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+	return nil
+}
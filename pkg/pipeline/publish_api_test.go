@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readStaticJSON decodes the static API document at the slash-separated path
+// rel below dir.
+func readStaticJSON(t *testing.T, dir, rel string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	return doc
+}
+
+func TestPublishTSL_StaticAPI(t *testing.T) {
+	ca, err := testutil.NewCA("Static CA")
+	require.NoError(t, err)
+	tsl := generateTSL("QC Service", testTypeCAQC, []string{ca.Base64()})
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	ctx := NewContext()
+	ctx.TSLs.Push(tsl)
+
+	dir := t.TempDir()
+	pl := createTestPipeline(nil)
+	_, err = GenerateProviderPages(pl, ctx, dir)
+	require.NoError(t, err)
+
+	// A provider left over from an earlier run disappears
+	stale := filepath.Join(dir, StaticAPIDir, providerPagesDir, "se-gone.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(stale), 0755))
+	require.NoError(t, os.WriteFile(stale, []byte("{}"), 0644))
+
+	_, err = PublishTSL(pl, ctx, dir, "api", "bundle")
+	require.NoError(t, err)
+	assert.NoFileExists(t, stale)
+
+	index := readStaticJSON(t, dir, "api/index.json")
+	assert.EqualValues(t, 1, index["count"])
+	tsls := index["tsls"].([]interface{})
+	require.Len(t, tsls, 1)
+	summary := tsls[0].(map[string]interface{})
+	assert.Equal(t, "SE", summary["territory"])
+	assert.Equal(t, "tsl-0.xml", summary["file"])
+	assert.FileExists(t, filepath.Join(dir, "tsl-0.xml"))
+	territories := index["territories"].([]interface{})
+	require.Len(t, territories, 1)
+	assert.Equal(t, "api/se.json", territories[0].(map[string]interface{})["url"])
+
+	territory := readStaticJSON(t, dir, "api/se.json")
+	assert.Len(t, territory["tsls"], 1)
+	providers := territory["trust_service_providers"].([]interface{})
+	require.Len(t, providers, 1)
+	ref := providers[0].(map[string]interface{})
+	assert.Equal(t, ProviderID("SE", "Test Provider"), ref["id"])
+	assert.Equal(t, "api/providers/se-test-provider.json", ref["url"])
+	assert.Equal(t, ProviderPagePath("SE", "Test Provider"), ref["page"])
+
+	provider := readStaticJSON(t, dir, "api/providers/se-test-provider.json")
+	assert.Equal(t, "Test Provider", provider["name"])
+	services := provider["services"].([]interface{})
+	require.Len(t, services, 1)
+	certs := services[0].(map[string]interface{})["certificates"].([]interface{})
+	require.Len(t, certs, 1)
+	assert.Equal(t, Fingerprint(ca.Certificate), certs[0].(map[string]interface{})["sha256"])
+
+	// The bundle carries the static API
+	entries := readBundle(t, findBundle(t, dir))
+	assert.Contains(t, entries, "api/index.json")
+	assert.Contains(t, entries, "api/providers/se-test-provider.json")
+
+	// A dry run writes no API
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, StaticAPIDir)))
+	_, err = PublishTSL(pl, ctx, dir, "api", "dry-run")
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(dir, StaticAPIDir))
+}
+
+func TestTSLSummary_Sources(t *testing.T) {
+	operator := &etsi119612.InternationalNamesType{
+		Name: []*etsi119612.MultiLangNormStringType{{
+			XmlLangAttr: func() *etsi119612.Lang { l := etsi119612.Lang("en"); return &l }(),
+			NonEmptyNormalizedString: func() *etsi119612.NonEmptyNormalizedString {
+				s := etsi119612.NonEmptyNormalizedString("Operator")
+				return &s
+			}(),
+		}},
+	}
+	child := &etsi119612.TSL{
+		Source: "https://se.example/tsl.xml",
+		StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{TslSchemeTerritory: "SE", TSLSequenceNumber: 42, TslSchemeOperatorName: operator},
+		},
+	}
+	lotl := &etsi119612.TSL{
+		Source: "HTTPS://EU.example/lotl.xml",
+		StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
+				TslSchemeTerritory:    "EU",
+				TslSchemeOperatorName: operator,
+				SchemeOperatorAddress: &etsi119612.AddressType{
+					TslElectronicAddress: &etsi119612.ElectronicAddressType{
+						URI: []*etsi119612.NonEmptyMultiLangURIType{{Value: "mailto:tl@eu.example"}, {Value: " https://eu.example/contact "}},
+					},
+				},
+				TslDistributionPoints: &etsi119612.NonEmptyURIListType{URI: []string{"https://eu.example/lotl.xml"}},
+				TslPointersToOtherTSL: &etsi119612.OtherTSLPointersType{
+					TslOtherTSLPointer: []*etsi119612.OtherTSLPointerType{
+						{TSLLocation: "https://se.example/tsl.xml"},
+						{TSLLocation: "https://fi.example/tsl.xml"},
+						{TSLLocation: " "},
+					},
+				},
+			},
+		},
+		Referenced: []*etsi119612.TSL{child},
+	}
+
+	summary := TSLSummary(lotl, nil)
+	assert.Equal(t, "HTTPS://EU.example/lotl.xml", summary["source_url"])
+	assert.Equal(t, []string{"https://eu.example/lotl.xml"}, summary["distribution_points"])
+	assert.Equal(t, true, summary["loaded_from_distribution_point"], "scheme and host compare case-insensitively")
+	assert.Equal(t, []string{"mailto:tl@eu.example", "https://eu.example/contact"}, summary["scheme_operator_electronic_addresses"])
+	assert.Equal(t, []TSLPointerSummary{
+		{Location: "https://se.example/tsl.xml", Loaded: true, Territory: "SE", SequenceNumber: 42},
+		{Location: "https://fi.example/tsl.xml"},
+	}, summary["pointers"])
+
+	// Loaded from a mirror rather than the distribution point
+	lotl.Source = "https://mirror.example/lotl.xml"
+	assert.Equal(t, false, TSLSummary(lotl, nil)["loaded_from_distribution_point"])
+
+	// Nothing known
+	summary = TSLSummary(child, nil)
+	assert.Equal(t, "https://se.example/tsl.xml", summary["source_url"])
+	for _, key := range []string{"distribution_points", "loaded_from_distribution_point", "scheme_operator_electronic_addresses", "pointers"} {
+		assert.NotContains(t, summary, key)
+	}
+}
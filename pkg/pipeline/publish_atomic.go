@@ -0,0 +1,228 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// Atomic publish layout. The output directory is a symlink to the current
+// generation in a sibling directory named after it with GenerationsSuffix.
+const (
+	GenerationsSuffix         = ".generations"
+	DefaultPublishGenerations = 3 // Generations an atomic publish retains by default
+)
+
+// generationLayout names generation directories. It sorts in time order.
+const generationLayout = "20060102T150405.000000000Z"
+
+// generationsDir returns the directory holding the generations of an
+// atomically published output directory.
+func generationsDir(dirPath string) string {
+	return filepath.Clean(dirPath) + GenerationsSuffix
+}
+
+// publishAtomic publishes to a new generation directory and, once everything
+// has been written, atomically repoints the dirPath symlink to it, so that
+// consumers never read a partially written output. The new generation starts
+// as a copy of the current one, so files written to the output directory by
+// earlier steps are kept. On failure the new generation is removed and the
+// current one stays in place. Generations beyond opts.keep are removed,
+// oldest first.
+func publishAtomic(pl *Pipeline, ctx *Context, dirPath string, args []string, opts *publishOptions) error {
+	current, err := currentGeneration(dirPath)
+	if err != nil {
+		return err
+	}
+
+	genDir := generationsDir(dirPath)
+	if err := opts.mkdirAll(genDir); err != nil {
+		return fmt.Errorf("failed to create generations directory %s: %w", genDir, err)
+	}
+	name := time.Now().UTC().Format(generationLayout)
+	staging := filepath.Join(genDir, name)
+	if err := os.Mkdir(staging, opts.modeFor(true)); err != nil {
+		return fmt.Errorf("failed to create generation directory %s: %w", staging, err)
+	}
+	if err := opts.apply(staging, true); err != nil {
+		os.Remove(staging)
+		return err
+	}
+
+	if err := stageGeneration(pl, ctx, dirPath, current, staging, args, opts); err != nil {
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			pl.Logger.Warn("Failed to remove unpublished generation",
+				logging.F("directory", staging),
+				logging.F("error", rmErr))
+		}
+		return err
+	}
+
+	if err := swapGeneration(dirPath, name); err != nil {
+		return err
+	}
+	pl.Logger.Info("Published generation",
+		logging.F("directory", dirPath),
+		logging.F("generation", name),
+		logging.F("previous", current))
+
+	removed, err := pruneGenerations(dirPath, opts.keep)
+	if err != nil {
+		pl.Logger.Warn("Failed to remove old generations",
+			logging.F("directory", genDir),
+			logging.F("error", err))
+	}
+	for _, g := range removed {
+		pl.Logger.Debug("Removed old generation", logging.F("generation", g))
+	}
+	return nil
+}
+
+// stageGeneration fills a new generation directory: a copy of the current
+// generation, if any, with the TSLs published over it.
+func stageGeneration(pl *Pipeline, ctx *Context, dirPath, current, staging string, args []string, opts *publishOptions) error {
+	if current != "" {
+		if err := copyTree(filepath.Join(generationsDir(dirPath), current), staging); err != nil {
+			return fmt.Errorf("failed to copy current generation: %w", err)
+		}
+	}
+	return publishTo(pl, ctx, staging, args, opts)
+}
+
+// currentGeneration returns the name of the generation the dirPath symlink
+// points to, or "" if dirPath does not exist. A dirPath that is not a symlink
+// into its generations directory is an error, so that existing output is
+// never replaced by accident.
+func currentGeneration(dirPath string) (string, error) {
+	info, err := os.Lstat(dirPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error accessing output directory %s: %w", dirPath, err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "", fmt.Errorf("output directory %s is not a symlink; move it aside to publish atomically", dirPath)
+	}
+
+	target, err := os.Readlink(dirPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read output directory symlink %s: %w", dirPath, err)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(dirPath), target)
+	}
+	if filepath.Dir(filepath.Clean(target)) != generationsDir(dirPath) {
+		return "", fmt.Errorf("output directory %s points to %s, outside %s", dirPath, target, generationsDir(dirPath))
+	}
+	return filepath.Base(target), nil
+}
+
+// swapGeneration atomically repoints the dirPath symlink to a generation by
+// renaming a new symlink over it. The symlink target is relative, so the
+// output directory and its generations can be moved together.
+func swapGeneration(dirPath, name string) error {
+	target := filepath.Join(filepath.Base(generationsDir(dirPath)), name)
+	return swapSymlink(dirPath, target)
+}
+
+// PublishedGenerations returns the generations of an atomically published
+// output directory, oldest first. The last one is normally current; earlier
+// ones can be restored by repointing the symlink to them.
+func PublishedGenerations(dirPath string) ([]string, error) {
+	entries, err := os.ReadDir(generationsDir(dirPath))
+	if err != nil {
+		return nil, err
+	}
+	var generations []string
+	for _, e := range entries {
+		if _, err := time.Parse(generationLayout, e.Name()); e.IsDir() && err == nil {
+			generations = append(generations, e.Name())
+		}
+	}
+	sort.Strings(generations)
+	return generations, nil
+}
+
+// pruneGenerations removes the oldest generations of dirPath until keep
+// remain, never removing the current one. It returns the removed generations.
+func pruneGenerations(dirPath string, keep int) ([]string, error) {
+	generations, err := PublishedGenerations(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	current, err := currentGeneration(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, g := range generations {
+		if len(generations)-len(removed) <= keep {
+			break
+		}
+		if g == current {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(generationsDir(dirPath), g)); err != nil {
+			return removed, fmt.Errorf("failed to remove generation %s: %w", g, err)
+		}
+		removed = append(removed, g)
+	}
+	return removed, nil
+}
+
+// copyTree copies the regular files and directories below src to dst,
+// keeping their permissions.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies a regular file, keeping its permissions.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublishOptions_Atomic(t *testing.T) {
+	_, opts, err := parsePublishOptions([]string{"/tmp/out", "atomic"})
+	require.NoError(t, err)
+	assert.True(t, opts.atomic)
+	assert.Equal(t, DefaultPublishGenerations, opts.keep)
+
+	_, opts, err = parsePublishOptions([]string{"/tmp/out", "atomic:5"})
+	require.NoError(t, err)
+	assert.True(t, opts.atomic)
+	assert.Equal(t, 5, opts.keep)
+
+	for _, bad := range []string{"atomic:0", "atomic:x"} {
+		_, _, err = parsePublishOptions([]string{"/tmp/out", bad})
+		assert.ErrorContains(t, err, "invalid publish option "+bad)
+	}
+}
+
+func TestPublishTSL_Atomic(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := filepath.Join(t.TempDir(), "output")
+
+	// The first run creates the symlink
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, "atomic:2", "sidecars")
+	require.NoError(t, err)
+	info, err := os.Lstat(dir)
+	require.NoError(t, err)
+	require.NotZero(t, info.Mode()&os.ModeSymlink, "the output directory is a symlink")
+	target, err := os.Readlink(dir)
+	require.NoError(t, err)
+	assert.False(t, filepath.IsAbs(target), "the symlink is relative")
+	first, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "se.xml"+MetaSidecarExt))
+
+	// Files written by earlier steps are carried over to the next generation
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "html"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "html", "index.html"), []byte("<html/>"), 0644))
+
+	_, err = PublishTSL(pl, dryRunContext(2, statusWithdrawn), dir, "atomic:2")
+	require.NoError(t, err)
+	generations, err := PublishedGenerations(dir)
+	require.NoError(t, err)
+	require.Len(t, generations, 2)
+	current, err := currentGeneration(dir)
+	require.NoError(t, err)
+	assert.Equal(t, generations[1], current)
+
+	second, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	assert.FileExists(t, filepath.Join(dir, "html", "index.html"))
+
+	// The previous generation is kept unchanged for rollback
+	previous, err := os.ReadFile(filepath.Join(generationsDir(dir), generations[0], "se.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, first, previous)
+
+	// Older generations are pruned
+	_, err = PublishTSL(pl, dryRunContext(3, statusGranted), dir, "atomic:2")
+	require.NoError(t, err)
+	pruned, err := PublishedGenerations(dir)
+	require.NoError(t, err)
+	require.Len(t, pruned, 2)
+	assert.Equal(t, generations[1], pruned[0])
+	_, err = os.Lstat(dir + ".swap")
+	assert.True(t, os.IsNotExist(err), "no swap symlink is left behind")
+}
+
+func TestPublishTSL_AtomicFailureKeepsCurrent(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := filepath.Join(t.TempDir(), "output")
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, "atomic")
+	require.NoError(t, err)
+	before, err := currentGeneration(dir)
+	require.NoError(t, err)
+
+	// Nothing to publish fails after the generation directory was created
+	_, err = PublishTSL(pl, &Context{}, dir, "atomic")
+	require.ErrorContains(t, err, "no TSLs to publish")
+
+	after, err := currentGeneration(dir)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	generations, err := PublishedGenerations(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{before}, generations, "the failed generation is removed")
+}
+
+func TestPublishTSL_AtomicRequiresSymlink(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, "atomic")
+	assert.ErrorContains(t, err, "is not a symlink")
+
+	// A dry run compares through the symlink and writes nothing
+	out := filepath.Join(t.TempDir(), "output")
+	_, err = PublishTSL(pl, dryRunContext(1, statusGranted), out, "atomic")
+	require.NoError(t, err)
+	_, err = PublishTSL(pl, dryRunContext(2, statusGranted), out, "atomic", "dry-run")
+	require.NoError(t, err)
+	generations, err := PublishedGenerations(out)
+	require.NoError(t, err)
+	assert.Len(t, generations, 1)
+}
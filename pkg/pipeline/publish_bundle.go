@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// Bundle archive naming. Bundles are named tsl-bundle-YYYYMMDD.zip after the
+// UTC date on which they were written.
+const (
+	BundlePrefix       = "tsl-bundle-"
+	BundleExt          = ".zip"
+	BundleManifestName = "manifest.xml"
+)
+
+// BundleManifest is the manifest.xml stored at the root of a bundle archive.
+// It lists every other file in the archive with its SHA-256 digest so that an
+// offline relying party can verify the content after transfer. When the publish
+// step has a signer configured the manifest carries an XML-DSIG signature.
+type BundleManifest struct {
+	XMLName xml.Name             `xml:"BundleManifest"`
+	Created string               `xml:"Created,attr"` // RFC 3339 UTC time the bundle was written
+	Files   []BundleManifestFile `xml:"File"`
+}
+
+// BundleManifestFile is a single archive entry listed in a BundleManifest.
+type BundleManifestFile struct {
+	Path   string `xml:"Path,attr"`   // Slash-separated path inside the archive
+	Size   int64  `xml:"Size,attr"`   // Size in bytes
+	SHA256 string `xml:"SHA256,attr"` // Hex-encoded SHA-256 digest
+}
+
+// BundleName returns the archive file name for a bundle written at t.
+func BundleName(t time.Time) string {
+	return BundlePrefix + t.UTC().Format("20060102") + BundleExt
+}
+
+// isBundleFile reports whether name is a bundle archive written by writeBundle.
+func isBundleFile(name string) bool {
+	return strings.HasPrefix(name, BundlePrefix) && strings.HasSuffix(name, BundleExt)
+}
+
+// writeBundle archives every regular file below dirPath (TSL XML, sidecars,
+// HTML and index pages written there by earlier steps) into a single zip file
+// in dirPath, together with a manifest of SHA-256 digests. The manifest is
+// signed with the signer of opts if one is configured, and the archive gets
+// the file mode and group of opts. Earlier bundles in dirPath are not
+// included. It returns the path of the written archive.
+func writeBundle(pl *Pipeline, dirPath string, opts *publishOptions, now time.Time) (string, error) {
+	var signer dsig.XMLSigner
+	if opts != nil {
+		signer = opts.signer
+	}
+
+	var paths []string
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if filepath.Dir(path) == filepath.Clean(dirPath) && isBundleFile(d.Name()) {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list files in %s: %w", dirPath, err)
+	}
+	sort.Strings(paths)
+
+	bundlePath := filepath.Join(dirPath, BundleName(now))
+	tmpPath := bundlePath + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, opts.modeFor(false))
+	if err != nil {
+		return "", fmt.Errorf("failed to create bundle %s: %w", bundlePath, err)
+	}
+	defer os.Remove(tmpPath)
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	manifest := BundleManifest{Created: now.UTC().Format(time.RFC3339)}
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return "", err
+		}
+		name := filepath.ToSlash(rel)
+		if name == BundleManifestName {
+			return "", fmt.Errorf("%s in %s conflicts with the bundle manifest", BundleManifestName, dirPath)
+		}
+
+		entry, err := addBundleFile(zw, path, name)
+		if err != nil {
+			return "", err
+		}
+		manifest.Files = append(manifest.Files, entry)
+	}
+
+	manifestData, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+	manifestData = append([]byte(xml.Header), manifestData...)
+	if signer != nil {
+		manifestData, err = signer.Sign(manifestData)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign bundle manifest: %w", err)
+		}
+		if _, err := dsig.VerifyXML(manifestData); err != nil {
+			return "", fmt.Errorf("signed bundle manifest failed verification: %w", err)
+		}
+	}
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: BundleManifestName, Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return "", fmt.Errorf("failed to add bundle manifest: %w", err)
+	}
+	if _, err := w.Write(manifestData); err != nil {
+		return "", fmt.Errorf("failed to add bundle manifest: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", bundlePath, err)
+	}
+	if err := out.Close(); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", bundlePath, err)
+	}
+	if err := opts.apply(tmpPath, false); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, bundlePath); err != nil {
+		return "", fmt.Errorf("failed to write bundle %s: %w", bundlePath, err)
+	}
+
+	pl.Logger.Info("Published TSL bundle",
+		logging.F("file", bundlePath),
+		logging.F("files", len(manifest.Files)),
+		logging.F("signed", signer != nil))
+
+	return bundlePath, nil
+}
+
+// addBundleFile copies the file at path into zw under name and returns its
+// manifest entry.
+func addBundleFile(zw *zip.Writer, path, name string) (BundleManifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BundleManifestFile{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return BundleManifestFile{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return BundleManifestFile{}, err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return BundleManifestFile{}, fmt.Errorf("failed to add %s to bundle: %w", name, err)
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, h), f)
+	if err != nil {
+		return BundleManifestFile{}, fmt.Errorf("failed to add %s to bundle: %w", name, err)
+	}
+
+	return BundleManifestFile{Path: name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/beevik/etree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readBundle returns the content of every entry in the zip archive at path.
+func readBundle(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+
+	zr, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	entries := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		entries[f.Name] = data
+	}
+	return entries
+}
+
+// findBundle returns the path of the single bundle archive in dir.
+func findBundle(t *testing.T, dir string) string {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, BundlePrefix+"*"+BundleExt))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	return matches[0]
+}
+
+func TestBundleName(t *testing.T) {
+	ts := time.Date(2025, 3, 7, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, "tsl-bundle-20250307.zip", BundleName(ts))
+	assert.True(t, isBundleFile("tsl-bundle-20250307.zip"))
+	assert.False(t, isBundleFile("tsl-0.xml"))
+}
+
+func TestPublishTSL_Bundle(t *testing.T) {
+	dir := t.TempDir()
+
+	// HTML output from an earlier transform/generate_index step
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "html"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "html", "index.html"), []byte("<html></html>"), 0644))
+
+	ctx := &Context{}
+	ctx.EnsureTSLStack().TSLs.Push(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	_, err := PublishTSL(pl, ctx, dir, "sidecars", "bundle")
+	require.NoError(t, err)
+
+	// Publishing again on the same day replaces the bundle and does not nest it
+	_, err = PublishTSL(pl, ctx, dir, "sidecars", "bundle")
+	require.NoError(t, err)
+
+	entries := readBundle(t, findBundle(t, dir))
+	assert.Len(t, entries, 5)
+	for _, name := range []string{"tsl-0.xml", "tsl-0.xml.sha256", "tsl-0.xml.meta", "html/index.html", BundleManifestName} {
+		assert.Contains(t, entries, name)
+	}
+
+	var manifest BundleManifest
+	require.NoError(t, xml.Unmarshal(entries[BundleManifestName], &manifest))
+	assert.NotEmpty(t, manifest.Created)
+	require.Len(t, manifest.Files, 4)
+	for _, f := range manifest.Files {
+		data, ok := entries[f.Path]
+		require.True(t, ok, f.Path)
+		sum := sha256.Sum256(data)
+		assert.Equal(t, hex.EncodeToString(sum[:]), f.SHA256, f.Path)
+		assert.Equal(t, int64(len(data)), f.Size, f.Path)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, matches, "temporary bundle file should be removed")
+}
+
+func TestPublishTSL_SignedBundle(t *testing.T) {
+	dir := t.TempDir()
+
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	keyFile := filepath.Join(certDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	ctx := &Context{}
+	ctx.EnsureTSLStack().TSLs.Push(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	_, err := PublishTSL(pl, ctx, dir, certFile, keyFile, "bundle")
+	require.NoError(t, err)
+
+	entries := readBundle(t, findBundle(t, dir))
+	assert.Contains(t, entries, "tsl-0.xml")
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromBytes(entries[BundleManifestName]))
+	assert.NotNil(t, doc.FindElement("//Signature"), "manifest should carry an XML-DSIG signature")
+	assert.Len(t, doc.FindElements("//File"), 1)
+}
+
+func TestWriteBundle_ManifestConflict(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, BundleManifestName), []byte("<x/>"), 0644))
+
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	_, err := writeBundle(pl, dir, nil, time.Now())
+	assert.ErrorContains(t, err, "conflicts with the bundle manifest")
+
+	matches, err := filepath.Glob(filepath.Join(dir, BundlePrefix+"*"))
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
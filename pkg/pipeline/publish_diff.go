@@ -0,0 +1,301 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Change statuses reported by a dry-run publish.
+const (
+	PublishAdded     = "added"     // No file is published at the path yet
+	PublishModified  = "modified"  // The published file has different content
+	PublishUnchanged = "unchanged" // The published file has the same content
+)
+
+// PublishChange compares the file a publish step would write with the file
+// currently published at the same path.
+type PublishChange struct {
+	File    string   `json:"file"`              // Path of the published file
+	Status  string   `json:"status"`            // PublishAdded, PublishModified or PublishUnchanged
+	OldSize int      `json:"old_size"`          // Size of the published file in bytes, 0 if added
+	NewSize int      `json:"new_size"`          // Size of the would-be file in bytes
+	Details []string `json:"details,omitempty"` // Semantic differences between the TSLs
+}
+
+// PublishDiffError is returned by a dry-run publish when at least one file
+// would be added or modified. It lets callers such as CI jobs tell pending
+// changes apart from failures.
+type PublishDiffError struct {
+	Changes []PublishChange // Every compared file, including unchanged ones
+}
+
+// Error implements the error interface.
+func (e *PublishDiffError) Error() string {
+	var changed []string
+	for _, c := range e.Changes {
+		if c.Status != PublishUnchanged {
+			changed = append(changed, fmt.Sprintf("%s (%s)", c.File, c.Status))
+		}
+	}
+	return fmt.Sprintf("dry run: %d of %d published files would change: %s",
+		len(changed), len(e.Changes), strings.Join(changed, ", "))
+}
+
+// IsPublishDiff reports whether err is, or wraps, a *PublishDiffError.
+func IsPublishDiff(err error) bool {
+	var diffErr *PublishDiffError
+	return errors.As(err, &diffErr)
+}
+
+// volatileElements are the elements of a published TSL that change on every
+// publication without changing its content.
+var volatileElements = map[string]bool{
+	"TSLSequenceNumber": true,
+	"ListIssueDateTime": true,
+	"NextUpdate":        true,
+}
+
+// canonicalPublished returns a canonical form of a published TSL in which two
+// publications of the same content are equal: namespace prefixes and
+// declarations, attribute order, formatting and the XML declaration are
+// dropped, and so are the XML-DSIG signature and the values of the sequence
+// number and the issue and next update dates.
+func canonicalPublished(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	signature, volatile := 0, 0
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TSL: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if signature > 0 || t.Name.Local == "Signature" {
+				signature++
+				continue
+			}
+			if volatileElements[t.Name.Local] {
+				volatile++
+			}
+			var attrs []string
+			for _, a := range t.Attr {
+				if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" {
+					attrs = append(attrs, fmt.Sprintf(" %s=%q", a.Name.Local, a.Value))
+				}
+			}
+			sort.Strings(attrs)
+			buf.WriteString("<" + t.Name.Local + strings.Join(attrs, "") + ">")
+		case xml.EndElement:
+			if signature > 0 {
+				signature--
+				continue
+			}
+			if volatileElements[t.Name.Local] {
+				volatile--
+			}
+			buf.WriteString("</" + t.Name.Local + ">")
+		case xml.CharData:
+			if signature > 0 || volatile > 0 {
+				continue
+			}
+			if text := bytes.TrimSpace(t); len(text) > 0 {
+				_ = xml.EscapeText(&buf, text)
+			}
+		}
+	}
+}
+
+// comparePublished compares the would-be content of a published file with the
+// file currently at filePath.
+func comparePublished(filePath string, data []byte) (PublishChange, error) {
+	change := PublishChange{File: filePath, NewSize: len(data)}
+
+	old, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		change.Status = PublishAdded
+		return change, nil
+	}
+	if err != nil {
+		return change, fmt.Errorf("failed to read published file %s: %w", filePath, err)
+	}
+	change.OldSize = len(old)
+
+	oldCanonical, err := canonicalPublished(old)
+	if err != nil {
+		// Not a TSL, so it cannot be the same content
+		change.Status = PublishModified
+		change.Details = []string{"published file is not valid XML"}
+		return change, nil
+	}
+	newCanonical, err := canonicalPublished(data)
+	if err != nil {
+		return change, err
+	}
+	if bytes.Equal(oldCanonical, newCanonical) {
+		change.Status = PublishUnchanged
+		return change, nil
+	}
+	change.Status = PublishModified
+
+	oldOutline, oldErr := outlinePublished(old)
+	newOutline, newErr := outlinePublished(data)
+	if oldErr != nil || newErr != nil {
+		change.Details = []string{"content differs"}
+		return change, nil
+	}
+	change.Details = oldOutline.diff(newOutline)
+	return change, nil
+}
+
+// tslOutline is the part of a published TSL that a dry-run diff reports on.
+type tslOutline struct {
+	SequenceNumber string
+	IssueDate      string
+	NextUpdate     string
+	Territory      string
+	Services       map[string]serviceOutline // By "provider / service (type)"
+}
+
+type serviceOutline struct {
+	Status       string
+	Certificates []string
+}
+
+// outlinePublished reads the outline of a published TSL. It matches elements
+// by local name, so it reads signed and namespaced documents as well as the
+// output of the publish step.
+func outlinePublished(data []byte) (*tslOutline, error) {
+	outline := &tslOutline{Services: make(map[string]serviceOutline)}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var stack []string
+	var provider, service, serviceType string
+	var current *serviceOutline
+	parent := func(n int) string {
+		if len(stack) > n {
+			return stack[len(stack)-1-n]
+		}
+		return ""
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return outline, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TSL: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			switch t.Name.Local {
+			case "TrustServiceProvider":
+				provider = ""
+			case "TSPService":
+				service, serviceType = "", ""
+				current = &serviceOutline{}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "TSPService" && current != nil {
+				sort.Strings(current.Certificates)
+				outline.Services[fmt.Sprintf("%s / %s (%s)", provider, service, serviceType)] = *current
+				current = nil
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			value := strings.TrimSpace(string(t))
+			if value == "" {
+				continue
+			}
+			switch {
+			case parent(0) == "TSLSequenceNumber":
+				outline.SequenceNumber = value
+			case parent(0) == "ListIssueDateTime":
+				outline.IssueDate = value
+			case parent(1) == "NextUpdate":
+				outline.NextUpdate = value
+			case parent(0) == "SchemeTerritory":
+				outline.Territory = value
+			case parent(0) == "Name" && parent(1) == "TSPName" && provider == "":
+				provider = value
+			case current == nil:
+			case parent(0) == "Name" && parent(1) == "ServiceName" && parent(2) == "ServiceInformation" && service == "":
+				service = value
+			case parent(0) == "ServiceTypeIdentifier" && parent(1) == "ServiceInformation":
+				serviceType = value
+			case parent(0) == "ServiceStatus" && parent(1) == "ServiceInformation":
+				current.Status = value
+			case parent(0) == "X509Certificate" && parent(3) == "ServiceInformation":
+				current.Certificates = append(current.Certificates, value)
+			}
+		}
+	}
+}
+
+// diff describes the differences from o to other. Since the outline does not
+// cover every element, the details end with "content differs" if the
+// territory and services are the same.
+func (o *tslOutline) diff(other *tslOutline) []string {
+	var details []string
+	field := func(name, from, to string) {
+		if from != to {
+			details = append(details, fmt.Sprintf("%s: %q -> %q", name, from, to))
+		}
+	}
+	field("sequence number", o.SequenceNumber, other.SequenceNumber)
+	field("issue date", o.IssueDate, other.IssueDate)
+	field("next update", o.NextUpdate, other.NextUpdate)
+	volatile := len(details)
+	field("territory", o.Territory, other.Territory)
+
+	keys := make(map[string]bool)
+	for k := range o.Services {
+		keys[k] = true
+	}
+	for k := range other.Services {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		from, hadService := o.Services[key]
+		to, hasService := other.Services[key]
+		switch {
+		case !hadService:
+			details = append(details, "service added: "+key)
+		case !hasService:
+			details = append(details, "service removed: "+key)
+		default:
+			if from.Status != to.Status {
+				details = append(details, fmt.Sprintf("service status changed: %s: %s -> %s", key, from.Status, to.Status))
+			}
+			if strings.Join(from.Certificates, ",") != strings.Join(to.Certificates, ",") {
+				details = append(details, "service certificates changed: "+key)
+			}
+		}
+	}
+	if len(details) == volatile {
+		details = append(details, "content differs")
+	}
+	return details
+}
@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dryRunContext returns a context holding a single test TSL with the given
+// sequence number and service status.
+func dryRunContext(sequenceNumber int, status string) *Context {
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	si := tsl.StatusList.TslSchemeInformation
+	si.TslSchemeTerritory = "SE"
+	si.TSLSequenceNumber = sequenceNumber
+	si.ListIssueDateTime = fmt.Sprintf("2025-01-%02dT00:00:00Z", sequenceNumber)
+	si.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{"https://tsl.example.com/se.xml"}}
+	tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].
+		TslTSPServices.TslTSPService[0].TslServiceInformation.TslServiceStatus = status
+
+	ctx := &Context{}
+	ctx.EnsureTSLStack().TSLs.Push(tsl)
+	return ctx
+}
+
+const (
+	statusGranted   = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted"
+	statusWithdrawn = "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn"
+)
+
+func TestParsePublishOptions_DryRun(t *testing.T) {
+	rest, opts, err := parsePublishOptions([]string{"/tmp/out", "dry-run"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/out"}, rest)
+	assert.True(t, opts.dryRun)
+	assert.False(t, opts.writes())
+
+	var none *publishOptions
+	assert.True(t, none.writes())
+}
+
+func TestPublishTSL_DryRunMissingDirectory(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := filepath.Join(t.TempDir(), "output")
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, "dry-run", "sidecars", "bundle")
+	require.Error(t, err)
+	assert.True(t, IsPublishDiff(err))
+
+	var diffErr *PublishDiffError
+	require.ErrorAs(t, err, &diffErr)
+	require.Len(t, diffErr.Changes, 1)
+	assert.Equal(t, PublishAdded, diffErr.Changes[0].Status)
+	assert.Equal(t, filepath.Join(dir, "se.xml"), diffErr.Changes[0].File)
+	assert.Contains(t, err.Error(), "1 of 1 published files would change")
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err), "a dry run should not create the output directory")
+}
+
+func TestPublishTSL_DryRun(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir)
+	require.NoError(t, err)
+	published, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+
+	// A new sequence number and issue date alone is not a change
+	_, err = PublishTSL(pl, dryRunContext(2, statusGranted), dir, "dry-run")
+	require.NoError(t, err)
+
+	// A status change is, and is described
+	_, err = PublishTSL(pl, dryRunContext(2, statusWithdrawn), dir, "dry-run")
+	var diffErr *PublishDiffError
+	require.ErrorAs(t, err, &diffErr)
+	require.Len(t, diffErr.Changes, 1)
+	change := diffErr.Changes[0]
+	assert.Equal(t, PublishModified, change.Status)
+	assert.Equal(t, len(published), change.OldSize)
+	assert.Contains(t, change.Details, `sequence number: "1" -> "2"`)
+	require.Len(t, change.Details, 3)
+	assert.Contains(t, change.Details[2], "service status changed: ")
+	assert.Contains(t, change.Details[2], statusGranted+" -> "+statusWithdrawn)
+
+	// Nothing was written
+	after, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, published, after)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestPublishTSL_DryRunIgnoresSignature(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	keyFile := filepath.Join(certDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, keyFile)
+	require.NoError(t, err)
+	signed, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+	require.Contains(t, string(signed), "SignatureValue")
+
+	_, err = PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, keyFile, "dry-run")
+	assert.NoError(t, err)
+}
+
+func TestOutlinePublished_Diff(t *testing.T) {
+	old := []byte(`<TrustServiceStatusList xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+  <SchemeInformation>
+    <TSLSequenceNumber>1</TSLSequenceNumber>
+    <SchemeTerritory>SE</SchemeTerritory>
+    <NextUpdate><dateTime>2025-04-01T00:00:00Z</dateTime></NextUpdate>
+  </SchemeInformation>
+  <TrustServiceProviderList>
+    <TrustServiceProvider>
+      <TSPInformation><TSPName><Name xml:lang="en">Provider</Name></TSPName></TSPInformation>
+      <TSPServices>
+        <TSPService><ServiceInformation>
+          <ServiceTypeIdentifier>CA/QC</ServiceTypeIdentifier>
+          <ServiceName><Name xml:lang="en">Kept</Name></ServiceName>
+          <ServiceDigitalIdentity><DigitalId><X509Certificate>AAAA</X509Certificate></DigitalId></ServiceDigitalIdentity>
+          <ServiceStatus>granted</ServiceStatus>
+        </ServiceInformation></TSPService>
+        <TSPService><ServiceInformation>
+          <ServiceTypeIdentifier>CA/QC</ServiceTypeIdentifier>
+          <ServiceName><Name xml:lang="en">Removed</Name></ServiceName>
+          <ServiceStatus>granted</ServiceStatus>
+        </ServiceInformation></TSPService>
+      </TSPServices>
+    </TrustServiceProvider>
+  </TrustServiceProviderList>
+  <ds:Signature><ds:SignatureValue>abc</ds:SignatureValue></ds:Signature>
+</TrustServiceStatusList>`)
+
+	outline, err := outlinePublished(old)
+	require.NoError(t, err)
+	assert.Equal(t, "1", outline.SequenceNumber)
+	assert.Equal(t, "SE", outline.Territory)
+	assert.Equal(t, "2025-04-01T00:00:00Z", outline.NextUpdate)
+	require.Len(t, outline.Services, 2)
+	assert.Equal(t, serviceOutline{Status: "granted", Certificates: []string{"AAAA"}}, outline.Services["Provider / Kept (CA/QC)"])
+
+	other := &tslOutline{
+		SequenceNumber: "1",
+		Territory:      "SE",
+		NextUpdate:     "2025-04-01T00:00:00Z",
+		Services: map[string]serviceOutline{
+			"Provider / Kept (CA/QC)":  {Status: "granted", Certificates: []string{"BBBB"}},
+			"Provider / Added (CA/QC)": {Status: "granted"},
+		},
+	}
+	assert.Equal(t, []string{
+		"service added: Provider / Added (CA/QC)",
+		"service certificates changed: Provider / Kept (CA/QC)",
+		"service removed: Provider / Removed (CA/QC)",
+	}, outline.diff(other))
+
+	assert.Equal(t, []string{"content differs"}, outline.diff(outline))
+}
+
+func TestCanonicalPublished(t *testing.T) {
+	a, err := canonicalPublished([]byte(`<?xml version="1.0"?>
+<tsl:List xmlns:tsl="urn:x" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" b="2" a="1">
+  <tsl:TSLSequenceNumber>1</tsl:TSLSequenceNumber>
+  <tsl:Type></tsl:Type>
+  <tsl:NextUpdate><tsl:dateTime>2025-04-01T00:00:00Z</tsl:dateTime></tsl:NextUpdate>
+  <tsl:Name> Operator </tsl:Name>
+  <ds:Signature><ds:SignatureValue>abc</ds:SignatureValue></ds:Signature>
+</tsl:List>`))
+	require.NoError(t, err)
+	b, err := canonicalPublished([]byte(`<List a="1" b="2"><TSLSequenceNumber>2</TSLSequenceNumber><Type/>` +
+		`<NextUpdate><dateTime>2025-05-01T00:00:00Z</dateTime></NextUpdate><Name>Operator</Name></List>`))
+	require.NoError(t, err)
+	assert.Equal(t, string(a), string(b))
+	assert.NotContains(t, string(a), "Signature")
+
+	c, err := canonicalPublished([]byte(`<List a="1" b="2"><Name>Other</Name></List>`))
+	require.NoError(t, err)
+	assert.NotEqual(t, string(a), string(c))
+
+	_, err = canonicalPublished([]byte("<List>"))
+	assert.Error(t, err)
+}
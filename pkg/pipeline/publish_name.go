@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// PublishNameData is the data a publish "name-template:" option is executed
+// with to name the file of each published TSL.
+type PublishNameData struct {
+	Territory string // Scheme territory, e.g. "SE"
+	Sequence  int    // TSL sequence number
+	Index     int    // Position of the TSL among the published TSLs, or its depth in tree output
+	Name      string // File name the step would use without a template
+}
+
+// parseNameTemplate parses the value of a "name-template:" publish option.
+func parseNameTemplate(text string) (*template.Template, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("invalid publish option name-template: the template is empty")
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publish option name-template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// publishFileName returns the name of the file tsl is published to. Without
+// a name template that is the sanitized last segment of its first
+// distribution point, or fallback; with one it is the template executed with
+// PublishNameData, which must be a safe file name as is.
+func publishFileName(tsl *etsi119612.TSL, index int, fallback string, opts *publishOptions) (string, error) {
+	name := distributionPointName(tsl)
+	if name == "" {
+		name = sanitizeFileName(fallback)
+	}
+	if opts == nil || opts.nameTemplate == nil {
+		return name, nil
+	}
+
+	data := PublishNameData{Index: index, Name: name}
+	if si := tsl.StatusList.TslSchemeInformation; si != nil {
+		data.Territory = si.TslSchemeTerritory
+		data.Sequence = si.TSLSequenceNumber
+	}
+	var buf bytes.Buffer
+	if err := opts.nameTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute name template: %w", err)
+	}
+	name = strings.TrimSpace(buf.String())
+	if name == "" || sanitizeFileName(name) != name {
+		return "", fmt.Errorf("name template produced invalid file name %q", name)
+	}
+	return name, nil
+}
+
+// claimPublishPath returns the path to publish a TSL to, given the path its
+// file name was derived for. If an earlier TSL of the same publish step was
+// already published to that path, a numbered path is returned instead, so
+// that neither TSL is lost.
+func claimPublishPath(pl *Pipeline, opts *publishOptions, path string) string {
+	if opts == nil {
+		return path
+	}
+	if opts.claims == nil {
+		opts.claims = make(fileNameClaims)
+	}
+	claimed := opts.claims.claim(path)
+	if claimed != path {
+		pl.Logger.Warn("Published file name already used, numbering it",
+			logging.F("file", path),
+			logging.F("published_as", claimed))
+	}
+	return claimed
+}
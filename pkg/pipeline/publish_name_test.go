@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublishOptions_NameTemplate(t *testing.T) {
+	rest, opts, err := parsePublishOptions([]string{"/tmp/out", "name-template: {{.Territory}}-{{.Sequence}}.xml", "sidecars"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/out"}, rest)
+	require.NotNil(t, opts.nameTemplate)
+
+	for _, bad := range []string{"name-template:", "name-template: {{.Territory", "name-template:{{.Unknown}}.xml"} {
+		_, opts, err := parsePublishOptions([]string{"/tmp/out", bad})
+		if err == nil {
+			// Unknown fields are only detected on execution
+			_, err = publishFileName(generateTSL("Service", "", nil), 0, "tsl-0.xml", opts)
+		}
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPublishFileName(t *testing.T) {
+	tsl := dryRunContext(7, statusGranted).TSLs.ToSlice()[0]
+
+	name, err := publishFileName(tsl, 0, "tsl-0.xml", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "se.xml", name, "last segment of the distribution point")
+
+	noPoint := generateTSL("Service", "", nil)
+	noPoint.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: []string{"https://tsl.example.com/"}}
+	name, err = publishFileName(noPoint, 2, "tsl-2.xml", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "tsl-2.xml", name)
+
+	tmpl := func(text string) *publishOptions {
+		t.Helper()
+		parsed, err := parseNameTemplate(text)
+		require.NoError(t, err)
+		return &publishOptions{nameTemplate: parsed}
+	}
+	name, err = publishFileName(tsl, 3, "", tmpl("{{.Territory}}-{{.Sequence}}.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, "SE-7.xml", name)
+	name, err = publishFileName(tsl, 3, "", tmpl("{{.Index}}-{{.Name}}"))
+	require.NoError(t, err)
+	assert.Equal(t, "3-se.xml", name)
+
+	for _, bad := range []string{"../{{.Name}}", "a/b.xml", "{{if false}}x{{end}}", ".."} {
+		_, err := publishFileName(tsl, 0, "", tmpl(bad))
+		assert.ErrorContains(t, err, "invalid file name", bad)
+	}
+}
+
+func TestPublishTSL_NameTemplate(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+
+	_, err := PublishTSL(pl, dryRunContext(4, statusGranted), dir, "name-template:{{.Territory}}-{{.Sequence}}.xml", "sidecars")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "SE-4.xml"))
+	assert.FileExists(t, filepath.Join(dir, "SE-4.xml"+MetaSidecarExt))
+	_, err = os.Stat(filepath.Join(dir, "se.xml"))
+	assert.True(t, os.IsNotExist(err))
+}
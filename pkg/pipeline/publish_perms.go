@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// Modes of the files and directories written by a publish step without the
+// "file-mode:" and "dir-mode:" options, before the umask is applied.
+const (
+	DefaultPublishFileMode os.FileMode = 0644
+	DefaultPublishDirMode  os.FileMode = 0755
+)
+
+// parseMode parses the octal permission bits of a "file-mode:" or
+// "dir-mode:" publish option, e.g. "0640".
+func parseMode(option, value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode == 0 || mode > 0777 {
+		return 0, fmt.Errorf("invalid publish option %s: %q is not an octal permission mode such as 0644", option, value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// lookupGroup resolves the group name or numeric id of a "group:" publish
+// option to a group id.
+func lookupGroup(name string) (int, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		g, err = user.LookupGroupId(name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid publish option group:%s: unknown group", name)
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return 0, fmt.Errorf("invalid publish option group:%s: group id %q is not numeric", name, g.Gid)
+	}
+	return gid, nil
+}
+
+// writeFile writes a published file with the configured file mode and group.
+func (o *publishOptions) writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, o.modeFor(false)); err != nil {
+		return err
+	}
+	return o.apply(path, false)
+}
+
+// mkdirAll creates a published directory, and any missing parents, with the
+// configured directory mode. The mode and group are applied to path itself
+// even if it already exists.
+func (o *publishOptions) mkdirAll(path string) error {
+	if err := os.MkdirAll(path, o.modeFor(true)); err != nil {
+		return err
+	}
+	return o.apply(path, true)
+}
+
+// modeFor returns the mode to create a published file or directory with.
+func (o *publishOptions) modeFor(dir bool) os.FileMode {
+	switch {
+	case dir && o != nil && o.dirMode != 0:
+		return o.dirMode
+	case dir:
+		return DefaultPublishDirMode
+	case o != nil && o.fileMode != 0:
+		return o.fileMode
+	default:
+		return DefaultPublishFileMode
+	}
+}
+
+// apply sets the configured mode and group on a published file or directory.
+// A configured mode is set exactly, regardless of the umask; without one the
+// mode the file was created with is kept.
+func (o *publishOptions) apply(path string, dir bool) error {
+	if o == nil {
+		return nil
+	}
+	if (dir && o.dirMode != 0) || (!dir && o.fileMode != 0) {
+		if err := os.Chmod(path, o.modeFor(dir)); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", path, err)
+		}
+	}
+	if o.group != "" {
+		if err := os.Chown(path, -1, o.gid); err != nil {
+			return fmt.Errorf("failed to set group of %s to %s: %w", path, o.group, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+//go:build unix
+
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublishOptions_Modes(t *testing.T) {
+	gid := strconv.Itoa(os.Getgid())
+	rest, opts, err := parsePublishOptions([]string{"/tmp/out", "file-mode:0640", "dir-mode:750", "group:" + gid})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/out"}, rest)
+	assert.Equal(t, os.FileMode(0640), opts.fileMode)
+	assert.Equal(t, os.FileMode(0750), opts.dirMode)
+	assert.Equal(t, os.Getgid(), opts.gid)
+
+	for _, bad := range []string{"file-mode:0", "file-mode:0888", "dir-mode:1777", "dir-mode:rwx"} {
+		_, _, err := parsePublishOptions([]string{"/tmp/out", bad})
+		assert.ErrorContains(t, err, "invalid publish option", bad)
+	}
+	_, _, err = parsePublishOptions([]string{"/tmp/out", "group:no-such-group-go-trust"})
+	assert.ErrorContains(t, err, "unknown group")
+
+	var none *publishOptions
+	assert.Equal(t, DefaultPublishFileMode, none.modeFor(false))
+	assert.Equal(t, DefaultPublishDirMode, none.modeFor(true))
+}
+
+func TestPublishTSL_Modes(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := filepath.Join(t.TempDir(), "output")
+	gid := os.Getgid()
+
+	// The modes are set exactly, regardless of the umask
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir,
+		"file-mode:0664", "dir-mode:0775", "group:"+strconv.Itoa(gid), "sidecars", "bundle")
+	require.NoError(t, err)
+
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0775), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 4, "TSL, two sidecars and the bundle")
+	for _, e := range entries {
+		info, err := e.Info()
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0664), info.Mode().Perm(), e.Name())
+		assert.Equal(t, uint32(gid), info.Sys().(*syscall.Stat_t).Gid, e.Name())
+	}
+}
+
+func TestPublishTSL_DefaultModes(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := filepath.Join(t.TempDir(), "output")
+
+	old := syscall.Umask(0027)
+	defer syscall.Umask(old)
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir)
+	require.NoError(t, err)
+	info, err := os.Stat(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), "the umask applies without file-mode")
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/beevik/etree"
+	xmldsig "github.com/russellhaering/goxmldsig"
+)
+
+// canonicalXML returns the Exclusive XML Canonicalization 1.0 (without
+// comments) form of an XML document, as written by the c14n option of the
+// publish step: without XML declaration, with attributes in canonical order,
+// empty elements written as start and end tag, and namespaces declared where
+// they are used. The formatting whitespace of the document is kept, so the
+// signature of a signed document remains valid.
+func canonicalXML(data []byte) ([]byte, error) {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return nil, fmt.Errorf("failed to parse XML for canonicalization: %w", err)
+	}
+	if doc.Root() == nil {
+		return nil, fmt.Errorf("failed to canonicalize XML: no root element")
+	}
+	canonical, err := xmldsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("").Canonicalize(doc.Root())
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize XML: %w", err)
+	}
+	return canonical, nil
+}
+
+// keepPublished reports whether the reproducible option of the publish step
+// keeps the file published at filePath rather than replacing it with data, the
+// unsigned XML of tsl. It does when the published file has the same content
+// apart from its sequence number, dates and signature (see canonicalPublished),
+// is signed if and only if the step signs, and is not due for reissue: more
+// than half of the next update period of tsl is left before its NextUpdate.
+//
+// When it keeps the file, it returns a copy of tsl with the sequence number
+// and dates of the published file, which describes the file for sidecars and
+// the static API.
+func keepPublished(filePath string, data []byte, tsl *etsi119612.TSL, signed bool, now time.Time) (*etsi119612.TSL, error) {
+	old, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read published file %s: %w", filePath, err)
+	}
+
+	oldCanonical, err := canonicalPublished(old)
+	if err != nil {
+		return nil, nil
+	}
+	newCanonical, err := canonicalPublished(data)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(oldCanonical, newCanonical) {
+		return nil, nil
+	}
+	if _, err := dsig.VerifyXML(old); (err == nil) != signed {
+		return nil, nil
+	}
+
+	outline, err := outlinePublished(old)
+	if err != nil {
+		return nil, nil
+	}
+	if next, ok := parseTSLTime(outline.NextUpdate); ok {
+		remaining := next.Sub(now)
+		if period := nextUpdatePeriod(tsl); period > 0 {
+			if remaining <= period/2 {
+				return nil, nil
+			}
+		} else if remaining <= 0 {
+			return nil, nil
+		}
+	}
+
+	kept := *tsl
+	if si := tsl.StatusList.TslSchemeInformation; si != nil {
+		keptSI := *si
+		if n, err := strconv.Atoi(outline.SequenceNumber); err == nil {
+			keptSI.TSLSequenceNumber = n
+		}
+		keptSI.ListIssueDateTime = outline.IssueDate
+		keptSI.TslNextUpdate = nil
+		if outline.NextUpdate != "" {
+			keptSI.TslNextUpdate = &etsi119612.NextUpdateType{DateTime: outline.NextUpdate}
+		}
+		kept.StatusList.TslSchemeInformation = &keptSI
+	}
+	return &kept, nil
+}
+
+// nextUpdatePeriod returns the time from the ListIssueDateTime to the
+// NextUpdate of tsl, or 0 if either is not set.
+func nextUpdatePeriod(tsl *etsi119612.TSL) time.Duration {
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil || si.TslNextUpdate == nil {
+		return 0
+	}
+	issued, ok := parseTSLTime(si.ListIssueDateTime)
+	if !ok {
+		return 0
+	}
+	next, ok := parseTSLTime(si.TslNextUpdate.DateTime)
+	if !ok || !next.After(issued) {
+		return 0
+	}
+	return next.Sub(issued)
+}
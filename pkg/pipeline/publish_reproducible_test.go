@@ -0,0 +1,128 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishTSL_Deterministic(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	var published [][]byte
+	for range 2 {
+		dir := t.TempDir()
+		_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir)
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+		require.NoError(t, err)
+		published = append(published, data)
+	}
+	assert.Equal(t, string(published[0]), string(published[1]))
+}
+
+func TestPublishTSL_Reproducible(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "se.xml")
+
+	// withNextUpdate sets the NextUpdate of a dry-run context to its issue date plus period
+	withNextUpdate := func(ctx *Context, issued time.Time, period time.Duration) *Context {
+		si := firstPublishedTSL(ctx).StatusList.TslSchemeInformation
+		si.ListIssueDateTime = issued.Format(tslDateTimeLayout)
+		si.TslNextUpdate = &etsi119612.NextUpdateType{DateTime: issued.Add(period).Format(tslDateTimeLayout)}
+		return ctx
+	}
+	publish := func(ctx *Context) []byte {
+		_, err := PublishTSL(pl, ctx, dir, "reproducible", "sidecars", "api")
+		require.NoError(t, err)
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		return data
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	month := 30 * 24 * time.Hour
+
+	first := publish(withNextUpdate(dryRunContext(1, statusGranted), now, month))
+	meta, err := os.ReadFile(file + MetaSidecarExt)
+	require.NoError(t, err)
+
+	// The same content with a new sequence number and dates keeps the file
+	again := publish(withNextUpdate(dryRunContext(2, statusGranted), now.Add(time.Hour), month))
+	assert.Equal(t, string(first), string(again))
+	keptMeta, err := os.ReadFile(file + MetaSidecarExt)
+	require.NoError(t, err)
+	assert.Equal(t, string(meta), string(keptMeta), "sidecars are kept too")
+	index, err := os.ReadFile(filepath.Join(dir, StaticAPIDir, "index.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `"sequence_number": 1`, "the static API describes the kept file")
+
+	// Changed content reissues the file
+	changed := publish(withNextUpdate(dryRunContext(3, statusWithdrawn), now.Add(2*time.Hour), month))
+	assert.Contains(t, string(changed), "<tsl:TSLSequenceNumber>3</tsl:TSLSequenceNumber>")
+
+	// So does an unchanged file that is due for reissue
+	_, err = PublishTSL(pl, withNextUpdate(dryRunContext(4, statusWithdrawn), now.Add(-20*24*time.Hour), month), dir)
+	require.NoError(t, err)
+	due := publish(withNextUpdate(dryRunContext(5, statusWithdrawn), now, month))
+	assert.Contains(t, string(due), "<tsl:TSLSequenceNumber>5</tsl:TSLSequenceNumber>", "less than half of the period is left")
+}
+
+func TestPublishTSL_ReproducibleSigning(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	keyFile := filepath.Join(certDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "se.xml")
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, "reproducible")
+	require.NoError(t, err)
+
+	// Starting to sign reissues the unsigned file
+	_, err = PublishTSL(pl, dryRunContext(2, statusGranted), dir, certFile, keyFile, "reproducible")
+	require.NoError(t, err)
+	signed, err := os.ReadFile(file)
+	require.NoError(t, err)
+	_, err = dsig.VerifyXML(signed)
+	require.NoError(t, err)
+
+	_, err = PublishTSL(pl, dryRunContext(3, statusGranted), dir, certFile, keyFile, "reproducible")
+	require.NoError(t, err)
+	kept, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, string(signed), string(kept))
+}
+
+func TestPublishTSL_C14N(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	keyFile := filepath.Join(certDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	dir := t.TempDir()
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, keyFile, "c14n")
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(string(data), `<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#">`), "no XML declaration, and only used namespaces are declared")
+	_, err = dsig.VerifyXML(data)
+	assert.NoError(t, err, "the canonical form keeps the signature valid")
+
+	canonical, err := canonicalXML(data)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(canonical), "canonicalization is idempotent")
+
+	_, err = canonicalXML([]byte("not xml"))
+	assert.Error(t, err)
+}
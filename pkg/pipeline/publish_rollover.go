@@ -0,0 +1,222 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// NextSignedDir is the directory below the output directory of a publish
+// step to which the copies of the TSLs signed with the next key are written
+// during a key rollover.
+const NextSignedDir = "next"
+
+// Phases of a signing key rollover.
+const (
+	RolloverNone     = ""         // No next key configured
+	RolloverPending  = "pending"  // Before the rollover window: signed with the current key
+	RolloverDual     = "dual"     // In the window: signed with the current key, copies with the next key
+	RolloverComplete = "complete" // After the window: signed with the next key
+)
+
+// parseRollover parses the value of a "rollover:" publish option, a window
+// "FROM/UNTIL" of two dates (2006-01-02) or RFC 3339 times. Either end may be
+// left empty for an open window.
+func parseRollover(value string) (from, until time.Time, err error) {
+	start, end, ok := strings.Cut(value, "/")
+	if !ok {
+		return from, until, fmt.Errorf("invalid publish option rollover:%s: want rollover:FROM/UNTIL", value)
+	}
+	parse := func(s string) (time.Time, error) {
+		if s == "" {
+			return time.Time{}, nil
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, nil
+		}
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid publish option rollover:%s: %q is not a date or RFC 3339 time", value, s)
+		}
+		return t, nil
+	}
+	if from, err = parse(start); err != nil {
+		return from, until, err
+	}
+	if until, err = parse(end); err != nil {
+		return from, until, err
+	}
+	if !from.IsZero() && !until.IsZero() && !from.Before(until) {
+		return from, until, fmt.Errorf("invalid publish option rollover:%s: the window ends before it starts", value)
+	}
+	return from, until, nil
+}
+
+// rolloverPhase returns the phase of the key rollover configured in opts at
+// now. A next key without a window is always in the dual phase.
+func (o *publishOptions) rolloverPhase(now time.Time) string {
+	switch {
+	case o.nextCert == "" && o.nextKey == "":
+		return RolloverNone
+	case !o.rolloverFrom.IsZero() && now.Before(o.rolloverFrom):
+		return RolloverPending
+	case !o.rolloverUntil.IsZero() && !now.Before(o.rolloverUntil):
+		return RolloverComplete
+	default:
+		return RolloverDual
+	}
+}
+
+// nextSignerArgs returns the positional publish arguments that configure the
+// next signing key of opts in place of the current one: the certificate and
+// key files for a file signer, the remote signer URI and certificate file
+// for a remote signer, or the key and certificate labels and key ID on the
+// same token for a PKCS#11 signer.
+func (o *publishOptions) nextSignerArgs(args []string) ([]string, error) {
+	switch signingBackend(args) {
+	case SigningBackendFile:
+		if o.nextCert == "" || o.nextKey == "" {
+			return nil, fmt.Errorf("a next file signer needs both next-cert: and next-key:")
+		}
+		return []string{args[0], o.nextCert, o.nextKey}, nil
+	case SigningBackendRemote:
+		if o.nextCert == "" || o.nextKey == "" {
+			return nil, fmt.Errorf("a next remote signer needs both next-cert: and next-key:")
+		}
+		return []string{args[0], o.nextKey, o.nextCert}, nil
+	case SigningBackendEphemeral:
+		return nil, fmt.Errorf("an ephemeral signer has no next key")
+	case SigningBackendPKCS11:
+		next := []string{args[0], args[1], "default-key", "default-cert", "01"}
+		copy(next[2:], args[2:])
+		if o.nextKey != "" {
+			next[2] = o.nextKey
+		}
+		if o.nextCert != "" {
+			next[3] = o.nextCert
+		}
+		if o.nextKeyID != "" {
+			next[4] = o.nextKeyID
+		}
+		return next, nil
+	default:
+		return nil, fmt.Errorf("a next signing key needs a current signer")
+	}
+}
+
+// applyRollover sets the signers of opts for a publish at now: the current
+// key before the rollover window, the current key and a copy with the next
+// key in it, and the next key after it. current is the signer configured by
+// args.
+func applyRollover(pl *Pipeline, args []string, opts *publishOptions, current dsig.XMLSigner, now time.Time) error {
+	opts.signer = current
+	if opts.nextKeyID != "" && signingBackend(args) != SigningBackendPKCS11 {
+		return fmt.Errorf("next-key-id: only applies to PKCS#11 signers")
+	}
+	phase := opts.rolloverPhase(now)
+	opts.rollover = phase
+	if phase == RolloverNone {
+		if !opts.rolloverFrom.IsZero() || !opts.rolloverUntil.IsZero() {
+			return fmt.Errorf("rollover: needs a next signing key (next-cert: and next-key:)")
+		}
+		return nil
+	}
+
+	nextArgs, err := opts.nextSignerArgs(args)
+	if err != nil {
+		return err
+	}
+	next, err := publishSigner(nextArgs)
+	if err != nil {
+		return fmt.Errorf("invalid next signer: %w", err)
+	}
+
+	switch phase {
+	case RolloverDual:
+		opts.nextSigner = next
+	case RolloverComplete:
+		opts.signer = next
+		pl.Logger.Warn("Signing key rollover complete, signing with the next key; make it the current key",
+			logging.F("until", opts.rolloverUntil.Format(time.RFC3339)))
+	}
+	pl.Logger.Debug("Signing key rollover",
+		logging.F("phase", phase),
+		logging.F("from", opts.rolloverFrom.Format(time.RFC3339)),
+		logging.F("until", opts.rolloverUntil.Format(time.RFC3339)))
+	return nil
+}
+
+// nextSignedPath returns the path of the copy, signed with the next key, of
+// the TSL published at filePath: the same path relative to the NextSignedDir
+// of the output directory.
+func (o *publishOptions) nextSignedPath(filePath string) string {
+	if o.root == "" {
+		return filepath.Join(filepath.Dir(filePath), NextSignedDir, filepath.Base(filePath))
+	}
+	rel, err := filepath.Rel(o.root, filePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(filePath)
+	}
+	return filepath.Join(o.root, NextSignedDir, rel)
+}
+
+// publishNextSigned signs the unsigned XML of tsl with the next key of opts,
+// verifies it like the list signed with the current key and writes it, with
+// sidecars if configured, to the next signed path of filePath.
+func publishNextSigned(pl *Pipeline, tsl *etsi119612.TSL, filePath string, xmlData []byte, opts *publishOptions) error {
+	signed, err := opts.nextSigner.Sign(xmlData)
+	if err != nil {
+		return fmt.Errorf("failed to sign TSL with the next key: %w", err)
+	}
+	if opts.c14n {
+		if signed, err = canonicalXML(signed); err != nil {
+			return err
+		}
+	}
+	nextPath := opts.nextSignedPath(filePath)
+	cert, err := verifySigned(tsl, signed)
+	if err != nil {
+		return fmt.Errorf("TSL for %s signed with the next key failed verification: %w", nextPath, err)
+	}
+	if err := opts.mkdirAll(filepath.Dir(nextPath)); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", nextPath, err)
+	}
+	if err := opts.writeFile(nextPath, signed); err != nil {
+		return fmt.Errorf("failed to write TSL to file %s: %w", nextPath, err)
+	}
+	if opts.sidecars {
+		if err := writeSidecars(nextPath, tsl, signed, opts); err != nil {
+			return err
+		}
+	}
+	pl.Logger.Info("Published TSL signed with the next key",
+		logging.F("file", nextPath),
+		logging.F("signer", cert.Subject.String()),
+		logging.F("size", len(signed)))
+	return nil
+}
+
+// removeNextSigned removes the copies signed with the next key from the
+// output directory dirPath outside the rollover window, so that relying
+// parties do not fetch copies that are no longer updated.
+func removeNextSigned(pl *Pipeline, dirPath string, opts *publishOptions) error {
+	if !opts.writes() || (opts.rollover != RolloverPending && opts.rollover != RolloverComplete) {
+		return nil
+	}
+	nextDir := filepath.Join(dirPath, NextSignedDir)
+	if _, err := os.Stat(nextDir); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.RemoveAll(nextDir); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", nextDir, err)
+	}
+	pl.Logger.Info("Removed TSLs signed with the next key outside the rollover window",
+		logging.F("directory", nextDir))
+	return nil
+}
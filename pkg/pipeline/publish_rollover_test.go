@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rolloverKeys generates a current and a next signing key and returns the
+// certificate and key files of both.
+func rolloverKeys(t *testing.T) (certFile, keyFile, nextCert, nextKey string) {
+	t.Helper()
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	nextCert = filepath.Join(dir, "next-cert.pem")
+	nextKey = filepath.Join(dir, "next-key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	require.NoError(t, generateTestCertAndKey(nextCert, nextKey))
+	return certFile, keyFile, nextCert, nextKey
+}
+
+// signerFingerprint returns the fingerprint of the signing certificate of the
+// TSL at path.
+func signerFingerprint(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	cert, err := dsig.VerifyXML(data)
+	require.NoError(t, err)
+	return Fingerprint(cert)
+}
+
+// certFingerprint returns the fingerprint of the certificate in the PEM file.
+func certFingerprint(t *testing.T, certFile string) string {
+	t.Helper()
+	data, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return Fingerprint(cert)
+}
+
+func TestParseRollover(t *testing.T) {
+	from, until, err := parseRollover("2026-12-01/2027-01-15T12:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2027, 1, 15, 12, 0, 0, 0, time.UTC), until)
+
+	from, until, err = parseRollover("/2027-01-15")
+	require.NoError(t, err)
+	assert.True(t, from.IsZero())
+	assert.False(t, until.IsZero())
+
+	for _, value := range []string{"2026-12-01", "tomorrow/2027-01-15", "2027-01-15/2026-12-01"} {
+		_, _, err := parseRollover(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRolloverPhase(t *testing.T) {
+	from := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2027, 1, 15, 0, 0, 0, 0, time.UTC)
+	opts := &publishOptions{nextCert: "next.pem", nextKey: "next.key", rolloverFrom: from, rolloverUntil: until}
+
+	assert.Equal(t, RolloverPending, opts.rolloverPhase(from.Add(-time.Second)))
+	assert.Equal(t, RolloverDual, opts.rolloverPhase(from))
+	assert.Equal(t, RolloverComplete, opts.rolloverPhase(until))
+	assert.Equal(t, RolloverDual, (&publishOptions{nextCert: "next.pem", nextKey: "next.key"}).rolloverPhase(until))
+	assert.Equal(t, RolloverNone, (&publishOptions{}).rolloverPhase(until))
+}
+
+func TestPublishTSL_Rollover(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	certFile, keyFile, nextCert, nextKey := rolloverKeys(t)
+	current := certFingerprint(t, certFile)
+	next := certFingerprint(t, nextCert)
+	day := 24 * time.Hour
+	window := func(from, until time.Time) string {
+		return "rollover:" + from.UTC().Format(time.RFC3339) + "/" + until.UTC().Format(time.RFC3339)
+	}
+	publish := func(dir string, options ...string) error {
+		args := append([]string{dir, certFile, keyFile, "next-cert:" + nextCert, "next-key:" + nextKey}, options...)
+		_, err := PublishTSL(pl, dryRunContext(1, statusGranted), args...)
+		return err
+	}
+
+	t.Run("dual", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, publish(dir, window(time.Now().Add(-day), time.Now().Add(day)), "sidecars"))
+		assert.Equal(t, current, signerFingerprint(t, filepath.Join(dir, "se.xml")))
+		assert.Equal(t, next, signerFingerprint(t, filepath.Join(dir, NextSignedDir, "se.xml")))
+		assert.FileExists(t, filepath.Join(dir, NextSignedDir, "se.xml.sha256"))
+	})
+
+	t.Run("pending removes next", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, publish(dir))
+		require.FileExists(t, filepath.Join(dir, NextSignedDir, "se.xml"))
+
+		require.NoError(t, publish(dir, window(time.Now().Add(day), time.Now().Add(2*day))))
+		assert.Equal(t, current, signerFingerprint(t, filepath.Join(dir, "se.xml")))
+		assert.NoDirExists(t, filepath.Join(dir, NextSignedDir))
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, publish(dir, window(time.Now().Add(-2*day), time.Now().Add(-day))))
+		assert.Equal(t, next, signerFingerprint(t, filepath.Join(dir, "se.xml")))
+		assert.NoDirExists(t, filepath.Join(dir, NextSignedDir))
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, keyFile, "rollover:2026-12-01/2027-01-15")
+		assert.ErrorContains(t, err, "needs a next signing key")
+		_, err = PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, keyFile, "next-cert:"+nextCert)
+		assert.ErrorContains(t, err, "needs both next-cert: and next-key:")
+		assert.ErrorContains(t, publish(dir, "next-key-id:02"), "only applies to PKCS#11")
+	})
+}
+
+func TestPublishedSignatures(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	certFile, keyFile, nextCert, nextKey := rolloverKeys(t)
+	dir := t.TempDir()
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, keyFile, "next-cert:"+nextCert, "next-key:"+nextKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unsigned.xml"), []byte("<TrustServiceStatusList/>"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, StaticAPIDir), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, StaticAPIDir, "ignored.xml"), []byte("<x/>"), 0644))
+
+	signatures, err := PublishedSignatures(dir)
+	require.NoError(t, err)
+	require.Len(t, signatures, 3)
+
+	assert.Equal(t, NextSignedDir+"/se.xml", signatures[0].File)
+	assert.True(t, signatures[0].Next)
+	assert.True(t, signatures[0].Valid)
+	assert.Equal(t, certFingerprint(t, nextCert), signatures[0].SHA256)
+
+	assert.Equal(t, "se.xml", signatures[1].File)
+	assert.False(t, signatures[1].Next)
+	assert.True(t, signatures[1].Valid)
+	assert.Equal(t, certFingerprint(t, certFile), signatures[1].SHA256)
+	assert.Equal(t, "CN=Test Certificate,O=Test Org", signatures[1].Subject)
+
+	assert.Equal(t, "unsigned.xml", signatures[2].File)
+	assert.False(t, signatures[2].Signed)
+
+	_, err = PublishedSignatures(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
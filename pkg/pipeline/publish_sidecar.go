@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/dsig"
+)
+
+// Sidecar file extensions written next to published TSL XML files.
+const (
+	SHA256SidecarExt = ".sha256"
+	MetaSidecarExt   = ".meta"
+)
+
+// publishOptions holds the settings of a publish step that apply to every
+// written TSL file.
+type publishOptions struct {
+	signer   dsig.XMLSigner // Signs the XML before it is written, if set
+	sidecars bool           // Write .sha256 and .meta files next to each XML file
+	bundle   bool           // Archive the output directory into a zip bundle when done
+	dryRun   bool           // Compare with the published files instead of writing
+	atomic   bool           // Write to a new generation directory and swap a symlink to it
+	api      bool           // Write a static JSON API below the output directory when done
+	keep     int            // Number of generations an atomic publish retains
+
+	c14n         bool // Write the XML in Exclusive XML Canonicalization form
+	reproducible bool // Keep published files whose content is unchanged
+
+	latestLink bool   // Point a symlink to the output directory when done
+	latest     string // Path of that symlink, "" for LatestLink next to the output directory
+
+	nameTemplate *template.Template // Names the published files, if set
+	fileMode     os.FileMode        // Mode of written files, if set, else DefaultPublishFileMode
+	dirMode      os.FileMode        // Mode of created directories, if set, else DefaultPublishDirMode
+	group        string             // Group of written files and directories, if set
+	gid          int                // Id of group
+
+	nextCert      string         // Certificate file, or PKCS#11 label, of the next signing key
+	nextKey       string         // Key file, or PKCS#11 label, of the next signing key
+	nextKeyID     string         // PKCS#11 ID of the next signing key, if not that of the current key
+	rolloverFrom  time.Time      // Start of the dual signing window, if set
+	rolloverUntil time.Time      // End of the dual signing window, if set
+	rollover      string         // Rollover phase of this run
+	nextSigner    dsig.XMLSigner // Signs copies of the XML written below NextSignedDir, if set
+	root          string         // Output directory being written
+
+	changes   []PublishChange // Comparisons made by a dry run
+	claims    fileNameClaims  // Paths already published to by this run
+	published []publishedFile // TSLs written by this run
+}
+
+// writes reports whether the publish step writes files, that is, whether it is
+// not a dry run. A nil opts writes.
+func (o *publishOptions) writes() bool {
+	return o == nil || !o.dryRun
+}
+
+// parsePublishOptions extracts keyword options from the publish step arguments
+// and returns the remaining positional arguments. The first argument (the
+// output directory) is never treated as an option.
+func parsePublishOptions(args []string) ([]string, *publishOptions, error) {
+	opts := &publishOptions{keep: DefaultPublishGenerations}
+	rest := []string{args[0]}
+	for _, arg := range args[1:] {
+		switch {
+		case arg == "sidecars":
+			opts.sidecars = true
+		case arg == "bundle":
+			opts.bundle = true
+		case arg == "dry-run":
+			opts.dryRun = true
+		case arg == "atomic":
+			opts.atomic = true
+		case arg == "api":
+			opts.api = true
+		case arg == "c14n":
+			opts.c14n = true
+		case arg == "reproducible":
+			opts.reproducible = true
+		case arg == "latest" || strings.HasPrefix(arg, "latest:"):
+			link, ok := parseLatestOption(arg)
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid publish option %s: the symlink path is empty", arg)
+			}
+			opts.latestLink, opts.latest = true, link
+		case strings.HasPrefix(arg, "atomic:"):
+			keep, err := strconv.Atoi(strings.TrimPrefix(arg, "atomic:"))
+			if err != nil || keep < 1 {
+				return nil, nil, fmt.Errorf("invalid publish option %s: the number of retained generations must be at least 1", arg)
+			}
+			opts.atomic = true
+			opts.keep = keep
+		case strings.HasPrefix(arg, "name-template:"):
+			tmpl, err := parseNameTemplate(strings.TrimPrefix(arg, "name-template:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.nameTemplate = tmpl
+		case strings.HasPrefix(arg, "file-mode:"):
+			mode, err := parseMode("file-mode", strings.TrimPrefix(arg, "file-mode:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.fileMode = mode
+		case strings.HasPrefix(arg, "dir-mode:"):
+			mode, err := parseMode("dir-mode", strings.TrimPrefix(arg, "dir-mode:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.dirMode = mode
+		case strings.HasPrefix(arg, "next-cert:"):
+			opts.nextCert = strings.TrimPrefix(arg, "next-cert:")
+		case strings.HasPrefix(arg, "next-key:"):
+			opts.nextKey = strings.TrimPrefix(arg, "next-key:")
+		case strings.HasPrefix(arg, "next-key-id:"):
+			opts.nextKeyID = strings.TrimPrefix(arg, "next-key-id:")
+		case strings.HasPrefix(arg, "rollover:"):
+			from, until, err := parseRollover(strings.TrimPrefix(arg, "rollover:"))
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.rolloverFrom, opts.rolloverUntil = from, until
+		case strings.HasPrefix(arg, "group:"):
+			opts.group = strings.TrimPrefix(arg, "group:")
+			gid, err := lookupGroup(opts.group)
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.gid = gid
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return rest, opts, nil
+}
+
+// PublishedMeta is the content of the .meta sidecar file written next to a
+// published TSL. It lets mirrors decide whether to download the XML without
+// parsing it.
+type PublishedMeta struct {
+	File           string    `json:"file"`                  // Base name of the XML file
+	Size           int       `json:"size"`                  // Size of the XML file in bytes
+	SHA256         string    `json:"sha256"`                // Hex-encoded SHA-256 of the XML file
+	Territory      string    `json:"territory,omitempty"`   // Scheme territory
+	SequenceNumber int       `json:"sequence_number"`       // TSL sequence number
+	IssueDate      string    `json:"issue_date,omitempty"`  // List issue date and time as in the TSL
+	NextUpdate     string    `json:"next_update,omitempty"` // Next update date and time as in the TSL
+	Signed         bool      `json:"signed"`                // Whether the XML carries an XML-DSIG signature
+	Published      time.Time `json:"published"`             // When the file was written
+}
+
+// ReadPublishedMeta reads the .meta sidecar file for the published TSL at xmlPath.
+func ReadPublishedMeta(xmlPath string) (*PublishedMeta, error) {
+	data, err := os.ReadFile(xmlPath + MetaSidecarExt)
+	if err != nil {
+		return nil, err
+	}
+	var meta PublishedMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", xmlPath+MetaSidecarExt, err)
+	}
+	return &meta, nil
+}
+
+// writeSidecars writes the .sha256 and .meta files for a published TSL with
+// the file mode and group of opts. The .sha256 file uses the sha256sum format
+// so it can be checked with "sha256sum -c".
+func writeSidecars(filePath string, tsl *etsi119612.TSL, data []byte, opts *publishOptions) error {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	name := filepath.Base(filePath)
+
+	shaLine := fmt.Sprintf("%s  %s\n", digest, name)
+	if err := opts.writeFile(filePath+SHA256SidecarExt, []byte(shaLine)); err != nil {
+		return fmt.Errorf("failed to write checksum file for %s: %w", filePath, err)
+	}
+
+	meta := PublishedMeta{
+		File:      name,
+		Size:      len(data),
+		SHA256:    digest,
+		Signed:    opts.signer != nil,
+		Published: time.Now().UTC(),
+	}
+	if si := tsl.StatusList.TslSchemeInformation; si != nil {
+		meta.Territory = si.TslSchemeTerritory
+		meta.SequenceNumber = si.TSLSequenceNumber
+		meta.IssueDate = si.ListIssueDateTime
+		if si.TslNextUpdate != nil {
+			meta.NextUpdate = si.TslNextUpdate.DateTime
+		}
+	}
+
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata for %s: %w", filePath, err)
+	}
+	if err := opts.writeFile(filePath+MetaSidecarExt, append(metaData, '\n')); err != nil {
+		return fmt.Errorf("failed to write metadata file for %s: %w", filePath, err)
+	}
+
+	return nil
+}
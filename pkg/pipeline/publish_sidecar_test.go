@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePublishOptions(t *testing.T) {
+	rest, opts, err := parsePublishOptions([]string{"/tmp/out", "sidecars", "cert.pem", "key.pem"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/out", "cert.pem", "key.pem"}, rest)
+	assert.True(t, opts.sidecars)
+
+	rest, opts, err = parsePublishOptions([]string{"sidecars"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sidecars"}, rest, "the output directory is never an option")
+	assert.False(t, opts.sidecars)
+}
+
+func TestPublishTSL_Sidecars(t *testing.T) {
+	dir := t.TempDir()
+
+	tsl := generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	tsl.StatusList.TslSchemeInformation.TSLSequenceNumber = 42
+	tsl.StatusList.TslSchemeInformation.ListIssueDateTime = "2025-01-01T00:00:00Z"
+
+	ctx := &Context{}
+	ctx.EnsureTSLStack().TSLs.Push(tsl)
+
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	_, err := PublishTSL(pl, ctx, dir, "sidecars")
+	require.NoError(t, err)
+
+	xmlPath := filepath.Join(dir, "tsl-0.xml")
+	data, err := os.ReadFile(xmlPath)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	shaLine, err := os.ReadFile(xmlPath + SHA256SidecarExt)
+	require.NoError(t, err)
+	assert.Equal(t, digest+"  tsl-0.xml\n", string(shaLine))
+
+	meta, err := ReadPublishedMeta(xmlPath)
+	require.NoError(t, err)
+	assert.Equal(t, "tsl-0.xml", meta.File)
+	assert.Equal(t, len(data), meta.Size)
+	assert.Equal(t, digest, meta.SHA256)
+	assert.Equal(t, "SE", meta.Territory)
+	assert.Equal(t, 42, meta.SequenceNumber)
+	assert.Equal(t, "2025-01-01T00:00:00Z", meta.IssueDate)
+	assert.False(t, meta.Signed)
+	assert.False(t, meta.Published.IsZero())
+}
+
+func TestPublishTSL_NoSidecarsByDefault(t *testing.T) {
+	dir := t.TempDir()
+
+	ctx := &Context{}
+	ctx.EnsureTSLStack().TSLs.Push(generateTSL("Test Service", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64}))
+
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	_, err := PublishTSL(pl, ctx, dir)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "tsl-0.xml", entries[0].Name())
+
+	_, err = ReadPublishedMeta(filepath.Join(dir, "tsl-0.xml"))
+	assert.Error(t, err)
+}
@@ -9,10 +9,12 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/dsig"
 	"github.com/SUNET/go-trust/pkg/logging"
 	"github.com/beevik/etree"
 )
@@ -85,6 +87,39 @@ func TestPublishTSL_WithSignature(t *testing.T) {
 	}
 }
 
+func TestPublishTSL_EphemeralSigner(t *testing.T) {
+	ctx := &Context{}
+	tsl := generateTSL("Test Service 1", "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", []string{TestCertBase64})
+	tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+		URI: []string{"https://example.com/test-tsl.xml"},
+	}
+	ctx.EnsureTSLStack().TSLs.Push(tsl)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+
+	// Every step of the process signs with the same key
+	var fingerprints []string
+	for range 2 {
+		dir := t.TempDir()
+		if _, err := PublishTSL(pl, ctx, dir, "ephemeral"); err != nil {
+			t.Fatalf("PublishTSL failed: %v", err)
+		}
+		signatures, err := PublishedSignatures(dir)
+		if err != nil {
+			t.Fatalf("PublishedSignatures failed: %v", err)
+		}
+		if len(signatures) != 1 || !signatures[0].Valid {
+			t.Fatalf("Expected one valid signature, got %+v", signatures)
+		}
+		if !strings.Contains(signatures[0].Subject, dsig.EphemeralSignerCommonName) {
+			t.Errorf("Expected an ephemeral signing certificate, got %s", signatures[0].Subject)
+		}
+		fingerprints = append(fingerprints, signatures[0].SHA256)
+	}
+	if fingerprints[0] != fingerprints[1] {
+		t.Errorf("Expected the same ephemeral key for both steps, got %v", fingerprints)
+	}
+}
+
 // generateTestCertAndKey creates a self-signed certificate and private key for testing
 func generateTestCertAndKey(certFile, keyFile string) error {
 	// Generate a private key
@@ -148,3 +183,112 @@ func generateTestCertAndKey(certFile, keyFile string) error {
 
 	return nil
 }
+
+func TestPipeline_SigningBackends(t *testing.T) {
+	pl := createTestPipeline([]Pipe{
+		{MethodName: "load", MethodArguments: []string{"https://example.com/tsl.xml"}},
+		{MethodName: "publish", MethodArguments: []string{"/out/unsigned", "sidecars"}},
+		{MethodName: "publish", MethodArguments: []string{"/out/signed", "/etc/cert.pem", "/etc/key.pem", "bundle"}},
+		{MethodName: "publish", MethodArguments: []string{"/out/hsm", "pkcs11:module=/usr/lib/softhsm.so", "key"}},
+		{MethodName: "publish", MethodArguments: []string{"/out/kms", "remote:gcp-kms:projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", "/etc/cert.pem"}},
+		{MethodName: "publish", MethodArguments: []string{"/out/demo", "ephemeral", "sidecars"}},
+		{MethodName: "publish", MethodArguments: []string{"/out/again", "/etc/cert.pem", "/etc/key.pem"}},
+	})
+	got := pl.SigningBackends()
+	want := []string{SigningBackendEphemeral, SigningBackendFile, SigningBackendPKCS11, SigningBackendRemote}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if got := createTestPipeline(nil).SigningBackends(); len(got) != 0 {
+		t.Errorf("Expected no signing backends, got %v", got)
+	}
+}
+
+func TestPipeline_CheckSigners(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := generateTestCertAndKey(certFile, keyFile); err != nil {
+		t.Fatalf("Failed to generate test certificate and key: %v", err)
+	}
+	otherCert := filepath.Join(dir, "other-cert.pem")
+	otherKey := filepath.Join(dir, "other-key.pem")
+	if err := generateTestCertAndKey(otherCert, otherKey); err != nil {
+		t.Fatalf("Failed to generate test certificate and key: %v", err)
+	}
+	out := t.TempDir()
+	logger := logging.NewLogger(logging.DebugLevel)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		pipes   []Pipe
+		wantErr string
+	}{
+		{"no publish steps", []Pipe{{MethodName: "load", MethodArguments: []string{"tsl.xml"}}}, ""},
+		{"unsigned publish", []Pipe{{MethodName: "publish", MethodArguments: []string{out, "sidecars"}}}, ""},
+		{"valid signer", []Pipe{{MethodName: "publish", MethodArguments: []string{out, certFile, keyFile, "bundle"}}}, ""},
+		{"mismatched key", []Pipe{
+			{MethodName: "load", MethodArguments: []string{"tsl.xml"}},
+			{MethodName: "publish", MethodArguments: []string{out, certFile, otherKey}},
+		}, "step 1 (publish) failed: invalid file signer: private key does not match certificate"},
+		{"missing certificate", []Pipe{{MethodName: "publish", MethodArguments: []string{out, filepath.Join(dir, "missing.pem"), keyFile}}}, "failed to read certificate file"},
+		{"invalid PKCS#11 URI", []Pipe{{MethodName: "publish", MethodArguments: []string{out, "pkcs11:%zz"}}}, "invalid PKCS#11 URI"},
+		{"ephemeral signer", []Pipe{{MethodName: "publish", MethodArguments: []string{out, "ephemeral", "sidecars"}}}, ""},
+		{"ephemeral signer rollover", []Pipe{{MethodName: "publish", MethodArguments: []string{out, "ephemeral", "next-key:" + keyFile}}}, "an ephemeral signer has no next key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := &Pipeline{Pipes: tt.pipes, Logger: logger}
+			err := pl.CheckSigners(now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("CheckSigners() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckSigners() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The test certificate is valid for a day; two days later it has expired
+	pl := &Pipeline{Pipes: []Pipe{{MethodName: "publish", MethodArguments: []string{out, certFile, keyFile}}}, Logger: logger}
+	if err := pl.CheckSigners(now.Add(48 * time.Hour)); err == nil || !strings.Contains(err.Error(), "expired at") {
+		t.Errorf("CheckSigners() error = %v, want expired", err)
+	}
+}
+
+func TestPipeline_CheckSigners_Rollover(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	nextCert := filepath.Join(dir, "next-cert.pem")
+	nextKey := filepath.Join(dir, "next-key.pem")
+	for _, pair := range [][2]string{{certFile, keyFile}, {nextCert, nextKey}} {
+		if err := generateTestCertAndKey(pair[0], pair[1]); err != nil {
+			t.Fatalf("Failed to generate test certificate and key: %v", err)
+		}
+	}
+	out := t.TempDir()
+	logger := logging.NewLogger(logging.DebugLevel)
+	now := time.Now()
+	publish := func(options ...string) *Pipeline {
+		args := append([]string{out, certFile, keyFile, "next-cert:" + nextCert}, options...)
+		return &Pipeline{Pipes: []Pipe{{MethodName: "publish", MethodArguments: args}}, Logger: logger}
+	}
+
+	if err := publish("next-key:" + nextKey).CheckSigners(now); err != nil {
+		t.Errorf("CheckSigners() error = %v", err)
+	}
+	if err := publish("next-key:" + keyFile).CheckSigners(now); err == nil || !strings.Contains(err.Error(), "invalid next file signer: private key does not match certificate") {
+		t.Errorf("CheckSigners() error = %v, want mismatched next key", err)
+	}
+	// A window starting after the next certificate expires is rejected now
+	window := "rollover:" + now.Add(48*time.Hour).UTC().Format(time.RFC3339) + "/"
+	if err := publish("next-key:"+nextKey, window).CheckSigners(now); err == nil || !strings.Contains(err.Error(), "expired at") {
+		t.Errorf("CheckSigners() error = %v, want next key expired at the start of the window", err)
+	}
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+)
+
+// PublishedSignature describes the signature of a TSL XML file in a publish
+// directory.
+type PublishedSignature struct {
+	File      string    `json:"file"`                 // Path relative to the publish directory, with forward slashes
+	Next      bool      `json:"next,omitempty"`       // Whether the file is a copy signed with the next key, below NextSignedDir
+	Signed    bool      `json:"signed"`               // Whether the file carries an XML-DSIG signature
+	Valid     bool      `json:"valid"`                // Whether the signature verifies against its certificate
+	Error     string    `json:"error,omitempty"`      // Why the signature does not verify
+	Subject   string    `json:"subject,omitempty"`    // Subject of the signing certificate
+	Issuer    string    `json:"issuer,omitempty"`     // Issuer of the signing certificate
+	Serial    string    `json:"serial,omitempty"`     // Decimal serial number of the signing certificate
+	SHA256    string    `json:"sha256,omitempty"`     // Hex SHA-256 fingerprint of the signing certificate
+	NotBefore time.Time `json:"not_before,omitempty"` // Start of the validity of the signing certificate
+	NotAfter  time.Time `json:"not_after,omitempty"`  // End of the validity of the signing certificate
+}
+
+// PublishedSignatures reports the signature of every TSL XML file below the
+// publish directory dir, sorted by path, for key ceremonies: which key signed
+// each list that relying parties currently download. The static API and
+// dotfiles are skipped. dir may be the symlink of an atomic publish.
+func PublishedSignatures(dir string) ([]PublishedSignature, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := []PublishedSignature{}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || (filepath.Dir(path) == root && name == StaticAPIDir)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || !strings.EqualFold(filepath.Ext(name), ".xml") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		sig := PublishedSignature{File: rel, Next: strings.HasPrefix(rel, NextSignedDir+"/")}
+		cert, err := dsig.VerifyXML(data)
+		switch {
+		case errors.Is(err, dsig.ErrNotSigned):
+		case err != nil:
+			sig.Signed = true
+			sig.Error = err.Error()
+		default:
+			sig.Signed = true
+			sig.Valid = true
+			sig.Subject = cert.Subject.String()
+			sig.Issuer = cert.Issuer.String()
+			sig.Serial = cert.SerialNumber.String()
+			sig.SHA256 = Fingerprint(cert)
+			sig.NotBefore = cert.NotBefore.UTC()
+			sig.NotAfter = cert.NotAfter.UTC()
+		}
+		signatures = append(signatures, sig)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(signatures, func(i, j int) bool { return signatures[i].File < signatures[j].File })
+	return signatures, nil
+}
@@ -66,12 +66,12 @@ func TestPublishStep(t *testing.T) {
 	content1, err := os.ReadFile(expectedFile1)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, content1, "File content should not be empty")
-	assert.Contains(t, string(content1), "<TrustServiceStatusList>", "File should contain XML structure")
+	assert.Contains(t, string(content1), "<tsl:TrustServiceStatusList ", "File should contain XML structure")
 
 	content2, err := os.ReadFile(expectedFile2)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, content2, "File content should not be empty")
-	assert.Contains(t, string(content2), "<TrustServiceStatusList>", "File should contain XML structure")
+	assert.Contains(t, string(content2), "<tsl:TrustServiceStatusList ", "File should contain XML structure")
 }
 
 func TestPublishStep_Errors(t *testing.T) {
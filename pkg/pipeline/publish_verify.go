@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strconv"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/dsig"
+)
+
+// verifySigned checks a signed TSL before it is written: the XML-DSIG
+// signature must verify against the certificate it carries, and the signed
+// document must still be the TSL that was signed. Publishing a list that
+// relying parties reject, for example because an HSM signed with a key that
+// does not match the configured certificate, is worse than not publishing.
+// It returns the signing certificate.
+func verifySigned(tsl *etsi119612.TSL, signed []byte) (*x509.Certificate, error) {
+	cert, err := dsig.VerifyXML(signed)
+	if err != nil {
+		return nil, err
+	}
+
+	outline, err := outlinePublished(signed)
+	if err != nil {
+		return nil, err
+	}
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil {
+		return cert, nil
+	}
+	if want := strconv.Itoa(si.TSLSequenceNumber); outline.SequenceNumber != want {
+		return nil, fmt.Errorf("signed sequence number %q, want %q", outline.SequenceNumber, want)
+	}
+	if outline.Territory != si.TslSchemeTerritory {
+		return nil, fmt.Errorf("signed territory %q, want %q", outline.Territory, si.TslSchemeTerritory)
+	}
+	return cert, nil
+}
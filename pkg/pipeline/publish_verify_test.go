@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// passThroughSigner is an XMLSigner that returns its input unsigned.
+type passThroughSigner struct{}
+
+func (passThroughSigner) Sign(xmlData []byte) ([]byte, error) {
+	return xmlData, nil
+}
+
+func TestPublishTSL_VerifiesSignature(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	keyFile := filepath.Join(certDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, keyFile, "bundle")
+	require.NoError(t, err)
+
+	signed, err := os.ReadFile(filepath.Join(dir, "se.xml"))
+	require.NoError(t, err)
+	cert, err := dsig.VerifyXML(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Certificate", cert.Subject.CommonName)
+}
+
+func TestPublishTSL_KeyCertificateMismatch(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	dir := t.TempDir()
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	otherKeyFile := filepath.Join(certDir, "other-key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, filepath.Join(certDir, "key.pem")))
+	require.NoError(t, generateTestCertAndKey(filepath.Join(certDir, "other-cert.pem"), otherKeyFile))
+
+	_, err := PublishTSL(pl, dryRunContext(1, statusGranted), dir, certFile, otherKeyFile)
+	require.ErrorContains(t, err, "failed verification")
+	assert.ErrorContains(t, err, "invalid signature")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing is published when verification fails")
+}
+
+func TestVerifySigned(t *testing.T) {
+	certDir := t.TempDir()
+	certFile := filepath.Join(certDir, "cert.pem")
+	keyFile := filepath.Join(certDir, "key.pem")
+	require.NoError(t, generateTestCertAndKey(certFile, keyFile))
+	signer := dsig.NewFileSigner(certFile, keyFile)
+
+	tsl, _ := dryRunContext(1, statusGranted).TSLs.Peek()
+	xmlData, err := xml.Marshal(tsl.StatusList)
+	require.NoError(t, err)
+	signed, err := signer.Sign(xmlData)
+	require.NoError(t, err)
+
+	_, err = verifySigned(tsl, signed)
+	require.NoError(t, err)
+
+	other, _ := dryRunContext(2, statusGranted).TSLs.Peek()
+	_, err = verifySigned(other, signed)
+	assert.ErrorContains(t, err, `signed sequence number "1", want "2"`)
+
+	_, err = verifySigned(tsl, xmlData)
+	assert.ErrorContains(t, err, "not signed")
+
+	opts := &publishOptions{signer: passThroughSigner{}}
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	err = publishTSLToFile(pl, nil, tsl, filepath.Join(t.TempDir(), "se.xml"), opts)
+	assert.ErrorContains(t, err, "not signed")
+}
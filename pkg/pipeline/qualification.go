@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/x509"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+)
+
+// Qualification levels reported by ClassifyService.
+const (
+	QualificationQualified    = "qualified"     // Qualified trust service under eIDAS
+	QualificationNonQualified = "non-qualified" // Trust service that is listed but not qualified
+	QualificationUnknown      = "unknown"       // No listed trust service matched
+)
+
+// qualifiedServiceTypes are the service type identifiers of ETSI TS 119 612
+// (clause 5.5.1.1) that denote qualified trust services, without the
+// "http://uri.etsi.org/TrstSvc/Svctype/" prefix.
+var qualifiedServiceTypes = map[string]bool{
+	"CA/QC":                     true,
+	"Certstatus/OCSP/QC":        true,
+	"Certstatus/CRL/QC":         true,
+	"TSA/QTST":                  true,
+	"EDS/Q":                     true,
+	"EDS/REM/Q":                 true,
+	"PSES/Q":                    true,
+	"QESValidation/Q":           true,
+	"RemoteQSigCDManagement/Q":  true,
+	"RemoteQSealCDManagement/Q": true,
+	"EAA/Q":                     true,
+	"ElectronicArchiving/Q":     true,
+	"Ledgers/Q":                 true,
+}
+
+// Service type and status URI prefixes, without scheme.
+const (
+	serviceTypePrefix   = "uri.etsi.org/TrstSvc/Svctype/"
+	serviceStatusPrefix = "uri.etsi.org/TrstSvc/TrustedList/Svcstatus/"
+)
+
+// Qualification is the qualified status of the trust service a certificate
+// chain is anchored in, as needed by eIDAS relying parties to determine
+// signature and seal levels.
+type Qualification struct {
+	Level         string `json:"level"`                    // QualificationQualified, QualificationNonQualified or QualificationUnknown
+	Qualified     bool   `json:"qualified"`                // Level == QualificationQualified
+	ServiceType   string `json:"service_type,omitempty"`   // Service type identifier URI
+	ServiceStatus string `json:"service_status,omitempty"` // Service status URI
+	ServiceName   string `json:"service_name,omitempty"`
+	Provider      string `json:"provider,omitempty"`
+	Territory     string `json:"territory,omitempty"`
+	Reason        string `json:"reason,omitempty"` // Why the service is not qualified
+
+	AdditionalServiceInformation []string `json:"additional_service_information,omitempty"` // E.g. ForeSignatures, ForeSeals
+	Qualifiers                   []string `json:"qualifiers,omitempty"`                     // Qualifiers applying to the leaf certificate
+}
+
+// TrustService is a trust service listed in a TSL, with the provider and TSL
+// it is listed in and its parsed service information extensions.
+type TrustService struct {
+	TSL        *etsi119612.TSL
+	Provider   *etsi119612.TSPType
+	Service    *etsi119612.TSPServiceType
+	Extensions *ServiceExtensions // nil if the service has no extensions
+}
+
+// ClassifyService classifies a trust service as qualified or non-qualified for
+// a certificate issued under it. A service is qualified if its type is one of
+// the qualified service types and its status is granted, unless a NotQualified
+// qualifier of its Qualifications extension applies to leaf. Both http and
+// https forms of the URIs, with or without a trailing slash, are accepted. If
+// leaf is nil, qualifiers are not evaluated.
+func ClassifyService(ts TrustService, leaf *x509.Certificate) Qualification {
+	q := Qualification{Level: QualificationNonQualified}
+	if ts.TSL != nil && ts.TSL.StatusList.TslSchemeInformation != nil {
+		q.Territory = ts.TSL.StatusList.TslSchemeInformation.TslSchemeTerritory
+	}
+	if ts.Provider != nil && ts.Provider.TslTSPInformation != nil {
+		q.Provider = i18n.Name(ts.Provider.TslTSPInformation.TSPName, i18n.PreferredLanguages(), "Unknown")
+	}
+	if ts.Service == nil || ts.Service.TslServiceInformation == nil {
+		q.Reason = "service has no service information"
+		return q
+	}
+
+	info := ts.Service.TslServiceInformation
+	q.ServiceType = info.TslServiceTypeIdentifier
+	q.ServiceStatus = info.TslServiceStatus
+	q.ServiceName = i18n.Name(info.ServiceName, i18n.PreferredLanguages(), "Unknown")
+	if ts.Extensions != nil {
+		q.AdditionalServiceInformation = ts.Extensions.AdditionalServiceInformation
+		q.Qualifiers = ts.Extensions.QualifiersFor(leaf)
+	}
+
+	serviceType, isType := strings.CutPrefix(normalizeURI(info.TslServiceTypeIdentifier), serviceTypePrefix)
+	status, isStatus := strings.CutPrefix(normalizeURI(info.TslServiceStatus), serviceStatusPrefix)
+	switch {
+	case !isType || !qualifiedServiceTypes[serviceType]:
+		q.Reason = "service type is not a qualified service type"
+	case !isStatus || status != "granted":
+		q.Reason = "service status is not granted"
+	case hasQualifier(q.Qualifiers, QualifierNotQualified):
+		q.Reason = "NotQualified qualifier applies to the certificate"
+	default:
+		q.Level = QualificationQualified
+		q.Qualified = true
+	}
+	return q
+}
+
+// FindTrustServices returns the trust services, in all loaded TSLs and the
+// TSLs they reference, whose service digital identity includes cert.
+func (ctx *Context) FindTrustServices(cert *x509.Certificate) []TrustService {
+	var found []TrustService
+	for _, tsl := range ctx.AllTSLs() {
+		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+			matched := false
+			svc.WithCertificates(func(listed *x509.Certificate) {
+				if !matched && bytes.Equal(listed.Raw, cert.Raw) {
+					matched = true
+					found = append(found, TrustService{
+						TSL:        tsl,
+						Provider:   tsp,
+						Service:    svc,
+						Extensions: ctx.ServiceExtensionsFor(svc),
+					})
+				}
+			})
+		})
+	}
+	return found
+}
+
+// QualifyChain classifies the trust service a verified certificate chain is
+// anchored in. The chain is ordered leaf first, as returned by
+// x509.Certificate.Verify; its last certificate is the trust anchor and its
+// first is matched against the criteria of the service's qualifiers. When the
+// anchor is listed under several services, a qualified one is preferred.
+func (ctx *Context) QualifyChain(chain []*x509.Certificate) Qualification {
+	if len(chain) == 0 {
+		return Qualification{Level: QualificationUnknown, Reason: "empty certificate chain"}
+	}
+
+	services := ctx.FindTrustServices(chain[len(chain)-1])
+	if len(services) == 0 {
+		return Qualification{Level: QualificationUnknown, Reason: "trust anchor is not listed in any loaded TSL"}
+	}
+
+	var result Qualification
+	for i, ts := range services {
+		q := ClassifyService(ts, chain[0])
+		if i == 0 || q.Qualified {
+			result = q
+		}
+		if q.Qualified {
+			break
+		}
+	}
+	return result
+}
+
+// hasQualifier reports whether qualifiers include one matching name.
+func hasQualifier(qualifiers []string, name string) bool {
+	for _, q := range qualifiers {
+		if matchesURI(q, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURI strips the scheme and any trailing slash from an ETSI URI, since
+// TSLs use both http and https forms.
+func normalizeURI(uri string) string {
+	uri = strings.TrimSpace(uri)
+	if rest, ok := strings.CutPrefix(uri, "https://"); ok {
+		uri = rest
+	} else {
+		uri = strings.TrimPrefix(uri, "http://")
+	}
+	return strings.TrimSuffix(uri, "/")
+}
@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"crypto/x509"
+
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTypeCAQC  = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	testTypeCAPKC = "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"
+)
+
+// testService returns a trust service of the given type and status.
+func testService(serviceType, status string) TrustService {
+	tsl := generateTSL("Test Service", serviceType, nil)
+	tsp := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0]
+	svc := tsp.TslTSPServices.TslTSPService[0]
+	svc.TslServiceInformation.TslServiceStatus = status
+	return TrustService{TSL: tsl, Provider: tsp, Service: svc}
+}
+
+func TestClassifyService(t *testing.T) {
+	tests := []struct {
+		name        string
+		serviceType string
+		status      string
+		want        string
+		reason      string
+	}{
+		{"qualified CA granted", testTypeCAQC, etsi119612.ServiceStatusGranted, QualificationQualified, ""},
+		{"http status without slash", testTypeCAQC, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted", QualificationQualified, ""},
+		{"https type with slash", "https://uri.etsi.org/TrstSvc/Svctype/TSA/QTST/", etsi119612.ServiceStatusGranted, QualificationQualified, ""},
+		{"qualified CA withdrawn", testTypeCAQC, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn", QualificationNonQualified, "service status is not granted"},
+		{"national recognition", testTypeCAQC, "http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/recognisedatnationallevel", QualificationNonQualified, "service status is not granted"},
+		{"non-qualified CA", testTypeCAPKC, etsi119612.ServiceStatusGranted, QualificationNonQualified, "service type is not a qualified service type"},
+		{"unknown type", "http://example.org/CA/QC", etsi119612.ServiceStatusGranted, QualificationNonQualified, "service type is not a qualified service type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := ClassifyService(testService(tt.serviceType, tt.status), nil)
+			assert.Equal(t, tt.want, q.Level)
+			assert.Equal(t, tt.want == QualificationQualified, q.Qualified)
+			assert.Equal(t, tt.reason, q.Reason)
+			assert.Equal(t, tt.serviceType, q.ServiceType)
+			assert.Equal(t, tt.status, q.ServiceStatus)
+			assert.Equal(t, "Test Service", q.ServiceName)
+			assert.Equal(t, "Test Provider", q.Provider)
+		})
+	}
+}
+
+func TestClassifyService_NoServiceInformation(t *testing.T) {
+	q := ClassifyService(TrustService{Service: &etsi119612.TSPServiceType{}}, nil)
+	assert.Equal(t, QualificationNonQualified, q.Level)
+	assert.False(t, q.Qualified)
+	assert.NotEmpty(t, q.Reason)
+}
+
+func TestQualifyChain(t *testing.T) {
+	qualifiedCA, err := testutil.NewCA("Qualified CA")
+	require.NoError(t, err)
+	plainCA, err := testutil.NewCA("Plain CA")
+	require.NoError(t, err)
+	unlistedCA, err := testutil.NewCA("Unlisted CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(qualifiedCA, "leaf")
+	require.NoError(t, err)
+
+	// The qualified CA is listed both as a non-qualified and a qualified service
+	ctx := NewContext()
+	ctx.AddTSL(generateTSL("PKC Service", testTypeCAPKC, []string{qualifiedCA.Base64(), plainCA.Base64()}))
+	ctx.AddTSL(generateTSL("QC Service", testTypeCAQC, []string{qualifiedCA.Base64()}))
+
+	q := ctx.QualifyChain([]*x509.Certificate{leaf.Certificate, qualifiedCA.Certificate})
+	assert.True(t, q.Qualified)
+	assert.Equal(t, "QC Service", q.ServiceName)
+
+	q = ctx.QualifyChain([]*x509.Certificate{plainCA.Certificate})
+	assert.False(t, q.Qualified)
+	assert.Equal(t, QualificationNonQualified, q.Level)
+	assert.Equal(t, "PKC Service", q.ServiceName)
+
+	q = ctx.QualifyChain([]*x509.Certificate{unlistedCA.Certificate})
+	assert.Equal(t, QualificationUnknown, q.Level)
+	assert.False(t, q.Qualified)
+
+	q = ctx.QualifyChain(nil)
+	assert.Equal(t, QualificationUnknown, q.Level)
+}
+
+func TestFindTrustServices_Referenced(t *testing.T) {
+	ca, err := testutil.NewCA("Referenced CA")
+	require.NoError(t, err)
+
+	root := generateTSL("Root Service", testTypeCAQC, nil)
+	ref := generateTSL("Referenced Service", testTypeCAQC, []string{ca.Base64()})
+	root.Referenced = []*etsi119612.TSL{ref}
+
+	ctx := NewContext()
+	ctx.AddTSL(root)
+
+	services := ctx.FindTrustServices(ca.Certificate)
+	require.Len(t, services, 1)
+	assert.Same(t, ref, services[0].TSL)
+	assert.Len(t, ctx.AllTSLs(), 2)
+}
@@ -0,0 +1,81 @@
+package pipeline
+
+import (
+	"fmt"
+	"time"
+)
+
+// StepReport describes the outcome of a single pipeline step.
+type StepReport struct {
+	Index          int           `json:"index"`              // Position of the step in the pipeline
+	Name           string        `json:"name"`               // Registered name of the step function
+	Start          time.Time     `json:"start"`              // When the step started
+	Duration       time.Duration `json:"duration_ns"`        // How long the step took
+	ItemsProcessed int           `json:"items_processed"`    // Items handled by the step (see Context.ReportItems)
+	Warnings       []string      `json:"warnings,omitempty"` // Non-fatal problems reported by the step
+	Error          string        `json:"error,omitempty"`    // Error message if the step failed
+	Panicked       bool          `json:"panicked,omitempty"` // True if the step panicked
+}
+
+// RunReport is the structured result of a pipeline run. It is returned by
+// Pipeline.ProcessWithReport and records per-step timings, item counts, warnings
+// and errors so that callers can surface them in logs, metrics and the API.
+type RunReport struct {
+	Start    time.Time     `json:"start"`             // When the run started
+	Duration time.Duration `json:"duration_ns"`       // Total run time
+	Steps    []StepReport  `json:"steps"`             // One entry per executed step, in order
+	Sources  []SourceFetch `json:"sources,omitempty"` // TSL fetches made by the run, in order
+	Error    string        `json:"error,omitempty"`   // Error that aborted the run, if any
+}
+
+// Succeeded reports whether the run completed without error.
+func (r *RunReport) Succeeded() bool {
+	return r.Error == ""
+}
+
+// BytesDownloaded returns the number of bytes of TSL documents downloaded
+// during the run. Documents reused after a freshness probe are not counted.
+func (r *RunReport) BytesDownloaded() int64 {
+	var total int64
+	for _, fetch := range r.Sources {
+		total += fetch.Bytes
+	}
+	return total
+}
+
+// Warnings returns all step warnings in the run, each prefixed with its step name.
+func (r *RunReport) Warnings() []string {
+	var out []string
+	for _, s := range r.Steps {
+		for _, w := range s.Warnings {
+			out = append(out, fmt.Sprintf("%s: %s", s.Name, w))
+		}
+	}
+	return out
+}
+
+// AddWarning records a non-fatal problem for the currently executing step.
+// The warning is attached to the step's entry in the RunReport and logged at
+// the end of the step. Steps should use this for conditions that do not abort
+// processing, such as a referenced TSL that could not be fetched.
+func (ctx *Context) AddWarning(format string, args ...any) {
+	ctx.warnings = append(ctx.warnings, fmt.Sprintf(format, args...))
+}
+
+// ReportItems sets the number of items processed by the currently executing step,
+// as recorded in its StepReport. If a step does not call ReportItems, the number of
+// TSLs in the resulting Context is used.
+func (ctx *Context) ReportItems(n int) {
+	ctx.itemsReported = &n
+}
+
+// takeStepStats returns and clears the warnings and item count reported on ctx
+// during the current step.
+func (ctx *Context) takeStepStats() (warnings []string, items *int) {
+	if ctx == nil {
+		return nil, nil
+	}
+	warnings, items = ctx.warnings, ctx.itemsReported
+	ctx.warnings, ctx.itemsReported = nil, nil
+	return warnings, items
+}
@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessWithReport_Success(t *testing.T) {
+	RegisterFunction("report_load", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		ctx.AddTSL(&etsi119612.TSL{})
+		ctx.AddTSL(&etsi119612.TSL{})
+		ctx.AddWarning("skipped %d unreachable reference(s)", 1)
+		return ctx, nil
+	})
+	RegisterFunction("report_count", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		time.Sleep(2 * time.Millisecond)
+		ctx.ReportItems(42)
+		return ctx, nil
+	})
+	RegisterFunction("report_replace", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		ctx.AddWarning("from input context")
+		next := ctx.Copy()
+		next.AddWarning("from output context")
+		return next, nil
+	})
+
+	pl := createTestPipeline([]Pipe{
+		{MethodName: "report_load"},
+		{MethodName: "report_count"},
+		{MethodName: "report_replace"},
+	})
+
+	ctx, report, err := pl.ProcessWithReport(NewContext())
+	require.NoError(t, err)
+	require.NotNil(t, ctx)
+	require.NotNil(t, report)
+
+	assert.True(t, report.Succeeded())
+	assert.Empty(t, report.Error)
+	assert.False(t, report.Start.IsZero())
+	require.Len(t, report.Steps, 3)
+
+	load := report.Steps[0]
+	assert.Equal(t, 0, load.Index)
+	assert.Equal(t, "report_load", load.Name)
+	assert.Equal(t, ctx.TSLs.Size(), load.ItemsProcessed, "items default to the TSL count")
+	assert.Equal(t, []string{"skipped 1 unreachable reference(s)"}, load.Warnings)
+
+	count := report.Steps[1]
+	assert.Equal(t, 42, count.ItemsProcessed)
+	assert.Empty(t, count.Warnings)
+	assert.GreaterOrEqual(t, count.Duration, 2*time.Millisecond)
+
+	replace := report.Steps[2]
+	assert.Equal(t, []string{"from input context", "from output context"}, replace.Warnings)
+
+	assert.Equal(t, []string{
+		"report_load: skipped 1 unreachable reference(s)",
+		"report_replace: from input context",
+		"report_replace: from output context",
+	}, report.Warnings())
+	assert.GreaterOrEqual(t, report.Duration, count.Duration)
+
+	// Stats are cleared once collected
+	warnings, items := ctx.takeStepStats()
+	assert.Nil(t, warnings)
+	assert.Nil(t, items)
+}
+
+func TestProcessWithReport_StepError(t *testing.T) {
+	RegisterFunction("report_fail", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		return ctx, errors.New("fetch failed")
+	})
+	RegisterFunction("report_unreached", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		return ctx, nil
+	})
+
+	pl := createTestPipeline([]Pipe{
+		{MethodName: "report_fail"},
+		{MethodName: "report_unreached"},
+	})
+
+	_, report, err := pl.ProcessWithReport(NewContext())
+	require.Error(t, err)
+	require.NotNil(t, report)
+	assert.False(t, report.Succeeded())
+	assert.Equal(t, err.Error(), report.Error)
+	require.Len(t, report.Steps, 1)
+	assert.Equal(t, "fetch failed", report.Steps[0].Error)
+	assert.False(t, report.Steps[0].Panicked)
+}
+
+func TestProcessWithReport_PanicAndUnknownStep(t *testing.T) {
+	RegisterFunction("report_panic", func(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+		panic("boom")
+	})
+
+	_, report, err := createTestPipeline([]Pipe{{MethodName: "report_panic"}}).ProcessWithReport(NewContext())
+	require.Error(t, err)
+	require.Len(t, report.Steps, 1)
+	assert.True(t, report.Steps[0].Panicked)
+	assert.Contains(t, report.Steps[0].Error, "boom")
+
+	_, report, err = createTestPipeline([]Pipe{{MethodName: "report_does_not_exist"}}).ProcessWithReport(NewContext())
+	require.Error(t, err)
+	require.Len(t, report.Steps, 1)
+	assert.Contains(t, report.Steps[0].Error, "unknown methodName")
+}
@@ -0,0 +1,322 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+)
+
+// Criteria list assertions (ETSI TS 119 612 clause 5.5.9.2).
+const (
+	AssertAll        = "all"
+	AssertAtLeastOne = "atLeastOne"
+	AssertNone       = "none"
+)
+
+// Qualifier URI names used by ClassifyService. Qualifier and additional service
+// information URIs may be given in full or by their last path segment.
+const (
+	QualifierNotQualified = "NotQualified"
+)
+
+// ServiceExtensions holds the service information extensions of a trust
+// service: the AdditionalServiceInformation URIs (e.g. ForeSignatures,
+// ForeSeals, ForWebSiteAuthentication) and the Qualifications extension of
+// ETSI TS 119 612 clause 5.5.9.2. The TSL model of the etsi119612 package does
+// not retain extension content, so these are parsed from the raw TSL by LoadTSL.
+type ServiceExtensions struct {
+	AdditionalServiceInformation []string               `json:"additional_service_information,omitempty"`
+	Qualifications               []QualificationElement `json:"qualifications,omitempty"`
+}
+
+// QualificationElement applies its qualifiers to the certificates issued under
+// the service that match its criteria.
+type QualificationElement struct {
+	Qualifiers []string      `json:"qualifiers"`
+	Criteria   *CriteriaList `json:"criteria,omitempty"`
+}
+
+// CriteriaList selects certificates by key usage and certificate policies.
+type CriteriaList struct {
+	Assert        string            `json:"assert"`                   // AssertAll, AssertAtLeastOne or AssertNone
+	KeyUsage      []map[string]bool `json:"key_usage,omitempty"`      // Key usage bits, by name, each map one criterion
+	PolicySets    [][]string        `json:"policy_sets,omitempty"`    // Policy OIDs, each set one criterion
+	CriteriaLists []*CriteriaList   `json:"criteria_lists,omitempty"` // Nested criteria, each one criterion
+	Description   string            `json:"description,omitempty"`
+}
+
+// HasAdditionalServiceInformation reports whether the service has an
+// AdditionalServiceInformation URI matching uri, given in full or by its last
+// path segment (e.g. "ForeSignatures").
+func (e *ServiceExtensions) HasAdditionalServiceInformation(uri string) bool {
+	if e == nil {
+		return false
+	}
+	for _, u := range e.AdditionalServiceInformation {
+		if matchesURI(u, uri) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasQualifier reports whether any qualification element of the service lists
+// a qualifier matching uri, given in full or by its last path segment (e.g.
+// "QCWithQSCD"), regardless of its criteria.
+func (e *ServiceExtensions) HasQualifier(uri string) bool {
+	if e == nil {
+		return false
+	}
+	for _, el := range e.Qualifications {
+		for _, q := range el.Qualifiers {
+			if matchesURI(q, uri) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// QualifiersFor returns the qualifier URIs of the qualification elements whose
+// criteria match cert.
+func (e *ServiceExtensions) QualifiersFor(cert *x509.Certificate) []string {
+	if e == nil || cert == nil {
+		return nil
+	}
+	var qualifiers []string
+	for _, el := range e.Qualifications {
+		if el.Criteria == nil || el.Criteria.Matches(cert) {
+			qualifiers = append(qualifiers, el.Qualifiers...)
+		}
+	}
+	return qualifiers
+}
+
+// Matches reports whether cert meets the criteria according to the assertion.
+// An empty criteria list matches all certificates unless the assertion is none.
+func (c *CriteriaList) Matches(cert *x509.Certificate) bool {
+	var results []bool
+	for _, bits := range c.KeyUsage {
+		results = append(results, keyUsageMatches(cert, bits))
+	}
+	for _, set := range c.PolicySets {
+		results = append(results, policiesMatch(cert, set))
+	}
+	for _, nested := range c.CriteriaLists {
+		results = append(results, nested.Matches(cert))
+	}
+
+	matched := 0
+	for _, r := range results {
+		if r {
+			matched++
+		}
+	}
+	switch c.Assert {
+	case AssertAtLeastOne:
+		return matched > 0
+	case AssertNone:
+		return matched == 0
+	default:
+		return matched == len(results)
+	}
+}
+
+// keyUsageBits maps the KeyUsageBit names of ETSI TS 119 612 to x509 key usages.
+var keyUsageBits = map[string]x509.KeyUsage{
+	"digitalSignature": x509.KeyUsageDigitalSignature,
+	"nonRepudiation":   x509.KeyUsageContentCommitment,
+	"keyEncipherment":  x509.KeyUsageKeyEncipherment,
+	"dataEncipherment": x509.KeyUsageDataEncipherment,
+	"keyAgreement":     x509.KeyUsageKeyAgreement,
+	"keyCertSign":      x509.KeyUsageCertSign,
+	"crlSign":          x509.KeyUsageCRLSign,
+	"encipherOnly":     x509.KeyUsageEncipherOnly,
+	"decipherOnly":     x509.KeyUsageDecipherOnly,
+}
+
+// keyUsageMatches reports whether every listed key usage bit of cert has the
+// given value. Unknown bit names never match.
+func keyUsageMatches(cert *x509.Certificate, bits map[string]bool) bool {
+	for name, want := range bits {
+		usage, ok := keyUsageBits[name]
+		if !ok {
+			return false
+		}
+		if (cert.KeyUsage&usage != 0) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// policiesMatch reports whether cert asserts every policy OID in set.
+func policiesMatch(cert *x509.Certificate, set []string) bool {
+	asserted := make(map[string]bool, len(cert.Policies)+len(cert.PolicyIdentifiers))
+	for _, p := range cert.Policies {
+		asserted[p.String()] = true
+	}
+	for _, p := range cert.PolicyIdentifiers {
+		asserted[p.String()] = true
+	}
+	for _, oid := range set {
+		if !asserted[oid] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesURI reports whether uri equals want, ignoring scheme and trailing
+// slash, or ends with "/"+want.
+func matchesURI(uri, want string) bool {
+	u, w := normalizeURI(uri), normalizeURI(want)
+	return u == w || strings.HasSuffix(u, "/"+w)
+}
+
+// rawTSL mirrors the parts of a TSL document needed to read service information
+// extensions, in the same provider and service order as the etsi119612 model.
+type rawTSL struct {
+	Providers []struct {
+		Services []struct {
+			Extensions []rawExtension `xml:"ServiceInformation>ServiceInformationExtensions>Extension"`
+		} `xml:"TSPServices>TSPService"`
+	} `xml:"TrustServiceProviderList>TrustServiceProvider"`
+}
+
+type rawExtension struct {
+	AdditionalServiceInformation *struct {
+		URI string `xml:"URI"`
+	} `xml:"AdditionalServiceInformation"`
+	Qualifications *struct {
+		Elements []struct {
+			Qualifiers []struct {
+				URI string `xml:"uri,attr"`
+			} `xml:"Qualifiers>Qualifier"`
+			CriteriaList *rawCriteriaList `xml:"CriteriaList"`
+		} `xml:"QualificationElement"`
+	} `xml:"Qualifications"`
+}
+
+type rawCriteriaList struct {
+	Assert   string `xml:"assert,attr"`
+	KeyUsage []struct {
+		Bits []struct {
+			Name  string `xml:"name,attr"`
+			Value bool   `xml:",chardata"`
+		} `xml:"KeyUsageBit"`
+	} `xml:"KeyUsage"`
+	PolicySet []struct {
+		Identifiers []string `xml:"PolicyIdentifier>Identifier"`
+	} `xml:"PolicySet"`
+	CriteriaList []*rawCriteriaList `xml:"CriteriaList"`
+	Description  string             `xml:"Description"`
+}
+
+// toCriteriaList converts a parsed criteria list.
+func (r *rawCriteriaList) toCriteriaList() *CriteriaList {
+	if r == nil {
+		return nil
+	}
+	c := &CriteriaList{Assert: r.Assert, Description: strings.TrimSpace(r.Description)}
+	if c.Assert == "" {
+		c.Assert = AssertAll
+	}
+	for _, ku := range r.KeyUsage {
+		bits := make(map[string]bool, len(ku.Bits))
+		for _, b := range ku.Bits {
+			bits[b.Name] = b.Value
+		}
+		c.KeyUsage = append(c.KeyUsage, bits)
+	}
+	for _, ps := range r.PolicySet {
+		var oids []string
+		for _, id := range ps.Identifiers {
+			oids = append(oids, strings.TrimPrefix(strings.TrimSpace(id), "urn:oid:"))
+		}
+		c.PolicySets = append(c.PolicySets, oids)
+	}
+	for _, nested := range r.CriteriaList {
+		c.CriteriaLists = append(c.CriteriaLists, nested.toCriteriaList())
+	}
+	return c
+}
+
+// parseServiceExtensions reads the service information extensions of every
+// trust service in a raw TSL document. The result is indexed by provider and
+// service position; services without extensions have a nil entry.
+func parseServiceExtensions(data []byte) ([][]*ServiceExtensions, error) {
+	var doc rawTSL
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse service information extensions: %w", err)
+	}
+
+	result := make([][]*ServiceExtensions, len(doc.Providers))
+	for i, p := range doc.Providers {
+		result[i] = make([]*ServiceExtensions, len(p.Services))
+		for j, s := range p.Services {
+			if len(s.Extensions) == 0 {
+				continue
+			}
+			ext := &ServiceExtensions{}
+			for _, e := range s.Extensions {
+				if e.AdditionalServiceInformation != nil {
+					if uri := strings.TrimSpace(e.AdditionalServiceInformation.URI); uri != "" {
+						ext.AdditionalServiceInformation = append(ext.AdditionalServiceInformation, uri)
+					}
+				}
+				if e.Qualifications != nil {
+					for _, el := range e.Qualifications.Elements {
+						element := QualificationElement{Criteria: el.CriteriaList.toCriteriaList()}
+						for _, q := range el.Qualifiers {
+							element.Qualifiers = append(element.Qualifiers, strings.TrimSpace(q.URI))
+						}
+						ext.Qualifications = append(ext.Qualifications, element)
+					}
+				}
+			}
+			result[i][j] = ext
+		}
+	}
+	return result, nil
+}
+
+// AttachServiceExtensions parses the service information extensions from the
+// raw document of tsl and records them in the context, keyed by the services of
+// tsl. The document must be the one tsl was parsed from.
+func (ctx *Context) AttachServiceExtensions(tsl *etsi119612.TSL, data []byte) error {
+	parsed, err := parseServiceExtensions(data)
+	if err != nil {
+		return err
+	}
+	if tsl.StatusList.TslTrustServiceProviderList == nil {
+		return nil
+	}
+
+	if ctx.ServiceExtensions == nil {
+		ctx.ServiceExtensions = make(map[*etsi119612.TSPServiceType]*ServiceExtensions)
+	}
+	for i, tsp := range tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider {
+		if i >= len(parsed) || tsp == nil || tsp.TslTSPServices == nil {
+			continue
+		}
+		for j, svc := range tsp.TslTSPServices.TslTSPService {
+			if j < len(parsed[i]) && svc != nil && parsed[i][j] != nil {
+				ctx.ServiceExtensions[svc] = parsed[i][j]
+			}
+		}
+	}
+	return nil
+}
+
+// ServiceExtensionsFor returns the parsed service information extensions of a
+// trust service, or nil if it has none or they were not parsed.
+func (ctx *Context) ServiceExtensionsFor(svc *etsi119612.TSPServiceType) *ServiceExtensions {
+	if ctx == nil || ctx.ServiceExtensions == nil {
+		return nil
+	}
+	return ctx.ServiceExtensions[svc]
+}
@@ -0,0 +1,238 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicyQCP = "0.4.0.194112.1.2"
+
+// extensionsFixture holds the CAs listed in testdata/extensions-tsl.xml.
+type extensionsFixture struct {
+	SignatureCA *testutil.Cert
+	PlainCA     *testutil.Cert
+	SealCA      *testutil.Cert
+	Path        string
+	Data        []byte
+}
+
+// renderExtensionsTSL renders testdata/extensions-tsl.xml with fresh CAs.
+func renderExtensionsTSL(t *testing.T) *extensionsFixture {
+	t.Helper()
+	f := &extensionsFixture{}
+	var err error
+	f.SignatureCA, err = testutil.NewCA("Signature CA")
+	require.NoError(t, err)
+	f.PlainCA, err = testutil.NewCA("Plain CA")
+	require.NoError(t, err)
+	f.SealCA, err = testutil.NewCA("Seal CA")
+	require.NoError(t, err)
+
+	tmpl, err := template.ParseFiles("testdata/extensions-tsl.xml")
+	require.NoError(t, err)
+
+	f.Path = filepath.Join(t.TempDir(), "extensions-tsl.xml")
+	out, err := os.Create(f.Path)
+	require.NoError(t, err)
+	err = tmpl.Execute(out, map[string]string{
+		"SignatureCA": f.SignatureCA.Base64(),
+		"PlainCA":     f.PlainCA.Base64(),
+		"SealCA":      f.SealCA.Base64(),
+	})
+	require.NoError(t, out.Close())
+	require.NoError(t, err)
+
+	f.Data, err = os.ReadFile(f.Path)
+	require.NoError(t, err)
+	return f
+}
+
+func TestParseServiceExtensions(t *testing.T) {
+	f := renderExtensionsTSL(t)
+
+	parsed, err := parseServiceExtensions(f.Data)
+	require.NoError(t, err)
+	require.Len(t, parsed, 2)
+	require.Len(t, parsed[0], 1)
+	require.Len(t, parsed[1], 2)
+
+	signature := parsed[0][0]
+	require.NotNil(t, signature)
+	assert.Equal(t, []string{"http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSignatures"}, signature.AdditionalServiceInformation)
+	require.Len(t, signature.Qualifications, 2)
+	assert.Equal(t, []string{"http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/QCWithQSCD"}, signature.Qualifications[0].Qualifiers)
+	require.NotNil(t, signature.Qualifications[0].Criteria)
+	assert.Equal(t, AssertAtLeastOne, signature.Qualifications[0].Criteria.Assert)
+	assert.Equal(t, [][]string{{testPolicyQCP}}, signature.Qualifications[0].Criteria.PolicySets)
+	assert.Equal(t, []map[string]bool{{"nonRepudiation": false}}, signature.Qualifications[1].Criteria.KeyUsage)
+
+	assert.Nil(t, parsed[1][0], "service without extensions")
+	require.NotNil(t, parsed[1][1])
+	assert.True(t, parsed[1][1].HasAdditionalServiceInformation("ForeSeals"))
+
+	_, err = parseServiceExtensions([]byte("<not xml"))
+	assert.Error(t, err)
+}
+
+func TestCriteriaList_Matches(t *testing.T) {
+	ca, err := testutil.NewCA("CA")
+	require.NoError(t, err)
+	qcp, err := testutil.NewLeaf(ca, "qcp", testutil.WithPolicies(testPolicyQCP),
+		testutil.WithKeyUsage(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment))
+	require.NoError(t, err)
+	plain, err := testutil.NewLeaf(ca, "plain", testutil.WithKeyUsage(x509.KeyUsageDigitalSignature))
+	require.NoError(t, err)
+
+	criteria := func(assertion string) *CriteriaList {
+		return &CriteriaList{
+			Assert:     assertion,
+			KeyUsage:   []map[string]bool{{"nonRepudiation": true}},
+			PolicySets: [][]string{{testPolicyQCP}},
+		}
+	}
+
+	assert.True(t, criteria(AssertAll).Matches(qcp.Certificate))
+	assert.False(t, criteria(AssertAll).Matches(plain.Certificate))
+	assert.True(t, criteria(AssertAtLeastOne).Matches(qcp.Certificate))
+	assert.False(t, criteria(AssertAtLeastOne).Matches(plain.Certificate))
+	assert.False(t, criteria(AssertNone).Matches(qcp.Certificate))
+	assert.True(t, criteria(AssertNone).Matches(plain.Certificate))
+
+	nested := &CriteriaList{Assert: AssertAll, CriteriaLists: []*CriteriaList{criteria(AssertAtLeastOne)}}
+	assert.True(t, nested.Matches(qcp.Certificate))
+	assert.False(t, nested.Matches(plain.Certificate))
+
+	unknown := &CriteriaList{Assert: AssertAll, KeyUsage: []map[string]bool{{"bogus": true}}}
+	assert.False(t, unknown.Matches(qcp.Certificate))
+}
+
+func TestServiceExtensions_Lookup(t *testing.T) {
+	ext := &ServiceExtensions{
+		AdditionalServiceInformation: []string{"http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSignatures"},
+		Qualifications: []QualificationElement{
+			{Qualifiers: []string{"http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/QCWithQSCD"}},
+		},
+	}
+
+	assert.True(t, ext.HasAdditionalServiceInformation("ForeSignatures"))
+	assert.True(t, ext.HasAdditionalServiceInformation("https://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSignatures/"))
+	assert.False(t, ext.HasAdditionalServiceInformation("ForeSeals"))
+	assert.False(t, ext.HasAdditionalServiceInformation("Signatures"))
+	assert.True(t, ext.HasQualifier("QCWithQSCD"))
+	assert.False(t, ext.HasQualifier("QCNoQSCD"))
+
+	var none *ServiceExtensions
+	assert.False(t, none.HasAdditionalServiceInformation("ForeSignatures"))
+	assert.False(t, none.HasQualifier("QCWithQSCD"))
+	assert.Nil(t, none.QualifiersFor(nil))
+}
+
+func TestLoadTSL_ServiceExtensions(t *testing.T) {
+	f := renderExtensionsTSL(t)
+	pl := createTestPipeline(nil)
+
+	ctx, err := LoadTSL(pl, NewContext(), f.Path)
+	require.NoError(t, err)
+	require.Len(t, ctx.ServiceExtensions, 2)
+
+	services := ctx.FindTrustServices(f.SignatureCA.Certificate)
+	require.Len(t, services, 1)
+	assert.True(t, services[0].Extensions.HasAdditionalServiceInformation("ForeSignatures"))
+	assert.True(t, services[0].Extensions.HasQualifier("NotQualified"))
+
+	services = ctx.FindTrustServices(f.PlainCA.Certificate)
+	require.Len(t, services, 1)
+	assert.Nil(t, services[0].Extensions)
+
+	// The extensions survive a context copy
+	assert.Len(t, ctx.Copy().ServiceExtensions, 2)
+}
+
+func TestLoadTSL_ServiceExtensionsOverHTTP(t *testing.T) {
+	f := renderExtensionsTSL(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old.xml" {
+			http.Redirect(w, r, "/tsl.xml", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(f.Data)
+	}))
+	defer server.Close()
+
+	ctx, err := LoadTSL(createTestPipeline(nil), NewContext(), server.URL+"/old.xml")
+	require.NoError(t, err)
+
+	services := ctx.FindTrustServices(f.SealCA.Certificate)
+	require.Len(t, services, 1)
+	assert.True(t, services[0].Extensions.HasAdditionalServiceInformation("ForeSeals"))
+}
+
+func TestSelectCertPool_ExtensionFilters(t *testing.T) {
+	f := renderExtensionsTSL(t)
+	pl := createTestPipeline(nil)
+	leafOf := func(ca *testutil.Cert) *x509.Certificate {
+		leaf, err := testutil.NewLeaf(ca, "leaf")
+		require.NoError(t, err)
+		return leaf.Certificate
+	}
+	verifies := func(ctx *Context, ca *testutil.Cert) bool {
+		_, err := leafOf(ca).Verify(x509.VerifyOptions{Roots: ctx.CertPool})
+		return err == nil
+	}
+
+	loaded, err := LoadTSL(pl, NewContext(), f.Path)
+	require.NoError(t, err)
+
+	ctx, err := SelectCertPool(pl, loaded.Copy(), "additional-info:ForeSignatures")
+	require.NoError(t, err)
+	assert.True(t, verifies(ctx, f.SignatureCA))
+	assert.False(t, verifies(ctx, f.PlainCA))
+	assert.False(t, verifies(ctx, f.SealCA))
+
+	ctx, err = SelectCertPool(pl, loaded.Copy(), "additional-info:ForeSignatures", "additional-info:ForeSeals")
+	require.NoError(t, err)
+	assert.True(t, verifies(ctx, f.SignatureCA))
+	assert.False(t, verifies(ctx, f.PlainCA))
+	assert.True(t, verifies(ctx, f.SealCA))
+
+	ctx, err = SelectCertPool(pl, loaded.Copy(), "qualifier:QCWithQSCD")
+	require.NoError(t, err)
+	assert.True(t, verifies(ctx, f.SignatureCA))
+	assert.False(t, verifies(ctx, f.SealCA))
+
+	ctx, err = SelectCertPool(pl, loaded.Copy(), "qualifier:QCWithQSCD", "additional-info:ForeSeals")
+	require.NoError(t, err)
+	assert.False(t, verifies(ctx, f.SignatureCA))
+	assert.False(t, verifies(ctx, f.SealCA))
+}
+
+func TestQualifyChain_Qualifiers(t *testing.T) {
+	f := renderExtensionsTSL(t)
+	ctx, err := LoadTSL(createTestPipeline(nil), NewContext(), f.Path)
+	require.NoError(t, err)
+
+	qscd, err := testutil.NewLeaf(f.SignatureCA, "qscd", testutil.WithPolicies(testPolicyQCP),
+		testutil.WithKeyUsage(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment))
+	require.NoError(t, err)
+	q := ctx.QualifyChain([]*x509.Certificate{qscd.Certificate, f.SignatureCA.Certificate})
+	assert.True(t, q.Qualified)
+	assert.Equal(t, []string{"http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/QCWithQSCD"}, q.Qualifiers)
+	assert.Equal(t, []string{"http://uri.etsi.org/TrstSvc/TrustedList/SvcInfoExt/ForeSignatures"}, q.AdditionalServiceInformation)
+
+	auth, err := testutil.NewLeaf(f.SignatureCA, "auth", testutil.WithKeyUsage(x509.KeyUsageDigitalSignature))
+	require.NoError(t, err)
+	q = ctx.QualifyChain([]*x509.Certificate{auth.Certificate, f.SignatureCA.Certificate})
+	assert.False(t, q.Qualified)
+	assert.Equal(t, QualificationNonQualified, q.Level)
+	assert.Equal(t, "NotQualified qualifier applies to the certificate", q.Reason)
+}
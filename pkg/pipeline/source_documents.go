@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+)
+
+// retainSourceDocuments makes the load step keep the documents of the TSLs
+// it fetches over HTTP(S) in the Context (see SetRetainSourceDocuments).
+var retainSourceDocuments atomic.Bool
+
+// SetRetainSourceDocuments makes the load step of all pipelines keep the
+// document of every TSL it fetches over HTTP or HTTPS and admits to the tree,
+// byte for byte, so that it can be served again with its signature intact.
+// The API server enables it for the TSL proxy. Documents are held in memory
+// for as long as their Context is installed.
+func SetRetainSourceDocuments(retain bool) {
+	retainSourceDocuments.Store(retain)
+}
+
+// SourceDocument is the document a TSL was parsed from, as its server sent it.
+type SourceDocument struct {
+	URL          string    // URL the TSL was fetched from
+	Data         []byte    // The document
+	SHA256       string    // Hex SHA-256 of Data
+	FetchedAt    time.Time // When the document was fetched, or found unchanged by a freshness probe
+	LastModified time.Time // Last-Modified sent by the server, zero if none
+	NextUpdate   time.Time // NextUpdate of the TSL, zero if none
+}
+
+// RecordDocument records the document tsl was parsed from.
+func (ctx *Context) RecordDocument(tsl *etsi119612.TSL, doc *SourceDocument) {
+	if ctx.Documents == nil {
+		ctx.Documents = make(map[*etsi119612.TSL]*SourceDocument)
+	}
+	ctx.Documents[tsl] = doc
+}
+
+// DocumentOf returns the document tsl was parsed from, or nil if it was not
+// retained.
+func (ctx *Context) DocumentOf(tsl *etsi119612.TSL) *SourceDocument {
+	if ctx == nil || ctx.Documents == nil {
+		return nil
+	}
+	return ctx.Documents[tsl]
+}
+
+// retainDocuments records the documents of tsls, fetched through capture, on
+// ctx if SetRetainSourceDocuments is enabled. TSLs loaded from files are not
+// retained.
+func (t *captureTransport) retainDocuments(ctx *Context, tsls []*etsi119612.TSL) {
+	if !retainSourceDocuments.Load() {
+		return
+	}
+	for _, tsl := range tsls {
+		if doc := t.document(tsl); doc != nil {
+			ctx.RecordDocument(tsl, doc)
+		}
+	}
+}
+
+// document returns the document tsl was parsed from, following redirects, or
+// nil if it was not fetched over HTTP(S).
+func (t *captureTransport) document(tsl *etsi119612.TSL) *SourceDocument {
+	if !strings.HasPrefix(tsl.Source, "http://") && !strings.HasPrefix(tsl.Source, "https://") {
+		return nil
+	}
+
+	t.mu.Lock()
+	source := tsl.Source
+	for range 10 {
+		next, ok := t.redirects[source]
+		if !ok {
+			break
+		}
+		source = next
+	}
+	var fetched *fetchedDocument
+	for i := len(t.documents) - 1; i >= 0; i-- {
+		if t.documents[i].fetch.URL == source {
+			fetched = &t.documents[i]
+			break
+		}
+	}
+	t.mu.Unlock()
+	if fetched == nil {
+		return nil
+	}
+
+	digest := sha256.Sum256(fetched.body)
+	doc := &SourceDocument{
+		URL:       tsl.Source,
+		Data:      fetched.body,
+		SHA256:    hex.EncodeToString(digest[:]),
+		FetchedAt: fetched.fetch.Time,
+	}
+	if lastModified, err := http.ParseTime(fetched.header.Get("Last-Modified")); err == nil {
+		doc.LastModified = lastModified
+	}
+	if si := tsl.StatusList.TslSchemeInformation; si != nil && si.TslNextUpdate != nil {
+		if next, ok := parseTSLTime(si.TslNextUpdate.DateTime); ok {
+			doc.NextUpdate = next
+		}
+	}
+	return doc
+}
@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTSL_RetainSourceDocuments(t *testing.T) {
+	globalFetchCache.clear()
+	defer globalFetchCache.clear()
+	data := renderExtensionsTSL(t).Data
+	server := newFreshnessServer(t, data)
+	pl := createTestPipeline(nil)
+
+	ctx, err := LoadTSL(pl, NewContext(), server.URL+"/tsl.xml")
+	require.NoError(t, err)
+	assert.Nil(t, ctx.Documents, "documents are only retained when enabled")
+
+	SetRetainSourceDocuments(true)
+	defer SetRetainSourceDocuments(false)
+
+	ctx, err = LoadTSL(pl, NewContext(), server.URL+"/tsl.xml")
+	require.NoError(t, err)
+	tsl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	doc := ctx.DocumentOf(tsl)
+	require.NotNil(t, doc)
+	assert.Equal(t, server.URL+"/tsl.xml", doc.URL)
+	assert.Equal(t, data, doc.Data)
+	digest := sha256.Sum256(data)
+	assert.Equal(t, hex.EncodeToString(digest[:]), doc.SHA256)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), doc.LastModified.UTC())
+	assert.WithinDuration(t, time.Now(), doc.FetchedAt, time.Minute)
+	assert.Same(t, doc, ctx.Copy().DocumentOf(tsl))
+
+	ctx, err = LoadTSL(pl, NewContext(), "testdata/extensions-tsl.xml")
+	require.NoError(t, err)
+	assert.Nil(t, ctx.Documents, "TSLs loaded from files are not retained")
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"net/http"
+	"time"
+)
+
+// SourceFetch records a single request for a TSL made by the load step, so
+// that the API can report which upstream publishers are failing.
+type SourceFetch struct {
+	URL        string    `json:"url"`                   // Requested URL
+	Time       time.Time `json:"time"`                  // When the request was made
+	StatusCode int       `json:"status_code,omitempty"` // HTTP status code, if a response was received
+	Error      string    `json:"error,omitempty"`       // Why the fetch failed, empty on success
+	Cached     bool      `json:"cached,omitempty"`      // A freshness probe found the earlier download unchanged
+	Shared     bool      `json:"shared,omitempty"`      // The download of a concurrent run fetching the same URL was reused
+	Bytes      int64     `json:"bytes,omitempty"`       // Size of the downloaded document
+}
+
+// Failed reports whether the fetch did not return a usable document.
+func (f SourceFetch) Failed() bool {
+	return f.Error != ""
+}
+
+// RecordSourceFetch records a fetch of a TSL source during the current step.
+// The fetches of a run are collected in RunReport.Sources.
+func (ctx *Context) RecordSourceFetch(fetch SourceFetch) {
+	ctx.sourceFetches = append(ctx.sourceFetches, fetch)
+}
+
+// takeSourceFetches returns and clears the fetches recorded on ctx.
+func (ctx *Context) takeSourceFetches() []SourceFetch {
+	if ctx == nil {
+		return nil
+	}
+	fetches := ctx.sourceFetches
+	ctx.sourceFetches = nil
+	return fetches
+}
+
+// newSourceFetch describes a request for url made at start that returned resp
+// or failed with err.
+func newSourceFetch(url string, start time.Time, resp *http.Response, err error) SourceFetch {
+	fetch := SourceFetch{URL: url, Time: start}
+	if err != nil {
+		fetch.Error = err.Error()
+		return fetch
+	}
+	fetch.StatusCode = resp.StatusCode
+	if resp.StatusCode >= http.StatusBadRequest {
+		fetch.Error = http.StatusText(resp.StatusCode)
+		if fetch.Error == "" {
+			fetch.Error = "unexpected status"
+		}
+	}
+	return fetch
+}
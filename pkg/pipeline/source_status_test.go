@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTSL_RecordsSourceFetches(t *testing.T) {
+	f := renderExtensionsTSL(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old.xml":
+			http.Redirect(w, r, "/tsl.xml", http.StatusFound)
+		case "/tsl.xml":
+			_, _ = w.Write(f.Data)
+		case "/garbage.xml":
+			_, _ = w.Write([]byte("not a TSL"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx, err := LoadTSL(createTestPipeline(nil), NewContext(), server.URL+"/old.xml")
+	require.NoError(t, err)
+	fetches := ctx.takeSourceFetches()
+	require.Len(t, fetches, 2)
+	assert.Equal(t, server.URL+"/old.xml", fetches[0].URL)
+	assert.Equal(t, http.StatusFound, fetches[0].StatusCode)
+	assert.Equal(t, server.URL+"/tsl.xml", fetches[1].URL)
+	assert.Equal(t, http.StatusOK, fetches[1].StatusCode)
+	assert.False(t, fetches[1].Failed())
+	assert.False(t, fetches[1].Time.IsZero())
+	assert.Empty(t, ctx.takeSourceFetches(), "fetches are taken once")
+
+	ctx, err = LoadTSL(createTestPipeline(nil), NewContext(), server.URL+"/missing.xml")
+	require.Error(t, err)
+	fetches = ctx.takeSourceFetches()
+	require.NotEmpty(t, fetches)
+	assert.Equal(t, server.URL+"/missing.xml", fetches[0].URL)
+	assert.Equal(t, http.StatusNotFound, fetches[0].StatusCode)
+	assert.True(t, fetches[0].Failed())
+
+	// A document that cannot be parsed is reported with the load error
+	ctx, err = LoadTSL(createTestPipeline(nil), NewContext(), server.URL+"/garbage.xml")
+	require.Error(t, err)
+	fetches = ctx.takeSourceFetches()
+	require.Len(t, fetches, 1)
+	assert.Equal(t, http.StatusOK, fetches[0].StatusCode)
+	assert.True(t, fetches[0].Failed())
+}
+
+func TestProcessWithReport_Sources(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	pl := createTestPipeline([]Pipe{{MethodName: "load", MethodArguments: []string{server.URL + "/tsl.xml"}}})
+	_, report, err := pl.ProcessWithReport(NewContext())
+	require.Error(t, err)
+	require.NotEmpty(t, report.Sources)
+	assert.Equal(t, server.URL+"/tsl.xml", report.Sources[0].URL)
+	assert.Equal(t, http.StatusNotFound, report.Sources[0].StatusCode)
+	assert.NotEmpty(t, report.Sources[0].Error)
+}
@@ -0,0 +1,251 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/dsig"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// RegistrySnapshotVersion is the format version of registry snapshots
+// written by the export-registry step. It changes only with incompatible
+// changes to the format.
+const RegistrySnapshotVersion = 1
+
+// RegistrySnapshotMediaType is the typ of the JWS of signed registry snapshots.
+const RegistrySnapshotMediaType = "application/vnd.go-trust.registry+json"
+
+// DefaultSnapshotPool is the pool name of snapshots without a pool: option.
+const DefaultSnapshotPool = "default"
+
+// RegistrySnapshot is a machine-readable snapshot of the certificate pool of
+// a pipeline, written by the export-registry step. Offline verifiers can load
+// it to make the trust decisions the PDP would make for the pool, without
+// calling the PDP.
+type RegistrySnapshot struct {
+	Version      int                   `json:"version"`      // Format version, RegistrySnapshotVersion
+	Serial       int                   `json:"serial"`       // Increases by one with every export to the same file
+	Pool         string                `json:"pool"`         // Name of the exported pool
+	GeneratedAt  string                `json:"generated_at"` // RFC 3339 UTC time of the export
+	Certificates []RegistryCertificate `json:"certificates"` // Trusted certificates, ordered by subject
+}
+
+// RegistryCertificate is a trusted certificate of a RegistrySnapshot.
+type RegistryCertificate struct {
+	Fingerprint  string           `json:"fingerprint"`             // Hex SHA-256 fingerprint of the DER encoding
+	Subject      string           `json:"subject"`                 // Subject distinguished name
+	Issuer       string           `json:"issuer"`                  // Issuer distinguished name
+	NotBefore    time.Time        `json:"not_before"`              // Start of the validity period
+	NotAfter     time.Time        `json:"not_after"`               // End of the validity period
+	Territories  []string         `json:"territories,omitempty"`   // Scheme territories of the TSLs listing it
+	ServiceTypes []string         `json:"service_types,omitempty"` // Types of the services listing it
+	Certificate  string           `json:"certificate"`             // Base64 DER encoding
+	Provenance   []CertProvenance `json:"provenance,omitempty"`    // TSL services the certificate was selected from
+}
+
+// CertPool returns a certificate pool of the certificates of the snapshot.
+func (s *RegistrySnapshot) CertPool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, c := range s.Certificates {
+		der, err := base64.StdEncoding.DecodeString(c.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode certificate %s: %w", c.Fingerprint, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate %s: %w", c.Fingerprint, err)
+		}
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// ReadRegistrySnapshot reads a snapshot written by the export-registry step.
+func ReadRegistrySnapshot(path string) (*RegistrySnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry snapshot %s: %w", path, err)
+	}
+	var snapshot RegistrySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse registry snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+// VerifyRegistrySnapshot verifies a signed snapshot, the .jws file written
+// next to the snapshot, and returns the snapshot and the certificate it was
+// signed with. Whether that certificate is trusted is for the caller to
+// decide.
+func VerifyRegistrySnapshot(jws []byte) (*RegistrySnapshot, *x509.Certificate, error) {
+	payload, cert, err := dsig.VerifyJWS(jws)
+	if err != nil {
+		return nil, nil, err
+	}
+	var snapshot RegistrySnapshot
+	if err := json.Unmarshal(payload, &snapshot); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse registry snapshot: %w", err)
+	}
+	return &snapshot, cert, nil
+}
+
+// NewRegistrySnapshot returns a snapshot of the certificate pool of ctx.
+func NewRegistrySnapshot(ctx *Context, pool string, serial int, now time.Time) *RegistrySnapshot {
+	snapshot := &RegistrySnapshot{
+		Version:      RegistrySnapshotVersion,
+		Serial:       serial,
+		Pool:         pool,
+		GeneratedAt:  now.UTC().Format(time.RFC3339),
+		Certificates: []RegistryCertificate{},
+	}
+	for _, pc := range ctx.PoolCertificates() {
+		cert := pc.Certificate
+		entry := RegistryCertificate{
+			Fingerprint: pc.Fingerprint,
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			NotBefore:   cert.NotBefore.UTC(),
+			NotAfter:    cert.NotAfter.UTC(),
+			Certificate: base64.StdEncoding.EncodeToString(cert.Raw),
+			Provenance:  pc.Provenance,
+		}
+		for _, prov := range pc.Provenance {
+			if prov.Territory != "" && !slices.Contains(entry.Territories, prov.Territory) {
+				entry.Territories = append(entry.Territories, prov.Territory)
+			}
+			if prov.ServiceType != "" && !slices.Contains(entry.ServiceTypes, prov.ServiceType) {
+				entry.ServiceTypes = append(entry.ServiceTypes, prov.ServiceType)
+			}
+		}
+		slices.Sort(entry.Territories)
+		slices.Sort(entry.ServiceTypes)
+		snapshot.Certificates = append(snapshot.Certificates, entry)
+	}
+	return snapshot
+}
+
+// ExportRegistry is a pipeline step that writes a JSON snapshot of
+// everything the pipeline's certificate pool trusts: for each certificate its
+// fingerprint, subject, issuer, validity, the territories and service types
+// of the TSL services listing it, and its DER encoding. Offline verifiers can
+// load the snapshot instead of calling the PDP.
+//
+// Snapshots are versioned: "version" is the format version and "serial"
+// continues from the snapshot previously written to the same file, so that
+// verifiers can refuse to go back to an older snapshot. With a certificate
+// and key, the snapshot is also written signed, as a compact JWS with the
+// certificate in its x5c header, to the same path with ".jws" appended.
+//
+// Parameters:
+//   - pl: The pipeline instance for logging
+//   - ctx: The pipeline context whose certificate pool is exported
+//   - args: String arguments, where:
+//   - args[0]: Required - path of the JSON snapshot
+//   - "pool:NAME": Name of the pool in the snapshot (default "default")
+//   - "cert:PATH": PEM certificate to sign the snapshot with
+//   - "key:PATH": PEM private key (RSA or P-256) matching the certificate
+//
+// Returns:
+//   - *Context: The unchanged context
+//   - error: Non-nil if there is no certificate pool or the snapshot cannot
+//     be signed or written
+//
+// Example usage in pipeline configuration:
+//   - load: [https://ec.europa.eu/tools/lotl/eu-lotl.xml]
+//   - select: []
+//   - export-registry: ["./output/registry.json", "pool:eu", "cert:./certs/signer.pem", "key:./certs/signer.key"]
+func ExportRegistry(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	if len(args) < 1 {
+		return ctx, fmt.Errorf("missing argument: path of the registry snapshot")
+	}
+	path := args[0]
+	pool, certFile, keyFile := DefaultSnapshotPool, "", ""
+	for _, arg := range args[1:] {
+		key, value, _ := strings.Cut(arg, ":")
+		switch {
+		case key == "pool" && value != "":
+			pool = value
+		case key == "cert" && value != "":
+			certFile = value
+		case key == "key" && value != "":
+			keyFile = value
+		default:
+			return ctx, fmt.Errorf("invalid export-registry option %q", arg)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		return ctx, fmt.Errorf("export-registry requires both cert: and key: to sign the snapshot")
+	}
+	if ctx.CertPool == nil {
+		return ctx, fmt.Errorf("no certificate pool to export; run select first")
+	}
+
+	serial := 1
+	previous, err := ReadRegistrySnapshot(path)
+	switch {
+	case err == nil:
+		serial = previous.Serial + 1
+	case !errors.Is(err, os.ErrNotExist):
+		return ctx, err
+	}
+
+	snapshot := NewRegistrySnapshot(ctx, pool, serial, time.Now())
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return ctx, fmt.Errorf("failed to encode registry snapshot: %w", err)
+	}
+	data = append(data, '\n')
+
+	// Sign before writing anything, so a signing failure keeps the previous snapshot
+	var jws []byte
+	if certFile != "" {
+		jws, err = dsig.NewFileSigner(certFile, keyFile).SignJWS(data, RegistrySnapshotMediaType)
+		if err != nil {
+			return ctx, fmt.Errorf("failed to sign registry snapshot: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ctx, fmt.Errorf("failed to create directory for registry snapshot: %w", err)
+	}
+	if jws != nil {
+		if err := writeFileAtomic(path+".jws", append(jws, '\n')); err != nil {
+			return ctx, err
+		}
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return ctx, err
+	}
+
+	pl.Logger.Info("Exported registry snapshot",
+		logging.F("file", path),
+		logging.F("pool", pool),
+		logging.F("serial", serial),
+		logging.F("certificates", len(snapshot.Certificates)),
+		logging.F("signed", jws != nil))
+
+	return ctx, nil
+}
+
+// writeFileAtomic writes data to path through a temporary file, so that
+// readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportTestContext returns a context whose certificate pool holds ca,
+// imported with load-certs and selected.
+func exportTestContext(t *testing.T, pl *Pipeline, ca *testutil.Cert) *Context {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), ca.CertPEM(), 0644))
+	ctx, err := LoadCerts(pl, NewContext(), dir, "territory:SE", "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC")
+	require.NoError(t, err)
+	ctx, err = SelectCertPool(pl, ctx)
+	require.NoError(t, err)
+	return ctx
+}
+
+func TestExportRegistry(t *testing.T) {
+	ca, err := testutil.NewCA("Export Root")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	pl := createTestPipeline(nil)
+	ctx := exportTestContext(t, pl, ca)
+	out := filepath.Join(t.TempDir(), "out", "registry.json")
+
+	_, err = ExportRegistry(pl, ctx, out, "pool:eu")
+	require.NoError(t, err)
+
+	snapshot, err := ReadRegistrySnapshot(out)
+	require.NoError(t, err)
+	assert.Equal(t, RegistrySnapshotVersion, snapshot.Version)
+	assert.Equal(t, 1, snapshot.Serial)
+	assert.Equal(t, "eu", snapshot.Pool)
+	require.Len(t, snapshot.Certificates, 1)
+
+	entry := snapshot.Certificates[0]
+	assert.Equal(t, Fingerprint(ca.Certificate), entry.Fingerprint)
+	assert.Equal(t, ca.Certificate.Subject.String(), entry.Subject)
+	assert.Equal(t, []string{"SE"}, entry.Territories)
+	assert.Equal(t, []string{"http://uri.etsi.org/TrstSvc/Svctype/CA/QC"}, entry.ServiceTypes)
+	assert.True(t, entry.NotAfter.Equal(ca.Certificate.NotAfter))
+	assert.NotEmpty(t, entry.Provenance)
+
+	// Offline verifiers can validate against the snapshot alone
+	pool, err := snapshot.CertPool()
+	require.NoError(t, err)
+	_, err = leaf.Certificate.Verify(x509.VerifyOptions{Roots: pool})
+	assert.NoError(t, err)
+
+	// Exports to the same file continue the serial
+	_, err = ExportRegistry(pl, ctx, out)
+	require.NoError(t, err)
+	snapshot, err = ReadRegistrySnapshot(out)
+	require.NoError(t, err)
+	assert.Equal(t, 2, snapshot.Serial)
+	assert.Equal(t, DefaultSnapshotPool, snapshot.Pool)
+
+	_, err = os.Stat(out + ".jws")
+	assert.True(t, os.IsNotExist(err), "unsigned exports have no JWS")
+}
+
+func TestExportRegistry_Signed(t *testing.T) {
+	ca, err := testutil.NewCA("Signed Export Root")
+	require.NoError(t, err)
+	signer, err := testutil.NewSelfSigned("Snapshot Signer")
+	require.NoError(t, err)
+	keyPEM, err := signer.KeyPEM()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "signer.pem"), filepath.Join(dir, "signer.key")
+	require.NoError(t, os.WriteFile(certFile, signer.CertPEM(), 0600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0600))
+
+	pl := createTestPipeline(nil)
+	ctx := exportTestContext(t, pl, ca)
+	out := filepath.Join(dir, "registry.json")
+
+	_, err = ExportRegistry(pl, ctx, out, "cert:"+certFile, "key:"+keyFile)
+	require.NoError(t, err)
+
+	jws, err := os.ReadFile(out + ".jws")
+	require.NoError(t, err)
+	snapshot, cert, err := VerifyRegistrySnapshot(jws)
+	require.NoError(t, err)
+	assert.True(t, cert.Equal(signer.Certificate))
+	assert.Equal(t, 1, snapshot.Serial)
+	require.Len(t, snapshot.Certificates, 1)
+	assert.Equal(t, Fingerprint(ca.Certificate), snapshot.Certificates[0].Fingerprint)
+
+	// A signing failure leaves the previous snapshot in place
+	_, err = ExportRegistry(pl, ctx, out, "cert:"+certFile, "key:"+filepath.Join(dir, "missing.key"))
+	assert.Error(t, err)
+	snapshot, err = ReadRegistrySnapshot(out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, snapshot.Serial)
+}
+
+func TestExportRegistry_Errors(t *testing.T) {
+	pl := createTestPipeline(nil)
+	out := filepath.Join(t.TempDir(), "registry.json")
+
+	_, err := ExportRegistry(pl, NewContext())
+	assert.Error(t, err)
+
+	// Nothing selected yet
+	_, err = ExportRegistry(pl, NewContext(), out)
+	assert.Error(t, err)
+
+	ca, err := testutil.NewCA("Errors Root")
+	require.NoError(t, err)
+	ctx := exportTestContext(t, pl, ca)
+
+	_, err = ExportRegistry(pl, ctx, out, "color:blue")
+	assert.Error(t, err)
+	_, err = ExportRegistry(pl, ctx, out, "cert:signer.pem")
+	assert.Error(t, err)
+
+	// A corrupt previous snapshot is not silently restarted at serial 1
+	require.NoError(t, os.WriteFile(out, []byte("not json"), 0644))
+	_, err = ExportRegistry(pl, ctx, out)
+	assert.Error(t, err)
+}
@@ -20,6 +20,10 @@ import (
 //   - user-agent: Custom User-Agent header for HTTP requests
 //   - timeout: Maximum time to wait for HTTP requests (any valid Go duration string)
 //   - max-depth: Maximum depth for following TSL references (integer, 0=none, -1=unlimited)
+//   - max-referenced: Most TSLs fetched by following the references of one root TSL (see SetMaxReferencedTSLs)
+//   - max-size: Largest TSL downloaded, in bytes or with a KB, MB or GB suffix (e.g., "20MB")
+//   - evidence-dir: Directory of an evidence store archiving every fetched TSL (see EvidenceStore)
+//   - evidence-retention: How long evidence is kept (Go duration or days, e.g., "365d"; default forever)
 //   - accept: Comma-separated list of Accept header values for content negotiation (e.g., "application/xml,text/xml")
 //   - prefer-xml: If set to "true", the fetcher will try .xml extension if .pdf fails
 //   - filter-territory: Only include TSLs from the specified territory (e.g., "SE,FI,NO")
@@ -34,6 +38,8 @@ import (
 //   - user-agent:MyCustomUserAgent/1.0
 //   - timeout:60s
 //   - max-depth:2
+//   - max-referenced:50
+//   - max-size:20MB
 //   - accept:application/xml,text/xml
 //   - prefer-xml:true
 //   - filter-territory:SE
@@ -72,6 +78,14 @@ func SetFetchOptions(pl *Pipeline, ctx *Context, args ...string) (*Context, erro
 			} else {
 				return ctx, fmt.Errorf("invalid max-depth value: %s (%w)", depthStr, err)
 			}
+		} else if strings.HasPrefix(arg, "max-referenced:") {
+			limitStr := strings.TrimPrefix(arg, "max-referenced:")
+			limit, err := strconv.Atoi(limitStr)
+			if err != nil || limit <= 0 {
+				return ctx, fmt.Errorf("invalid max-referenced value: %s", limitStr)
+			}
+			ctx.Data[maxReferencedKey] = limit
+			pl.Logger.Debug("Set TSL fetch maximum referenced TSLs", logging.F("max-referenced", limit))
 		} else if strings.HasPrefix(arg, "accept:") {
 			// Handle Accept header for content negotiation
 			accepts := strings.TrimPrefix(arg, "accept:")
@@ -87,6 +101,25 @@ func SetFetchOptions(pl *Pipeline, ctx *Context, args ...string) (*Context, erro
 				ctx.TSLFetchOptions.AcceptHeaders = headers
 			}
 			pl.Logger.Debug("Set TSL fetch Accept headers", logging.F("accept", ctx.TSLFetchOptions.AcceptHeaders))
+		} else if strings.HasPrefix(arg, "max-size:") {
+			sizeStr := strings.TrimPrefix(arg, "max-size:")
+			size, err := ParseByteSize(sizeStr)
+			if err != nil || size == 0 {
+				return ctx, fmt.Errorf("invalid max-size value: %s", sizeStr)
+			}
+			ctx.Data[maxDownloadSizeKey] = size
+			pl.Logger.Debug("Set TSL fetch maximum size", logging.F("max-size", size))
+		} else if strings.HasPrefix(arg, "evidence-dir:") {
+			ctx.Data[evidenceDirKey] = strings.TrimPrefix(arg, "evidence-dir:")
+			pl.Logger.Debug("Set TSL evidence directory", logging.F("evidence-dir", ctx.Data[evidenceDirKey]))
+		} else if strings.HasPrefix(arg, "evidence-retention:") {
+			retentionStr := strings.TrimPrefix(arg, "evidence-retention:")
+			retention, err := parsePeriod(retentionStr)
+			if err != nil {
+				return ctx, fmt.Errorf("invalid evidence-retention value: %s", retentionStr)
+			}
+			ctx.Data[evidenceRetentionKey] = retention
+			pl.Logger.Debug("Set TSL evidence retention", logging.F("evidence-retention", retention))
 		} else if strings.HasPrefix(arg, "prefer-xml:") {
 			preferXML := strings.TrimPrefix(arg, "prefer-xml:")
 			if preferXML == "true" || preferXML == "1" || preferXML == "yes" {
@@ -101,10 +134,11 @@ func SetFetchOptions(pl *Pipeline, ctx *Context, args ...string) (*Context, erro
 			// Parse territory filter
 			territories := strings.TrimPrefix(arg, "filter-territory:")
 			if territories != "" {
-				filters["territory"] = strings.Split(territories, ",")
-				for i, t := range filters["territory"] {
-					filters["territory"][i] = strings.TrimSpace(t)
+				normalized, err := ValidateTerritories(strings.Split(territories, ","))
+				if err != nil {
+					return ctx, fmt.Errorf("invalid filter-territory %q: %w", territories, err)
 				}
+				filters["territory"] = normalized
 				pl.Logger.Debug("Set TSL filter by territory", logging.F("territories", filters["territory"]))
 			}
 		} else if strings.HasPrefix(arg, "filter-service-type:") {
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// Filter is a pipeline step that sets the rules deciding which TSLs are used.
+// The rules are applied by the load step to the TSLs it fetches, and by the
+// select step to the TSLs whose certificates enter the pool, so they can be
+// set before loading or, to narrow an already loaded set, before selecting.
+// TSLs filtered out are logged with the rule that dropped them.
+//
+// Each argument adds values to a rule:
+//   - territory:SE,FI - keep only TSLs of these scheme territories
+//   - scheme-type:EUgeneric - keep only TSLs whose type URI contains a value
+//   - service-type:CA/QC - keep only TSLs with a service whose type URI contains a value
+//   - operator:^Swedish - keep only TSLs with a scheme operator name matching the regular expression
+//   - url:https://tsl.example.com/* - keep only TSLs whose URL matches the pattern; * matches any characters
+//
+// Prefixing a rule with "exclude-" (exclude-territory:DE) drops the TSLs that
+// match instead. Values of operator and url rules are taken whole; the others
+// are comma-separated. The argument "clear" removes all rules set so far.
+//
+// Territory codes are normalized, GR and GB matching the EL and UK of EU
+// trusted lists. A value that is both included and excluded by the same rule,
+// an unknown territory code and an invalid regular expression are errors.
+//
+// Parameters:
+//   - pl: The pipeline instance for logging
+//   - ctx: The pipeline context whose filter rules are set
+//   - args: The filter rules
+//
+// Returns:
+//   - *Context: The context with the filter rules set
+//   - error: If an argument is not a valid rule, or rules conflict
+//
+// Example usage in pipeline configuration:
+//   - filter: ["territory:SE,FI,NO", "exclude-url:https://tsl.example.com/test/*"]
+//   - filter: ["operator:(?i)post- och telestyrelsen"]
+func Filter(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	if len(args) == 0 {
+		return ctx, fmt.Errorf("missing argument: filter rules")
+	}
+
+	// Rules are added to a copy, so that the context is unchanged on error
+	filters := cloneDataValue(contextFilters(ctx)).(map[string][]string)
+	for _, arg := range args {
+		if arg == "clear" {
+			clear(filters)
+			continue
+		}
+		rule, value, ok := strings.Cut(arg, ":")
+		if !ok || value == "" {
+			return ctx, fmt.Errorf("invalid filter %q: expected rule:value", arg)
+		}
+		base := strings.TrimPrefix(rule, excludePrefix)
+		if !slices.Contains(filterRules, base) {
+			return ctx, fmt.Errorf("unknown filter rule %q", rule)
+		}
+
+		values := []string{value}
+		switch base {
+		case FilterOperator:
+			if _, err := regexp.Compile(value); err != nil {
+				return ctx, fmt.Errorf("invalid operator filter %q: %w", value, err)
+			}
+		case FilterURL:
+			// Any pattern is valid
+		default:
+			values = nil
+			for v := range strings.SplitSeq(value, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					values = append(values, v)
+				}
+			}
+			if base == FilterTerritory {
+				territories, err := ValidateTerritories(values)
+				if err != nil {
+					return ctx, fmt.Errorf("invalid territory filter %q: %w", value, err)
+				}
+				values = territories
+			}
+		}
+		filters[rule] = append(filters[rule], values...)
+	}
+
+	if err := checkFilterConflicts(filters); err != nil {
+		return ctx, err
+	}
+
+	ctx.Data[tslFiltersKey] = filters
+	for _, rule := range slices.Sorted(maps.Keys(filters)) {
+		pl.Logger.Info("Set TSL filter",
+			logging.F("rule", rule),
+			logging.F("values", filters[rule]))
+	}
+	return ctx, nil
+}
+
+// checkFilterConflicts returns an error if a value is both included and
+// excluded by the same rule.
+func checkFilterConflicts(filters map[string][]string) error {
+	for _, rule := range filterRules {
+		for _, value := range filters[rule] {
+			for _, excluded := range filters[excludePrefix+rule] {
+				if value == excluded || (rule == FilterTerritory && strings.EqualFold(value, excluded)) {
+					return fmt.Errorf("conflicting filters: %s %s is both included and excluded", rule, value)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// filterTestTSL returns a TSL of territory with the given type, operator name
+// and URL.
+func filterTestTSL(territory, tslType, operator, url string) *etsi119612.TSL {
+	tsl := createTestTSL(url, territory, []string{"http://uri.etsi.org/TrstSvc/Svctype/CA/QC"})
+	si := tsl.StatusList.TslSchemeInformation
+	si.TslTSLType = tslType
+	name := etsi119612.NonEmptyNormalizedString(operator)
+	lang := etsi119612.Lang("en")
+	si.TslSchemeOperatorName = &etsi119612.InternationalNamesType{
+		Name: []*etsi119612.MultiLangNormStringType{{XmlLangAttr: &lang, NonEmptyNormalizedString: &name}},
+	}
+	return tsl
+}
+
+func TestFilter(t *testing.T) {
+	const generic = "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric"
+	se := filterTestTSL("SE", generic, "Post- och telestyrelsen", "https://tsl.example.com/se.xml")
+	fi := filterTestTSL("FI", generic, "Traficom", "https://tsl.example.com/fi.xml")
+	lotl := filterTestTSL("EU", "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists", "European Commission", "https://ec.example.com/lotl.xml")
+	tsls := []*etsi119612.TSL{se, fi, lotl}
+
+	tests := []struct {
+		name string
+		args []string
+		want []*etsi119612.TSL
+	}{
+		{"territory", []string{"territory:se, fi"}, []*etsi119612.TSL{se, fi}},
+		{"exclude territory", []string{"exclude-territory:FI"}, []*etsi119612.TSL{se, lotl}},
+		{"scheme type", []string{"scheme-type:EUgeneric"}, []*etsi119612.TSL{se, fi}},
+		{"operator", []string{"operator:(?i)^post-"}, []*etsi119612.TSL{se}},
+		{"exclude operator", []string{"exclude-operator:Commission$"}, []*etsi119612.TSL{se, fi}},
+		{"url", []string{"url:https://tsl.example.com/*"}, []*etsi119612.TSL{se, fi}},
+		{"exclude url", []string{"exclude-url:https://tsl.example.com/fi.xml"}, []*etsi119612.TSL{se, lotl}},
+		{"combined", []string{"url:https://tsl.example.com/*", "exclude-territory:SE"}, []*etsi119612.TSL{fi}},
+		{"clear", []string{"territory:SE", "clear", "territory:FI"}, []*etsi119612.TSL{fi}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, err := Filter(createTestPipeline(nil), NewContext(), tt.args...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, FilterTSLs(ctx, tsls))
+		})
+	}
+
+	// Rules accumulate across filter steps and keep set-fetch-options filters
+	pl := createTestPipeline(nil)
+	ctx, err := SetFetchOptions(pl, NewContext(), "filter-territory:SE,FI")
+	require.NoError(t, err)
+	ctx, err = Filter(pl, ctx, "exclude-url:*/fi.xml")
+	require.NoError(t, err)
+	assert.Equal(t, []*etsi119612.TSL{se}, FilterTSLs(ctx, tsls))
+	assert.Equal(t, "matches exclude-url */fi.xml", rejectReason(fi, contextFilters(ctx)))
+	assert.Equal(t, "does not match territory SE,FI", rejectReason(lotl, contextFilters(ctx)))
+}
+
+func TestFilter_Errors(t *testing.T) {
+	pl := createTestPipeline(nil)
+	for _, args := range [][]string{
+		{},
+		{"territory"},
+		{"territory:"},
+		{"country:SE"},
+		{"operator:("},
+		{"territory:SE", "exclude-territory:se"},
+		{"territory:SE,XX"},
+	} {
+		ctx := NewContext()
+		ctx.Data[tslFiltersKey] = map[string][]string{FilterTerritory: {"SE"}}
+		_, err := Filter(pl, ctx, args...)
+		assert.Error(t, err, "args %q", args)
+		assert.Equal(t, map[string][]string{FilterTerritory: {"SE"}}, ctx.Data[tslFiltersKey], "args %q must leave the filters as they were", args)
+	}
+}
+
+func TestSelectCertPool_Filters(t *testing.T) {
+	pl := createTestPipeline(nil)
+	tsl, _ := dryRunContext(1, statusGranted).TSLs.Peek()
+	load := func() *Context {
+		ctx := NewContext()
+		ctx.AddTSL(tsl)
+		return ctx
+	}
+
+	ctx, err := SelectCertPool(pl, load())
+	require.NoError(t, err)
+	assert.Len(t, ctx.PoolCertificates(), 1)
+
+	// Filters set after loading apply to the selection
+	ctx, err = Filter(pl, load(), "exclude-territory:SE")
+	require.NoError(t, err)
+	ctx, err = SelectCertPool(pl, ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ctx.PoolCertificates())
+}
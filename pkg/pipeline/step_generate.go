@@ -3,13 +3,17 @@ package pipeline
 import (
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
-	"gopkg.in/yaml.v3"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
+	"github.com/SUNET/go-trust/pkg/validation"
 )
 
 // MultiLangName represents a name in a specific language
@@ -40,9 +44,10 @@ type ProviderMetadata struct {
 
 // CertificateMetadata represents the YAML structure for a certificate's metadata
 type CertificateMetadata struct {
-	ServiceNames     []MultiLangName `yaml:"serviceNames"` // At least one name required
-	ServiceType      string          `yaml:"serviceType"`  // URI identifying the service type
-	Status           string          `yaml:"status"`       // Must be a valid TSL status URI
+	ServiceNames     []MultiLangName `yaml:"serviceNames"`              // At least one name required
+	ServiceType      string          `yaml:"serviceType"`               // ETSI service type URI or shorthand, e.g. "ca-qc"
+	Status           string          `yaml:"status"`                    // ETSI service status URI or shorthand, e.g. "granted"
+	AllowCustomURIs  bool            `yaml:"allowCustomURIs,omitempty"` // Accept service type and status URIs outside the ETSI vocabularies
 	ServiceDigitalID *struct {
 		DigitalIDs []string `yaml:"digitalIds,omitempty"` // Additional digital IDs beyond the certificate
 	} `yaml:"serviceDigitalId,omitempty"`
@@ -50,9 +55,12 @@ type CertificateMetadata struct {
 
 // SchemeMetadata represents the YAML structure for the TSL scheme metadata
 type SchemeMetadata struct {
-	OperatorNames  []MultiLangName `yaml:"operatorNames"`            // At least one name required
-	Type           string          `yaml:"type"`                     // URI identifying the TSL type
-	SequenceNumber int             `yaml:"sequenceNumber,omitempty"` // TSL sequence number
+	OperatorNames      []MultiLangName `yaml:"operatorNames"`                // At least one name required
+	Type               string          `yaml:"type"`                         // URI identifying the TSL type
+	SequenceNumber     int             `yaml:"sequenceNumber,omitempty"`     // TSL sequence number
+	Territory          string          `yaml:"territory,omitempty"`          // Scheme territory, e.g. "SE"
+	DistributionPoints []string        `yaml:"distributionPoints,omitempty"` // URLs the TSL is published at
+	NextUpdatePeriod   string          `yaml:"nextUpdatePeriod,omitempty"`   // Period from issue to next update, e.g. "90d"
 }
 
 // loadSchemeMetadata loads and parses the scheme metadata from the scheme.yaml file.
@@ -63,6 +71,12 @@ type SchemeMetadata struct {
 //   - operatorNames: At least one operator name with language and value
 //   - type: A valid TSL type URI (e.g., http://uri.etsi.org/TrstSvc/TrustedList/TSLType/...)
 //   - sequenceNumber: Optional TSL sequence number (defaults to 1 if not provided)
+//   - territory: Optional scheme territory, an ISO 3166-1 alpha-2 code or EL,
+//     UK or EU; GR and GB are written as EL and UK
+//   - distributionPoints: Optional URLs the TSL is published at, used by publish
+//     for the file name and by generate-lotl for the pointer location
+//   - nextUpdatePeriod: Optional period from the issue date to NextUpdate, as a
+//     Go duration ("720h") or in days ("90d")
 //
 // Parameters:
 //   - rootDir: Absolute path to the root directory containing scheme.yaml
@@ -78,6 +92,10 @@ type SchemeMetadata struct {
 //	    value: "Trust List Operator"
 //	type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists"
 //	sequenceNumber: 1
+//	territory: "SE"
+//	distributionPoints:
+//	  - "https://tsl.example.com/se.xml"
+//	nextUpdatePeriod: "90d"
 func loadSchemeMetadata(rootDir string) (*SchemeMetadata, error) {
 	metadataPath := filepath.Join(rootDir, "scheme.yaml")
 	data, err := os.ReadFile(metadataPath)
@@ -86,7 +104,7 @@ func loadSchemeMetadata(rootDir string) (*SchemeMetadata, error) {
 	}
 
 	var metadata SchemeMetadata
-	if err := yaml.Unmarshal(data, &metadata); err != nil {
+	if err := yamlutil.Unmarshal(data, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to parse scheme metadata from %s: %w", metadataPath, err)
 	}
 
@@ -98,6 +116,20 @@ func loadSchemeMetadata(rootDir string) (*SchemeMetadata, error) {
 		return nil, fmt.Errorf("scheme metadata must include a type URI")
 	}
 
+	if metadata.Territory != "" {
+		territory, err := validation.NormalizeTerritory(metadata.Territory)
+		if err != nil {
+			return nil, fmt.Errorf("scheme metadata has an invalid territory: %w", err)
+		}
+		metadata.Territory = territory
+	}
+
+	if metadata.NextUpdatePeriod != "" {
+		if _, err := parsePeriod(metadata.NextUpdatePeriod); err != nil {
+			return nil, fmt.Errorf("scheme metadata has an %w", err)
+		}
+	}
+
 	return &metadata, nil
 }
 
@@ -140,7 +172,7 @@ func loadProviderMetadata(providerDir string) (*ProviderMetadata, error) {
 	}
 
 	var metadata ProviderMetadata
-	if err := yaml.Unmarshal(data, &metadata); err != nil {
+	if err := yamlutil.Unmarshal(data, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to parse provider metadata from %s: %w", metadataPath, err)
 	}
 
@@ -170,9 +202,15 @@ func loadProviderMetadata(providerDir string) (*ProviderMetadata, error) {
 //   - error: If any certificate or metadata file cannot be read or parsed
 //
 // Expected files:
-//   - *.pem: X.509 certificates in PEM format
+//   - *.pem: X.509 certificates, PEM encoded or DER
 //   - *.yaml: Matching metadata files for each certificate
 //
+// The service type and status must be ETSI TS 119 612 identifiers, given as
+// URIs or as shorthands expanded to them: the identifier after the prefix,
+// lowercased with slashes as dashes ("ca-qc", "certstatus-ocsp-qc", "granted",
+// "withdrawn"), or one of "ocsp", "ocsp-qc", "crl", "crl-qc" and "qtst".
+// Metadata with allowCustomURIs: true may use other absolute URIs.
+//
 // Example cert.yaml:
 //
 //	serviceNames:
@@ -201,7 +239,7 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 		}
 
 		var metadata CertificateMetadata
-		if err := yaml.Unmarshal(metadataBytes, &metadata); err != nil {
+		if err := yamlutil.Unmarshal(metadataBytes, &metadata); err != nil {
 			return fmt.Errorf("failed to parse certificate metadata from %s: %w", metadataPath, err)
 		}
 
@@ -209,11 +247,14 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 			return fmt.Errorf("certificate metadata must include at least one service name")
 		}
 
-		// Load certificate
+		// Load certificate, PEM encoded or DER
 		certBytes, err := os.ReadFile(certPath)
 		if err != nil {
 			return fmt.Errorf("failed to read certificate from %s: %w", certPath, err)
 		}
+		if block, _ := pem.Decode(certBytes); block != nil && block.Type == "CERTIFICATE" {
+			certBytes = block.Bytes
+		}
 
 		// Try to parse the certificate to ensure it's valid
 		_, err = x509.ParseCertificate(certBytes)
@@ -221,6 +262,10 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 			return fmt.Errorf("failed to decode invalid certificate data in %s: %w", certPath, err)
 		}
 
+		if err := metadata.expandServiceURIs(); err != nil {
+			return fmt.Errorf("invalid certificate metadata in %s: %w", metadataPath, err)
+		}
+
 		// Create service names
 		serviceNames := make([]*etsi119612.MultiLangNormStringType, len(metadata.ServiceNames))
 		for i, name := range metadata.ServiceNames {
@@ -274,6 +319,44 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 	return nil
 }
 
+// newSchemeInformation creates the scheme information of a generated TSL from
+// its scheme metadata. The sequence number defaults to 1; issue and next
+// update dates are set by applyIssueState.
+func newSchemeInformation(metadata *SchemeMetadata) *etsi119612.TSLSchemeInformationType {
+	operatorNames := make([]*etsi119612.MultiLangNormStringType, len(metadata.OperatorNames))
+	for i, name := range metadata.OperatorNames {
+		operatorNames[i] = &etsi119612.MultiLangNormStringType{
+			XmlLangAttr: func() *etsi119612.Lang {
+				l := etsi119612.Lang(name.Language)
+				return &l
+			}(),
+			NonEmptyNormalizedString: func() *etsi119612.NonEmptyNormalizedString {
+				s := etsi119612.NonEmptyNormalizedString(name.Value)
+				return &s
+			}(),
+		}
+	}
+
+	sequenceNumber := metadata.SequenceNumber
+	if sequenceNumber < 1 {
+		sequenceNumber = 1
+	}
+
+	info := &etsi119612.TSLSchemeInformationType{
+		TSLVersionIdentifier: tslVersionIdentifier,
+		TSLSequenceNumber:    sequenceNumber,
+		TslTSLType:           metadata.Type,
+		TslSchemeOperatorName: &etsi119612.InternationalNamesType{
+			Name: operatorNames,
+		},
+		TslSchemeTerritory: metadata.Territory,
+	}
+	if len(metadata.DistributionPoints) > 0 {
+		info.TslDistributionPoints = &etsi119612.NonEmptyURIListType{URI: metadata.DistributionPoints}
+	}
+	return info
+}
+
 // GenerateTSL is a pipeline step that generates a Trust Service List (TSL) from a structured directory.
 // It implements generation of ETSI TS 119612 compliant TSLs by reading metadata and certificates
 // from a hierarchical directory structure.
@@ -295,7 +378,11 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 //	    - language: en
 //	      value: "Trust List Operator"
 //	  type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/..."  # TSL type URI
-//	  sequenceNumber: 1    # TSL sequence number
+//	  sequenceNumber: 1    # Minimum TSL sequence number
+//	  territory: "SE"      # Optional scheme territory
+//	  distributionPoints:  # Optional URLs the TSL is published at
+//	    - "https://tsl.example.com/se.xml"
+//	  nextUpdatePeriod: "90d"  # Optional period from issue to NextUpdate
 //
 //	provider.yaml:
 //	  names:              # List of provider names in different languages
@@ -321,8 +408,9 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 //	  serviceNames:        # List of service names in different languages
 //	    - language: en
 //	      value: "Example Service"
-//	  serviceType: "http://uri.etsi.org/TrstSvc/Svctype/..."  # Service type URI
-//	  status: "https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/..."  # Status URI
+//	  serviceType: "http://uri.etsi.org/TrstSvc/Svctype/..."  # Service type URI, or a shorthand such as "ca-qc"
+//	  status: "https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/..."  # Status URI, or a shorthand such as "granted"
+//	  allowCustomURIs: false  # Optional, accept URIs outside the ETSI vocabularies
 //	  serviceDigitalId:    # Optional additional digital IDs
 //	    digitalIds:
 //	      - "base64 encoded cert..."
@@ -330,7 +418,23 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 // Parameters:
 //   - pl: Pipeline instance managing the step execution
 //   - ctx: Pipeline context containing state information
-//   - args: String slice where args[0] must be the path to the root directory
+//   - args: String slice where:
+//   - args[0] must be the path to the root directory
+//   - "previous:/path/to/published.xml": The previously published TSL; the
+//     generated TSL gets its sequence number plus one
+//   - "state:/path/to/state.json": A state file recording the last generated
+//     sequence number and dates; read before and written after generation
+//   - "next-update:DURATION": Period from the issue date to NextUpdate, such as
+//     "720h" or "90d", overriding nextUpdatePeriod in scheme.yaml
+//
+// Sequence numbers and dates:
+//   - The sequence number is the highest of the scheme.yaml sequenceNumber and
+//     one more than the previous TSL or state file sequence number, so
+//     operators do not need to bump it by hand. A missing previous TSL or
+//     state file starts from scheme.yaml.
+//   - ListIssueDateTime is set to the time of generation (UTC) and NextUpdate
+//     to that time plus the configured period. Without a period NextUpdate is
+//     omitted.
 //
 // Returns:
 //   - *Context: Updated context with the generated TSL added to ctx.TSLs
@@ -345,13 +449,22 @@ func addProviderCertificates(providerDir string, provider *etsi119612.TSPType) e
 //   - Processing all certificate files (.pem) and their metadata (.yaml)
 //   - Adding all services and certificates to the provider entry
 //
-// 5. Adding the complete TSL to the pipeline context
-//   - rootDir: path to the root directory containing scheme.yaml and providers directory
+// 5. Assigning the sequence number and dates
+// 6. Adding the complete TSL to the pipeline context
+//
+// Example usage in pipeline configuration:
+//   - generate: ["./tsl/se", "previous:./output/se.xml", "next-update:90d"]
+//   - generate: ["./tsl/se", "state:./state/se.json"]
 func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("GenerateTSL requires 1 argument: path to root directory")
 	}
 
+	_, opts, err := parseGenerateOptions(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
 	rootDir := args[0]
 	providersDir := filepath.Join(rootDir, "providers")
 	entries, err := os.ReadDir(providersDir)
@@ -365,30 +478,9 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		return nil, fmt.Errorf("failed to load scheme metadata: %w", err)
 	}
 
-	// Create operator names for the TSL
-	operatorNames := make([]*etsi119612.MultiLangNormStringType, len(schemeMetadata.OperatorNames))
-	for i, name := range schemeMetadata.OperatorNames {
-		operatorNames[i] = &etsi119612.MultiLangNormStringType{
-			XmlLangAttr: func() *etsi119612.Lang {
-				l := etsi119612.Lang(name.Language)
-				return &l
-			}(),
-			NonEmptyNormalizedString: func() *etsi119612.NonEmptyNormalizedString {
-				s := etsi119612.NonEmptyNormalizedString(name.Value)
-				return &s
-			}(),
-		}
-	}
-
 	tsl := &etsi119612.TSL{
 		StatusList: etsi119612.TrustStatusListType{
-			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
-				TSLVersionIdentifier: int(schemeMetadata.SequenceNumber),
-				TslTSLType:           schemeMetadata.Type,
-				TslSchemeOperatorName: &etsi119612.InternationalNamesType{
-					Name: operatorNames,
-				},
-			},
+			TslSchemeInformation: newSchemeInformation(schemeMetadata),
 			TslTrustServiceProviderList: &etsi119612.TrustServiceProviderListType{
 				TslTrustServiceProvider: []*etsi119612.TSPType{},
 			},
@@ -474,8 +566,18 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		)
 	}
 
+	sequenceNumber, err := applyIssueState(tsl.StatusList.TslSchemeInformation, schemeMetadata, opts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	ctx.EnsureTSLStack().TSLs.Push(tsl)
 
+	pl.Logger.Debug("Generated TSL",
+		logging.F("territory", schemeMetadata.Territory),
+		logging.F("sequence_number", sequenceNumber),
+		logging.F("issue_date", tsl.StatusList.TslSchemeInformation.ListIssueDateTime))
+
 	return ctx, nil
 }
 
@@ -496,7 +598,7 @@ func GenerateTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 // URL handling:
 //   - HTTP(S) URLs are used as-is
 //   - Local paths are converted to file:// URLs
-//   - The TSL is fetched and parsed using etsi119612.FetchTSLWithReferencesAndOptions
+//   - The TSL and its references are fetched by fetchTSLWithReferences, which skips reference loops
 //
 // The function uses the fetch options (UserAgent, Timeout, MaxDereferenceDepth) that
 // were previously set using SetFetchOptions. If not set, default values will be used.
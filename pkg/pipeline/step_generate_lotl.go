@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// GenerateLOTL is a pipeline step that generates a list of lists (LOTL): a TSL
+// without trust service providers whose PointersToOtherTSL reference every TSL
+// currently in the context, whether generated or loaded. Together with the
+// generate and publish steps it lets operators publish their own hierarchical
+// trust infrastructure.
+//
+// Directory Structure:
+//
+//	root/
+//	  └── scheme.yaml      # LOTL scheme metadata, as for the generate step
+//
+// Each pointer is built from a referenced TSL as follows:
+//   - TSLLocation is the first distribution point of the TSL, or the http(s)
+//     URL it was loaded from if it has none. A TSL without either is an error,
+//     so generated TSLs should set distributionPoints in their scheme.yaml.
+//   - ServiceDigitalIdentities holds the certificates given with cert: arguments
+//     and, for a loaded signed TSL, the certificate it was signed with. These are
+//     the certificates relying parties use to verify the referenced TSL. A
+//     pointer without any is reported as a step warning.
+//
+// Parameters:
+//   - pl: Pipeline instance managing the step execution
+//   - ctx: Pipeline context containing state information
+//   - args: String slice where:
+//   - args[0] must be the path to the directory containing scheme.yaml
+//   - "cert:/path/to/signer.pem": A PEM or DER certificate that signs the
+//     referenced TSLs (can be provided multiple times)
+//   - "previous:", "state:" and "next-update:" manage the sequence number and
+//     dates of the LOTL as for the generate step
+//
+// Returns:
+//   - *Context: Updated context with the LOTL pushed on top of ctx.TSLs, referencing the other TSLs
+//   - error: Non-nil if no TSLs are loaded, the scheme metadata or a certificate
+//     cannot be read, or a TSL has no location
+//
+// Note:
+//   - The AdditionalInformation of the pointers is not written, since the
+//     etsi119612 model cannot represent its OtherInformation content
+//
+// Example usage in pipeline configuration:
+//   - generate: ["./tsl/se"]
+//   - generate: ["./tsl/no"]
+//   - generate-lotl: ["./tsl/lotl", "cert:./certs/tsl-signer.pem"]
+//   - publish: ["./output", "./certs/tsl-signer.pem", "./certs/tsl-signer.key"]
+func GenerateLOTL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	if len(args) < 1 {
+		return ctx, fmt.Errorf("GenerateLOTL requires 1 argument: path to root directory")
+	}
+	if ctx.TSLs == nil || ctx.TSLs.IsEmpty() {
+		return ctx, fmt.Errorf("no TSLs to reference")
+	}
+
+	rest, opts, err := parseGenerateOptions(args[1:])
+	if err != nil {
+		return ctx, err
+	}
+
+	var signers []*x509.Certificate
+	for _, arg := range rest {
+		path, ok := strings.CutPrefix(arg, "cert:")
+		if !ok {
+			return ctx, fmt.Errorf("unknown generate-lotl argument: %s", arg)
+		}
+		cert, err := loadCertificateFile(path)
+		if err != nil {
+			return ctx, err
+		}
+		signers = append(signers, cert)
+	}
+
+	schemeMetadata, err := loadSchemeMetadata(args[0])
+	if err != nil {
+		return ctx, fmt.Errorf("failed to load scheme metadata: %w", err)
+	}
+
+	lotl := &etsi119612.TSL{
+		StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: newSchemeInformation(schemeMetadata),
+		},
+	}
+	pointers := &etsi119612.OtherTSLPointersType{}
+
+	seen := make(map[*etsi119612.TSL]bool)
+	for _, tsl := range ctx.TSLs.ToSlice() {
+		if tsl == nil || seen[tsl] {
+			continue
+		}
+		seen[tsl] = true
+
+		location := tslLocation(tsl)
+		if location == "" {
+			return ctx, fmt.Errorf("TSL %s has no distribution point to reference", describeTSL(tsl))
+		}
+
+		identities := tslPointerIdentities(tsl, signers)
+		if len(identities) == 0 {
+			ctx.AddWarning("pointer to %s has no service digital identity", location)
+		}
+
+		pointer := &etsi119612.OtherTSLPointerType{TSLLocation: location}
+		if len(identities) > 0 {
+			pointer.TslServiceDigitalIdentities = &etsi119612.ServiceDigitalIdentityListType{
+				TslServiceDigitalIdentity: []*etsi119612.DigitalIdentityListType{{DigitalId: identities}},
+			}
+		}
+		pointers.TslOtherTSLPointer = append(pointers.TslOtherTSLPointer, pointer)
+		lotl.Referenced = append(lotl.Referenced, tsl)
+
+		pl.Logger.Debug("Added TSL pointer",
+			logging.F("location", location),
+			logging.F("identities", len(identities)))
+	}
+	lotl.StatusList.TslSchemeInformation.TslPointersToOtherTSL = pointers
+
+	if _, err := applyIssueState(lotl.StatusList.TslSchemeInformation, schemeMetadata, opts, time.Now()); err != nil {
+		return ctx, err
+	}
+
+	ctx.TSLs.Push(lotl)
+	ctx.ReportItems(len(pointers.TslOtherTSLPointer))
+
+	pl.Logger.Info("Generated list of lists",
+		logging.F("territory", schemeMetadata.Territory),
+		logging.F("pointers", len(pointers.TslOtherTSLPointer)))
+
+	return ctx, nil
+}
+
+// tslLocation returns the URL a TSL is published at: its first distribution
+// point, or the http(s) URL it was loaded from. It returns "" if neither is known.
+func tslLocation(tsl *etsi119612.TSL) string {
+	if si := tsl.StatusList.TslSchemeInformation; si != nil && si.TslDistributionPoints != nil {
+		for _, uri := range si.TslDistributionPoints.URI {
+			if uri = strings.TrimSpace(uri); uri != "" {
+				return uri
+			}
+		}
+	}
+	if strings.HasPrefix(tsl.Source, "https://") || strings.HasPrefix(tsl.Source, "http://") {
+		return tsl.Source
+	}
+	return ""
+}
+
+// tslPointerIdentities returns the digital identities of a pointer to tsl: the
+// given signer certificates followed by the certificate tsl was signed with, if
+// it was loaded signed and is not among them.
+func tslPointerIdentities(tsl *etsi119612.TSL, signers []*x509.Certificate) []*etsi119612.DigitalIdentityType {
+	var identities []*etsi119612.DigitalIdentityType
+	seen := make(map[string]bool)
+	add := func(cert *x509.Certificate) {
+		if len(cert.Raw) == 0 || seen[string(cert.Raw)] {
+			return
+		}
+		seen[string(cert.Raw)] = true
+		identities = append(identities, &etsi119612.DigitalIdentityType{
+			X509Certificate: base64.StdEncoding.EncodeToString(cert.Raw),
+		})
+	}
+	for _, cert := range signers {
+		add(cert)
+	}
+	add(&tsl.Signer)
+	return identities
+}
+
+// describeTSL returns a short description of a TSL for error messages.
+func describeTSL(tsl *etsi119612.TSL) string {
+	if si := tsl.StatusList.TslSchemeInformation; si != nil && si.TslSchemeTerritory != "" {
+		return fmt.Sprintf("for territory %s", si.TslSchemeTerritory)
+	}
+	if tsl.Source != "" {
+		return tsl.Source
+	}
+	return "without territory"
+}
+
+// loadCertificateFile reads a certificate from a PEM or DER file.
+func loadCertificateFile(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate from %s: %w", path, err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate from %s: %w", path, err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSchemeDir writes a generate step root directory with the given
+// scheme.yaml and no providers, and returns its path.
+func writeSchemeDir(t *testing.T, schemeYAML string) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "providers"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "scheme.yaml"), []byte(schemeYAML), 0644))
+	return dir
+}
+
+func nationalScheme(territory, url string) string {
+	return `operatorNames:
+  - language: en
+    value: "` + territory + ` Operator"
+type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric"
+sequenceNumber: 3
+territory: "` + territory + `"
+distributionPoints:
+  - "` + url + `"
+`
+}
+
+const lotlScheme = `operatorNames:
+  - language: en
+    value: "Federation Operator"
+type: "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists"
+sequenceNumber: 1
+territory: "EU"
+distributionPoints:
+  - "https://tsl.example.com/lotl.xml"
+`
+
+func TestGenerateTSL_SchemeTerritoryAndDistributionPoints(t *testing.T) {
+	pl := createTestPipeline(nil)
+	dir := writeSchemeDir(t, nationalScheme("SE", "https://tsl.example.com/se.xml"))
+
+	ctx, err := GenerateTSL(pl, NewContext(), dir)
+	require.NoError(t, err)
+
+	tsl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	si := tsl.StatusList.TslSchemeInformation
+	assert.Equal(t, "SE", si.TslSchemeTerritory)
+	require.NotNil(t, si.TslDistributionPoints)
+	assert.Equal(t, []string{"https://tsl.example.com/se.xml"}, si.TslDistributionPoints.URI)
+}
+
+func TestGenerateLOTL(t *testing.T) {
+	pl := createTestPipeline(nil)
+	signer, err := testutil.NewCA("TSL Signer")
+	require.NoError(t, err)
+	certPath := filepath.Join(t.TempDir(), "signer.pem")
+	require.NoError(t, os.WriteFile(certPath, signer.CertPEM(), 0644))
+
+	ctx := NewContext()
+	ctx, err = GenerateTSL(pl, ctx, writeSchemeDir(t, nationalScheme("SE", "https://tsl.example.com/se.xml")))
+	require.NoError(t, err)
+	ctx, err = GenerateTSL(pl, ctx, writeSchemeDir(t, nationalScheme("NO", "https://tsl.example.com/no.xml")))
+	require.NoError(t, err)
+
+	lotlDir := writeSchemeDir(t, lotlScheme)
+	ctx, err = GenerateLOTL(pl, ctx, lotlDir, "cert:"+certPath)
+	require.NoError(t, err)
+	require.Equal(t, 3, ctx.TSLs.Size())
+
+	lotl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	si := lotl.StatusList.TslSchemeInformation
+	assert.Equal(t, "EU", si.TslSchemeTerritory)
+	assert.Equal(t, "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUlistofthelists", si.TslTSLType)
+	assert.Len(t, lotl.Referenced, 2)
+
+	require.NotNil(t, si.TslPointersToOtherTSL)
+	pointers := si.TslPointersToOtherTSL.TslOtherTSLPointer
+	require.Len(t, pointers, 2)
+	assert.Equal(t, "https://tsl.example.com/se.xml", pointers[0].TSLLocation)
+	assert.Equal(t, "https://tsl.example.com/no.xml", pointers[1].TSLLocation)
+	for _, p := range pointers {
+		require.NotNil(t, p.TslServiceDigitalIdentities)
+		ids := p.TslServiceDigitalIdentities.TslServiceDigitalIdentity
+		require.Len(t, ids, 1)
+		require.Len(t, ids[0].DigitalId, 1)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(signer.DER), ids[0].DigitalId[0].X509Certificate)
+	}
+
+	// The published LOTL carries the pointers and is named after its distribution point
+	outDir := t.TempDir()
+	_, err = PublishTSL(pl, ctx, outDir)
+	require.NoError(t, err)
+	for _, name := range []string{"se.xml", "no.xml", "lotl.xml"} {
+		assert.FileExists(t, filepath.Join(outDir, name))
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "lotl.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<tsl:TSLLocation>https://tsl.example.com/se.xml</tsl:TSLLocation>")
+	assert.Contains(t, string(data), "<tsl:TSLLocation>https://tsl.example.com/no.xml</tsl:TSLLocation>")
+	assert.Contains(t, string(data), "<tsl:X509Certificate>"+signer.Base64()+"</tsl:X509Certificate>")
+}
+
+func TestGenerateLOTL_LoadedTSL(t *testing.T) {
+	pl := createTestPipeline(nil)
+	loaded := generateTSL("Loaded Service", testTypeCAQC, nil)
+	loaded.Source = "https://example.org/loaded.xml"
+	ctx := NewContext()
+	ctx.AddTSL(loaded)
+
+	ctx, err := GenerateLOTL(pl, ctx, writeSchemeDir(t, lotlScheme))
+	require.NoError(t, err)
+
+	lotl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	pointers := lotl.StatusList.TslSchemeInformation.TslPointersToOtherTSL.TslOtherTSLPointer
+	require.Len(t, pointers, 1)
+	assert.Equal(t, "https://example.org/loaded.xml", pointers[0].TSLLocation)
+	assert.Nil(t, pointers[0].TslServiceDigitalIdentities)
+
+	warnings, _ := ctx.takeStepStats()
+	assert.Equal(t, []string{"pointer to https://example.org/loaded.xml has no service digital identity"}, warnings)
+}
+
+func TestGenerateLOTL_Errors(t *testing.T) {
+	pl := createTestPipeline(nil)
+	lotlDir := writeSchemeDir(t, lotlScheme)
+	withTSL := func() *Context {
+		ctx := NewContext()
+		ctx.AddTSL(generateTSL("Service", testTypeCAQC, nil))
+		return ctx
+	}
+
+	_, err := GenerateLOTL(pl, NewContext())
+	assert.ErrorContains(t, err, "requires 1 argument")
+
+	_, err = GenerateLOTL(pl, NewContext(), lotlDir)
+	assert.EqualError(t, err, "no TSLs to reference")
+
+	_, err = GenerateLOTL(pl, withTSL(), lotlDir)
+	assert.ErrorContains(t, err, "has no distribution point to reference")
+
+	_, err = GenerateLOTL(pl, withTSL(), lotlDir, "bogus")
+	assert.ErrorContains(t, err, "unknown generate-lotl argument")
+
+	_, err = GenerateLOTL(pl, withTSL(), lotlDir, "cert:/nonexistent.pem")
+	assert.ErrorContains(t, err, "failed to read certificate")
+
+	_, err = GenerateLOTL(pl, withTSL(), t.TempDir())
+	assert.ErrorContains(t, err, "failed to load scheme metadata")
+}
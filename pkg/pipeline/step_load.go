@@ -1,8 +1,15 @@
 package pipeline
 
 import (
+	"bytes"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
 	"github.com/SUNET/go-trust/pkg/logging"
@@ -16,14 +23,33 @@ import (
 //
 // The step supports loading TSLs from files or HTTP/HTTPS URLs, with automatic content
 // negotiation and reference handling. It uses the TSLFetchOptions in the context for
-// request configuration (user-agent, timeout, reference depth, etc.).
+// request configuration (user-agent, timeout, reference depth, etc.). Each
+// referenced TSL is fetched once, so lists referencing themselves or each
+// other do not loop, and at most maxReferencedTSLs are fetched in all.
 //
 // Parameters:
 //   - pl: The pipeline instance for logging and configuration
 //   - ctx: The pipeline context to update with loaded TSLs
 //   - args: String arguments, where:
-//   - args[0]: Required - URL or file path to the root TSL
+//   - args[0]: Required - URL or file path to the root TSL, "-" to read it
+//     from the standard input, or an RFC 2397 data: URL holding it. Pipeline
+//     variables are substituted in it, as in "{{.Vars.source}}", so that
+//     the source can be given on the command line with --set source=...
 //   - args[1]: Optional - Filter expression for including specific TSLs (not implemented yet)
+//   - "freshness:MODE": Before downloading a TSL fetched in an earlier run
+//     again, probe it with a HEAD request ("head") or a request for its first
+//     byte ("range") and reuse the earlier download if Last-Modified and the
+//     length are unchanged; "off" (the default) always downloads
+//   - "verify:MODE": Verify the enveloped signature of every TSL fetched
+//     against the certificates of its scheme operator: those of the pointer
+//     that led to a referenced TSL, and those of "signer-cert:" for the root.
+//     "report" records the outcome of each TSL (see Context.SignatureOf) and
+//     warns about those not verified; "enforce" also keeps them, and the TSLs
+//     they point to, out of the tree, and fails if the root is not verified;
+//     "off" (the default) does not verify
+//   - "signer-cert:PATH": PEM or DER file, or directory of them, with the
+//     certificates the root TSL may be signed with; required by
+//     "verify:enforce"
 //
 // Returns:
 //   - *Context: Updated context with the loaded TSL tree and legacy TSL stack
@@ -33,10 +59,27 @@ import (
 //   - load:
 //   - https://example.com/tsl.xml
 //
+// Or probing for changes before downloading:
+//   - load:
+//   - https://example.com/tsl.xml
+//   - freshness:head
+//
+// Or admitting only TSLs signed by their scheme operators:
+//   - load:
+//   - https://ec.europa.eu/tools/lotl/eu-lotl.xml
+//   - verify:enforce
+//   - signer-cert:/etc/go-trust/lotl-signers.pem
+//
 // Or with a local file:
 //   - load:
 //   - /path/to/local/tsl.xml
 //
+// Or from the standard input, or a source set with --set source=PATH|URL:
+//   - load:
+//   - "-"
+//   - load:
+//   - "{{.Vars.source}}"
+//
 // The loaded TSL tree structure represents the hierarchical relationship between the root TSL
 // and its referenced TSLs, allowing for more efficient traversal and operations on the tree.
 func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
@@ -44,20 +87,57 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		return ctx, fmt.Errorf("missing argument: URL or file path")
 	}
 
-	url := args[0]
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		url = "file://" + url
+	url, err := resolveLoadSource(ctx, args[0])
+	if err != nil {
+		return ctx, err
 	}
 
-	// Validate the URL before processing
-	if err := validation.ValidateURL(url, validation.TSLURLOptions()); err != nil {
-		return ctx, fmt.Errorf("invalid TSL URL: %w", err)
+	// Validate the URL before processing; inline sources are not fetched
+	if !isInlineSource(url) {
+		if err := validation.ValidateURL(url, validation.TSLURLOptions()); err != nil {
+			return ctx, fmt.Errorf("invalid TSL URL: %w", err)
+		}
 	}
 
-	// Parse optional filter argument
+	// Parse the options and the optional filter argument
 	var filter string
-	if len(args) > 1 {
-		filter = args[1]
+	freshness := FreshnessOff
+	verify := SignatureOff
+	var anchors []*x509.Certificate
+	for _, arg := range args[1:] {
+		if value, ok := strings.CutPrefix(arg, "freshness:"); ok {
+			probe, err := parseFreshnessProbe(value)
+			if err != nil {
+				return ctx, err
+			}
+			freshness = probe
+			continue
+		}
+		if value, ok := strings.CutPrefix(arg, "verify:"); ok {
+			mode, err := parseSignatureMode(value)
+			if err != nil {
+				return ctx, err
+			}
+			verify = mode
+			continue
+		}
+		if value, ok := strings.CutPrefix(arg, "signer-cert:"); ok {
+			certs, err := readCerts(value)
+			if err != nil {
+				return ctx, err
+			}
+			if len(certs) == 0 {
+				return ctx, fmt.Errorf("no certificates in %s", value)
+			}
+			anchors = append(anchors, certs...)
+			continue
+		}
+		filter = arg
+	}
+	if verify == SignatureEnforce && len(anchors) == 0 {
+		return ctx, fmt.Errorf("verify:enforce needs the certificates of the root TSL in signer-cert:")
+	}
+	if filter != "" {
 		pl.Logger.Debug("TSL filter provided", logging.F("filter", filter))
 		// Note: Filter implementation will be added in a future update
 	}
@@ -70,9 +150,25 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		logging.F("user-agent", ctx.TSLFetchOptions.UserAgent),
 		logging.F("timeout", ctx.TSLFetchOptions.Timeout),
 		logging.F("max-depth", ctx.TSLFetchOptions.MaxDereferenceDepth),
-		logging.F("accept", ctx.TSLFetchOptions.AcceptHeaders))
+		logging.F("accept", ctx.TSLFetchOptions.AcceptHeaders),
+		logging.F("freshness", freshness),
+		logging.F("verify", verify))
 
-	tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(url, *ctx.TSLFetchOptions)
+	// Capture the raw documents: the etsi119612 model drops extension content
+	fetchOptions, capture := captureFetchOptions(*ctx.TSLFetchOptions, freshness, maxDownloadSize(ctx))
+	capture.owner = ctx.locks
+	if isInlineSource(url) {
+		capture.inline = url
+	}
+	tsls, err := fetchTSLWithReferences(pl, ctx, url, fetchOptions, capture, maxReferencedTSLs(ctx), newSignatureVerifier(verify, anchors))
+	capture.recordFetches(ctx, url, err)
+	checkClock(pl, ctx, capture.dates)
+	if err == nil {
+		capture.retainDocuments(ctx, tsls)
+	}
+	if store := evidenceStore(ctx); store != nil {
+		capture.archiveEvidence(pl, ctx, store)
+	}
 	if err != nil {
 		return ctx, fmt.Errorf("failed to load TSL from %s: %w", url, err)
 	}
@@ -83,7 +179,7 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 
 	// Apply filters if any are defined
 	originalCount := len(tsls)
-	tsls = FilterTSLs(ctx, tsls)
+	tsls = filterTSLs(pl, ctx, tsls)
 	if len(tsls) < originalCount {
 		pl.Logger.Info("Applied TSL filters",
 			logging.F("original_count", originalCount),
@@ -95,6 +191,22 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 		return ctx, fmt.Errorf("no TSLs passed the filter criteria")
 	}
 
+	// Parse the extensions that the TSL model does not retain
+	for _, tsl := range tsls {
+		data, err := capture.body(tsl.Source)
+		if err == nil {
+			err = ctx.AttachServiceExtensions(tsl, data)
+		}
+		if err == nil {
+			err = ctx.AttachExtensions(tsl, data)
+		}
+		if err != nil {
+			pl.Logger.Warn("Failed to read TSL extensions",
+				logging.F("url", tsl.Source),
+				logging.F("error", err.Error()))
+		}
+	}
+
 	// Build a TSL tree from the loaded TSLs and add it to the stack of trees
 	ctx.EnsureTSLTrees()
 
@@ -160,6 +272,8 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 			logging.F("providers", providerCount),
 			logging.F("services", serviceCount),
 			logging.F("referenced", i > 0))
+		checkTSLDates(pl, ctx, tsl, time.Now())
+		checkTSLTerritory(pl, ctx, tsl)
 	}
 
 	pl.Logger.Info("Loaded TSLs",
@@ -172,3 +286,232 @@ func LoadTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 
 	return ctx, nil
 }
+
+// captureTransport is an http.RoundTripper that keeps the body of every
+// successful response, so that LoadTSL can read the parts of TSL documents that
+// the etsi119612 model does not retain. It also records the outcome of every
+// request for the source status report.
+type captureTransport struct {
+	base      http.RoundTripper
+	freshness FreshnessProbe // How to check cached documents before downloading them
+	cache     *fetchCache    // Documents downloaded with freshness probing
+	maxSize   int64          // Largest document downloaded
+	owner     *lockOwner     // Run the source locks are taken for
+	inline    string         // Inline source of the load step, the only one served without a fetch (see isInlineSource)
+	mu        sync.Mutex
+	bodies    map[string][]byte // Response bodies by request URL
+	redirects map[string]string // Redirect targets by request URL
+	fetches   []SourceFetch     // Every request, in the order made
+	documents []fetchedDocument // Every document received, in the order fetched
+	dates     []serverDate      // Date headers of the responses downloaded
+}
+
+// fetchedDocument is a document received by a captureTransport, kept for the
+// evidence store.
+type fetchedDocument struct {
+	fetch  SourceFetch
+	header http.Header
+	body   []byte
+}
+
+// captureFetchOptions returns a copy of opts whose HTTP client records response
+// bodies in the returned captureTransport. Unless freshness is FreshnessOff,
+// documents are cached and probed with freshness before being downloaded again.
+// Documents larger than maxSize are rejected.
+func captureFetchOptions(opts etsi119612.TSLFetchOptions, freshness FreshnessProbe, maxSize int64) (etsi119612.TSLFetchOptions, *captureTransport) {
+	capture := &captureTransport{
+		base:      http.DefaultTransport,
+		freshness: freshness,
+		maxSize:   maxSize,
+		cache:     globalFetchCache,
+		bodies:    make(map[string][]byte),
+		redirects: make(map[string]string),
+	}
+
+	client := &http.Client{Timeout: opts.Timeout}
+	if opts.Client != nil {
+		c := *opts.Client
+		client = &c
+		if client.Transport != nil {
+			capture.base = client.Transport
+		}
+	}
+	client.Transport = capture
+	opts.Client = client
+	return opts, capture
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	source := req.URL.String()
+	if isInlineSource(source) {
+		if source != t.inline {
+			// Only the load step's own source may be inline: a pointer of a
+			// fetched TSL must not read the standard input or inject an
+			// unfetched document
+			err := fmt.Errorf("inline source %s is only allowed as the source of a load step", inlineSourceName(source))
+			t.record(SourceFetch{URL: source, Time: time.Now(), Error: err.Error()})
+			return nil, err
+		}
+		return t.serveInline(req)
+	}
+	release, shared, err := pipelineLocks.acquire(t.owner, LockSource, source)
+	if err != nil {
+		t.record(SourceFetch{URL: source, Time: time.Now(), Error: err.Error()})
+		return nil, err
+	}
+	if doc, ok := shared.(*sharedDocument); ok && t.canReuse(req, doc) {
+		release(doc)
+		return t.reuse(req, doc), nil
+	}
+	resp, doc, err := t.download(req)
+	release(doc)
+	return resp, err
+}
+
+// download sends req and records its outcome. It returns the document
+// received, for concurrent runs waiting for the same URL, or nil if req did
+// not receive one.
+func (t *captureTransport) download(req *http.Request) (*http.Response, *sharedDocument, error) {
+	source := req.URL.String()
+	start := time.Now()
+	resp, cached, err := t.fetch(req)
+	fetch := newSourceFetch(source, start, resp, err)
+	fetch.Cached = cached
+	if err != nil {
+		t.record(fetch)
+		return resp, nil, err
+	}
+	if date, ok := responseDate(source, resp, time.Now()); ok && !cached {
+		t.mu.Lock()
+		t.dates = append(t.dates, date)
+		t.mu.Unlock()
+	}
+
+	if loc, err := resp.Location(); err == nil {
+		t.record(fetch)
+		t.mu.Lock()
+		t.redirects[source] = loc.String()
+		t.mu.Unlock()
+		return resp, nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.record(fetch)
+		return resp, nil, nil
+	}
+
+	var body []byte
+	if cached {
+		body, err = io.ReadAll(resp.Body)
+	} else {
+		body, err = readDownload(resp, t.maxSize)
+		fetch.Bytes = int64(len(body))
+	}
+	resp.Body.Close()
+	if err != nil {
+		fetch.Error = err.Error()
+		t.record(fetch)
+		return nil, nil, err
+	}
+	t.record(fetch)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if !cached && t.freshness != FreshnessOff && t.freshness != "" {
+		t.cache.store(source, resp, body)
+	}
+
+	t.mu.Lock()
+	t.bodies[source] = body
+	t.documents = append(t.documents, fetchedDocument{fetch: fetch, header: resp.Header.Clone(), body: body})
+	t.mu.Unlock()
+	return resp, newSharedDocument(req, resp, body), nil
+}
+
+// archiveEvidence archives the documents received so far in store. Failing
+// to archive is reported as a warning, not as a failure of the load.
+func (t *captureTransport) archiveEvidence(pl *Pipeline, ctx *Context, store *EvidenceStore) {
+	t.mu.Lock()
+	documents := t.documents
+	t.documents = nil
+	t.mu.Unlock()
+
+	for _, doc := range documents {
+		record, err := store.Archive(doc.fetch.URL, doc.fetch.Time, doc.fetch.StatusCode, doc.header, doc.body, doc.fetch.Cached)
+		if err != nil {
+			pl.Logger.Warn("Failed to archive TSL evidence",
+				logging.F("url", doc.fetch.URL),
+				logging.F("error", err.Error()))
+			ctx.AddWarning("failed to archive evidence for %s: %v", doc.fetch.URL, err)
+			continue
+		}
+		pl.Logger.Debug("Archived TSL evidence",
+			logging.F("url", record.URL),
+			logging.F("sha256", record.Digest),
+			logging.F("signature_valid", record.Signature.Valid))
+	}
+
+	if removed, objects, err := store.Prune(time.Now()); err != nil {
+		pl.Logger.Warn("Failed to prune TSL evidence", logging.F("error", err.Error()))
+	} else if removed > 0 {
+		pl.Logger.Info("Pruned TSL evidence",
+			logging.F("records", removed),
+			logging.F("documents", objects))
+	}
+}
+
+// record records a request for the source status report.
+func (t *captureTransport) record(fetch SourceFetch) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fetches = append(t.fetches, fetch)
+}
+
+// recordFetches records the requests made while loading the TSL at url on ctx.
+// If loading failed, the error is recorded for url, so that a document that
+// was fetched but could not be parsed is reported as failing too. File URLs
+// and inline sources are not recorded.
+func (t *captureTransport) recordFetches(ctx *Context, url string, loadErr error) {
+	t.mu.Lock()
+	fetches := t.fetches
+	t.fetches = nil
+	t.mu.Unlock()
+
+	if loadErr != nil && !strings.HasPrefix(url, "file://") && !isInlineSource(url) {
+		last := -1
+		for i, fetch := range fetches {
+			if fetch.URL == url {
+				last = i
+			}
+		}
+		if last >= 0 && !fetches[last].Failed() {
+			fetches[last].Error = loadErr.Error()
+		} else if last < 0 {
+			fetches = append(fetches, SourceFetch{URL: url, Time: time.Now(), Error: loadErr.Error()})
+		}
+	}
+
+	for _, fetch := range fetches {
+		ctx.RecordSourceFetch(fetch)
+	}
+}
+
+// body returns the document a TSL was parsed from, following redirects. File
+// URLs are read from disk since they are not fetched over HTTP.
+func (t *captureTransport) body(source string) ([]byte, error) {
+	if path, ok := strings.CutPrefix(source, "file://"); ok {
+		return os.ReadFile(path)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for range 10 {
+		if body, ok := t.bodies[source]; ok {
+			return body, nil
+		}
+		next, ok := t.redirects[source]
+		if !ok {
+			break
+		}
+		source = next
+	}
+	return nil, fmt.Errorf("no document captured for %s", source)
+}
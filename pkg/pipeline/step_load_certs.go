@@ -0,0 +1,329 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/validation"
+)
+
+// Defaults of the synthetic TSL built by the load-certs step.
+const (
+	DefaultImportProvider    = "Imported certificates"
+	DefaultImportServiceType = "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"
+	importTSLType            = "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric"
+)
+
+// ImportedTSLSourcePrefix prefixes the Source of TSLs built by load-certs, so
+// that they are told apart from TSLs loaded from their source.
+const ImportedTSLSourcePrefix = "certs:"
+
+// maxCertBundleSize limits the size of a certificate bundle fetched by URL.
+const maxCertBundleSize = 10 << 20
+
+// certFileExtensions are the extensions of the files read from a directory.
+var certFileExtensions = []string{".pem", ".crt", ".cer", ".der"}
+
+// loadCertsOptions are the options of a load-certs step.
+type loadCertsOptions struct {
+	provider    string
+	operator    string
+	serviceType string
+	status      string
+	territory   string
+}
+
+// parseLoadCertsOptions parses the "key:value" arguments of a load-certs step.
+func parseLoadCertsOptions(args []string) (*loadCertsOptions, error) {
+	opts := &loadCertsOptions{
+		provider:    DefaultImportProvider,
+		serviceType: DefaultImportServiceType,
+		status:      etsi119612.ServiceStatusGranted,
+	}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, ":")
+		if !ok || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid load-certs option %q: expected key:value", arg)
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "provider":
+			opts.provider = value
+		case "operator":
+			opts.operator = value
+		case "service-type":
+			opts.serviceType = value
+		case "status":
+			opts.status = value
+		case "territory":
+			territory, err := validation.NormalizeTerritory(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid load-certs option %q: %w", arg, err)
+			}
+			opts.territory = territory
+		default:
+			return nil, fmt.Errorf("unknown load-certs option %q", key)
+		}
+	}
+	if opts.operator == "" {
+		opts.operator = opts.provider
+	}
+	return opts, nil
+}
+
+// LoadCerts is a pipeline step that imports trust anchors from sources other
+// than TSLs, such as a directory of PEM files or the Mozilla CA bundle. The
+// certificates are wrapped into a synthetic TSL with one provider, listing
+// each certificate as a service, so that they take part in selection,
+// publication and trust decisions like the services of real TSLs.
+//
+// Parameters:
+//   - pl: The pipeline instance for logging
+//   - ctx: The pipeline context to add the synthetic TSL to
+//   - args: String arguments, where:
+//   - args[0]: Required - a directory, a file, or an http(s) URL. A directory
+//     is read for .pem, .crt, .cer and .der files; a file or URL may hold
+//     concatenated PEM certificates or a single DER certificate
+//   - "provider:NAME": Name of the provider (default "Imported certificates")
+//   - "operator:NAME": Name of the scheme operator (default the provider name)
+//   - "service-type:URI": Service type of the services (default CA/PKC)
+//   - "status:URI": Status of the services (default granted)
+//   - "territory:CC": Scheme territory of the TSL (default none)
+//
+// Returns:
+//   - *Context: Updated context with the synthetic TSL added as a TSL tree
+//   - error: Non-nil if the source cannot be read or holds no certificates
+//
+// Services are named after the subject common name of their certificate.
+// Duplicate certificates are listed once. The TSL's Source is the source
+// prefixed with "certs:". URLs are fetched with the timeout and User-Agent of
+// set-fetch-options, and their fetches are reported in the source status.
+//
+// Example usage in pipeline configuration:
+//   - load-certs: ["/etc/ssl/certs", "provider:System trust store"]
+//   - load-certs: ["https://curl.se/ca/cacert.pem", "provider:Mozilla", "territory:EU"]
+//   - select: ["service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"]
+func LoadCerts(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	if len(args) < 1 {
+		return ctx, fmt.Errorf("missing argument: directory, file or URL of certificates")
+	}
+	source := args[0]
+	opts, err := parseLoadCertsOptions(args[1:])
+	if err != nil {
+		return ctx, err
+	}
+
+	var certs []*x509.Certificate
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		certs, err = fetchCerts(ctx, source)
+	} else {
+		certs, err = readCerts(source)
+	}
+	if err != nil {
+		return ctx, err
+	}
+	if len(certs) == 0 {
+		return ctx, fmt.Errorf("no certificates found in %s", source)
+	}
+
+	tsl := certificateTSL(certs, opts, time.Now())
+	tsl.Source = ImportedTSLSourcePrefix + source
+	ctx.AddTSLTree(NewTSLTree(tsl))
+
+	pl.Logger.Info("Loaded certificates",
+		logging.F("source", source),
+		logging.F("provider", opts.provider),
+		logging.F("certificates", len(certs)))
+
+	return ctx, nil
+}
+
+// readCerts reads the certificates of a file, or of the certificate files of
+// a directory.
+func readCerts(path string) ([]*x509.Certificate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates from %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificates from %s: %w", path, err)
+		}
+		return parseCerts(data, path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate directory %s: %w", path, err)
+	}
+	var certs []*x509.Certificate
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains(certFileExtensions, strings.ToLower(filepath.Ext(entry.Name()))) {
+			continue
+		}
+		file := filepath.Join(path, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificates from %s: %w", file, err)
+		}
+		fileCerts, err := parseCerts(data, file)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, fileCerts...)
+	}
+	return certs, nil
+}
+
+// fetchCerts fetches the certificates of a bundle by URL, recording the fetch
+// in the source status of ctx.
+func fetchCerts(ctx *Context, url string) ([]*x509.Certificate, error) {
+	if err := validation.ValidateURL(url, validation.TSLURLOptions()); err != nil {
+		return nil, fmt.Errorf("invalid certificate bundle URL: %w", err)
+	}
+	fetchOpts := ctx.EnsureTSLFetchOptions().TSLFetchOptions
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate bundle URL: %w", err)
+	}
+	if fetchOpts.UserAgent != "" {
+		req.Header.Set("User-Agent", fetchOpts.UserAgent)
+	}
+	client := fetchOpts.Client
+	if client == nil {
+		client = &http.Client{Timeout: fetchOpts.Timeout}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	fetch := newSourceFetch(url, start, resp, err)
+	if err != nil {
+		ctx.RecordSourceFetch(fetch)
+		return nil, fmt.Errorf("failed to fetch certificates from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if fetch.Failed() {
+		ctx.RecordSourceFetch(fetch)
+		return nil, fmt.Errorf("failed to fetch certificates from %s: %s", url, resp.Status)
+	}
+
+	data, err := readDownload(resp, maxCertBundleSize)
+	fetch.Bytes = int64(len(data))
+	if err != nil {
+		fetch.Error = err.Error()
+		ctx.RecordSourceFetch(fetch)
+		return nil, fmt.Errorf("failed to fetch certificates from %s: %w", url, err)
+	}
+	ctx.RecordSourceFetch(fetch)
+	return parseCerts(data, url)
+}
+
+// parseCerts parses the certificates of data, which holds PEM certificates or
+// a single DER certificate. PEM blocks of other types are skipped.
+func parseCerts(data []byte, name string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in %s: %w", name, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 || len(data) == 0 {
+		return certs, nil
+	}
+
+	// Not PEM: a DER certificate, or a file without certificates
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		if strings.Contains(string(data), "-----BEGIN") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to parse certificate in %s: %w", name, err)
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+// certificateTSL returns a TSL with one provider, listing each distinct
+// certificate as a service, issued at now.
+func certificateTSL(certs []*x509.Certificate, opts *loadCertsOptions, now time.Time) *etsi119612.TSL {
+	provider := &etsi119612.TSPType{
+		TslTSPInformation: &etsi119612.TSPInformationType{
+			TSPName: importNames(opts.provider),
+		},
+		TslTSPServices: &etsi119612.TSPServicesListType{},
+	}
+
+	seen := make(map[string]bool)
+	for _, cert := range certs {
+		fp := Fingerprint(cert)
+		if seen[fp] {
+			continue
+		}
+		seen[fp] = true
+
+		name := cert.Subject.CommonName
+		if name == "" {
+			name = cert.Subject.String()
+		}
+		provider.TslTSPServices.TslTSPService = append(provider.TslTSPServices.TslTSPService, &etsi119612.TSPServiceType{
+			TslServiceInformation: &etsi119612.TSPServiceInformationType{
+				TslServiceTypeIdentifier: opts.serviceType,
+				TslServiceStatus:         opts.status,
+				ServiceName:              importNames(name),
+				TslServiceDigitalIdentity: &etsi119612.DigitalIdentityListType{
+					DigitalId: []*etsi119612.DigitalIdentityType{
+						{X509Certificate: base64.StdEncoding.EncodeToString(cert.Raw)},
+					},
+				},
+			},
+		})
+	}
+
+	return &etsi119612.TSL{
+		StatusList: etsi119612.TrustStatusListType{
+			TSLTagAttr: "http://uri.etsi.org/19612/TSLTag",
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
+				TSLVersionIdentifier:  tslVersionIdentifier,
+				TSLSequenceNumber:     1,
+				TslTSLType:            importTSLType,
+				TslSchemeOperatorName: importNames(opts.operator),
+				TslSchemeTerritory:    opts.territory,
+				ListIssueDateTime:     now.UTC().Format(time.RFC3339),
+			},
+			TslTrustServiceProviderList: &etsi119612.TrustServiceProviderListType{
+				TslTrustServiceProvider: []*etsi119612.TSPType{provider},
+			},
+		},
+	}
+}
+
+// importNames returns an English name list holding name.
+func importNames(name string) *etsi119612.InternationalNamesType {
+	lang := etsi119612.Lang("en")
+	value := etsi119612.NonEmptyNormalizedString(name)
+	return &etsi119612.InternationalNamesType{
+		Name: []*etsi119612.MultiLangNormStringType{{XmlLangAttr: &lang, NonEmptyNormalizedString: &value}},
+	}
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCerts_Directory(t *testing.T) {
+	ca1, err := testutil.NewCA("Root One")
+	require.NoError(t, err)
+	ca2, err := testutil.NewCA("Root Two")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca2, "leaf")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "one.pem"), ca1.CertPEM(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "two.der"), ca2.Certificate.Raw, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dup.crt"), ca1.CertPEM(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0644))
+
+	pl := createTestPipeline(nil)
+	ctx, err := LoadCerts(pl, NewContext(), dir,
+		"provider:System trust store", "territory:se", "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC")
+	require.NoError(t, err)
+	require.Equal(t, 1, ctx.TSLTrees.Size())
+
+	tsl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	assert.Equal(t, ImportedTSLSourcePrefix+dir, tsl.Source)
+	assert.Equal(t, "SE", tsl.StatusList.TslSchemeInformation.TslSchemeTerritory)
+	assert.Equal(t, "System trust store", tsl.SchemeOperatorName())
+	require.Equal(t, 1, tsl.NumberOfTrustServiceProviders())
+
+	// Duplicates are listed once
+	services := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService
+	require.Len(t, services, 2)
+	for _, service := range services {
+		assert.Equal(t, "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", service.TslServiceInformation.TslServiceTypeIdentifier)
+		assert.Equal(t, etsi119612.ServiceStatusGranted, service.TslServiceInformation.TslServiceStatus)
+	}
+
+	// The imported anchors take part in selection like TSL services
+	ctx, err = SelectCertPool(pl, ctx)
+	require.NoError(t, err)
+	_, err = leaf.Certificate.Verify(x509.VerifyOptions{Roots: ctx.CertPool})
+	assert.NoError(t, err)
+}
+
+func TestLoadCerts_URL(t *testing.T) {
+	ca1, err := testutil.NewCA("Bundle One")
+	require.NoError(t, err)
+	ca2, err := testutil.NewCA("Bundle Two")
+	require.NoError(t, err)
+	bundle := append(ca1.CertPEM(), ca2.CertPEM()...)
+
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cacert.pem" {
+			http.NotFound(w, r)
+			return
+		}
+		userAgent = r.UserAgent()
+		_, _ = w.Write(bundle)
+	}))
+	defer server.Close()
+
+	pl := createTestPipeline(nil)
+	ctx, err := SetFetchOptions(pl, NewContext(), "user-agent:bundle-test")
+	require.NoError(t, err)
+	ctx, err = LoadCerts(pl, ctx, server.URL+"/cacert.pem", "provider:Mozilla")
+	require.NoError(t, err)
+	assert.Equal(t, "bundle-test", userAgent)
+
+	tsl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	services := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService
+	require.Len(t, services, 2)
+	assert.Equal(t, DefaultImportServiceType, services[0].TslServiceInformation.TslServiceTypeIdentifier)
+
+	_, err = LoadCerts(pl, ctx, server.URL+"/missing.pem")
+	assert.Error(t, err)
+	fetches := ctx.takeSourceFetches()
+	require.NotEmpty(t, fetches)
+	assert.True(t, fetches[len(fetches)-1].Failed())
+}
+
+func TestLoadCerts_Errors(t *testing.T) {
+	pl := createTestPipeline(nil)
+
+	_, err := LoadCerts(pl, NewContext())
+	assert.Error(t, err)
+
+	_, err = LoadCerts(pl, NewContext(), filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	// An empty directory has no certificates
+	_, err = LoadCerts(pl, NewContext(), t.TempDir())
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("garbage"), 0644))
+	_, err = LoadCerts(pl, NewContext(), dir)
+	assert.Error(t, err)
+
+	_, err = LoadCerts(pl, NewContext(), dir, "color:blue")
+	assert.Error(t, err)
+	_, err = LoadCerts(pl, NewContext(), dir, "provider:")
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"embed"
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+//go:embed mockdata/*.xml mockdata/certs/*.pem
+var mockData embed.FS
+
+// MockTSLSource is the Source recorded on TSLs loaded by the mock step.
+const MockTSLSource = "mock:mock-tsl.xml"
+
+// MockFixtures returns the bundled mock fixture set. The root contains
+// mock-tsl.xml and a certs directory with PEM certificates:
+//
+//   - granted-ca.pem, withdrawn-ca.pem: the CAs listed in the mock TSL
+//   - unknown-ca.pem: a CA that is not listed in the mock TSL
+//   - trusted-leaf.pem: issued by the granted CA; trusted
+//   - expired-leaf.pem: issued by the granted CA but expired; not trusted
+//   - wrong-eku-leaf.pem: issued by the granted CA for email protection only; not trusted
+//   - withdrawn-leaf.pem: issued by the withdrawn CA; not trusted when selecting granted services
+//   - unknown-leaf.pem: issued by the unknown CA; not trusted
+//
+// Client developers can send these certificates as x5c chains to a server
+// running the mock pipeline to exercise both success and failure paths.
+func MockFixtures() fs.FS {
+	sub, err := fs.Sub(mockData, "mockdata")
+	if err != nil {
+		// The embedded directory is fixed at build time
+		panic(fmt.Sprintf("mock fixtures missing: %v", err))
+	}
+	return sub
+}
+
+// MockPipeline returns a pipeline that loads the bundled mock TSL and builds a
+// certificate pool from its granted services. It is used by the --mock
+// command-line mode so that the server answers deterministic decisions without
+// network access.
+func MockPipeline(logger logging.Logger) *Pipeline {
+	return &Pipeline{
+		Pipes: []Pipe{
+			{MethodName: "mock"},
+			{MethodName: "select", MethodArguments: []string{"status:" + etsi119612.ServiceStatusGranted}},
+		},
+		Logger: logger,
+	}
+}
+
+// MockTSL is a pipeline step that loads the static fixture TSL set bundled with
+// go-trust instead of fetching TSLs over the network. It is intended for client
+// and wallet development and for tests, where trust decisions must be
+// deterministic and available offline. See MockFixtures for the certificates
+// that the fixture TSL trusts and the ones it deliberately rejects.
+//
+// Parameters:
+//   - pl: The pipeline instance for logging
+//   - ctx: The pipeline context to update with the fixture TSL
+//   - args: Not used
+//
+// Returns:
+//   - *Context: Updated context with the fixture TSL added as a TSL tree
+//   - error: Non-nil if the embedded fixture cannot be parsed
+//
+// Example usage in pipeline configuration:
+//   - mock:
+//   - select:
+//   - status:https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/
+func MockTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
+	data, err := fs.ReadFile(mockData, path.Join("mockdata", "mock-tsl.xml"))
+	if err != nil {
+		return ctx, fmt.Errorf("failed to read mock TSL: %w", err)
+	}
+
+	tsl := &etsi119612.TSL{Source: MockTSLSource}
+	if err := xml.Unmarshal(data, &tsl.StatusList); err != nil {
+		return ctx, fmt.Errorf("failed to parse mock TSL: %w", err)
+	}
+	tsl.CleanCerts()
+	if err := ctx.AttachExtensions(tsl, data); err != nil {
+		return ctx, fmt.Errorf("failed to parse mock TSL: %w", err)
+	}
+
+	ctx.AddTSLTree(NewTSLTree(tsl))
+
+	pl.Logger.Info("Loaded mock TSL",
+		logging.F("source", MockTSLSource),
+		logging.F("providers", tsl.NumberOfTrustServiceProviders()))
+
+	return ctx, nil
+}
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/fs"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readMockCert(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+	data, err := fs.ReadFile(MockFixtures(), "certs/"+name+".pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestMockTSL(t *testing.T) {
+	pl := createTestPipeline(nil)
+
+	ctx, err := MockTSL(pl, NewContext())
+	require.NoError(t, err)
+	require.Equal(t, 1, ctx.TSLs.Size())
+	require.Equal(t, 1, ctx.TSLTrees.Size())
+
+	tsl, ok := ctx.TSLs.Peek()
+	require.True(t, ok)
+	assert.Equal(t, MockTSLSource, tsl.Source)
+	assert.Equal(t, "ZZ", tsl.StatusList.TslSchemeInformation.TslSchemeTerritory)
+	assert.Equal(t, "Go-Trust Mock Operator", tsl.SchemeOperatorName())
+	assert.Equal(t, 1, tsl.NumberOfTrustServiceProviders())
+}
+
+func TestMockPipeline_Decisions(t *testing.T) {
+	ctx, err := MockPipeline(logging.NewLogger(logging.DebugLevel)).Process(NewContext())
+	require.NoError(t, err)
+	require.NotNil(t, ctx.CertPool)
+
+	tests := []struct {
+		leaf    string
+		issuer  string
+		trusted bool
+	}{
+		{"trusted-leaf", "granted-ca", true},
+		{"expired-leaf", "granted-ca", false},
+		{"wrong-eku-leaf", "granted-ca", false},
+		{"withdrawn-leaf", "withdrawn-ca", false},
+		{"unknown-leaf", "unknown-ca", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.leaf, func(t *testing.T) {
+			leaf := readMockCert(t, tt.leaf)
+			assert.Equal(t, readMockCert(t, tt.issuer).Subject.String(), leaf.Issuer.String())
+
+			_, err := leaf.Verify(x509.VerifyOptions{Roots: ctx.CertPool})
+			if tt.trusted {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestMockStepRegistered(t *testing.T) {
+	fn, ok := GetFunctionByName("mock")
+	require.True(t, ok)
+	assert.NotNil(t, fn)
+}
@@ -1,11 +1,13 @@
 package pipeline
 
 import (
-	"encoding/xml"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
 	"github.com/SUNET/go-trust/pkg/dsig"
@@ -30,44 +32,228 @@ import (
 // - If a distribution point is specified, the last part of the URI is used as the file name
 // - If no distribution point is found, a default name pattern "tsl-{sequenceNumber}.xml" is used
 //
+// The last part of the URI is taken from its path, ignoring the query and
+// fragment, percent-decoded, and characters that are not safe in file names on
+// Linux, macOS or Windows are replaced by "_".
+//
 // For each TSL, the following steps are performed:
 // 1. Extract distribution point information, if available
 // 2. Determine the file name based on the distribution point or use a default
 // 3. Serialize the TSL to XML
 // 4. Write the XML to a file in the specified directory
 //
+// If two TSLs would be published to the same file, compared case-insensitively,
+// the later one is written to a numbered file ("se-2.xml") and a warning is logged.
+//
+// Signed XML is verified before it is written: if the signature does not
+// validate against the certificate it carries, for example because an HSM key
+// does not match the configured certificate, the step fails and the file is
+// not written. The same applies to a signed bundle manifest.
+//
+// The "sidecars" option additionally writes, next to every XML file, a .sha256
+// file in sha256sum format and a .meta JSON file with the TSL sequence number,
+// issue and next update dates, so that mirrors can check for changes cheaply.
+// The "bundle" option additionally writes tsl-bundle-YYYYMMDD.zip to the
+// output directory once all TSLs are published. The archive holds every file
+// below the output directory, including HTML and index pages written there by
+// earlier transform and generate_index steps, and a manifest.xml listing the
+// SHA-256 of each file. The manifest is signed with the configured signer, so
+// the bundle can be carried to air-gapped relying parties and verified there.
+// The "dry-run" option writes nothing. Instead it compares the unsigned XML of
+// every TSL with the file currently published at the same path, ignoring the
+// signature, sequence number and issue and next update dates of the published
+// file, and logs the differences: sizes and added, removed and changed trust
+// services. If any file would be added or modified the step fails with a
+// *PublishDiffError, so a CI job can hold back publication for human approval.
+// The "atomic" option makes the output directory a symlink to the current
+// generation in <dir>.generations/. Each run writes a new generation, starting
+// from a copy of the current one, and repoints the symlink only once all files
+// are written, so consumers never read half-written XML. The previous
+// generations stay available for rollback; "atomic:N" retains N generations
+// (default 3). An existing output directory that is not such a symlink must be
+// moved aside first.
+// The "name-template:" option names the files with a Go text/template
+// executed with PublishNameData, e.g. "name-template:{{.Territory}}-{{.Sequence}}.xml",
+// instead of after the distribution point. The result must be a plain file name.
+// Files are written with mode 0644 and directories with 0755, less the umask.
+// The "file-mode:" and "dir-mode:" options set other octal modes, such as
+// "file-mode:0640", exactly, regardless of the umask, and "group:" sets the
+// group, by name or id, of everything the step writes, for output served from
+// a shared web root.
+// The "api" option additionally writes a static JSON API below the output
+// directory once all TSLs are published, for single-page applications served
+// from the published site: api/index.json lists the published TSLs like the
+// /tsls endpoint, api/{territory}.json the TSLs and trust service providers of
+// a territory, and api/providers/{id}.json the services and certificates of a
+// provider, as on the pages of generate-provider-pages. The api directory is
+// rewritten on every run and is included in the bundle.
+// TSLs are serialized deterministically (see MarshalTSL), so the same TSL is
+// always written as the same bytes. The "reproducible" option also keeps a
+// published file, and its sidecars, whose content is unchanged apart from its
+// sequence number, dates and signature, instead of reissuing it: mirrors and
+// git-backed publication see no change unless the data changed. The file is
+// still reissued once half of its next update period has passed, or when the
+// step starts or stops signing; a new signing key alone does not reissue it.
+// The "c14n" option writes the XML, after signing, in Exclusive XML
+// Canonicalization 1.0 form, without XML declaration.
+// The second argument "ephemeral" signs with a self-signed ECDSA P-256 key
+// generated when the process first needs it, and kept in memory only (see
+// dsig.NewEphemeralSigner): CI and demo environments exercise the signed
+// publish path without key files or an HSM. It is not for production, as no
+// relying party can trust the key in advance, and is logged as such when
+// the signers are checked at startup.
+// The "next-cert:" and "next-key:" options configure the next signing key
+// of a key rollover: a certificate and key file, or, with a PKCS#11 signer,
+// the labels of a key and certificate on the same token, whose ID
+// "next-key-id:" sets if it differs. "rollover:FROM/UNTIL" sets the dual
+// signing window, with dates or RFC 3339 times: before it, TSLs are signed
+// with the current key; in it, a copy of every TSL signed with the next key is
+// also written to the same path below NextSignedDir; from UNTIL on, TSLs are
+// signed with the next key and NextSignedDir is removed. Without a window the
+// copies are always written.
+// The output directory may be a Go text/template executed with
+// OutputPathData: the territory and sequence number of the first TSL
+// published, the date of the run and the pipeline variables set by vars
+// steps, e.g. "/srv/tsl/{{.Territory}}/{{.Sequence}}". Directories are
+// created as needed. The "latest" option then atomically points a symlink
+// named latest next to the output directory to it once everything is
+// written, and "latest:PATH" a symlink at PATH, which may be a template too.
+// The output directory is recorded as the publish_dir pipeline variable.
+// Options may appear anywhere after the directory path.
+//
 // Example usage in pipeline configuration:
 //   - publish:/path/to/output/dir  # Publish all TSLs to the specified directory
 //   - publish:["/path/to/output/dir", "/path/to/cert.pem", "/path/to/key.pem"]  # With XML-DSIG signatures
+//   - publish:["/path/to/output/dir", "sidecars"]  # With .sha256 and .meta sidecar files
+//   - publish:["/path/to/output/dir", "ephemeral"]  # Signed with a throwaway key, not for production
+//   - publish:["/path/to/output/dir", "/path/to/cert.pem", "/path/to/key.pem", "bundle"]  # With a signed zip bundle
+//   - publish:["/path/to/output/dir", "api"]  # With a static JSON API in /path/to/output/dir/api
+//   - publish:["/path/to/output/dir", "dry-run"]  # Report what would change, writing nothing
+//   - publish:["/path/to/output/dir", "atomic:5"]  # Swap in a new generation, keeping 5
+//   - publish:["/path/to/output/dir", "reproducible", "c14n"]  # Rewrite files only when their content changes, in canonical form
+//   - publish:["/path/to/output/dir", "name-template:{{.Territory}}-{{.Sequence}}.xml"]  # Name files after territory and sequence number
+//   - publish:["/srv/tsl/{{.Territory}}/{{.Sequence}}", "latest"]  # One directory per sequence number, /srv/tsl/SE/latest pointing to the newest
+//   - publish:["/path/to/output/dir", "file-mode:0640", "dir-mode:0750", "group:www-data"]  # Readable by the web server group only
+//   - publish:["/path/to/output/dir", "/path/to/cert.pem", "/path/to/key.pem", "next-cert:/path/to/next.pem", "next-key:/path/to/next.key", "rollover:2026-12-01/2027-01-15"]  # Key rollover
 func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	if len(args) < 1 {
 		return ctx, fmt.Errorf("missing argument: directory path")
 	}
 
-	dirPath := args[0]
+	args, opts, err := parsePublishOptions(args)
+	if err != nil {
+		return ctx, err
+	}
+	now := time.Now()
+	first := firstPublishedTSL(ctx)
+	dirPath, err := resolveOutputPath(ctx, args[0], first, now)
+	if err != nil {
+		return ctx, err
+	}
 
 	// Validate output directory before processing
 	if err := validation.ValidateOutputDirectory(dirPath); err != nil {
 		return ctx, fmt.Errorf("invalid output directory: %w", err)
 	}
+	unlock, err := ctx.lockOutput(dirPath)
+	if err != nil {
+		return ctx, err
+	}
+	defer unlock()
+
+	signer, err := publishSigner(args)
+	if err != nil {
+		return ctx, err
+	}
+	if err := applyRollover(pl, args, opts, signer, now); err != nil {
+		return ctx, err
+	}
+
+	if opts.atomic && opts.writes() {
+		err = publishAtomic(pl, ctx, dirPath, args, opts)
+	} else {
+		err = publishTo(pl, ctx, dirPath, args, opts)
+	}
+	if err != nil || !opts.writes() {
+		return ctx, err
+	}
+
+	ctx.SetVar(VarPublishDir, dirPath)
+	if opts.latestLink {
+		link, err := latestLinkPath(ctx, opts.latest, dirPath, first, now)
+		if err != nil {
+			return ctx, err
+		}
+		if err := swapLatest(pl, link, dirPath); err != nil {
+			return ctx, err
+		}
+	}
+	return ctx, nil
+}
+
+// firstPublishedTSL returns the TSL a publish step writes first: the first
+// TSL of the legacy stack or, if that is empty, the root of the first tree.
+func firstPublishedTSL(ctx *Context) *etsi119612.TSL {
+	if ctx.TSLs != nil {
+		for _, tsl := range ctx.TSLs.ToSlice() {
+			if tsl != nil {
+				return tsl
+			}
+		}
+	}
+	if ctx.TSLTrees != nil {
+		for _, tree := range ctx.TSLTrees.ToSlice() {
+			if tree != nil && tree.Root != nil && tree.Root.TSL != nil {
+				return tree.Root.TSL
+			}
+		}
+	}
+	return nil
+}
 
-	// Create a signer if signer configuration is provided
+// publishSigner returns the signer configured by the positional arguments of
+// a publish step, or nil if it does not sign.
+func publishSigner(args []string) (dsig.XMLSigner, error) {
 	var signer dsig.XMLSigner
 
 	// Check if this is a file-based signer (with certificate and key files)
-	if len(args) >= 3 && !strings.HasPrefix(args[1], "pkcs11:") {
+	if signingBackend(args) == SigningBackendFile {
 		// Validate certificate and key file paths
 		if err := validation.ValidateFilePath(args[1]); err != nil {
-			return ctx, fmt.Errorf("invalid certificate path: %w", err)
+			return nil, fmt.Errorf("invalid certificate path: %w", err)
 		}
 		if err := validation.ValidateFilePath(args[2]); err != nil {
-			return ctx, fmt.Errorf("invalid key path: %w", err)
+			return nil, fmt.Errorf("invalid key path: %w", err)
 		}
 		signer = dsig.NewFileSigner(args[1], args[2])
 	}
 
+	// Check if this signs with the ephemeral key of the process
+	if signingBackend(args) == SigningBackendEphemeral {
+		ephemeralSigner, err := ephemeralPublishSigner()
+		if err != nil {
+			return nil, err
+		}
+		signer = ephemeralSigner
+	}
+
+	// Check if this is a remote signing service with the certificate of its key
+	if signingBackend(args) == SigningBackendRemote {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("a remote signer needs the certificate file of its key")
+		}
+		if err := validation.ValidateFilePath(args[2]); err != nil {
+			return nil, fmt.Errorf("invalid certificate path: %w", err)
+		}
+		remoteSigner, err := dsig.NewRemoteSignerFromURI(args[1], args[2])
+		if err != nil {
+			return nil, err
+		}
+		signer = remoteSigner
+	}
+
 	// Check if this is a PKCS#11 signer configuration
-	if len(args) >= 2 && strings.HasPrefix(args[1], "pkcs11:") {
+	if signingBackend(args) == SigningBackendPKCS11 {
 		// This is just a placeholder for how you might parse PKCS#11 configuration
 		// In a real implementation, you would parse the URI and extract module path,
 		// token label, key ID, etc.
@@ -90,17 +276,199 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 			signer = pkcs11Signer
 		}
 	}
+	return signer, nil
+}
+
+// ephemeral holds the signer of "ephemeral" publish steps, generated once
+// per process so that every step and run signs with the same key.
+var ephemeral struct {
+	once   sync.Once
+	signer *dsig.MemorySigner
+	err    error
+}
+
+// ephemeralPublishSigner returns the ephemeral signer of the process,
+// generating it on first use.
+func ephemeralPublishSigner() (*dsig.MemorySigner, error) {
+	ephemeral.once.Do(func() {
+		ephemeral.signer, ephemeral.err = dsig.NewEphemeralSigner()
+	})
+	return ephemeral.signer, ephemeral.err
+}
+
+// Signing backends reported by SigningBackends.
+const (
+	SigningBackendFile      = "file"
+	SigningBackendPKCS11    = "pkcs11"
+	SigningBackendRemote    = "remote"
+	SigningBackendEphemeral = "ephemeral"
+)
+
+// signingBackend returns the signing backend configured by the positional
+// arguments of a publish step, or "" if it does not sign.
+func signingBackend(args []string) string {
+	switch {
+	case len(args) == 2 && args[1] == SigningBackendEphemeral:
+		return SigningBackendEphemeral
+	case len(args) >= 2 && strings.HasPrefix(args[1], "pkcs11:"):
+		return SigningBackendPKCS11
+	case len(args) >= 2 && strings.HasPrefix(args[1], "remote:"):
+		return SigningBackendRemote
+	case len(args) >= 3:
+		return SigningBackendFile
+	default:
+		return ""
+	}
+}
+
+// SigningBackends returns the signing backends that the publish steps of the
+// pipeline are configured with, sorted and without duplicates.
+func (pl *Pipeline) SigningBackends() []string {
+	seen := make(map[string]bool)
+	backends := []string{}
+	for _, backend := range pl.SigningSteps() {
+		if !seen[backend] {
+			seen[backend] = true
+			backends = append(backends, backend)
+		}
+	}
+	sort.Strings(backends)
+	return backends
+}
+
+// SigningSteps returns the signing backend of every publish step of the
+// pipeline that signs, by the index of the step.
+func (pl *Pipeline) SigningSteps() map[int]string {
+	steps := make(map[int]string)
+	for i, pipe := range pl.Pipes {
+		if pipe.MethodName != "publish" || len(pipe.MethodArguments) == 0 {
+			continue
+		}
+		args, _, err := parsePublishOptions(pipe.MethodArguments)
+		if err != nil {
+			continue
+		}
+		if backend := signingBackend(args); backend != "" {
+			steps[i] = backend
+		}
+	}
+	return steps
+}
+
+// SignerExpiryWarning is how long before its signing certificate expires
+// CheckSigners warns about a signer.
+const SignerExpiryWarning = 30 * 24 * time.Hour
+
+// CheckSigners checks the signing credentials of every signing publish step
+// of the pipeline at now, without signing anything: that the certificate and
+// key load, that the key belongs to the certificate and that the certificate
+// is valid. A PKCS#11 signer logs in to its token for this. Call it when the
+// pipeline is loaded, so that a broken signer stops the service at startup
+// rather than failing the first scheduled publish. Certificates expiring
+// within SignerExpiryWarning are logged as warnings.
+//
+// During a key rollover the next key is checked too, at the start of the
+// rollover window if that is later; once the window has passed only the next
+// key is checked.
+func (pl *Pipeline) CheckSigners(now time.Time) error {
+	for i, pipe := range pl.Pipes {
+		if pipe.MethodName != "publish" || len(pipe.MethodArguments) == 0 {
+			continue
+		}
+		stepErr := func(err error) error {
+			return NewPipelineStepError(pipe.MethodName, i, pipe.MethodArguments, err)
+		}
+		args, opts, err := parsePublishOptions(pipe.MethodArguments)
+		if err != nil {
+			return stepErr(err)
+		}
+		signer, err := publishSigner(args)
+		if err == nil && signer == nil && signingBackend(args) == SigningBackendPKCS11 {
+			err = fmt.Errorf("invalid PKCS#11 URI: %s", args[1])
+		}
+		if err == nil {
+			err = applyRollover(pl, args, opts, signer, now)
+		}
+		if err != nil {
+			return stepErr(err)
+		}
+
+		type signerCheck struct {
+			role   string
+			signer dsig.XMLSigner
+			at     time.Time
+		}
+		checks := []signerCheck{{"current", opts.signer, now}}
+		switch opts.rollover {
+		case RolloverDual:
+			checks = append(checks, signerCheck{"next", opts.nextSigner, now})
+		case RolloverComplete:
+			checks[0].role = "next"
+		case RolloverPending:
+			nextArgs, err := opts.nextSignerArgs(args)
+			if err != nil {
+				return stepErr(err)
+			}
+			next, err := publishSigner(nextArgs)
+			if err != nil {
+				return stepErr(fmt.Errorf("invalid next signer: %w", err))
+			}
+			checks = append(checks, signerCheck{"next", next, opts.rolloverFrom})
+		}
+
+		for _, check := range checks {
+			checker, ok := check.signer.(dsig.CredentialChecker)
+			if !ok {
+				continue
+			}
+			cert, err := checker.CheckCredentials(check.at)
+			if err != nil {
+				if check.role == "next" {
+					return stepErr(fmt.Errorf("invalid next %s signer: %w", signingBackend(args), err))
+				}
+				return stepErr(fmt.Errorf("invalid %s signer: %w", signingBackend(args), err))
+			}
+
+			fields := []logging.Field{
+				logging.F("step", i),
+				logging.F("backend", signingBackend(args)),
+				logging.F("key", check.role),
+				logging.F("subject", cert.Subject.String()),
+				logging.F("not_after", cert.NotAfter.UTC().Format(time.RFC3339)),
+			}
+			if signingBackend(args) == SigningBackendEphemeral {
+				pl.Logger.Warn("Signing with an ephemeral self-signed key: not for production", fields...)
+			} else if cert.NotAfter.Sub(now) < SignerExpiryWarning {
+				pl.Logger.Warn("Signing certificate expires soon", fields...)
+			} else {
+				pl.Logger.Debug("Signer credentials valid", fields...)
+			}
+		}
+	}
+	return nil
+}
+
+// publishTo writes the TSLs of ctx, and the files of the publish options, to
+// dirPath. args are the positional publish arguments, args[0] being the
+// output directory as configured.
+func publishTo(pl *Pipeline, ctx *Context, dirPath string, args []string, opts *publishOptions) error {
+	opts.root = dirPath
 	info, err := os.Stat(dirPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			if err := os.MkdirAll(dirPath, 0755); err != nil {
-				return ctx, fmt.Errorf("failed to create output directory %s: %w", dirPath, err)
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("error accessing output directory %s: %w", dirPath, err)
+		}
+		// A dry run against a missing directory reports every file as added
+		if opts.writes() {
+			if err := opts.mkdirAll(dirPath); err != nil {
+				return fmt.Errorf("failed to create output directory %s: %w", dirPath, err)
 			}
-		} else {
-			return ctx, fmt.Errorf("error accessing output directory %s: %w", dirPath, err)
 		}
 	} else if !info.IsDir() {
-		return ctx, fmt.Errorf("%s is not a directory", dirPath)
+		return fmt.Errorf("%s is not a directory", dirPath)
+	}
+	if err := removeNextSigned(pl, dirPath, opts); err != nil {
+		return err
 	}
 
 	// Check legacy stack first for backwards compatibility
@@ -114,66 +482,26 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 				continue
 			}
 
-			// Determine filename from distribution points or use default
-			filename := fmt.Sprintf("tsl-%d.xml", i)
-			if tsl.StatusList.TslSchemeInformation != nil &&
-				tsl.StatusList.TslSchemeInformation.TslDistributionPoints != nil &&
-				len(tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI) > 0 {
-
-				// Extract the filename from the first distribution point URI
-				uri := tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI[0]
-				parts := strings.Split(uri, "/")
-				if len(parts) > 0 && parts[len(parts)-1] != "" {
-					filename = parts[len(parts)-1]
-				}
-			}
-
-			// Special case for tests
-			if ctx.Data != nil && ctx.Data["test"] == "pkcs11" {
-				filename = "test-tsl.xml"
-			}
-
-			// Construct the full file path
-			filePath := filepath.Join(dirPath, filename)
-
-			// Create XML representation with root element
-			type TrustStatusListWrapper struct {
-				XMLName xml.Name                       `xml:"TrustServiceStatusList"`
-				List    etsi119612.TrustStatusListType `xml:",innerxml"`
-			}
-			wrapper := TrustStatusListWrapper{List: tsl.StatusList}
-			xmlContent, err := xml.MarshalIndent(wrapper, "", "  ")
+			// Determine filename from distribution points or the name template
+			filename, err := publishFileName(tsl, i, fmt.Sprintf("tsl-%d.xml", i), opts)
 			if err != nil {
-				return ctx, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+				return err
 			}
 
-			// Add XML header
-			xmlContent = append([]byte(xml.Header), xmlContent...)
-
-			if signer != nil {
-				xmlContent, err = signer.Sign(xmlContent)
-				if err != nil {
-					return ctx, fmt.Errorf("failed to sign TSL: %w", err)
-				}
-			}
+			// Construct the full file path
+			filePath := claimPublishPath(pl, opts, filepath.Join(dirPath, filename))
 
-			// Write the TSL to file
-			if err := os.WriteFile(filePath, xmlContent, 0644); err != nil {
-				return ctx, fmt.Errorf("failed to write TSL to %s: %w", filePath, err)
+			if err := publishTSLToFile(pl, ctx, tsl, filePath, opts); err != nil {
+				return err
 			}
-
-			pl.Logger.Info("Published TSL",
-				logging.F("file", filePath),
-				logging.F("signed", signer != nil),
-				logging.F("size", len(xmlContent)))
 		}
 
-		return ctx, nil
+		return finishPublish(pl, dirPath, opts)
 	}
 
 	// If legacy stack is empty, use the new tree structure
 	if ctx.TSLTrees == nil || ctx.TSLTrees.IsEmpty() {
-		return ctx, fmt.Errorf("no TSLs to publish")
+		return fmt.Errorf("no TSLs to publish")
 	}
 
 	// Check if we should maintain the tree structure in the output
@@ -255,12 +583,12 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 				logging.F("format", subdirFormat))
 
 			// Call the specialized function for tree publishing
-			if err := processTreeForPublishing(pl, ctx, tree, dirPath, treeIdx, subdirFormat, signer); err != nil {
+			if err := processTreeForPublishing(pl, ctx, tree, dirPath, treeIdx, subdirFormat, opts); err != nil {
 				pl.Logger.Error("Error processing tree for publishing",
 					logging.F("error", err),
 					logging.F("directory", dirPath),
 					logging.F("format", subdirFormat))
-				return ctx, fmt.Errorf("failed to process tree for publishing: %w", err)
+				return fmt.Errorf("failed to process tree for publishing: %w", err)
 			}
 
 			// Log success and don't add to the flat list
@@ -283,24 +611,10 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 				continue
 			}
 
-			// Determine filename from distribution points or use default
-			filename := fmt.Sprintf("tsl-%d.xml", i)
-			if tsl.StatusList.TslSchemeInformation != nil &&
-				tsl.StatusList.TslSchemeInformation.TslDistributionPoints != nil &&
-				len(tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI) > 0 {
-
-				// Extract the filename from the first distribution point URI
-				uri := tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI[0]
-				parts := strings.Split(uri, "/")
-				if len(parts) > 0 && parts[len(parts)-1] != "" {
-					filename = parts[len(parts)-1]
-				}
-			}
-
-			// Use "test-tsl.xml" for pkcs11 signer tests, but default otherwise
-			// Check if this is being called from the TestPKCS11SignerWithSoftHSM test
-			if strings.Contains(dirPath, "TestPKCS11SignerWithSoftHSM") {
-				filename = "test-tsl.xml"
+			// Determine filename from distribution points or the name template
+			filename, err := publishFileName(tsl, i, fmt.Sprintf("tsl-%d.xml", i), opts)
+			if err != nil {
+				return err
 			}
 
 			// Log the filename using the pipeline's logger
@@ -308,35 +622,45 @@ func PublishTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 				logging.F("index", i),
 				logging.F("filename", filename))
 
-			// Create XML representation with root element
-			type TrustStatusListWrapper struct {
-				XMLName xml.Name                       `xml:"TrustServiceStatusList"`
-				List    etsi119612.TrustStatusListType `xml:",innerxml"`
+			filePath := claimPublishPath(pl, opts, filepath.Join(dirPath, filename))
+			if err := publishTSLToFile(pl, ctx, tsl, filePath, opts); err != nil {
+				return err
 			}
-			wrapper := TrustStatusListWrapper{List: tsl.StatusList}
-			xmlData, err := xml.MarshalIndent(wrapper, "", "  ")
-			if err != nil {
-				return ctx, fmt.Errorf("failed to marshal TSL to XML: %w", err)
-			}
-
-			// Add XML header
-			xmlData = append([]byte(xml.Header), xmlData...)
+		}
+	}
 
-			// Sign the XML if a signer is provided
-			if signer != nil {
-				xmlData, err = signer.Sign(xmlData)
-				if err != nil {
-					return ctx, fmt.Errorf("failed to sign XML: %w", err)
-				}
-			}
+	return finishPublish(pl, dirPath, opts)
+}
 
-			// Write to file
-			filePath := filepath.Join(dirPath, filename)
-			if err := os.WriteFile(filePath, xmlData, 0644); err != nil {
-				return ctx, fmt.Errorf("failed to write TSL to file %s: %w", filePath, err)
+// finishPublish runs the publish options that apply to the output directory as
+// a whole, after every TSL has been written. For a dry run it returns a
+// *PublishDiffError if any file would change.
+func finishPublish(pl *Pipeline, dirPath string, opts *publishOptions) error {
+	if opts.dryRun {
+		changed := 0
+		for _, c := range opts.changes {
+			if c.Status != PublishUnchanged {
+				changed++
 			}
 		}
+		pl.Logger.Info("Dry run publish completed",
+			logging.F("directory", dirPath),
+			logging.F("files", len(opts.changes)),
+			logging.F("changed", changed))
+		if changed > 0 {
+			return &PublishDiffError{Changes: opts.changes}
+		}
+		return nil
 	}
-
-	return ctx, nil
+	if opts.api {
+		if err := writeStaticAPI(pl, dirPath, opts, time.Now()); err != nil {
+			return fmt.Errorf("failed to write static API: %w", err)
+		}
+	}
+	if opts.bundle {
+		if _, err := writeBundle(pl, dirPath, opts, time.Now()); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	return nil
 }
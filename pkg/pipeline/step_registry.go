@@ -1,6 +1,13 @@
 package pipeline
 
-import "sync"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/SUNET/go-trust/pkg/utils/yamlutil"
+)
 
 // StepFunc is the function type for pipeline steps.
 // Each step takes a pipeline instance, a context, and variadic string arguments,
@@ -53,3 +60,38 @@ func GetFunctionByName(name string) (StepFunc, bool) {
 	fn, ok := functionRegistry[name]
 	return fn, ok
 }
+
+// RegisteredFunctionNames returns the names of the registered pipeline step
+// functions, sorted.
+func RegisteredFunctionNames() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := make([]string, 0, len(functionRegistry))
+	for name := range functionRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SuggestStep returns the registered step closest to name, an unknown step
+// name, for "did you mean" hints, or "" if none is close.
+func SuggestStep(name string) string {
+	return yamlutil.Suggest(name, RegisteredFunctionNames())
+}
+
+// unknownStepError returns the error of the step at index, whose name is not
+// registered, with its line and the closest registered step if known.
+func unknownStepError(index int, pipe Pipe) error {
+	msg := fmt.Sprintf("step %d: unknown methodName '%s'", index, pipe.MethodName)
+	if pipe.Line > 0 {
+		msg += fmt.Sprintf(" at line %d", pipe.Line)
+	}
+	if pipe.File != "" {
+		msg += " of " + pipe.File
+	}
+	if suggestion := SuggestStep(pipe.MethodName); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean '%s'?", suggestion)
+	}
+	return errors.New(msg)
+}
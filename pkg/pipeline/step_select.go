@@ -1,13 +1,13 @@
 package pipeline
 
 import (
-	"crypto/x509"
 	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
 	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/tslpool"
 )
 
 // SelectCertPool creates a new x509.CertPool from all certificates in the loaded TSLs.
@@ -27,6 +27,9 @@ import (
 //   - "service-type:URI": Filter certificates by service type URI (can be provided multiple times)
 //   - "status:URI": Filter certificates by status URI (can be provided multiple times)
 //   - "status-logic:and": Use AND logic for status filters (all filters must match) instead of default OR logic
+//   - "additional-info:URI": Filter by AdditionalServiceInformation extension URI, or its last segment such as ForeSignatures (can be provided multiple times)
+//   - "qualifier:URI": Filter by Qualifications extension qualifier URI, or its last segment such as QCWithQSCD (can be provided multiple times)
+//   - "max-pool-size:N": Add at most N distinct certificates to the pool (0=unlimited), overriding the limit set with SetMaxPoolSize
 //
 // Returns:
 //   - *Context: Updated context with the new certificate pool in ctx.CertPool
@@ -34,14 +37,19 @@ import (
 //
 // The created certificate pool is stored in the context's CertPool field and can be
 // used for certificate validation operations. Each certificate from valid trust services
-// is added as a trusted root certificate.
+// is added as a trusted root certificate, and the TSL source, territory, provider and
+// service it was listed under are recorded (see Context.CertProvenance).
+// Certificates listed in several TSLs, or under several services, are added
+// once; the step reports the number of distinct certificates in the pool.
 //
 // Note:
 //   - Requires at least one TSL to be loaded in the context
 //   - Invalid or nil TSLs in the stack are safely skipped
 //   - The previous certificate pool, if any, is replaced
 //   - The reference-depth parameter controls how deep in the TSL reference tree to process
-//   - Service type and status filters are combined with OR logic within each category and AND between categories
+//   - Service type, status, additional-info and qualifier filters are combined with OR logic within each category and AND between categories
+//   - Extension filters only match services loaded with the load step, which parses service information extensions
+//   - Certificates beyond the maximum pool size are left out with a warning, in TSL order
 //
 // Example usage in pipeline configuration:
 //   - select  # Create cert pool from top TSL only, all service types
@@ -51,6 +59,8 @@ import (
 //   - select: ["service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC"]  # Only qualified CA certificates
 //   - select: ["reference-depth:1", "service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC", "status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/"]  # Only granted qualified CA certificates up to depth 1
 //   - select: ["status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/granted/", "status:http://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/recognized/", "status-logic:and"]  # Only certificates that match both status filters
+//   - select: ["service-type:http://uri.etsi.org/TrstSvc/Svctype/CA/QC", "additional-info:ForeSignatures"]  # Only qualified CAs for electronic signatures
+//   - select: ["include-referenced", "max-pool-size:5000"]  # At most 5000 distinct certificates
 func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	// Check if we have TSLs either in the legacy stack or in the tree structure
 	if (ctx.TSLTrees == nil || ctx.TSLTrees.IsEmpty()) && (ctx.TSLs == nil || ctx.TSLs.IsEmpty()) {
@@ -62,6 +72,9 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 	serviceTypeFilters := []string{}
 	statusFilters := []string{}
 	useStatusAndLogic := false // Default: use OR logic for status filters
+	additionalInfoFilters := []string{}
+	qualifierFilters := []string{}
+	maxPoolSize := int(defaultMaxPoolSize.Load())
 
 	for _, arg := range args {
 		if arg == "include-referenced" {
@@ -88,79 +101,54 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 			}
 		} else if arg == "status-logic:and" {
 			useStatusAndLogic = true
-		}
-	}
-
-	// Initialize the certificate pool
-	ctx.InitCertPool()
-
-	// Track certificate counts for logging
-	certCount := 0
-	tslCount := 0
-
-	// Create a certificate processing function that applies filters
-	processCertificate := func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType, cert *x509.Certificate) {
-		// Apply service type filter if specified
-		if len(serviceTypeFilters) > 0 {
-			serviceTypeMatch := false
-			serviceType := svc.TslServiceInformation.TslServiceTypeIdentifier
-			for _, filter := range serviceTypeFilters {
-				if serviceType == filter {
-					serviceTypeMatch = true
-					break
-				}
+		} else if strings.HasPrefix(arg, "additional-info:") {
+			info := strings.TrimPrefix(arg, "additional-info:")
+			if info != "" {
+				additionalInfoFilters = append(additionalInfoFilters, info)
 			}
-			if !serviceTypeMatch {
-				return
-			}
-		}
-
-		// Apply status filter if specified
-		if len(statusFilters) > 0 {
-			status := svc.TslServiceInformation.TslServiceStatus
-
-			if useStatusAndLogic {
-				// AND logic: certificate must match ALL status filters
-				for _, filter := range statusFilters {
-					if status != filter {
-						// If any filter doesn't match, skip this certificate
-						return
-					}
-				}
+		} else if strings.HasPrefix(arg, "max-pool-size:") {
+			sizeStr := strings.TrimPrefix(arg, "max-pool-size:")
+			if size, err := strconv.Atoi(sizeStr); err == nil && size >= 0 {
+				maxPoolSize = size
 			} else {
-				// OR logic (default): certificate must match ANY status filter
-				statusMatch := false
-				for _, filter := range statusFilters {
-					if status == filter {
-						statusMatch = true
-						break
-					}
-				}
-				if !statusMatch {
-					return
-				}
+				pl.Logger.Warn("Invalid max-pool-size value, using default",
+					logging.F("value", sizeStr),
+					logging.F("default", maxPoolSize))
+			}
+		} else if strings.HasPrefix(arg, "qualifier:") {
+			qualifier := strings.TrimPrefix(arg, "qualifier:")
+			if qualifier != "" {
+				qualifierFilters = append(qualifierFilters, qualifier)
 			}
 		}
-
-		// Add the certificate to the pool
-		ctx.CertPool.AddCert(cert)
-		certCount++
 	}
 
-	// Define a function to process a TSL and extract certificates
+	// Build the pool in the context, recording the provenance of its certificates
+	ctx.InitCertPool()
+	pool := tslpool.NewPool(ctx.CertPool)
+	ctx.pool = pool
+	filters := contextFilters(ctx)
+	opts := tslpool.Options{
+		ServiceTypes:   serviceTypeFilters,
+		Statuses:       statusFilters,
+		AllStatuses:    useStatusAndLogic,
+		AdditionalInfo: additionalInfoFilters,
+		Qualifiers:     qualifierFilters,
+		MaxSize:        maxPoolSize,
+		// TSLs dropped by the filter step contribute no certificates
+		Reject: func(tsl *etsi119612.TSL) string {
+			return rejectReason(tsl, filters)
+		},
+		Extensions: func(svc *etsi119612.TSPServiceType) tslpool.Extensions {
+			if ext := ctx.ServiceExtensionsFor(svc); ext != nil {
+				return ext
+			}
+			return nil
+		},
+	}
+	report := &tslpool.Report{}
 	processTSL := func(tsl *etsi119612.TSL) {
-		if tsl == nil {
-			return
-		}
-
-		tslCount++
-
-		// Process the TSL
-		tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
-			svc.WithCertificates(func(cert *x509.Certificate) {
-				processCertificate(tsp, svc, cert)
-			})
-		})
+		pool.AddTSL(tsl, opts, report)
 	}
 
 	// Define a function to process a tree with a limited depth
@@ -224,14 +212,35 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 		}
 	}
 
+	if pl != nil && pl.Logger != nil {
+		for _, rejected := range report.Rejected {
+			pl.Logger.Debug("Skipping filtered TSL",
+				logging.F("url", rejected.TSL.Source),
+				logging.F("reason", rejected.Reason))
+		}
+	}
+
+	ctx.ReportItems(report.Added)
+	if report.LeftOut > 0 {
+		ctx.AddWarning("certificate pool limit of %d reached: %d certificates left out", maxPoolSize, report.LeftOut)
+		if pl != nil && pl.Logger != nil {
+			pl.Logger.Warn("Certificate pool limit reached",
+				logging.F("max_pool_size", maxPoolSize),
+				logging.F("left_out", report.LeftOut))
+		}
+	}
+
 	// Log summary information
 	if pl != nil && pl.Logger != nil {
 		pl.Logger.Info("Certificate pool created",
-			logging.F("tsl_count", tslCount),
-			logging.F("certificate_count", certCount),
+			logging.F("tsl_count", report.TSLs),
+			logging.F("certificate_count", report.Added),
+			logging.F("duplicate_count", report.Duplicates),
 			logging.F("reference_depth", referenceDepth),
 			logging.F("service_type_filters", len(serviceTypeFilters)),
-			logging.F("status_filters", len(statusFilters)))
+			logging.F("status_filters", len(statusFilters)),
+			logging.F("additional_info_filters", len(additionalInfoFilters)),
+			logging.F("qualifier_filters", len(qualifierFilters)))
 	}
 
 	if pl != nil && pl.Logger != nil {
@@ -244,6 +253,12 @@ func SelectCertPool(pl *Pipeline, ctx *Context, args ...string) (*Context, error
 			pl.Logger.Debug("Status filters applied",
 				logging.F("filters", statusFilters))
 		}
+
+		if len(additionalInfoFilters) > 0 || len(qualifierFilters) > 0 {
+			pl.Logger.Debug("Service information extension filters applied",
+				logging.F("additional_info", additionalInfoFilters),
+				logging.F("qualifiers", qualifierFilters))
+		}
 	}
 
 	return ctx, nil
@@ -8,7 +8,14 @@ func init() {
 	RegisterFunction("select-cert-pool", SelectCertPool) // Alternative name for backward compatibility
 	RegisterFunction("echo", Echo)
 	RegisterFunction("generate", GenerateTSL)
+	RegisterFunction("generate-lotl", GenerateLOTL)
 	RegisterFunction("publish", PublishTSL)
 	RegisterFunction("log", Log)
 	RegisterFunction("set-fetch-options", SetFetchOptions)
+	RegisterFunction("filter", Filter)
+	RegisterFunction("mock", MockTSL)
+	RegisterFunction("load-certs", LoadCerts)
+	RegisterFunction("export-registry", ExportRegistry)
+	RegisterFunction("generate-provider-pages", GenerateProviderPages)
+	RegisterFunction("vars", SetVars)
 }
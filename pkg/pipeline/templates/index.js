@@ -2,7 +2,7 @@
 document.addEventListener('DOMContentLoaded', function() {
     let totalServices = 0;
     document.querySelectorAll('#tsl-tbody tr').forEach(row => {
-        const services = parseInt(row.cells[4].textContent) || 0;
+        const services = parseInt(row.getAttribute('data-services')) || 0;
         totalServices += services;
     });
     document.getElementById('total-services').textContent = totalServices.toLocaleString();
@@ -47,6 +47,11 @@ function filterTable() {
     });
 
     document.getElementById('no-results').style.display = visibleCount === 0 ? 'block' : 'none';
+
+    // Providers have no TSL type and are filtered by the search term only
+    document.querySelectorAll('#provider-tbody tr').forEach(row => {
+        row.style.display = row.textContent.toLowerCase().includes(searchTerm) ? '' : 'none';
+    });
 }
 
 // Table sorting
@@ -80,8 +85,8 @@ function sortTable(columnIndex) {
         let aValue = a.cells[columnIndex].textContent.trim();
         let bValue = b.cells[columnIndex].textContent.trim();
 
-        // Extract numeric values from badges
-        if (columnIndex === 0) {
+        // Sort territories by their code rather than the title
+        if (header.getAttribute('data-column') === 'territory') {
             aValue = a.getAttribute('data-territory') || aValue;
             bValue = b.getAttribute('data-territory') || bValue;
         }
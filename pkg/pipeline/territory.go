@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/validation"
+)
+
+// TerritoryMismatchError reports that a certificate chain is trusted, but its
+// trust anchor is not listed in a TSL of any allowed scheme territory.
+type TerritoryMismatchError struct {
+	Allowed []string // Allowed scheme territories
+	Found   []string // Scheme territories of the TSLs listing the trust anchors
+}
+
+// Error implements the error interface.
+func (e *TerritoryMismatchError) Error() string {
+	if len(e.Found) == 0 {
+		return fmt.Sprintf("territory mismatch: trust anchor is not listed in a TSL of territory %s",
+			strings.Join(e.Allowed, ", "))
+	}
+	return fmt.Sprintf("territory mismatch: trust anchor is listed for territory %s, not %s",
+		strings.Join(e.Found, ", "), strings.Join(e.Allowed, ", "))
+}
+
+// NormalizeTerritories normalizes territory codes with
+// validation.NormalizeTerritory, so that GR and GB match the EL and UK of EU
+// trusted lists, and drops empty and duplicate ones, keeping their order.
+// Unknown codes are kept; see ValidateTerritories.
+func NormalizeTerritories(territories []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(territories))
+	for _, t := range territories {
+		t, _ = validation.NormalizeTerritory(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	return normalized
+}
+
+// ValidateTerritories normalizes territory codes like NormalizeTerritories,
+// failing for the first unknown one. Use it for territories given in
+// pipelines, configuration and requests, where an unknown code is a mistake
+// that would otherwise match nothing.
+func ValidateTerritories(territories []string) ([]string, error) {
+	for _, t := range territories {
+		if strings.TrimSpace(t) == "" {
+			continue
+		}
+		if _, err := validation.NormalizeTerritory(t); err != nil {
+			return nil, err
+		}
+	}
+	return NormalizeTerritories(territories), nil
+}
+
+// territoryOf returns the normalized scheme territory of tsl, or "" if it
+// has none.
+func territoryOf(tsl *etsi119612.TSL) string {
+	if tsl == nil || tsl.StatusList.TslSchemeInformation == nil {
+		return ""
+	}
+	territory, _ := validation.NormalizeTerritory(tsl.StatusList.TslSchemeInformation.TslSchemeTerritory)
+	return territory
+}
+
+// checkTSLTerritory warns about a TSL whose scheme territory is not a known
+// territory code, which territory filters and request restrictions cannot
+// match.
+func checkTSLTerritory(pl *Pipeline, ctx *Context, tsl *etsi119612.TSL) {
+	si := tsl.StatusList.TslSchemeInformation
+	if si == nil || si.TslSchemeTerritory == "" {
+		return
+	}
+	if _, err := validation.NormalizeTerritory(si.TslSchemeTerritory); err != nil {
+		pl.Logger.Warn("TSL has an unknown scheme territory",
+			logging.F("url", tsl.Source),
+			logging.F("territory", si.TslSchemeTerritory))
+		ctx.AddWarning("TSL %s has an unknown scheme territory %q", tsl.Source, si.TslSchemeTerritory)
+	}
+}
+
+// AnchorTerritories returns the scheme territories of the TSLs that list a
+// trust anchor as a service digital identity, normalized and without duplicates.
+func (ctx *Context) AnchorTerritories(anchor *x509.Certificate) []string {
+	var territories []string
+	for _, ts := range ctx.FindTrustServices(anchor) {
+		if ts.TSL.StatusList.TslSchemeInformation != nil {
+			territories = append(territories, ts.TSL.StatusList.TslSchemeInformation.TslSchemeTerritory)
+		}
+	}
+	return NormalizeTerritories(territories)
+}
+
+// SelectChainByTerritory returns the first of the verified certificate chains
+// whose trust anchor is listed in a TSL of one of the allowed scheme
+// territories. The chains are ordered leaf first, as returned by
+// x509.Certificate.Verify. Territory codes are compared case-insensitively.
+//
+// Parameters:
+//   - chains: Verified certificate chains, leaf first
+//   - allowed: Allowed scheme territories, such as "SE" or "EU"
+//
+// Returns:
+//   - The first chain anchored in an allowed territory
+//   - error: A *TerritoryMismatchError if no chain is
+func (ctx *Context) SelectChainByTerritory(chains [][]*x509.Certificate, allowed []string) ([]*x509.Certificate, error) {
+	allowed = NormalizeTerritories(allowed)
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, t := range allowed {
+		allowedSet[t] = true
+	}
+
+	var found []string
+	for _, chain := range chains {
+		if len(chain) == 0 {
+			continue
+		}
+		territories := ctx.AnchorTerritories(chain[len(chain)-1])
+		for _, t := range territories {
+			if allowedSet[t] {
+				return chain, nil
+			}
+		}
+		found = append(found, territories...)
+	}
+	return nil, &TerritoryMismatchError{Allowed: allowed, Found: NormalizeTerritories(found)}
+}
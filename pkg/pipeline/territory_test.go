@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/SUNET/go-trust/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTerritories(t *testing.T) {
+	assert.Equal(t, []string{"SE", "EU"}, NormalizeTerritories([]string{" se", "EU", "", "Se"}))
+	assert.Nil(t, NormalizeTerritories(nil))
+	assert.Equal(t, []string{"EL", "UK", "XX"}, NormalizeTerritories([]string{"GR", "el", "GB", "xx"}))
+}
+
+func TestValidateTerritories(t *testing.T) {
+	territories, err := ValidateTerritories([]string{"gr", " ", "SE", "EL"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"EL", "SE"}, territories)
+
+	_, err = ValidateTerritories([]string{"SE", "XX"})
+	assert.ErrorIs(t, err, validation.ErrUnknownTerritory)
+}
+
+func TestCheckTSLTerritory(t *testing.T) {
+	pl := createTestPipeline(nil)
+	for territory, warns := range map[string]bool{"SE": false, "GR": false, "": false, "XX": true} {
+		ctx := NewContext()
+		tsl := generateTSL("Service", testTypeCAQC, nil)
+		tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = territory
+		checkTSLTerritory(pl, ctx, tsl)
+		if warns {
+			require.Len(t, ctx.warnings, 1, territory)
+			assert.Contains(t, ctx.warnings[0], `unknown scheme territory "XX"`)
+		} else {
+			assert.Empty(t, ctx.warnings, territory)
+		}
+	}
+}
+
+func TestSelectChainByTerritory(t *testing.T) {
+	seCA, err := testutil.NewCA("SE CA")
+	require.NoError(t, err)
+	deCA, err := testutil.NewCA("DE CA")
+	require.NoError(t, err)
+	unlistedCA, err := testutil.NewCA("Unlisted CA")
+	require.NoError(t, err)
+
+	se := generateTSL("SE Service", testTypeCAQC, []string{seCA.Base64()})
+	se.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	de := generateTSL("DE Service", testTypeCAQC, []string{deCA.Base64()})
+	de.StatusList.TslSchemeInformation.TslSchemeTerritory = "DE"
+	ctx := NewContext()
+	ctx.AddTSL(se)
+	ctx.AddTSL(de)
+
+	assert.Equal(t, []string{"SE"}, ctx.AnchorTerritories(seCA.Certificate))
+	assert.Empty(t, ctx.AnchorTerritories(unlistedCA.Certificate))
+
+	seChain := []*x509.Certificate{seCA.Certificate}
+	deChain := []*x509.Certificate{deCA.Certificate}
+
+	chain, err := ctx.SelectChainByTerritory([][]*x509.Certificate{deChain, seChain}, []string{"se"})
+	require.NoError(t, err)
+	assert.Equal(t, seChain, chain)
+
+	_, err = ctx.SelectChainByTerritory([][]*x509.Certificate{deChain}, []string{"SE", "EU"})
+	var mismatch *TerritoryMismatchError
+	require.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, []string{"SE", "EU"}, mismatch.Allowed)
+	assert.Equal(t, []string{"DE"}, mismatch.Found)
+	assert.EqualError(t, err, "territory mismatch: trust anchor is listed for territory DE, not SE, EU")
+
+	_, err = ctx.SelectChainByTerritory([][]*x509.Certificate{{unlistedCA.Certificate}}, []string{"SE"})
+	assert.EqualError(t, err, "territory mismatch: trust anchor is not listed in a TSL of territory SE")
+}
@@ -1,15 +1,10 @@
 package pipeline
 
 import (
-	"bytes"
 	"crypto/x509"
-	"encoding/base64"
-	"fmt"
-	"os"
-	"os/exec"
-	"strings"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
 )
 
 // Test certificate variables for reuse in tests
@@ -17,46 +12,14 @@ var TestCertBase64 string
 var TestCertDER []byte
 var TestCert *x509.Certificate
 
-// generateTestCertBase64 runs openssl to generate a self-signed cert and returns the base64-encoded DER string.
+// GenerateTestCertBase64 generates a self-signed CA certificate and returns it as a
+// base64-encoded DER string, the raw DER bytes and the parsed certificate.
 func GenerateTestCertBase64() (string, []byte, *x509.Certificate, error) {
-	keyFile, err := os.CreateTemp("", "testkey-*.pem")
+	cert, err := testutil.NewCA("Test Cert")
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create temp key file: %w", err)
+		return "", nil, nil, err
 	}
-	defer os.Remove(keyFile.Name())
-	keyFile.Close()
-	certFile, err := os.CreateTemp("", "testcert-*.pem")
-	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create temp cert file: %w", err)
-	}
-	defer os.Remove(certFile.Name())
-	certFile.Close()
-	derFile, err := os.CreateTemp("", "testcert-*.der")
-	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create temp der file: %w", err)
-	}
-	defer os.Remove(derFile.Name())
-	derFile.Close()
-
-	opensslCmd := fmt.Sprintf("openssl req -x509 -newkey rsa:2048 -keyout %s -out %s -days 365 -nodes -subj '/CN=Test Cert' 2>/dev/null && openssl x509 -outform der -in %s -out %s 2>/dev/null && openssl base64 -in %s -A 2>/dev/null", keyFile.Name(), certFile.Name(), certFile.Name(), derFile.Name(), derFile.Name())
-	cmd := exec.Command("bash", "-c", opensslCmd)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err = cmd.Run()
-	output := out.String()
-	if err != nil {
-		return "", nil, nil, fmt.Errorf("openssl error: %v\noutput: %s", err, output)
-	}
-	certBase64 := strings.TrimSpace(output)
-	certDER, err := base64.StdEncoding.DecodeString(certBase64)
-	if err != nil {
-		return certBase64, nil, nil, fmt.Errorf("base64 decode error: %v\noutput: %s", err, output)
-	}
-	cert, err := x509.ParseCertificate(certDER)
-	if err != nil {
-		return certBase64, certDER, nil, fmt.Errorf("parse cert error: %v\noutput: %s", err, output)
-	}
-	return certBase64, certDER, cert, nil
+	return cert.Base64(), cert.DER, cert.Certificate, nil
 }
 
 func init() {
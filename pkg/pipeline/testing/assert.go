@@ -0,0 +1,117 @@
+package testing
+
+import (
+	"crypto/x509"
+	stdtesting "testing"
+
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+)
+
+// NewPipeline returns a pipeline with the given pipes and a debug-level logger,
+// suitable for passing to step functions in tests.
+func NewPipeline(pipes ...pipeline.Pipe) *pipeline.Pipeline {
+	return &pipeline.Pipeline{
+		Pipes:  pipes,
+		Logger: logging.NewLogger(logging.DebugLevel),
+	}
+}
+
+// RunStep looks up the registered step name and runs it on ctx with args, failing
+// the test if the step is unknown or returns an error. It returns the resulting Context.
+func RunStep(t stdtesting.TB, name string, ctx *pipeline.Context, args ...string) *pipeline.Context {
+	t.Helper()
+
+	fn, ok := pipeline.GetFunctionByName(name)
+	if !ok {
+		t.Fatalf("unknown pipeline step %q", name)
+	}
+
+	next, err := fn(NewPipeline(), ctx, args...)
+	if err != nil {
+		t.Fatalf("pipeline step %q failed: %v", name, err)
+	}
+	return next
+}
+
+// AssertTSLCount checks that ctx holds exactly want TSLs in its TSL stack.
+func AssertTSLCount(t stdtesting.TB, ctx *pipeline.Context, want int) bool {
+	t.Helper()
+
+	got := 0
+	if ctx != nil && ctx.TSLs != nil {
+		got = ctx.TSLs.Size()
+	}
+	if got != want {
+		t.Errorf("expected %d TSL(s) in context, got %d", want, got)
+		return false
+	}
+	return true
+}
+
+// AssertTerritories checks that the TSLs in ctx have exactly the given scheme
+// territories, in the order returned by the TSL stack.
+func AssertTerritories(t stdtesting.TB, ctx *pipeline.Context, want ...string) bool {
+	t.Helper()
+
+	var got []string
+	if ctx != nil && ctx.TSLs != nil {
+		for _, tsl := range ctx.TSLs.ToSlice() {
+			if tsl == nil || tsl.StatusList.TslSchemeInformation == nil {
+				got = append(got, "")
+				continue
+			}
+			got = append(got, tsl.StatusList.TslSchemeInformation.TslSchemeTerritory)
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("expected territories %v, got %v", want, got)
+		return false
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected territories %v, got %v", want, got)
+			return false
+		}
+	}
+	return true
+}
+
+// AssertCertInPool checks that cert is a trust anchor in the context's certificate pool.
+func AssertCertInPool(t stdtesting.TB, ctx *pipeline.Context, cert *x509.Certificate) bool {
+	t.Helper()
+
+	if !certInPool(ctx, cert) {
+		t.Errorf("expected certificate %q in context cert pool", cert.Subject.String())
+		return false
+	}
+	return true
+}
+
+// AssertCertNotInPool checks that cert is not a trust anchor in the context's
+// certificate pool. A context without a pool passes.
+func AssertCertNotInPool(t stdtesting.TB, ctx *pipeline.Context, cert *x509.Certificate) bool {
+	t.Helper()
+
+	if certInPool(ctx, cert) {
+		t.Errorf("expected certificate %q not to be in context cert pool", cert.Subject.String())
+		return false
+	}
+	return true
+}
+
+// certInPool reports whether cert verifies against the context's pool as a root.
+// x509.CertPool does not expose its contents, so membership is tested by
+// verification; this works for the self-signed certificates from NewCert.
+func certInPool(ctx *pipeline.Context, cert *x509.Certificate) bool {
+	if ctx == nil || ctx.CertPool == nil {
+		return false
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:       ctx.CertPool,
+		CurrentTime: cert.NotBefore,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
@@ -0,0 +1,235 @@
+package testing
+
+import (
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+)
+
+// Default values used by the builders when a field is not set explicitly.
+const (
+	DefaultOperatorName = "Test Operator"
+	DefaultTerritory    = "SE"
+	DefaultServiceType  = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+)
+
+// ServiceStatusWithdrawn is the ETSI status URI for a withdrawn service, for use
+// with ServiceBuilder.WithStatus. The granted status is etsi119612.ServiceStatusGranted.
+const ServiceStatusWithdrawn = "https://uri.etsi.org/TrstSvc/TrustedList/Svcstatus/withdrawn/"
+
+// TSLBuilder assembles a synthetic TSL. Create one with NewTSL, configure it with
+// the With* methods and call Build to obtain the TSL.
+type TSLBuilder struct {
+	operatorName   string
+	territory      string
+	sequenceNumber int
+	issueDate      time.Time
+	nextUpdate     time.Time
+	source         string
+	pointers       []string
+	providers      []*ProviderBuilder
+}
+
+// NewTSL returns a TSLBuilder for a TSL with a default operator name and
+// territory, sequence number 1, issued now and due for update in 30 days.
+func NewTSL() *TSLBuilder {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &TSLBuilder{
+		operatorName:   DefaultOperatorName,
+		territory:      DefaultTerritory,
+		sequenceNumber: 1,
+		issueDate:      now,
+		nextUpdate:     now.AddDate(0, 0, 30),
+	}
+}
+
+// WithOperatorName sets the scheme operator name.
+func (b *TSLBuilder) WithOperatorName(name string) *TSLBuilder {
+	b.operatorName = name
+	return b
+}
+
+// WithTerritory sets the scheme territory code.
+func (b *TSLBuilder) WithTerritory(territory string) *TSLBuilder {
+	b.territory = territory
+	return b
+}
+
+// WithSequenceNumber sets the TSL sequence number.
+func (b *TSLBuilder) WithSequenceNumber(n int) *TSLBuilder {
+	b.sequenceNumber = n
+	return b
+}
+
+// WithIssueDate sets the list issue date and time.
+func (b *TSLBuilder) WithIssueDate(t time.Time) *TSLBuilder {
+	b.issueDate = t
+	return b
+}
+
+// WithNextUpdate sets the next update date and time.
+func (b *TSLBuilder) WithNextUpdate(t time.Time) *TSLBuilder {
+	b.nextUpdate = t
+	return b
+}
+
+// WithSource sets the Source field of the built TSL, normally the URL it was loaded from.
+func (b *TSLBuilder) WithSource(source string) *TSLBuilder {
+	b.source = source
+	return b
+}
+
+// WithPointer adds a pointer to another TSL at the given location. Loading the
+// built TSL with reference dereferencing enabled fetches the referenced TSL.
+func (b *TSLBuilder) WithPointer(location string) *TSLBuilder {
+	b.pointers = append(b.pointers, location)
+	return b
+}
+
+// WithProvider adds a trust service provider to the TSL.
+func (b *TSLBuilder) WithProvider(p *ProviderBuilder) *TSLBuilder {
+	b.providers = append(b.providers, p)
+	return b
+}
+
+// Build returns a new TSL reflecting the current builder configuration.
+func (b *TSLBuilder) Build() *etsi119612.TSL {
+	info := &etsi119612.TSLSchemeInformationType{
+		TSLVersionIdentifier:  5,
+		TSLSequenceNumber:     b.sequenceNumber,
+		TslTSLType:            "http://uri.etsi.org/TrstSvc/TrustedList/TSLType/EUgeneric",
+		TslSchemeOperatorName: names(b.operatorName),
+		TslSchemeTerritory:    b.territory,
+		ListIssueDateTime:     b.issueDate.UTC().Format(time.RFC3339),
+		TslNextUpdate:         &etsi119612.NextUpdateType{DateTime: b.nextUpdate.UTC().Format(time.RFC3339)},
+	}
+
+	if len(b.pointers) > 0 {
+		info.TslPointersToOtherTSL = &etsi119612.OtherTSLPointersType{}
+		for _, location := range b.pointers {
+			info.TslPointersToOtherTSL.TslOtherTSLPointer = append(info.TslPointersToOtherTSL.TslOtherTSLPointer,
+				&etsi119612.OtherTSLPointerType{TSLLocation: location})
+		}
+	}
+
+	tsl := &etsi119612.TSL{
+		Source: b.source,
+		StatusList: etsi119612.TrustStatusListType{
+			TSLTagAttr:           "http://uri.etsi.org/19612/TSLTag",
+			TslSchemeInformation: info,
+		},
+	}
+
+	if len(b.providers) > 0 {
+		list := &etsi119612.TrustServiceProviderListType{}
+		for _, p := range b.providers {
+			list.TslTrustServiceProvider = append(list.TslTrustServiceProvider, p.Build())
+		}
+		tsl.StatusList.TslTrustServiceProviderList = list
+	}
+
+	return tsl
+}
+
+// ProviderBuilder assembles a synthetic trust service provider for use with TSLBuilder.
+type ProviderBuilder struct {
+	name     string
+	services []*ServiceBuilder
+}
+
+// NewProvider returns a ProviderBuilder for a provider with the given name.
+func NewProvider(name string) *ProviderBuilder {
+	return &ProviderBuilder{name: name}
+}
+
+// WithService adds a trust service to the provider.
+func (b *ProviderBuilder) WithService(s *ServiceBuilder) *ProviderBuilder {
+	b.services = append(b.services, s)
+	return b
+}
+
+// Build returns a new trust service provider reflecting the builder configuration.
+func (b *ProviderBuilder) Build() *etsi119612.TSPType {
+	tsp := &etsi119612.TSPType{
+		TslTSPInformation: &etsi119612.TSPInformationType{
+			TSPName: names(b.name),
+		},
+	}
+
+	if len(b.services) > 0 {
+		list := &etsi119612.TSPServicesListType{}
+		for _, s := range b.services {
+			list.TslTSPService = append(list.TslTSPService, s.Build())
+		}
+		tsp.TslTSPServices = list
+	}
+
+	return tsp
+}
+
+// ServiceBuilder assembles a synthetic trust service for use with ProviderBuilder.
+type ServiceBuilder struct {
+	name        string
+	serviceType string
+	status      string
+	certs       []*Cert
+}
+
+// NewService returns a ServiceBuilder for a granted CA/QC service with the given name.
+func NewService(name string) *ServiceBuilder {
+	return &ServiceBuilder{
+		name:        name,
+		serviceType: DefaultServiceType,
+		status:      etsi119612.ServiceStatusGranted,
+	}
+}
+
+// WithType sets the service type identifier URI.
+func (b *ServiceBuilder) WithType(serviceType string) *ServiceBuilder {
+	b.serviceType = serviceType
+	return b
+}
+
+// WithStatus sets the service status URI.
+func (b *ServiceBuilder) WithStatus(status string) *ServiceBuilder {
+	b.status = status
+	return b
+}
+
+// WithCert adds certificates to the service's digital identity.
+func (b *ServiceBuilder) WithCert(certs ...*Cert) *ServiceBuilder {
+	b.certs = append(b.certs, certs...)
+	return b
+}
+
+// Build returns a new trust service reflecting the builder configuration.
+func (b *ServiceBuilder) Build() *etsi119612.TSPServiceType {
+	var digitalIds []*etsi119612.DigitalIdentityType
+	for _, cert := range b.certs {
+		digitalIds = append(digitalIds, &etsi119612.DigitalIdentityType{
+			X509Certificate: cert.Base64(),
+		})
+	}
+
+	return &etsi119612.TSPServiceType{
+		TslServiceInformation: &etsi119612.TSPServiceInformationType{
+			TslServiceTypeIdentifier: b.serviceType,
+			TslServiceStatus:         b.status,
+			ServiceName:              names(b.name),
+			TslServiceDigitalIdentity: &etsi119612.DigitalIdentityListType{
+				DigitalId: digitalIds,
+			},
+		},
+	}
+}
+
+// names returns an InternationalNamesType holding a single English name.
+func names(name string) *etsi119612.InternationalNamesType {
+	lang := etsi119612.Lang("en")
+	value := etsi119612.NonEmptyNormalizedString(name)
+	return &etsi119612.InternationalNamesType{
+		Name: []*etsi119612.MultiLangNormStringType{
+			{XmlLangAttr: &lang, NonEmptyNormalizedString: &value},
+		},
+	}
+}
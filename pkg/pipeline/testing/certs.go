@@ -0,0 +1,23 @@
+package testing
+
+import (
+	stdtesting "testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+// Cert is a generated certificate together with its private key. See the
+// testutil package for CA/leaf chains and deliberately invalid certificates.
+type Cert = testutil.Cert
+
+// NewCert generates a self-signed CA certificate with the given common name,
+// valid from one hour ago. It fails the test on error.
+func NewCert(t stdtesting.TB, commonName string) *Cert {
+	t.Helper()
+
+	cert, err := testutil.NewCA(commonName)
+	if err != nil {
+		t.Fatalf("failed to generate certificate: %v", err)
+	}
+	return cert
+}
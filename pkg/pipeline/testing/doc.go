@@ -0,0 +1,28 @@
+// Package testing provides helpers for testing pipeline steps and policies that
+// consume Trust Status Lists without external tooling.
+//
+// It offers three groups of helpers:
+//
+//   - Builders (NewTSL, NewProvider, NewService, NewCert) that assemble synthetic
+//     TSLs, trust service providers, services and certificates in pure Go.
+//   - TSLServer, an in-memory fetcher that serves TSL fixtures over httptest so
+//     that steps such as "load" can be exercised against real HTTP URLs.
+//   - Context assertions (AssertTSLCount, AssertCertInPool, ...) and RunStep for
+//     invoking registered steps by name.
+//
+// A typical test builds a TSL, serves it and runs the standard steps:
+//
+//	cert := pltesting.NewCert(t, "Test CA")
+//	srv := pltesting.NewTSLServer(t)
+//	srv.Add("/tsl.xml", pltesting.NewTSL().
+//		WithProvider(pltesting.NewProvider("Test TSP").
+//			WithService(pltesting.NewService("Test CA").WithCert(cert))).
+//		Build())
+//
+//	ctx := pltesting.RunStep(t, "load", pipeline.NewContext(), srv.URL("/tsl.xml"))
+//	ctx = pltesting.RunStep(t, "select", ctx)
+//	pltesting.AssertCertInPool(t, ctx, cert.Certificate)
+//
+// The package name shadows the standard library testing package, so callers
+// normally import it under an alias such as pltesting.
+package testing
@@ -0,0 +1,90 @@
+package testing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	stdtesting "testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+)
+
+// TSLServer is an in-memory fetcher that serves TSL fixtures over HTTP. Fixtures
+// are registered by path and marshaled to XML on every request, so they can be
+// added or replaced while the server is running, for example to point one TSL at
+// another via URL.
+type TSLServer struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	fixtures map[string]*etsi119612.TSL
+	requests map[string]int
+}
+
+// NewTSLServer starts a TSLServer. The server is closed automatically when the
+// test finishes.
+func NewTSLServer(t stdtesting.TB) *TSLServer {
+	t.Helper()
+
+	s := &TSLServer{
+		fixtures: make(map[string]*etsi119612.TSL),
+		requests: make(map[string]int),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Add registers tsl to be served at path, replacing any existing fixture.
+func (s *TSLServer) Add(path string, tsl *etsi119612.TSL) *TSLServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures[path] = tsl
+	return s
+}
+
+// URL returns the absolute URL of path on the server.
+func (s *TSLServer) URL(path string) string {
+	return s.server.URL + path
+}
+
+// Requests returns the number of requests received for path.
+func (s *TSLServer) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Close shuts down the server. It is safe to call more than once.
+func (s *TSLServer) Close() {
+	s.server.Close()
+}
+
+func (s *TSLServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	tsl, ok := s.fixtures[r.URL.Path]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	data, err := MarshalTSL(tsl)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write(data)
+}
+
+// MarshalTSL serializes tsl to an XML document in the ETSI TS 119 612 namespace
+// that the load step can parse back into an equivalent TSL (see
+// pipeline.MarshalTSL).
+func MarshalTSL(tsl *etsi119612.TSL) ([]byte, error) {
+	return pipeline.MarshalTSL(tsl)
+}
@@ -0,0 +1,130 @@
+package testing_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSLBuilder(t *testing.T) {
+	cert := pltesting.NewCert(t, "Builder CA")
+	issued := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tsl := pltesting.NewTSL().
+		WithOperatorName("Builder Operator").
+		WithTerritory("FI").
+		WithSequenceNumber(7).
+		WithIssueDate(issued).
+		WithPointer("https://example.com/other.xml").
+		WithProvider(pltesting.NewProvider("Builder TSP").
+			WithService(pltesting.NewService("Builder Service").
+				WithType("http://example.org/svc").
+				WithStatus(pltesting.ServiceStatusWithdrawn).
+				WithCert(cert))).
+		Build()
+
+	si := tsl.StatusList.TslSchemeInformation
+	require.NotNil(t, si)
+	assert.Equal(t, "Builder Operator", tsl.SchemeOperatorName())
+	assert.Equal(t, "FI", si.TslSchemeTerritory)
+	assert.Equal(t, 7, si.TSLSequenceNumber)
+	assert.Equal(t, "2025-01-02T03:04:05Z", si.ListIssueDateTime)
+	require.NotNil(t, si.TslPointersToOtherTSL)
+	assert.Equal(t, "https://example.com/other.xml", si.TslPointersToOtherTSL.TslOtherTSLPointer[0].TSLLocation)
+
+	assert.Equal(t, 1, tsl.NumberOfTrustServiceProviders())
+	svc := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0].TslServiceInformation
+	assert.Equal(t, "http://example.org/svc", svc.TslServiceTypeIdentifier)
+	assert.Equal(t, pltesting.ServiceStatusWithdrawn, svc.TslServiceStatus)
+	require.Len(t, svc.TslServiceDigitalIdentity.DigitalId, 1)
+	assert.Equal(t, cert.Base64(), svc.TslServiceDigitalIdentity.DigitalId[0].X509Certificate)
+}
+
+func TestNewCert(t *testing.T) {
+	cert := pltesting.NewCert(t, "Self Signed")
+	assert.Equal(t, "Self Signed", cert.Certificate.Subject.CommonName)
+	assert.True(t, cert.Certificate.IsCA)
+	assert.NoError(t, cert.Certificate.CheckSignatureFrom(cert.Certificate))
+	assert.True(t, time.Now().Before(cert.Certificate.NotAfter))
+}
+
+func TestMarshalTSL(t *testing.T) {
+	data, err := pltesting.MarshalTSL(pltesting.NewTSL().WithTerritory("NO").Build())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), xml.Header)
+	assert.Contains(t, string(data), "<tsl:TrustServiceStatusList xmlns:tsl=\"http://uri.etsi.org/02231/v2#\"")
+	assert.Contains(t, string(data), "<tsl:SchemeTerritory>NO</tsl:SchemeTerritory>")
+	assert.NotContains(t, string(data), "<List ")
+}
+
+func TestTSLServer_LoadAndSelect(t *testing.T) {
+	trusted := pltesting.NewCert(t, "Trusted CA")
+	withdrawn := pltesting.NewCert(t, "Withdrawn CA")
+	referenced := pltesting.NewCert(t, "Referenced CA")
+	unrelated := pltesting.NewCert(t, "Unrelated CA")
+
+	srv := pltesting.NewTSLServer(t)
+	srv.Add("/ref.xml", pltesting.NewTSL().
+		WithTerritory("DK").
+		WithProvider(pltesting.NewProvider("Referenced TSP").
+			WithService(pltesting.NewService("Referenced CA").WithCert(referenced))).
+		Build())
+	srv.Add("/root.xml", pltesting.NewTSL().
+		WithTerritory("SE").
+		WithPointer(srv.URL("/ref.xml")).
+		WithProvider(pltesting.NewProvider("Root TSP").
+			WithService(pltesting.NewService("Trusted CA").WithCert(trusted)).
+			WithService(pltesting.NewService("Withdrawn CA").
+				WithStatus(pltesting.ServiceStatusWithdrawn).
+				WithCert(withdrawn))).
+		Build())
+
+	ctx := pltesting.RunStep(t, "set-fetch-options", pipeline.NewContext(), "max-depth:1")
+	ctx = pltesting.RunStep(t, "load", ctx, srv.URL("/root.xml"))
+	pltesting.AssertTSLCount(t, ctx, 2)
+	assert.Equal(t, 1, srv.Requests("/root.xml"))
+	assert.Equal(t, 1, srv.Requests("/ref.xml"))
+
+	ctx = pltesting.RunStep(t, "select", ctx, "status:"+etsi119612.ServiceStatusGranted, "include-referenced")
+	pltesting.AssertCertInPool(t, ctx, trusted.Certificate)
+	pltesting.AssertCertInPool(t, ctx, referenced.Certificate)
+	pltesting.AssertCertNotInPool(t, ctx, withdrawn.Certificate)
+	pltesting.AssertCertNotInPool(t, ctx, unrelated.Certificate)
+}
+
+func TestTSLServer_NotFound(t *testing.T) {
+	srv := pltesting.NewTSLServer(t)
+
+	resp, err := http.Get(srv.URL("/missing.xml"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 1, srv.Requests("/missing.xml"))
+
+	fn, ok := pipeline.GetFunctionByName("load")
+	require.True(t, ok)
+	_, err = fn(pltesting.NewPipeline(), pipeline.NewContext(), srv.URL("/missing.xml"))
+	assert.Error(t, err)
+}
+
+func TestAssertions(t *testing.T) {
+	ctx := pipeline.NewContext()
+	ctx.AddTSLTree(pipeline.NewTSLTree(pltesting.NewTSL().WithTerritory("SE").Build()))
+	ctx.AddTSLTree(pipeline.NewTSLTree(pltesting.NewTSL().WithTerritory("FI").Build()))
+
+	assert.True(t, pltesting.AssertTSLCount(t, ctx, 2))
+	assert.True(t, pltesting.AssertTerritories(t, ctx, "SE", "FI"))
+
+	mock := &testing.T{}
+	assert.False(t, pltesting.AssertTSLCount(mock, ctx, 3))
+	assert.False(t, pltesting.AssertTerritories(mock, ctx, "SE"))
+	assert.False(t, pltesting.AssertCertInPool(mock, ctx, pltesting.NewCert(t, "No Pool").Certificate))
+	assert.True(t, mock.Failed())
+}
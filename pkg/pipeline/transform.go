@@ -4,15 +4,19 @@ package pipeline
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
 	"github.com/SUNET/go-trust/pkg/validation"
 	"github.com/SUNET/go-trust/xslt"
 )
@@ -81,6 +85,41 @@ func (c *xsltCache) clear() {
 //   - If "replace", transformed TSLs replace the originals in the context.
 //   - Otherwise, it's treated as a directory path where transformed TSLs are saved.
 //   - arg[2]: (Optional) Output file extension (default: "xml")
+//   - "workers:N": (Optional) Transform at most N TSLs at a time (default: the
+//     number of CPUs, at most 8)
+//   - "param:name=value": (Optional, repeatable) Pass a string parameter to the
+//     stylesheet, as with "xsltproc --stringparam name value". Unless given,
+//     "lang" is the most preferred configured language (see
+//     i18n.SetPreferredLanguages), in which tsl-to-html.xslt shows names
+//   - "territory:SE,NO": (Optional, repeatable) Only transform TSLs of these territories
+//   - "depth:N" or "depth:N-M": (Optional) Only transform TSLs at these depths of
+//     their TSL tree, the root being at depth 0
+//   - "latest" or "latest:PATH": (Optional) Once the output is written, atomically
+//     point a symlink named latest next to the output directory, or at PATH, to it
+//   - "assets:inline" or "assets:copy" and "stylesheet:PATH": (Optional) Embed
+//     a base stylesheet in the HTML output, or copy it to the assets directory,
+//     instead of linking PicoCSS on its CDN, as for generate_index. The
+//     stylesheet is passed as the "inline-css" or "stylesheet-url" parameter,
+//     which tsl-to-html.xslt uses
+//
+// The output directory may be a Go text/template executed with
+// OutputPathData, as for the publish step, with the territory and sequence
+// number of the first TSL transformed, e.g. "/srv/html/{{.Date}}". It is
+// recorded as the transform_dir pipeline variable.
+//
+// With "territory:" or "depth:" only the selected TSLs are transformed; in
+// replace mode the others are kept unchanged. The step fails if no TSL is
+// selected.
+//
+// TSLs are transformed in parallel, each by its own xsltproc process in its
+// own temporary directory, and progress is logged as they complete. If any
+// transformation fails, all others still run and the step fails with an
+// error listing every failure; no output is written or replaced.
+//
+// Output files are named after the last path segment of each TSL's first
+// distribution point, percent-decoded and sanitized as in the publish step,
+// with the extension replaced. TSLs that would share a file name get
+// numbered files ("se-2.html").
 //
 // Example usage in pipeline YAML for file-based XSLT:
 //
@@ -94,6 +133,15 @@ func (c *xsltCache) clear() {
 //   - embedded:tsl-to-html.xslt
 //   - /output/directory
 //   - html
+//
+// OR for Swedish HTML pages of the root TSLs only:
+//
+//   - transform:
+//   - embedded:tsl-to-html.xslt
+//   - /output/directory/sv
+//   - html
+//   - param:lang=sv
+//   - depth:0
 func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error) {
 	if len(args) < 2 {
 		return ctx, fmt.Errorf("missing required arguments: need XSLT stylesheet path and mode ('replace' or output directory)")
@@ -102,22 +150,27 @@ func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 	// Parse arguments
 	xsltPath := args[0]
 	mode := args[1]
-	extension := "xml"
-	if len(args) >= 3 {
-		extension = args[2]
+	cfg, err := parseTransformOptions(args[2:])
+	if err != nil {
+		return ctx, err
 	}
+	cfg.params = withLanguageParam(cfg.params)
 
 	// Validate XSLT path before processing
 	if err := validation.ValidateXSLTPath(xsltPath); err != nil {
 		return ctx, fmt.Errorf("invalid XSLT path: %w", err)
 	}
 
-	// If mode is not "replace", validate it as an output directory
-	if mode != "replace" {
+	// If mode is not "replace", validate it as an output directory; a
+	// template is validated once resolved
+	if mode != "replace" && !isOutputPathTemplate(mode) {
 		if err := validation.ValidateOutputDirectory(mode); err != nil {
 			return ctx, fmt.Errorf("invalid output directory: %w", err)
 		}
 	}
+	if mode == "replace" && cfg.assets.mode != "" {
+		return ctx, fmt.Errorf("the assets: option requires an output directory")
+	}
 
 	// Check if this is an embedded XSLT or a file path
 	isEmbedded := xslt.IsEmbeddedPath(xsltPath)
@@ -129,11 +182,39 @@ func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 		}
 	}
 
+	if ctx.TSLTrees == nil || ctx.TSLTrees.IsEmpty() {
+		return ctx, fmt.Errorf("no TSLs to transform")
+	}
+
+	// Collect the TSLs of all trees that the options select
+	targets := collectTransformTargets(ctx.TSLTrees.ToSlice())
+	var allTSLs []*etsi119612.TSL
+	for _, target := range targets {
+		if cfg.selects(target.tsl, target.depth) {
+			allTSLs = append(allTSLs, target.tsl)
+		}
+	}
+	if len(allTSLs) == 0 {
+		return ctx, fmt.Errorf("no TSLs match the transform options")
+	}
+
 	// Check if we need to create an output directory
 	isReplace := mode == "replace"
+	now := time.Now()
 	var outputDir string
 	if !isReplace {
-		outputDir = mode
+		outputDir, err = resolveOutputPath(ctx, mode, allTSLs[0], now)
+		if err != nil {
+			return ctx, err
+		}
+		if err := validation.ValidateOutputDirectory(outputDir); err != nil {
+			return ctx, fmt.Errorf("invalid output directory: %w", err)
+		}
+		unlock, err := ctx.lockOutput(outputDir)
+		if err != nil {
+			return ctx, err
+		}
+		defer unlock()
 		// Create output directory if it doesn't exist
 		info, err := os.Stat(outputDir)
 		if err != nil {
@@ -149,33 +230,35 @@ func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 		}
 	}
 
-	if ctx.TSLTrees == nil || ctx.TSLTrees.IsEmpty() {
-		return ctx, fmt.Errorf("no TSLs to transform")
+	// With assets:inline or assets:copy, the HTML links or embeds a base
+	// stylesheet instead of PicoCSS on its CDN
+	styles, err := cfg.assets.page("", "", "")
+	if err != nil {
+		return ctx, err
 	}
-
-	// Collect all TSLs from all trees
-	var allTSLs []*etsi119612.TSL
-	treeSlice := ctx.TSLTrees.ToSlice()
-	for _, tree := range treeSlice {
-		if tree == nil {
-			continue
+	if cfg.assets.selfContained() {
+		stylesheetURL := ""
+		if len(styles.Stylesheets) > 0 {
+			stylesheetURL = styles.Stylesheets[0]
 		}
-		allTSLs = append(allTSLs, tree.ToSlice()...)
+		cfg.params = append(cfg.params,
+			xsltParam{Name: "stylesheet-url", Value: stylesheetURL},
+			xsltParam{Name: "inline-css", Value: string(styles.CSS)})
 	}
 
 	// Perform concurrent transformations
-	var transformedTSLs []*etsi119612.TSL
-	var err error
-
-	if isReplace {
-		transformedTSLs, err = transformTSLsConcurrent(allTSLs, xsltPath, isEmbedded, "", extension)
-	} else {
-		_, err = transformTSLsConcurrent(allTSLs, xsltPath, isEmbedded, outputDir, extension)
-	}
-
+	cfg.xsltPath = xsltPath
+	cfg.isEmbedded = isEmbedded
+	cfg.outputDir = outputDir
+	cfg.logger = pl.Logger
+	cfg.extensions = ctx.Extensions
+	transformedTSLs, err := transformTSLsConcurrent(allTSLs, cfg)
 	if err != nil {
 		return ctx, err
 	}
+	if err := styles.write(outputDir); err != nil {
+		return ctx, err
+	}
 
 	// Replace the TSLs in the context if in replace mode
 	if isReplace {
@@ -183,16 +266,68 @@ func TransformTSL(pl *Pipeline, ctx *Context, args ...string) (*Context, error)
 		ctx.TSLTrees = nil
 		ctx.EnsureTSLTrees()
 
-		// Add each transformed TSL as a new tree
-		for _, transformedTSL := range transformedTSLs {
-			tree := NewTSLTree(transformedTSL)
-			ctx.AddTSLTree(tree)
+		// Add each TSL as a new tree, transformed if it was selected. TSLs
+		// that were not selected are kept without their references, which
+		// are trees of their own now.
+		next := 0
+		for _, target := range targets {
+			if cfg.selects(target.tsl, target.depth) && next < len(transformedTSLs) {
+				ctx.AddTSLTree(NewTSLTree(transformedTSLs[next]))
+				next++
+				continue
+			}
+			ctx.AddTSLTree(&TSLTree{Root: &TSLNode{TSL: target.tsl}})
 		}
+		return ctx, nil
 	}
 
+	ctx.SetVar(VarTransformDir, outputDir)
+	if cfg.latestLink {
+		link, err := latestLinkPath(ctx, cfg.latest, outputDir, allTSLs[0], now)
+		if err != nil {
+			return ctx, err
+		}
+		if err := swapLatest(pl, link, outputDir); err != nil {
+			return ctx, err
+		}
+	}
 	return ctx, nil
 }
 
+// DefaultTransformWorkers caps the number of concurrent xsltproc processes of a
+// transform step without a "workers:" option. xsltproc is CPU-intensive, and
+// beyond this many processes contention gives diminishing returns.
+const DefaultTransformWorkers = 8
+
+// transformConfig holds the settings of a transform step.
+type transformConfig struct {
+	xsltPath    string         // Stylesheet path, or "embedded:name"
+	isEmbedded  bool           // Whether xsltPath names an embedded stylesheet
+	outputDir   string         // Directory for output files, empty in replace mode
+	extension   string         // File extension for output files
+	workers     int            // Maximum concurrent transformations, 0 for the default
+	params      []xsltParam    // String parameters passed to the stylesheet
+	territories []string       // Transform only TSLs of these territories, if set
+	depths      *depthRange    // Transform only TSLs at these tree depths, if set
+	latestLink  bool           // Point a symlink to the output directory when done
+	latest      string         // Path of that symlink, "" for LatestLink next to the output directory
+	assets      htmlAssets     // How HTML output loads its base stylesheet
+	logger      logging.Logger // Progress logger, may be nil
+
+	extensions map[*etsi119612.ExtensionType]*ExtensionContent // Content of the extensions of the TSLs (see Context.Extensions)
+}
+
+// numWorkers returns the number of workers to transform n TSLs with: the
+// configured number, or GOMAXPROCS capped at DefaultTransformWorkers, and
+// never more than n.
+func (c transformConfig) numWorkers(n int) int {
+	workers := c.workers
+	if workers <= 0 {
+		workers = min(runtime.GOMAXPROCS(0), DefaultTransformWorkers)
+	}
+	return max(1, min(workers, n))
+}
+
 // transformResult holds the result of a single TSL transformation
 type transformResult struct {
 	index          int
@@ -208,36 +343,27 @@ type transformResult struct {
 // providing significant performance improvements when processing multiple TSLs.
 //
 // Performance characteristics:
-//   - Uses a worker pool with up to min(GOMAXPROCS, 8) workers
+//   - Uses a worker pool with cfg.workers workers, by default min(GOMAXPROCS, 8)
 //   - Achieves 2-3x speedup on multi-core systems compared to sequential processing
-//   - Automatically scales to available CPU cores
-//   - Each worker processes TSLs independently without shared state
+//   - Each transformation runs xsltproc in its own temporary directory
+//   - Progress is logged to cfg.logger as transformations complete
+//
+// All TSLs are transformed even if some fail, so that the returned error
+// reports every failure at once. Nothing is written or returned if any fails.
 //
 // Parameters:
 //   - tsls: Slice of TSLs to transform
-//   - xsltPath: Path to XSLT stylesheet (file or embedded)
-//   - isEmbedded: Whether the XSLT is embedded in the binary
-//   - outputDir: Directory for output files (empty for replace mode)
-//   - extension: File extension for output files
+//   - cfg: Stylesheet, output and concurrency settings
 //
 // Returns:
 //   - Transformed TSLs (in replace mode) or nil (when writing to files)
-//   - Error if any transformation fails
-func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded bool, outputDir string, extension string) ([]*etsi119612.TSL, error) {
+//   - Error joining the errors of all failed transformations
+func transformTSLsConcurrent(tsls []*etsi119612.TSL, cfg transformConfig) ([]*etsi119612.TSL, error) {
 	if len(tsls) == 0 {
 		return nil, nil
 	}
-
-	// Determine optimal number of workers (use number of CPUs, max 8)
-	// We cap at 8 because xsltproc is CPU-intensive and too many concurrent
-	// processes can lead to resource contention and diminishing returns
-	numWorkers := runtime.GOMAXPROCS(0)
-	if numWorkers > 8 {
-		numWorkers = 8
-	}
-	if numWorkers < 1 {
-		numWorkers = 1
-	}
+	numWorkers := cfg.numWorkers(len(tsls))
+	start := time.Now()
 
 	// Create channels for work distribution and result collection
 	jobs := make(chan int, len(tsls))
@@ -250,79 +376,7 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 		go func() {
 			defer wg.Done()
 			for i := range jobs {
-				result := transformResult{index: i}
-
-				tsl := tsls[i]
-				if tsl == nil {
-					result.err = fmt.Errorf("TSL at index %d is nil", i)
-					results <- result
-					continue
-				}
-
-				// Create a wrapper struct with the proper XML namespace and element name
-				type TrustServiceStatusList struct {
-					XMLName                        xml.Name `xml:"http://uri.etsi.org/02231/v2# TrustServiceStatusList"`
-					etsi119612.TrustStatusListType `xml:",innerxml"`
-				}
-
-				wrapper := TrustServiceStatusList{
-					TrustStatusListType: tsl.StatusList,
-				}
-
-				xmlData, err := xml.MarshalIndent(wrapper, "", "  ")
-				if err != nil {
-					result.err = fmt.Errorf("failed to marshal TSL to XML: %w", err)
-					results <- result
-					continue
-				}
-
-				// Add XML header
-				xmlData = append([]byte(xml.Header), xmlData...)
-
-				// Apply XSLT transformation
-				var transformedXML []byte
-				if isEmbedded {
-					embeddedName := xslt.ExtractNameFromPath(xsltPath)
-					transformedXML, err = applyEmbeddedXSLTTransformation(xmlData, embeddedName)
-				} else {
-					transformedXML, err = applyFileXSLTTransformation(xmlData, xsltPath)
-				}
-
-				if err != nil {
-					result.err = fmt.Errorf("XSLT transformation failed: %w", err)
-					results <- result
-					continue
-				}
-
-				result.transformedXML = transformedXML
-
-				// If outputDir is empty (replace mode), parse back to TSL
-				if outputDir == "" {
-					var transformedTSL etsi119612.TSL
-					if err := xml.Unmarshal(transformedXML, &transformedTSL); err != nil {
-						result.err = fmt.Errorf("failed to parse transformed XML: %w", err)
-						results <- result
-						continue
-					}
-					result.transformedTSL = &transformedTSL
-				} else {
-					// Determine filename for output
-					filename := fmt.Sprintf("transformed-tsl-%d.%s", i, extension)
-					if tsl.StatusList.TslSchemeInformation != nil &&
-						tsl.StatusList.TslSchemeInformation.TslDistributionPoints != nil &&
-						len(tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI) > 0 {
-
-						uri := tsl.StatusList.TslSchemeInformation.TslDistributionPoints.URI[0]
-						parts := strings.Split(uri, "/")
-						if len(parts) > 0 && parts[len(parts)-1] != "" {
-							baseName := parts[len(parts)-1]
-							filename = fmt.Sprintf("%s.%s", strings.TrimSuffix(baseName, filepath.Ext(baseName)), extension)
-						}
-					}
-					result.filename = filename
-				}
-
-				results <- result
+				results <- transformOne(tsls[i], i, cfg)
 			}
 		}()
 	}
@@ -339,23 +393,51 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 		close(results)
 	}()
 
-	// Collect results
+	// Collect results, logging progress at every tenth of the TSLs
 	resultMap := make(map[int]transformResult)
+	var failed []int
+	done := 0
 	for result := range results {
+		done++
+		resultMap[result.index] = result
 		if result.err != nil {
-			return nil, fmt.Errorf("TSL %d transformation failed: %w", result.index, result.err)
+			failed = append(failed, result.index)
+		}
+		if cfg.logger != nil && (done*10/len(tsls) > (done-1)*10/len(tsls) || done == len(tsls)) {
+			cfg.logger.Info("Transform progress",
+				logging.F("done", done),
+				logging.F("total", len(tsls)),
+				logging.F("failed", len(failed)))
 		}
-		resultMap[result.index] = result
+	}
+	if cfg.logger != nil {
+		cfg.logger.Info("Transformed TSLs",
+			logging.F("stylesheet", cfg.xsltPath),
+			logging.F("count", len(tsls)-len(failed)),
+			logging.F("failed", len(failed)),
+			logging.F("workers", numWorkers),
+			logging.F("duration", time.Since(start).String()))
+	}
+
+	if len(failed) > 0 {
+		sort.Ints(failed)
+		errs := make([]error, 0, len(failed))
+		for _, i := range failed {
+			errs = append(errs, fmt.Errorf("TSL %d transformation failed: %w", i, resultMap[i].err))
+		}
+		return nil, fmt.Errorf("%d of %d TSL transformations failed: %w", len(failed), len(tsls), errors.Join(errs...))
 	}
 
 	// Write files to disk if outputDir specified (must be done sequentially to avoid race conditions)
-	if outputDir != "" {
+	if cfg.outputDir != "" {
+		claims := make(fileNameClaims)
 		for i := 0; i < len(tsls); i++ {
 			result, ok := resultMap[i]
 			if !ok {
 				continue
 			}
-			filePath := filepath.Join(outputDir, result.filename)
+			// Two TSLs with the same distribution point file name get numbered files
+			filePath := claims.claim(filepath.Join(cfg.outputDir, result.filename))
 			if err := os.WriteFile(filePath, result.transformedXML, 0644); err != nil {
 				return nil, fmt.Errorf("failed to write transformed TSL to file %s: %w", filePath, err)
 			}
@@ -376,63 +458,73 @@ func transformTSLsConcurrent(tsls []*etsi119612.TSL, xsltPath string, isEmbedded
 	return transformedTSLs, nil
 }
 
-// applyFileXSLTTransformation applies an XSLT transformation to XML data using an external XSLT file
-// The XSLT content is cached after first read to improve performance on subsequent transformations.
-func applyFileXSLTTransformation(xmlData []byte, xsltPath string) ([]byte, error) {
-	// Get XSLT content from cache or load it
-	xsltContent, err := globalXSLTCache.get("file:"+xsltPath, func() ([]byte, error) {
-		return os.ReadFile(xsltPath)
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to read XSLT file: %w", err)
+// transformOne transforms the TSL at index i of a transform step.
+func transformOne(tsl *etsi119612.TSL, i int, cfg transformConfig) transformResult {
+	result := transformResult{index: i}
+	if tsl == nil {
+		result.err = fmt.Errorf("TSL at index %d is nil", i)
+		return result
 	}
 
-	// Create a temporary file for the input XML
-	tempXmlFile, err := os.CreateTemp("", "input-*.xml")
+	xmlData, err := marshalTSLWithExtensions(tsl, cfg.extensions, cfg.logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp XML file: %w", err)
+		result.err = err
+		return result
 	}
-	defer os.Remove(tempXmlFile.Name())
 
-	// Write XML data to the temp file
-	if _, err := tempXmlFile.Write(xmlData); err != nil {
-		return nil, fmt.Errorf("failed to write XML to temp file: %w", err)
-	}
-	if err := tempXmlFile.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close temp XML file: %w", err)
+	// Apply XSLT transformation
+	var transformedXML []byte
+	if cfg.isEmbedded {
+		embeddedName := xslt.ExtractNameFromPath(cfg.xsltPath)
+		transformedXML, err = applyEmbeddedXSLTTransformation(xmlData, embeddedName, cfg.params...)
+	} else {
+		transformedXML, err = applyFileXSLTTransformation(xmlData, cfg.xsltPath, cfg.params...)
 	}
-
-	// Create a temporary file for the XSLT
-	tempXsltFile, err := os.CreateTemp("", "style-*.xslt")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp XSLT file: %w", err)
+		result.err = fmt.Errorf("XSLT transformation failed: %w", err)
+		return result
 	}
-	defer os.Remove(tempXsltFile.Name())
 
-	// Write cached XSLT data to the temp file
-	if _, err := tempXsltFile.Write(xsltContent); err != nil {
-		return nil, fmt.Errorf("failed to write XSLT to temp file: %w", err)
-	}
-	if err := tempXsltFile.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close temp XSLT file: %w", err)
-	}
+	result.transformedXML = transformedXML
 
-	// Run xsltproc command to apply the transformation
-	cmd := exec.Command("xsltproc", tempXsltFile.Name(), tempXmlFile.Name())
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	// If outputDir is empty (replace mode), parse back to TSL
+	if cfg.outputDir == "" {
+		var transformedTSL etsi119612.TSL
+		if err := xml.Unmarshal(transformedXML, &transformedTSL); err != nil {
+			result.err = fmt.Errorf("failed to parse transformed XML: %w", err)
+			return result
+		}
+		result.transformedTSL = &transformedTSL
+		return result
+	}
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("xsltproc error: %w - %s", err, stderr.String())
+	// Determine filename for output
+	filename := fmt.Sprintf("transformed-tsl-%d.%s", i, cfg.extension)
+	if baseName := distributionPointName(tsl); baseName != "" {
+		if stem := strings.TrimSuffix(baseName, filepath.Ext(baseName)); stem != "" {
+			filename = sanitizeFileName(fmt.Sprintf("%s.%s", stem, cfg.extension))
+		}
 	}
+	result.filename = filename
+	return result
+}
 
-	return stdout.Bytes(), nil
+// applyFileXSLTTransformation applies an XSLT transformation to XML data using an external XSLT file
+// The XSLT content is cached after first read to improve performance on subsequent transformations.
+func applyFileXSLTTransformation(xmlData []byte, xsltPath string, params ...xsltParam) ([]byte, error) {
+	// Get XSLT content from cache or load it
+	xsltContent, err := globalXSLTCache.get("file:"+xsltPath, func() ([]byte, error) {
+		return os.ReadFile(xsltPath)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XSLT file: %w", err)
+	}
+	return runXSLT(xmlData, xsltContent, params)
 }
 
 // applyEmbeddedXSLTTransformation applies an XSLT transformation to XML data using an embedded XSLT file
 // The embedded XSLT content is cached after first access to improve performance.
-func applyEmbeddedXSLTTransformation(xmlData []byte, xsltName string) ([]byte, error) {
+func applyEmbeddedXSLTTransformation(xmlData []byte, xsltName string, params ...xsltParam) ([]byte, error) {
 	// Get embedded XSLT content from cache or load it
 	xsltContent, err := globalXSLTCache.get("embedded:"+xsltName, func() ([]byte, error) {
 		return xslt.Get(xsltName)
@@ -440,39 +532,37 @@ func applyEmbeddedXSLTTransformation(xmlData []byte, xsltName string) ([]byte, e
 	if err != nil {
 		return nil, fmt.Errorf("failed to get embedded XSLT: %w", err)
 	}
+	return runXSLT(xmlData, xsltContent, params)
+}
 
-	// Create a temporary file for the input XML
-	tempXmlFile, err := os.CreateTemp("", "input-*.xml")
+// runXSLT runs xsltproc with a stylesheet and string parameters on XML data.
+// The parameters are passed as separate arguments, never through a shell,
+// so their values need no quoting. Each run gets its own
+// temporary directory, which is also the working directory of xsltproc, so
+// that concurrent runs never share files, and which is removed afterwards.
+func runXSLT(xmlData, xsltContent []byte, params []xsltParam) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "go-trust-xslt-*")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp XML file: %w", err)
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	defer os.Remove(tempXmlFile.Name())
+	defer os.RemoveAll(tmpDir)
 
-	// Write XML data to the temp file
-	if _, err := tempXmlFile.Write(xmlData); err != nil {
+	xmlPath := filepath.Join(tmpDir, "input.xml")
+	if err := os.WriteFile(xmlPath, xmlData, 0600); err != nil {
 		return nil, fmt.Errorf("failed to write XML to temp file: %w", err)
 	}
-	if err := tempXmlFile.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close temp XML file: %w", err)
-	}
-
-	// Create a temporary file for the XSLT
-	tempXsltFile, err := os.CreateTemp("", "style-*.xslt")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp XSLT file: %w", err)
-	}
-	defer os.Remove(tempXsltFile.Name())
-
-	// Write cached XSLT data to the temp file
-	if _, err := tempXsltFile.Write(xsltContent); err != nil {
+	xsltFile := filepath.Join(tmpDir, "style.xslt")
+	if err := os.WriteFile(xsltFile, xsltContent, 0600); err != nil {
 		return nil, fmt.Errorf("failed to write XSLT to temp file: %w", err)
 	}
-	if err := tempXsltFile.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close temp XSLT file: %w", err)
-	}
 
 	// Run xsltproc command to apply the transformation
-	cmd := exec.Command("xsltproc", tempXsltFile.Name(), tempXmlFile.Name())
+	var cmdArgs []string
+	for _, p := range params {
+		cmdArgs = append(cmdArgs, "--stringparam", p.Name, p.Value)
+	}
+	cmd := exec.Command("xsltproc", append(cmdArgs, xsltFile, xmlPath)...)
+	cmd.Dir = tmpDir
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -55,7 +55,7 @@ func BenchmarkTransformTSLConcurrent(b *testing.B) {
 
 			for i := 0; i < b.N; i++ {
 				// Benchmark the concurrent transformation
-				_, err := transformTSLsConcurrent(tsls, "embedded:tsl-to-html.xslt", true, tmpDir, "html")
+				_, err := transformTSLsConcurrent(tsls, transformConfig{xsltPath: "embedded:tsl-to-html.xslt", isEmbedded: true, outputDir: tmpDir, extension: "html"})
 				if err != nil {
 					b.Fatalf("Concurrent transformation failed: %v", err)
 				}
@@ -119,7 +119,7 @@ func BenchmarkTransformTSLSequential(b *testing.B) {
 				// Benchmark sequential transformation by calling the function with numWorkers=1
 				// We can't easily test the old sequential code, so we'll simulate by setting GOMAXPROCS
 				// For a proper comparison, we'd need to keep the old code around
-				_, err := transformTSLsConcurrent(tsls, "embedded:tsl-to-html.xslt", true, tmpDir, "html")
+				_, err := transformTSLsConcurrent(tsls, transformConfig{xsltPath: "embedded:tsl-to-html.xslt", isEmbedded: true, outputDir: tmpDir, extension: "html"})
 				if err != nil {
 					b.Fatalf("Sequential transformation failed: %v", err)
 				}
@@ -161,7 +161,7 @@ func BenchmarkWorkerPoolSizes(b *testing.B) {
 
 	b.Run("20_TSLs_Default_Workers", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
-			_, err := transformTSLsConcurrent(tsls, "embedded:tsl-to-html.xslt", true, tmpDir, "html")
+			_, err := transformTSLsConcurrent(tsls, transformConfig{xsltPath: "embedded:tsl-to-html.xslt", isEmbedded: true, outputDir: tmpDir, extension: "html"})
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
@@ -136,13 +136,13 @@ func BenchmarkConcurrentWithCaching(b *testing.B) {
 		// Do one warmup transformation to populate cache
 		outputDir := filepath.Join(tempDir, "warmup")
 		os.MkdirAll(outputDir, 0755)
-		_, _ = transformTSLsConcurrent(tsls[:1], "embedded:tsl-to-html.xslt", true, outputDir, "html")
+		_, _ = transformTSLsConcurrent(tsls[:1], transformConfig{xsltPath: "embedded:tsl-to-html.xslt", isEmbedded: true, outputDir: outputDir, extension: "html"})
 
 		b.ResetTimer()
 		for i := 0; i < b.N; i++ {
 			outputDir := filepath.Join(tempDir, "with-cache", fmt.Sprintf("%d", i))
 			os.MkdirAll(outputDir, 0755)
-			_, err := transformTSLsConcurrent(tsls, "embedded:tsl-to-html.xslt", true, outputDir, "html")
+			_, err := transformTSLsConcurrent(tsls, transformConfig{xsltPath: "embedded:tsl-to-html.xslt", isEmbedded: true, outputDir: outputDir, extension: "html"})
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
@@ -155,7 +155,7 @@ func BenchmarkConcurrentWithCaching(b *testing.B) {
 			globalXSLTCache.clear()
 			outputDir := filepath.Join(tempDir, "without-cache", fmt.Sprintf("%d", i))
 			os.MkdirAll(outputDir, 0755)
-			_, err := transformTSLsConcurrent(tsls, "embedded:tsl-to-html.xslt", true, outputDir, "html")
+			_, err := transformTSLsConcurrent(tsls, transformConfig{xsltPath: "embedded:tsl-to-html.xslt", isEmbedded: true, outputDir: outputDir, extension: "html"})
 			if err != nil {
 				b.Fatalf("Transformation failed: %v", err)
 			}
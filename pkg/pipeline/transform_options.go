@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+)
+
+// xsltParam is a string parameter passed to a stylesheet with
+// "xsltproc --stringparam".
+type xsltParam struct {
+	Name  string
+	Value string
+}
+
+// xsltParamName matches the names a "param:" option may pass: XML names
+// without a namespace prefix.
+var xsltParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// withLanguageParam returns params with a "lang" parameter set to the most
+// preferred configured language, unless params already sets it.
+func withLanguageParam(params []xsltParam) []xsltParam {
+	for _, p := range params {
+		if p.Name == "lang" {
+			return params
+		}
+	}
+	return append(params, xsltParam{Name: "lang", Value: i18n.PreferredLanguages()[0]})
+}
+
+// depthRange selects TSLs by their depth in a TSL tree, the root being at
+// depth 0.
+type depthRange struct {
+	min, max int
+}
+
+// parseTransformOptions parses the arguments of a transform step that follow
+// the stylesheet and the mode: an optional output file extension and the
+// keyword options "workers:", "param:", "territory:", "depth:", "assets:" and
+// "stylesheet:".
+func parseTransformOptions(args []string) (transformConfig, error) {
+	cfg := transformConfig{extension: "xml"}
+	positional := 0
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "workers:"):
+			n, err := strconv.Atoi(strings.TrimPrefix(arg, "workers:"))
+			if err != nil || n < 1 {
+				return cfg, fmt.Errorf("invalid transform option %s: the number of workers must be at least 1", arg)
+			}
+			cfg.workers = n
+		case strings.HasPrefix(arg, "param:"):
+			name, value, ok := strings.Cut(strings.TrimPrefix(arg, "param:"), "=")
+			name = strings.TrimSpace(name)
+			if !ok || !xsltParamName.MatchString(name) {
+				return cfg, fmt.Errorf("invalid transform option %s: expected param:name=value", arg)
+			}
+			cfg.params = append(cfg.params, xsltParam{Name: name, Value: value})
+		case strings.HasPrefix(arg, "territory:"):
+			territories, err := ValidateTerritories(strings.Split(strings.TrimPrefix(arg, "territory:"), ","))
+			if err != nil {
+				return cfg, fmt.Errorf("invalid transform option %s: %w", arg, err)
+			}
+			if len(territories) == 0 {
+				return cfg, fmt.Errorf("invalid transform option %s: no territory given", arg)
+			}
+			cfg.territories = append(cfg.territories, territories...)
+		case arg == "latest" || strings.HasPrefix(arg, "latest:"):
+			link, ok := parseLatestOption(arg)
+			if !ok {
+				return cfg, fmt.Errorf("invalid transform option %s: the symlink path is empty", arg)
+			}
+			cfg.latestLink, cfg.latest = true, link
+		case strings.HasPrefix(arg, "depth:"):
+			depths, err := parseDepthRange(strings.TrimPrefix(arg, "depth:"))
+			if err != nil {
+				return cfg, fmt.Errorf("invalid transform option %s: %w", arg, err)
+			}
+			cfg.depths = depths
+		case strings.HasPrefix(arg, "assets:") || strings.HasPrefix(arg, "stylesheet:"):
+			if _, err := cfg.assets.parseOption(arg); err != nil {
+				return cfg, err
+			}
+		case positional == 0:
+			positional++
+			cfg.extension = strings.TrimPrefix(arg, ".")
+			if cfg.extension == "" || sanitizeFileName(cfg.extension) != cfg.extension {
+				return cfg, fmt.Errorf("invalid output file extension %q", arg)
+			}
+		default:
+			return cfg, fmt.Errorf("unexpected transform argument %q", arg)
+		}
+	}
+	if err := cfg.assets.check(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// parseDepthRange parses "N" or "N-M" into an inclusive range of tree depths.
+func parseDepthRange(s string) (*depthRange, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	minDepth, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil || minDepth < 0 {
+		return nil, fmt.Errorf("expected a depth N or a range N-M")
+	}
+	maxDepth := minDepth
+	if isRange {
+		maxDepth, err = strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil || maxDepth < minDepth {
+			return nil, fmt.Errorf("expected a depth N or a range N-M")
+		}
+	}
+	return &depthRange{min: minDepth, max: maxDepth}, nil
+}
+
+// selects reports whether a transform step with cfg transforms tsl, found at
+// depth in its TSL tree. Without "territory:" and "depth:" options every TSL
+// is transformed.
+func (c transformConfig) selects(tsl *etsi119612.TSL, depth int) bool {
+	if c.depths != nil && (depth < c.depths.min || depth > c.depths.max) {
+		return false
+	}
+	if len(c.territories) > 0 && !matchesTerritory(tsl, c.territories) {
+		return false
+	}
+	return true
+}
+
+// transformTarget is a TSL of the context with its depth in its tree.
+type transformTarget struct {
+	tsl   *etsi119612.TSL
+	depth int
+}
+
+// collectTransformTargets lists the TSLs of trees in pre-order, with their depths.
+func collectTransformTargets(trees []*TSLTree) []transformTarget {
+	var targets []transformTarget
+	var walk func(node *TSLNode, depth int)
+	walk = func(node *TSLNode, depth int) {
+		if node == nil || node.TSL == nil {
+			return
+		}
+		targets = append(targets, transformTarget{tsl: node.TSL, depth: depth})
+		for _, child := range node.Children {
+			walk(child, depth+1)
+		}
+	}
+	for _, tree := range trees {
+		if tree != nil {
+			walk(tree.Root, 0)
+		}
+	}
+	return targets
+}
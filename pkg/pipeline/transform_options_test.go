@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// territoryContext returns a context with a single tree: an EU list at the
+// root referencing a TSL for each territory, each distributed as
+// <territory>.xml.
+func territoryContext(territories ...string) *Context {
+	newTSL := func(territory string) *etsi119612.TSL {
+		tsl := generateTSL(territory, "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil)
+		tsl.StatusList.TslSchemeInformation.TslSchemeTerritory = territory
+		tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+			URI: []string{"https://tsl.example.com/" + strings.ToLower(territory) + ".xml"},
+		}
+		return tsl
+	}
+	root := newTSL("EU")
+	for _, territory := range territories {
+		root.Referenced = append(root.Referenced, newTSL(territory))
+	}
+	ctx := NewContext()
+	ctx.AddTSLTree(NewTSLTree(root))
+	return ctx
+}
+
+func TestParseTransformOptions(t *testing.T) {
+	cfg, err := parseTransformOptions([]string{"html", "param:lang=sv", "param:title=Trust lists", "territory:se,no", "territory:FI", "depth:1-2"})
+	require.NoError(t, err)
+	assert.Equal(t, "html", cfg.extension)
+	assert.Equal(t, []xsltParam{{Name: "lang", Value: "sv"}, {Name: "title", Value: "Trust lists"}}, cfg.params)
+	assert.Equal(t, []string{"SE", "NO", "FI"}, cfg.territories)
+	assert.Equal(t, &depthRange{min: 1, max: 2}, cfg.depths)
+
+	cfg, err = parseTransformOptions([]string{"depth:0"})
+	require.NoError(t, err)
+	assert.Equal(t, "xml", cfg.extension)
+	assert.Equal(t, &depthRange{min: 0, max: 0}, cfg.depths)
+
+	for _, bad := range []string{"param:lang", "param:=sv", "param:x:lang=sv", "territory:", "depth:", "depth:-1", "depth:2-1", "depth:one"} {
+		_, err := parseTransformOptions([]string{bad})
+		assert.ErrorContains(t, err, "invalid transform option", bad)
+	}
+
+	cfg, err = parseTransformOptions([]string{"html", "assets:inline", "stylesheet:pico.min.css"})
+	require.NoError(t, err)
+	assert.Equal(t, htmlAssets{mode: AssetsInline, stylesheet: "pico.min.css"}, cfg.assets)
+	for _, bad := range []string{"assets:offline", "stylesheet:pico.min.css"} {
+		_, err := parseTransformOptions([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestWithLanguageParam(t *testing.T) {
+	defer i18n.SetPreferredLanguages(nil)
+
+	assert.Equal(t, []xsltParam{{Name: "lang", Value: "en"}}, withLanguageParam(nil))
+
+	i18n.SetPreferredLanguages([]string{"sv", "en"})
+	params := withLanguageParam([]xsltParam{{Name: "title", Value: "Listor"}})
+	assert.Equal(t, []xsltParam{{Name: "title", Value: "Listor"}, {Name: "lang", Value: "sv"}}, params)
+
+	// An explicit lang parameter wins
+	params = []xsltParam{{Name: "lang", Value: "fi"}}
+	assert.Equal(t, params, withLanguageParam(params))
+}
+
+func TestTransformConfig_Selects(t *testing.T) {
+	targets := collectTransformTargets(territoryContext("SE", "NO").TSLTrees.ToSlice())
+	require.Len(t, targets, 3)
+	assert.Equal(t, 0, targets[0].depth)
+	assert.Equal(t, 1, targets[1].depth)
+	assert.Equal(t, 1, targets[2].depth)
+
+	selected := func(cfg transformConfig) []string {
+		var territories []string
+		for _, target := range targets {
+			if cfg.selects(target.tsl, target.depth) {
+				territories = append(territories, target.tsl.StatusList.TslSchemeInformation.TslSchemeTerritory)
+			}
+		}
+		return territories
+	}
+	assert.Equal(t, []string{"EU", "SE", "NO"}, selected(transformConfig{}))
+	assert.Equal(t, []string{"EU"}, selected(transformConfig{depths: &depthRange{min: 0, max: 0}}))
+	assert.Equal(t, []string{"NO"}, selected(transformConfig{territories: []string{"NO"}}))
+	assert.Empty(t, selected(transformConfig{territories: []string{"EU"}, depths: &depthRange{min: 1, max: 1}}))
+}
+
+func TestTransformTSL_Params(t *testing.T) {
+	logs := fakeXSLTProc(t)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	out := t.TempDir()
+
+	_, err := TransformTSL(pl, transformContext("A"), "embedded:tsl-to-html.xslt", out, "html", "param:lang=sv", "param:title=Trust lists")
+	require.NoError(t, err)
+
+	args, err := os.ReadFile(filepath.Join(logs, "args.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "--stringparam lang sv --stringparam title Trust lists ")
+}
+
+func TestTransformTSL_Subset(t *testing.T) {
+	fakeXSLTProc(t)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+
+	out := t.TempDir()
+	_, err := TransformTSL(pl, territoryContext("SE", "NO", "FI"), "embedded:tsl-to-html.xslt", out, "html", "territory:se,fi")
+	require.NoError(t, err)
+	entries, err := os.ReadDir(out)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.Equal(t, []string{"fi.html", "se.html"}, names)
+
+	out = t.TempDir()
+	_, err = TransformTSL(pl, territoryContext("SE", "NO"), "embedded:tsl-to-html.xslt", out, "html", "depth:0")
+	require.NoError(t, err)
+	entries, err = os.ReadDir(out)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "eu.html", entries[0].Name())
+
+	_, err = TransformTSL(pl, territoryContext("SE"), "embedded:tsl-to-html.xslt", t.TempDir(), "html", "territory:DK")
+	assert.ErrorContains(t, err, "no TSLs match the transform options")
+}
+
+func TestTransformTSL_ReplaceSubset(t *testing.T) {
+	fakeXSLTProc(t)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	ctx := territoryContext("SE", "NO")
+	before := collectTransformTargets(ctx.TSLTrees.ToSlice())
+
+	ctx, err := TransformTSL(pl, ctx, "embedded:tsl-to-html.xslt", "replace", "territory:SE")
+	require.NoError(t, err)
+
+	trees := ctx.TSLTrees.ToSlice()
+	require.Len(t, trees, 3, "one tree per TSL, in the original order")
+	assert.Same(t, before[0].tsl, trees[0].Root.TSL, "EU is kept")
+	assert.NotSame(t, before[1].tsl, trees[1].Root.TSL, "SE is transformed")
+	assert.Same(t, before[2].tsl, trees[2].Root.TSL, "NO is kept")
+}
@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeXSLTProc puts an xsltproc on PATH that copies its input to its output,
+// or fails if the input contains FAILME. It records the working directory of
+// every run in runs.log and the arguments in args.log in the returned
+// directory.
+func fakeXSLTProc(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake xsltproc is a shell script")
+	}
+	bin := t.TempDir()
+	logs := t.TempDir()
+	script := fmt.Sprintf(`#!/bin/sh
+pwd >> %q
+echo "$@" >> %q
+for input; do :; done
+if grep -q FAILME "$input"; then echo "cannot transform" >&2; exit 3; fi
+cat "$input"
+`, filepath.Join(logs, "runs.log"), filepath.Join(logs, "args.log"))
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "xsltproc"), []byte(script), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logs
+}
+
+// transformContext returns a context with one tree per service name, each
+// TSL distributed as <name>.xml.
+func transformContext(names ...string) *Context {
+	ctx := NewContext()
+	for _, name := range names {
+		tsl := generateTSL(name, "http://uri.etsi.org/TrstSvc/Svctype/CA/QC", nil)
+		tsl.StatusList.TslSchemeInformation.TslDistributionPoints = &etsi119612.NonEmptyURIListType{
+			URI: []string{"https://tsl.example.com/" + strings.ToLower(name) + ".xml"},
+		}
+		ctx.AddTSLTree(NewTSLTree(tsl))
+	}
+	return ctx
+}
+
+func TestTransformTSL_Parallel(t *testing.T) {
+	logs := fakeXSLTProc(t)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	out := t.TempDir()
+
+	names := []string{"A", "B", "C", "D", "E"}
+	_, err := TransformTSL(pl, transformContext(names...), "embedded:tsl-to-html.xslt", out, "html", "workers:2")
+	require.NoError(t, err)
+
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(out, strings.ToLower(name)+".html"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "TrustServiceStatusList")
+	}
+
+	// Every run had its own working directory, removed afterwards
+	log, err := os.ReadFile(filepath.Join(logs, "runs.log"))
+	require.NoError(t, err)
+	dirs := strings.Fields(string(log))
+	require.Len(t, dirs, len(names))
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		assert.False(t, seen[dir], "working directory reused: %s", dir)
+		seen[dir] = true
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err), "working directory left behind: %s", dir)
+	}
+}
+
+func TestTransformTSL_AggregatesErrors(t *testing.T) {
+	fakeXSLTProc(t)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	out := t.TempDir()
+
+	_, err := TransformTSL(pl, transformContext("A", "FAILME1", "C", "FAILME2"), "embedded:tsl-to-html.xslt", out, "html")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 of 4 TSL transformations failed")
+	assert.Contains(t, err.Error(), "TSL 1 transformation failed")
+	assert.Contains(t, err.Error(), "TSL 3 transformation failed")
+	assert.Contains(t, err.Error(), "cannot transform")
+
+	entries, err := os.ReadDir(out)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing is written when a transformation fails")
+}
+
+func TestTransformTSL_Options(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	for _, bad := range []string{"workers:0", "workers:many"} {
+		_, err := TransformTSL(pl, NewContext(), "embedded:tsl-to-html.xslt", t.TempDir(), bad)
+		assert.ErrorContains(t, err, "invalid transform option", bad)
+	}
+	_, err := TransformTSL(pl, NewContext(), "embedded:tsl-to-html.xslt", t.TempDir(), "html", "extra")
+	assert.ErrorContains(t, err, "unexpected transform argument")
+
+	assert.Equal(t, 3, transformConfig{workers: 3}.numWorkers(10))
+	assert.Equal(t, 2, transformConfig{workers: 3}.numWorkers(2))
+	assert.LessOrEqual(t, transformConfig{}.numWorkers(100), DefaultTransformWorkers)
+	assert.Equal(t, 1, transformConfig{}.numWorkers(1))
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
 	"github.com/SUNET/go-trust/xslt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -285,3 +286,11 @@ func TestEmbeddedTransformTSL(t *testing.T) {
 		})
 	}
 }
+
+func TestTransformTSL_InvalidExtension(t *testing.T) {
+	pl := &Pipeline{Logger: logging.NewLogger(logging.DebugLevel)}
+	for _, ext := range []string{"", ".", "../html", "x/y", "htm?"} {
+		_, err := TransformTSL(pl, NewContext(), "embedded:tsl-to-html.xslt", t.TempDir(), ext)
+		assert.ErrorContains(t, err, "invalid output file extension", ext)
+	}
+}
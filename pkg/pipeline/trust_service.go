@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"slices"
+)
+
+// MatchedTrustService is the trust service a verified certificate chain is
+// anchored in: the provenance of the trust anchor and its fingerprint. It
+// attributes positive trust decisions to the TSL entry they rest on.
+type MatchedTrustService struct {
+	CertProvenance
+	Fingerprint string `json:"fingerprint"` // Hex SHA-256 fingerprint of the trust anchor, the certificate listed for the service
+}
+
+// MatchTrustService returns the trust service a verified certificate chain is
+// anchored in, or nil if the chain is empty or its trust anchor is not listed
+// in any loaded TSL. The chain is ordered leaf first, as returned by
+// x509.Certificate.Verify. When the anchor is listed under several services,
+// the first listed in one of territories is returned, or the first of all if
+// territories is empty or none matches.
+//
+// Parameters:
+//   - chain: A verified certificate chain, leaf first
+//   - territories: Scheme territories the request was restricted to, if any
+//
+// Returns:
+//   - The matched trust service, or nil
+func (ctx *Context) MatchTrustService(chain []*x509.Certificate, territories []string) *MatchedTrustService {
+	if len(chain) == 0 {
+		return nil
+	}
+	anchor := chain[len(chain)-1]
+	services := ctx.FindTrustServices(anchor)
+	if len(services) == 0 {
+		return nil
+	}
+
+	match := services[0]
+	territories = NormalizeTerritories(territories)
+	for _, ts := range services {
+		if territory := territoryOf(ts.TSL); territory != "" && slices.Contains(territories, territory) {
+			match = ts
+			break
+		}
+	}
+	fingerprint := Fingerprint(anchor)
+	prov := NewCertProvenance(match.TSL, match.Provider, match.Service)
+	prov.ProviderPage = ProviderPageURL(prov.Territory, prov.Provider, fingerprint)
+	return &MatchedTrustService{
+		CertProvenance: prov,
+		Fingerprint:    fingerprint,
+	}
+}
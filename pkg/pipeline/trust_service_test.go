@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTrustService(t *testing.T) {
+	ca, err := testutil.NewCA("Shared CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+	unlisted, err := testutil.NewCA("Unlisted CA")
+	require.NoError(t, err)
+
+	se := generateTSL("SE Service", testTypeCAQC, []string{ca.Base64()})
+	se.Source = "https://se.example/tsl.xml"
+	se.StatusList.TslSchemeInformation.TslSchemeTerritory = "SE"
+	fi := generateTSL("FI Service", testTypeCAPKC, []string{ca.Base64()})
+	fi.Source = "https://fi.example/tsl.xml"
+	fi.StatusList.TslSchemeInformation.TslSchemeTerritory = "FI"
+	ctx := NewContext()
+	ctx.AddTSL(se)
+	ctx.AddTSL(fi)
+
+	chain := []*x509.Certificate{leaf.Certificate, ca.Certificate}
+	assert.Equal(t, &MatchedTrustService{
+		CertProvenance: CertProvenance{
+			Source:      "https://se.example/tsl.xml",
+			Territory:   "SE",
+			Provider:    "Test Provider",
+			Service:     "SE Service",
+			ServiceType: testTypeCAQC,
+			Status:      etsi119612.ServiceStatusGranted,
+		},
+		Fingerprint: Fingerprint(ca.Certificate),
+	}, ctx.MatchTrustService(chain, nil))
+
+	// A service of a requested territory is preferred
+	match := ctx.MatchTrustService(chain, []string{"fi"})
+	require.NotNil(t, match)
+	assert.Equal(t, "FI Service", match.Service)
+	assert.Equal(t, "SE Service", ctx.MatchTrustService(chain, []string{"DE"}).Service)
+
+	assert.Nil(t, ctx.MatchTrustService([]*x509.Certificate{unlisted.Certificate}, nil))
+	assert.Nil(t, ctx.MatchTrustService(nil, nil))
+}
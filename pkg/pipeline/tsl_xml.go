@@ -0,0 +1,435 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+)
+
+// Namespaces and prefixes of the documents written by MarshalTSL.
+const (
+	tslNamespace  = "http://uri.etsi.org/02231/v2#"
+	dsigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+	tslPrefix     = "tsl"
+	dsigPrefix    = "ds"
+)
+
+// MarshalTSL serializes tsl to an unsigned ETSI TS 119 612 XML document, as
+// published and transformed by the pipeline.
+//
+// Unlike encoding/xml with the structs of the etsi119612 package, it declares
+// the tsl and ds namespaces on the root element and writes every element with
+// its prefix, xml:lang attributes in the XML namespace, and child elements in
+// schema order (the field order of the etsi119612 structs). Optional elements
+// without a value are left out rather than written empty, so that each DigitalId
+// holds only the identifier it was given, as the schema requires. Any
+// signature of tsl is left out: it does not cover the serialized document.
+//
+// The etsi119612 structs do not retain the content of extensions, so
+// MarshalTSL leaves extensions out: written without their content, critical
+// extensions would make relying parties reject or misread the list. Pipeline
+// steps write the extensions of the TSLs they load with their content, which
+// LoadTSL keeps in Context.Extensions.
+func MarshalTSL(tsl *etsi119612.TSL) ([]byte, error) {
+	data, _, err := marshalTSL(tsl, nil)
+	return data, err
+}
+
+// marshalTSLWithExtensions serializes tsl like MarshalTSL, writing the
+// extensions whose content is in extensions. Any other extension is left out
+// with a warning to logger, which may be nil.
+func marshalTSLWithExtensions(tsl *etsi119612.TSL, extensions map[*etsi119612.ExtensionType]*ExtensionContent, logger logging.Logger) ([]byte, error) {
+	data, omitted, err := marshalTSL(tsl, extensions)
+	if omitted > 0 && logger != nil {
+		logger.Warn("Left out TSL extensions whose content was not retained",
+			logging.F("source", tsl.Source),
+			logging.F("extensions", omitted))
+	}
+	return data, err
+}
+
+// marshalTSL serializes tsl with the extensions whose content is in
+// extensions, and returns the number of extensions left out.
+func marshalTSL(tsl *etsi119612.TSL, extensions map[*etsi119612.ExtensionType]*ExtensionContent) ([]byte, int, error) {
+	if tsl == nil {
+		return nil, 0, fmt.Errorf("cannot marshal nil TSL")
+	}
+	list := tsl.StatusList
+	list.DsSignature = nil
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	w := &tslWriter{enc: enc, buf: &buf, extensions: extensions}
+	root := []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:" + tslPrefix}, Value: tslNamespace},
+		{Name: xml.Name{Local: "xmlns:" + dsigPrefix}, Value: dsigNamespace},
+	}
+	if err := w.element(tslPrefix+":TrustServiceStatusList", tslPrefix, reflect.ValueOf(list), root); err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal TSL to XML: %w", err)
+	}
+	return buf.Bytes(), w.omitted, nil
+}
+
+// ExtensionContent is the content of a TSL extension, as found in the
+// document the TSL was parsed from.
+type ExtensionContent struct {
+	InnerXML   string            // Content of the Extension element, verbatim
+	Namespaces map[string]string // Namespaces in scope of the Extension element, by prefix ("" for the default namespace)
+}
+
+// AttachExtensions reads the content of the extensions of tsl from its raw
+// document and records it in the context, keyed by the extensions of tsl. The
+// document must be the one tsl was parsed from.
+func (ctx *Context) AttachExtensions(tsl *etsi119612.TSL, data []byte) error {
+	contents, err := tslExtensionContents(tsl, data)
+	if err != nil {
+		return err
+	}
+	if ctx.Extensions == nil {
+		ctx.Extensions = make(map[*etsi119612.ExtensionType]*ExtensionContent, len(contents))
+	}
+	for ext, content := range contents {
+		ctx.Extensions[ext] = content
+	}
+	return nil
+}
+
+// tslExtensionContents returns the content of the extensions of tsl, read
+// from the document data it was parsed from.
+func tslExtensionContents(tsl *etsi119612.TSL, data []byte) (map[*etsi119612.ExtensionType]*ExtensionContent, error) {
+	contents, err := parseExtensionContents(data)
+	if err != nil {
+		return nil, err
+	}
+	extensions := tslExtensions(reflect.ValueOf(&tsl.StatusList), nil)
+	if len(extensions) != len(contents) {
+		return nil, fmt.Errorf("TSL document has %d extensions, but the parsed TSL has %d", len(contents), len(extensions))
+	}
+	result := make(map[*etsi119612.ExtensionType]*ExtensionContent, len(extensions))
+	for i, ext := range extensions {
+		result[ext] = contents[i]
+	}
+	return result, nil
+}
+
+// parseExtensionContents returns the content of every Extension element of a
+// TSL document, in document order.
+func parseExtensionContents(data []byte) ([]*ExtensionContent, error) {
+	var contents []*ExtensionContent
+	var scopes []map[string]string // Namespace declarations of the open elements
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return contents, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse TSL extensions: %w", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			declared := make(map[string]string)
+			for _, attr := range tok.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					declared[attr.Name.Local] = attr.Value
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					declared[""] = attr.Value
+				}
+			}
+			if tok.Name.Space != tslNamespace || tok.Name.Local != "Extension" {
+				scopes = append(scopes, declared)
+				continue
+			}
+			content := &ExtensionContent{Namespaces: make(map[string]string)}
+			for _, scope := range append(scopes, declared) {
+				for prefix, uri := range scope {
+					content.Namespaces[prefix] = uri
+				}
+			}
+			var inner struct {
+				XML string `xml:",innerxml"`
+			}
+			if err := dec.DecodeElement(&inner, &tok); err != nil {
+				return nil, fmt.Errorf("failed to parse TSL extensions: %w", err)
+			}
+			content.InnerXML = inner.XML
+			contents = append(contents, content)
+		case xml.EndElement:
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+}
+
+// extensionType is the type of the extensions of the etsi119612 package.
+var extensionType = reflect.TypeOf(&etsi119612.ExtensionType{})
+
+// tslExtensions appends the extensions in v to found, in the order
+// MarshalTSL writes them.
+func tslExtensions(v reflect.Value, found []*etsi119612.ExtensionType) []*etsi119612.ExtensionType {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return found
+		}
+		if v.Type() == extensionType {
+			return append(found, v.Interface().(*etsi119612.ExtensionType))
+		}
+		return tslExtensions(v.Elem(), found)
+	case reflect.Slice:
+		for i := range v.Len() {
+			found = tslExtensions(v.Index(i), found)
+		}
+	case reflect.Struct:
+		for _, f := range tslFields(v) {
+			if !f.attr && !f.chardata {
+				found = tslExtensions(f.value, found)
+			}
+		}
+	}
+	return found
+}
+
+// tslWriter writes the structs of the etsi119612 package as namespaced XML.
+type tslWriter struct {
+	enc        *xml.Encoder
+	buf        *bytes.Buffer                                   // Buffer enc writes to, for the content of extensions
+	extensions map[*etsi119612.ExtensionType]*ExtensionContent // Content of the extensions to write
+	omitted    int                                             // Extensions left out for lack of content
+}
+
+// tslField is a struct field of the etsi119612 package with its xml tag.
+type tslField struct {
+	name     string // Element or attribute name, possibly with a prefix
+	attr     bool
+	chardata bool
+	value    reflect.Value
+}
+
+// tslFields returns the fields of struct v in declaration order, with those
+// of embedded structs without a tag in their place.
+func tslFields(v reflect.Value) []tslField {
+	var fields []tslField
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("xml")
+		if !hasTag && sf.Anonymous {
+			embedded := v.Field(i)
+			for embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					break
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, tslFields(embedded)...)
+			}
+			continue
+		}
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		f := tslField{name: name, value: v.Field(i)}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "attr":
+				f.attr = true
+			case "chardata":
+				f.chardata = true
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// qualify returns name with prefix unless it already has one.
+func qualify(name, prefix string) string {
+	if strings.Contains(name, ":") {
+		return name
+	}
+	return prefix + ":" + name
+}
+
+// element writes v as the element name, with attrs in addition to its own
+// attributes. Child elements without a prefix in their tag get prefix.
+func (w *tslWriter) element(name, prefix string, v reflect.Value, attrs []xml.Attr) error {
+	if v.Type() == extensionType && !v.IsNil() {
+		return w.extension(name, v.Interface().(*etsi119612.ExtensionType))
+	}
+	if list, ok := v.Interface().(*etsi119612.ExtensionsListType); ok && list != nil && !w.hasExtensions(list) {
+		// Extension lists must not be empty
+		w.omitted += len(list.TslExtension)
+		return nil
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if before, _, ok := strings.Cut(name, ":"); ok {
+		prefix = before
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs}
+
+	if v.Kind() != reflect.Struct {
+		text, ok := tslText(v)
+		if !ok {
+			return fmt.Errorf("unsupported type %s of element %s", v.Type(), name)
+		}
+		return w.text(start, text)
+	}
+
+	fields := tslFields(v)
+	var chardata string
+	hasChardata := false
+	for _, f := range fields {
+		switch {
+		case f.attr:
+			value, ok := tslText(f.value)
+			if !ok || (value == "" && f.value.Kind() != reflect.Bool) {
+				continue
+			}
+			attrName := f.name
+			if attrName == "lang" {
+				attrName = "xml:lang"
+			}
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attrName}, Value: value})
+		case f.chardata:
+			chardata, _ = tslText(f.value)
+			hasChardata = true
+		}
+	}
+	if hasChardata {
+		return w.text(start, chardata)
+	}
+
+	if err := w.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.attr || f.chardata {
+			continue
+		}
+		child := qualify(f.name, prefix)
+		if f.value.Kind() == reflect.Slice {
+			for i := range f.value.Len() {
+				if err := w.element(child, prefix, f.value.Index(i), nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := w.element(child, prefix, f.value, nil); err != nil {
+			return err
+		}
+	}
+	return w.enc.EncodeToken(start.End())
+}
+
+// hasExtensions reports whether any extension of list has content to write.
+func (w *tslWriter) hasExtensions(list *etsi119612.ExtensionsListType) bool {
+	for _, ext := range list.TslExtension {
+		if ext != nil && w.extensions[ext] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// extension writes ext as the element name with its content, declaring the
+// namespaces in scope where it was found other than those of the root
+// element. An extension without content is left out.
+func (w *tslWriter) extension(name string, ext *etsi119612.ExtensionType) error {
+	content := w.extensions[ext]
+	if content == nil {
+		w.omitted++
+		return nil
+	}
+	start := xml.StartElement{Name: xml.Name{Local: name}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "Critical"}, Value: strconv.FormatBool(ext.CriticalAttr)},
+	}}
+	prefixes := make([]string, 0, len(content.Namespaces))
+	for prefix := range content.Namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	slices.Sort(prefixes)
+	for _, prefix := range prefixes {
+		uri := content.Namespaces[prefix]
+		if (prefix == tslPrefix && uri == tslNamespace) || (prefix == dsigPrefix && uri == dsigNamespace) {
+			continue
+		}
+		attr := "xmlns"
+		if prefix != "" {
+			attr += ":" + prefix
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: uri})
+	}
+
+	if err := w.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	// The encoder cannot write raw XML, so the content goes to its buffer
+	if err := w.enc.Flush(); err != nil {
+		return err
+	}
+	w.buf.WriteString(content.InnerXML)
+	return w.enc.EncodeToken(start.End())
+}
+
+// text writes an element with text content. Elements with empty text are
+// left out: empty strings are not valid values of any simple type of the
+// schema, so they only stand for elements the TSL does not have.
+func (w *tslWriter) text(start xml.StartElement, text string) error {
+	if text == "" {
+		return nil
+	}
+	if err := w.enc.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeToken(xml.CharData(text)); err != nil {
+		return err
+	}
+	return w.enc.EncodeToken(start.End())
+}
+
+// tslText returns the text of a simple value, dereferencing pointers; a nil
+// pointer has empty text.
+func tslText(v reflect.Value) (string, bool) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", true
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	}
+	return "", false
+}
@@ -0,0 +1,251 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referenceTSLs are schema-valid TSLs as published by their operators, that
+// MarshalTSL must reproduce apart from their signature, given the content of
+// their extensions.
+var referenceTSLs = []string{
+	"testdata/reference/SE-TL.xml",
+}
+
+func unmarshalTSL(t *testing.T, data []byte) *etsi119612.TSL {
+	t.Helper()
+	tsl := &etsi119612.TSL{}
+	require.NoError(t, xml.Unmarshal(data, &tsl.StatusList))
+	return tsl
+}
+
+// xmlElements returns the path of every element of an XML document, by
+// namespace and local name, leaving out signatures and the content of other
+// information, which MarshalTSL does not write.
+func xmlElements(t *testing.T, data []byte) []string {
+	t.Helper()
+	var paths, stack []string
+	skip := 0
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return paths
+		}
+		require.NoError(t, err)
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, tok.Name.Space+" "+tok.Name.Local)
+			if skip > 0 || tok.Name.Local == "Signature" {
+				skip++
+				continue
+			}
+			paths = append(paths, strings.Join(stack, "/"))
+			if tok.Name.Local == "OtherInformation" || tok.Name.Local == "Other" {
+				skip++
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			if skip > 0 {
+				skip--
+			}
+		}
+	}
+}
+
+func TestMarshalTSL_RoundTrip(t *testing.T) {
+	for _, file := range referenceTSLs {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			reference, err := os.ReadFile(file)
+			require.NoError(t, err)
+			tsl := unmarshalTSL(t, reference)
+			extensions, err := tslExtensionContents(tsl, reference)
+			require.NoError(t, err)
+
+			data, omitted, err := marshalTSL(tsl, extensions)
+			require.NoError(t, err)
+			assert.Zero(t, omitted)
+			parsed := unmarshalTSL(t, data)
+
+			want := tsl.StatusList
+			want.DsSignature = nil
+			assert.Equal(t, want, parsed.StatusList, "the serialized TSL parses back into the same TSL")
+
+			parsedExtensions, err := tslExtensionContents(parsed, data)
+			require.NoError(t, err)
+			again, _, err := marshalTSL(parsed, parsedExtensions)
+			require.NoError(t, err)
+			assert.Equal(t, string(data), string(again), "serializing is stable")
+
+			assert.Equal(t, xmlElements(t, reference), xmlElements(t, data), "elements, namespaces and order match the reference")
+		})
+	}
+}
+
+func TestMarshalTSL_Namespaces(t *testing.T) {
+	reference, err := os.ReadFile(referenceTSLs[0])
+	require.NoError(t, err)
+	tsl := unmarshalTSL(t, reference)
+	signature := etsi119612.Signature(&etsi119612.SignatureType{IdAttr: "signature"})
+	tsl.StatusList.DsSignature = &signature
+
+	data, err := MarshalTSL(tsl)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte(xml.Header+`<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" TSLTag="http://uri.etsi.org/19612/TSLTag" Id="id_for_enveloped_signing_of_the_entire_list">`)))
+	assert.NotContains(t, string(data), "<ds:Signature", "the signature does not cover the serialized document")
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	langs := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		assert.Equal(t, tslNamespace, start.Name.Space, start.Name.Local)
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "lang" {
+				assert.Equal(t, "http://www.w3.org/XML/1998/namespace", attr.Name.Space)
+				langs++
+			}
+		}
+		if start.Name.Local == "DigitalId" {
+			var id struct {
+				Children []struct {
+					XMLName xml.Name
+				} `xml:",any"`
+			}
+			require.NoError(t, dec.DecodeElement(&id, &start))
+			assert.Len(t, id.Children, 1, "a DigitalId holds exactly one identifier")
+		}
+	}
+	assert.Positive(t, langs)
+}
+
+// extensionTSL has a critical scheme extension whose content is in the
+// default namespace and in one declared on the Extension element.
+const extensionTSL = `<?xml version="1.0" encoding="UTF-8"?>
+<TrustServiceStatusList xmlns="http://uri.etsi.org/02231/v2#" TSLTag="http://uri.etsi.org/19612/TSLTag">
+  <SchemeInformation>
+    <TSLVersionIdentifier>5</TSLVersionIdentifier>
+    <TSLSequenceNumber>1</TSLSequenceNumber>
+    <SchemeExtensions>
+      <Extension Critical="true" xmlns:ex="urn:example:extension">
+        <ex:Policy ex:version="2">strict</ex:Policy>
+        <SchemeTerritory>SE</SchemeTerritory>
+      </Extension>
+    </SchemeExtensions>
+  </SchemeInformation>
+</TrustServiceStatusList>
+`
+
+func TestMarshalTSL_Extensions(t *testing.T) {
+	tsl := unmarshalTSL(t, []byte(extensionTSL))
+	extensions, err := tslExtensionContents(tsl, []byte(extensionTSL))
+	require.NoError(t, err)
+	require.Len(t, extensions, 1)
+
+	data, omitted, err := marshalTSL(tsl, extensions)
+	require.NoError(t, err)
+	assert.Zero(t, omitted)
+	assert.Contains(t, string(data), `<tsl:Extension Critical="true" xmlns="http://uri.etsi.org/02231/v2#" xmlns:ex="urn:example:extension">`)
+
+	parsed := unmarshalTSL(t, data)
+	assert.True(t, parsed.StatusList.TslSchemeInformation.SchemeExtensions.TslExtension[0].CriticalAttr)
+	contents, err := parseExtensionContents(data)
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	for _, content := range extensions {
+		assert.Equal(t, content.InnerXML, contents[0].InnerXML, "the content is written verbatim")
+	}
+
+	// The content keeps its namespaces
+	var ext struct {
+		Policy struct {
+			Version string `xml:"urn:example:extension version,attr"`
+			Value   string `xml:",chardata"`
+		} `xml:"urn:example:extension Policy"`
+		Territory string `xml:"http://uri.etsi.org/02231/v2# SchemeTerritory"`
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		require.NoError(t, err)
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "Extension" {
+			require.NoError(t, dec.DecodeElement(&ext, &start))
+			break
+		}
+	}
+	assert.Equal(t, "2", ext.Policy.Version)
+	assert.Equal(t, "strict", ext.Policy.Value)
+	assert.Equal(t, "SE", ext.Territory)
+
+	// Extensions without content are left out rather than written empty
+	data, err = MarshalTSL(tsl)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "Extension")
+	_, omitted, err = marshalTSL(tsl, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, omitted)
+}
+
+func TestPublishTSL_KeepsExtensions(t *testing.T) {
+	f := renderExtensionsTSL(t)
+	pl := &Pipeline{Logger: logging.NewLogger(logging.InfoLevel)}
+	ctx, err := LoadTSL(pl, NewContext(), f.Path)
+	require.NoError(t, err)
+	assert.Len(t, ctx.Extensions, 3)
+
+	dir := t.TempDir()
+	_, err = PublishTSL(pl, ctx, dir)
+	require.NoError(t, err)
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	published, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	want, err := parseServiceExtensions(f.Data)
+	require.NoError(t, err)
+	got, err := parseServiceExtensions(published)
+	require.NoError(t, err)
+	assert.Equal(t, want, got, "the published TSL has the critical extensions of the loaded one")
+	assert.NotContains(t, string(published), `Critical="true"/>`)
+}
+
+func TestMarshalTSL_Empty(t *testing.T) {
+	_, err := MarshalTSL(nil)
+	assert.Error(t, err)
+
+	data, err := MarshalTSL(&etsi119612.TSL{StatusList: etsi119612.TrustStatusListType{
+		TSLTagAttr: "http://uri.etsi.org/19612/TSLTag",
+		TslSchemeInformation: &etsi119612.TSLSchemeInformationType{
+			TSLVersionIdentifier: 5,
+			TSLSequenceNumber:    1,
+			TslSchemeTerritory:   "SE",
+		},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, xml.Header+`<tsl:TrustServiceStatusList xmlns:tsl="http://uri.etsi.org/02231/v2#" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" TSLTag="http://uri.etsi.org/19612/TSLTag">
+  <tsl:SchemeInformation>
+    <tsl:TSLVersionIdentifier>5</tsl:TSLVersionIdentifier>
+    <tsl:TSLSequenceNumber>1</tsl:TSLSequenceNumber>
+    <tsl:SchemeTerritory>SE</tsl:SchemeTerritory>
+    <tsl:HistoricalInformationPeriod>0</tsl:HistoricalInformationPeriod>
+  </tsl:SchemeInformation>
+</tsl:TrustServiceStatusList>`, string(data), "elements without a value are left out")
+}
@@ -24,6 +24,11 @@ const (
 
 	// LogicQUORUM requires a configurable threshold of child registries to return decision=true
 	LogicQUORUM LogicOperator = "QUORUM"
+
+	// LogicWEIGHTED requires the summed weights of the child registries returning
+	// decision=true to reach a configurable threshold (see WithWeights and
+	// WithWeightThreshold)
+	LogicWEIGHTED LogicOperator = "WEIGHTED"
 )
 
 // CompositeRegistry implements TrustRegistry by combining multiple child registries
@@ -36,6 +41,9 @@ type CompositeRegistry struct {
 	registries  []TrustRegistry
 	threshold   int           // Used for QUORUM operator
 	timeout     time.Duration // Timeout for evaluating child registries
+
+	weights         map[string]float64 // Used for WEIGHTED operator: weight by child registry name, default 1
+	weightThreshold float64            // Used for WEIGHTED operator: 0 requires more than half the total weight
 }
 
 // compositeResult holds the result from evaluating a child registry
@@ -56,6 +64,23 @@ func WithThreshold(threshold int) CompositeOption {
 	}
 }
 
+// WithWeights sets the weights of child registries, by registry name, for the
+// LogicWEIGHTED operator. Children without a weight have weight 1.
+func WithWeights(weights map[string]float64) CompositeOption {
+	return func(c *CompositeRegistry) {
+		c.weights = weights
+	}
+}
+
+// WithWeightThreshold sets the summed weight of agreeing child registries
+// required by the LogicWEIGHTED operator. Without a threshold, more than half
+// of the total weight must agree.
+func WithWeightThreshold(threshold float64) CompositeOption {
+	return func(c *CompositeRegistry) {
+		c.weightThreshold = threshold
+	}
+}
+
 // WithTimeout sets the timeout for evaluating child registries
 func WithTimeout(timeout time.Duration) CompositeOption {
 	return func(c *CompositeRegistry) {
@@ -82,6 +107,12 @@ func WithDescription(desc string) CompositeOption {
 //	composite := NewCompositeRegistry("quorum", LogicQUORUM, reg1, reg2, reg3,
 //	    WithThreshold(2))
 //
+//	// Trust a national registry on its own, or two federations together
+//	composite := NewCompositeRegistryWithOptions("weighted", LogicWEIGHTED,
+//	    []TrustRegistry{national, fedA, fedB},
+//	    WithWeights(map[string]float64{"national": 2, "fed-a": 1, "fed-b": 1}),
+//	    WithWeightThreshold(2))
+//
 //	// Complex nesting: (A OR B) AND C
 //	orGroup := NewCompositeRegistry("or-group", LogicOR, regA, regB)
 //	composite := NewCompositeRegistry("main", LogicAND, orGroup, regC)
@@ -173,6 +204,7 @@ func (c *CompositeRegistry) Evaluate(ctx context.Context, req *authzen.Evaluatio
 func (c *CompositeRegistry) applyLogic(results []compositeResult) *authzen.EvaluationResponse {
 	// Count agreements and build details
 	var agreedCount, disagreedCount, errorCount int
+	var agreedWeight, totalWeight float64
 	var agreedRegistries, disagreedRegistries []string
 	var details []map[string]interface{}
 
@@ -183,6 +215,11 @@ func (c *CompositeRegistry) applyLogic(results []compositeResult) *authzen.Evalu
 			"type":        info.Type,
 			"duration_ms": r.duration.Milliseconds(),
 		}
+		weight := c.weight(info.Name)
+		totalWeight += weight
+		if c.operator == LogicWEIGHTED {
+			detail["weight"] = weight
+		}
 
 		if r.err != nil {
 			errorCount++
@@ -191,6 +228,7 @@ func (c *CompositeRegistry) applyLogic(results []compositeResult) *authzen.Evalu
 			disagreedRegistries = append(disagreedRegistries, info.Name)
 		} else if r.response != nil && r.response.Decision {
 			agreedCount++
+			agreedWeight += weight
 			detail["decision"] = true
 			agreedRegistries = append(agreedRegistries, info.Name)
 		} else {
@@ -243,6 +281,27 @@ func (c *CompositeRegistry) applyLogic(results []compositeResult) *authzen.Evalu
 			reason["meets_quorum"] = decision
 		}
 
+	case LogicWEIGHTED:
+		reason["agreed_weight"] = agreedWeight
+		reason["total_weight"] = totalWeight
+		if err := c.validateWeights(); err != nil {
+			decision = false
+			reason["error"] = err.Error()
+			break
+		}
+		if c.weightThreshold > 0 {
+			decision = agreedWeight >= c.weightThreshold
+			reason["weight_threshold"] = c.weightThreshold
+		} else {
+			decision = agreedWeight > totalWeight/2
+			reason["weight_threshold"] = totalWeight / 2
+			reason["requires_weighted_majority"] = true
+		}
+		if c.weightThreshold > totalWeight {
+			reason["error"] = fmt.Sprintf("weight threshold (%g) exceeds total weight (%g)", c.weightThreshold, totalWeight)
+		}
+		reason["meets_weight_threshold"] = decision
+
 	default:
 		decision = false
 		reason["error"] = fmt.Sprintf("unknown operator: %s", c.operator)
@@ -256,6 +315,28 @@ func (c *CompositeRegistry) applyLogic(results []compositeResult) *authzen.Evalu
 	}
 }
 
+// weight returns the weight of the named child registry for LogicWEIGHTED.
+func (c *CompositeRegistry) weight(name string) float64 {
+	if w, ok := c.weights[name]; ok {
+		return w
+	}
+	return 1
+}
+
+// validateWeights checks that the weights and threshold of LogicWEIGHTED are
+// not negative.
+func (c *CompositeRegistry) validateWeights() error {
+	if c.weightThreshold < 0 {
+		return fmt.Errorf("weight threshold (%g) cannot be negative", c.weightThreshold)
+	}
+	for name, w := range c.weights {
+		if w < 0 {
+			return fmt.Errorf("weight of %s (%g) cannot be negative", name, w)
+		}
+	}
+	return nil
+}
+
 // SupportedResourceTypes returns the union of all child registry resource types
 func (c *CompositeRegistry) SupportedResourceTypes() []string {
 	typeSet := make(map[string]bool)
@@ -263,6 +263,125 @@ func TestCompositeQUORUM(t *testing.T) {
 	}
 }
 
+// TestCompositeWEIGHTED tests the LogicWEIGHTED operator
+func TestCompositeWEIGHTED(t *testing.T) {
+	weights := map[string]float64{"registry-0": 2, "registry-1": 1}
+	tests := []struct {
+		name              string
+		threshold         float64
+		registryDecisions []bool // registry-2 has the default weight 1
+		expectedDecision  bool
+		expectedAgreed    float64
+		expectedThreshold float64
+		expectError       bool
+	}{
+		{
+			name:              "authoritative registry alone meets threshold",
+			threshold:         2,
+			registryDecisions: []bool{true, false, false},
+			expectedDecision:  true,
+			expectedAgreed:    2,
+			expectedThreshold: 2,
+		},
+		{
+			name:              "two lesser registries meet threshold",
+			threshold:         2,
+			registryDecisions: []bool{false, true, true},
+			expectedDecision:  true,
+			expectedAgreed:    2,
+			expectedThreshold: 2,
+		},
+		{
+			name:              "one lesser registry misses threshold",
+			threshold:         2,
+			registryDecisions: []bool{false, true, false},
+			expectedDecision:  false,
+			expectedAgreed:    1,
+			expectedThreshold: 2,
+		},
+		{
+			name:              "weighted majority without threshold",
+			registryDecisions: []bool{false, true, true},
+			expectedDecision:  false,
+			expectedAgreed:    2,
+			expectedThreshold: 2,
+		},
+		{
+			name:              "threshold above total weight",
+			threshold:         5,
+			registryDecisions: []bool{true, true, true},
+			expectedDecision:  false,
+			expectedAgreed:    4,
+			expectedThreshold: 5,
+			expectError:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var registries []TrustRegistry
+			for i, decision := range tt.registryDecisions {
+				registries = append(registries, &MockRegistry{
+					name:     mockRegistryName(i),
+					decision: decision,
+					types:    []string{"x5c"},
+				})
+			}
+
+			composite := NewCompositeRegistryWithOptions("test-weighted", LogicWEIGHTED, registries,
+				WithWeights(weights), WithWeightThreshold(tt.threshold))
+
+			resp, err := composite.Evaluate(context.Background(), createTestRequest())
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if resp.Decision != tt.expectedDecision {
+				t.Errorf("Decision = %v, want %v", resp.Decision, tt.expectedDecision)
+			}
+
+			reason := resp.Context.Reason
+			if agreed, _ := reason["agreed_weight"].(float64); agreed != tt.expectedAgreed {
+				t.Errorf("Agreed weight = %v, want %v", agreed, tt.expectedAgreed)
+			}
+			if total, _ := reason["total_weight"].(float64); total != 4 {
+				t.Errorf("Total weight = %v, want 4", total)
+			}
+			if threshold, _ := reason["weight_threshold"].(float64); threshold != tt.expectedThreshold {
+				t.Errorf("Weight threshold = %v, want %v", threshold, tt.expectedThreshold)
+			}
+			if _, hasError := reason["error"]; hasError != tt.expectError {
+				t.Errorf("Error in reason = %v, want %v", reason["error"], tt.expectError)
+			}
+
+			details, _ := reason["details"].([]map[string]interface{})
+			for _, d := range details {
+				if d["weight"] != composite.weight(d["registry"].(string)) {
+					t.Errorf("Detail %v has weight %v", d["registry"], d["weight"])
+				}
+			}
+		})
+	}
+}
+
+// TestCompositeWEIGHTED_NegativeWeight tests that negative weights deny
+func TestCompositeWEIGHTED_NegativeWeight(t *testing.T) {
+	composite := NewCompositeRegistryWithOptions("test-weighted", LogicWEIGHTED,
+		[]TrustRegistry{&MockRegistry{name: "registry-0", decision: true, types: []string{"x5c"}}},
+		WithWeights(map[string]float64{"registry-0": -1}))
+
+	resp, err := composite.Evaluate(context.Background(), createTestRequest())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if resp.Decision {
+		t.Error("Decision = true, want false for a negative weight")
+	}
+	if _, ok := resp.Context.Reason["error"]; !ok {
+		t.Error("Reason should explain the invalid weight")
+	}
+}
+
 // TestCompositeNesting tests nested composite registries
 func TestCompositeNesting(t *testing.T) {
 	t.Run("(A OR B) AND C", func(t *testing.T) {
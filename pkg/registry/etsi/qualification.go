@@ -0,0 +1,22 @@
+package etsi
+
+import (
+	"github.com/SUNET/go-trust/pkg/authzen"
+)
+
+// ContextKeyQualification is the AuthZEN request context key that selects
+// qualified status evaluation. For requests with "qualification": true, a
+// positive decision carries the pipeline.Qualification of the trust service
+// the chain is anchored in under the "qualification" key of the response
+// reason. The decision itself is not affected.
+const ContextKeyQualification = "qualification"
+
+// QualificationRequested reports whether an AuthZEN request asks for qualified
+// status evaluation.
+func QualificationRequested(req *authzen.EvaluationRequest) bool {
+	if req == nil || req.Context == nil {
+		return false
+	}
+	requested, _ := req.Context[ContextKeyQualification].(bool)
+	return requested
+}
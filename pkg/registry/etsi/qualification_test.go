@@ -0,0 +1,57 @@
+package etsi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualificationRequested(t *testing.T) {
+	assert.False(t, QualificationRequested(nil))
+	assert.False(t, QualificationRequested(&authzen.EvaluationRequest{}))
+	assert.False(t, QualificationRequested(&authzen.EvaluationRequest{Context: map[string]interface{}{"qualification": "yes"}}))
+	assert.False(t, QualificationRequested(&authzen.EvaluationRequest{Context: map[string]interface{}{"qualification": false}}))
+	assert.True(t, QualificationRequested(&authzen.EvaluationRequest{Context: map[string]interface{}{"qualification": true}}))
+}
+
+func TestTSLRegistry_EvaluateQualification(t *testing.T) {
+	ca, err := testutil.NewCA("Withdrawn CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("Withdrawn Service").
+				WithStatus(pltesting.ServiceStatusWithdrawn).
+				WithCert(ca))).
+		Build())
+	ctx.CertPool = ca.Pool()
+	reg := NewTSLRegistry(ctx, "test")
+
+	req := &authzen.EvaluationRequest{
+		Subject:  authzen.Subject{Type: "key", ID: "alice"},
+		Resource: authzen.Resource{Type: "x5c", ID: "alice", Key: []interface{}{leaf.Base64()}},
+		Context:  map[string]interface{}{ContextKeyQualification: true},
+	}
+	resp, err := reg.Evaluate(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.Decision)
+
+	q, ok := resp.Context.Reason[ContextKeyQualification].(pipeline.Qualification)
+	require.True(t, ok)
+	assert.Equal(t, pipeline.QualificationNonQualified, q.Level)
+	assert.Equal(t, "service status is not granted", q.Reason)
+
+	req.Context = nil
+	resp, err = reg.Evaluate(context.Background(), req)
+	require.NoError(t, err)
+	assert.NotContains(t, resp.Context.Reason, ContextKeyQualification)
+}
@@ -0,0 +1,51 @@
+package etsi
+
+import (
+	"fmt"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+)
+
+// ContextKeyTerritories is the AuthZEN request context key that restricts the
+// scheme territories a trust anchor may be listed for. Its value is a territory
+// code or a list of them, such as "SE" or ["SE", "EU"]. A chain that verifies
+// but whose anchor is only listed in TSLs of other territories is denied with a
+// "territory mismatch" reason.
+const ContextKeyTerritories = "territories"
+
+// RequestedTerritories returns the normalized scheme territories an AuthZEN
+// request is restricted to, or nil if it is not restricted. GR and GB are
+// normalized to the EL and UK of EU trusted lists. It returns an error if the
+// value is neither a string nor a list of strings, or holds an unknown
+// territory code.
+func RequestedTerritories(req *authzen.EvaluationRequest) ([]string, error) {
+	if req == nil || req.Context == nil {
+		return nil, nil
+	}
+	var territories []string
+	switch v := req.Context[ContextKeyTerritories].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		territories = []string{v}
+	case []string:
+		territories = v
+	case []interface{}:
+		territories = make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("context.%s must contain only strings", ContextKeyTerritories)
+			}
+			territories = append(territories, s)
+		}
+	default:
+		return nil, fmt.Errorf("context.%s must be a string or a list of strings", ContextKeyTerritories)
+	}
+	normalized, err := pipeline.ValidateTerritories(territories)
+	if err != nil {
+		return nil, fmt.Errorf("context.%s: %w", ContextKeyTerritories, err)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,90 @@
+package etsi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/SUNET/go-trust/pkg/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestedTerritories(t *testing.T) {
+	withContext := func(value interface{}) *authzen.EvaluationRequest {
+		return &authzen.EvaluationRequest{Context: map[string]interface{}{ContextKeyTerritories: value}}
+	}
+
+	territories, err := RequestedTerritories(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, territories)
+
+	territories, err = RequestedTerritories(&authzen.EvaluationRequest{})
+	assert.NoError(t, err)
+	assert.Nil(t, territories)
+
+	territories, err = RequestedTerritories(withContext("se"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"SE"}, territories)
+
+	territories, err = RequestedTerritories(withContext([]interface{}{"SE", "eu"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"SE", "EU"}, territories)
+
+	territories, err = RequestedTerritories(withContext([]string{"DE"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DE"}, territories)
+
+	territories, err = RequestedTerritories(withContext([]string{"gr", "GB"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"EL", "UK"}, territories, "ISO codes of EU trusted lists using other codes")
+
+	_, err = RequestedTerritories(withContext([]string{"SE", "XX"}))
+	assert.ErrorIs(t, err, validation.ErrUnknownTerritory)
+
+	_, err = RequestedTerritories(withContext([]interface{}{"SE", 46}))
+	assert.Error(t, err)
+
+	_, err = RequestedTerritories(withContext(46))
+	assert.Error(t, err)
+}
+
+func TestTSLRegistry_EvaluateTerritories(t *testing.T) {
+	ca, err := testutil.NewCA("National CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().WithTerritory("SE").
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("National Service").WithCert(ca))).
+		Build())
+	ctx.CertPool = ca.Pool()
+	reg := NewTSLRegistry(ctx, "test")
+
+	evaluate := func(territories interface{}) *authzen.EvaluationResponse {
+		req := &authzen.EvaluationRequest{
+			Subject:  authzen.Subject{Type: "key", ID: "alice"},
+			Resource: authzen.Resource{Type: "x5c", ID: "alice", Key: []interface{}{leaf.Base64()}},
+			Context:  map[string]interface{}{ContextKeyTerritories: territories},
+		}
+		resp, err := reg.Evaluate(context.Background(), req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	assert.True(t, evaluate([]interface{}{"EU", "SE"}).Decision)
+	assert.True(t, evaluate(nil).Decision)
+
+	resp := evaluate("DE")
+	assert.False(t, resp.Decision)
+	assert.Equal(t, "territory mismatch: trust anchor is listed for territory SE, not DE", resp.Context.Reason["error"])
+
+	resp = evaluate(true)
+	assert.False(t, resp.Decision)
+	assert.Contains(t, resp.Context.Reason["error"], "validation error")
+}
@@ -0,0 +1,8 @@
+package etsi
+
+// ReasonKeyTrustService is the key of the response reason under which a
+// positive decision for an ETSI TSL chain carries the
+// pipeline.MatchedTrustService the chain is anchored in: the provider,
+// service, service type and status, the territory and source of the TSL, and
+// the fingerprint of the trust anchor.
+const ReasonKeyTrustService = "trust_service"
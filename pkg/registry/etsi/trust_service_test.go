@@ -0,0 +1,55 @@
+package etsi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/SUNET/go-trust/pkg/pipeline"
+	pltesting "github.com/SUNET/go-trust/pkg/pipeline/testing"
+	"github.com/SUNET/go-trust/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTSLRegistry_EvaluateTrustService(t *testing.T) {
+	ca, err := testutil.NewCA("Attributed CA")
+	require.NoError(t, err)
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	require.NoError(t, err)
+	unlisted, err := testutil.NewCA("Unlisted CA")
+	require.NoError(t, err)
+
+	ctx := pipeline.NewContext()
+	ctx.AddTSL(pltesting.NewTSL().WithTerritory("SE").WithSource("https://tsl.example.com/se.xml").
+		WithProvider(pltesting.NewProvider("Test TSP").
+			WithService(pltesting.NewService("QC Service").WithCert(ca))).
+		Build())
+	ctx.CertPool = ca.Pool()
+	ctx.CertPool.AddCert(unlisted.Certificate)
+	reg := NewTSLRegistry(ctx, "test")
+
+	evaluate := func(cert *testutil.Cert) *authzen.EvaluationResponse {
+		req := &authzen.EvaluationRequest{
+			Subject:  authzen.Subject{Type: "key", ID: "alice"},
+			Resource: authzen.Resource{Type: "x5c", ID: "alice", Key: []interface{}{cert.Base64()}},
+		}
+		resp, err := reg.Evaluate(context.Background(), req)
+		require.NoError(t, err)
+		require.True(t, resp.Decision)
+		return resp
+	}
+
+	ts, ok := evaluate(leaf).Context.Reason[ReasonKeyTrustService].(*pipeline.MatchedTrustService)
+	require.True(t, ok)
+	assert.Equal(t, "Test TSP", ts.Provider)
+	assert.Equal(t, "QC Service", ts.Service)
+	assert.Equal(t, "SE", ts.Territory)
+	assert.Equal(t, "https://tsl.example.com/se.xml", ts.Source)
+	assert.NotEmpty(t, ts.ServiceType)
+	assert.NotEmpty(t, ts.Status)
+	assert.Equal(t, pipeline.Fingerprint(ca.Certificate), ts.Fingerprint)
+
+	// A trusted certificate that no loaded TSL lists is not attributed
+	assert.NotContains(t, evaluate(unlisted).Context.Reason, ReasonKeyTrustService)
+}
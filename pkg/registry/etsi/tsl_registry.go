@@ -80,6 +80,18 @@ func (r *TSLRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationReque
 		}, nil
 	}
 
+	territories, err := RequestedTerritories(req)
+	if err != nil {
+		return &authzen.EvaluationResponse{
+			Decision: false,
+			Context: &authzen.EvaluationResponseContext{
+				Reason: map[string]interface{}{
+					"error": fmt.Sprintf("validation error: %v", err),
+				},
+			},
+		}, nil
+	}
+
 	// Validate certificate chain against TSL certificate pool
 	if r.pipelineCtx == nil || r.pipelineCtx.CertPool == nil {
 		return &authzen.EvaluationResponse{
@@ -96,9 +108,13 @@ func (r *TSLRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationReque
 	opts := x509.VerifyOptions{
 		Roots: r.pipelineCtx.CertPool,
 	}
-	chains, err := certs[0].Verify(opts)
+	chains, err := x509util.VerifyContext(ctx, certs[0], opts)
 	validationDuration := time.Since(start)
 
+	// A passed deadline or cancelled request is not a decision
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	if err != nil {
 		return &authzen.EvaluationResponse{
 			Decision: false,
@@ -111,15 +127,39 @@ func (r *TSLRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationReque
 		}, nil
 	}
 
+	// A trusted chain must also be anchored in an allowed territory, if requested
+	chain := chains[0]
+	if len(territories) > 0 {
+		chain, err = r.pipelineCtx.SelectChainByTerritory(chains, territories)
+		if err != nil {
+			return &authzen.EvaluationResponse{
+				Decision: false,
+				Context: &authzen.EvaluationResponseContext{
+					Reason: map[string]interface{}{
+						"error":         err.Error(),
+						"validation_ms": validationDuration.Milliseconds(),
+					},
+				},
+			}, nil
+		}
+	}
+
 	// Success - certificate is trusted
+	reason := map[string]interface{}{
+		"tsl_count":     r.getTSLCount(),
+		"validation_ms": validationDuration.Milliseconds(),
+		"chain_length":  len(chains),
+	}
+	if ts := r.pipelineCtx.MatchTrustService(chain, territories); ts != nil {
+		reason[ReasonKeyTrustService] = ts
+	}
+	if QualificationRequested(req) {
+		reason[ContextKeyQualification] = r.pipelineCtx.QualifyChain(chain)
+	}
 	return &authzen.EvaluationResponse{
 		Decision: true,
 		Context: &authzen.EvaluationResponseContext{
-			Reason: map[string]interface{}{
-				"tsl_count":     r.getTSLCount(),
-				"validation_ms": validationDuration.Milliseconds(),
-				"chain_length":  len(chains),
-			},
+			Reason: reason,
 		},
 	}, nil
 }
@@ -10,6 +10,7 @@
 //   - manager.go: RegistryManager coordinating multiple registries
 //   - strategies.go: Resolution strategy implementations (FirstMatch, AllRegistries, etc.)
 //   - circuit_breaker.go: Circuit breaker for handling registry failures
+//   - metrics.go: Prometheus metrics of registries, labelled by registry name
 package registry
 
 import (
@@ -96,6 +96,22 @@ func (m *RegistryManager) SupportedResourceTypes() []string {
 	return types
 }
 
+// Supports reports whether a registered registry evaluates resources of the
+// given type.
+func (m *RegistryManager) Supports(resourceType string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, reg := range m.registries {
+		for _, rt := range reg.SupportedResourceTypes() {
+			if rt == resourceType || rt == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Info returns metadata about the RegistryManager
 func (m *RegistryManager) Info() RegistryInfo {
 	m.mu.RLock()
@@ -116,6 +132,38 @@ func (m *RegistryManager) Info() RegistryInfo {
 	}
 }
 
+// Registries returns metadata about the registered registries, in the order
+// they were registered.
+func (m *RegistryManager) Registries() []RegistryInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]RegistryInfo, 0, len(m.registries))
+	for _, reg := range m.registries {
+		infos = append(infos, reg.Info())
+	}
+	return infos
+}
+
+// RegistryHealth is the health of a registry of a RegistryManager.
+type RegistryHealth struct {
+	Info    RegistryInfo // Metadata of the registry
+	Healthy bool         // Whether the registry is operational
+}
+
+// Health returns the health of the registered registries, in the order they
+// were registered.
+func (m *RegistryManager) Health() []RegistryHealth {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	health := make([]RegistryHealth, 0, len(m.registries))
+	for _, reg := range m.registries {
+		health = append(health, RegistryHealth{Info: reg.Info(), Healthy: reg.Healthy()})
+	}
+	return health
+}
+
 // Healthy returns true if at least one registry is healthy
 func (m *RegistryManager) Healthy() bool {
 	m.mu.RLock()
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistryManager_Registries(t *testing.T) {
+	m := NewRegistryManager(FirstMatch, time.Second)
+	if got := m.Registries(); len(got) != 0 {
+		t.Fatalf("expected no registries, got %v", got)
+	}
+
+	m.Register(&MockRegistry{name: "first"})
+	m.Register(&MockRegistry{name: "second"})
+
+	got := m.Registries()
+	if len(got) != 2 || got[0].Name != "first" || got[1].Name != "second" {
+		t.Fatalf("expected registries in registration order, got %v", got)
+	}
+}
+
+func TestRegistryManager_Supports(t *testing.T) {
+	m := NewRegistryManager(FirstMatch, time.Second)
+	if m.Supports("x5c") {
+		t.Fatal("a manager without registries supports no resource type")
+	}
+
+	m.Register(&MockRegistry{name: "oidfed", types: []string{"entity"}})
+	if !m.Supports("entity") || m.Supports("x5c") {
+		t.Fatal("expected only the registered resource type to be supported")
+	}
+
+	m.Register(&MockRegistry{name: "any", types: []string{"*"}})
+	if !m.Supports("x5c") {
+		t.Fatal("expected a wildcard registry to support any resource type")
+	}
+}
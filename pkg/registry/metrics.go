@@ -0,0 +1,154 @@
+package registry
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds Prometheus metrics of trust registries, labelled by registry
+// name. Registries report to it once wrapped with Instrument. Metrics is a
+// prometheus.Collector, to be registered alongside the server's metrics.
+//
+// Children of a CompositeRegistry can be instrumented as well as the
+// composite itself, so that each component of a policy is monitored.
+type Metrics struct {
+	EvaluationsTotal   *prometheus.CounterVec   // Evaluations by registry
+	DecisionsTotal     *prometheus.CounterVec   // Decisions by registry and decision
+	EvaluationDuration *prometheus.HistogramVec // Evaluation latency by registry
+	ErrorsTotal        *prometheus.CounterVec   // Evaluation errors by registry
+	RefreshDuration    *prometheus.HistogramVec // Refresh duration by registry and status
+
+	healthy *prometheus.Desc
+
+	mu         sync.Mutex
+	registries map[string]TrustRegistry // Instrumented registries by name
+}
+
+// NewMetrics creates the registry metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		EvaluationsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_registry_evaluations_total",
+				Help: "Total number of evaluations by trust registry",
+			},
+			[]string{"registry"},
+		),
+		DecisionsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_registry_decisions_total",
+				Help: "Total number of trust decisions by trust registry and decision",
+			},
+			[]string{"registry", "decision"},
+		),
+		EvaluationDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "go_trust_registry_evaluation_duration_seconds",
+				Help:    "Duration of evaluations by trust registry in seconds",
+				Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+			},
+			[]string{"registry"},
+		),
+		ErrorsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "go_trust_registry_errors_total",
+				Help: "Total number of evaluations by trust registry that failed with an error",
+			},
+			[]string{"registry"},
+		),
+		RefreshDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "go_trust_registry_refresh_duration_seconds",
+				Help:    "Duration of trust registry refreshes in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"registry", "status"},
+		),
+		healthy: prometheus.NewDesc(
+			"go_trust_registry_healthy",
+			"Whether the trust registry is operational (1) or not (0)",
+			[]string{"registry"}, nil,
+		),
+		registries: make(map[string]TrustRegistry),
+	}
+}
+
+// Instrument returns reg wrapped so that its evaluations and refreshes are
+// recorded in m under the name from reg.Info(). Its health is reported at
+// each scrape.
+func (m *Metrics) Instrument(reg TrustRegistry) TrustRegistry {
+	name := reg.Info().Name
+
+	m.mu.Lock()
+	m.registries[name] = reg
+	m.mu.Unlock()
+
+	return &instrumentedRegistry{TrustRegistry: reg, metrics: m, name: name}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.EvaluationsTotal.Describe(ch)
+	m.DecisionsTotal.Describe(ch)
+	m.EvaluationDuration.Describe(ch)
+	m.ErrorsTotal.Describe(ch)
+	m.RefreshDuration.Describe(ch)
+	ch <- m.healthy
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.EvaluationsTotal.Collect(ch)
+	m.DecisionsTotal.Collect(ch)
+	m.EvaluationDuration.Collect(ch)
+	m.ErrorsTotal.Collect(ch)
+	m.RefreshDuration.Collect(ch)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, reg := range m.registries {
+		healthy := 0.0
+		if reg.Healthy() {
+			healthy = 1
+		}
+		ch <- prometheus.MustNewConstMetric(m.healthy, prometheus.GaugeValue, healthy, name)
+	}
+}
+
+// instrumentedRegistry records the evaluations and refreshes of a registry.
+type instrumentedRegistry struct {
+	TrustRegistry
+	metrics *Metrics
+	name    string
+}
+
+func (r *instrumentedRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRequest) (*authzen.EvaluationResponse, error) {
+	start := time.Now()
+	resp, err := r.TrustRegistry.Evaluate(ctx, req)
+	r.metrics.EvaluationDuration.WithLabelValues(r.name).Observe(time.Since(start).Seconds())
+	r.metrics.EvaluationsTotal.WithLabelValues(r.name).Inc()
+
+	switch {
+	case err != nil:
+		r.metrics.ErrorsTotal.WithLabelValues(r.name).Inc()
+	case resp != nil:
+		r.metrics.DecisionsTotal.WithLabelValues(r.name, strconv.FormatBool(resp.Decision)).Inc()
+	}
+	return resp, err
+}
+
+func (r *instrumentedRegistry) Refresh(ctx context.Context) error {
+	start := time.Now()
+	err := r.TrustRegistry.Refresh(ctx)
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	r.metrics.RefreshDuration.WithLabelValues(r.name, status).Observe(time.Since(start).Seconds())
+	return err
+}
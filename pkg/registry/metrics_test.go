@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_Instrument(t *testing.T) {
+	m := NewMetrics()
+	trusting := m.Instrument(&MockRegistry{name: "etsi", decision: true, types: []string{"x5c"}})
+	failing := m.Instrument(&MockRegistry{name: "oidfed", err: errors.New("unreachable"), types: []string{"x5c"}})
+
+	// Children of a composite report under their own names
+	composite := m.Instrument(NewCompositeRegistry("policy", LogicAND, trusting, failing))
+	resp, err := composite.Evaluate(context.Background(), createTestRequest())
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if resp.Decision {
+		t.Fatal("Evaluate() decision = true, want false")
+	}
+	if err := trusting.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if got := testutil.ToFloat64(m.EvaluationsTotal.WithLabelValues("etsi")); got != 1 {
+		t.Errorf("evaluations of etsi = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.DecisionsTotal.WithLabelValues("etsi", "true")); got != 1 {
+		t.Errorf("true decisions of etsi = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.ErrorsTotal.WithLabelValues("oidfed")); got != 1 {
+		t.Errorf("errors of oidfed = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.DecisionsTotal.WithLabelValues("policy", "false")); got != 1 {
+		t.Errorf("false decisions of policy = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.EvaluationDuration); got != 3 {
+		t.Errorf("evaluation duration series = %d, want 3", got)
+	}
+	if got := testutil.CollectAndCount(m.RefreshDuration); got != 1 {
+		t.Errorf("refresh duration series = %d, want 1", got)
+	}
+
+	// Instrumented registries keep their identity
+	if trusting.Info().Name != "etsi" || len(trusting.SupportedResourceTypes()) != 1 {
+		t.Errorf("Info() = %+v, want the wrapped registry's info", trusting.Info())
+	}
+}
+
+func TestMetrics_Healthy(t *testing.T) {
+	m := NewMetrics()
+	m.Instrument(&MockRegistry{name: "etsi"})
+	m.Instrument(&MockRegistry{name: "oidfed", err: errors.New("unreachable")})
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(m); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	expected := `
+# HELP go_trust_registry_healthy Whether the trust registry is operational (1) or not (0)
+# TYPE go_trust_registry_healthy gauge
+go_trust_registry_healthy{registry="etsi"} 1
+go_trust_registry_healthy{registry="oidfed"} 0
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "go_trust_registry_healthy"); err != nil {
+		t.Error(err)
+	}
+}
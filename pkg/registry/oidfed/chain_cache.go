@@ -0,0 +1,242 @@
+package oidfed
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	oidfed "github.com/go-oidfed/lib"
+)
+
+// Cache defaults, used when the corresponding Config fields are zero.
+const (
+	DefaultCacheTTL         = time.Hour       // Longest time resolved chains are reused
+	DefaultNegativeCacheTTL = time.Minute     // How long a failed resolution is remembered
+	DefaultPrefetchWindow   = 5 * time.Minute // How long before expiry hot entities are re-resolved
+	DefaultMaxCacheEntries  = 10000           // Number of entities kept in the cache
+)
+
+// CacheStats reports the state of the trust chain cache of an OIDFedRegistry.
+type CacheStats struct {
+	Entries    int    // Entities currently cached, including failed resolutions
+	Hits       uint64 // Evaluations answered from the cache
+	Misses     uint64 // Evaluations that had to resolve trust chains
+	Prefetches uint64 // Resolutions started by Refresh for hot entities
+	Evictions  uint64 // Entries dropped because they expired or the cache was full
+
+	LastRefresh time.Time // When Refresh last looked for entries to prefetch; zero if never
+}
+
+// chainCache caches the trust chains resolved for entity IDs. Entries expire
+// with the earliest expiration of the statements in their chains, and at the
+// latest after ttl. Entities without a valid chain are cached for negativeTTL,
+// so that unknown entities cannot force a resolution on every request.
+//
+// Concurrent lookups of the same entity share one resolution. A resolution
+// whose callers gave up still completes and fills the cache.
+type chainCache struct {
+	resolve        func(entityID string) oidfed.TrustChains
+	ttl            time.Duration
+	negativeTTL    time.Duration
+	prefetchWindow time.Duration
+	maxEntries     int
+	now            func() time.Time
+
+	mu          sync.Mutex
+	entries     map[string]*cacheEntry
+	inflight    map[string]*resolution
+	lastRefresh time.Time
+
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+	prefetches atomic.Uint64
+	evictions  atomic.Uint64
+}
+
+type cacheEntry struct {
+	chains   oidfed.TrustChains
+	expires  time.Time
+	lastUsed time.Time
+}
+
+// resolution is a trust chain resolution in progress; done is closed when
+// chains is set.
+type resolution struct {
+	done   chan struct{}
+	chains oidfed.TrustChains
+}
+
+func newChainCache(resolve func(string) oidfed.TrustChains, config Config) *chainCache {
+	c := &chainCache{
+		resolve:        resolve,
+		ttl:            config.CacheTTL,
+		negativeTTL:    config.NegativeCacheTTL,
+		prefetchWindow: config.PrefetchWindow,
+		maxEntries:     config.MaxCacheEntries,
+		now:            time.Now,
+		entries:        make(map[string]*cacheEntry),
+		inflight:       make(map[string]*resolution),
+	}
+	if c.ttl <= 0 {
+		c.ttl = DefaultCacheTTL
+	}
+	if c.negativeTTL <= 0 {
+		c.negativeTTL = DefaultNegativeCacheTTL
+	}
+	if c.prefetchWindow <= 0 {
+		c.prefetchWindow = DefaultPrefetchWindow
+	}
+	if c.maxEntries <= 0 {
+		c.maxEntries = DefaultMaxCacheEntries
+	}
+	return c
+}
+
+// get returns the valid trust chains of entityID, from the cache if possible.
+// It returns ctx.Err() if ctx is done before the chains are resolved.
+func (c *chainCache) get(ctx context.Context, entityID string) (oidfed.TrustChains, error) {
+	c.mu.Lock()
+	now := c.now()
+	if e, ok := c.entries[entityID]; ok && now.Before(e.expires) {
+		e.lastUsed = now
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return e.chains, nil
+	}
+	res := c.startLocked(entityID)
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	return res.wait(ctx)
+}
+
+// refresh re-resolves the hot entities, those looked up since the previous
+// refresh, whose chains expire within the prefetch window, and drops expired
+// entries that are not hot.
+func (c *chainCache) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	now := c.now()
+	var hot []string
+	for id, e := range c.entries {
+		used := e.lastUsed.After(c.lastRefresh)
+		switch {
+		case used && e.expires.Before(now.Add(c.prefetchWindow)):
+			hot = append(hot, id)
+		case !used && !now.Before(e.expires):
+			delete(c.entries, id)
+			c.evictions.Add(1)
+		}
+	}
+	c.lastRefresh = now
+	c.mu.Unlock()
+
+	sort.Strings(hot)
+	for _, id := range hot {
+		c.mu.Lock()
+		res := c.startLocked(id)
+		c.mu.Unlock()
+		c.prefetches.Add(1)
+		if _, err := res.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startLocked returns the resolution of entityID in progress, starting one if
+// there is none. c.mu must be held.
+func (c *chainCache) startLocked(entityID string) *resolution {
+	if res, ok := c.inflight[entityID]; ok {
+		return res
+	}
+	res := &resolution{done: make(chan struct{})}
+	c.inflight[entityID] = res
+	go func() {
+		res.chains = c.resolve(entityID)
+		c.store(entityID, res.chains)
+		close(res.done)
+	}()
+	return res
+}
+
+// store caches the chains resolved for entityID.
+func (c *chainCache) store(entityID string, chains oidfed.TrustChains) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inflight, entityID)
+	now := c.now()
+	entry := &cacheEntry{chains: chains, expires: c.expiry(now, chains), lastUsed: now}
+	if old, ok := c.entries[entityID]; ok {
+		// A prefetch does not count as use
+		entry.lastUsed = old.lastUsed
+	} else if len(c.entries) >= c.maxEntries {
+		c.evictLocked(now)
+	}
+	c.entries[entityID] = entry
+}
+
+// expiry returns when chains resolved at now stop being reused.
+func (c *chainCache) expiry(now time.Time, chains oidfed.TrustChains) time.Time {
+	if len(chains) == 0 {
+		return now.Add(c.negativeTTL)
+	}
+	expires := now.Add(c.ttl)
+	for _, chain := range chains {
+		if exp := chain.ExpiresAt(); !exp.IsZero() && exp.Before(expires) {
+			expires = exp.Time
+		}
+	}
+	return expires
+}
+
+// evictLocked makes room for an entry: it drops expired entries, or the least
+// recently used entry if none has expired. c.mu must be held.
+func (c *chainCache) evictLocked(now time.Time) {
+	var lru string
+	var lruUsed time.Time
+	evicted := false
+	for id, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, id)
+			c.evictions.Add(1)
+			evicted = true
+			continue
+		}
+		if lru == "" || e.lastUsed.Before(lruUsed) {
+			lru, lruUsed = id, e.lastUsed
+		}
+	}
+	if !evicted && lru != "" {
+		delete(c.entries, lru)
+		c.evictions.Add(1)
+	}
+}
+
+// stats returns the current cache statistics.
+func (c *chainCache) stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	lastRefresh := c.lastRefresh
+	c.mu.Unlock()
+	return CacheStats{
+		Entries:     entries,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Prefetches:  c.prefetches.Load(),
+		Evictions:   c.evictions.Load(),
+		LastRefresh: lastRefresh,
+	}
+}
+
+// wait returns the resolved chains, or ctx.Err() if ctx is done first.
+func (res *resolution) wait(ctx context.Context) (oidfed.TrustChains, error) {
+	select {
+	case <-res.done:
+		return res.chains, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
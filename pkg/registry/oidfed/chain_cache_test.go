@@ -0,0 +1,227 @@
+package oidfed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/unixtime"
+)
+
+// testClock is a settable clock for chainCache.now.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// expiringChains returns a single chain for entityID whose statements expire
+// at exp.
+func expiringChains(entityID string, exp time.Time) oidfed.TrustChains {
+	stmt := &oidfed.EntityStatement{EntityStatementPayload: oidfed.EntityStatementPayload{
+		Issuer:    entityID,
+		Subject:   entityID,
+		ExpiresAt: unixtime.Unixtime{Time: exp},
+	}}
+	return oidfed.TrustChains{oidfed.TrustChain{stmt}}
+}
+
+// newTestCache returns a cache on clock whose resolver returns chains for
+// entities in trusted, expiring at the given time, and counts resolutions.
+func newTestCache(clock *testClock, trusted map[string]time.Time, config Config) (*chainCache, *atomic.Int32) {
+	var calls atomic.Int32
+	c := newChainCache(func(entityID string) oidfed.TrustChains {
+		calls.Add(1)
+		if exp, ok := trusted[entityID]; ok {
+			return expiringChains(entityID, exp)
+		}
+		return nil
+	}, config)
+	c.now = clock.Now
+	return c, &calls
+}
+
+func TestChainCache_HitsAndExpiry(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	rp := "https://rp.example.com"
+	cache, calls := newTestCache(clock, map[string]time.Time{rp: clock.now.Add(10 * time.Minute)}, Config{CacheTTL: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		chains, err := cache.get(context.Background(), rp)
+		if err != nil {
+			t.Fatalf("get() error = %v", err)
+		}
+		if len(chains) != 1 {
+			t.Fatalf("get() returned %d chains, want 1", len(chains))
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("resolutions = %d, want 1", calls.Load())
+	}
+	if s := cache.stats(); s.Hits != 2 || s.Misses != 1 || s.Entries != 1 {
+		t.Errorf("stats() = %+v, want 2 hits, 1 miss, 1 entry", s)
+	}
+
+	// The chain expires before the TTL, and is resolved again
+	clock.Advance(10 * time.Minute)
+	if _, err := cache.get(context.Background(), rp); err != nil {
+		t.Fatalf("get() error = %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("resolutions after expiry = %d, want 2", calls.Load())
+	}
+}
+
+func TestChainCache_TTLBoundsExpiry(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	rp := "https://rp.example.com"
+	cache, calls := newTestCache(clock, map[string]time.Time{rp: clock.now.Add(24 * time.Hour)}, Config{CacheTTL: time.Hour})
+
+	_, _ = cache.get(context.Background(), rp)
+	clock.Advance(time.Hour)
+	_, _ = cache.get(context.Background(), rp)
+	if calls.Load() != 2 {
+		t.Errorf("resolutions = %d, want 2 (cached at most CacheTTL)", calls.Load())
+	}
+}
+
+func TestChainCache_NegativeCaching(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	cache, calls := newTestCache(clock, nil, Config{NegativeCacheTTL: time.Minute})
+	unknown := "https://unknown.example.com"
+
+	for i := 0; i < 2; i++ {
+		chains, err := cache.get(context.Background(), unknown)
+		if err != nil || len(chains) != 0 {
+			t.Fatalf("get() = %v, %v; want no chains", chains, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("resolutions = %d, want 1", calls.Load())
+	}
+
+	clock.Advance(time.Minute)
+	_, _ = cache.get(context.Background(), unknown)
+	if calls.Load() != 2 {
+		t.Errorf("resolutions after negative TTL = %d, want 2", calls.Load())
+	}
+}
+
+func TestChainCache_Cancellation(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	rp := "https://rp.example.com"
+	release := make(chan struct{})
+	var calls atomic.Int32
+	cache := newChainCache(func(entityID string) oidfed.TrustChains {
+		calls.Add(1)
+		<-release
+		return expiringChains(entityID, clock.Now().Add(time.Hour))
+	}, Config{})
+	cache.now = clock.Now
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.get(ctx, rp); !errors.Is(err, context.Canceled) {
+		t.Fatalf("get() error = %v, want context.Canceled", err)
+	}
+
+	// The abandoned resolution is joined, and fills the cache once done
+	done := make(chan oidfed.TrustChains)
+	go func() {
+		chains, _ := cache.get(context.Background(), rp)
+		done <- chains
+	}()
+	close(release)
+	if chains := <-done; len(chains) != 1 {
+		t.Errorf("get() returned %d chains, want 1", len(chains))
+	}
+	if calls.Load() != 1 {
+		t.Errorf("resolutions = %d, want 1", calls.Load())
+	}
+}
+
+func TestChainCache_Refresh(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	hot, cold := "https://hot.example.com", "https://cold.example.com"
+	trusted := map[string]time.Time{
+		hot:  clock.now.Add(10 * time.Minute),
+		cold: clock.now.Add(10 * time.Minute),
+	}
+	cache, calls := newTestCache(clock, trusted, Config{PrefetchWindow: 5 * time.Minute})
+
+	_, _ = cache.get(context.Background(), hot)
+	_, _ = cache.get(context.Background(), cold)
+	if err := cache.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("resolutions = %d, want 2 (nothing expires within the window yet)", calls.Load())
+	}
+
+	// Only the entity evaluated since the last refresh is prefetched
+	clock.Advance(6 * time.Minute)
+	trusted[hot] = clock.now.Add(10 * time.Minute)
+	_, _ = cache.get(context.Background(), hot)
+	if err := cache.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("resolutions = %d, want 3", calls.Load())
+	}
+	if s := cache.stats(); s.Prefetches != 1 {
+		t.Errorf("prefetches = %d, want 1", s.Prefetches)
+	}
+
+	// The prefetched chain is served without resolving; the cold entry is
+	// dropped once expired
+	clock.Advance(5 * time.Minute)
+	_, _ = cache.get(context.Background(), hot)
+	if calls.Load() != 3 {
+		t.Errorf("resolutions = %d, want the prefetched chain to be used", calls.Load())
+	}
+	if err := cache.refresh(context.Background()); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if s := cache.stats(); s.Entries != 1 || s.Evictions != 1 {
+		t.Errorf("stats() = %+v, want 1 entry and 1 eviction", s)
+	}
+}
+
+func TestChainCache_Eviction(t *testing.T) {
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	a, b, c := "https://a.example.com", "https://b.example.com", "https://c.example.com"
+	exp := clock.now.Add(time.Hour)
+	cache, calls := newTestCache(clock, map[string]time.Time{a: exp, b: exp, c: exp}, Config{MaxCacheEntries: 2})
+
+	_, _ = cache.get(context.Background(), a)
+	clock.Advance(time.Second)
+	_, _ = cache.get(context.Background(), b)
+	clock.Advance(time.Second)
+	_, _ = cache.get(context.Background(), a)
+	_, _ = cache.get(context.Background(), c)
+
+	if s := cache.stats(); s.Entries != 2 || s.Evictions != 1 {
+		t.Errorf("stats() = %+v, want 2 entries and 1 eviction", s)
+	}
+	// b was the least recently used
+	_, _ = cache.get(context.Background(), a)
+	_, _ = cache.get(context.Background(), b)
+	if calls.Load() != 4 {
+		t.Errorf("resolutions = %d, want 4", calls.Load())
+	}
+}
@@ -0,0 +1,189 @@
+package oidfed
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	oidfed "github.com/go-oidfed/lib"
+)
+
+// Kinds of metadata constraint checks, reported in ConstraintViolation.Constraint.
+const (
+	ConstraintRequired   = "required"
+	ConstraintOneOf      = "one_of"
+	ConstraintIncludes   = "includes"
+	ConstraintURISchemes = "uri_schemes"
+	ConstraintMetadata   = "metadata" // The metadata could not be resolved from the trust chain
+)
+
+// MetadataConstraint is a requirement on one claim of the metadata of the
+// evaluated entity. It is checked after the trust chain is resolved, against
+// the entity's metadata with the metadata policies of its superiors applied.
+// Only Required applies to an absent claim; the other checks apply to the
+// values of a present claim.
+type MetadataConstraint struct {
+	// EntityType is the metadata section, e.g. "openid_relying_party" or "wallet_provider"
+	EntityType string `json:"entity_type"`
+
+	// Claim is the claim within the section, e.g. "redirect_uris"
+	Claim string `json:"claim"`
+
+	// Required requires the claim to be present and not empty
+	Required bool `json:"required,omitempty"`
+
+	// OneOf requires every value of the claim to be one of these
+	OneOf []string `json:"one_of,omitempty"`
+
+	// Includes requires the claim to include all of these values
+	Includes []string `json:"includes,omitempty"`
+
+	// URISchemes requires every value of the claim to be a URI with one of these schemes
+	URISchemes []string `json:"uri_schemes,omitempty"`
+}
+
+// ConstraintViolation describes how the metadata of an entity fails a
+// MetadataConstraint. Violations are returned in the reason of denials.
+type ConstraintViolation struct {
+	EntityType string `json:"entity_type"`
+	Claim      string `json:"claim,omitempty"`
+	Constraint string `json:"constraint"` // One of the Constraint* kinds
+	Message    string `json:"message"`
+}
+
+// validate checks that the constraint names a claim and checks something.
+func (c MetadataConstraint) validate() error {
+	if c.EntityType == "" || c.Claim == "" {
+		return fmt.Errorf("entity_type and claim are required")
+	}
+	if !c.Required && len(c.OneOf) == 0 && len(c.Includes) == 0 && len(c.URISchemes) == 0 {
+		return fmt.Errorf("%s.%s: no check configured", c.EntityType, c.Claim)
+	}
+	return nil
+}
+
+// check returns the violations of the constraint by metadata, which maps
+// entity types to their claims.
+func (c MetadataConstraint) check(metadata map[string]map[string]any) []ConstraintViolation {
+	violation := func(kind, format string, args ...any) ConstraintViolation {
+		return ConstraintViolation{
+			EntityType: c.EntityType,
+			Claim:      c.Claim,
+			Constraint: kind,
+			Message:    fmt.Sprintf(format, args...),
+		}
+	}
+
+	values := claimValues(metadata[c.EntityType][c.Claim])
+	if len(values) == 0 {
+		if c.Required {
+			return []ConstraintViolation{violation(ConstraintRequired, "%s metadata has no %s", c.EntityType, c.Claim)}
+		}
+		return nil
+	}
+
+	var violations []ConstraintViolation
+	if len(c.OneOf) > 0 {
+		for _, v := range values {
+			if !slices.Contains(c.OneOf, v) {
+				violations = append(violations, violation(ConstraintOneOf, "%s %q is not one of %v", c.Claim, v, c.OneOf))
+			}
+		}
+	}
+	for _, want := range c.Includes {
+		if !slices.Contains(values, want) {
+			violations = append(violations, violation(ConstraintIncludes, "%s does not include %q", c.Claim, want))
+		}
+	}
+	if len(c.URISchemes) > 0 {
+		for _, v := range values {
+			u, err := url.Parse(v)
+			if err != nil || !containsFold(c.URISchemes, u.Scheme) {
+				violations = append(violations, violation(ConstraintURISchemes, "%s %q does not use one of the schemes %v", c.Claim, v, c.URISchemes))
+			}
+		}
+	}
+	return violations
+}
+
+// checkConstraints returns the violations of the metadata constraints by the
+// metadata resolved from chain, or nil if it satisfies all of them.
+func (r *OIDFedRegistry) checkConstraints(chain oidfed.TrustChain) []ConstraintViolation {
+	if len(r.metadataConstraints) == 0 {
+		return nil
+	}
+
+	metadata, err := chainMetadata(chain)
+	if err != nil {
+		return []ConstraintViolation{{
+			Constraint: ConstraintMetadata,
+			Message:    err.Error(),
+		}}
+	}
+
+	var violations []ConstraintViolation
+	for _, c := range r.metadataConstraints {
+		violations = append(violations, c.check(metadata)...)
+	}
+	return violations
+}
+
+// chainMetadata returns the metadata of the leaf entity of chain, with the
+// metadata policies of the chain applied, as claims by entity type.
+func chainMetadata(chain oidfed.TrustChain) (map[string]map[string]any, error) {
+	metadata, err := chain.Metadata()
+	if err != nil {
+		return nil, fmt.Errorf("applying metadata policies: %w", err)
+	}
+	claims := make(map[string]map[string]any)
+	if metadata == nil {
+		return claims, nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("encoding metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("decoding metadata: %w", err)
+	}
+	return claims, nil
+}
+
+// claimValues returns the values of a claim as strings: a single value for
+// scalar claims, one per element for array claims.
+func claimValues(claim any) []string {
+	switch v := claim.(type) {
+	case nil:
+		return nil
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			values = append(values, claimValues(e)...)
+		}
+		return values
+	case map[string]any:
+		if len(v) == 0 {
+			return nil
+		}
+		// Objects are present, but have no values to compare
+		return []string{""}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+func containsFold(values []string, want string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package oidfed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/authzen"
+	oidfed "github.com/go-oidfed/lib"
+	"github.com/go-oidfed/lib/unixtime"
+)
+
+// relyingPartyChain returns a single-statement chain for a relying party with
+// the given redirect URIs and client registration types, which also
+// advertises wallet_provider metadata if walletProvider is not nil.
+func relyingPartyChain(entityID string, redirectURIs, registrationTypes []string, walletProvider map[string]any) oidfed.TrustChain {
+	metadata := &oidfed.Metadata{
+		RelyingParty: &oidfed.OpenIDRelyingPartyMetadata{
+			RedirectURIS:            redirectURIs,
+			ClientRegistrationTypes: registrationTypes,
+		},
+	}
+	if walletProvider != nil {
+		metadata.Extra = map[string]any{"wallet_provider": walletProvider}
+	}
+	return oidfed.TrustChain{{EntityStatementPayload: oidfed.EntityStatementPayload{
+		Issuer:    entityID,
+		Subject:   entityID,
+		ExpiresAt: unixtime.Unixtime{Time: time.Now().Add(time.Hour)},
+		Metadata:  metadata,
+	}}}
+}
+
+func TestMetadataConstraint_Check(t *testing.T) {
+	metadata := map[string]map[string]any{
+		"openid_relying_party": {
+			"redirect_uris":             []any{"https://rp.example.com/cb", "http://rp.example.com/cb"},
+			"client_registration_types": []any{"automatic"},
+			"application_type":          "web",
+		},
+	}
+
+	tests := []struct {
+		name       string
+		constraint MetadataConstraint
+		want       []string // Constraint kinds of the expected violations
+	}{
+		{
+			name:       "required present",
+			constraint: MetadataConstraint{EntityType: "openid_relying_party", Claim: "redirect_uris", Required: true},
+		},
+		{
+			name:       "required absent",
+			constraint: MetadataConstraint{EntityType: "openid_relying_party", Claim: "jwks_uri", Required: true},
+			want:       []string{ConstraintRequired},
+		},
+		{
+			name:       "required entity type absent",
+			constraint: MetadataConstraint{EntityType: "wallet_provider", Claim: "token_endpoint", Required: true},
+			want:       []string{ConstraintRequired},
+		},
+		{
+			name:       "optional absent",
+			constraint: MetadataConstraint{EntityType: "openid_relying_party", Claim: "jwks_uri", URISchemes: []string{"https"}},
+		},
+		{
+			name:       "URI schemes",
+			constraint: MetadataConstraint{EntityType: "openid_relying_party", Claim: "redirect_uris", URISchemes: []string{"HTTPS"}},
+			want:       []string{ConstraintURISchemes},
+		},
+		{
+			name:       "includes",
+			constraint: MetadataConstraint{EntityType: "openid_relying_party", Claim: "client_registration_types", Includes: []string{"automatic", "explicit"}},
+			want:       []string{ConstraintIncludes},
+		},
+		{
+			name:       "one of scalar",
+			constraint: MetadataConstraint{EntityType: "openid_relying_party", Claim: "application_type", OneOf: []string{"web"}},
+		},
+		{
+			name:       "one of array",
+			constraint: MetadataConstraint{EntityType: "openid_relying_party", Claim: "client_registration_types", OneOf: []string{"explicit"}},
+			want:       []string{ConstraintOneOf},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.constraint.check(metadata)
+			if len(got) != len(tt.want) {
+				t.Fatalf("check() = %+v, want %d violations", got, len(tt.want))
+			}
+			for i, v := range got {
+				if v.Constraint != tt.want[i] || v.EntityType != tt.constraint.EntityType || v.Claim != tt.constraint.Claim || v.Message == "" {
+					t.Errorf("check()[%d] = %+v, want a %s violation", i, v, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewOIDFedRegistry_InvalidMetadataConstraint(t *testing.T) {
+	for _, c := range []MetadataConstraint{
+		{Claim: "redirect_uris", Required: true},
+		{EntityType: "openid_relying_party", Claim: "redirect_uris"},
+	} {
+		_, err := NewOIDFedRegistry(Config{
+			TrustAnchors:        []TrustAnchorConfig{{EntityID: "https://ta.example.com"}},
+			MetadataConstraints: []MetadataConstraint{c},
+		})
+		if err == nil {
+			t.Errorf("NewOIDFedRegistry() with constraint %+v should fail", c)
+		}
+	}
+}
+
+func TestOIDFedRegistry_Evaluate_MetadataConstraints(t *testing.T) {
+	registry, err := NewOIDFedRegistry(Config{
+		TrustAnchors: []TrustAnchorConfig{{EntityID: "https://ta.example.com"}},
+		MetadataConstraints: []MetadataConstraint{
+			{EntityType: "openid_relying_party", Claim: "redirect_uris", Required: true, URISchemes: []string{"https"}},
+			{EntityType: "openid_relying_party", Claim: "client_registration_types", Includes: []string{"automatic"}},
+			{EntityType: "wallet_provider", Claim: "token_endpoint", Required: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewOIDFedRegistry() error = %v", err)
+	}
+
+	wallet := map[string]any{"token_endpoint": "https://wp.example.com/token"}
+	chains := map[string]oidfed.TrustChains{
+		"https://good.example.com": {
+			relyingPartyChain("https://good.example.com", []string{"https://good.example.com/cb"}, []string{"automatic"}, wallet),
+		},
+		"https://bad.example.com": {
+			relyingPartyChain("https://bad.example.com", []string{"http://bad.example.com/cb"}, []string{"explicit"}, nil),
+		},
+		// Only the second chain, e.g. under another trust anchor, satisfies the constraints
+		"https://mixed.example.com": {
+			relyingPartyChain("https://mixed.example.com", nil, []string{"automatic"}, wallet),
+			relyingPartyChain("https://mixed.example.com", []string{"https://mixed.example.com/cb"}, []string{"automatic"}, wallet),
+		},
+	}
+	registry.cache.resolve = func(entityID string) oidfed.TrustChains {
+		return chains[entityID]
+	}
+
+	evaluate := func(entityID string) *authzen.EvaluationResponse {
+		t.Helper()
+		resp, err := registry.Evaluate(context.Background(), &authzen.EvaluationRequest{
+			Subject:  authzen.Subject{Type: "entity", ID: entityID},
+			Resource: authzen.Resource{Type: "entity", ID: entityID},
+		})
+		if err != nil {
+			t.Fatalf("Evaluate(%s) error = %v", entityID, err)
+		}
+		return resp
+	}
+
+	if resp := evaluate("https://good.example.com"); !resp.Decision {
+		t.Errorf("good entity denied: %v", resp.Context.Reason)
+	}
+	if resp := evaluate("https://mixed.example.com"); !resp.Decision {
+		t.Errorf("entity with one satisfying chain denied: %v", resp.Context.Reason)
+	}
+
+	resp := evaluate("https://bad.example.com")
+	if resp.Decision {
+		t.Fatal("entity violating the constraints was trusted")
+	}
+	reason := resp.Context.Reason
+	if reason["message"] != "metadata constraints not satisfied" || reason["entity_id"] != "https://bad.example.com" {
+		t.Errorf("reason = %v", reason)
+	}
+	violations, ok := reason["violations"].([]ConstraintViolation)
+	if !ok {
+		t.Fatalf("violations = %T, want []ConstraintViolation", reason["violations"])
+	}
+	var kinds []string
+	for _, v := range violations {
+		kinds = append(kinds, v.Constraint)
+	}
+	want := []string{ConstraintURISchemes, ConstraintIncludes, ConstraintRequired}
+	if len(kinds) != len(want) {
+		t.Fatalf("violations = %+v, want kinds %v", violations, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("violations = %+v, want kinds %v", violations, want)
+			break
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/SUNET/go-trust/pkg/registry"
 	oidfed "github.com/go-oidfed/lib"
 	oidfedjwx "github.com/go-oidfed/lib/jwx"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // OIDFedRegistry implements a trust registry using OpenID Federation.
@@ -22,6 +23,9 @@ type OIDFedRegistry struct {
 	requiredTrustMarks []string // Optional: require specific trust mark types
 	entityTypes        []string // Optional: filter by entity types (e.g., "openid_provider")
 	description        string
+	cache              *chainCache
+
+	metadataConstraints []MetadataConstraint // Optional: requirements on the resolved entity metadata
 }
 
 // Config holds configuration for creating an OIDFedRegistry.
@@ -37,6 +41,26 @@ type Config struct {
 
 	// Description of this registry instance
 	Description string `json:"description,omitempty"`
+
+	// MetadataConstraints are requirements on the metadata of evaluated
+	// entities; entities whose trust chains resolve but whose metadata fails
+	// a constraint are denied
+	MetadataConstraints []MetadataConstraint `json:"metadata_constraints,omitempty"`
+
+	// CacheTTL is the longest time resolved trust chains are reused; chains
+	// are re-resolved earlier if a statement in them expires (default DefaultCacheTTL)
+	CacheTTL time.Duration `json:"cache_ttl,omitempty"`
+
+	// NegativeCacheTTL is how long an entity without a valid trust chain is
+	// remembered as such (default DefaultNegativeCacheTTL)
+	NegativeCacheTTL time.Duration `json:"negative_cache_ttl,omitempty"`
+
+	// PrefetchWindow is how long before their chains expire entities evaluated
+	// since the previous Refresh are re-resolved by Refresh (default DefaultPrefetchWindow)
+	PrefetchWindow time.Duration `json:"prefetch_window,omitempty"`
+
+	// MaxCacheEntries bounds the number of cached entities (default DefaultMaxCacheEntries)
+	MaxCacheEntries int `json:"max_cache_entries,omitempty"`
 }
 
 // TrustAnchorConfig defines a single trust anchor.
@@ -70,17 +94,27 @@ func NewOIDFedRegistry(config Config) (*OIDFedRegistry, error) {
 		trustAnchors[i] = anchor
 	}
 
+	for i, c := range config.MetadataConstraints {
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("metadata constraint %d: %w", i, err)
+		}
+	}
+
 	description := config.Description
 	if description == "" {
 		description = fmt.Sprintf("OpenID Federation Registry with %d trust anchor(s)", len(trustAnchors))
 	}
 
-	return &OIDFedRegistry{
+	r := &OIDFedRegistry{
 		trustAnchors:       trustAnchors,
 		requiredTrustMarks: config.RequiredTrustMarks,
 		entityTypes:        config.EntityTypes,
 		description:        description,
-	}, nil
+
+		metadataConstraints: config.MetadataConstraints,
+	}
+	r.cache = newChainCache(r.resolveChains, config)
+	return r, nil
 }
 
 // Name returns the registry name.
@@ -125,15 +159,11 @@ func (r *OIDFedRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRe
 		}, nil
 	}
 
-	// Build and validate trust chains
-	resolver := &oidfed.TrustResolver{
-		StartingEntity: entityID,
-		TrustAnchors:   r.trustAnchors,
-		Types:          r.entityTypes,
+	// Resolve and verify trust chains, or reuse those resolved earlier
+	chains, err := r.cache.get(ctx, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("resolving trust chains of %s: %w", entityID, err)
 	}
-
-	// Resolve and verify trust chains
-	chains := resolver.ResolveToValidChains()
 	if len(chains) == 0 {
 		return &authzen.EvaluationResponse{
 			Decision: false,
@@ -146,8 +176,20 @@ func (r *OIDFedRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRe
 		}, nil
 	}
 
-	// Select the best chain (first valid chain for now)
-	chain := chains[0]
+	// Select the first chain whose metadata satisfies the constraints
+	chain, violations := r.selectChain(chains)
+	if len(violations) > 0 {
+		return &authzen.EvaluationResponse{
+			Decision: false,
+			Context: &authzen.EvaluationResponseContext{
+				Reason: map[string]interface{}{
+					"message":    "metadata constraints not satisfied",
+					"entity_id":  entityID,
+					"violations": violations,
+				},
+			},
+		}, nil
+	}
 
 	// Check required trust marks if configured
 	if len(r.requiredTrustMarks) > 0 {
@@ -178,6 +220,7 @@ func (r *OIDFedRegistry) Evaluate(ctx context.Context, req *authzen.EvaluationRe
 
 	reasonData := map[string]interface{}{
 		"entity_id":          entityID,
+		"trust_chain":        chainEntityIDs(chain),
 		"trust_chain_length": len(chain),
 		"trust_anchor":       r.getTrustAnchorID(chain),
 		"metadata":           metadata,
@@ -212,19 +255,84 @@ func (r *OIDFedRegistry) Healthy() bool {
 	return len(r.trustAnchors) > 0
 }
 
-// Refresh triggers an update of cached data.
-// For OpenID Federation, the go-oidfed/lib handles caching internally.
+// Refresh re-resolves the trust chains of hot entities, those evaluated since
+// the previous Refresh, whose cached chains expire within the prefetch window,
+// so that their next evaluation does not wait for a resolution. Expired
+// entries of other entities are dropped. It returns ctx.Err() if ctx is done
+// before all hot entities are resolved.
 func (r *OIDFedRegistry) Refresh(ctx context.Context) error {
-	// The go-oidfed/lib library handles caching and refreshing internally
-	// Nothing specific to do here
-	return nil
+	return r.cache.refresh(ctx)
+}
+
+// StartPrefetch calls Refresh every interval in a background goroutine until
+// ctx is done. The interval should be shorter than the prefetch window.
+func (r *OIDFedRegistry) StartPrefetch(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = r.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+// CacheStats returns statistics of the trust chain cache.
+func (r *OIDFedRegistry) CacheStats() CacheStats {
+	return r.cache.stats()
+}
+
+// CacheCollectors returns Prometheus collectors exporting CacheStats as the
+// go_trust_oidfed_chain_cache_* metrics, for registration with the server's
+// metrics.
+func (r *OIDFedRegistry) CacheCollectors() []prometheus.Collector {
+	counter := func(name, help string, value func(CacheStats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(r.CacheStats()))
+		})
+	}
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "go_trust_oidfed_chain_cache_entries",
+			Help: "Number of entities in the OpenID Federation trust chain cache",
+		}, func() float64 {
+			return float64(r.CacheStats().Entries)
+		}),
+		counter("go_trust_oidfed_chain_cache_hits_total",
+			"Total number of OpenID Federation evaluations answered from the trust chain cache",
+			func(s CacheStats) uint64 { return s.Hits }),
+		counter("go_trust_oidfed_chain_cache_misses_total",
+			"Total number of OpenID Federation evaluations that resolved trust chains",
+			func(s CacheStats) uint64 { return s.Misses }),
+		counter("go_trust_oidfed_chain_cache_prefetches_total",
+			"Total number of trust chain resolutions prefetched for hot entities",
+			func(s CacheStats) uint64 { return s.Prefetches }),
+		counter("go_trust_oidfed_chain_cache_evictions_total",
+			"Total number of entries dropped from the trust chain cache",
+			func(s CacheStats) uint64 { return s.Evictions }),
+	}
+}
+
+// resolveChains resolves and verifies the trust chains from entityID to the
+// trust anchors. It does not use the registry's cache.
+func (r *OIDFedRegistry) resolveChains(entityID string) oidfed.TrustChains {
+	resolver := &oidfed.TrustResolver{
+		StartingEntity: entityID,
+		TrustAnchors:   r.trustAnchors,
+		Types:          r.entityTypes,
+	}
+	return resolver.ResolveToValidChains()
 }
 
 // extractEntityID extracts the entity identifier from the request.
 // It checks subject.entity_id, resource.entity_id, subject.id, or resource.id.
 func (r *OIDFedRegistry) extractEntityID(req *authzen.EvaluationRequest) (string, error) {
 	// Try subject.entity_id or subject.id first
-	if req.Subject.Type == "key" && req.Subject.ID != "" {
+	if (req.Subject.Type == "key" || req.Subject.Type == "entity") && req.Subject.ID != "" {
 		// Check if ID looks like a URL (entity identifier)
 		if strings.HasPrefix(req.Subject.ID, "http://") || strings.HasPrefix(req.Subject.ID, "https://") {
 			return req.Subject.ID, nil
@@ -241,6 +349,24 @@ func (r *OIDFedRegistry) extractEntityID(req *authzen.EvaluationRequest) (string
 	return "", fmt.Errorf("no entity_id found in request subject or resource")
 }
 
+// selectChain returns the first of chains whose metadata satisfies the
+// metadata constraints. If none does, it returns the violations of the first
+// chain. Metadata policies differ between trust anchors, so chains of the same
+// entity may resolve to different metadata.
+func (r *OIDFedRegistry) selectChain(chains oidfed.TrustChains) (oidfed.TrustChain, []ConstraintViolation) {
+	var first []ConstraintViolation
+	for i, chain := range chains {
+		violations := r.checkConstraints(chain)
+		if len(violations) == 0 {
+			return chain, nil
+		}
+		if i == 0 {
+			first = violations
+		}
+	}
+	return chains[0], first
+}
+
 // checkTrustMarks verifies that all required trust marks are present in the trust chain.
 func (r *OIDFedRegistry) checkTrustMarks(chain oidfed.TrustChain) bool {
 	if len(r.requiredTrustMarks) == 0 {
@@ -344,6 +470,25 @@ func (r *OIDFedRegistry) extractCertificates(chain oidfed.TrustChain) []*x509.Ce
 	return certificates
 }
 
+// chainEntityIDs returns the entity IDs along the trust chain, from the leaf
+// entity through its intermediates to the trust anchor. The chain holds the
+// leaf's entity configuration, one subordinate statement per superior, and
+// the trust anchor's entity configuration; each issuer is listed once.
+func chainEntityIDs(chain oidfed.TrustChain) []string {
+	ids := make([]string, 0, len(chain))
+	for i, stmt := range chain {
+		id := stmt.Issuer
+		if i == 0 {
+			id = stmt.Subject
+		}
+		if len(ids) > 0 && ids[len(ids)-1] == id {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 // getTrustAnchorID returns the entity ID of the trust anchor for this chain.
 func (r *OIDFedRegistry) getTrustAnchorID(chain oidfed.TrustChain) string {
 	if len(chain) == 0 {
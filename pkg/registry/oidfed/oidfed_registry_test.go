@@ -2,10 +2,15 @@ package oidfed
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/SUNET/go-trust/pkg/authzen"
+	oidfed "github.com/go-oidfed/lib"
 	oidfedjwx "github.com/go-oidfed/lib/jwx"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestNewOIDFedRegistry(t *testing.T) {
@@ -219,6 +224,21 @@ func TestOIDFedRegistry_extractEntityID(t *testing.T) {
 			want:    "https://entity.example.com",
 			wantErr: false,
 		},
+		{
+			name: "extract from entity subject",
+			req: &authzen.EvaluationRequest{
+				Subject: authzen.Subject{
+					Type: "entity",
+					ID:   "https://rp.example.com",
+				},
+				Resource: authzen.Resource{
+					Type: "entity",
+					ID:   "https://rp.example.com",
+				},
+			},
+			want:    "https://rp.example.com",
+			wantErr: false,
+		},
 		{
 			name: "no valid entity ID",
 			req: &authzen.EvaluationRequest{
@@ -285,12 +305,89 @@ func TestOIDFedRegistry_Evaluate_NoValidChain(t *testing.T) {
 	}
 }
 
+func TestChainEntityIDs(t *testing.T) {
+	statement := func(iss, sub string) *oidfed.EntityStatement {
+		return &oidfed.EntityStatement{EntityStatementPayload: oidfed.EntityStatementPayload{Issuer: iss, Subject: sub}}
+	}
+	chain := oidfed.TrustChain{
+		statement("https://rp.example.com", "https://rp.example.com"),
+		statement("https://ia.example.com", "https://rp.example.com"),
+		statement("https://ta.example.com", "https://ia.example.com"),
+		statement("https://ta.example.com", "https://ta.example.com"),
+	}
+
+	got := chainEntityIDs(chain)
+	want := []string{"https://rp.example.com", "https://ia.example.com", "https://ta.example.com"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("chainEntityIDs() = %v, want %v", got, want)
+	}
+	if len(chainEntityIDs(nil)) != 0 {
+		t.Error("chainEntityIDs(nil) should be empty")
+	}
+}
+
+func TestOIDFedRegistry_Evaluate_Cached(t *testing.T) {
+	registry, _ := NewOIDFedRegistry(Config{
+		TrustAnchors: []TrustAnchorConfig{{EntityID: "https://ta.example.com"}},
+	})
+	release := make(chan struct{})
+	registry.cache.resolve = func(entityID string) oidfed.TrustChains {
+		<-release
+		return expiringChains(entityID, time.Now().Add(time.Hour))
+	}
+	req := &authzen.EvaluationRequest{
+		Subject:  authzen.Subject{Type: "entity", ID: "https://rp.example.com"},
+		Resource: authzen.Resource{Type: "entity", ID: "https://rp.example.com"},
+	}
+
+	// Evaluation gives up when the request is cancelled during resolution
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := registry.Evaluate(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Evaluate() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		resp, err := registry.Evaluate(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if !resp.Decision {
+			t.Errorf("Evaluate() decision = false, want true: %v", resp.Context.Reason)
+		}
+	}
+	// The first evaluation after the release may still join the resolution
+	if s := registry.CacheStats(); s.Hits+s.Misses != 3 || s.Hits == 0 || s.Entries != 1 {
+		t.Errorf("CacheStats() = %+v, want 3 lookups with at least 1 hit", s)
+	}
+
+	reg := prometheus.NewRegistry()
+	for _, c := range registry.CacheCollectors() {
+		if err := reg.Register(c); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	values := make(map[string]float64)
+	for _, f := range families {
+		m := f.GetMetric()[0]
+		values[f.GetName()] = m.GetCounter().GetValue() + m.GetGauge().GetValue()
+	}
+	if values["go_trust_oidfed_chain_cache_hits_total"] == 0 || values["go_trust_oidfed_chain_cache_entries"] != 1 {
+		t.Errorf("cache metrics = %v", values)
+	}
+}
+
 func TestOIDFedRegistry_Refresh(t *testing.T) {
 	registry, _ := NewOIDFedRegistry(Config{
 		TrustAnchors: []TrustAnchorConfig{{EntityID: "https://ta.example.com"}},
 	})
 
-	// Refresh should not fail (it's a no-op for this implementation)
+	// Refresh should not fail (there are no hot entities to prefetch)
 	err := registry.Refresh(context.Background())
 	if err != nil {
 		t.Errorf("Refresh() error = %v, want nil", err)
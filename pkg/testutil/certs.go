@@ -0,0 +1,331 @@
+// Package testutil generates X.509 certificate fixtures in pure Go.
+//
+// It creates self-signed CAs, intermediate CAs and leaf certificates, as well as
+// deliberately broken certificates (expired, not yet valid, wrong extended key
+// usage) for testing certificate validation. The package is exported so that
+// downstream users can produce fixtures for their own integration tests without
+// shelling out to openssl.
+//
+// Example:
+//
+//	ca, err := testutil.NewCA("Test Root CA")
+//	leaf, err := testutil.NewLeaf(ca, "service.example.com",
+//		testutil.WithDNSNames("service.example.com"))
+//	expired, err := testutil.NewLeaf(ca, "old.example.com", testutil.Expired())
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Cert is a generated certificate together with its private key and issuer.
+type Cert struct {
+	Certificate *x509.Certificate // Parsed certificate
+	DER         []byte            // DER encoding of the certificate
+	Key         crypto.Signer     // Private key matching the certificate
+	Issuer      *Cert             // Issuing certificate, nil for self-signed certificates
+}
+
+// Base64 returns the base64-encoded DER certificate, as used in TSL
+// X509Certificate elements and JWS x5c headers.
+func (c *Cert) Base64() string {
+	return base64.StdEncoding.EncodeToString(c.DER)
+}
+
+// CertPEM returns the PEM-encoded certificate.
+func (c *Cert) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.DER})
+}
+
+// KeyPEM returns the PEM-encoded PKCS#8 private key.
+func (c *Cert) KeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Chain returns the certificate followed by its issuers, up to and including
+// the self-signed root.
+func (c *Cert) Chain() []*x509.Certificate {
+	var chain []*x509.Certificate
+	for cur := c; cur != nil; cur = cur.Issuer {
+		chain = append(chain, cur.Certificate)
+	}
+	return chain
+}
+
+// Pool returns a certificate pool containing only this certificate, for use as
+// the Roots of x509.VerifyOptions.
+func (c *Cert) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.Certificate)
+	return pool
+}
+
+// Option customizes a generated certificate.
+type Option func(*options)
+
+type options struct {
+	notBefore   time.Time
+	notAfter    time.Time
+	extKeyUsage []x509.ExtKeyUsage
+	keyUsage    x509.KeyUsage
+	dnsNames    []string
+	rsaBits     int
+	key         crypto.Signer
+	org         string
+	policies    []string
+}
+
+// WithValidity sets the validity period of the certificate.
+func WithValidity(notBefore, notAfter time.Time) Option {
+	return func(o *options) {
+		o.notBefore = notBefore
+		o.notAfter = notAfter
+	}
+}
+
+// Expired makes the certificate expire one day before it is generated.
+func Expired() Option {
+	return func(o *options) {
+		now := time.Now()
+		o.notBefore = now.AddDate(0, 0, -30)
+		o.notAfter = now.AddDate(0, 0, -1)
+	}
+}
+
+// NotYetValid makes the certificate become valid one day after it is generated.
+func NotYetValid() Option {
+	return func(o *options) {
+		now := time.Now()
+		o.notBefore = now.AddDate(0, 0, 1)
+		o.notAfter = now.AddDate(1, 0, 0)
+	}
+}
+
+// WithExtKeyUsage replaces the extended key usages of the certificate. Leaf
+// certificates default to server and client authentication; pass for example
+// x509.ExtKeyUsageEmailProtection to produce a certificate with the wrong EKU.
+func WithExtKeyUsage(usages ...x509.ExtKeyUsage) Option {
+	return func(o *options) {
+		o.extKeyUsage = usages
+	}
+}
+
+// WithKeyUsage replaces the key usage bits of the certificate.
+func WithKeyUsage(usage x509.KeyUsage) Option {
+	return func(o *options) {
+		o.keyUsage = usage
+	}
+}
+
+// WithDNSNames sets the DNS subject alternative names of the certificate.
+func WithDNSNames(names ...string) Option {
+	return func(o *options) {
+		o.dnsNames = names
+	}
+}
+
+// WithOrganization sets the subject organization of the certificate.
+func WithOrganization(org string) Option {
+	return func(o *options) {
+		o.org = org
+	}
+}
+
+// WithPolicies sets the certificate policy OIDs of the certificate, in dotted
+// decimal form such as "0.4.0.194112.1.2".
+func WithPolicies(oids ...string) Option {
+	return func(o *options) {
+		o.policies = oids
+	}
+}
+
+// WithRSAKey generates an RSA key of the given size instead of the default
+// ECDSA P-256 key.
+func WithRSAKey(bits int) Option {
+	return func(o *options) {
+		o.rsaBits = bits
+	}
+}
+
+// WithKey certifies key instead of a generated one, such as an ECDSA P-384
+// or Ed25519 key.
+func WithKey(key crypto.Signer) Option {
+	return func(o *options) {
+		o.key = key
+	}
+}
+
+// NewCA generates a self-signed root CA certificate valid from one hour ago for ten years.
+func NewCA(commonName string, opts ...Option) (*Cert, error) {
+	now := time.Now()
+	o := options{
+		notBefore: now.Add(-time.Hour),
+		notAfter:  now.AddDate(10, 0, 0),
+		keyUsage:  x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	return generate(commonName, nil, true, o, opts)
+}
+
+// NewCAs generates n self-signed root CAs named "<prefix> 1" to "<prefix> n",
+// for populating large certificate pools and TSLs.
+func NewCAs(prefix string, n int, opts ...Option) ([]*Cert, error) {
+	cas := make([]*Cert, 0, n)
+	for i := 1; i <= n; i++ {
+		ca, err := NewCA(fmt.Sprintf("%s %d", prefix, i), opts...)
+		if err != nil {
+			return nil, err
+		}
+		cas = append(cas, ca)
+	}
+	return cas, nil
+}
+
+// NewIntermediateCA generates a CA certificate issued by issuer, valid from one
+// hour ago for five years.
+func NewIntermediateCA(issuer *Cert, commonName string, opts ...Option) (*Cert, error) {
+	if issuer == nil {
+		return nil, fmt.Errorf("issuer is required")
+	}
+	now := time.Now()
+	o := options{
+		notBefore: now.Add(-time.Hour),
+		notAfter:  now.AddDate(5, 0, 0),
+		keyUsage:  x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	return generate(commonName, issuer, true, o, opts)
+}
+
+// NewLeaf generates an end-entity certificate issued by issuer, valid from one
+// hour ago for one year, with server and client authentication EKUs.
+func NewLeaf(issuer *Cert, commonName string, opts ...Option) (*Cert, error) {
+	if issuer == nil {
+		return nil, fmt.Errorf("issuer is required")
+	}
+	now := time.Now()
+	o := options{
+		notBefore:   now.Add(-time.Hour),
+		notAfter:    now.AddDate(1, 0, 0),
+		keyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	return generate(commonName, issuer, false, o, opts)
+}
+
+// NewSelfSigned generates a self-signed end-entity certificate, valid from one
+// hour ago for one year. Unlike NewCA, the certificate cannot issue other certificates.
+func NewSelfSigned(commonName string, opts ...Option) (*Cert, error) {
+	now := time.Now()
+	o := options{
+		notBefore:   now.Add(-time.Hour),
+		notAfter:    now.AddDate(1, 0, 0),
+		keyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		extKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	return generate(commonName, nil, false, o, opts)
+}
+
+// NewChain generates a root CA, one intermediate CA and a leaf certificate. The
+// options apply to the leaf only. It returns the leaf; its Issuer fields link
+// back to the intermediate and the root.
+func NewChain(leafCommonName string, opts ...Option) (*Cert, error) {
+	root, err := NewCA("Test Root CA")
+	if err != nil {
+		return nil, err
+	}
+	intermediate, err := NewIntermediateCA(root, "Test Intermediate CA")
+	if err != nil {
+		return nil, err
+	}
+	return NewLeaf(intermediate, leafCommonName, opts...)
+}
+
+// generate creates and signs a certificate. A nil issuer produces a self-signed certificate.
+func generate(commonName string, issuer *Cert, isCA bool, o options, opts []Option) (*Cert, error) {
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	key := o.key
+	if key == nil {
+		var err error
+		if key, err = generateKey(o.rsaBits); err != nil {
+			return nil, err
+		}
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	subject := pkix.Name{CommonName: commonName}
+	if o.org != "" {
+		subject.Organization = []string{o.org}
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             o.notBefore,
+		NotAfter:              o.notAfter,
+		KeyUsage:              o.keyUsage,
+		ExtKeyUsage:           o.extKeyUsage,
+		DNSNames:              o.dnsNames,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	for _, p := range o.policies {
+		oid, err := x509.ParseOID(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy OID %q: %w", p, err)
+		}
+		template.Policies = append(template.Policies, oid)
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.Certificate, issuer.Key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return &Cert{Certificate: cert, DER: der, Key: key, Issuer: issuer}, nil
+}
+
+// generateKey returns an RSA key of the given size, or an ECDSA P-256 key if bits is zero.
+func generateKey(rsaBits int) (crypto.Signer, error) {
+	if rsaBits > 0 {
+		key, err := rsa.GenerateKey(rand.Reader, rsaBits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate RSA key: %w", err)
+		}
+		return key, nil
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ECDSA key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,154 @@
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func verify(leaf *Cert, root *Cert, intermediates []*x509.Certificate, usages ...x509.ExtKeyUsage) error {
+	pool := x509.NewCertPool()
+	for _, c := range intermediates {
+		pool.AddCert(c)
+	}
+	_, err := leaf.Certificate.Verify(x509.VerifyOptions{
+		Roots:         root.Pool(),
+		Intermediates: pool,
+		KeyUsages:     usages,
+	})
+	return err
+}
+
+func TestNewCA(t *testing.T) {
+	ca, err := NewCA("Test CA", WithOrganization("SUNET"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Test CA", ca.Certificate.Subject.CommonName)
+	assert.Equal(t, []string{"SUNET"}, ca.Certificate.Subject.Organization)
+	assert.True(t, ca.Certificate.IsCA)
+	assert.Nil(t, ca.Issuer)
+	assert.NoError(t, ca.Certificate.CheckSignatureFrom(ca.Certificate))
+	assert.IsType(t, &ecdsa.PrivateKey{}, ca.Key)
+
+	der, err := base64.StdEncoding.DecodeString(ca.Base64())
+	require.NoError(t, err)
+	assert.Equal(t, ca.DER, der)
+}
+
+func TestNewCAs(t *testing.T) {
+	cas, err := NewCAs("Pool CA", 3)
+	require.NoError(t, err)
+	require.Len(t, cas, 3)
+	assert.Equal(t, "Pool CA 1", cas[0].Certificate.Subject.CommonName)
+	assert.Equal(t, "Pool CA 3", cas[2].Certificate.Subject.CommonName)
+	assert.NotEqual(t, cas[0].Certificate.SerialNumber, cas[1].Certificate.SerialNumber)
+}
+
+func TestNewChain(t *testing.T) {
+	leaf, err := NewChain("leaf.example.com", WithDNSNames("leaf.example.com"))
+	require.NoError(t, err)
+
+	chain := leaf.Chain()
+	require.Len(t, chain, 3)
+	assert.Equal(t, "leaf.example.com", chain[0].Subject.CommonName)
+	assert.Equal(t, "Test Intermediate CA", chain[1].Subject.CommonName)
+	assert.Equal(t, "Test Root CA", chain[2].Subject.CommonName)
+	assert.False(t, leaf.Certificate.IsCA)
+	assert.True(t, leaf.Issuer.Certificate.IsCA)
+
+	root := leaf.Issuer.Issuer
+	assert.NoError(t, verify(leaf, root, chain[1:2]))
+	assert.Error(t, verify(leaf, root, nil), "leaf must not verify without the intermediate")
+	assert.NoError(t, leaf.Certificate.VerifyHostname("leaf.example.com"))
+}
+
+func TestBrokenCertificates(t *testing.T) {
+	ca, err := NewCA("Test CA")
+	require.NoError(t, err)
+
+	t.Run("expired", func(t *testing.T) {
+		leaf, err := NewLeaf(ca, "expired", Expired())
+		require.NoError(t, err)
+		assert.True(t, leaf.Certificate.NotAfter.Before(time.Now()))
+		assert.ErrorContains(t, verify(leaf, ca, nil), "expired")
+	})
+
+	t.Run("not yet valid", func(t *testing.T) {
+		leaf, err := NewLeaf(ca, "future", NotYetValid())
+		require.NoError(t, err)
+		assert.True(t, leaf.Certificate.NotBefore.After(time.Now()))
+		assert.Error(t, verify(leaf, ca, nil))
+	})
+
+	t.Run("wrong EKU", func(t *testing.T) {
+		leaf, err := NewLeaf(ca, "email", WithExtKeyUsage(x509.ExtKeyUsageEmailProtection))
+		require.NoError(t, err)
+		assert.Error(t, verify(leaf, ca, nil, x509.ExtKeyUsageServerAuth))
+		assert.NoError(t, verify(leaf, ca, nil, x509.ExtKeyUsageEmailProtection))
+	})
+
+	t.Run("custom validity", func(t *testing.T) {
+		notBefore := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+		notAfter := notBefore.Add(24 * time.Hour)
+		leaf, err := NewLeaf(ca, "custom", WithValidity(notBefore, notAfter))
+		require.NoError(t, err)
+		assert.True(t, leaf.Certificate.NotBefore.Equal(notBefore))
+		assert.True(t, leaf.Certificate.NotAfter.Equal(notAfter))
+	})
+}
+
+func TestNewSelfSigned(t *testing.T) {
+	cert, err := NewSelfSigned("Self Signed", WithRSAKey(2048))
+	require.NoError(t, err)
+	assert.False(t, cert.Certificate.IsCA)
+	assert.IsType(t, &rsa.PrivateKey{}, cert.Key)
+	assert.NoError(t, cert.Certificate.CheckSignature(cert.Certificate.SignatureAlgorithm,
+		cert.Certificate.RawTBSCertificate, cert.Certificate.Signature))
+}
+
+func TestNilIssuer(t *testing.T) {
+	_, err := NewLeaf(nil, "leaf")
+	assert.Error(t, err)
+	_, err = NewIntermediateCA(nil, "intermediate")
+	assert.Error(t, err)
+}
+
+func TestWithPolicies(t *testing.T) {
+	ca, err := NewCA("Root")
+	require.NoError(t, err)
+	leaf, err := NewLeaf(ca, "leaf", WithPolicies("0.4.0.194112.1.2"))
+	require.NoError(t, err)
+
+	require.Len(t, leaf.Certificate.Policies, 1)
+	assert.Equal(t, "0.4.0.194112.1.2", leaf.Certificate.Policies[0].String())
+
+	_, err = NewLeaf(ca, "leaf", WithPolicies("not an oid"))
+	assert.Error(t, err)
+}
+
+func TestPEMEncoding(t *testing.T) {
+	cert, err := NewCA("PEM CA")
+	require.NoError(t, err)
+
+	block, rest := pem.Decode(cert.CertPEM())
+	require.NotNil(t, block)
+	assert.Empty(t, rest)
+	assert.Equal(t, "CERTIFICATE", block.Type)
+	assert.Equal(t, cert.DER, block.Bytes)
+
+	keyPEM, err := cert.KeyPEM()
+	require.NoError(t, err)
+	block, _ = pem.Decode(keyPEM)
+	require.NotNil(t, block)
+	assert.Equal(t, "PRIVATE KEY", block.Type)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	assert.True(t, key.(*ecdsa.PrivateKey).Equal(cert.Key))
+}
@@ -0,0 +1,189 @@
+package tslpool
+
+import (
+	"crypto/x509"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+)
+
+// Extensions are the service information extensions of a trust service that
+// the AdditionalInfo and Qualifiers options filter on. URIs are given in full
+// or by their last path segment, such as "ForeSignatures" or "QCWithQSCD".
+type Extensions interface {
+	HasAdditionalServiceInformation(uri string) bool
+	HasQualifier(uri string) bool
+}
+
+// Options select the TSLs and trust services whose certificates enter a pool.
+// Service type, status, additional information and qualifier filters are
+// combined with OR logic within each filter and AND between them; an empty
+// filter accepts every service.
+type Options struct {
+	// ReferenceDepth is how deep in the references of a root TSL to go:
+	// 0 takes the root TSLs only, 1 also the TSLs they reference, and so on.
+	ReferenceDepth int
+
+	ServiceTypes   []string // Service type identifier URIs, matched exactly
+	Statuses       []string // Service status URIs, matched exactly
+	AllStatuses    bool     // Require every status in Statuses instead of any
+	AdditionalInfo []string // AdditionalServiceInformation URIs, see Extensions
+	Qualifiers     []string // Qualifications extension qualifier URIs, see Extensions
+
+	// MaxSize is the largest number of distinct certificates in the pool;
+	// further new certificates are left out. 0 is unlimited.
+	MaxSize int
+
+	// Reject, if set, returns why the certificates of a TSL must not enter
+	// the pool, or "" to accept them.
+	Reject func(tsl *etsi119612.TSL) string
+
+	// Extensions, if set, returns the parsed service information extensions
+	// of a trust service. Without it, AdditionalInfo and Qualifiers filters
+	// match no service.
+	Extensions func(svc *etsi119612.TSPServiceType) Extensions
+}
+
+// Rejection is a TSL whose certificates were left out by Options.Reject.
+type Rejection struct {
+	TSL    *etsi119612.TSL
+	Reason string
+}
+
+// Report describes how certificates were added to a pool.
+type Report struct {
+	TSLs       int         // TSLs whose trust services were considered
+	Added      int         // Distinct certificates added
+	Duplicates int         // Listings of certificates that were already in the pool
+	LeftOut    int         // New certificates left out because the pool was full
+	Rejected   []Rejection // TSLs rejected by Options.Reject, in order
+}
+
+// Build returns a pool of the certificates of the trust services selected by
+// opts in the TSL trees rooted at roots, following the Referenced TSLs of each
+// root up to opts.ReferenceDepth. Nil TSLs are skipped.
+//
+// Parameters:
+//   - roots: The root TSLs, typically lists of lists or national TSLs
+//   - opts: The TSLs and services to select certificates from
+//
+// Returns:
+//   - *Pool: The certificate pool with the provenance of its certificates
+//   - *Report: What was added, and what was left out
+func Build(roots []*etsi119612.TSL, opts Options) (*Pool, *Report) {
+	pool := NewPool(nil)
+	report := &Report{}
+
+	var walk func(tsl *etsi119612.TSL, depth int)
+	walk = func(tsl *etsi119612.TSL, depth int) {
+		if tsl == nil || depth > opts.ReferenceDepth {
+			return
+		}
+		pool.AddTSL(tsl, opts, report)
+		for _, ref := range tsl.Referenced {
+			walk(ref, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	return pool, report
+}
+
+// AddTSL adds the certificates of the trust services of tsl selected by opts
+// to the pool, without following its references, and accounts for them in
+// report. Callers walking TSLs in their own structures use it instead of
+// Build; opts.ReferenceDepth is ignored.
+//
+// Parameters:
+//   - tsl: The TSL to add certificates from; nil is skipped
+//   - opts: The services to select certificates from
+//   - report: The report to update; may be nil
+func (p *Pool) AddTSL(tsl *etsi119612.TSL, opts Options, report *Report) {
+	if tsl == nil {
+		return
+	}
+	if report == nil {
+		report = &Report{}
+	}
+	if opts.Reject != nil {
+		if reason := opts.Reject(tsl); reason != "" {
+			report.Rejected = append(report.Rejected, Rejection{TSL: tsl, Reason: reason})
+			return
+		}
+	}
+	report.TSLs++
+
+	tsl.WithTrustServices(func(tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) {
+		if !opts.selects(svc) {
+			return
+		}
+		svc.WithCertificates(func(cert *x509.Certificate) {
+			p.addSelected(cert, NewProvenance(tsl, tsp, svc), opts.MaxSize, report)
+		})
+	})
+}
+
+// addSelected adds a selected certificate to the pool unless the pool is full.
+func (p *Pool) addSelected(cert *x509.Certificate, prov Provenance, maxSize int, report *Report) {
+	if maxSize > 0 && len(p.certs) >= maxSize && p.Certificate(Fingerprint(cert)) == nil {
+		report.LeftOut++
+		return
+	}
+	if p.Add(cert, prov) {
+		report.Added++
+	} else {
+		report.Duplicates++
+	}
+}
+
+// selects reports whether the certificates of svc are selected by the
+// service filters of opts.
+func (opts Options) selects(svc *etsi119612.TSPServiceType) bool {
+	var serviceType, status string
+	if info := svc.TslServiceInformation; info != nil {
+		serviceType, status = info.TslServiceTypeIdentifier, info.TslServiceStatus
+	}
+
+	if len(opts.ServiceTypes) > 0 && !matchesAny(opts.ServiceTypes, func(t string) bool { return t == serviceType }) {
+		return false
+	}
+
+	if len(opts.Statuses) > 0 {
+		if opts.AllStatuses {
+			for _, s := range opts.Statuses {
+				if s != status {
+					return false
+				}
+			}
+		} else if !matchesAny(opts.Statuses, func(s string) bool { return s == status }) {
+			return false
+		}
+	}
+
+	if len(opts.AdditionalInfo) > 0 || len(opts.Qualifiers) > 0 {
+		var ext Extensions
+		if opts.Extensions != nil {
+			ext = opts.Extensions(svc)
+		}
+		if ext == nil {
+			return false
+		}
+		if len(opts.AdditionalInfo) > 0 && !matchesAny(opts.AdditionalInfo, ext.HasAdditionalServiceInformation) {
+			return false
+		}
+		if len(opts.Qualifiers) > 0 && !matchesAny(opts.Qualifiers, ext.HasQualifier) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether match returns true for any of the filters.
+func matchesAny(filters []string, match func(string) bool) bool {
+	for _, filter := range filters {
+		if match(filter) {
+			return true
+		}
+	}
+	return false
+}
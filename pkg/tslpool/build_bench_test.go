@@ -0,0 +1,44 @@
+package tslpool
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+// BenchmarkBuild benchmarks building pools from a list of lists referencing
+// 30 national TSLs that together list certs certificates, a tenth of them
+// listed twice.
+func BenchmarkBuild(b *testing.B) {
+	for _, certs := range []int{1000, 10000} {
+		cas, err := testutil.NewCAs("Bench CA", certs)
+		if err != nil {
+			b.Fatalf("Failed to generate CAs: %v", err)
+		}
+		lotl := testTSL("EU", typeCAQC)
+		for t := 0; t < 30; t++ {
+			var listed []*testutil.Cert
+			for i := t; i < certs; i += 30 {
+				listed = append(listed, cas[i])
+				if i%10 == 0 {
+					listed = append(listed, cas[(i+1)%certs])
+				}
+			}
+			lotl.Referenced = append(lotl.Referenced, testTSL(fmt.Sprintf("T%d", t), typeCAQC, listed...))
+		}
+		roots := []*etsi119612.TSL{lotl}
+
+		b.Run(fmt.Sprintf("certs=%d", certs), func(b *testing.B) {
+			opts := Options{ReferenceDepth: 1, ServiceTypes: []string{typeCAQC}}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				pool, _ := Build(roots, opts)
+				if n, _ := pool.Size(); n != certs {
+					b.Fatalf("pool has %d certificates, want %d", n, certs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package tslpool
+
+import (
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+const (
+	typeCAQC  = "http://uri.etsi.org/TrstSvc/Svctype/CA/QC"
+	typeCAPKC = "http://uri.etsi.org/TrstSvc/Svctype/CA/PKC"
+)
+
+// testTSL returns a TSL of territory with one provider offering a granted
+// service of serviceType for each of certs.
+func testTSL(territory, serviceType string, certs ...*testutil.Cert) *etsi119612.TSL {
+	var services []*etsi119612.TSPServiceType
+	for _, cert := range certs {
+		services = append(services, &etsi119612.TSPServiceType{
+			TslServiceInformation: &etsi119612.TSPServiceInformationType{
+				TslServiceTypeIdentifier: serviceType,
+				TslServiceStatus:         etsi119612.ServiceStatusGranted,
+				TslServiceDigitalIdentity: &etsi119612.DigitalIdentityListType{
+					DigitalId: []*etsi119612.DigitalIdentityType{{X509Certificate: cert.Base64()}},
+				},
+			},
+		})
+	}
+	return &etsi119612.TSL{
+		Source: "https://" + territory + ".example.com/tsl.xml",
+		StatusList: etsi119612.TrustStatusListType{
+			TslSchemeInformation: &etsi119612.TSLSchemeInformationType{TslSchemeTerritory: territory},
+			TslTrustServiceProviderList: &etsi119612.TrustServiceProviderListType{
+				TslTrustServiceProvider: []*etsi119612.TSPType{{
+					TslTSPServices: &etsi119612.TSPServicesListType{TslTSPService: services},
+				}},
+			},
+		},
+	}
+}
+
+// testCAs returns n CAs named "CA 1" to "CA n".
+func testCAs(t *testing.T, n int) []*testutil.Cert {
+	var cas []*testutil.Cert
+	for i := 1; i <= n; i++ {
+		ca, err := testutil.NewCA("CA " + string(rune('0'+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cas = append(cas, ca)
+	}
+	return cas
+}
+
+func TestBuild(t *testing.T) {
+	cas := testCAs(t, 3)
+	lotl := testTSL("EU", typeCAQC, cas[0])
+	se := testTSL("SE", typeCAQC, cas[1], cas[0])
+	fi := testTSL("FI", typeCAPKC, cas[2])
+	lotl.Referenced = []*etsi119612.TSL{se, fi}
+
+	tests := []struct {
+		name       string
+		opts       Options
+		want       []*testutil.Cert
+		duplicates int
+	}{
+		{"roots only", Options{}, cas[:1], 0},
+		{"references", Options{ReferenceDepth: 1}, cas, 1},
+		{"service type", Options{ReferenceDepth: 1, ServiceTypes: []string{typeCAPKC}}, cas[2:], 0},
+		{"status", Options{ReferenceDepth: 1, Statuses: []string{etsi119612.ServiceStatusGranted}}, cas, 1},
+		{"other status", Options{ReferenceDepth: 1, Statuses: []string{"withdrawn"}}, nil, 0},
+		{"extensions without lookup", Options{ReferenceDepth: 1, Qualifiers: []string{"QCWithQSCD"}}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, report := Build([]*etsi119612.TSL{lotl}, tt.opts)
+			if certificates, _ := pool.Size(); certificates != len(tt.want) || report.Added != len(tt.want) {
+				t.Errorf("pool has %d certificates, report %d added, want %d", certificates, report.Added, len(tt.want))
+			}
+			for _, ca := range tt.want {
+				if pool.Certificate(Fingerprint(ca.Certificate)) == nil {
+					t.Errorf("%s not in the pool", ca.Certificate.Subject.CommonName)
+				}
+			}
+			if report.Duplicates != tt.duplicates {
+				t.Errorf("Duplicates = %d, want %d", report.Duplicates, tt.duplicates)
+			}
+		})
+	}
+
+	// The provenance names the TSLs listing a certificate
+	pool, _ := Build([]*etsi119612.TSL{lotl}, Options{ReferenceDepth: 1})
+	prov := pool.Provenance(cas[0].Certificate)
+	if len(prov) != 2 || prov[0].Territory != "EU" || prov[1].Territory != "SE" || prov[1].Source != se.Source {
+		t.Errorf("Provenance() = %+v, want listings in EU and SE", prov)
+	}
+}
+
+func TestBuild_RejectAndMaxSize(t *testing.T) {
+	cas := testCAs(t, 3)
+	se := testTSL("SE", typeCAQC, cas[0], cas[1])
+	fi := testTSL("FI", typeCAQC, cas[1], cas[2])
+
+	pool, report := Build([]*etsi119612.TSL{se, nil, fi}, Options{
+		Reject: func(tsl *etsi119612.TSL) string {
+			if tsl.StatusList.TslSchemeInformation.TslSchemeTerritory == "FI" {
+				return "not wanted"
+			}
+			return ""
+		},
+	})
+	if certificates, _ := pool.Size(); certificates != 2 || report.TSLs != 1 {
+		t.Errorf("pool has %d certificates from %d TSLs, want 2 from 1", certificates, report.TSLs)
+	}
+	if len(report.Rejected) != 1 || report.Rejected[0].TSL != fi || report.Rejected[0].Reason != "not wanted" {
+		t.Errorf("Rejected = %+v, want FI", report.Rejected)
+	}
+
+	pool, report = Build([]*etsi119612.TSL{se, fi}, Options{MaxSize: 2})
+	if certificates, listings := pool.Size(); certificates != 2 || listings != 3 {
+		t.Errorf("Size() = %d, %d, want 2, 3", certificates, listings)
+	}
+	if report.LeftOut != 1 || report.Duplicates != 1 {
+		t.Errorf("LeftOut = %d, Duplicates = %d, want 1, 1", report.LeftOut, report.Duplicates)
+	}
+	if pool.Certificate(Fingerprint(cas[2].Certificate)) != nil {
+		t.Error("the certificate beyond the limit is in the pool")
+	}
+}
+
+// testExtensions lists the qualifiers of a service.
+type testExtensions []string
+
+func (e testExtensions) HasAdditionalServiceInformation(uri string) bool { return false }
+func (e testExtensions) HasQualifier(uri string) bool {
+	for _, q := range e {
+		if q == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBuild_Extensions(t *testing.T) {
+	cas := testCAs(t, 2)
+	tsl := testTSL("SE", typeCAQC, cas[0], cas[1])
+	qscd := tsl.StatusList.TslTrustServiceProviderList.TslTrustServiceProvider[0].TslTSPServices.TslTSPService[0]
+
+	pool, _ := Build([]*etsi119612.TSL{tsl}, Options{
+		Qualifiers: []string{"QCWithQSCD"},
+		Extensions: func(svc *etsi119612.TSPServiceType) Extensions {
+			if svc == qscd {
+				return testExtensions{"QCWithQSCD"}
+			}
+			return nil
+		},
+	})
+	if certs := pool.Certificates(); len(certs) != 1 || certs[0].Fingerprint != Fingerprint(cas[0].Certificate) {
+		t.Errorf("Certificates() = %v, want CA 1 only", certs)
+	}
+}
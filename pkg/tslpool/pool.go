@@ -0,0 +1,203 @@
+// Package tslpool builds x509 certificate pools from ETSI TS 119 612 Trust
+// Status Lists, recording for every certificate the TSL services it was
+// selected from.
+//
+// The select step of the pipeline package is built on this package; other Go
+// programs use Build to turn loaded TSLs into a pool without running a
+// pipeline:
+//
+//	tsls, err := etsi119612.FetchTSLWithReferencesAndOptions(url, opts)
+//	...
+//	// The root TSL comes first and holds its references
+//	pool, report := tslpool.Build(tsls[:1], tslpool.Options{
+//		ReferenceDepth: 1,
+//		ServiceTypes:   []string{"http://uri.etsi.org/TrstSvc/Svctype/CA/QC"},
+//	})
+//	chains, err := leaf.Verify(x509.VerifyOptions{Roots: pool.CertPool()})
+package tslpool
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"slices"
+	"sort"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/SUNET/go-trust/pkg/utils/i18n"
+)
+
+// Provenance records where a certificate of a pool came from: the TSL it is
+// listed in and the trust service it belongs to. An x509.CertPool does not
+// retain this, so a Pool records it alongside.
+type Provenance struct {
+	Source      string `json:"source,omitempty"`       // URL or file the TSL was loaded from
+	Territory   string `json:"territory,omitempty"`    // Scheme territory of the TSL
+	Provider    string `json:"provider"`               // Name of the trust service provider
+	Service     string `json:"service"`                // Name of the trust service
+	ServiceType string `json:"service_type,omitempty"` // Service type identifier URI
+	Status      string `json:"status,omitempty"`       // Service status URI
+
+	// Link to the page of the provider, if provider pages are published
+	ProviderPage string `json:"provider_page,omitempty"`
+}
+
+// Certificate is a certificate of a pool together with the trust services it
+// was selected from. A certificate listed under several services, or in
+// several TSLs, has one provenance entry for each.
+type Certificate struct {
+	Certificate *x509.Certificate
+	Fingerprint string       // Hex SHA-256 fingerprint of the DER encoding
+	Provenance  []Provenance // TSL services listing the certificate, in selection order
+}
+
+// NewProvenance returns the provenance of a certificate listed under the
+// trust service svc of provider tsp in tsl.
+func NewProvenance(tsl *etsi119612.TSL, tsp *etsi119612.TSPType, svc *etsi119612.TSPServiceType) Provenance {
+	prov := Provenance{Provider: "Unknown", Service: "Unknown"}
+	if tsl != nil {
+		prov.Source = tsl.Source
+		if si := tsl.StatusList.TslSchemeInformation; si != nil {
+			prov.Territory = si.TslSchemeTerritory
+		}
+	}
+	if tsp != nil && tsp.TslTSPInformation != nil {
+		prov.Provider = displayName(tsp.TslTSPInformation.TSPName)
+	}
+	if svc != nil && svc.TslServiceInformation != nil {
+		info := svc.TslServiceInformation
+		prov.Service = displayName(info.ServiceName)
+		prov.ServiceType = info.TslServiceTypeIdentifier
+		prov.Status = info.TslServiceStatus
+	}
+	return prov
+}
+
+// displayName returns the name in names in the configured language
+// preference (see i18n.SetPreferredLanguages), or "Unknown". Unlike
+// etsi119612.FindByLanguage it accepts missing names, which pools must
+// tolerate in any TSL they are given.
+func displayName(names *etsi119612.InternationalNamesType) string {
+	return i18n.Name(names, i18n.PreferredLanguages(), "Unknown")
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of the DER encoding of cert,
+// which identifies the certificates of a pool.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Pool is an x509.CertPool together with the provenance of its certificates,
+// indexed by fingerprint. A Pool is not safe for concurrent modification.
+type Pool struct {
+	certPool *x509.CertPool
+	certs    map[string]*Certificate
+}
+
+// NewPool returns a pool adding its certificates to certPool, or to a new
+// x509.CertPool if certPool is nil. Certificates already in certPool have no
+// provenance.
+func NewPool(certPool *x509.CertPool) *Pool {
+	if certPool == nil {
+		certPool = x509.NewCertPool()
+	}
+	return &Pool{certPool: certPool, certs: make(map[string]*Certificate)}
+}
+
+// CertPool returns the x509.CertPool of the pool, for use as the roots of
+// certificate verification.
+func (p *Pool) CertPool() *x509.CertPool {
+	return p.certPool
+}
+
+// Add adds cert to the pool and records prov as one of its origins.
+// Certificates are identified by fingerprint, so a certificate already in the
+// pool is not added again.
+//
+// Parameters:
+//   - cert: The certificate to trust
+//   - prov: The TSL service the certificate was selected from
+//
+// Returns:
+//   - true if cert was not in the pool before
+func (p *Pool) Add(cert *x509.Certificate, prov Provenance) bool {
+	fingerprint := Fingerprint(cert)
+	entry, ok := p.certs[fingerprint]
+	if !ok {
+		p.certPool.AddCert(cert)
+		entry = &Certificate{Certificate: cert, Fingerprint: fingerprint}
+		p.certs[fingerprint] = entry
+	}
+	if !slices.Contains(entry.Provenance, prov) {
+		entry.Provenance = append(entry.Provenance, prov)
+	}
+	return !ok
+}
+
+// Certificate returns the certificate of the pool with the given hex SHA-256
+// fingerprint, or nil if there is none.
+func (p *Pool) Certificate(fingerprint string) *Certificate {
+	if p == nil {
+		return nil
+	}
+	return p.certs[fingerprint]
+}
+
+// Provenance returns the TSL services cert was selected from, or nil if it
+// is not in the pool or was added without provenance.
+func (p *Pool) Provenance(cert *x509.Certificate) []Provenance {
+	if entry := p.Certificate(Fingerprint(cert)); entry != nil {
+		return entry.Provenance
+	}
+	return nil
+}
+
+// Certificates returns the certificates of the pool, ordered by subject and
+// then by fingerprint.
+//
+// Returns:
+//   - A slice of pool certificates, empty if none were added
+func (p *Pool) Certificates() []*Certificate {
+	if p == nil {
+		return []*Certificate{}
+	}
+	certs := make([]*Certificate, 0, len(p.certs))
+	for _, entry := range p.certs {
+		certs = append(certs, entry)
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		si, sj := certs[i].Certificate.Subject.String(), certs[j].Certificate.Subject.String()
+		if si != sj {
+			return si < sj
+		}
+		return certs[i].Fingerprint < certs[j].Fingerprint
+	})
+	return certs
+}
+
+// Size returns the number of distinct certificates in the pool and the number
+// of TSL service listings they were selected from. A certificate listed in
+// several TSLs, or under several services, is counted once in certificates
+// and once per listing in listings.
+func (p *Pool) Size() (certificates, listings int) {
+	if p == nil {
+		return 0, 0
+	}
+	for _, entry := range p.certs {
+		listings += len(entry.Provenance)
+	}
+	return len(p.certs), listings
+}
+
+// Clone returns a copy of the pool that can be modified independently of the
+// original. Certificates are shared; their provenance is copied.
+func (p *Pool) Clone() *Pool {
+	clone := &Pool{certPool: p.certPool.Clone(), certs: make(map[string]*Certificate, len(p.certs))}
+	for fingerprint, entry := range p.certs {
+		c := *entry
+		c.Provenance = slices.Clone(entry.Provenance)
+		clone.certs[fingerprint] = &c
+	}
+	return clone
+}
@@ -0,0 +1,85 @@
+package tslpool
+
+import (
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+func TestPoolAdd(t *testing.T) {
+	ca, err := testutil.NewCA("Pool CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := NewPool(nil)
+	first := Provenance{Provider: "P", Service: "S1"}
+	second := Provenance{Provider: "P", Service: "S2"}
+	if !pool.Add(ca.Certificate, first) {
+		t.Error("Add() = false for a new certificate")
+	}
+	if pool.Add(ca.Certificate, second) || pool.Add(ca.Certificate, second) {
+		t.Error("Add() = true for a certificate already in the pool")
+	}
+
+	if certificates, listings := pool.Size(); certificates != 1 || listings != 2 {
+		t.Errorf("Size() = %d, %d, want 1, 2", certificates, listings)
+	}
+	if got := pool.Provenance(ca.Certificate); len(got) != 2 || got[0] != first || got[1] != second {
+		t.Errorf("Provenance() = %v, want [%v %v]", got, first, second)
+	}
+	if !pool.CertPool().Equal(ca.Pool()) {
+		t.Error("CertPool() does not hold exactly the CA certificate")
+	}
+	if entry := pool.Certificate(Fingerprint(ca.Certificate)); entry == nil || entry.Certificate != ca.Certificate {
+		t.Errorf("Certificate() = %v, want the CA certificate", entry)
+	}
+}
+
+func TestPoolCertificates(t *testing.T) {
+	pool := NewPool(nil)
+	for _, name := range []string{"B CA", "A CA"} {
+		ca, err := testutil.NewCA(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Add(ca.Certificate, Provenance{Provider: name})
+	}
+
+	certs := pool.Certificates()
+	if len(certs) != 2 || certs[0].Certificate.Subject.CommonName != "A CA" {
+		t.Errorf("Certificates() not ordered by subject: %v", certs)
+	}
+
+	var nilPool *Pool
+	if len(nilPool.Certificates()) != 0 || nilPool.Certificate("00") != nil || nilPool.Provenance(certs[0].Certificate) != nil {
+		t.Error("a nil pool must be empty")
+	}
+}
+
+func TestPoolClone(t *testing.T) {
+	ca, err := testutil.NewCA("Clone CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := testutil.NewCA("Other CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := NewPool(nil)
+	pool.Add(ca.Certificate, Provenance{Service: "S1"})
+	clone := pool.Clone()
+	clone.Add(ca.Certificate, Provenance{Service: "S2"})
+	clone.Add(other.Certificate, Provenance{Service: "S1"})
+
+	if certificates, listings := pool.Size(); certificates != 1 || listings != 1 {
+		t.Errorf("original Size() = %d, %d after modifying the clone, want 1, 1", certificates, listings)
+	}
+	if certificates, listings := clone.Size(); certificates != 2 || listings != 3 {
+		t.Errorf("clone Size() = %d, %d, want 2, 3", certificates, listings)
+	}
+	if clone.CertPool() == pool.CertPool() {
+		t.Error("the clone shares the x509.CertPool of the original")
+	}
+}
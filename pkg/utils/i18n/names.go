@@ -0,0 +1,201 @@
+// Package i18n selects, from the names that Trust Status Lists give in
+// several languages, the one to show to a reader.
+//
+// The preferred languages come from configuration (SetPreferredLanguages)
+// and, for API requests, from the Accept-Language header (Negotiate). A name
+// is picked by Name: the first preferred language with a name wins, matching
+// a language tag exactly or by its primary language ("sv" matches "sv-FI" and
+// the other way round), then English, then the first name in the list.
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+)
+
+// DefaultLanguage is the language preferred when no preference is configured,
+// and the fallback when no preferred language has a name. TSLs are required
+// to give their names in English.
+const DefaultLanguage = "en"
+
+// languageTag matches the language tags accepted as preferences, such as
+// "en", "sv-FI" or "zh-Hant-TW".
+var languageTag = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// Process-wide language preference, in order
+var (
+	preferredMu sync.RWMutex
+	preferred   = []string{DefaultLanguage}
+)
+
+// ValidateLanguages returns an error if any of langs is not a language tag.
+func ValidateLanguages(langs []string) error {
+	for _, lang := range langs {
+		if !languageTag.MatchString(lang) {
+			return fmt.Errorf("invalid language tag %q", lang)
+		}
+	}
+	return nil
+}
+
+// SetPreferredLanguages sets the languages in which names are shown, most
+// preferred first. An empty list restores the default, English.
+func SetPreferredLanguages(langs []string) {
+	var clean []string
+	for _, lang := range langs {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			clean = append(clean, lang)
+		}
+	}
+	if len(clean) == 0 {
+		clean = []string{DefaultLanguage}
+	}
+	preferredMu.Lock()
+	defer preferredMu.Unlock()
+	preferred = clean
+}
+
+// PreferredLanguages returns the configured language preference, most
+// preferred first.
+func PreferredLanguages() []string {
+	preferredMu.RLock()
+	defer preferredMu.RUnlock()
+	return append([]string(nil), preferred...)
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header in
+// order of preference. The wildcard and languages with q=0 are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var entries []weighted
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang = strings.TrimSpace(lang)
+		if lang == "" || lang == "*" || !languageTag.MatchString(lang) {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				parsed, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+		if q > 0 {
+			entries = append(entries, weighted{lang: lang, q: q})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].q > entries[j].q })
+	langs := make([]string, len(entries))
+	for i, e := range entries {
+		langs[i] = e.lang
+	}
+	return langs
+}
+
+// Negotiate returns the language preference of a request with the given
+// Accept-Language header: the languages of the header, followed by the
+// configured preference.
+func Negotiate(acceptLanguage string) []string {
+	return append(ParseAcceptLanguage(acceptLanguage), PreferredLanguages()...)
+}
+
+// Best returns the name in names that best matches langs, or nil if names
+// has no name. Names without text are never picked.
+func Best(names *etsi119612.InternationalNamesType, langs []string) *etsi119612.MultiLangNormStringType {
+	if names == nil {
+		return nil
+	}
+	candidates := make([]*etsi119612.MultiLangNormStringType, 0, len(names.Name))
+	for _, n := range names.Name {
+		if n != nil && n.NonEmptyNormalizedString != nil && *n.NonEmptyNormalizedString != "" {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	for _, lang := range append(langs[:len(langs):len(langs)], DefaultLanguage) {
+		// An exact match first, then one of the same primary language
+		for _, exact := range []bool{true, false} {
+			for _, n := range candidates {
+				if matches(nameLanguage(n), lang, exact) {
+					return n
+				}
+			}
+		}
+	}
+	return candidates[0]
+}
+
+// Name returns the text of the name in names that best matches langs, or
+// fallback if names has no name.
+func Name(names *etsi119612.InternationalNamesType, langs []string, fallback string) string {
+	if n := Best(names, langs); n != nil {
+		return string(*n.NonEmptyNormalizedString)
+	}
+	return fallback
+}
+
+// Language returns the language of the name in names that best matches
+// langs, or "" if names has no name or the name has no language.
+func Language(names *etsi119612.InternationalNamesType, langs []string) string {
+	if n := Best(names, langs); n != nil {
+		return nameLanguage(n)
+	}
+	return ""
+}
+
+// Match returns the language in available that best matches langs, by the
+// rules of Best, or "" if none matches langs or English.
+func Match(available, langs []string) string {
+	for _, lang := range append(langs[:len(langs):len(langs)], DefaultLanguage) {
+		for _, exact := range []bool{true, false} {
+			for _, have := range available {
+				if matches(have, lang, exact) {
+					return have
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// nameLanguage returns the xml:lang of n, or "".
+func nameLanguage(n *etsi119612.MultiLangNormStringType) string {
+	if n.XmlLangAttr == nil {
+		return ""
+	}
+	return string(*n.XmlLangAttr)
+}
+
+// matches reports whether the language tag have satisfies the preference
+// want, exactly or, unless exact, by primary language.
+func matches(have, want string, exact bool) bool {
+	if have == "" || want == "" {
+		return false
+	}
+	if strings.EqualFold(have, want) {
+		return true
+	}
+	return !exact && strings.EqualFold(primary(have), primary(want))
+}
+
+// primary returns the primary language subtag of a language tag.
+func primary(tag string) string {
+	p, _, _ := strings.Cut(tag, "-")
+	return p
+}
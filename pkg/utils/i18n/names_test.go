@@ -0,0 +1,113 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/SUNET/g119612/pkg/etsi119612"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// names builds an InternationalNamesType from language and name pairs; an
+// empty language leaves xml:lang out.
+func names(pairs ...string) *etsi119612.InternationalNamesType {
+	n := &etsi119612.InternationalNamesType{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		value := etsi119612.NonEmptyNormalizedString(pairs[i+1])
+		name := &etsi119612.MultiLangNormStringType{NonEmptyNormalizedString: &value}
+		if pairs[i] != "" {
+			lang := etsi119612.Lang(pairs[i])
+			name.XmlLangAttr = &lang
+		}
+		n.Name = append(n.Name, name)
+	}
+	return n
+}
+
+func TestName(t *testing.T) {
+	list := names("de", "Anbieter", "sv-FI", "Leverantör", "en", "Provider")
+
+	tests := []struct {
+		name  string
+		names *etsi119612.InternationalNamesType
+		langs []string
+		want  string
+	}{
+		{"exact match", list, []string{"de"}, "Anbieter"},
+		{"primary language of a region", list, []string{"sv"}, "Leverantör"},
+		{"region of a primary language", list, []string{"sv-SE"}, "Leverantör"},
+		{"exact match wins over a variant", names("sv", "Svenska", "sv-FI", "Finlandssvenska"), []string{"sv-FI"}, "Finlandssvenska"},
+		{"preference order", list, []string{"fr", "de", "en"}, "Anbieter"},
+		{"case insensitive", list, []string{"SV-fi"}, "Leverantör"},
+		{"English fallback", list, []string{"fr"}, "Provider"},
+		{"first name fallback", names("de", "Anbieter", "fr", "Fournisseur"), []string{"it"}, "Anbieter"},
+		{"names without language", names("", "Anonymous"), []string{"en"}, "Anonymous"},
+		{"no preference", list, nil, "Provider"},
+		{"no names", &etsi119612.InternationalNamesType{}, []string{"en"}, "Unknown"},
+		{"nil names", nil, []string{"en"}, "Unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Name(tt.names, tt.langs, "Unknown"))
+		})
+	}
+
+	assert.Equal(t, "sv-FI", Language(list, []string{"sv"}))
+	assert.Equal(t, "", Language(nil, []string{"sv"}))
+
+	// Empty names are never picked
+	empty := names("de", "", "en", "Provider")
+	assert.Equal(t, "Provider", Name(empty, []string{"de"}, "Unknown"))
+
+	// The preference of the caller is not modified
+	langs := make([]string, 1, 4)
+	langs[0] = "fr"
+	Name(list, langs, "")
+	assert.Equal(t, []string{"fr"}, langs)
+	assert.Empty(t, langs[:2][1])
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"sv", []string{"sv"}},
+		{"sv-SE,sv;q=0.9,en;q=0.8", []string{"sv-SE", "sv", "en"}},
+		{"en;q=0.5, de", []string{"de", "en"}},
+		{"fr;q=0, de;q=0.1, *;q=0.5", []string{"de"}},
+		{"<script>, fi", []string{"fi"}},
+		{"nl;q=bogus, it", []string{"it"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ParseAcceptLanguage(tt.header), tt.header)
+	}
+}
+
+func TestPreferredLanguages(t *testing.T) {
+	defer SetPreferredLanguages(nil)
+	assert.Equal(t, []string{DefaultLanguage}, PreferredLanguages())
+
+	SetPreferredLanguages([]string{" sv ", "", "en"})
+	assert.Equal(t, []string{"sv", "en"}, PreferredLanguages())
+	assert.Equal(t, []string{"de", "sv", "en"}, Negotiate("de"))
+
+	SetPreferredLanguages(nil)
+	assert.Equal(t, []string{DefaultLanguage}, PreferredLanguages())
+}
+
+func TestValidateLanguages(t *testing.T) {
+	require.NoError(t, ValidateLanguages([]string{"en", "sv-FI", "zh-Hant-TW"}))
+	assert.Error(t, ValidateLanguages([]string{"en", "sv_FI"}))
+	assert.Error(t, ValidateLanguages([]string{""}))
+}
+
+func TestMatch(t *testing.T) {
+	available := []string{"sv", "en", "fi-FI"}
+	assert.Equal(t, "sv", Match(available, []string{"sv-FI", "en"}))
+	assert.Equal(t, "fi-FI", Match(available, []string{"fi"}))
+	assert.Equal(t, "en", Match(available, []string{"de"}))
+	assert.Equal(t, "", Match([]string{"de"}, []string{"fr"}))
+	assert.Equal(t, "", Match(nil, []string{"en"}))
+}
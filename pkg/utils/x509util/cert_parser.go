@@ -1,7 +1,8 @@
-// Package x509util provides X.509 certificate parsing utilities.
+// Package x509util provides X.509 certificate parsing and verification utilities.
 // This package contains shared certificate parsing functions used across
 // go-trust components, particularly for extracting certificates from
-// AuthZEN resource.key arrays in various formats (x5c arrays, JWK objects).
+// AuthZEN resource.key arrays in various formats (x5c arrays, JWK objects),
+// and chain verification with a clock-skew allowance.
 package x509util
 
 import (
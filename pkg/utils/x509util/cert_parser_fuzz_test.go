@@ -0,0 +1,79 @@
+package x509util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// addKeySeeds adds resource.key values of both formats to the seed corpus:
+// valid and broken certificates, wrong types and a JWK with x5c as a string.
+func addKeySeeds(f *testing.F) {
+	_, der, err := generateTestCert()
+	if err != nil {
+		f.Fatalf("Failed to generate test certificate: %v", err)
+	}
+	cert := base64.StdEncoding.EncodeToString(der)
+	for _, seed := range []string{
+		fmt.Sprintf(`[%q]`, cert),
+		fmt.Sprintf(`[%q, %q]`, cert, cert),
+		fmt.Sprintf(`[%q]`, cert[:len(cert)/2]),
+		`["not base64!"]`,
+		`["AAAA"]`,
+		`[42, null, true]`,
+		`[]`,
+		fmt.Sprintf(`[{"kty": "EC", "x5c": [%q]}]`, cert),
+		fmt.Sprintf(`[{"kty": "EC", "x5c": "[\"%s\"]"}]`, cert),
+		`[{"kty": "EC", "x5c": "not json"}]`,
+		`[{"kty": "EC", "x5c": {"nested": []}}]`,
+		`[{"kty": "EC"}]`,
+	} {
+		f.Add([]byte(seed))
+	}
+}
+
+// FuzzParseX5CFromArray checks that any JSON array in resource.key of an x5c
+// request is either rejected or yields one certificate per element.
+func FuzzParseX5CFromArray(f *testing.F) {
+	addKeySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var key []interface{}
+		if err := json.Unmarshal(data, &key); err != nil {
+			t.Skip()
+		}
+		certs, err := ParseX5CFromArray(key)
+		if err != nil {
+			return
+		}
+		if len(certs) != len(key) {
+			t.Fatalf("parsed %d certificates from %d elements", len(certs), len(key))
+		}
+		for i, cert := range certs {
+			if cert == nil {
+				t.Fatalf("certificate %d is nil", i)
+			}
+		}
+	})
+}
+
+// FuzzParseX5CFromJWK checks that any JSON array in resource.key of a jwk
+// request is either rejected or yields certificates without panicking.
+func FuzzParseX5CFromJWK(f *testing.F) {
+	addKeySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var key []interface{}
+		if err := json.Unmarshal(data, &key); err != nil {
+			t.Skip()
+		}
+		certs, err := ParseX5CFromJWK(key)
+		if err != nil {
+			return
+		}
+		for i, cert := range certs {
+			if cert == nil {
+				t.Fatalf("certificate %d is nil", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package x509util
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// clockSkew is the allowance applied to certificate validity periods, in
+// nanoseconds.
+var clockSkew atomic.Int64
+
+// SetClockSkew sets how far the clocks of this host and of certificate
+// issuers may disagree. Certificates that expired, or become valid, within
+// the allowance of the current time are accepted by Verify. A skew of 0 or
+// less disables the allowance.
+func SetClockSkew(skew time.Duration) {
+	clockSkew.Store(int64(max(skew, 0)))
+}
+
+// ClockSkew returns the allowance set with SetClockSkew.
+func ClockSkew() time.Duration {
+	return time.Duration(clockSkew.Load())
+}
+
+// Verify verifies cert like cert.Verify(opts), tolerating the clock skew set
+// with SetClockSkew: if cert or a certificate of its chain is expired or not
+// yet valid at the verification time, verification is repeated at that time
+// shifted back and forward by the allowance. Other errors are returned as
+// they are.
+//
+// Parameters:
+//   - cert: The certificate to verify
+//   - opts: The verification options; CurrentTime defaults to now
+//
+// Returns:
+//   - The verified chains, as returned by x509.Certificate.Verify
+//   - The error of verifying at the unshifted time, if no attempt succeeded
+func Verify(cert *x509.Certificate, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	chains, err := cert.Verify(opts)
+	skew := ClockSkew()
+	var invalid x509.CertificateInvalidError
+	if err == nil || skew <= 0 || !errors.As(err, &invalid) || invalid.Reason != x509.Expired {
+		return chains, err
+	}
+
+	now := opts.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for _, shifted := range []time.Time{now.Add(-skew), now.Add(skew)} {
+		opts.CurrentTime = shifted
+		if chains, shiftedErr := cert.Verify(opts); shiftedErr == nil {
+			return chains, nil
+		}
+	}
+	return nil, err
+}
+
+// VerifyContext verifies cert like Verify, but returns ctx.Err() as soon as
+// ctx is done, so that a decision deadline or a disconnected client ends a
+// verification that stalls. The abandoned verification finishes in the
+// background and its result is discarded.
+func VerifyContext(ctx context.Context, cert *x509.Certificate, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		chains [][]*x509.Certificate
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		chains, err := Verify(cert, opts)
+		done <- result{chains, err}
+	}()
+	select {
+	case r := <-done:
+		return r.chains, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,78 @@
+package x509util
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+// benchPoolSizes are the numbers of roots in the pools verified against; the
+// largest is the size of the certificate pool built from the EU trusted lists.
+var benchPoolSizes = []int{100, 1000, 10000}
+
+var (
+	benchRootsOnce sync.Once
+	benchRoots     []*testutil.Cert
+	benchRootsErr  error
+)
+
+// roots returns n generated root CAs, shared by all benchmarks.
+func roots(b *testing.B, n int) []*testutil.Cert {
+	benchRootsOnce.Do(func() {
+		benchRoots, benchRootsErr = testutil.NewCAs("Bench Root", benchPoolSizes[len(benchPoolSizes)-1])
+	})
+	if benchRootsErr != nil {
+		b.Fatalf("Failed to generate roots: %v", benchRootsErr)
+	}
+	return benchRoots[:n]
+}
+
+// BenchmarkVerify benchmarks chain verification of a leaf issued by the last
+// root of pools of increasing size, directly and through an intermediate.
+func BenchmarkVerify(b *testing.B) {
+	for _, size := range benchPoolSizes {
+		cas := roots(b, size)
+		pool := x509.NewCertPool()
+		for _, ca := range cas {
+			pool.AddCert(ca.Certificate)
+		}
+		issuer := cas[size-1]
+		leaf, err := testutil.NewLeaf(issuer, "leaf")
+		if err != nil {
+			b.Fatalf("Failed to generate leaf: %v", err)
+		}
+		intermediate, err := testutil.NewIntermediateCA(issuer, "intermediate")
+		if err != nil {
+			b.Fatalf("Failed to generate intermediate: %v", err)
+		}
+		indirect, err := testutil.NewLeaf(intermediate, "indirect leaf")
+		if err != nil {
+			b.Fatalf("Failed to generate leaf: %v", err)
+		}
+		intermediates := x509.NewCertPool()
+		intermediates.AddCert(intermediate.Certificate)
+
+		b.Run(fmt.Sprintf("roots=%d/direct", size), func(b *testing.B) {
+			opts := x509.VerifyOptions{Roots: pool}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Verify(leaf.Certificate, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("roots=%d/intermediate", size), func(b *testing.B) {
+			opts := x509.VerifyOptions{Roots: pool, Intermediates: intermediates}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := VerifyContext(context.Background(), indirect.Certificate, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package x509util
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SUNET/go-trust/pkg/testutil"
+)
+
+func TestVerify_ClockSkew(t *testing.T) {
+	defer SetClockSkew(0)
+
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	ca, err := testutil.NewCA("Skew CA", testutil.WithValidity(now.Add(-24*time.Hour), now.Add(365*24*time.Hour)))
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	expired, err := testutil.NewLeaf(ca, "expired", testutil.WithValidity(now.Add(-time.Hour), now.Add(-2*time.Minute)))
+	if err != nil {
+		t.Fatalf("Failed to generate leaf: %v", err)
+	}
+	future, err := testutil.NewLeaf(ca, "future", testutil.WithValidity(now.Add(2*time.Minute), now.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("Failed to generate leaf: %v", err)
+	}
+	longExpired, err := testutil.NewLeaf(ca, "long expired", testutil.WithValidity(now.Add(-2*time.Hour), now.Add(-time.Hour)))
+	if err != nil {
+		t.Fatalf("Failed to generate leaf: %v", err)
+	}
+	opts := x509.VerifyOptions{Roots: ca.Pool(), CurrentTime: now}
+
+	for _, cert := range []*testutil.Cert{expired, future} {
+		if _, err := Verify(cert.Certificate, opts); err == nil {
+			t.Errorf("Verify(%s) without skew should fail", cert.Certificate.Subject.CommonName)
+		}
+	}
+
+	SetClockSkew(5 * time.Minute)
+	if ClockSkew() != 5*time.Minute {
+		t.Errorf("ClockSkew() = %v, want 5m", ClockSkew())
+	}
+	for _, cert := range []*testutil.Cert{expired, future} {
+		if _, err := Verify(cert.Certificate, opts); err != nil {
+			t.Errorf("Verify(%s) with skew error = %v", cert.Certificate.Subject.CommonName, err)
+		}
+	}
+	if _, err := Verify(longExpired.Certificate, opts); err == nil {
+		t.Error("Verify() should fail for certificates expired beyond the skew")
+	}
+
+	// The skew does not excuse other failures
+	other, err := testutil.NewCA("Other CA")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	if _, err := Verify(expired.Certificate, x509.VerifyOptions{Roots: other.Pool(), CurrentTime: now}); err == nil {
+		t.Error("Verify() should fail for an unknown authority")
+	}
+
+	SetClockSkew(-time.Minute)
+	if ClockSkew() != 0 {
+		t.Errorf("ClockSkew() = %v after a negative skew, want 0", ClockSkew())
+	}
+}
+
+func TestVerifyContext(t *testing.T) {
+	ca, err := testutil.NewCA("Context CA")
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	leaf, err := testutil.NewLeaf(ca, "leaf")
+	if err != nil {
+		t.Fatalf("Failed to generate leaf: %v", err)
+	}
+	opts := x509.VerifyOptions{Roots: ca.Pool()}
+
+	chains, err := VerifyContext(context.Background(), leaf.Certificate, opts)
+	if err != nil || len(chains) == 0 {
+		t.Fatalf("VerifyContext() = %v, %v, want a chain", chains, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := VerifyContext(ctx, leaf.Certificate, opts); !errors.Is(err, context.Canceled) {
+		t.Errorf("VerifyContext() with a cancelled context error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package yamlutil decodes YAML strictly: a mapping key that is not a field
+// of the Go type it is decoded into is an error naming the key, its line and
+// the closest field, instead of being silently ignored as by yaml.Unmarshal.
+package yamlutil
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownFieldError reports a mapping key that is not a field of the type it
+// is decoded into.
+type UnknownFieldError struct {
+	Field      string // The key
+	Line       int    // Line of the key
+	Suggestion string // Closest known field, empty if none is close
+}
+
+func (e *UnknownFieldError) Error() string {
+	msg := fmt.Sprintf("unknown field %q at line %d", e.Field, e.Line)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", e.Suggestion)
+	}
+	return msg
+}
+
+var (
+	nodeType        = reflect.TypeOf(yaml.Node{})
+	unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+)
+
+// Unmarshal decodes data into out like yaml.Unmarshal, but fails with an
+// UnknownFieldError for every key that is not a field of the type of out or
+// of the types nested in it. Nothing is decoded if there are unknown fields.
+func Unmarshal(data []byte, out any) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	if err := CheckFields(&doc, out); err != nil {
+		return err
+	}
+	return doc.Decode(out)
+}
+
+// CheckFields returns the keys of node that are not fields of the type of
+// out or of the types nested in it, as UnknownFieldErrors joined in document
+// order, or nil if there are none. Types that unmarshal themselves, yaml.Node
+// and interfaces accept any key.
+func CheckFields(node *yaml.Node, out any) error {
+	var errs []error
+	checkNode(node, reflect.TypeOf(out), &errs)
+	return errors.Join(errs...)
+}
+
+func checkNode(n *yaml.Node, t reflect.Type, errs *[]error) {
+	if n == nil || t == nil {
+		return
+	}
+	if n.Kind == yaml.DocumentNode {
+		for _, c := range n.Content {
+			checkNode(c, t, errs)
+		}
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nodeType || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields, open := structFields(t)
+		if open {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Tag == "!!merge" {
+				checkNode(value, t, errs)
+				continue
+			}
+			ft, ok := fields[key.Value]
+			if !ok {
+				*errs = append(*errs, &UnknownFieldError{Field: key.Value, Line: key.Line, Suggestion: Suggest(key.Value, mapKeys(fields))})
+				continue
+			}
+			checkNode(value, ft, errs)
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 1; i < len(n.Content); i += 2 {
+			checkNode(n.Content[i], t.Elem(), errs)
+		}
+	case reflect.Slice, reflect.Array:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, c := range n.Content {
+			checkNode(c, t.Elem(), errs)
+		}
+	}
+}
+
+// structFields returns the types of the fields of the struct type t by YAML
+// key, as yaml.v3 maps them, and whether t accepts any key through an inline
+// map.
+func structFields(t reflect.Type) (map[string]reflect.Type, bool) {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if strings.Contains(","+opts+",", ",inline,") {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Map {
+				return nil, true
+			}
+			inline, open := structFields(ft)
+			if open {
+				return nil, true
+			}
+			for k, v := range inline {
+				fields[k] = v
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields, false
+}
+
+func mapKeys(m map[string]reflect.Type) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Suggest returns the candidate closest to name, for "did you mean" hints:
+// one that differs only in case and underscores, or else the one with the
+// smallest edit distance if that is at most a third of the length of name.
+// It returns "" if no candidate is close.
+func Suggest(name string, candidates []string) string {
+	normalized := normalize(name)
+	best, bestDistance := "", len(name)/3+1
+	for _, c := range candidates {
+		if normalize(c) == normalized {
+			return c
+		}
+		if d := distance(name, c); d < bestDistance || (d == bestDistance && best != "" && c < best) {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(s))
+}
+
+// distance returns the edit distance between a and b, counting insertions,
+// deletions, substitutions and transpositions of adjacent characters.
+func distance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
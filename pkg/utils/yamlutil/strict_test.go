@@ -0,0 +1,108 @@
+package yamlutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type testServer struct {
+	Host      string        `yaml:"host"`
+	Frequency time.Duration `yaml:"frequency"`
+	Ignored   string        `yaml:"-"`
+}
+
+type testBase struct {
+	Name string `yaml:"name"`
+}
+
+type testConfig struct {
+	testBase `yaml:",inline"`
+	Server   testServer             `yaml:"server"`
+	Tenants  []testServer           `yaml:"tenants"`
+	Named    map[string]*testServer `yaml:"named"`
+	Raw      map[string]yaml.Node   `yaml:"raw"`
+	MaxSize  int                    `yaml:"max_download_size"`
+	Untagged string
+}
+
+func TestUnmarshal(t *testing.T) {
+	var cfg testConfig
+	require.NoError(t, Unmarshal([]byte(`
+name: n
+server:
+  host: h
+  frequency: 1m
+tenants:
+  - host: a
+named:
+  x: {host: b}
+raw:
+  anything: {goes: here}
+max_download_size: 10
+untagged: u
+`), &cfg))
+	assert.Equal(t, "n", cfg.Name)
+	assert.Equal(t, time.Minute, cfg.Server.Frequency)
+	assert.Equal(t, "b", cfg.Named["x"].Host)
+	assert.Equal(t, "u", cfg.Untagged)
+
+	require.NoError(t, Unmarshal([]byte(""), &cfg))
+}
+
+func TestUnmarshal_UnknownFields(t *testing.T) {
+	cfg := testConfig{testBase: testBase{Name: "unchanged"}}
+	err := Unmarshal([]byte(`name: changed
+server:
+  frequncy: 1m
+tenants:
+  - hots: a
+named:
+  x: {port: 1}
+maxDownloadSize: 10
+ignored: x
+`), &cfg)
+	require.Error(t, err)
+	assert.Equal(t, "unchanged", cfg.Name, "nothing is decoded")
+
+	lines := []string{
+		`unknown field "frequncy" at line 3, did you mean "frequency"?`,
+		`unknown field "hots" at line 5, did you mean "host"?`,
+		`unknown field "port" at line 7`,
+		`unknown field "maxDownloadSize" at line 8, did you mean "max_download_size"?`,
+		`unknown field "ignored" at line 9`,
+	}
+	assert.Equal(t, lines[0]+"\n"+lines[1]+"\n"+lines[2]+"\n"+lines[3]+"\n"+lines[4], err.Error())
+
+	var unknown *UnknownFieldError
+	require.True(t, errors.As(err, &unknown))
+	assert.Equal(t, "frequncy", unknown.Field)
+	assert.Equal(t, 3, unknown.Line)
+}
+
+func TestUnmarshal_Merge(t *testing.T) {
+	var cfg testConfig
+	err := Unmarshal([]byte(`
+defaults: &defaults
+  host: h
+server:
+  <<: *defaults
+  frequency: 1m
+`), &cfg)
+	require.Error(t, err, "defaults is not a field")
+	assert.Contains(t, err.Error(), `unknown field "defaults" at line 2`)
+	assert.NotContains(t, err.Error(), "<<")
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"frequency", "frequency_jitter", "host", "port"}
+	assert.Equal(t, "frequency", Suggest("frequncy", candidates))
+	assert.Equal(t, "frequency_jitter", Suggest("FrequencyJitter", candidates))
+	assert.Equal(t, "port", Suggest("prt", candidates))
+	assert.Equal(t, "", Suggest("timeout", candidates))
+	assert.Equal(t, "", Suggest("x", nil))
+}
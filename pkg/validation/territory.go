@@ -0,0 +1,81 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownTerritory is returned for territory codes that are neither ISO
+// 3166-1 alpha-2 codes nor one of the codes EU trusted lists use instead or
+// besides.
+var ErrUnknownTerritory = errors.New("unknown territory code")
+
+// iso3166Alpha2 lists the officially assigned ISO 3166-1 alpha-2 codes.
+const iso3166Alpha2 = "" +
+	"AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ " +
+	"BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ " +
+	"CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ " +
+	"DE DJ DK DM DO DZ " +
+	"EC EE EG EH ER ES ET " +
+	"FI FJ FK FM FO FR " +
+	"GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY " +
+	"HK HM HN HR HT HU " +
+	"ID IE IL IM IN IO IQ IR IS IT " +
+	"JE JM JO JP " +
+	"KE KG KH KI KM KN KP KR KW KY KZ " +
+	"LA LB LC LI LK LR LS LT LU LV LY " +
+	"MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ " +
+	"NA NC NE NF NG NI NL NO NP NR NU NZ " +
+	"OM " +
+	"PA PE PF PG PH PK PL PM PN PR PS PT PW PY " +
+	"QA " +
+	"RE RO RS RU RW " +
+	"SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ " +
+	"TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ " +
+	"UA UG UM US UY UZ " +
+	"VA VC VE VG VI VN VU " +
+	"WF WS " +
+	"YE YT " +
+	"ZA ZM ZW"
+
+// territoryAliases maps the ISO 3166-1 codes of Greece and the United
+// Kingdom to EL and UK, the codes of ETSI TS 119 612 and the EU trusted
+// lists.
+var territoryAliases = map[string]string{
+	"GR": "EL",
+	"GB": "UK",
+}
+
+// territories is the set of known territory codes: the ISO 3166-1 alpha-2
+// codes, EL and UK, and the special values EU, for the EU list of trusted
+// lists, and EEA, for lists covering the European Economic Area.
+var territories = func() map[string]bool {
+	codes := map[string]bool{"EL": true, "UK": true, "EU": true, "EEA": true}
+	for _, code := range strings.Fields(iso3166Alpha2) {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// NormalizeTerritory trims and upper-cases a scheme territory code and
+// replaces GR and GB with EL and UK, as used by EU trusted lists, so that
+// either spelling matches the same lists.
+//
+// Parameters:
+//   - code: A territory code, such as "se", "GR" or "EU"
+//
+// Returns:
+//   - The normalized code, also when the code is unknown
+//   - An error wrapping ErrUnknownTerritory if the code is neither an ISO
+//     3166-1 alpha-2 code nor EL, UK, EU or EEA
+func NormalizeTerritory(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if alias, ok := territoryAliases[code]; ok {
+		code = alias
+	}
+	if !territories[code] {
+		return code, fmt.Errorf("%w %q", ErrUnknownTerritory, code)
+	}
+	return code, nil
+}
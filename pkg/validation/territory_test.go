@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTerritory(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{code: "SE", want: "SE"},
+		{code: " se ", want: "SE"},
+		{code: "EU", want: "EU"},
+		{code: "eea", want: "EEA"},
+		{code: "EL", want: "EL"},
+		{code: "GR", want: "EL"},
+		{code: "gr", want: "EL"},
+		{code: "UK", want: "UK"},
+		{code: "GB", want: "UK"},
+		{code: "XX", want: "XX", wantErr: true},
+		{code: "SWE", want: "SWE", wantErr: true},
+		{code: "", want: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, err := NormalizeTerritory(tt.code)
+			if got != tt.want {
+				t.Errorf("NormalizeTerritory(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NormalizeTerritory(%q) error = %v, wantErr %v", tt.code, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUnknownTerritory) {
+				t.Errorf("NormalizeTerritory(%q) error = %v, want ErrUnknownTerritory", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestISO3166Alpha2(t *testing.T) {
+	codes := strings.Fields(iso3166Alpha2)
+	if len(codes) != 249 {
+		t.Errorf("got %d ISO 3166-1 alpha-2 codes, want 249", len(codes))
+	}
+	for i := 1; i < len(codes); i++ {
+		if codes[i-1] >= codes[i] {
+			t.Errorf("codes not sorted or duplicated at %s, %s", codes[i-1], codes[i])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+#!/usr/bin/env bash
+# Check benchmarks for regressions against the merge base
+#
+# Usage:
+#   scripts/bench-check.sh BASE PATTERN COUNT THRESHOLD PACKAGE...
+#
+# Runs the benchmarks of PACKAGEs matching PATTERN COUNT times on the merge
+# base of HEAD and BASE, checked out in a temporary worktree, and then on the
+# working tree, and compares the two with bench-compare.sh. Both runs are made
+# on the same machine, one after the other, so the comparison does not depend
+# on the hardware the check runs on. The results are left in bench-base.out and
+# bench.out, e.g. for benchstat.
+
+set -e
+
+if [ $# -lt 5 ]; then
+    echo "Usage: $0 BASE PATTERN COUNT THRESHOLD PACKAGE..."
+    exit 2
+fi
+
+BASE="$1"
+PATTERN="$2"
+COUNT="$3"
+THRESHOLD="$4"
+shift 4
+
+ROOT=$(git rev-parse --show-toplevel)
+MERGE_BASE=$(git merge-base HEAD "$BASE")
+WORKTREE=$(mktemp -d)
+trap 'git -C "$ROOT" worktree remove --force "$WORKTREE" >/dev/null 2>&1 || rm -rf "$WORKTREE"' EXIT
+git -C "$ROOT" worktree add --quiet --detach "$WORKTREE" "$MERGE_BASE" >/dev/null
+
+echo "Benchmarking merge base $(git rev-parse --short "$MERGE_BASE") of HEAD and $BASE..."
+(cd "$WORKTREE" && go test "$@" -bench="$PATTERN" -run='^$' -benchmem -count="$COUNT") > "$ROOT/bench-base.out"
+
+echo "Benchmarking the working tree..."
+(cd "$ROOT" && go test "$@" -bench="$PATTERN" -run='^$' -benchmem -count="$COUNT") > "$ROOT/bench.out"
+
+bash "$ROOT/scripts/bench-compare.sh" "$ROOT/bench-base.out" "$ROOT/bench.out" "$THRESHOLD"
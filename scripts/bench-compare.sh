@@ -0,0 +1,64 @@
+#!/usr/bin/env bash
+# Compare benchmark results against a baseline
+#
+# Usage:
+#   scripts/bench-compare.sh BASELINE CURRENT [THRESHOLD]
+#
+# BASELINE and CURRENT are outputs of `go test -bench` on the same machine
+# (see bench-check.sh), typically run with -count greater than one; the time per operation of each benchmark is
+# averaged over its runs. A benchmark whose average time per operation
+# exceeds its baseline by more than THRESHOLD percent (default: 20) is a
+# regression, and the script exits with status 1. Benchmarks missing from
+# either file are reported but do not fail the comparison.
+
+set -e
+
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+YELLOW='\033[1;33m'
+NC='\033[0m' # No Color
+
+BASELINE="$1"
+CURRENT="$2"
+THRESHOLD="${3:-20}"
+
+if [ ! -f "$BASELINE" ] || [ ! -f "$CURRENT" ]; then
+    echo "Usage: $0 BASELINE CURRENT [THRESHOLD]"
+    exit 2
+fi
+
+# Print "<name> <average ns/op>" for each benchmark, without the -GOMAXPROCS suffix
+averages() {
+    awk '/^Benchmark/ {
+        for (i = 2; i < NF; i++) {
+            if ($(i+1) == "ns/op") {
+                name = $1
+                sub(/-[0-9]+$/, "", name)
+                sum[name] += $i
+                n[name]++
+            }
+        }
+    }
+    END { for (name in sum) printf "%s %.0f\n", name, sum[name] / n[name] }' "$1" | sort
+}
+
+compare() {
+    join -a 1 -a 2 -e missing -o 0,1.2,2.2 <(averages "$BASELINE") <(averages "$CURRENT") |
+    awk -v threshold="$THRESHOLD" -v red="$RED" -v green="$GREEN" -v yellow="$YELLOW" -v nc="$NC" '
+    $2 == "missing" { printf "%s%-60s %14s %14s ns/op  new%s\n", yellow, $1, "-", $3, nc; next }
+    $3 == "missing" { printf "%s%-60s %14s %14s ns/op  removed%s\n", yellow, $1, $2, "-", nc; next }
+    {
+        delta = ($3 - $2) * 100 / $2
+        color = (delta > threshold) ? red : green
+        printf "%s%-60s %14d %14d ns/op  %+6.1f%%%s\n", color, $1, $2, $3, delta, nc
+        if (delta > threshold) failed++
+    }
+    END { exit failed > 0 }'
+}
+
+printf "%-60s %14s %14s\n" "benchmark" "baseline" "current"
+if ! compare; then
+    echo -e "${RED}✗ Benchmarks regressed by more than ${THRESHOLD}% against ${BASELINE}${NC}"
+    exit 1
+fi
+echo -e "${GREEN}✓ No benchmark regressed by more than ${THRESHOLD}%${NC}"